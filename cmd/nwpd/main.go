@@ -8,10 +8,16 @@ import (
 	"github.com/gardener/network-problem-detector/pkg/agent"
 	"github.com/gardener/network-problem-detector/pkg/aggregate"
 	"github.com/gardener/network-problem-detector/pkg/collect"
+	"github.com/gardener/network-problem-detector/pkg/compare"
 	"github.com/gardener/network-problem-detector/pkg/controller"
+	"github.com/gardener/network-problem-detector/pkg/convert"
 	"github.com/gardener/network-problem-detector/pkg/deploy"
+	"github.com/gardener/network-problem-detector/pkg/external"
+	"github.com/gardener/network-problem-detector/pkg/generate"
 	"github.com/gardener/network-problem-detector/pkg/list"
+	"github.com/gardener/network-problem-detector/pkg/matrix"
 	"github.com/gardener/network-problem-detector/pkg/query"
+	"github.com/gardener/network-problem-detector/pkg/status"
 
 	"github.com/spf13/cobra"
 )
@@ -35,8 +41,14 @@ func main() {
 	rootCmd.AddCommand(collect.CreateCollectCmd())
 	rootCmd.AddCommand(collect.CreateRunCollectCmd())
 	rootCmd.AddCommand(aggregate.CreateAggregateCmd())
+	rootCmd.AddCommand(compare.CreateCompareCmd())
+	rootCmd.AddCommand(convert.CreateConvertCmd())
 	rootCmd.AddCommand(query.CreateQueryCmd())
 	rootCmd.AddCommand(list.CreateListCmd())
+	rootCmd.AddCommand(matrix.CreateMatrixCmd())
+	rootCmd.AddCommand(generate.CreateGenerateCmd())
+	rootCmd.AddCommand(status.CreateStatusCmd())
+	rootCmd.AddCommand(external.CreateRunExternalCmd())
 	err := rootCmd.Execute()
 	if err != nil {
 		panic(err)
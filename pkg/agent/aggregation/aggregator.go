@@ -50,6 +50,8 @@ type obsAggr struct {
 	lastReport              time.Time
 	lastReportToK8sExporter time.Time
 	lastK8sExporterStatus   bool
+	// lastAlerts are the alerting edges of the last report
+	lastAlerts map[jobEdge]time.Time
 }
 
 type jobEdge struct {
@@ -68,6 +70,60 @@ type ObservationListenerExtended interface {
 	nwpd.ObservationListener
 
 	UpdateValidEdges(edges ValidEdges)
+	// EdgeState returns the state of the edge and its last observation.
+	EdgeState(jobID, srcHost, destHost string) (EdgeState, *nwpd.Observation)
+	// ListEdges returns the current state of the aggregated edges sorted by job ID, source and destination host.
+	// At most limit items are returned starting with the item at offset.
+	ListEdges(offset, limit int) *EdgeStatusList
+	// ReportEvent creates an event for the node if the K8s exporter is enabled.
+	ReportEvent(severity types.Severity, reason, message string)
+}
+
+// EdgeState is the state of an edge derived from its observations.
+type EdgeState int
+
+const (
+	// EdgeStateUnknown means there are no observations for the edge.
+	EdgeStateUnknown EdgeState = iota
+	// EdgeStateOK means all observations of the current report period were successful.
+	EdgeStateOK
+	// EdgeStateDegraded means the last observation was successful, but there were failures in the current report period.
+	EdgeStateDegraded
+	// EdgeStateFailing means the last observation failed.
+	EdgeStateFailing
+)
+
+// EdgeStatus is the current state of an aggregated job edge.
+type EdgeStatus struct {
+	JobID                   string     `json:"jobID"`
+	SrcHost                 string     `json:"srcHost"`
+	DestHost                string     `json:"destHost"`
+	DestAddress             string     `json:"destAddress,omitempty"`
+	Protocol                string     `json:"protocol,omitempty"`
+	DestIP                  string     `json:"destIP,omitempty"`
+	DestPort                int32      `json:"destPort,omitempty"`
+	FirstTime               time.Time  `json:"firstTime"`
+	TotalCount              int        `json:"totalCount"`
+	LastOk                  bool       `json:"lastOk"`
+	LastSuccess             *time.Time `json:"lastSuccess,omitempty"`
+	OkStrike                int        `json:"okStrike"`
+	LastFailure             *time.Time `json:"lastFailure,omitempty"`
+	FailedStrike            int        `json:"failedStrike"`
+	PartialCount            int        `json:"partialCount,omitempty"`
+	LastPartial             *time.Time `json:"lastPartial,omitempty"`
+	MaintenanceCount        int        `json:"maintenanceCount,omitempty"`
+	InMaintenance           bool       `json:"inMaintenance,omitempty"`
+	WarmingUpCount          int        `json:"warmingUpCount,omitempty"`
+	WarmingUp               bool       `json:"warmingUp,omitempty"`
+	SecondsSinceLastSuccess float64    `json:"secondsSinceLastSuccess"`
+}
+
+// EdgeStatusList is a page of edge states.
+type EdgeStatusList struct {
+	Timestamp time.Time    `json:"timestamp"`
+	Total     int          `json:"total"`
+	Offset    int          `json:"offset"`
+	Items     []EdgeStatus `json:"items"`
 }
 
 func (je jobEdge) String() string {
@@ -80,13 +136,22 @@ type jobEdgeAggregation struct {
 	reportStart        time.Time
 	reportOkCount      int
 	reportFailureCount int
-	okLast             time.Time
-	okStrikeFirst      time.Time
-	okStrike           int
-	failedLast         time.Time
-	failedStrikeFirst  time.Time
-	failedStrike       int
-	lastObs            *nwpd.Observation
+	reportPartialCount int
+	// reportMaintenanceOkCount and reportMaintenanceFailureCount count the observations during maintenance windows
+	// separately, so that they are neither included in the issues nor in the node condition.
+	reportMaintenanceOkCount      int
+	reportMaintenanceFailureCount int
+	okLast                        time.Time
+	okStrikeFirst                 time.Time
+	okStrike                      int
+	failedLast                    time.Time
+	failedStrikeFirst             time.Time
+	failedStrike                  int
+	partialCount                  int
+	partialLast                   time.Time
+	maintenanceCount              int
+	warmingUpCount                int
+	lastObs                       *nwpd.Observation
 }
 
 func (jea *jobEdgeAggregation) IsOKSinceLastReport() bool {
@@ -115,16 +180,60 @@ func (jea *jobEdgeAggregation) Report(je jobEdge, start time.Time) string {
 		if jea.lastObs != nil && jea.lastObs.Timestamp.AsTime().Before(start) {
 			msg += fmt.Sprintf(" last observed: %s", common.FormatAsUTC(jea.lastObs.Timestamp.AsTime()))
 		}
-		return msg
+		return msg + jea.triggeredBySuffix()
+	}
+	now := time.Now()
+	seconds := int(now.Sub(jea.reportStart).Seconds())
+	partial := ""
+	if jea.reportPartialCount > 0 {
+		partial = fmt.Sprintf(" (%d partially)", jea.reportPartialCount)
+	}
+	msg := fmt.Sprintf("%s: %d/%d checks failed%s in last %ds (last ok: %s)", je,
+		jea.reportFailureCount, jea.reportFailureCount+jea.reportOkCount, partial, seconds, common.FormatAsUTC(jea.okLast))
+	if !jea.IsLastOK() {
+		msg = fmt.Sprintf("DARK for %s: %s", jea.SinceLastSuccess(now).Truncate(time.Second), msg)
+	}
+	return msg + jea.triggeredBySuffix()
+}
+
+// HasMaintenanceSinceLastReport returns true if there were observations during a maintenance window since the last report.
+func (jea *jobEdgeAggregation) HasMaintenanceSinceLastReport() bool {
+	return jea.reportMaintenanceOkCount+jea.reportMaintenanceFailureCount > 0
+}
+
+// MaintenanceReport reports the observations during maintenance windows since the last report.
+func (jea *jobEdgeAggregation) MaintenanceReport(je jobEdge) string {
+	return fmt.Sprintf("%s: %d/%d checks failed during maintenance", je, jea.reportMaintenanceFailureCount,
+		jea.reportMaintenanceFailureCount+jea.reportMaintenanceOkCount)
+}
+
+func (jea *jobEdgeAggregation) triggeredBySuffix() string {
+	if jea.lastObs == nil || jea.lastObs.TriggeredBy == "" {
+		return ""
 	}
-	seconds := int(time.Now().Sub(jea.reportStart).Seconds())
-	return fmt.Sprintf("%s: %d/%d checks failed in last %ds (last ok: %s)", je,
-		jea.reportFailureCount, jea.reportFailureCount+jea.reportOkCount, seconds, common.FormatAsUTC(jea.okLast))
+	return fmt.Sprintf(" (triggered by %s)", jea.lastObs.TriggeredBy)
+}
+
+// SinceLastSuccess returns the duration since the last successful observation
+// or since the first observation if there was no successful one.
+func (jea *jobEdgeAggregation) SinceLastSuccess(now time.Time) time.Duration {
+	if jea.okLast.IsZero() {
+		return now.Sub(jea.firstTime)
+	}
+	return now.Sub(jea.okLast)
 }
 
 func (jea *jobEdgeAggregation) add(obs *nwpd.Observation) {
 	jea.totalCount++
 	jea.lastObs = obs
+	if obs.Maintenance {
+		jea.maintenanceCount++
+	}
+	if obs.WarmingUp {
+		// the destination pod has been started recently and may not listen yet, so the observation is neutral
+		jea.warmingUpCount++
+		return
+	}
 	if obs.Ok {
 		if jea.okLast.Before(jea.failedLast) {
 			jea.okStrike = 0
@@ -132,7 +241,11 @@ func (jea *jobEdgeAggregation) add(obs *nwpd.Observation) {
 		}
 		jea.okLast = obs.Timestamp.AsTime()
 		jea.okStrike++
-		jea.reportOkCount++
+		if obs.Maintenance {
+			jea.reportMaintenanceOkCount++
+		} else {
+			jea.reportOkCount++
+		}
 	} else {
 		if jea.failedLast.Before(jea.okLast) {
 			jea.failedStrike = 0
@@ -140,7 +253,18 @@ func (jea *jobEdgeAggregation) add(obs *nwpd.Observation) {
 		}
 		jea.failedLast = obs.Timestamp.AsTime()
 		jea.failedStrike++
-		jea.reportFailureCount++
+		if obs.Partial {
+			jea.partialLast = obs.Timestamp.AsTime()
+			jea.partialCount++
+		}
+		if obs.Maintenance {
+			jea.reportMaintenanceFailureCount++
+		} else {
+			jea.reportFailureCount++
+			if obs.Partial {
+				jea.reportPartialCount++
+			}
+		}
 	}
 }
 
@@ -178,6 +302,10 @@ func (c *groupCounter) inc(key string, ok *bool) {
 	}
 }
 
+func (c *groupCounter) size() int {
+	return len(c.ok) + len(c.unknown) + len(c.failed)
+}
+
 func (c *groupCounter) summary() string {
 	var failedNames []string
 	for key := range c.failed {
@@ -339,6 +467,101 @@ func (a *obsAggr) Add(obs *nwpd.Observation) {
 	}
 }
 
+func (a *obsAggr) EdgeState(jobID, srcHost, destHost string) (EdgeState, *nwpd.Observation) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	jea := a.aggregations[jobEdge{jobID: jobID, srcHost: srcHost, destHost: destHost}]
+	if jea == nil || jea.lastObs == nil {
+		return EdgeStateUnknown, nil
+	}
+	switch {
+	case !jea.lastObs.Ok:
+		return EdgeStateFailing, jea.lastObs
+	case jea.reportFailureCount > 0:
+		return EdgeStateDegraded, jea.lastObs
+	default:
+		return EdgeStateOK, jea.lastObs
+	}
+}
+
+func (a *obsAggr) ListEdges(offset, limit int) *EdgeStatusList {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	edges := make([]jobEdge, 0, len(a.aggregations))
+	for je := range a.aggregations {
+		if a.isValidEdge(je) {
+			edges = append(edges, je)
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].jobID != edges[j].jobID {
+			return edges[i].jobID < edges[j].jobID
+		}
+		if edges[i].srcHost != edges[j].srcHost {
+			return edges[i].srcHost < edges[j].srcHost
+		}
+		return edges[i].destHost < edges[j].destHost
+	})
+
+	list := &EdgeStatusList{
+		Timestamp: time.Now().UTC(),
+		Total:     len(edges),
+		Offset:    offset,
+		Items:     []EdgeStatus{},
+	}
+	if offset < 0 || offset >= len(edges) {
+		return list
+	}
+	end := len(edges)
+	if limit >= 0 && offset+limit < end {
+		end = offset + limit
+	}
+	for _, je := range edges[offset:end] {
+		jea := a.aggregations[je]
+		status := EdgeStatus{
+			JobID:        je.jobID,
+			SrcHost:      je.srcHost,
+			DestHost:     je.destHost,
+			FirstTime:    jea.firstTime.UTC(),
+			TotalCount:   jea.totalCount,
+			LastOk:       jea.IsLastOK(),
+			OkStrike:     jea.okStrike,
+			FailedStrike: jea.failedStrike,
+			PartialCount: jea.partialCount,
+
+			MaintenanceCount: jea.maintenanceCount,
+			WarmingUpCount:   jea.warmingUpCount,
+
+			SecondsSinceLastSuccess: jea.SinceLastSuccess(list.Timestamp).Seconds(),
+		}
+		if jea.lastObs != nil {
+			// the address may change over the lifetime of the edge, e.g. on a new IP of a node
+			status.DestAddress = jea.lastObs.DestAddress
+			status.Protocol = jea.lastObs.Protocol
+			status.DestIP = jea.lastObs.DestIP
+			status.DestPort = jea.lastObs.DestPort
+			status.InMaintenance = jea.lastObs.Maintenance
+			status.WarmingUp = jea.lastObs.WarmingUp
+		}
+		if !jea.okLast.IsZero() {
+			t := jea.okLast.UTC()
+			status.LastSuccess = &t
+		}
+		if !jea.partialLast.IsZero() {
+			t := jea.partialLast.UTC()
+			status.LastPartial = &t
+		}
+		if !jea.failedLast.IsZero() {
+			t := jea.failedLast.UTC()
+			status.LastFailure = &t
+		}
+		list.Items = append(list.Items, status)
+	}
+	return list
+}
+
 type reportOptions struct {
 	fullReport               bool
 	hostNetwork              bool
@@ -356,9 +579,19 @@ type reportData struct {
 	noissues    []string
 	issues      []string
 	status      *conditionStatus
-}
-
-func newReportData(start, end time.Time, options *reportOptions) *reportData {
+	// ingressCounter and ingressIssues group the edges to load balancers and ingress endpoints
+	ingressCounter *groupCounter
+	ingressIssues  []string
+	// serviceCounter groups the edges to services selected by label
+	serviceCounter *groupCounter
+	// maintenanceCounter and maintenanceIssues group the edges with observations during maintenance windows
+	maintenanceCounter *groupCounter
+	maintenanceIssues  []string
+	// previousAlerts are the alerting edges of the previous report
+	previousAlerts map[jobEdge]time.Time
+}
+
+func newReportData(start, end time.Time, options *reportOptions, previousAlerts map[jobEdge]time.Time) *reportData {
 	return &reportData{
 		options:     options,
 		start:       start,
@@ -367,21 +600,53 @@ func newReportData(start, end time.Time, options *reportOptions) *reportData {
 		srcCounter:  newGroupCounter(),
 		destCounter: newGroupCounter(),
 		status:      newConditionStatus(options.hostNetwork),
+
+		ingressCounter:     newGroupCounter(),
+		serviceCounter:     newGroupCounter(),
+		maintenanceCounter: newGroupCounter(),
+		previousAlerts:     previousAlerts,
 	}
 }
 
 func (r *reportData) add(je jobEdge, aggr *jobEdgeAggregation) {
+	if aggr.HasMaintenanceSinceLastReport() {
+		good := aggr.reportMaintenanceFailureCount == 0
+		r.maintenanceCounter.inc(je.String(), &good)
+		if !good {
+			r.maintenanceIssues = append(r.maintenanceIssues, aggr.MaintenanceReport(je))
+		}
+		// no transitions of the node condition during maintenance
+		if firstTime, alerting := r.previousAlerts[je]; alerting {
+			r.status.update(je, true, firstTime)
+		}
+		if aggr.reportFailureCount == 0 && aggr.reportOkCount == 0 {
+			return
+		}
+	}
 	var ok *bool
 	if aggr.reportFailureCount != 0 || aggr.reportOkCount != 0 {
 		good := aggr.reportFailureCount == 0
 		ok = &good
-		r.updateStatus(je, aggr)
+		if !aggr.HasMaintenanceSinceLastReport() {
+			r.updateStatus(je, aggr)
+		}
 	}
 	r.jobCounter.inc(je.jobID, ok)
 	r.srcCounter.inc(je.srcHost, ok)
 	r.destCounter.inc(je.destHost, ok)
+	ingress := isIngressEdge(je)
+	if ingress {
+		r.ingressCounter.inc(je.destHost, ok)
+	}
+	if isServiceEdge(je) {
+		r.serviceCounter.inc(je.destHost, ok)
+	}
 	if ok != nil && !*ok {
-		r.issues = append(r.issues, aggr.Report(je, r.start))
+		if ingress {
+			r.ingressIssues = append(r.ingressIssues, aggr.Report(je, r.start))
+		} else {
+			r.issues = append(r.issues, aggr.Report(je, r.start))
+		}
 	} else if r.options.fullReport || ok == nil {
 		if r.options.fullReport || aggr.IsOverdue() {
 			r.noissues = append(r.noissues, aggr.Report(je, r.start))
@@ -389,6 +654,19 @@ func (r *reportData) add(je jobEdge, aggr *jobEdgeAggregation) {
 	}
 }
 
+// isIngressEdge returns true for edges to load balancers and ingress endpoints.
+// Their failures are usually caused by the load balancer or the ingress controller, not by the cluster network.
+func isIngressEdge(je jobEdge) bool {
+	return strings.HasPrefix(je.destHost, common.DestHostPrefixLoadBalancer) ||
+		strings.HasPrefix(je.destHost, common.DestHostPrefixIngress) ||
+		strings.HasSuffix(je.jobID, "2ingress")
+}
+
+// isServiceEdge returns true for edges to services selected by label.
+func isServiceEdge(je jobEdge) bool {
+	return strings.HasPrefix(je.destHost, common.DestHostPrefixService)
+}
+
 func (r *reportData) updateStatus(je jobEdge, aggr *jobEdgeAggregation) {
 	alerting := aggr.reportFailureCount > 0 &&
 		aggr.failedStrike >= r.options.conditionMinFailureCount &&
@@ -399,14 +677,44 @@ func (r *reportData) updateStatus(je jobEdge, aggr *jobEdgeAggregation) {
 func (r *reportData) sort() {
 	sort.Strings(r.issues)
 	sort.Strings(r.noissues)
+	sort.Strings(r.ingressIssues)
+	sort.Strings(r.maintenanceIssues)
 }
 
 func (r *reportData) summary() []string {
-	return []string{
+	summary := []string{
 		fmt.Sprintf("Jobs: %s", r.jobCounter.summary()),
 		fmt.Sprintf("SourceHost: %s", r.srcCounter.summary()),
 		fmt.Sprintf("DestHost: %s", r.destCounter.summary()),
 	}
+	if r.ingressCounter.size() > 0 {
+		summary = append(summary, fmt.Sprintf("Ingress: %s", r.ingressCounter.summary()))
+	}
+	if r.serviceCounter.size() > 0 {
+		summary = append(summary, fmt.Sprintf("Services: %s", r.serviceCounter.summary()))
+	}
+	if r.maintenanceCounter.size() > 0 {
+		summary = append(summary, fmt.Sprintf("Maintenance: %s", r.maintenanceCounter.summary()))
+	}
+	return summary
+}
+
+// ingressReport returns the issues of the edges to load balancers and ingress endpoints.
+func (r *reportData) ingressReport() []string {
+	var lines []string
+	for _, s := range r.ingressIssues {
+		lines = append(lines, "Ingress: "+s)
+	}
+	return lines
+}
+
+// maintenanceReport returns the issues of the edges during maintenance windows.
+func (r *reportData) maintenanceReport() []string {
+	var lines []string
+	for _, s := range r.maintenanceIssues {
+		lines = append(lines, "Maintenance: "+s)
+	}
+	return lines
 }
 
 func (a *obsAggr) report() {
@@ -431,6 +739,12 @@ func (a *obsAggr) reportToLog(report *reportData) {
 	for _, s := range report.issues {
 		a.log.Warn(prefix + s)
 	}
+	for _, s := range report.ingressReport() {
+		a.log.Warn(prefix + s)
+	}
+	for _, s := range report.maintenanceReport() {
+		a.log.Info(prefix + s)
+	}
 	for _, s := range report.summary() {
 		a.log.Info(prefix + s)
 	}
@@ -467,7 +781,7 @@ func (a *obsAggr) reportToFilesystem(report *reportData) {
 	defer f.Close()
 
 	prefix := time.Now().UTC().Format("2006-01-02T15:04:05Z ")
-	for _, s := range report.issues {
+	for _, s := range append(append(report.issues, report.ingressReport()...), report.maintenanceReport()...) {
 		f.WriteString(prefix)
 		f.WriteString(s)
 		f.WriteString("\n")
@@ -490,6 +804,13 @@ func (a *obsAggr) reportToK8sExporter(report *reportData) {
 	})
 }
 
+func (a *obsAggr) ReportEvent(severity types.Severity, reason, message string) {
+	if a.k8sExporter == nil {
+		return
+	}
+	a.k8sExporter.ExportEvent(severity, reason, message)
+}
+
 func (a *obsAggr) calcReport(options *reportOptions, resetCount bool) *reportData {
 	a.lock.Lock()
 	defer a.lock.Unlock()
@@ -497,7 +818,7 @@ func (a *obsAggr) calcReport(options *reportOptions, resetCount bool) *reportDat
 	end := time.Now()
 	start := end.Add(-1 * a.reportPeriod)
 	outdated := end.Add(-1 * a.timeWindow)
-	report := newReportData(start, end, options)
+	report := newReportData(start, end, options, a.lastAlerts)
 	for je, aggr := range a.aggregations {
 		if !a.isValidEdge(je) {
 			delete(a.aggregations, je)
@@ -510,8 +831,14 @@ func (a *obsAggr) calcReport(options *reportOptions, resetCount bool) *reportDat
 		if resetCount {
 			aggr.reportOkCount = 0
 			aggr.reportFailureCount = 0
+			aggr.reportPartialCount = 0
+			aggr.reportMaintenanceOkCount = 0
+			aggr.reportMaintenanceFailureCount = 0
 		}
 	}
+	if resetCount {
+		a.lastAlerts = report.status.alerts
+	}
 	return report
 }
 
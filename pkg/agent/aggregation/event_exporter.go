@@ -0,0 +1,327 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package aggregation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/agent/version"
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// eventExporterTimeout is the timeout for creating or updating the events of a window.
+const eventExporterTimeout = 30 * time.Second
+
+// eventReasons are the reasons of the events by category.
+var eventReasons = map[config.EventCategory]string{
+	config.EventCategoryNodes:    "FailedNodeChecks",
+	config.EventCategoryIngress:  "FailedIngressChecks",
+	config.EventCategoryExternal: "FailedExternalChecks",
+}
+
+type eventCounterKey struct {
+	category config.EventCategory
+	jobID    string
+	// dest is the zone of the destination node for the category 'nodes' and the destination host otherwise
+	dest string
+}
+
+type eventCounter struct {
+	ok     int
+	failed int
+}
+
+// EventExporter exports the failed checks of an agent as Kubernetes events of its node. Per window, it creates or updates
+// at most one event per category summarizing the window. An event is updated with increased count if the category has
+// failed checks in subsequent windows, so that a persistent problem results in a single event.
+type EventExporter struct {
+	log            logrus.FieldLogger
+	events         typedcorev1.EventInterface
+	source         string
+	nodeName       string
+	window         time.Duration
+	categories     map[config.EventCategory]bool
+	maxEvents      int
+	maxMessageSize int
+	now            func() time.Time
+
+	lock        sync.Mutex
+	nodeZones   map[string]string
+	windowStart time.Time
+	counters    map[eventCounterKey]*eventCounter
+
+	// exportLock serializes the exports of the windows
+	exportLock sync.Mutex
+	// exported are the last created or updated events by category
+	exported map[config.EventCategory]*corev1.Event
+}
+
+// NewEventExporter creates an event exporter for the node of the agent using the in-cluster config.
+func NewEventExporter(log logrus.FieldLogger, hostNetwork bool, cfg *config.EventExporterConfig) (*EventExporter, error) {
+	agentName := common.NameDaemonSetAgentPodNet
+	if hostNetwork {
+		agentName = common.NameDaemonSetAgentHostNet
+	}
+	restConfig, err := clientcmd.BuildConfigFromFlags("", "")
+	if err != nil {
+		return nil, err
+	}
+	restConfig.UserAgent = fmt.Sprintf("%s/%s", agentName, version.Version)
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return newEventExporter(log, clientset.CoreV1().Events(common.NamespaceDefault), agentName, runners.GetNodeName(), cfg), nil
+}
+
+func newEventExporter(log logrus.FieldLogger, events typedcorev1.EventInterface, source, nodeName string, cfg *config.EventExporterConfig) *EventExporter {
+	categories := map[config.EventCategory]bool{}
+	for _, category := range cfg.CategoriesOrDefault() {
+		categories[category] = true
+	}
+	return &EventExporter{
+		log:            log,
+		events:         events,
+		source:         source,
+		nodeName:       nodeName,
+		window:         cfg.WindowOrDefault(),
+		categories:     categories,
+		maxEvents:      cfg.MaxEventsPerWindowOrDefault(),
+		maxMessageSize: cfg.MaxMessageSizeOrDefault(),
+		now:            time.Now,
+		windowStart:    time.Now(),
+		counters:       map[eventCounterKey]*eventCounter{},
+		exported:       map[config.EventCategory]*corev1.Event{},
+	}
+}
+
+// UpdateNodes sets the nodes of the cluster. Checks to them belong to the category 'nodes' and are summarized by zone.
+func (e *EventExporter) UpdateNodes(nodes []config.Node) {
+	zones := map[string]string{}
+	for _, node := range nodes {
+		zones[node.Hostname] = node.Zone
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.nodeZones = zones
+}
+
+// Add counts the observation in the current window. Observations during maintenance windows or warm-up periods are ignored.
+// If the window has ended, its events are exported in the background.
+func (e *EventExporter) Add(obs *nwpd.Observation) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	now := e.now()
+	if !obs.Maintenance && !obs.WarmingUp {
+		key := e.counterKey(obs)
+		if e.categories[key.category] {
+			counter := e.counters[key]
+			if counter == nil {
+				counter = &eventCounter{}
+				e.counters[key] = counter
+			}
+			if obs.Ok {
+				counter.ok++
+			} else {
+				counter.failed++
+			}
+		}
+	}
+
+	if e.windowStart.Add(e.window).Before(now) {
+		counters := e.counters
+		e.counters = map[eventCounterKey]*eventCounter{}
+		e.windowStart = now
+		go e.export(counters)
+	}
+}
+
+func (e *EventExporter) counterKey(obs *nwpd.Observation) eventCounterKey {
+	if isIngressEdge(jobEdge{jobID: obs.JobID, destHost: obs.DestHost}) {
+		return eventCounterKey{category: config.EventCategoryIngress, jobID: obs.JobID, dest: obs.DestHost}
+	}
+	if zone, ok := e.nodeZones[obs.DestHost]; ok {
+		return eventCounterKey{category: config.EventCategoryNodes, jobID: obs.JobID, dest: zone}
+	}
+	return eventCounterKey{category: config.EventCategoryExternal, jobID: obs.JobID, dest: obs.DestHost}
+}
+
+// export creates or updates the events for the categories with failed checks. At most maxEvents events are exported,
+// the categories with most failed checks first.
+func (e *EventExporter) export(counters map[eventCounterKey]*eventCounter) {
+	e.exportLock.Lock()
+	defer e.exportLock.Unlock()
+
+	messages, failed := e.summarize(counters)
+	var categories []config.EventCategory
+	for category := range messages {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if failed[categories[i]] != failed[categories[j]] {
+			return failed[categories[i]] > failed[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	if len(categories) > e.maxEvents {
+		e.log.Warnf("event exporter: skipping events for categories %v (max %d events per window)", categories[e.maxEvents:], e.maxEvents)
+		categories = categories[:e.maxEvents]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventExporterTimeout)
+	defer cancel()
+	for _, category := range categories {
+		if err := e.exportEvent(ctx, category, messages[category]); err != nil {
+			e.log.Warnf("event exporter: exporting event for category %s failed: %s", category, err)
+		}
+	}
+}
+
+// summarize creates the messages and counts the failed checks by category. Categories without failed checks are omitted.
+func (e *EventExporter) summarize(counters map[eventCounterKey]*eventCounter) (map[config.EventCategory]string, map[config.EventCategory]int) {
+	var keys []eventCounterKey
+	failed := map[config.EventCategory]int{}
+	for key, counter := range counters {
+		if counter.failed > 0 {
+			keys = append(keys, key)
+			failed[key.category] += counter.failed
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ci, cj := counters[keys[i]], counters[keys[j]]
+		if ci.failed != cj.failed {
+			return ci.failed > cj.failed
+		}
+		if keys[i].jobID != keys[j].jobID {
+			return keys[i].jobID < keys[j].jobID
+		}
+		return keys[i].dest < keys[j].dest
+	})
+
+	lines := map[config.EventCategory][]string{}
+	for _, key := range keys {
+		counter := counters[key]
+		dest := key.dest
+		if key.category == config.EventCategoryNodes {
+			dest = "zone " + key.dest
+			if key.dest == "" {
+				dest = "nodes"
+			}
+		}
+		lines[key.category] = append(lines[key.category],
+			fmt.Sprintf("%d/%d %s checks to %s failed", counter.failed, counter.failed+counter.ok, key.jobID, dest))
+	}
+	messages := map[config.EventCategory]string{}
+	for category, list := range lines {
+		messages[category] = truncateSummary(fmt.Sprintf("failed checks in last %s:", e.window), list, e.maxMessageSize)
+	}
+	return messages, failed
+}
+
+// truncateSummary joins the lines to a message of at most maxSize bytes. Omitted lines are counted at the end.
+func truncateSummary(prefix string, lines []string, maxSize int) string {
+	msg := prefix
+	for i, line := range lines {
+		sep := " "
+		if i > 0 {
+			sep = ", "
+		}
+		more := ""
+		if i < len(lines)-1 {
+			more = fmt.Sprintf(" and %d more", len(lines)-i-1)
+		}
+		if len(msg)+len(sep)+len(line)+len(more) > maxSize {
+			more = fmt.Sprintf(" and %d more", len(lines)-i)
+			if len(msg)+len(more) <= maxSize {
+				msg += more
+			}
+			break
+		}
+		msg += sep + line
+	}
+	if len(msg) > maxSize {
+		return msg[:maxSize]
+	}
+	return msg
+}
+
+func (e *EventExporter) eventName(category config.EventCategory) string {
+	return fmt.Sprintf("%s.%s.%s", e.nodeName, e.source, category)
+}
+
+// exportEvent creates the event of the category or updates it with increased count if it already exists.
+func (e *EventExporter) exportEvent(ctx context.Context, category config.EventCategory, message string) error {
+	now := metav1.NewTime(e.now())
+	name := e.eventName(category)
+	event := e.exported[category]
+	if event == nil {
+		existing, err := e.events.Get(ctx, name, metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if err == nil {
+			event = existing
+		}
+	}
+
+	if event == nil {
+		event = &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: common.NamespaceDefault,
+			},
+			InvolvedObject: corev1.ObjectReference{
+				Kind: "Node",
+				Name: e.nodeName,
+				UID:  k8stypes.UID(e.nodeName),
+			},
+			Reason:         eventReasons[category],
+			Message:        message,
+			Type:           corev1.EventTypeWarning,
+			Source:         corev1.EventSource{Component: e.source, Host: e.nodeName},
+			FirstTimestamp: now,
+			LastTimestamp:  now,
+			Count:          1,
+		}
+		created, err := e.events.Create(ctx, event, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		e.exported[category] = created
+		return nil
+	}
+
+	event = event.DeepCopy()
+	event.Message = message
+	event.Count++
+	event.LastTimestamp = now
+	event.Series = &corev1.EventSeries{Count: event.Count, LastObservedTime: metav1.NewMicroTime(now.Time)}
+	updated, err := e.events.Update(ctx, event, metav1.UpdateOptions{})
+	if err != nil {
+		// the event may have expired or been modified, it is fetched again in the next window
+		delete(e.exported, category)
+		return err
+	}
+	e.exported[category] = updated
+	return nil
+}
@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package aggregation
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// fakeEvents stores events by name.
+type fakeEvents struct {
+	typedcorev1.EventInterface
+	events  map[string]*corev1.Event
+	creates int
+	updates int
+}
+
+func (f *fakeEvents) Get(_ context.Context, name string, _ metav1.GetOptions) (*corev1.Event, error) {
+	if event, ok := f.events[name]; ok {
+		return event.DeepCopy(), nil
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("events"), name)
+}
+
+func (f *fakeEvents) Create(_ context.Context, event *corev1.Event, _ metav1.CreateOptions) (*corev1.Event, error) {
+	f.creates++
+	f.events[event.Name] = event.DeepCopy()
+	return event, nil
+}
+
+func (f *fakeEvents) Update(_ context.Context, event *corev1.Event, _ metav1.UpdateOptions) (*corev1.Event, error) {
+	f.updates++
+	f.events[event.Name] = event.DeepCopy()
+	return event, nil
+}
+
+func TestEventExporter(t *testing.T) {
+	events := &fakeEvents{events: map[string]*corev1.Event{}}
+	exporter := newEventExporter(logrus.New(), events, "nwpd-agent-node-net", "node1", &config.EventExporterConfig{
+		Enabled:            true,
+		Categories:         []config.EventCategory{config.EventCategoryNodes, config.EventCategoryExternal},
+		MaxEventsPerWindow: 1,
+	})
+	exporter.UpdateNodes([]config.Node{{Hostname: "node1", Zone: "a"}, {Hostname: "node2", Zone: "b"}, {Hostname: "node3", Zone: "b"}})
+
+	addWindow := func() {
+		add := func(jobID, destHost string, ok bool, count int) {
+			for i := 0; i < count; i++ {
+				exporter.Add(&nwpd.Observation{JobID: jobID, SrcHost: "node1", DestHost: destHost, Ok: ok})
+			}
+		}
+		add("tcp-n2n", "node2", true, 12)
+		add("tcp-n2n", "node2", false, 3)
+		add("tcp-n2n", "node3", true, 15)
+		add("tcp-n2n", "node1", false, 1)
+		add("tcp-n2n", "node1", true, 9)
+		add("tcp-n2api-ext", "api-server", false, 2)
+		add("tcp-n2lb", "lb-default-nginx", false, 5)
+		add("ping-n2n", "node2", true, 10)
+		for i := 0; i < 100; i++ {
+			exporter.Add(&nwpd.Observation{JobID: "tcp-n2n", SrcHost: "node1", DestHost: "node2", Maintenance: true})
+			exporter.Add(&nwpd.Observation{JobID: "tcp-n2n", SrcHost: "node1", DestHost: "node3", WarmingUp: true})
+		}
+	}
+
+	addWindow()
+	assert.NotContains(t, exporter.counters, eventCounterKey{category: config.EventCategoryIngress, jobID: "tcp-n2lb", dest: "lb-default-nginx"},
+		"category ingress not enabled")
+	exporter.export(exporter.counters)
+	assert.Equal(t, 1, events.creates, "max events per window")
+	event := events.events["node1.nwpd-agent-node-net.nodes"]
+	if assert.NotNil(t, event) {
+		assert.Equal(t, "failed checks in last 5m0s: 3/30 tcp-n2n checks to zone b failed, 1/10 tcp-n2n checks to zone a failed", event.Message)
+		assert.Equal(t, "FailedNodeChecks", event.Reason)
+		assert.Equal(t, corev1.EventTypeWarning, event.Type)
+		assert.Equal(t, "node1", event.InvolvedObject.Name)
+		assert.Equal(t, int32(1), event.Count)
+	}
+
+	exporter.counters = map[eventCounterKey]*eventCounter{}
+	addWindow()
+	exporter.export(exporter.counters)
+	assert.Equal(t, 1, events.creates)
+	assert.Equal(t, 1, events.updates)
+	event = events.events["node1.nwpd-agent-node-net.nodes"]
+	assert.Equal(t, int32(2), event.Count)
+	if assert.NotNil(t, event.Series) {
+		assert.Equal(t, int32(2), event.Series.Count)
+	}
+
+	// a restarted exporter updates the existing event
+	restarted := newEventExporter(logrus.New(), events, "nwpd-agent-node-net", "node1", &config.EventExporterConfig{Enabled: true})
+	restarted.UpdateNodes([]config.Node{{Hostname: "node2", Zone: "b"}})
+	restarted.Add(&nwpd.Observation{JobID: "tcp-n2n", DestHost: "node2"})
+	restarted.export(restarted.counters)
+	assert.Equal(t, int32(3), events.events["node1.nwpd-agent-node-net.nodes"].Count)
+	assert.Equal(t, "failed checks in last 5m0s: 1/1 tcp-n2n checks to zone b failed", events.events["node1.nwpd-agent-node-net.nodes"].Message)
+}
+
+func TestEventExporterWindow(t *testing.T) {
+	events := &fakeEvents{events: map[string]*corev1.Event{}}
+	exporter := newEventExporter(logrus.New(), events, "nwpd-agent-node-net", "node1", &config.EventExporterConfig{Enabled: true})
+	now := time.Now()
+	exporter.now = func() time.Time { return now }
+	exporter.windowStart = now
+
+	exporter.Add(&nwpd.Observation{JobID: "tcp-n2api-ext", DestHost: "api-server"})
+	assert.Len(t, exporter.counters, 1)
+	now = now.Add(5*time.Minute + time.Second)
+	exporter.Add(&nwpd.Observation{JobID: "tcp-n2api-ext", DestHost: "api-server"})
+	assert.Empty(t, exporter.counters, "counters of new window must be empty")
+	assert.Equal(t, now, exporter.windowStart)
+}
+
+func TestTruncateSummary(t *testing.T) {
+	lines := []string{strings.Repeat("a", 10), strings.Repeat("b", 10), strings.Repeat("c", 10)}
+	assert.Equal(t, "x: aaaaaaaaaa, bbbbbbbbbb, cccccccccc", truncateSummary("x:", lines, 100))
+	assert.Equal(t, "x: aaaaaaaaaa, bbbbbbbbbb, cccccccccc", truncateSummary("x:", lines, 37))
+	assert.Equal(t, "x: aaaaaaaaaa, bbbbbbbbbb and 1 more", truncateSummary("x:", lines, 36))
+	assert.Equal(t, "x: aaaaaaaaaa and 2 more", truncateSummary("x:", lines, 35))
+	assert.Equal(t, "x: and 3 more", truncateSummary("x:", lines, 20))
+	assert.Equal(t, "x:", truncateSummary("x:", lines, 4))
+	assert.Equal(t, "x", truncateSummary("x:", lines, 1))
+}
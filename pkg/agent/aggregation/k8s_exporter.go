@@ -16,11 +16,13 @@ import (
 	"github.com/gardener/network-problem-detector/pkg/agent/version"
 	"github.com/gardener/network-problem-detector/pkg/common"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/utils/clock"
 )
 
 type k8sExporter struct {
 	log              logrus.FieldLogger
+	source           string
 	client           problemclient.Client
 	conditionManager condition.ConditionManager
 }
@@ -47,6 +49,7 @@ func newExporter(log logrus.FieldLogger, hostNetwork bool, heartbeatPeriod time.
 
 	ke := k8sExporter{
 		log:              log,
+		source:           agentName,
 		client:           c,
 		conditionManager: condition.NewConditionManager(log, c, clock.RealClock{}, heartbeatPeriod),
 	}
@@ -61,3 +64,11 @@ func (ke *k8sExporter) ExportProblems(status *types.Status) {
 		ke.conditionManager.UpdateCondition(cdt)
 	}
 }
+
+func (ke *k8sExporter) ExportEvent(severity types.Severity, reason, message string) {
+	eventType := corev1.EventTypeNormal
+	if severity == types.Warn {
+		eventType = corev1.EventTypeWarning
+	}
+	ke.client.Eventf(eventType, ke.source, reason, "%s", message)
+}
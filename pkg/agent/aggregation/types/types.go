@@ -93,6 +93,8 @@ type Monitor interface {
 type Exporter interface {
 	// Export problems to the control plane.
 	ExportProblems(*Status)
+	// ExportEvent exports a single event with the reason and message to the control plane.
+	ExportEvent(severity Severity, reason, message string)
 }
 
 // ProblemDaemonType is the type of the problem daemon.
@@ -0,0 +1,289 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package cgroup reads the resource usage and limits of the container from the cgroup files
+// visible inside the container. Both the cgroup v1 and the unified cgroup v2 hierarchy are supported.
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultRoot is the mount point of the cgroup file system.
+const DefaultRoot = "/sys/fs/cgroup"
+
+// memory limits of cgroup v1 above this value mean unlimited (page counter maximum rounded to the page size)
+const unlimitedV1 = 1 << 62
+
+// Stats are the resource usage statistics of the cgroup.
+type Stats struct {
+	// CPUUsageSeconds is the total CPU time consumed.
+	CPUUsageSeconds float64
+	// CPUPeriods is the number of elapsed enforcement periods of the CPU quota.
+	CPUPeriods uint64
+	// CPUThrottledPeriods is the number of periods in which the cgroup was throttled.
+	CPUThrottledPeriods uint64
+	// CPUThrottledSeconds is the total time the cgroup was throttled.
+	CPUThrottledSeconds float64
+	// CPULimitCores is the CPU limit in cores (quota per period) or 0 if unlimited.
+	CPULimitCores float64
+	// MemoryUsageBytes is the current memory usage including the page cache.
+	MemoryUsageBytes uint64
+	// MemoryWorkingSetBytes is the memory usage without inactive file pages, as used by the kubelet for evictions.
+	MemoryWorkingSetBytes uint64
+	// MemoryLimitBytes is the memory limit or 0 if unlimited.
+	MemoryLimitBytes uint64
+}
+
+// Reader reads the statistics of the cgroup hierarchy mounted at a root directory.
+type Reader struct {
+	root string
+	v2   bool
+}
+
+// NewReader creates a reader for the cgroup file system mounted at root.
+func NewReader(root string) (*Reader, error) {
+	if _, err := os.Stat(root); err != nil {
+		return nil, fmt.Errorf("cgroup file system not found: %w", err)
+	}
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	return &Reader{root: root, v2: err == nil}, nil
+}
+
+// IsV2 returns true for the unified cgroup v2 hierarchy.
+func (r *Reader) IsV2() bool {
+	return r.v2
+}
+
+// Read reads the current statistics.
+func (r *Reader) Read() (*Stats, error) {
+	if r.v2 {
+		return r.readV2()
+	}
+	return r.readV1()
+}
+
+// MemoryLimit returns the memory limit or 0 if unlimited.
+func (r *Reader) MemoryLimit() (uint64, error) {
+	if r.v2 {
+		return r.readLimitV2("memory.max")
+	}
+	return r.readLimitV1(filepath.Join("memory", "memory.limit_in_bytes"))
+}
+
+// CPULimit returns the CPU limit in cores or 0 if unlimited.
+func (r *Reader) CPULimit() (float64, error) {
+	if r.v2 {
+		return r.readCPULimitV2()
+	}
+	return r.readCPULimitV1(r.firstExisting("cpu,cpuacct", "cpu"))
+}
+
+func (r *Reader) readV2() (*Stats, error) {
+	cpu, err := r.readKeyValues("cpu.stat")
+	if err != nil {
+		return nil, err
+	}
+	memory, err := r.readKeyValues("memory.stat")
+	if err != nil {
+		return nil, err
+	}
+	usage, err := r.readUint("memory.current")
+	if err != nil {
+		return nil, err
+	}
+	limit, err := r.readLimitV2("memory.max")
+	if err != nil {
+		return nil, err
+	}
+	cpuLimit, err := r.readCPULimitV2()
+	if err != nil {
+		return nil, err
+	}
+	return &Stats{
+		CPUUsageSeconds:       float64(cpu["usage_usec"]) / 1e6,
+		CPUPeriods:            cpu["nr_periods"],
+		CPUThrottledPeriods:   cpu["nr_throttled"],
+		CPUThrottledSeconds:   float64(cpu["throttled_usec"]) / 1e6,
+		CPULimitCores:         cpuLimit,
+		MemoryUsageBytes:      usage,
+		MemoryWorkingSetBytes: workingSet(usage, memory["inactive_file"]),
+		MemoryLimitBytes:      limit,
+	}, nil
+}
+
+func (r *Reader) readV1() (*Stats, error) {
+	cpuDir := r.firstExisting("cpu,cpuacct", "cpu")
+	cpuacctDir := r.firstExisting("cpu,cpuacct", "cpuacct")
+	cpu, err := r.readKeyValues(filepath.Join(cpuDir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	cpuUsage, err := r.readUint(filepath.Join(cpuacctDir, "cpuacct.usage"))
+	if err != nil {
+		return nil, err
+	}
+	memory, err := r.readKeyValues(filepath.Join("memory", "memory.stat"))
+	if err != nil {
+		return nil, err
+	}
+	usage, err := r.readUint(filepath.Join("memory", "memory.usage_in_bytes"))
+	if err != nil {
+		return nil, err
+	}
+	limit, err := r.readLimitV1(filepath.Join("memory", "memory.limit_in_bytes"))
+	if err != nil {
+		return nil, err
+	}
+	cpuLimit, err := r.readCPULimitV1(cpuDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Stats{
+		CPUUsageSeconds:       float64(cpuUsage) / 1e9,
+		CPUPeriods:            cpu["nr_periods"],
+		CPUThrottledPeriods:   cpu["nr_throttled"],
+		CPUThrottledSeconds:   float64(cpu["throttled_time"]) / 1e9,
+		CPULimitCores:         cpuLimit,
+		MemoryUsageBytes:      usage,
+		MemoryWorkingSetBytes: workingSet(usage, memory["total_inactive_file"]),
+		MemoryLimitBytes:      limit,
+	}, nil
+}
+
+func workingSet(usage, inactiveFile uint64) uint64 {
+	if inactiveFile > usage {
+		return 0
+	}
+	return usage - inactiveFile
+}
+
+// firstExisting returns the first existing subdirectory of the root. The cpu and cpuacct controllers of cgroup v1
+// are usually mounted together, with the single controller directories as symbolic links.
+func (r *Reader) firstExisting(dirs ...string) string {
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(r.root, dir)); err == nil {
+			return dir
+		}
+	}
+	return dirs[len(dirs)-1]
+}
+
+func (r *Reader) readLimitV2(name string) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(r.root, name))
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// readCPULimitV2 reads the CPU limit from the file cpu.max in the format '<quota|max> <period>'.
+// The file does not exist in the root cgroup.
+func (r *Reader) readCPULimitV2() (float64, error) {
+	data, err := os.ReadFile(filepath.Join(r.root, "cpu.max"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("invalid content of cpu.max: %q", string(data))
+	}
+	if fields[0] == "max" {
+		return 0, nil
+	}
+	quota, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid content of cpu.max: %w", err)
+	}
+	period, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil || period == 0 {
+		return 0, fmt.Errorf("invalid content of cpu.max: %q", string(data))
+	}
+	return float64(quota) / float64(period), nil
+}
+
+// readCPULimitV1 reads the CPU limit from the CFS quota and period. A quota of -1 means unlimited.
+func (r *Reader) readCPULimitV1(cpuDir string) (float64, error) {
+	data, err := os.ReadFile(filepath.Join(r.root, cpuDir, "cpu.cfs_quota_us"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	quota, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid content of cpu.cfs_quota_us: %w", err)
+	}
+	if quota <= 0 {
+		return 0, nil
+	}
+	period, err := r.readUint(filepath.Join(cpuDir, "cpu.cfs_period_us"))
+	if err != nil {
+		return 0, err
+	}
+	if period == 0 {
+		return 0, fmt.Errorf("invalid content of cpu.cfs_period_us: 0")
+	}
+	return float64(quota) / float64(period), nil
+}
+
+func (r *Reader) readLimitV1(name string) (uint64, error) {
+	limit, err := r.readUint(name)
+	if err != nil {
+		return 0, err
+	}
+	if limit >= unlimitedV1 {
+		return 0, nil
+	}
+	return limit, nil
+}
+
+func (r *Reader) readUint(name string) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(r.root, name))
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid content of %s: %w", name, err)
+	}
+	return value, nil
+}
+
+// readKeyValues reads a flat keyed file with lines in the format '<key> <value>'.
+func (r *Reader) readKeyValues(name string) (map[string]uint64, error) {
+	f, err := os.Open(filepath.Join(r.root, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[fields[0]] = value
+	}
+	return values, scanner.Err()
+}
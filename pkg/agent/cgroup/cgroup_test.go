@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		filename := filepath.Join(root, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		assert.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+	}
+}
+
+func TestReadV2(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"cgroup.controllers": "cpuset cpu io memory pids\n",
+		"cpu.stat":           "usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\nnr_periods 100\nnr_throttled 7\nthrottled_usec 350000\n",
+		"memory.current":     "50331648\n",
+		"memory.stat":        "anon 30000000\nfile 20000000\nactive_file 4000000\ninactive_file 16000000\n",
+		"memory.max":         "67108864\n",
+		"cpu.max":            "50000 100000\n",
+	})
+
+	reader, err := NewReader(root)
+	assert.NoError(t, err)
+	assert.True(t, reader.IsV2())
+	stats, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, &Stats{
+		CPUUsageSeconds:       2.5,
+		CPUPeriods:            100,
+		CPUThrottledPeriods:   7,
+		CPUThrottledSeconds:   0.35,
+		CPULimitCores:         0.5,
+		MemoryUsageBytes:      50331648,
+		MemoryWorkingSetBytes: 50331648 - 16000000,
+		MemoryLimitBytes:      67108864,
+	}, stats)
+	cpuLimit, err := reader.CPULimit()
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, cpuLimit)
+
+	writeFiles(t, root, map[string]string{"memory.max": "max\n", "cpu.max": "max 100000\n"})
+	limit, err := reader.MemoryLimit()
+	assert.NoError(t, err)
+	assert.Zero(t, limit)
+	stats, err = reader.Read()
+	assert.NoError(t, err)
+	assert.Zero(t, stats.CPULimitCores)
+}
+
+func TestReadV1(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"cpu,cpuacct/cpu.stat":          "nr_periods 200\nnr_throttled 20\nthrottled_time 1500000000\n",
+		"cpu,cpuacct/cpuacct.usage":     "12000000000\n",
+		"memory/memory.usage_in_bytes":  "41943040\n",
+		"memory/memory.stat":            "cache 10000000\nrss 30000000\ntotal_inactive_file 8000000\n",
+		"memory/memory.limit_in_bytes":  "67108864\n",
+		"cpu,cpuacct/cpu.cfs_quota_us":  "20000\n",
+		"cpu,cpuacct/cpu.cfs_period_us": "100000\n",
+	})
+
+	reader, err := NewReader(root)
+	assert.NoError(t, err)
+	assert.False(t, reader.IsV2())
+	stats, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, &Stats{
+		CPUUsageSeconds:       12,
+		CPUPeriods:            200,
+		CPUThrottledPeriods:   20,
+		CPUThrottledSeconds:   1.5,
+		CPULimitCores:         0.2,
+		MemoryUsageBytes:      41943040,
+		MemoryWorkingSetBytes: 41943040 - 8000000,
+		MemoryLimitBytes:      67108864,
+	}, stats)
+	cpuLimit, err := reader.CPULimit()
+	assert.NoError(t, err)
+	assert.Equal(t, 0.2, cpuLimit)
+
+	writeFiles(t, root, map[string]string{"memory/memory.limit_in_bytes": "9223372036854771712\n", "cpu,cpuacct/cpu.cfs_quota_us": "-1\n"})
+	limit, err := reader.MemoryLimit()
+	assert.NoError(t, err)
+	assert.Zero(t, limit)
+	stats, err = reader.Read()
+	assert.NoError(t, err)
+	assert.Zero(t, stats.CPULimitCores)
+}
+
+func TestNewReaderMissingRoot(t *testing.T) {
+	_, err := NewReader(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type edgeKey struct {
+	jobID    string
+	srcHost  string
+	destHost string
+}
+
+type edgeResult struct {
+	ok    bool
+	since time.Time
+}
+
+// changeFilter passes only observations changing the result of their edge between ok and failed.
+type changeFilter struct {
+	results map[edgeKey]edgeResult
+}
+
+// pass returns true if the observation is the first one of its edge or changes the result of the edge.
+// On a change, the duration of the previous state is set on the observation.
+func (f *changeFilter) pass(obs *nwpd.Observation) bool {
+	if f.results == nil {
+		f.results = map[edgeKey]edgeResult{}
+	}
+	key := edgeKey{jobID: obs.JobID, srcHost: obs.SrcHost, destHost: obs.DestHost}
+	timestamp := obs.Timestamp.AsTime()
+	last, found := f.results[key]
+	if found && last.ok == obs.Ok {
+		return false
+	}
+	if found {
+		obs.PreviousStateDuration = durationpb.New(timestamp.Sub(last.since))
+	}
+	f.results[key] = edgeResult{ok: obs.Ok, since: timestamp}
+	return true
+}
+
+// reset forgets the results of all edges.
+func (f *changeFilter) reset() {
+	f.results = nil
+}
@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestChangeFilter(t *testing.T) {
+	start := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	newObs := func(dest string, seconds int, ok bool) *nwpd.Observation {
+		return &nwpd.Observation{
+			JobID:     "tcp-n2n",
+			SrcHost:   "node1",
+			DestHost:  dest,
+			Timestamp: timestamppb.New(start.Add(time.Duration(seconds) * time.Second)),
+			Ok:        ok,
+		}
+	}
+
+	f := changeFilter{}
+	for _, testCase := range []struct {
+		obs              *nwpd.Observation
+		expected         bool
+		expectedDuration time.Duration
+	}{
+		{obs: newObs("node2", 0, true), expected: true},
+		{obs: newObs("node2", 10, true), expected: false},
+		{obs: newObs("node3", 10, false), expected: true},
+		{obs: newObs("node2", 20, false), expected: true, expectedDuration: 20 * time.Second},
+		{obs: newObs("node2", 30, false), expected: false},
+		{obs: newObs("node2", 40, true), expected: true, expectedDuration: 20 * time.Second},
+		{obs: newObs("node3", 40, false), expected: false},
+	} {
+		assert.Equal(t, testCase.expected, f.pass(testCase.obs), "%s at %s", testCase.obs.DestHost, testCase.obs.Timestamp.AsTime())
+		if testCase.expectedDuration != 0 {
+			assert.Equal(t, testCase.expectedDuration, testCase.obs.PreviousStateDuration.AsDuration())
+		} else {
+			assert.Nil(t, testCase.obs.PreviousStateDuration)
+		}
+	}
+
+	f.reset()
+	assert.True(t, f.pass(newObs("node2", 50, true)))
+}
@@ -6,10 +6,12 @@ package agent
 
 import (
 	"fmt"
-	"net"
+	"os"
+	"time"
 
+	"github.com/gardener/network-problem-detector/pkg/agent/cgroup"
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
 	"github.com/gardener/network-problem-detector/pkg/agent/version"
-	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
@@ -19,7 +21,21 @@ var (
 	agentConfigFile   string
 	clusterConfigFile string
 	hostNetwork       bool
-	grpcServer        *grpc.Server
+	simulate          string
+	startupDelay      time.Duration
+	runFor            time.Duration
+	summaryFile       string
+	thresholds        SummaryThresholds
+	cgroupRoot        string
+	memoryLimitRatio  float64
+	goMaxProcs        int
+	gcPercent         int
+	watchdogFactor    float64
+	keepUnknownFiles  bool
+	unknownFilesGrace time.Duration
+	faultInjection    bool
+	faultPolicyFile   string
+	grpcServers       []*grpc.Server
 )
 
 func CreateRunAgentCmd(injectedVersion string) *cobra.Command {
@@ -32,6 +48,24 @@ func CreateRunAgentCmd(injectedVersion string) *cobra.Command {
 	cmd.Flags().StringVar(&agentConfigFile, "config", "agent.config", "file configuration of agent server.")
 	cmd.Flags().StringVar(&clusterConfigFile, "cluster-config", "cluster.config", "file configuration of cluster nodes and agent pods.")
 	cmd.Flags().BoolVar(&hostNetwork, "hostNetwork", false, "if agent runs on host network.")
+	cmd.Flags().DurationVar(&startupDelay, "startup-delay", 5*time.Second, "maximum time to wait before starting the checks. The wait ends as soon as the internal kube-apiserver is reachable.")
+	cmd.Flags().StringVar(&simulate, "simulate", "", "replaces the checks of all jobs by synthetic ones and uses a generated cluster configuration for load and scale testing, e.g. 'nodes=1000,failRate=0.02,latency=5ms±3ms'.")
+	cmd.Flags().DurationVar(&runFor, "run-for", 0, "if set, runs the jobs only for the given duration, writes a summary and exits with code 0 (passed), 1 (degraded) or 2 (failed).")
+	cmd.Flags().StringVar(&summaryFile, "summary-file", "", "file for the JSON summary written at the end of '--run-for' (default: stdout).")
+	cmd.Flags().Float64Var(&thresholds.DegradedRatio, "summary-degraded-ratio", 0.01, "failure ratio of a job above which the '--run-for' verdict is 'degraded'.")
+	cmd.Flags().Float64Var(&thresholds.FailedRatio, "summary-failed-ratio", 0.05, "failure ratio of a job above which the '--run-for' verdict is 'failed'.")
+	cmd.Flags().StringVar(&cgroupRoot, "cgroup-root", cgroup.DefaultRoot, "mount point of the cgroup file system to read the resource usage of the container from.")
+	cmd.Flags().Float64Var(&memoryLimitRatio, "memory-limit-ratio", 0.9, "if > 0, the soft memory limit of the Go runtime is set to this ratio of the memory limit of the container, unless GOMEMLIMIT is set.")
+	cmd.Flags().IntVar(&goMaxProcs, "gomaxprocs", 0, "number of OS threads executing Go code simultaneously. 0 derives it from the CPU limit of the container (rounded up), a negative value keeps the default of the Go runtime (number of CPUs of the node). The environment variable GOMAXPROCS takes precedence.")
+	cmd.Flags().IntVar(&gcPercent, "gogc", 0, "garbage collection target percentage of the Go runtime, 0 keeps the default of 100. The environment variable GOGC takes precedence.")
+	cmd.Flags().Float64Var(&watchdogFactor, "watchdog-multiplier", 5, "a job is considered stuck if it has not completed a run within this multiple of its period (at least 1m). Stuck jobs make the agent unready. 0 disables the watchdog.")
+	cmd.Flags().BoolVar(&keepUnknownFiles, "keep-unknown-files", false, "keeps files in the output directory which do not belong to any data file prefix of the agent config (for debugging).")
+	cmd.Flags().DurationVar(&unknownFilesGrace, "unknown-files-grace-period", 48*time.Hour, "minimum time since the last modification before files in the output directory not belonging to any data file prefix of the agent config are deleted.")
+	// fault injection is only meant for end-to-end tests of the detector itself
+	cmd.Flags().BoolVar(&faultInjection, "fault-injection", os.Getenv(envFaultInjection) == "true", "enables the injection of synthetic faults into the checks for testing (also enabled by env var "+envFaultInjection+"=true).")
+	cmd.Flags().StringVar(&faultPolicyFile, "fault-policy-file", os.Getenv(envFaultPolicyFile), "YAML file with the injected faults, reloaded on modification (implies --fault-injection).")
+	_ = cmd.Flags().MarkHidden("fault-injection")
+	_ = cmd.Flags().MarkHidden("fault-policy-file")
 	cmd.RunE = runAgent
 	return cmd
 }
@@ -46,21 +80,78 @@ func runAgent(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Missing --cluster-config option")
 	}
 
-	srv, err := startAgentServer(log, agentConfigFile, clusterConfigFile, hostNetwork)
+	var simulation *runners.SimulationConfig
+	if simulate != "" {
+		var err error
+		simulation, err = runners.ParseSimulationConfig(simulate)
+		if err != nil {
+			return err
+		}
+		log.Infof("simulation mode: %d nodes, fail rate %.3f, latency %s±%s",
+			simulation.Nodes, simulation.FailRate, simulation.Latency, simulation.LatencyJitter)
+	}
+
+	if runFor < 0 {
+		return fmt.Errorf("invalid --run-for option: must not be negative")
+	}
+	if thresholds.DegradedRatio > thresholds.FailedRatio {
+		return fmt.Errorf("invalid thresholds: --summary-degraded-ratio must not be greater than --summary-failed-ratio")
+	}
+	if memoryLimitRatio < 0 || memoryLimitRatio > 1 {
+		return fmt.Errorf("invalid --memory-limit-ratio option: must be in range 0..1")
+	}
+	if gcPercent < 0 {
+		return fmt.Errorf("invalid --gogc option: must not be negative")
+	}
+	if watchdogFactor < 0 {
+		return fmt.Errorf("invalid --watchdog-multiplier option: must not be negative")
+	}
+	if unknownFilesGrace <= 0 {
+		return fmt.Errorf("invalid --unknown-files-grace-period option: must be positive")
+	}
+	setupSelfMetrics(log, cgroupRoot, memoryLimitRatio, goMaxProcs, gcPercent)
+
+	srv, err := startAgentServer(log, agentConfigFile, clusterConfigFile, hostNetwork, startupDelay, simulation)
 	if err != nil {
 		return fmt.Errorf("cannot start server: %w", err)
 	}
+	srv.runFor = runFor
+	if faultInjection || faultPolicyFile != "" {
+		log.Warn("fault injection enabled: checks may fail or be delayed by intention")
+		if err := srv.enableFaultInjection(faultPolicyFile); err != nil {
+			return fmt.Errorf("cannot load fault policy: %w", err)
+		}
+	}
+	if watchdogFactor > 0 {
+		srv.watchdog = newJobWatchdog(watchdogFactor)
+	}
 
 	log.Info("running...")
 	srv.run()
+
+	if srv.summary != nil {
+		summary := srv.summary.summary(time.Now(), hostNetwork, thresholds)
+		if err := WriteSummary(summary, summaryFile); err != nil {
+			return err
+		}
+		log.Infof("verdict: %s", summary.Verdict)
+		if summary.ExitCode != 0 {
+			os.Exit(summary.ExitCode)
+		}
+	}
 	return nil
 }
 
-func startAgentServer(log logrus.FieldLogger, agentConfigFile, clusterConfigFile string, hostNetwork bool) (*server, error) {
-	agentServer, err := newServer(log, agentConfigFile, clusterConfigFile, hostNetwork)
+func startAgentServer(log logrus.FieldLogger, agentConfigFile, clusterConfigFile string, hostNetwork bool,
+	startupDelay time.Duration, simulation *runners.SimulationConfig) (*server, error) {
+	agentServer, err := newServer(log, agentConfigFile, clusterConfigFile, hostNetwork, simulation)
 	if err != nil {
 		return nil, err
 	}
+	agentServer.startupDelay = startupDelay
+	if !keepUnknownFiles {
+		agentServer.unknownFilesGracePeriod = unknownFilesGrace
+	}
 
 	err = agentServer.setup()
 	if err != nil {
@@ -73,18 +164,15 @@ func startAgentServer(log logrus.FieldLogger, agentConfigFile, clusterConfigFile
 			return nil, err
 		}
 	*/
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", agentServer.getNetworkCfg().GRPCPort))
-	if err != nil {
-		return nil, err
-	}
 	//	s := grpc.NewServer(grpc.Creds(creds))
-	grpcServer = grpc.NewServer()
-	nwpd.RegisterAgentServiceServer(grpcServer, agentServer)
-	log.Infof("server listening at %s", listener.Addr())
-	go func() {
-		if err := grpcServer.Serve(listener); err != nil {
-			log.Fatalf("failed to serve: %v", err)
-		}
-	}()
+	if err := agentServer.startMetricsServer(); err != nil {
+		return nil, agentServer.bindPortsFailed(log, err)
+	}
+	if err := agentServer.listenEcho(); err != nil {
+		return nil, agentServer.bindPortsFailed(log, err)
+	}
+	if err := startGRPCServers(log, agentServer); err != nil {
+		return nil, agentServer.bindPortsFailed(log, err)
+	}
 	return agentServer, nil
 }
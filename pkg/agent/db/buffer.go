@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package db
+
+import (
+	"sync"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// obsBuffer is a bounded FIFO buffer of observations. If it is full, the backpressure policy decides
+// if adding blocks or if the oldest or the new observation is dropped.
+type obsBuffer struct {
+	size   int
+	policy config.BackpressurePolicy
+	onDrop func()
+
+	lock    sync.Mutex
+	notFull *sync.Cond
+	items   []*nwpd.Observation
+	// notify signals that observations are available
+	notify chan struct{}
+}
+
+func newObsBuffer(size int, policy config.BackpressurePolicy, onDrop func()) *obsBuffer {
+	if size < 1 {
+		size = 1
+	}
+	if onDrop == nil {
+		onDrop = func() {}
+	}
+	b := &obsBuffer{
+		size:   size,
+		policy: policy,
+		onDrop: onDrop,
+		notify: make(chan struct{}, 1),
+	}
+	b.notFull = sync.NewCond(&b.lock)
+	return b
+}
+
+// add appends the observation and applies the backpressure policy if the buffer is full.
+func (b *obsBuffer) add(obs *nwpd.Observation) {
+	dropped := false
+	b.lock.Lock()
+	if len(b.items) >= b.size {
+		switch b.policy {
+		case config.BackpressureDropNewest:
+			b.lock.Unlock()
+			b.onDrop()
+			return
+		case config.BackpressureDropOldest:
+			b.items[0] = nil
+			b.items = b.items[1:]
+			dropped = true
+		default:
+			for len(b.items) >= b.size {
+				b.notFull.Wait()
+			}
+		}
+	}
+	b.items = append(b.items, obs)
+	b.lock.Unlock()
+
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+	if dropped {
+		b.onDrop()
+	}
+}
+
+// take removes and returns all buffered observations.
+func (b *obsBuffer) take() []*nwpd.Observation {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	items := b.items
+	b.items = nil
+	b.notFull.Broadcast()
+	return items
+}
+
+func (b *obsBuffer) len() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.items)
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func destHosts(items []*nwpd.Observation) []string {
+	var result []string
+	for _, obs := range items {
+		result = append(result, obs.DestHost)
+	}
+	return result
+}
+
+func TestObsBufferDropPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy   config.BackpressurePolicy
+		expected []string
+	}{
+		{policy: config.BackpressureDropOldest, expected: []string{"c", "d", "e"}},
+		{policy: config.BackpressureDropNewest, expected: []string{"a", "b", "c"}},
+	} {
+		dropped := 0
+		b := newObsBuffer(3, tc.policy, func() { dropped++ })
+		for _, dest := range []string{"a", "b", "c", "d", "e"} {
+			b.add(&nwpd.Observation{DestHost: dest})
+		}
+		assert.Equal(t, 2, dropped, tc.policy)
+		assert.Equal(t, tc.expected, destHosts(b.take()), tc.policy)
+		assert.Equal(t, 0, b.len())
+	}
+}
+
+func TestObsBufferBlock(t *testing.T) {
+	b := newObsBuffer(2, config.BackpressureBlock, func() { t.Error("unexpected drop") })
+	b.add(&nwpd.Observation{DestHost: "a"})
+	b.add(&nwpd.Observation{DestHost: "b"})
+
+	added := make(chan struct{})
+	go func() {
+		b.add(&nwpd.Observation{DestHost: "c"})
+		close(added)
+	}()
+	select {
+	case <-added:
+		t.Fatal("add did not block")
+	case <-time.After(50 * time.Millisecond):
+	}
+	<-b.notify
+	assert.Equal(t, []string{"a", "b"}, destHosts(b.take()))
+	select {
+	case <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("add still blocked")
+	}
+	assert.Equal(t, []string{"c"}, destHosts(b.take()))
+}
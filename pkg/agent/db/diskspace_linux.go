@@ -0,0 +1,23 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build linux
+
+package db
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// freeDiskPercent returns the space of the filesystem of the directory available to unprivileged users in percent.
+func freeDiskPercent(directory string) (float64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(directory, &stat); err != nil {
+		return 0, err
+	}
+	if stat.Blocks == 0 {
+		return 100, nil
+	}
+	return float64(stat.Bavail) * 100 / float64(stat.Blocks), nil
+}
@@ -0,0 +1,17 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux
+
+package db
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// freeDiskPercent is not supported, the writer only switches to the degraded mode on write errors.
+func freeDiskPercent(_ string) (float64, error) {
+	return 0, fmt.Errorf("free disk space not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,265 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Record file formats:
+//
+// The legacy format (version 1) has no file header. Each record consists of the marker byte, the length of the value
+// as little endian uint16 and the value.
+//
+// The format version 2 starts with the magic bytes and the file header (uvarint length and the marshalled
+// RecordFileHeader). Each record consists of the marker byte, the length of the value as uvarint and the value.
+// The markers and the values are the same as in the legacy format.
+//
+// Files in the legacy format are only read (and converted to version 2 before appending) for compatibility with the
+// files of the previous release.
+const (
+	// FormatLegacy is the name of the record file format without file header.
+	FormatLegacy = "v1"
+	// FormatV2 is the name of the record file format with file header and uvarint lengths.
+	FormatV2 = "v2"
+
+	formatVersionLegacy = 1
+	formatVersionV2     = 2
+
+	// maxRecordSize limits the allocation for the value of a record on reading a corrupted file.
+	maxRecordSize = 16 << 20
+)
+
+// recordFileMagic are the first bytes of a record file of format version 2. Legacy files start with a marker byte.
+var recordFileMagic = []byte("NWPD")
+
+// ParseFormat returns the format name for a name given on the command line ('old' is an alias of the legacy format).
+func ParseFormat(name string) (string, error) {
+	switch name {
+	case FormatLegacy, "old":
+		return FormatLegacy, nil
+	case FormatV2:
+		return FormatV2, nil
+	default:
+		return "", fmt.Errorf("unknown record file format %q (supported: old, %s, %s)", name, FormatLegacy, FormatV2)
+	}
+}
+
+// FormatName returns the format name of the version of a file header.
+func FormatName(header *nwpd.RecordFileHeader) string {
+	if header.GetVersion() == formatVersionLegacy {
+		return FormatLegacy
+	}
+	return fmt.Sprintf("v%d", header.GetVersion())
+}
+
+// NewRecordFileHeader creates the header of a record file of the current format version.
+func NewRecordFileHeader(node, prefix string, start time.Time) *nwpd.RecordFileHeader {
+	header := &nwpd.RecordFileHeader{
+		Version: formatVersionV2,
+		Node:    node,
+		Prefix:  prefix,
+	}
+	if !start.IsZero() {
+		header.Start = timestamppb.New(start)
+	}
+	return header
+}
+
+// encodeRecordFileHeader returns the magic bytes, the length and the marshalled header.
+func encodeRecordFileHeader(header *nwpd.RecordFileHeader) ([]byte, error) {
+	value, err := proto.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	buf := append([]byte{}, recordFileMagic...)
+	buf = appendUvarint(buf, uint64(len(value)))
+	return append(buf, value...), nil
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+// recordReader reads the records of a record file in the legacy format or format version 2.
+type recordReader struct {
+	r      *bufio.Reader
+	header *nwpd.RecordFileHeader
+}
+
+// newRecordReader reads the file header. An empty file is reported as file of the current format version.
+func newRecordReader(r io.Reader) (*recordReader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(recordFileMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(magic) == 0 {
+		return &recordReader{r: br, header: &nwpd.RecordFileHeader{Version: formatVersionV2}}, nil
+	}
+	if !bytes.Equal(magic, recordFileMagic) {
+		return &recordReader{r: br, header: &nwpd.RecordFileHeader{Version: formatVersionLegacy}}, nil
+	}
+	if _, err := br.Discard(len(recordFileMagic)); err != nil {
+		return nil, err
+	}
+	value, err := readUvarintValue(br)
+	if err != nil {
+		return nil, fmt.Errorf("reading file header failed: %w", err)
+	}
+	header := &nwpd.RecordFileHeader{}
+	if err := proto.Unmarshal(value, header); err != nil {
+		return nil, fmt.Errorf("unmarshalling file header failed: %w", err)
+	}
+	if header.Version != formatVersionV2 {
+		return nil, fmt.Errorf("unsupported record file format version %d", header.Version)
+	}
+	return &recordReader{r: br, header: header}, nil
+}
+
+func (rr *recordReader) legacy() bool {
+	return rr.header.Version == formatVersionLegacy
+}
+
+// next returns the marker and the value of the next record. The value is nil at the end of the file.
+func (rr *recordReader) next() (byte, []byte, error) {
+	marker, err := rr.r.ReadByte()
+	if err == io.EOF {
+		return 0, nil, nil
+	} else if err != nil {
+		return 0, nil, err
+	}
+	var value []byte
+	if rr.legacy() {
+		var length uint16
+		if err := binary.Read(rr.r, binary.LittleEndian, &length); err != nil {
+			return 0, nil, fmt.Errorf("incomplete record: %w", err)
+		}
+		value = make([]byte, length)
+		if _, err := io.ReadFull(rr.r, value); err != nil {
+			return 0, nil, fmt.Errorf("incomplete record: %w", err)
+		}
+	} else if value, err = readUvarintValue(rr.r); err != nil {
+		return 0, nil, err
+	}
+	return marker, value, nil
+}
+
+func readUvarintValue(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("incomplete record: %w", err)
+	}
+	if length > maxRecordSize {
+		return nil, fmt.Errorf("invalid record length %d", length)
+	}
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return nil, fmt.Errorf("incomplete record: %w", err)
+	}
+	return value, nil
+}
+
+// ReadRecordFileHeader returns the file header of a record file. For files in the legacy format only the version is set.
+func ReadRecordFileHeader(filename string) (*nwpd.RecordFileHeader, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rr, err := newRecordReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return rr.header, nil
+}
+
+// RecordFileHeaderFromFilename returns a header of the current format version with the data file prefix and the start hour
+// parsed from a file name in the default format '<prefix>-YYYY-MM-DD-HH.records'. Prefix and start are empty if the
+// name has another format.
+func RecordFileHeaderFromFilename(filename, node string) *nwpd.RecordFileHeader {
+	name := filepath.Base(filename)
+	prefix, ok := parseRecordFilename(name)
+	if !ok {
+		return NewRecordFileHeader(node, "", time.Time{})
+	}
+	start, _ := time.Parse("2006-01-02-15.records", name[len(prefix)+1:])
+	return NewRecordFileHeader(node, prefix, start)
+}
+
+// ConvertRecordFile rewrites a record file in the legacy format to the format version 2 with the given header.
+// The records are copied unchanged. If target is empty, the file is converted in place.
+// It returns false if the file already has the format version 2.
+func ConvertRecordFile(filename, target string, header *nwpd.RecordFileHeader) (bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	rr, err := newRecordReader(f)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", filename, err)
+	}
+	if !rr.legacy() {
+		return false, nil
+	}
+	if target == "" {
+		target = filename
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), filepath.Base(target)+".tmp*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := bufio.NewWriter(tmp)
+	head, err := encodeRecordFileHeader(header)
+	if err != nil {
+		return false, err
+	}
+	if _, err := w.Write(head); err != nil {
+		return false, err
+	}
+	for {
+		marker, value, err := rr.next()
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", filename, err)
+		}
+		if value == nil {
+			break
+		}
+		if _, err := w.Write(encodeRecord(marker, value)); err != nil {
+			return false, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return false, err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return false, err
+	}
+	return true, nil
+}
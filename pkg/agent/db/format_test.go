@@ -0,0 +1,215 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package db
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// writeLegacyRecord writes a record in the legacy format without file header.
+func writeLegacyRecord(w io.Writer, marker byte, value []byte) error {
+	buf := make([]byte, 3, 3+len(value))
+	buf[0] = marker
+	binary.LittleEndian.PutUint16(buf[1:], uint16(len(value)))
+	_, err := w.Write(append(buf, value...))
+	return err
+}
+
+// legacyPersistor writes the string IDs as records in the legacy format.
+type legacyPersistor struct {
+	f io.Writer
+}
+
+func (p *legacyPersistor) Persist(obj *IntString) error {
+	value, err := proto.Marshal(&nwpd.IntString{Key: obj.Key(), Value: obj.Value()})
+	if err != nil {
+		return err
+	}
+	return writeLegacyRecord(p.f, markerStringID, value)
+}
+
+// writeLegacyRecordFile writes the observations to a record file in the legacy format.
+func writeLegacyRecordFile(t *testing.T, filename string, observations []*nwpd.Observation) {
+	f, err := os.Create(filename)
+	assert.NoError(t, err)
+	idMap := NewStringIdMap()
+	assert.NoError(t, writeLegacyRecord(f, markerOpen, openRecordValue(time.Now())))
+	for _, obs := range observations {
+		intobs, err := ToIntObservation(obs, idMap, &legacyPersistor{f: f})
+		assert.NoError(t, err)
+		value, err := IntObsToBytes(intobs)
+		assert.NoError(t, err)
+		assert.NoError(t, writeLegacyRecord(f, markerObservation, value))
+	}
+	assert.NoError(t, f.Close())
+}
+
+func readAll(t *testing.T, filename string) []string {
+	var hosts []string
+	assert.NoError(t, IterateRecordFile(filename, func(obs *nwpd.Observation) error {
+		hosts = append(hosts, obs.DestHost)
+		return nil
+	}))
+	return hosts
+}
+
+func testObservations(hosts ...string) []*nwpd.Observation {
+	var observations []*nwpd.Observation
+	for _, host := range hosts {
+		observations = append(observations, &nwpd.Observation{
+			JobID:     "tcp-n2n",
+			SrcHost:   "node-a",
+			DestHost:  host,
+			Timestamp: timestamppb.New(time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)),
+			Ok:        true,
+		})
+	}
+	return observations
+}
+
+func TestRecordFileHeader(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "nwpd-pod-2022-08-01-10.records")
+	assert.NoError(t, WriteRecordFile(filename, testObservations("node-b", "node-c")))
+
+	header, err := ReadRecordFileHeader(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatV2, FormatName(header))
+	assert.Equal(t, "nwpd-pod", header.GetPrefix())
+	assert.Equal(t, time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC), header.GetStart().AsTime())
+	assert.Equal(t, []string{"node-b", "node-c"}, readAll(t, filename))
+
+	legacy := filepath.Join(dir, "legacy.records")
+	writeLegacyRecordFile(t, legacy, testObservations("node-d"))
+	header, err = ReadRecordFileHeader(legacy)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatLegacy, FormatName(header))
+	assert.Empty(t, header.GetPrefix())
+	assert.Nil(t, header.GetStart())
+	assert.Equal(t, []string{"node-d"}, readAll(t, legacy))
+}
+
+func TestRecordFileLargeValue(t *testing.T) {
+	// values exceeding the uint16 length of the legacy format
+	dir := t.TempDir()
+	obs := testObservations("node-b")[0]
+	obs.Result = string(make([]byte, 70000))
+	w, err := NewObsWriter(logrus.New(), dir, testNaming(t, "", "test"), 1, nil, nil)
+	assert.NoError(t, err)
+	w.SetDetailLevel(config.DetailLevelFull)
+	w.write(obs)
+	files, err := GetAnyRecordFiles(dir, false)
+	assert.NoError(t, err)
+	if assert.Len(t, files, 1) {
+		assert.NoError(t, IterateRecordFile(files[0], func(result *nwpd.Observation) error {
+			assert.Len(t, result.Result, 70000)
+			return nil
+		}))
+	}
+}
+
+func TestConvertRecordFile(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "nwpd-pod-2022-08-01-10.records")
+	writeLegacyRecordFile(t, legacy, testObservations("node-b", "node-c", "node-b"))
+
+	target := filepath.Join(dir, "converted.records")
+	converted, err := ConvertRecordFile(legacy, target, NewRecordFileHeader("node-a", "nwpd-pod", time.Time{}))
+	assert.NoError(t, err)
+	assert.True(t, converted)
+	header, err := ReadRecordFileHeader(target)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatV2, FormatName(header))
+	assert.Equal(t, "node-a", header.GetNode())
+	assert.Equal(t, readAll(t, legacy), readAll(t, target))
+
+	converted, err = ConvertRecordFile(target, "", NewRecordFileHeader("", "", time.Time{}))
+	assert.NoError(t, err)
+	assert.False(t, converted, "already converted")
+
+	// in place
+	converted, err = ConvertRecordFile(legacy, "", RecordFileHeaderFromFilename(legacy, ""))
+	assert.NoError(t, err)
+	assert.True(t, converted)
+	header, err = ReadRecordFileHeader(legacy)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatV2, FormatName(header))
+	assert.Equal(t, "nwpd-pod", header.GetPrefix())
+	assert.Equal(t, []string{"node-b", "node-c", "node-b"}, readAll(t, legacy))
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2, "no temporary files left")
+}
+
+func TestWriterAppendsToLegacyFile(t *testing.T) {
+	dir := t.TempDir()
+	naming := testNaming(t, "", "test")
+	filename := filepath.Join(dir, naming.Filename(time.Now()))
+	writeLegacyRecordFile(t, filename, testObservations("node-b"))
+
+	w, err := NewObsWriter(logrus.New(), dir, naming, 1, nil, nil)
+	assert.NoError(t, err)
+	w.write(testObservations("node-c")[0])
+	w.discardFile()
+
+	header, err := ReadRecordFileHeader(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatV2, FormatName(header))
+	assert.Equal(t, "node1", header.GetNode())
+	assert.Equal(t, "test", header.GetPrefix())
+	assert.Equal(t, []string{"node-b", "node-c"}, readAll(t, filename))
+}
+
+func TestWriterNetworkInfo(t *testing.T) {
+	dir := t.TempDir()
+	naming := testNaming(t, "", "test")
+	w, err := NewObsWriter(logrus.New(), dir, naming, 1, nil, nil)
+	assert.NoError(t, err)
+	info := []*nwpd.NetworkInfo{{Family: "ipv4", Interface: "eth0", Gateway: "10.0.0.1", PrimaryIP: "10.0.0.5"}}
+	w.SetNetworkInfo(info)
+	w.write(testObservations("node-b")[0])
+	w.discardFile()
+
+	header, err := ReadRecordFileHeader(filepath.Join(dir, naming.Filename(time.Now())))
+	assert.NoError(t, err)
+	assert.Len(t, header.GetNetworkInfo(), 1)
+	assert.True(t, proto.Equal(info[0], header.GetNetworkInfo()[0]))
+}
+
+func TestIterateTruncatedRecordFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.records")
+	assert.NoError(t, WriteRecordFile(filename, testObservations("node-b")))
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filename, data[:len(data)-3], 0644))
+	assert.Error(t, IterateRecordFile(filename, func(_ *nwpd.Observation) error { return nil }))
+
+	assert.NoError(t, os.WriteFile(filename, []byte("NWPD\x7f"), 0644))
+	_, err = ReadRecordFileHeader(filename)
+	assert.Error(t, err)
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, expected := range map[string]string{"old": FormatLegacy, "v1": FormatLegacy, "v2": FormatV2} {
+		format, err := ParseFormat(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, format)
+	}
+	_, err := ParseFormat("v3")
+	assert.Error(t, err)
+}
@@ -5,8 +5,12 @@
 package db
 
 import (
+	"net"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/gardener/network-problem-detector/pkg/common/config"
 	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
 
 	"google.golang.org/protobuf/proto"
@@ -27,15 +31,93 @@ func ToIntObservation(obs *nwpd.Observation, idMap *StringIdMap, persistor IntSt
 	if err != nil {
 		return nil, err
 	}
-	return &nwpd.IntObservation{
-		SrcHost:        is,
-		DestHost:       id,
-		JobID:          ij,
-		Ok:             obs.Ok,
-		TimeMillis:     obs.Timestamp.AsTime().UnixMilli(),
-		DurationMillis: int32(obs.Duration.AsDuration().Milliseconds()),
-		PeriodMillis:   int32(obs.Period.AsDuration().Milliseconds()),
-	}, nil
+	ia, err := idMap.GetKey(persistor, obs.DestAddress)
+	if err != nil {
+		return nil, err
+	}
+	ip, err := idMap.GetKey(persistor, obs.Protocol)
+	if err != nil {
+		return nil, err
+	}
+	ii, err := idMap.GetKey(persistor, obs.DestIP)
+	if err != nil {
+		return nil, err
+	}
+	ils, err := idMap.GetKey(persistor, obs.ListSource)
+	if err != nil {
+		return nil, err
+	}
+	ilv, err := idMap.GetKey(persistor, obs.ListVersion)
+	if err != nil {
+		return nil, err
+	}
+	in, err := idMap.GetKey(persistor, obs.Netns)
+	if err != nil {
+		return nil, err
+	}
+	ifc, err := idMap.GetKey(persistor, obs.FailureClass)
+	if err != nil {
+		return nil, err
+	}
+	iif, err := idMap.GetKey(persistor, obs.Interface)
+	if err != nil {
+		return nil, err
+	}
+	iat, err := idMap.GetKey(persistor, obs.DestAddressType)
+	if err != nil {
+		return nil, err
+	}
+	ir, err := idMap.GetKey(persistor, obs.Result)
+	if err != nil {
+		return nil, err
+	}
+	intobs := &nwpd.IntObservation{
+		SrcHost:         is,
+		DestHost:        id,
+		DestAddress:     ia,
+		Protocol:        ip,
+		DestIP:          ii,
+		DestPort:        obs.DestPort,
+		SrcPort:         obs.SrcPort,
+		Partial:         obs.Partial,
+		Maintenance:     obs.Maintenance,
+		LatencyBreach:   obs.LatencyBreach,
+		WarmingUp:       obs.WarmingUp,
+		ListSource:      ils,
+		ListVersion:     ilv,
+		Netns:           in,
+		FailureClass:    ifc,
+		Interface:       iif,
+		DestAddressType: iat,
+		Result:          ir,
+		JobID:           ij,
+		Ok:              obs.Ok,
+		TimeMillis:      obs.Timestamp.AsTime().UnixMilli(),
+		DurationMillis:  int32(obs.Duration.AsDuration().Milliseconds()),
+		PeriodMillis:    int32(obs.Period.AsDuration().Milliseconds()),
+	}
+	if obs.PreviousStateDuration != nil {
+		intobs.PreviousStateMillis = obs.PreviousStateDuration.AsDuration().Milliseconds()
+	}
+	if obs.UdpDuration != nil {
+		intobs.UdpDurationMillis = int32(obs.UdpDuration.AsDuration().Milliseconds())
+	}
+	if obs.TcpDuration != nil {
+		intobs.TcpDurationMillis = int32(obs.TcpDuration.AsDuration().Milliseconds())
+	}
+	if obs.TriggeredBy != "" {
+		// the triggering observation is always on the same edge, so job ID and time are sufficient
+		triggerJobID, _, _, triggerTimeMillis, err := nwpd.ParseObservationID(obs.TriggeredBy)
+		if err != nil {
+			return nil, err
+		}
+		intobs.TriggeredByJobID, err = idMap.GetKey(persistor, triggerJobID)
+		if err != nil {
+			return nil, err
+		}
+		intobs.TriggeredByTimeMillis = triggerTimeMillis
+	}
+	return intobs, nil
 }
 
 func IntObsToObservation(o *nwpd.IntObservation, idMap *StringIdMap) (*nwpd.Observation, error) {
@@ -51,24 +133,133 @@ func IntObsToObservation(o *nwpd.IntObservation, idMap *StringIdMap) (*nwpd.Obse
 	if err != nil {
 		return nil, err
 	}
-	var duration, period *durationpb.Duration
+	// observations stored before the destination address was recorded have no address (key 0)
+	sa, err := idMap.GetValue(o.DestAddress)
+	if err != nil {
+		return nil, err
+	}
+	sp, err := idMap.GetValue(o.Protocol)
+	if err != nil {
+		return nil, err
+	}
+	si, err := idMap.GetValue(o.DestIP)
+	if err != nil {
+		return nil, err
+	}
+	sls, err := idMap.GetValue(o.ListSource)
+	if err != nil {
+		return nil, err
+	}
+	slv, err := idMap.GetValue(o.ListVersion)
+	if err != nil {
+		return nil, err
+	}
+	sn, err := idMap.GetValue(o.Netns)
+	if err != nil {
+		return nil, err
+	}
+	sfc, err := idMap.GetValue(o.FailureClass)
+	if err != nil {
+		return nil, err
+	}
+	sif, err := idMap.GetValue(o.Interface)
+	if err != nil {
+		return nil, err
+	}
+	sat, err := idMap.GetValue(o.DestAddressType)
+	if err != nil {
+		return nil, err
+	}
+	sr, err := idMap.GetValue(o.Result)
+	if err != nil {
+		return nil, err
+	}
+	var duration, period, previousStateDuration, udpDuration, tcpDuration *durationpb.Duration
 	if o.DurationMillis > 0 {
 		duration = durationpb.New(time.Millisecond * time.Duration(o.DurationMillis))
 	}
 	if o.PeriodMillis > 0 {
 		period = durationpb.New(time.Millisecond * time.Duration(o.PeriodMillis))
 	}
+	if o.PreviousStateMillis > 0 {
+		previousStateDuration = durationpb.New(time.Millisecond * time.Duration(o.PreviousStateMillis))
+	}
+	if o.UdpDurationMillis > 0 {
+		udpDuration = durationpb.New(time.Millisecond * time.Duration(o.UdpDurationMillis))
+	}
+	if o.TcpDurationMillis > 0 {
+		tcpDuration = durationpb.New(time.Millisecond * time.Duration(o.TcpDurationMillis))
+	}
+	var triggeredBy string
+	if o.TriggeredByTimeMillis != 0 {
+		triggerJobID, err := idMap.GetValue(o.TriggeredByJobID)
+		if err != nil {
+			return nil, err
+		}
+		triggeredBy = nwpd.ObservationID(triggerJobID, ss, sd, o.TriggeredByTimeMillis)
+	}
 	return &nwpd.Observation{
-		JobID:     sj,
-		SrcHost:   ss,
-		DestHost:  sd,
-		Timestamp: timestamppb.New(time.UnixMilli(o.TimeMillis)),
-		Duration:  duration,
-		Ok:        o.Ok,
-		Period:    period,
+		JobID:                 sj,
+		SrcHost:               ss,
+		DestHost:              sd,
+		DestAddress:           sa,
+		Protocol:              sp,
+		DestIP:                si,
+		DestPort:              o.DestPort,
+		SrcPort:               o.SrcPort,
+		Partial:               o.Partial,
+		Maintenance:           o.Maintenance,
+		LatencyBreach:         o.LatencyBreach,
+		WarmingUp:             o.WarmingUp,
+		ListSource:            sls,
+		ListVersion:           slv,
+		Netns:                 sn,
+		FailureClass:          sfc,
+		Interface:             sif,
+		DestAddressType:       sat,
+		Result:                sr,
+		Timestamp:             timestamppb.New(time.UnixMilli(o.TimeMillis)),
+		Duration:              duration,
+		Ok:                    o.Ok,
+		Period:                period,
+		TriggeredBy:           triggeredBy,
+		PreviousStateDuration: previousStateDuration,
+		UdpDuration:           udpDuration,
+		TcpDuration:           tcpDuration,
 	}, nil
 }
 
+// ApplyDetailLevel returns the observation with the fields stored for the detail level. The observation is not modified,
+// a reduced copy is returned if needed.
+func ApplyDetailLevel(obs *nwpd.Observation, level config.DetailLevel) *nwpd.Observation {
+	switch level {
+	case config.DetailLevelFull:
+		return obs
+	case config.DetailLevelMinimal:
+		return &nwpd.Observation{
+			JobID:                 obs.JobID,
+			SrcHost:               obs.SrcHost,
+			DestHost:              obs.DestHost,
+			Timestamp:             obs.Timestamp,
+			Ok:                    obs.Ok,
+			Period:                obs.Period,
+			Partial:               obs.Partial,
+			Maintenance:           obs.Maintenance,
+			LatencyBreach:         obs.LatencyBreach,
+			WarmingUp:             obs.WarmingUp,
+			TriggeredBy:           obs.TriggeredBy,
+			PreviousStateDuration: obs.PreviousStateDuration,
+		}
+	default:
+		if obs.Result == "" {
+			return obs
+		}
+		reduced := proto.Clone(obs).(*nwpd.Observation)
+		reduced.Result = ""
+		return reduced
+	}
+}
+
 func IntObsToBytes(o *nwpd.IntObservation) ([]byte, error) {
 	return proto.Marshal(o)
 }
@@ -81,3 +272,41 @@ func IntObsFromBytes(value []byte) (*nwpd.IntObservation, error) {
 	}
 	return intobs, nil
 }
+
+// legacyProtocols maps the job ID prefixes of the default jobs to the protocol.
+var legacyProtocols = []struct {
+	prefix   string
+	protocol string
+}{
+	{"tcp-", nwpd.ProtocolTCP},
+	{"https-", nwpd.ProtocolHTTPS},
+	{"ping-", nwpd.ProtocolICMP},
+	{"nslookup-", nwpd.ProtocolUDP},
+	{"dns-", nwpd.ProtocolUDP},
+}
+
+// upgradeV1Observation fills the structured fields of an observation read from a record of schema version 1
+// from the job ID of the default jobs and the destination address.
+func upgradeV1Observation(obs *nwpd.Observation) {
+	if obs.Protocol == "" {
+		for _, p := range legacyProtocols {
+			if strings.HasPrefix(obs.JobID, p.prefix) {
+				obs.Protocol = p.protocol
+				break
+			}
+		}
+	}
+	if obs.DestAddress == "" || obs.DestIP != "" {
+		return
+	}
+	host, port := obs.DestAddress, ""
+	if h, p, err := net.SplitHostPort(obs.DestAddress); err == nil {
+		host, port = h, p
+	}
+	if net.ParseIP(host) != nil {
+		obs.DestIP = host
+	}
+	if n, err := strconv.Atoi(port); err == nil {
+		obs.DestPort = int32(n)
+	}
+}
@@ -0,0 +1,162 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestIntObservationDestAddress(t *testing.T) {
+	idMap := NewStringIdMap()
+	timestamp := time.UnixMilli(time.Now().UnixMilli())
+	obs := &nwpd.Observation{
+		JobID:       "tcp-n2n",
+		SrcHost:     "node-a",
+		DestHost:    "node-b",
+		DestAddress: "10.250.0.2:1011",
+		Timestamp:   timestamppb.New(timestamp),
+		Duration:    durationpb.New(5 * time.Millisecond),
+		Period:      durationpb.New(10 * time.Second),
+		Ok:          true,
+	}
+
+	intobs, err := ToIntObservation(obs, idMap, nil)
+	assert.NoError(t, err)
+	data, err := IntObsToBytes(intobs)
+	assert.NoError(t, err)
+	intobs, err = IntObsFromBytes(data)
+	assert.NoError(t, err)
+	result, err := IntObsToObservation(intobs, idMap)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.250.0.2:1011", result.DestAddress)
+	assert.Equal(t, "node-b", result.DestHost)
+
+	// a new address of the same destination host keeps the edge
+	obs.DestAddress = "10.250.0.3:1011"
+	intobs2, err := ToIntObservation(obs, idMap, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, intobs.DestHost, intobs2.DestHost)
+	assert.NotEqual(t, intobs.DestAddress, intobs2.DestAddress)
+
+	// records written before the address was recorded have no address
+	intobs.DestAddress = 0
+	data, err = IntObsToBytes(intobs)
+	assert.NoError(t, err)
+	intobs, err = IntObsFromBytes(data)
+	assert.NoError(t, err)
+	result, err = IntObsToObservation(intobs, idMap)
+	assert.NoError(t, err)
+	assert.Empty(t, result.DestAddress)
+	assert.Equal(t, "node-b", result.DestHost)
+	assert.Equal(t, timestamp, result.Timestamp.AsTime().Local())
+}
+
+func TestIntObservationStructuredFields(t *testing.T) {
+	idMap := NewStringIdMap()
+	obs := &nwpd.Observation{
+		JobID:       "https-p2api-int",
+		SrcHost:     "node-a",
+		DestHost:    "kubernetes.default.svc.cluster.local.",
+		DestAddress: "kubernetes.default.svc.cluster.local.:443",
+		Protocol:    nwpd.ProtocolHTTPS,
+		DestPort:    443,
+		Timestamp:   timestamppb.Now(),
+	}
+	intobs, err := ToIntObservation(obs, idMap, nil)
+	assert.NoError(t, err)
+	result, err := IntObsToObservation(intobs, idMap)
+	assert.NoError(t, err)
+	assert.Equal(t, nwpd.ProtocolHTTPS, result.Protocol)
+	assert.Empty(t, result.DestIP)
+	assert.Equal(t, int32(443), result.DestPort)
+}
+
+func TestIterateRecordFileSchemaVersions(t *testing.T) {
+	timestamp := timestamppb.New(time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC))
+	v1 := []*nwpd.Observation{
+		{JobID: "tcp-n2p", SrcHost: "node-a", DestHost: "node-b", DestAddress: "10.128.0.2:1012", Timestamp: timestamp},
+		{JobID: "ping-n2n", SrcHost: "node-a", DestHost: "node-b", DestAddress: "10.250.0.2", Timestamp: timestamp},
+		{JobID: "https-n2api-ext", SrcHost: "node-a", DestHost: "api.example.com", DestAddress: "api.example.com:443", Timestamp: timestamp},
+		{JobID: "custom", SrcHost: "node-a", DestHost: "node-b", Timestamp: timestamp},
+	}
+	v2 := &nwpd.Observation{JobID: "custom", SrcHost: "node-a", DestHost: "node-b", DestAddress: "10.0.0.1:80",
+		Protocol: nwpd.ProtocolTCP, DestIP: "10.0.0.1", DestPort: 80, Timestamp: timestamp}
+
+	// a file of an agent with schema version 1 which is appended after an update of the agent
+	filename := filepath.Join(t.TempDir(), "test.records")
+	f, err := os.Create(filename)
+	assert.NoError(t, err)
+	idMap := NewStringIdMap()
+	write := func(obs *nwpd.Observation) {
+		intobs, err := ToIntObservation(obs, idMap, &legacyPersistor{f: f})
+		assert.NoError(t, err)
+		value, err := IntObsToBytes(intobs)
+		assert.NoError(t, err)
+		assert.NoError(t, writeLegacyRecord(f, markerObservation, value))
+	}
+	assert.NoError(t, writeLegacyRecord(f, markerOpen, []byte("10:00:00")))
+	for _, obs := range v1 {
+		write(obs)
+	}
+	assert.NoError(t, writeLegacyRecord(f, markerOpen, openRecordValue(time.Now())))
+	write(v2)
+	assert.NoError(t, f.Close())
+
+	var result []*nwpd.Observation
+	assert.NoError(t, IterateRecordFile(filename, func(obs *nwpd.Observation) error {
+		result = append(result, obs)
+		return nil
+	}))
+	assert.Len(t, result, 5)
+	type fields struct {
+		protocol string
+		ip       string
+		port     int32
+	}
+	var actual []fields
+	for _, obs := range result {
+		actual = append(actual, fields{obs.Protocol, obs.DestIP, obs.DestPort})
+	}
+	assert.Equal(t, []fields{
+		{nwpd.ProtocolTCP, "10.128.0.2", 1012},
+		{nwpd.ProtocolICMP, "10.250.0.2", 0},
+		{nwpd.ProtocolHTTPS, "", 443},
+		{"", "", 0},
+		{nwpd.ProtocolTCP, "10.0.0.1", 80},
+	}, actual)
+}
+
+func TestParseSchemaVersion(t *testing.T) {
+	assert.Equal(t, 1, parseSchemaVersion([]byte("10:00:00")))
+	assert.Equal(t, 2, parseSchemaVersion([]byte("v2 10:00:00")))
+	assert.Equal(t, schemaVersion, parseSchemaVersion(openRecordValue(time.Now())))
+}
+
+func TestIntObservationDestAddressType(t *testing.T) {
+	idMap := NewStringIdMap()
+	obs := &nwpd.Observation{
+		JobID:           "tcp-n2n-ext",
+		SrcHost:         "node-a",
+		DestHost:        "node-b",
+		DestAddress:     "1.2.3.4:10250",
+		DestAddressType: config.NodeAddressTypeExternal,
+		Timestamp:       timestamppb.Now(),
+	}
+	intobs, err := ToIntObservation(obs, idMap, nil)
+	assert.NoError(t, err)
+	result, err := IntObsToObservation(intobs, idMap)
+	assert.NoError(t, err)
+	assert.Equal(t, config.NodeAddressTypeExternal, result.DestAddressType)
+}
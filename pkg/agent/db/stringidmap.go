@@ -112,6 +112,8 @@ func (m *StringIdMap) GetKey(persistor IntStringPersistor, s string) (int64, err
 				value: s,
 			})
 			if err != nil {
+				// the key is assigned again on the next call
+				m.last--
 				return 0, err
 			}
 		}
@@ -6,17 +6,21 @@ package db
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"sort"
+	"strconv"
 	"strings"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
 	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
 
 	"github.com/sirupsen/logrus"
@@ -24,14 +28,32 @@ import (
 )
 
 type obsWriter struct {
-	log            logrus.FieldLogger
-	directory      string
-	prefix         string
-	retentionHours int
-	currentFile    atomic.Value
-	obsChan        chan *nwpd.Observation
-	done           chan struct{}
-	ticker         *time.Ticker
+	log              logrus.FieldLogger
+	directory        string
+	naming           *config.FileNaming
+	retentionHours   int
+	currentFile      atomic.Value
+	unknownFiles     atomic.Value
+	detailLevel      atomic.Value
+	degradedListener atomic.Value
+	networkInfo      atomic.Value
+	buffer           *obsBuffer
+	done             chan struct{}
+	stopped          chan struct{}
+	ticker           *time.Ticker
+	openFile         func(filename string) (recordFile, error)
+	// freeDisk returns the free space of the filesystem of the directory in percent
+	freeDisk func(directory string) (float64, error)
+	// minFreeDiskPercent is the free disk space below which the writer switches to the degraded mode, 0 to disable
+	minFreeDiskPercent float64
+	diskFreeListener   atomic.Value
+
+	// degraded mode while the output directory is not writable, only used by the goroutine of Run
+	degraded       bool
+	pending        *obsBuffer
+	pendingDropped int
+	retryBackoff   time.Duration
+	nextRetry      time.Time
 }
 
 var _ nwpd.ObservationWriter = &obsWriter{}
@@ -42,10 +64,52 @@ const (
 	markerOpen        = 127
 )
 
+const (
+	// degradedRetryMin is the initial delay of retrying to write to the output directory in degraded mode
+	degradedRetryMin = 5 * time.Second
+	// degradedRetryMax is the maximum delay of retrying to write to the output directory in degraded mode
+	degradedRetryMax = 2 * time.Minute
+	// freeDiskHysteresis is the free disk space in percentage points above the minimum needed to leave the degraded mode,
+	// so that the writer does not switch back on the first written observations.
+	freeDiskHysteresis = 1.0
+)
+
+// schemaVersion is the version of the observation records written to the record files. It is stored in the open record
+// with the prefix 'v'. Version 1 records (open record without version) have no structured protocol, IP and port fields.
+const schemaVersion = 2
+
+func openRecordValue(now time.Time) []byte {
+	return []byte(fmt.Sprintf("v%d %s", schemaVersion, now.UTC().Format("15:04:05")))
+}
+
+// parseSchemaVersion returns the schema version of the records following the open record.
+func parseSchemaVersion(value []byte) int {
+	s := string(value)
+	if !strings.HasPrefix(s, "v") {
+		return 1
+	}
+	if i := strings.Index(s, " "); i > 0 {
+		if version, err := strconv.Atoi(s[1:i]); err == nil {
+			return version
+		}
+	}
+	return 1
+}
+
+// recordFile is the file the records are appended to.
+type recordFile interface {
+	io.Writer
+	Sync() error
+	Close() error
+	Truncate(size int64) error
+	Stat() (os.FileInfo, error)
+}
+
 type writeFile struct {
 	filename string
 	end      time.Time
-	file     *os.File
+	file     recordFile
+	size     int64
 	idMap    *StringIdMap
 }
 
@@ -60,121 +124,345 @@ func (wf *writeFile) Persist(obj *IntString) error {
 	if err != nil {
 		return err
 	}
-	return writeRecord(wf.file, markerStringID, bytes)
+	return wf.writeRecord(markerStringID, bytes)
+}
+
+// writeHeader writes the magic bytes and the header at the start of a new file.
+func (wf *writeFile) writeHeader(header *nwpd.RecordFileHeader) error {
+	head, err := encodeRecordFileHeader(header)
+	if err != nil {
+		return err
+	}
+	return wf.write(head)
+}
+
+// writeRecord appends a record to the file. If writing fails, the file is truncated to its previous size
+// so that no incomplete record is left behind (not possible on a read-only filesystem, but nothing is written there).
+func (wf *writeFile) writeRecord(marker byte, value []byte) error {
+	return wf.write(encodeRecord(marker, value))
+}
+
+func (wf *writeFile) write(data []byte) error {
+	n, err := wf.file.Write(data)
+	if err != nil {
+		if n > 0 {
+			_ = wf.file.Truncate(wf.size)
+		}
+		return err
+	}
+	wf.size += int64(n)
+	return nil
 }
 
 var _ nwpd.ObservationWriter = &obsWriter{}
 
-func NewObsWriter(log logrus.FieldLogger, directory, prefix string, retentionHours int) (*obsWriter, error) {
+// NewObsWriter creates a writer storing the observations in hourly record files in the directory.
+// The observations are buffered in memory according to the buffer config (nil for defaults). onDrop is called
+// for each observation dropped by the backpressure policy or in degraded mode.
+// If the filesystem of the directory is full or read-only, or its free space is below the minimum of the buffer config,
+// the writer switches to a degraded mode keeping the observations in memory until the directory is writable again.
+func NewObsWriter(log logrus.FieldLogger, directory string, naming *config.FileNaming, retentionHours int,
+	bufferCfg *config.ObservationBufferConfig, onDrop func()) (*obsWriter, error) {
 	err := os.MkdirAll(directory, 0777)
 	if err != nil {
-		return nil, err
+		if !isFilesystemUnwritable(err) {
+			return nil, err
+		}
+		// the directory is created again on writing
+		log.Warnf("cannot create output directory %s: %s", directory, err)
+	}
+	if onDrop == nil {
+		onDrop = func() {}
 	}
 	writer := &obsWriter{
 		log:            log,
 		directory:      directory,
-		prefix:         prefix,
+		naming:         naming,
 		retentionHours: retentionHours,
-		obsChan:        make(chan *nwpd.Observation, 100),
+		buffer:         newObsBuffer(bufferCfg.SizeOrDefault(), bufferCfg.PolicyOrDefault(), onDrop),
 		done:           make(chan struct{}),
+		stopped:        make(chan struct{}),
 		ticker:         time.NewTicker(5 * time.Second),
+		openFile:       openRecordFile,
+		freeDisk:       freeDiskPercent,
+
+		minFreeDiskPercent: bufferCfg.MinFreeDiskPercentOrDefault(),
 	}
+	writer.pending = newObsBuffer(bufferCfg.DegradedSizeOrDefault(), config.BackpressureDropOldest, func() {
+		writer.pendingDropped++
+		onDrop()
+	})
 
 	return writer, nil
 }
 
+func openRecordFile(filename string) (recordFile, error) {
+	return os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+}
+
+// isFilesystemUnwritable returns true if the error is caused by a full or read-only filesystem or an exceeded disk quota.
+func isFilesystemUnwritable(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EROFS) || errors.Is(err, syscall.EDQUOT)
+}
+
+// unknownFilesCleanup configures the deletion of files in the directory not belonging to any known data file prefix.
+type unknownFilesCleanup struct {
+	knownNamings func() []*config.FileNaming
+	gracePeriod  time.Duration
+}
+
+// SetUnknownFilesCleanup enables the deletion of files in the directory which do not belong to any of the known data file prefixes,
+// e.g. files of renamed prefixes or foreign files. They are deleted on file rotation if they have not been modified within
+// the grace period (at least the retention time).
+func (w *obsWriter) SetUnknownFilesCleanup(knownNamings func() []*config.FileNaming, gracePeriod time.Duration) {
+	w.unknownFiles.Store(&unknownFilesCleanup{knownNamings: knownNamings, gracePeriod: gracePeriod})
+}
+
+// SetDetailLevel sets the detail level of the observations written from now on (default standard).
+func (w *obsWriter) SetDetailLevel(level config.DetailLevel) {
+	w.detailLevel.Store(level)
+}
+
+// SetNetworkInfo sets the network info of the agent recorded in the headers of the record files created from now on.
+func (w *obsWriter) SetNetworkInfo(info []*nwpd.NetworkInfo) {
+	w.networkInfo.Store(info)
+}
+
+// SetDegradedListener sets a listener called with false initially and on each switch to or from the degraded mode.
+// It must be called before Run.
+func (w *obsWriter) SetDegradedListener(listener func(degraded bool)) {
+	w.degradedListener.Store(listener)
+	listener(false)
+}
+
+// SetDiskFreeListener sets a listener called with the free space of the filesystem of the output directory in percent
+// each time it is checked. It must be called before Run.
+func (w *obsWriter) SetDiskFreeListener(listener func(percent float64)) {
+	w.diskFreeListener.Store(listener)
+}
+
+func (w *obsWriter) reportDegraded(degraded bool) {
+	if listener, ok := w.degradedListener.Load().(func(bool)); ok {
+		listener(degraded)
+	}
+}
+
 func (w *obsWriter) Add(obs *nwpd.Observation) {
-	w.obsChan <- obs
+	w.buffer.add(obs)
 }
 
 func (w *obsWriter) Stop() {
+	w.done <- struct{}{}
+	<-w.stopped
 	if w.ticker != nil {
 		w.ticker.Stop()
 		w.ticker = nil
 	}
-	w.done <- struct{}{}
-	file := w.currentFile.Load().(*writeFile)
-	if file != nil {
+	if file, ok := w.currentFile.Load().(*writeFile); ok && file != nil {
 		_ = file.file.Close()
 	}
 }
 
 func (w *obsWriter) Run() {
+	defer close(w.stopped)
 	for {
 		select {
 		case <-w.done:
+			w.flush()
 			return
 		case <-w.ticker.C:
-			file, err := w.getFile()
-			if err != nil {
-				w.log.Warnf("sync failed: getFile: %s", err)
+			if w.degraded {
+				w.retryDegraded(false)
 				continue
 			}
-			err = file.file.Sync()
-			if err != nil {
-				w.log.Warnf("sync failed: %s", err)
+			if w.checkFreeDisk() {
 				continue
 			}
-		case obs := <-w.obsChan:
 			file, err := w.getFile()
 			if err != nil {
-				w.log.Warnf("write failed: getFile: %s", err)
-				continue
-			}
-			intobs, err := ToIntObservation(obs, file.idMap, file)
-			if err != nil {
-				w.log.Warnf("write failed: ToIntObservation: %s", err)
+				w.handleWriteError(fmt.Errorf("sync failed: getFile: %w", err))
 				continue
 			}
-			value, err := IntObsToBytes(intobs)
+			err = file.file.Sync()
 			if err != nil {
-				w.log.Warnf("write failed: IntObsToBytes: %s", err)
+				w.handleWriteError(fmt.Errorf("sync failed: %w", err))
 				continue
 			}
-			if err := writeRecord(file.file, markerObservation, value); err != nil {
-				w.log.Warnf("write failed: %s", err)
-				continue
+		case <-w.buffer.notify:
+			for _, obs := range w.buffer.take() {
+				w.write(obs)
 			}
 		}
 	}
 }
 
-func writeRecord(w io.Writer, marker byte, value []byte) error {
-	if _, err := w.Write([]byte{marker}); err != nil {
-		return err
+// flush writes all pending observations and syncs the current file.
+func (w *obsWriter) flush() {
+	for items := w.buffer.take(); len(items) > 0; items = w.buffer.take() {
+		for _, obs := range items {
+			w.write(obs)
+		}
+	}
+	if w.degraded {
+		// last try to save the observations kept in memory
+		w.nextRetry = time.Time{}
+		w.retryDegraded(true)
+		if w.degraded {
+			w.log.Errorf("output directory %s not writable on stop: %d observations kept in memory are lost", w.directory, w.pending.len())
+		}
+		return
 	}
+	if file, ok := w.currentFile.Load().(*writeFile); ok && file != nil {
+		if err := file.file.Sync(); err != nil {
+			w.log.Warnf("sync failed: %s", err)
+		}
+	}
+}
 
-	if err := binary.Write(w, binary.LittleEndian, uint16(len(value))); err != nil {
-		return err
+func (w *obsWriter) write(obs *nwpd.Observation) {
+	if w.degraded {
+		w.pending.add(obs)
+		return
 	}
+	if err := w.writeObservation(obs); err != nil {
+		if w.handleWriteError(err) {
+			w.pending.add(obs)
+		}
+	}
+}
 
-	if _, err := w.Write(value); err != nil {
-		return err
+// handleWriteError logs the error and switches to the degraded mode if the filesystem is full or read-only.
+// It returns true in this case.
+func (w *obsWriter) handleWriteError(err error) bool {
+	if !isFilesystemUnwritable(err) {
+		w.log.Warnf("%s", err)
+		return false
 	}
-	return nil
+	w.enterDegraded(err)
+	return true
+}
+
+// freeDiskPercent returns the free space of the filesystem of the output directory and reports it to the listener.
+// ok is false if it cannot be determined.
+func (w *obsWriter) freeDiskPercent() (percent float64, ok bool) {
+	percent, err := w.freeDisk(w.directory)
+	if err != nil {
+		w.log.Debugf("cannot determine free disk space of %s: %s", w.directory, err)
+		return 0, false
+	}
+	if listener, ok := w.diskFreeListener.Load().(func(float64)); ok {
+		listener(percent)
+	}
+	return percent, true
+}
+
+// checkFreeDisk switches to the degraded mode if the free space of the filesystem of the output directory is below
+// the minimum. It returns true in this case.
+func (w *obsWriter) checkFreeDisk() bool {
+	percent, ok := w.freeDiskPercent()
+	if !ok || percent >= w.minFreeDiskPercent {
+		return false
+	}
+	w.enterDegraded(fmt.Errorf("free disk space %.1f%% below minimum of %.1f%%", percent, w.minFreeDiskPercent))
+	return true
+}
+
+// enterDegraded switches to the degraded mode keeping the observations in memory.
+func (w *obsWriter) enterDegraded(reason error) {
+	w.degraded = true
+	w.pendingDropped = 0
+	w.retryBackoff = degradedRetryMin
+	w.nextRetry = time.Now().Add(w.retryBackoff)
+	w.discardFile()
+	w.log.Errorf("output directory %s not writable (%s): switching to degraded mode keeping up to %d observations in memory",
+		w.directory, reason, w.pending.size)
+	w.reportDegraded(true)
+}
+
+// retryDegraded tries to write the observations kept in memory if the retry delay has expired. If this succeeds,
+// the degraded mode is left, otherwise the retry delay is doubled up to the maximum.
+// Unless final, the writing is also postponed while the free disk space is below the minimum plus the hysteresis.
+func (w *obsWriter) retryDegraded(final bool) {
+	if !w.degraded || time.Now().Before(w.nextRetry) {
+		return
+	}
+	if percent, ok := w.freeDiskPercent(); ok && !final && w.minFreeDiskPercent > 0 && percent < w.minFreeDiskPercent+freeDiskHysteresis {
+		w.postponeRetry(fmt.Errorf("free disk space %.1f%% below %.1f%%", percent, w.minFreeDiskPercent+freeDiskHysteresis))
+		return
+	}
+	items := w.pending.take()
+	written := 0
+	for i, obs := range items {
+		err := w.writeObservation(obs)
+		if err == nil {
+			written++
+			continue
+		}
+		if !isFilesystemUnwritable(err) {
+			w.log.Warnf("%s", err)
+			continue
+		}
+		for _, item := range items[i:] {
+			w.pending.add(item)
+		}
+		w.discardFile()
+		w.postponeRetry(err)
+		return
+	}
+	w.degraded = false
+	w.log.Infof("output directory %s writable again: leaving degraded mode after writing %d observations kept in memory (%d dropped)",
+		w.directory, written, w.pendingDropped)
+	w.reportDegraded(false)
 }
 
-func readRecord(r io.Reader) (byte, []byte, error) {
-	marker := make([]byte, 1)
-	if n, err := r.Read(marker); err == io.EOF {
-		return 0, nil, nil
-	} else if err != nil {
-		return 0, nil, err
-	} else if n != 1 {
-		return 0, nil, fmt.Errorf("missing marker")
+// postponeRetry doubles the retry delay of the degraded mode up to the maximum.
+func (w *obsWriter) postponeRetry(reason error) {
+	w.retryBackoff *= 2
+	if w.retryBackoff > degradedRetryMax {
+		w.retryBackoff = degradedRetryMax
 	}
+	w.nextRetry = time.Now().Add(w.retryBackoff)
+	w.log.Warnf("output directory %s still not writable (%s): retrying in %s", w.directory, reason, w.retryBackoff)
+}
 
-	var len uint16
-	if err := binary.Read(r, binary.LittleEndian, &len); err != nil {
-		return 0, nil, err
+// discardFile closes the current file after a failed write. On the next write, the file is opened again and
+// its string table is reloaded, as it may be missing strings which could not be persisted.
+func (w *obsWriter) discardFile() {
+	if file, ok := w.currentFile.Load().(*writeFile); ok && file != nil {
+		_ = file.file.Close()
+		w.currentFile.Store((*writeFile)(nil))
+	}
+}
+
+func (w *obsWriter) writeObservation(obs *nwpd.Observation) error {
+	file, err := w.getFile()
+	if err != nil {
+		return fmt.Errorf("write failed: getFile: %w", err)
 	}
-	value := make([]byte, len)
-	if n, err := r.Read(value); err != nil {
-		return 0, nil, err
-	} else if n != int(len) {
-		return 0, nil, fmt.Errorf("incomplete block: %d != %d", n, int(len))
+	level, _ := w.detailLevel.Load().(config.DetailLevel)
+	if level == "" {
+		level = config.DetailLevelStandard
 	}
-	return marker[0], value, nil
+	intobs, err := ToIntObservation(ApplyDetailLevel(obs, level), file.idMap, file)
+	if err != nil {
+		return fmt.Errorf("write failed: ToIntObservation: %w", err)
+	}
+	value, err := IntObsToBytes(intobs)
+	if err != nil {
+		return fmt.Errorf("write failed: IntObsToBytes: %w", err)
+	}
+	if err := file.writeRecord(markerObservation, value); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	return nil
+}
+
+// encodeRecord returns the marker, the length and the value of a record in the format version 2.
+func encodeRecord(marker byte, value []byte) []byte {
+	buf := make([]byte, 1, 1+binary.MaxVarintLen64+len(value))
+	buf[0] = marker
+	buf = appendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
 }
 
 func (w *obsWriter) loadStringIdMap(filename string) (*StringIdMap, error) {
@@ -185,10 +473,15 @@ func (w *obsWriter) loadStringIdMap(filename string) (*StringIdMap, error) {
 		}
 		return nil, err
 	}
+	defer f.Close()
 
+	rr, err := newRecordReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading StringIdMap failed: %s", err)
+	}
 	var objects []*IntString
 	for {
-		marker, value, err := readRecord(f)
+		marker, value, err := rr.next()
 		if err != nil {
 			return nil, fmt.Errorf("reading StringIdMap failed: %s", err)
 		}
@@ -234,14 +527,29 @@ func (w *obsWriter) getFile() (*writeFile, error) {
 		currentUTC := startOfHourUTC(now)
 		next := now.Add(61 * time.Minute)
 		nextUTC := startOfHourUTC(next)
-		filename := fmt.Sprintf("%s/%s-%s.records", w.directory, w.prefix, currentUTC.Format("2006-01-02-15"))
+		filename := path.Join(w.directory, w.naming.Filename(currentUTC))
+		if err := os.MkdirAll(w.directory, 0777); err != nil {
+			return nil, err
+		}
+		header := NewRecordFileHeader(w.naming.Node(), w.naming.Prefix(), currentUTC)
+		header.NetworkInfo, _ = w.networkInfo.Load().([]*nwpd.NetworkInfo)
+		// a file of the current hour written by the previous release is converted before appending
+		if converted, err := ConvertRecordFile(filename, "", header); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		} else if converted {
+			w.log.Infof("converted record file %s to format %s", filename, FormatV2)
+		}
 		idMap, err := w.loadStringIdMap(filename)
-		f, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 		if err != nil {
 			return nil, err
 		}
-		err = writeRecord(f, markerOpen, []byte(now.UTC().Format("15:04:05")))
+		f, err := w.openFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		info, err := f.Stat()
 		if err != nil {
+			_ = f.Close()
 			return nil, err
 		}
 		file = &writeFile{
@@ -249,6 +557,17 @@ func (w *obsWriter) getFile() (*writeFile, error) {
 			end:      nextUTC,
 			idMap:    idMap,
 			file:     f,
+			size:     info.Size(),
+		}
+		if file.size == 0 {
+			if err := file.writeHeader(header); err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+		}
+		if err := file.writeRecord(markerOpen, openRecordValue(now)); err != nil {
+			_ = f.Close()
+			return nil, err
 		}
 		w.currentFile.Store(file)
 	}
@@ -274,7 +593,7 @@ func (w *obsWriter) cleanOldFiles() {
 		return
 	}
 	for _, f := range files {
-		if !f.IsDir() && strings.HasPrefix(f.Name(), w.prefix) && f.ModTime().Before(limitUTC) {
+		if !f.IsDir() && w.naming.Matches(f.Name()) && f.ModTime().Before(limitUTC) {
 			filename := path.Join(w.directory, f.Name())
 			if err := os.Remove(filename); err != nil {
 				w.log.Warnf("cannot delete file %s: %s", filename, err)
@@ -283,6 +602,66 @@ func (w *obsWriter) cleanOldFiles() {
 			}
 		}
 	}
+	if cleanup, ok := w.unknownFiles.Load().(*unknownFilesCleanup); ok && cleanup != nil {
+		gracePeriod := cleanup.gracePeriod
+		if retention := time.Duration(hours) * time.Hour; gracePeriod < retention {
+			gracePeriod = retention
+		}
+		cleanUnknownFiles(w.log, w.directory, cleanup.knownNamings(), time.Now().Add(-gracePeriod))
+	}
+}
+
+// cleanUnknownFiles deletes the regular files in the directory not matching any of the known file namings
+// which have not been modified since the limit. Directories and other files like sockets are never deleted.
+func cleanUnknownFiles(log logrus.FieldLogger, directory string, knownNamings []*config.FileNaming, limit time.Time) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		log.Warnf("cannot read directory %s: %s", directory, err)
+		return
+	}
+	isKnown := func(name string) bool {
+		for _, naming := range knownNamings {
+			if naming.Matches(name) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || isKnown(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(limit) {
+			continue
+		}
+		reason := "foreign file"
+		if prefix, ok := parseRecordFilename(entry.Name()); ok {
+			reason = fmt.Sprintf("unknown data file prefix %s", prefix)
+		} else if strings.HasSuffix(entry.Name(), config.RecordFileSuffix) {
+			reason = "unknown file name"
+		}
+		filename := path.Join(directory, entry.Name())
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			log.Warnf("cannot delete unknown file %s: %s", filename, err)
+		} else if err == nil {
+			log.Infof("deleted unknown file %s (%s, last modified %s)", filename, reason, info.ModTime().UTC().Format(time.RFC3339))
+		}
+	}
+}
+
+// parseRecordFilename returns the data file prefix of a record file name in the format '<prefix>-YYYY-MM-DD-HH.records'.
+func parseRecordFilename(name string) (string, bool) {
+	const hourLayout = "2006-01-02-15"
+	base := strings.TrimSuffix(name, ".records")
+	if base == name || len(base) < len(hourLayout)+2 {
+		return "", false
+	}
+	hour := base[len(base)-len(hourLayout):]
+	if _, err := time.Parse(hourLayout, hour); err != nil || base[len(base)-len(hourLayout)-1] != '-' {
+		return "", false
+	}
+	return base[:len(base)-len(hourLayout)-1], true
 }
 
 type filterFunc func(key string) bool
@@ -325,7 +704,7 @@ func (w *obsWriter) ListObservations(options nwpd.ListObservationsOptions) (nwpd
 	srcHostFilter := createFilter(options.FilterSrcHosts)
 	descHostFilter := createFilter(options.FilterDestHosts)
 
-	files, err := GetRecordFiles(w.directory, w.prefix, start, end)
+	files, err := GetRecordFiles(w.directory, w.naming, start, end)
 	if err != nil {
 		return nil, err
 	}
@@ -363,12 +742,18 @@ func startOfHourUTC(t time.Time) time.Time {
 }
 
 // GetRecordFiles gets all observation record files
-func GetRecordFiles(directory, prefix string, start, end time.Time) ([]string, error) {
+func GetRecordFiles(directory string, naming *config.FileNaming, start, end time.Time) ([]string, error) {
 	startHour := startOfHourUTC(start)
 	endHour := startOfHourUTC(end)
 	var files []string
+	last := ""
 	for hour := startHour; !hour.After(endHour); hour = hour.Add(time.Hour) {
-		filename := fmt.Sprintf("%s/%s-%s.records", directory, prefix, hour.Format("2006-01-02-15"))
+		filename := path.Join(directory, naming.Filename(hour))
+		if filename == last {
+			// several hours share a file if the file name template has no hour
+			continue
+		}
+		last = filename
 		if stat, err := os.Stat(filename); err != nil {
 			if os.IsNotExist(err) {
 				continue
@@ -411,6 +796,47 @@ func GetAnyRecordFiles(directory string, subdir bool) ([]string, error) {
 	return files, nil
 }
 
+// WriteRecordFile writes the given observations to a new record file.
+// The result of an observation is not persisted.
+func WriteRecordFile(filename string, observations []*nwpd.Observation) error {
+	return WriteRecordFileWithHeader(filename, RecordFileHeaderFromFilename(filename, ""), observations)
+}
+
+// WriteRecordFileWithHeader writes the given observations to a new record file with the given file header.
+func WriteRecordFileWithHeader(filename string, header *nwpd.RecordFileHeader, observations []*nwpd.Observation) error {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	wf := &writeFile{
+		filename: filename,
+		file:     f,
+		idMap:    NewStringIdMap(),
+	}
+	if err := wf.writeHeader(header); err != nil {
+		return err
+	}
+	if err := wf.writeRecord(markerOpen, openRecordValue(time.Now())); err != nil {
+		return err
+	}
+	for _, obs := range observations {
+		intobs, err := ToIntObservation(ApplyDetailLevel(obs, config.DetailLevelStandard), wf.idMap, wf)
+		if err != nil {
+			return err
+		}
+		value, err := IntObsToBytes(intobs)
+		if err != nil {
+			return err
+		}
+		if err := wf.writeRecord(markerObservation, value); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
+
 type ObservationVisitor func(obs *nwpd.Observation) error
 
 func IterateRecordFile(filename string, visitor ObservationVisitor) error {
@@ -418,10 +844,17 @@ func IterateRecordFile(filename string, visitor ObservationVisitor) error {
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
+	rr, err := newRecordReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
 	idMap := NewStringIdMap()
+	// appended records after a restart of an older agent version have an open record without version
+	version := 1
 	for {
-		marker, value, err := readRecord(f)
+		marker, value, err := rr.next()
 		if err != nil {
 			return err
 		}
@@ -447,11 +880,14 @@ func IterateRecordFile(filename string, visitor ObservationVisitor) error {
 			if err != nil {
 				return fmt.Errorf("error on converting observation: %s", err)
 			}
+			if version < 2 {
+				upgradeV1Observation(obs)
+			}
 			if err := visitor(obs); err != nil {
 				return err
 			}
 		case markerOpen:
-			// ignore
+			version = parseSchemaVersion(value)
 		default:
 			return fmt.Errorf("invalid file format")
 		}
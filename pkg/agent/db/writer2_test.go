@@ -0,0 +1,328 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package db
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func testNaming(t *testing.T, template, prefix string) *config.FileNaming {
+	naming, err := config.NewFileNaming(template, prefix, "node1")
+	assert.NoError(t, err)
+	return naming
+}
+
+func TestParseRecordFilename(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		prefix string
+		ok     bool
+	}{
+		{name: "agent-2022-06-01-13.records", prefix: "agent", ok: true},
+		{name: "network-problem-detector-pod-forwarded-2022-06-01-13.records", prefix: "network-problem-detector-pod-forwarded", ok: true},
+		{name: "agent-2022-06-01.records"},
+		{name: "agent-2022-06-01-13.log"},
+		{name: "2022-06-01-13.records"},
+		{name: "agent_2022-06-01-13.records"},
+	} {
+		prefix, ok := parseRecordFilename(tc.name)
+		assert.Equal(t, tc.ok, ok, tc.name)
+		assert.Equal(t, tc.prefix, prefix, tc.name)
+	}
+}
+
+func TestCleanUnknownFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	old := now.Add(-72 * time.Hour)
+	files := map[string]time.Time{
+		// current prefixes
+		"nwpd-host-2022-06-01-13.records":          old,
+		"nwpd-pod-2022-06-01-13.records":           old,
+		"nwpd-pod-forwarded-2022-06-01-13.records": old,
+		"nwpd-pod-2022-06-04-13.records":           now,
+		"nwpd-daily-node1-2022-06-01.records":      old,
+		// old prefixes
+		"agent-2022-06-01-13.records":                        old,
+		"nwpd-host-old-2022-06-01-13.records":                old,
+		"network-problem-detector-pod-2022-06-04-12.records": now,
+		"nwpd-daily-node2-2022-06-01.records":                old,
+		// foreign files
+		"core":      old,
+		"notes.txt": now,
+	}
+	for name, modTime := range files {
+		filename := path.Join(dir, name)
+		assert.NoError(t, os.WriteFile(filename, []byte("x"), 0644))
+		assert.NoError(t, os.Chtimes(filename, modTime, modTime))
+	}
+	assert.NoError(t, os.Mkdir(path.Join(dir, "subdir"), 0755))
+	assert.NoError(t, os.Chtimes(path.Join(dir, "subdir"), old, old))
+	listener, err := net.Listen("unix", path.Join(dir, "admin-nwpd-old.sock"))
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	known := []*config.FileNaming{
+		testNaming(t, "", "nwpd-host"),
+		testNaming(t, "", "nwpd-pod"),
+		testNaming(t, "", "nwpd-pod-forwarded"),
+		testNaming(t, "{prefix}-{node}-{date}.records", "nwpd-daily"),
+	}
+	cleanUnknownFiles(logrus.New(), dir, known, now.Add(-48*time.Hour))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	var remaining []string
+	for _, entry := range entries {
+		remaining = append(remaining, entry.Name())
+	}
+	sort.Strings(remaining)
+	assert.Equal(t, []string{
+		"admin-nwpd-old.sock",
+		"network-problem-detector-pod-2022-06-04-12.records",
+		"notes.txt",
+		"nwpd-daily-node1-2022-06-01.records",
+		"nwpd-host-2022-06-01-13.records",
+		"nwpd-pod-2022-06-01-13.records",
+		"nwpd-pod-2022-06-04-13.records",
+		"nwpd-pod-forwarded-2022-06-01-13.records",
+		"subdir",
+	}, remaining)
+}
+
+func TestWriterDetailLevel(t *testing.T) {
+	timestamp := timestamppb.New(time.Now().Truncate(time.Millisecond))
+	obs := &nwpd.Observation{
+		JobID:        "tcp-n2api-ext",
+		SrcHost:      "node-a",
+		DestHost:     "api.example.com",
+		DestAddress:  "10.0.0.1:443",
+		Protocol:     nwpd.ProtocolTCP,
+		DestIP:       "10.0.0.1",
+		DestPort:     443,
+		Timestamp:    timestamp,
+		Duration:     durationpb.New(10 * time.Second),
+		Period:       durationpb.New(time.Minute),
+		FailureClass: "reset-after-connect",
+		Partial:      true,
+		Result:       "error: reset-after-connect: connection reset by peer",
+		Labels:       map[string]string{"tier": "external"},
+	}
+	written := func(level config.DetailLevel) *nwpd.Observation {
+		dir := t.TempDir()
+		w, err := NewObsWriter(logrus.New(), dir, testNaming(t, "", "test"), 1, nil, nil)
+		assert.NoError(t, err)
+		if level != "" {
+			w.SetDetailLevel(level)
+		}
+		w.write(obs)
+		files, err := GetAnyRecordFiles(dir, false)
+		assert.NoError(t, err)
+		var result []*nwpd.Observation
+		for _, filename := range files {
+			assert.NoError(t, IterateRecordFile(filename, func(obs *nwpd.Observation) error {
+				result = append(result, obs)
+				return nil
+			}))
+		}
+		if assert.Len(t, result, 1, level) {
+			return result[0]
+		}
+		return nil
+	}
+
+	standard := written("")
+	assert.Equal(t, "10.0.0.1", standard.DestIP)
+	assert.Equal(t, "reset-after-connect", standard.FailureClass)
+	assert.Equal(t, 10*time.Second, standard.Duration.AsDuration())
+	assert.Empty(t, standard.Result)
+	assert.Equal(t, standard.String(), written(config.DetailLevelStandard).String())
+
+	full := written(config.DetailLevelFull)
+	assert.Equal(t, obs.Result, full.Result)
+	assert.Equal(t, "10.0.0.1", full.DestIP)
+	assert.Empty(t, full.Labels, "labels are never stored")
+
+	minimal := written(config.DetailLevelMinimal)
+	assert.Equal(t, "api.example.com", minimal.DestHost)
+	assert.Equal(t, timestamp.AsTime(), minimal.Timestamp.AsTime())
+	assert.Equal(t, time.Minute, minimal.Period.AsDuration())
+	assert.True(t, minimal.Partial)
+	assert.False(t, minimal.Ok)
+	assert.Empty(t, minimal.DestAddress)
+	assert.Empty(t, minimal.DestIP)
+	assert.Empty(t, minimal.FailureClass)
+	assert.Nil(t, minimal.Duration)
+	assert.Empty(t, minimal.Result)
+
+	// the observation is shared with the metrics and the aggregation and must not be modified
+	assert.Equal(t, "error: reset-after-connect: connection reset by peer", obs.Result)
+	assert.Equal(t, "10.0.0.1", obs.DestIP)
+}
+
+// failingFile simulates a full filesystem by writing only a part of the data if failing is set.
+type failingFile struct {
+	*os.File
+	failing *bool
+}
+
+func (f *failingFile) Write(data []byte) (int, error) {
+	if *f.failing {
+		n, _ := f.File.Write(data[:len(data)/2])
+		return n, &os.PathError{Op: "write", Path: f.Name(), Err: syscall.ENOSPC}
+	}
+	return f.File.Write(data)
+}
+
+func TestWriterDegradedMode(t *testing.T) {
+	dir := t.TempDir()
+	dropped := 0
+	w, err := NewObsWriter(logrus.New(), dir, testNaming(t, "", "test"), 1, &config.ObservationBufferConfig{DegradedSize: 3}, func() { dropped++ })
+	assert.NoError(t, err)
+	var transitions []bool
+	w.SetDegradedListener(func(degraded bool) { transitions = append(transitions, degraded) })
+	failing := false
+	w.openFile = func(filename string) (recordFile, error) {
+		f, err := openRecordFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		return &failingFile{File: f.(*os.File), failing: &failing}, nil
+	}
+	w.freeDisk = func(_ string) (float64, error) { return 50, nil }
+	newObs := func(i int) *nwpd.Observation {
+		return &nwpd.Observation{
+			JobID:     "tcp-n2api-ext",
+			SrcHost:   "node-a",
+			DestHost:  fmt.Sprintf("host-%d", i),
+			Timestamp: timestamppb.Now(),
+			Ok:        true,
+		}
+	}
+	stored := func() []string {
+		files, err := GetAnyRecordFiles(dir, false)
+		assert.NoError(t, err)
+		var hosts []string
+		for _, filename := range files {
+			assert.NoError(t, IterateRecordFile(filename, func(obs *nwpd.Observation) error {
+				hosts = append(hosts, obs.DestHost)
+				return nil
+			}))
+		}
+		return hosts
+	}
+
+	w.write(newObs(0))
+	failing = true
+	for i := 1; i <= 5; i++ {
+		w.write(newObs(i))
+	}
+	assert.True(t, w.degraded)
+	assert.Equal(t, []bool{false, true}, transitions)
+	assert.Equal(t, 3, w.pending.len())
+	assert.Equal(t, 2, dropped)
+
+	// retry delay not expired yet
+	failing = false
+	w.retryDegraded(false)
+	assert.True(t, w.degraded)
+
+	failing = true
+	w.nextRetry = time.Time{}
+	w.retryDegraded(false)
+	assert.True(t, w.degraded)
+	assert.Equal(t, 2*degradedRetryMin, w.retryBackoff)
+	assert.Equal(t, 3, w.pending.len())
+
+	failing = false
+	w.nextRetry = time.Time{}
+	w.retryDegraded(false)
+	assert.False(t, w.degraded)
+	assert.Equal(t, []bool{false, true, false}, transitions)
+	assert.Equal(t, 0, w.pending.len())
+	w.write(newObs(6))
+
+	// the incomplete records of the failed writes have been removed
+	assert.Equal(t, []string{"host-0", "host-3", "host-4", "host-5", "host-6"}, stored())
+}
+
+func TestWriterFreeDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewObsWriter(logrus.New(), dir, testNaming(t, "", "test"), 1, nil, nil)
+	assert.NoError(t, err)
+	var transitions []bool
+	w.SetDegradedListener(func(degraded bool) { transitions = append(transitions, degraded) })
+	var reported []float64
+	w.SetDiskFreeListener(func(percent float64) { reported = append(reported, percent) })
+	free := 10.0
+	w.freeDisk = func(_ string) (float64, error) { return free, nil }
+	obs := &nwpd.Observation{JobID: "tcp-n2n", SrcHost: "node-a", DestHost: "node-b", Timestamp: timestamppb.Now(), Ok: true}
+
+	assert.False(t, w.checkFreeDisk())
+	free = 1.5
+	assert.True(t, w.checkFreeDisk())
+	assert.True(t, w.degraded)
+	w.write(obs)
+	assert.Equal(t, 1, w.pending.len())
+
+	// the free space must exceed the minimum by the hysteresis
+	free = 2.5
+	w.nextRetry = time.Time{}
+	w.retryDegraded(false)
+	assert.True(t, w.degraded)
+	assert.Equal(t, 1, w.pending.len())
+
+	free = 3.5
+	w.nextRetry = time.Time{}
+	w.retryDegraded(false)
+	assert.False(t, w.degraded)
+	assert.Equal(t, 0, w.pending.len())
+	assert.Equal(t, []bool{false, true, false}, transitions)
+	assert.Equal(t, []float64{10, 1.5, 2.5, 3.5}, reported)
+
+	// disabled minimum
+	w.minFreeDiskPercent = 0
+	free = 0.5
+	assert.False(t, w.checkFreeDisk())
+}
+
+func TestWriterStopWithoutFile(t *testing.T) {
+	w, err := NewObsWriter(logrus.New(), t.TempDir(), testNaming(t, "", "test"), 1, nil, nil)
+	assert.NoError(t, err)
+	go w.Run()
+	w.Stop()
+}
+
+func TestGetRecordFilesWithTemplate(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2022, 6, 1, 22, 30, 0, 0, time.UTC)
+	daily := testNaming(t, "{prefix}-{node}-{date}.records", "nwpd")
+	for _, name := range []string{"nwpd-node1-2022-06-01.records", "nwpd-node1-2022-06-02.records", "nwpd-2022-06-01-23.records"} {
+		assert.NoError(t, os.WriteFile(path.Join(dir, name), nil, 0644))
+	}
+
+	files, err := GetRecordFiles(dir, daily, start, start.Add(3*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{path.Join(dir, "nwpd-node1-2022-06-01.records"), path.Join(dir, "nwpd-node1-2022-06-02.records")}, files)
+
+	files, err = GetRecordFiles(dir, testNaming(t, "", "nwpd"), start, start.Add(3*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{path.Join(dir, "nwpd-2022-06-01-23.records")}, files)
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// echoConnTimeout is the maximum lifetime of a connection to the echo handler
+	echoConnTimeout = 10 * time.Second
+	// echoMaxBytes is the maximum number of bytes echoed per connection
+	echoMaxBytes = 4096
+)
+
+// listenEcho binds the echo port if configured. The connections are served when the agent runs.
+func (s *server) listenEcho() error {
+	port := s.getNetworkCfg().EchoPort
+	if port == 0 {
+		return nil
+	}
+	listener, err := listen(listenerEcho, "tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	s.echoListener = listener
+	s.log.Infof("provide echo handler at ':%d'", port)
+	return nil
+}
+
+// serveEcho accepts connections on the listener and echoes the received data, so that other agents can verify
+// with 'checkTCPPort --echo' that data passes established connections. It returns when the listener is closed.
+func serveEcho(log logrus.FieldLogger, listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Warnf("echo handler stopped: %s", err)
+			}
+			return
+		}
+		go handleEcho(conn)
+	}
+}
+
+// handleEcho echoes the data of the connection until it is closed by the peer, the connection timeout has passed
+// or the maximum number of bytes has been echoed.
+func handleEcho(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(echoConnTimeout))
+	_, _ = io.Copy(conn, io.LimitReader(conn, echoMaxBytes))
+}
@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// defaultEdgesLimit is the page size of the edges endpoint if no limit is requested
+	defaultEdgesLimit = 1000
+	// maxEdgesLimit bounds the page size of the edges endpoint to avoid huge responses on large clusters
+	maxEdgesLimit = 10000
+)
+
+// edgesHandler serves the current state of the aggregated edges as JSON.
+// The query parameters `offset` and `limit` are used for pagination.
+func (s *server) edgesHandler(w http.ResponseWriter, req *http.Request) {
+	if s.aggregator == nil {
+		http.Error(w, "aggregation not available", http.StatusServiceUnavailable)
+		return
+	}
+	offset, err := intQueryParam(req, "offset", 0)
+	if err != nil || offset < 0 {
+		http.Error(w, fmt.Sprintf("invalid offset: %s", req.URL.Query().Get("offset")), http.StatusBadRequest)
+		return
+	}
+	limit, err := intQueryParam(req, "limit", defaultEdgesLimit)
+	if err != nil || limit < 1 || limit > maxEdgesLimit {
+		http.Error(w, fmt.Sprintf("invalid limit (must be in range 1-%d): %s", maxEdgesLimit, req.URL.Query().Get("limit")), http.StatusBadRequest)
+		return
+	}
+
+	list := s.aggregator.ListEdges(offset, limit)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		s.log.Warnf("writing edges response failed: %s", err)
+	}
+}
+
+func intQueryParam(req *http.Request, name string, defaultValue int) (int, error) {
+	value := req.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
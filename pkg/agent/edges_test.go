@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/aggregation"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestEdgesHandler(t *testing.T) {
+	aggr, err := aggregation.NewObsAggregator(&aggregation.ObsAggregationOptions{
+		Log:          logrus.New(),
+		ReportPeriod: 1 * time.Hour,
+		TimeWindow:   1 * time.Hour,
+	})
+	assert.NoError(t, err)
+	srv := &server{log: logrus.New(), aggregator: aggr}
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		aggr.Add(&nwpd.Observation{
+			JobID:     "tcp-n2n",
+			SrcHost:   "node0",
+			DestHost:  fmt.Sprintf("node%d", i),
+			Timestamp: timestamppb.New(now),
+			Duration:  durationpb.New(1 * time.Millisecond),
+			Period:    durationpb.New(10 * time.Second),
+			Ok:        i != 3,
+		})
+	}
+	// some connections of the check failed
+	aggr.Add(&nwpd.Observation{
+		JobID:     "tcp-n2n",
+		SrcHost:   "node0",
+		DestHost:  "node4",
+		Timestamp: timestamppb.New(now.Add(1 * time.Second)),
+		Period:    durationpb.New(10 * time.Second),
+		Partial:   true,
+	})
+	// failure during a maintenance window
+	aggr.Add(&nwpd.Observation{
+		JobID:       "tcp-n2n",
+		SrcHost:     "node0",
+		DestHost:    "node2",
+		Timestamp:   timestamppb.New(now.Add(1 * time.Second)),
+		Period:      durationpb.New(10 * time.Second),
+		Maintenance: true,
+	})
+
+	get := func(query string) (int, *aggregation.EdgeStatusList) {
+		rec := httptest.NewRecorder()
+		srv.edgesHandler(rec, httptest.NewRequest(http.MethodGet, "/edges.json"+query, nil))
+		if rec.Code != http.StatusOK {
+			return rec.Code, nil
+		}
+		list := &aggregation.EdgeStatusList{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), list))
+		return rec.Code, list
+	}
+
+	code, list := get("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 5, list.Total)
+	assert.Len(t, list.Items, 5)
+	assert.False(t, list.Items[3].LastOk)
+	assert.NotNil(t, list.Items[3].LastFailure)
+	assert.Nil(t, list.Items[3].LastSuccess)
+	assert.Zero(t, list.Items[3].PartialCount)
+	assert.Equal(t, 1, list.Items[4].PartialCount)
+	assert.NotNil(t, list.Items[4].LastPartial)
+	assert.Equal(t, 1, list.Items[2].MaintenanceCount)
+	assert.True(t, list.Items[2].InMaintenance)
+	assert.False(t, list.Items[4].InMaintenance)
+
+	code, list = get("?offset=2&limit=2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 5, list.Total)
+	if assert.Len(t, list.Items, 2) {
+		assert.Equal(t, "node2", list.Items[0].DestHost)
+		assert.Equal(t, "node3", list.Items[1].DestHost)
+	}
+
+	code, list = get("?offset=10")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, list.Items, 0)
+
+	code, _ = get("?limit=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = get(fmt.Sprintf("?limit=%d", maxEdgesLimit+1))
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestLastSuccessCollector(t *testing.T) {
+	aggr, err := aggregation.NewObsAggregator(&aggregation.ObsAggregationOptions{
+		Log:          logrus.New(),
+		ReportPeriod: 1 * time.Hour,
+		TimeWindow:   1 * time.Hour,
+	})
+	assert.NoError(t, err)
+
+	now := time.Now()
+	for i, ok := range []bool{true, false, false} {
+		aggr.Add(&nwpd.Observation{
+			JobID:     "tcp-n2n",
+			SrcHost:   "node0",
+			DestHost:  "node1",
+			Timestamp: timestamppb.New(now.Add(time.Duration(i-2) * time.Minute)),
+			Period:    durationpb.New(1 * time.Minute),
+			Ok:        ok,
+		})
+	}
+
+	collector := &lastSuccessCollector{
+		maxDesc:     SecondsSinceLastSuccess.maxDesc,
+		perDestDesc: SecondsSinceLastSuccess.perDestDesc,
+	}
+	collector.setAggregator(aggr)
+	ch := make(chan prometheus.Metric, 10)
+	collector.Collect(ch)
+	close(ch)
+	count := 0
+	for m := range ch {
+		count++
+		pb := &dto.Metric{}
+		assert.NoError(t, m.Write(pb))
+		assert.InDelta(t, 120, pb.GetGauge().GetValue(), 5)
+	}
+	assert.Equal(t, 2, count)
+}
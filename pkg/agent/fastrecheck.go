@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+const (
+	fastRecheckOk      = "ok"
+	fastRecheckFailed  = "failed"
+	fastRecheckSkipped = "skipped"
+)
+
+// recheckState is the state of the fast re-checks of a failing edge.
+type recheckState struct {
+	// triggeredBy is the ID of the failing observation which started the re-checks. It is referenced by the re-checks.
+	triggeredBy string
+	remaining   int
+	pending     bool
+	// confirmedDown is set if all re-checks have failed. The edge is not re-checked again until it is ok.
+	confirmedDown bool
+}
+
+// fastRechecker re-checks failing edges of jobs with fast re-checks before their next period.
+// The number of concurrently running re-checks of all jobs is bounded, re-checks exceeding it are skipped.
+type fastRechecker struct {
+	lock  sync.Mutex
+	edges map[edgeKey]*recheckState
+	slots chan struct{}
+	// schedule runs the function after the delay
+	schedule func(delay time.Duration, f func())
+	// run runs the check of the job for the destination host and sends the observation to the observation channel.
+	// It returns false if the job or the destination host is unknown.
+	run func(jobID, destHost, triggeredBy string) bool
+}
+
+func newFastRechecker(concurrency int, run func(jobID, destHost, triggeredBy string) bool) *fastRechecker {
+	return &fastRechecker{
+		edges: map[edgeKey]*recheckState{},
+		slots: make(chan struct{}, concurrency),
+		schedule: func(delay time.Duration, f func()) {
+			time.AfterFunc(delay, f)
+		},
+		run: run,
+	}
+}
+
+// setConcurrency changes the maximum number of concurrently running re-checks. Running re-checks are not counted.
+func (r *fastRechecker) setConcurrency(concurrency int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if cap(r.slots) != concurrency {
+		r.slots = make(chan struct{}, concurrency)
+	}
+}
+
+// observe updates the re-check state of the edge of the observation and schedules the next re-check of a failing edge.
+// The re-checks stop on the first successful observation of the edge. If all re-checks fail, the edge is confirmed down and
+// further failures are not re-checked to avoid additional load during outages.
+func (r *fastRechecker) observe(obs *nwpd.Observation, cfg *config.FastRecheck) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := edgeKey{jobID: obs.JobID, srcHost: obs.SrcHost, destHost: obs.DestHost}
+	state := r.edges[key]
+	isRecheck := state != nil && state.pending && obs.TriggeredBy == state.triggeredBy
+	if isRecheck {
+		state.pending = false
+		if obs.Ok {
+			ReportFastRecheck(obs.JobID, fastRecheckOk)
+		} else {
+			ReportFastRecheck(obs.JobID, fastRecheckFailed)
+		}
+	}
+	if cfg == nil || obs.Ok || obs.WarmingUp {
+		// failures within the warm-up period of the destination are expected and not re-checked
+		delete(r.edges, key)
+		return
+	}
+	switch {
+	case state == nil:
+		state = &recheckState{triggeredBy: obs.ID(), remaining: cfg.Count}
+		r.edges[key] = state
+	case !isRecheck:
+		// re-checks are running or the edge is confirmed down
+		return
+	case state.remaining == 0:
+		state.confirmedDown = true
+		return
+	}
+	state.remaining--
+	state.pending = true
+	r.schedule(cfg.IntervalOrDefault(), func() { r.recheck(key, state) })
+}
+
+// recheck runs the re-check if a slot is free and the state of the edge has not been reset meanwhile.
+func (r *fastRechecker) recheck(key edgeKey, state *recheckState) {
+	r.lock.Lock()
+	if r.edges[key] != state {
+		r.lock.Unlock()
+		return
+	}
+	slots := r.slots
+	select {
+	case slots <- struct{}{}:
+	default:
+		delete(r.edges, key)
+		r.lock.Unlock()
+		ReportFastRecheck(key.jobID, fastRecheckSkipped)
+		return
+	}
+	r.lock.Unlock()
+
+	ok := r.run(key.jobID, key.destHost, state.triggeredBy)
+	<-slots
+	if !ok {
+		r.lock.Lock()
+		if r.edges[key] == state {
+			delete(r.edges, key)
+		}
+		r.lock.Unlock()
+	}
+}
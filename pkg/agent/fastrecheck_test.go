@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+type recheckCall struct {
+	destHost    string
+	triggeredBy string
+}
+
+// newTestRechecker creates a fast rechecker with manually run schedules and recorded re-checks.
+func newTestRechecker(concurrency int) (*fastRechecker, *[]func(), *[]recheckCall) {
+	var scheduled []func()
+	var calls []recheckCall
+	r := newFastRechecker(concurrency, func(_, destHost, triggeredBy string) bool {
+		calls = append(calls, recheckCall{destHost: destHost, triggeredBy: triggeredBy})
+		return true
+	})
+	r.schedule = func(_ time.Duration, f func()) {
+		scheduled = append(scheduled, f)
+	}
+	return r, &scheduled, &calls
+}
+
+func TestFastRechecker(t *testing.T) {
+	start := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	newObs := func(seconds int, ok bool, triggeredBy string) *nwpd.Observation {
+		return &nwpd.Observation{
+			JobID:       "tcp-n2api-ext",
+			SrcHost:     "node1",
+			DestHost:    "api",
+			Timestamp:   timestamppb.New(start.Add(time.Duration(seconds) * time.Second)),
+			Ok:          ok,
+			TriggeredBy: triggeredBy,
+		}
+	}
+	cfg := &config.FastRecheck{Count: 2}
+	runScheduled := func(scheduled *[]func()) {
+		fs := *scheduled
+		*scheduled = nil
+		for _, f := range fs {
+			f()
+		}
+	}
+
+	r, scheduled, calls := newTestRechecker(1)
+	r.observe(newObs(0, true, ""), cfg)
+	assert.Empty(t, *scheduled)
+
+	// a failure is re-checked until the re-checks are exhausted
+	failed := newObs(60, false, "")
+	r.observe(failed, cfg)
+	runScheduled(scheduled)
+	assert.Equal(t, []recheckCall{{destHost: "api", triggeredBy: failed.ID()}}, *calls)
+	r.observe(newObs(62, false, failed.ID()), cfg)
+	runScheduled(scheduled)
+	assert.Len(t, *calls, 2)
+	r.observe(newObs(64, false, failed.ID()), cfg)
+	assert.Empty(t, *scheduled)
+
+	// confirmed down: further failures are not re-checked
+	r.observe(newObs(120, false, ""), cfg)
+	assert.Empty(t, *scheduled)
+
+	// after recovery, a new failure is re-checked again and a successful re-check stops the re-checks
+	r.observe(newObs(180, true, ""), cfg)
+	failed = newObs(240, false, "")
+	r.observe(failed, cfg)
+	runScheduled(scheduled)
+	assert.Len(t, *calls, 3)
+	r.observe(newObs(242, true, failed.ID()), cfg)
+	assert.Empty(t, *scheduled)
+	assert.Empty(t, r.edges)
+
+	// jobs without fast re-checks are not re-checked
+	r.observe(newObs(300, false, ""), nil)
+	assert.Empty(t, *scheduled)
+}
+
+func TestFastRecheckerConcurrency(t *testing.T) {
+	r, scheduled, calls := newTestRechecker(1)
+	blocked := make(chan struct{})
+	r.run = func(_, destHost, triggeredBy string) bool {
+		<-blocked
+		*calls = append(*calls, recheckCall{destHost: destHost, triggeredBy: triggeredBy})
+		return true
+	}
+	cfg := &config.FastRecheck{Count: 3}
+	for _, dest := range []string{"node2", "node3"} {
+		r.observe(&nwpd.Observation{JobID: "tcp-n2n", SrcHost: "node1", DestHost: dest, Timestamp: timestamppb.Now()}, cfg)
+	}
+	assert.Len(t, *scheduled, 2)
+
+	done := make(chan struct{})
+	go func() {
+		(*scheduled)[0]()
+		close(done)
+	}()
+	// the pool is exhausted by the running re-check, so that the second one is skipped
+	assert.Eventually(t, func() bool { return len(r.slots) == 1 }, time.Second, 10*time.Millisecond)
+	(*scheduled)[1]()
+	close(blocked)
+	<-done
+	if assert.Len(t, *calls, 1) {
+		assert.Equal(t, "node2", (*calls)[0].destHost)
+	}
+	// the state of the skipped edge is removed, so that its next failure is re-checked again
+	assert.Len(t, r.edges, 1)
+}
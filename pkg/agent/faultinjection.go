@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+const (
+	// envFaultInjection enables the fault injection like the hidden option --fault-injection if set to 'true'.
+	envFaultInjection = "NWPD_FAULT_INJECTION"
+	// envFaultPolicyFile sets the fault policy file like the hidden option --fault-policy-file.
+	envFaultPolicyFile = "NWPD_FAULT_POLICY_FILE"
+)
+
+// faultPolicyWatch reloads the fault policy file if it has been modified.
+type faultPolicyWatch struct {
+	filename string
+	modTime  time.Time
+}
+
+// enableFaultInjection enables the fault injection of the runners and loads the fault policy file if given.
+func (s *server) enableFaultInjection(policyFile string) error {
+	runners.EnableFaultInjection(ReportInjectedFault)
+	runners.SetFaultZones(s.currentClusterConfig)
+	if policyFile == "" {
+		return nil
+	}
+	s.faultPolicy = &faultPolicyWatch{filename: policyFile}
+	return s.reloadFaultPolicy()
+}
+
+// reloadFaultPolicy loads the fault policy file if its modification time has changed.
+// A missing file removes all faults.
+func (s *server) reloadFaultPolicy() error {
+	if s.faultPolicy == nil {
+		return nil
+	}
+	var modTime time.Time
+	info, err := os.Stat(s.faultPolicy.filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if info != nil {
+		modTime = info.ModTime()
+	}
+	if modTime.Equal(s.faultPolicy.modTime) {
+		return nil
+	}
+	var policy *runners.FaultPolicy
+	if info != nil {
+		data, err := os.ReadFile(s.faultPolicy.filename)
+		if err != nil {
+			return err
+		}
+		if policy, err = runners.ParseFaultPolicy(data); err != nil {
+			return err
+		}
+	}
+	if err := runners.SetFaultPolicy(policy); err != nil {
+		return err
+	}
+	s.faultPolicy.modTime = modTime
+	s.log.Warnf("fault policy loaded from %s: %d faults", s.faultPolicy.filename, faultCount(policy))
+	return nil
+}
+
+func faultCount(policy *runners.FaultPolicy) int {
+	if policy == nil {
+		return 0
+	}
+	return len(policy.Faults)
+}
+
+// SetFaultPolicy replaces the injected faults. It is only available if the fault injection is enabled.
+func (s *server) SetFaultPolicy(_ context.Context, request *nwpd.SetFaultPolicyRequest) (*nwpd.SetFaultPolicyResponse, error) {
+	if !runners.FaultInjectionEnabled() {
+		return nil, status.Errorf(codes.FailedPrecondition, "fault injection is not enabled")
+	}
+	var policy *runners.FaultPolicy
+	if request.Policy != "" {
+		var err error
+		if policy, err = runners.ParseFaultPolicy([]byte(request.Policy)); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%s", err)
+		}
+	}
+	if err := runners.SetFaultPolicy(policy); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "%s", err)
+	}
+	s.log.Warnf("fault policy set: %d faults", faultCount(policy))
+	return &nwpd.SetFaultPolicyResponse{Faults: int32(faultCount(policy))}, nil
+}
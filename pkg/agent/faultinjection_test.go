@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestSetFaultPolicy(t *testing.T) {
+	s := &server{log: logrus.New()}
+	ctx := context.Background()
+	request := &nwpd.SetFaultPolicyRequest{Policy: "faults:\n- jobID: tcp-n2n\n  fail: true"}
+
+	_, err := s.SetFaultPolicy(ctx, request)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "not enabled")
+
+	assert.NoError(t, s.enableFaultInjection(""))
+	defer runners.DisableFaultInjection()
+	resp, err := s.SetFaultPolicy(ctx, request)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int32(1), resp.Faults)
+	}
+	_, err = s.SetFaultPolicy(ctx, &nwpd.SetFaultPolicyRequest{Policy: "faults:\n- jobID: tcp-n2n"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	resp, err = s.SetFaultPolicy(ctx, &nwpd.SetFaultPolicyRequest{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int32(0), resp.Faults)
+	}
+}
+
+func TestReloadFaultPolicy(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "faults.yaml")
+	assert.NoError(t, os.WriteFile(filename, []byte("faults:\n- fail: true\n- delay: 1s"), 0o644))
+	s := &server{log: logrus.New()}
+	assert.NoError(t, s.enableFaultInjection(filename))
+	defer runners.DisableFaultInjection()
+	modTime := s.faultPolicy.modTime
+	assert.False(t, modTime.IsZero())
+
+	// unchanged file is not reloaded
+	assert.NoError(t, s.reloadFaultPolicy())
+	assert.Equal(t, modTime, s.faultPolicy.modTime)
+
+	assert.NoError(t, os.WriteFile(filename, []byte("faults:\n- failRate: 2"), 0o644))
+	assert.NoError(t, os.Chtimes(filename, modTime.Add(time.Second), modTime.Add(time.Second)))
+	assert.Error(t, s.reloadFaultPolicy(), "invalid policy")
+	assert.Equal(t, modTime, s.faultPolicy.modTime)
+
+	assert.NoError(t, os.Remove(filename))
+	assert.NoError(t, s.reloadFaultPolicy(), "missing file removes faults")
+	assert.True(t, s.faultPolicy.modTime.IsZero())
+}
@@ -0,0 +1,240 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+const (
+	// forwardBufferSize is the maximum number of unacknowledged observations. If the buffer is full, the oldest ones are dropped.
+	forwardBufferSize = 10000
+	// forwardBatchSize is the maximum number of observations per request
+	forwardBatchSize = 500
+	// forwardPeriod is the period for sending the buffered observations
+	forwardPeriod = 1 * time.Second
+	// forwardMaxBackoff is the maximum wait time before retrying after a failed request
+	forwardMaxBackoff = 30 * time.Second
+	// forwardTimeout is the timeout of a single request
+	forwardTimeout = 10 * time.Second
+	// forwardStopTimeout is the maximum time for sending the remaining observations on stop
+	forwardStopTimeout = 5 * time.Second
+)
+
+// obsForwarder sends the observations of the agent in the pod network to the agent in the host network on the same node.
+// The observations are numbered and kept in a buffer until they are acknowledged, so that they survive restarts of the
+// receiving agent. On reconnect, the receiver skips observations it has already persisted by their sequence numbers.
+type obsForwarder struct {
+	log       logrus.FieldLogger
+	conn      *grpc.ClientConn
+	client    nwpd.AgentServiceClient
+	sender    string
+	instance  int64
+	maxBuffer int
+
+	lock     sync.Mutex
+	buffer   []*nwpd.Observation
+	firstSeq uint64 // sequence number of buffer[0]
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+var _ nwpd.ObservationListener = &obsForwarder{}
+
+func newObsForwarder(log logrus.FieldLogger, address, sender string) (*obsForwarder, error) {
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	f := newObsForwarderWithClient(log, nwpd.NewAgentServiceClient(conn), sender)
+	f.conn = conn
+	return f, nil
+}
+
+func newObsForwarderWithClient(log logrus.FieldLogger, client nwpd.AgentServiceClient, sender string) *obsForwarder {
+	return &obsForwarder{
+		log:       log,
+		client:    client,
+		sender:    sender,
+		instance:  time.Now().UnixNano(),
+		maxBuffer: forwardBufferSize,
+		firstSeq:  1,
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+}
+
+// Add buffers the observation for sending.
+func (f *obsForwarder) Add(obs *nwpd.Observation) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.buffer = append(f.buffer, obs)
+	if drop := len(f.buffer) - f.maxBuffer; drop > 0 {
+		f.buffer = f.buffer[drop:]
+		f.firstSeq += uint64(drop)
+		ReportForwardedObservations(forwardResultDropped, drop)
+	}
+}
+
+// Run sends the buffered observations periodically until Stop is called. Failed requests are retried with exponential backoff.
+func (f *obsForwarder) Run() {
+	defer close(f.stopped)
+	wait := forwardPeriod
+	backoff := forwardPeriod
+	failing := false
+	for {
+		select {
+		case <-f.done:
+			f.flush()
+			return
+		case <-time.After(wait):
+		}
+		wait = forwardPeriod
+		for {
+			n, err := f.send(context.Background())
+			if err != nil {
+				if !failing {
+					f.log.Warnf("forwarding observations failed, retrying: %s", err)
+					failing = true
+				}
+				wait = backoff
+				backoff *= 2
+				if backoff > forwardMaxBackoff {
+					backoff = forwardMaxBackoff
+				}
+				break
+			}
+			if failing {
+				f.log.Infof("forwarding observations resumed")
+				failing = false
+			}
+			backoff = forwardPeriod
+			if n < forwardBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// Stop stops sending after a last attempt to send the buffered observations.
+func (f *obsForwarder) Stop() {
+	close(f.done)
+	<-f.stopped
+	if f.conn != nil {
+		_ = f.conn.Close()
+	}
+}
+
+func (f *obsForwarder) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), forwardStopTimeout)
+	defer cancel()
+	for {
+		n, err := f.send(ctx)
+		if err != nil {
+			f.log.Warnf("forwarding observations on stop failed, %d observations lost: %s", f.pending(), err)
+			return
+		}
+		if n == 0 {
+			return
+		}
+	}
+}
+
+// send sends the next batch of buffered observations and removes the acknowledged ones from the buffer.
+// It returns the number of sent observations.
+func (f *obsForwarder) send(ctx context.Context) (int, error) {
+	f.lock.Lock()
+	n := len(f.buffer)
+	if n > forwardBatchSize {
+		n = forwardBatchSize
+	}
+	req := &nwpd.ForwardObservationsRequest{
+		Sender:        f.sender,
+		Instance:      f.instance,
+		FirstSequence: f.firstSeq,
+		Observations:  append([]*nwpd.Observation(nil), f.buffer[:n]...),
+	}
+	f.lock.Unlock()
+	if n == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, forwardTimeout)
+	defer cancel()
+	resp, err := f.client.ForwardObservations(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	f.acknowledge(resp.LastSequence)
+	ReportForwardedObservations(forwardResultOK, n)
+	return n, nil
+}
+
+// acknowledge removes the observations up to the given sequence number from the buffer.
+func (f *obsForwarder) acknowledge(lastSeq uint64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if lastSeq < f.firstSeq {
+		return
+	}
+	n := lastSeq - f.firstSeq + 1
+	if n > uint64(len(f.buffer)) {
+		n = uint64(len(f.buffer))
+	}
+	f.buffer = f.buffer[n:]
+	f.firstSeq += n
+}
+
+func (f *obsForwarder) pending() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return len(f.buffer)
+}
+
+// sequenceTracker remembers the last persisted sequence number per forwarding agent to skip duplicates on reconnect.
+type sequenceTracker struct {
+	lock    sync.Mutex
+	senders map[string]senderSequence
+}
+
+type senderSequence struct {
+	instance int64
+	last     uint64
+}
+
+func newSequenceTracker() *sequenceTracker {
+	return &sequenceTracker{senders: map[string]senderSequence{}}
+}
+
+// accept returns the number of leading observations of a request to skip as already persisted, the new last sequence
+// number and the number of observations missing since the last request (dropped by the sender).
+func (t *sequenceTracker) accept(sender string, instance int64, firstSeq uint64, count int) (skip int, last, missing uint64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	state, ok := t.senders[sender]
+	if !ok || state.instance != instance {
+		state = senderSequence{instance: instance, last: firstSeq - 1}
+	}
+	if firstSeq > state.last+1 {
+		missing = firstSeq - state.last - 1
+	} else if d := state.last + 1 - firstSeq; d > uint64(count) {
+		skip = count
+	} else {
+		skip = int(d)
+	}
+	if end := firstSeq + uint64(count) - 1; count > 0 && end > state.last {
+		state.last = end
+	}
+	t.senders[sender] = state
+	return skip, state.last, missing
+}
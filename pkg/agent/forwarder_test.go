@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// fakeReceiver simulates the host network agent.
+type fakeReceiver struct {
+	nwpd.AgentServiceClient
+	sequences *sequenceTracker
+	persisted []string
+	// fail makes the next request fail before persisting
+	fail bool
+	// loseAck makes the next request fail after persisting
+	loseAck bool
+}
+
+func (r *fakeReceiver) ForwardObservations(_ context.Context, in *nwpd.ForwardObservationsRequest, _ ...grpc.CallOption) (*nwpd.ForwardObservationsResponse, error) {
+	if r.fail {
+		r.fail = false
+		return nil, fmt.Errorf("connection refused")
+	}
+	skip, last, _ := r.sequences.accept(in.Sender, in.Instance, in.FirstSequence, len(in.Observations))
+	for _, obs := range in.Observations[skip:] {
+		r.persisted = append(r.persisted, obs.DestHost)
+	}
+	if r.loseAck {
+		r.loseAck = false
+		return nil, fmt.Errorf("connection reset")
+	}
+	return &nwpd.ForwardObservationsResponse{LastSequence: last}, nil
+}
+
+func observationsTo(dests ...string) []*nwpd.Observation {
+	var result []*nwpd.Observation
+	for _, dest := range dests {
+		result = append(result, &nwpd.Observation{JobID: "tcp-p2p", SrcHost: "node1", DestHost: dest})
+	}
+	return result
+}
+
+func TestObsForwarder(t *testing.T) {
+	receiver := &fakeReceiver{sequences: newSequenceTracker()}
+	f := newObsForwarderWithClient(logrus.New(), receiver, "pod1")
+	ctx := context.Background()
+
+	for _, obs := range observationsTo("a", "b") {
+		f.Add(obs)
+	}
+	receiver.fail = true
+	_, err := f.send(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 2, f.pending(), "kept for retry")
+
+	n, err := f.send(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 0, f.pending())
+
+	// acknowledgement lost, the resent observations are not persisted again
+	f.Add(observationsTo("c")[0])
+	receiver.loseAck = true
+	_, err = f.send(ctx)
+	assert.Error(t, err)
+	f.Add(observationsTo("d")[0])
+	_, err = f.send(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, receiver.persisted)
+
+	// restart of the receiver
+	receiver.sequences = newSequenceTracker()
+	f.Add(observationsTo("e")[0])
+	_, err = f.send(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, receiver.persisted)
+
+	// restart of the sender
+	f = newObsForwarderWithClient(logrus.New(), receiver, "pod1")
+	f.Add(observationsTo("f")[0])
+	_, err = f.send(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e", "f"}, receiver.persisted)
+}
+
+func TestObsForwarderBufferLimit(t *testing.T) {
+	receiver := &fakeReceiver{sequences: newSequenceTracker()}
+	f := newObsForwarderWithClient(logrus.New(), receiver, "pod1")
+	f.maxBuffer = 3
+	for _, obs := range observationsTo("a", "b", "c", "d", "e") {
+		f.Add(obs)
+	}
+	assert.Equal(t, 3, f.pending())
+	_, err := f.send(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "d", "e"}, receiver.persisted)
+}
+
+func TestSequenceTracker(t *testing.T) {
+	tracker := newSequenceTracker()
+	for _, step := range []struct {
+		sender   string
+		instance int64
+		first    uint64
+		count    int
+
+		skip    int
+		last    uint64
+		missing uint64
+	}{
+		{sender: "pod1", instance: 1, first: 1, count: 5, skip: 0, last: 5},
+		{sender: "pod1", instance: 1, first: 3, count: 5, skip: 3, last: 7},
+		{sender: "pod1", instance: 1, first: 1, count: 2, skip: 2, last: 7},
+		{sender: "pod1", instance: 1, first: 10, count: 1, skip: 0, last: 10, missing: 2},
+		{sender: "pod2", instance: 1, first: 4, count: 1, skip: 0, last: 4},
+		{sender: "pod1", instance: 2, first: 1, count: 1, skip: 0, last: 1},
+	} {
+		skip, last, missing := tracker.accept(step.sender, step.instance, step.first, step.count)
+		assert.Equal(t, step.skip, skip, "%+v", step)
+		assert.Equal(t, step.last, last, "%+v", step)
+		assert.Equal(t, step.missing, missing, "%+v", step)
+	}
+}
@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/cgroup"
+)
+
+const (
+	// runtimeSettingSourceEnv means the setting is given by an environment variable of the Go runtime.
+	runtimeSettingSourceEnv = "env"
+	// runtimeSettingSourceFlag means the setting is given by an option of the agent.
+	runtimeSettingSourceFlag = "flag"
+	// runtimeSettingSourceCgroup means the setting is derived from the limits of the container.
+	runtimeSettingSourceCgroup = "cgroup"
+	// runtimeSettingSourceDefault means the default of the Go runtime is used.
+	runtimeSettingSourceDefault = "default"
+	// defaultGCPercent is the GOGC value of the Go runtime if not set by the environment variable.
+	defaultGCPercent = 100
+)
+
+// applyGOMAXPROCS sets the number of OS threads executing Go code simultaneously and returns it with its source.
+// A positive value is used as is, 0 derives it from the CPU limit of the container (rounded up), a negative value keeps
+// the default of the Go runtime. The environment variable GOMAXPROCS always takes precedence.
+func applyGOMAXPROCS(reader *cgroup.Reader, value int, fromEnv bool) (int, string) {
+	switch {
+	case fromEnv:
+		return runtime.GOMAXPROCS(0), runtimeSettingSourceEnv
+	case value > 0:
+		runtime.GOMAXPROCS(value)
+		return value, runtimeSettingSourceFlag
+	case value == 0 && reader != nil:
+		if limit, err := reader.CPULimit(); err == nil && limit > 0 {
+			procs := int(math.Ceil(limit))
+			if procs > runtime.NumCPU() {
+				procs = runtime.NumCPU()
+			}
+			runtime.GOMAXPROCS(procs)
+			return procs, runtimeSettingSourceCgroup
+		}
+	}
+	return runtime.GOMAXPROCS(0), runtimeSettingSourceDefault
+}
+
+// applyGCPercent sets the garbage collection target percentage (GOGC) and returns it with its source.
+// A value of 0 keeps the default of the Go runtime. The environment variable GOGC always takes precedence.
+func applyGCPercent(value int, fromEnv bool) (int, string) {
+	switch {
+	case fromEnv:
+		percent := debug.SetGCPercent(defaultGCPercent)
+		debug.SetGCPercent(percent)
+		return percent, runtimeSettingSourceEnv
+	case value > 0:
+		debug.SetGCPercent(value)
+		return value, runtimeSettingSourceFlag
+	}
+	return defaultGCPercent, runtimeSettingSourceDefault
+}
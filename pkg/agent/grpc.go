@@ -0,0 +1,148 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// clusterMethods are the GRPC methods served by the cluster listener if the admin listener is enabled.
+// They are called by the pod network agent on the same node (forwarding) and by the polling controller
+// (including relayed checks, which are restricted to the configured jobs and destinations).
+var clusterMethods = map[string]bool{
+	"/" + nwpd.AgentService_ServiceDesc.ServiceName + "/ForwardObservations":       true,
+	"/" + nwpd.AgentService_ServiceDesc.ServiceName + "/GetAggregatedObservations": true,
+	"/" + nwpd.AgentService_ServiceDesc.ServiceName + "/RelayCheck":                true,
+	"/" + nwpd.AgentService_ServiceDesc.ServiceName + "/RelayChecks":               true,
+}
+
+// clusterMethodsInterceptor rejects all methods not needed by other agents or the controller.
+func clusterMethodsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !clusterMethods[info.FullMethod] {
+		return nil, status.Errorf(codes.PermissionDenied, "method %s is only served by the admin listener", info.FullMethod)
+	}
+	return handler(ctx, req)
+}
+
+// clusterMethodsStreamInterceptor rejects all streaming methods not needed by other agents or the controller.
+func clusterMethodsStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !clusterMethods[info.FullMethod] {
+		return status.Errorf(codes.PermissionDenied, "method %s is only served by the admin listener", info.FullMethod)
+	}
+	return handler(srv, stream)
+}
+
+// grpcListener is a listener of the GRPC server with the methods it serves.
+type grpcListener struct {
+	listener net.Listener
+	// clusterOnly restricts the methods to the cluster methods
+	clusterOnly bool
+}
+
+// createGRPCListeners creates the listeners of the GRPC server. Without admin listener, a single listener on all
+// interfaces serves all methods. Otherwise the cluster listener is bound to the given IP address of the pod.
+func createGRPCListeners(cfg *config.NetworkConfig, outputDir, podIP string) ([]grpcListener, error) {
+	if !cfg.HasAdminListener() {
+		listener, err := listen(listenerGRPC, "tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+		if err != nil {
+			return nil, err
+		}
+		return []grpcListener{{listener: listener}}, nil
+	}
+
+	if cfg.AdminGRPCPort != 0 && cfg.AdminGRPCPort == cfg.GRPCPort {
+		return nil, fmt.Errorf("admin GRPC port %d must differ from GRPC port", cfg.AdminGRPCPort)
+	}
+	if cfg.AdminSocket && outputDir == "" {
+		return nil, fmt.Errorf("admin socket needs an output directory")
+	}
+	var result []grpcListener
+	closeAll := func() {
+		for _, l := range result {
+			_ = l.listener.Close()
+		}
+	}
+	listener, err := listen(listenerGRPC, "tcp", net.JoinHostPort(podIP, fmt.Sprintf("%d", cfg.GRPCPort)))
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, grpcListener{listener: listener, clusterOnly: true})
+	if cfg.AdminGRPCPort != 0 {
+		listener, err := listen(listenerAdminGRPC, "tcp", fmt.Sprintf("127.0.0.1:%d", cfg.AdminGRPCPort))
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		result = append(result, grpcListener{listener: listener})
+	}
+	if cfg.AdminSocket {
+		path := cfg.AdminSocketPath(outputDir)
+		// remove the socket of a previous run
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			closeAll()
+			return nil, err
+		}
+		listener, err := listen(listenerAdminSocket, "unix", path)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		result = append(result, grpcListener{listener: listener})
+	}
+	return result, nil
+}
+
+// newGRPCServer creates a GRPC server for the agent service. If clusterOnly is true, only the cluster methods are served.
+func newGRPCServer(agentServer nwpd.AgentServiceServer, clusterOnly bool) *grpc.Server {
+	var opts []grpc.ServerOption
+	if clusterOnly {
+		opts = append(opts, grpc.UnaryInterceptor(clusterMethodsInterceptor), grpc.StreamInterceptor(clusterMethodsStreamInterceptor))
+	}
+	s := grpc.NewServer(opts...)
+	nwpd.RegisterAgentServiceServer(s, agentServer)
+	return s
+}
+
+// startGRPCServers serves the agent service on all listeners.
+func startGRPCServers(log logrus.FieldLogger, agentServer *server) error {
+	var outputDir string
+	if agentServer.currentAgentConfig != nil {
+		outputDir = agentServer.currentAgentConfig.OutputDir
+	}
+	listeners, err := createGRPCListeners(agentServer.getNetworkCfg(), outputDir, os.Getenv(common.EnvPodIP))
+	if err != nil {
+		return err
+	}
+	for _, l := range listeners {
+		kind := "admin "
+		if l.clusterOnly {
+			kind = "cluster "
+		} else if len(listeners) == 1 {
+			kind = ""
+		}
+		s := newGRPCServer(agentServer, l.clusterOnly)
+		grpcServers = append(grpcServers, s)
+		log.Infof("%sserver listening at %s", kind, l.listener.Addr())
+		listener := l.listener
+		go func() {
+			if err := s.Serve(listener); err != nil {
+				log.Fatalf("failed to serve: %v", err)
+			}
+		}()
+	}
+	return nil
+}
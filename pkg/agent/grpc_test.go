@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+type fakeAgentService struct {
+	nwpd.UnimplementedAgentServiceServer
+}
+
+func (s *fakeAgentService) GetObservations(_ context.Context, _ *nwpd.GetObservationsRequest) (*nwpd.GetObservationsResponse, error) {
+	return &nwpd.GetObservationsResponse{}, nil
+}
+
+func (s *fakeAgentService) GetAggregatedObservations(_ context.Context, _ *nwpd.GetObservationsRequest) (*nwpd.GetAggregatedObservationsResponse, error) {
+	return &nwpd.GetAggregatedObservationsResponse{Mtu: 1500}, nil
+}
+
+func TestCreateGRPCListeners(t *testing.T) {
+	listeners, err := createGRPCListeners(&config.NetworkConfig{}, "", "")
+	assert.NoError(t, err)
+	if assert.Len(t, listeners, 1) {
+		assert.False(t, listeners[0].clusterOnly)
+		_ = listeners[0].listener.Close()
+	}
+
+	_, err = createGRPCListeners(&config.NetworkConfig{AdminSocket: true}, "", "127.0.0.1")
+	assert.Error(t, err, "missing output directory")
+	_, err = createGRPCListeners(&config.NetworkConfig{GRPCPort: 4242, AdminGRPCPort: 4242}, "", "127.0.0.1")
+	assert.Error(t, err, "same port")
+
+	dir := t.TempDir()
+	cfg := &config.NetworkConfig{AdminSocket: true, DataFilePrefix: "nwpd-agent-pod"}
+	listeners, err = createGRPCListeners(cfg, dir, "127.0.0.1")
+	if !assert.NoError(t, err) || !assert.Len(t, listeners, 2) {
+		return
+	}
+	assert.True(t, listeners[0].clusterOnly)
+	assert.Equal(t, "127.0.0.1", listeners[0].listener.Addr().(*net.TCPAddr).IP.String())
+	assert.False(t, listeners[1].clusterOnly)
+	assert.Equal(t, cfg.AdminSocketPath(dir), listeners[1].listener.Addr().String())
+
+	ctx := context.Background()
+	for _, l := range listeners {
+		s := newGRPCServer(&fakeAgentService{}, l.clusterOnly)
+		go func(l grpcListener) { _ = s.Serve(l.listener) }(l)
+		defer s.Stop()
+	}
+	dial := func(target string) nwpd.AgentServiceClient {
+		cc, err := grpc.Dial(target, grpc.WithInsecure())
+		assert.NoError(t, err)
+		t.Cleanup(func() { _ = cc.Close() })
+		return nwpd.NewAgentServiceClient(cc)
+	}
+
+	cluster := dial(listeners[0].listener.Addr().String())
+	resp, err := cluster.GetAggregatedObservations(ctx, &nwpd.GetObservationsRequest{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int32(1500), resp.Mtu)
+	}
+	_, err = cluster.GetObservations(ctx, &nwpd.GetObservationsRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	admin := dial("unix://" + cfg.AdminSocketPath(dir))
+	_, err = admin.GetObservations(ctx, &nwpd.GetObservationsRequest{})
+	assert.NoError(t, err)
+	_, err = admin.GetAggregatedObservations(ctx, &nwpd.GetObservationsRequest{})
+	assert.NoError(t, err)
+
+	called := false
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		called = true
+		return nil
+	}
+	err = clusterMethodsStreamInterceptor(nil, nil, &grpc.StreamServerInfo{FullMethod: "/nwpd.AgentService/Other"}, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.False(t, called)
+	err = clusterMethodsStreamInterceptor(nil, nil, &grpc.StreamServerInfo{FullMethod: "/nwpd.AgentService/RelayChecks"}, handler)
+	assert.NoError(t, err)
+	assert.True(t, called)
+}
+
+func TestCreateGRPCListenersPortConflict(t *testing.T) {
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer occupied.Close()
+	port := occupied.Addr().(*net.TCPAddr).Port
+
+	before := testutil.ToFloat64(PortBindFailures.WithLabelValues(listenerGRPC))
+	_, err = createGRPCListeners(&config.NetworkConfig{GRPCPort: port, AdminGRPCPort: port + 1}, "", "127.0.0.1")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "binding grpc listener")
+	}
+	assert.Equal(t, before+1, testutil.ToFloat64(PortBindFailures.WithLabelValues(listenerGRPC)))
+}
@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !go1.19
+
+package agent
+
+import "math"
+
+const memoryLimitSupported = false
+
+// setMemoryLimit is a no-op, as the soft memory limit of the Go runtime is only available since Go 1.19.
+func setMemoryLimit(_ int64) int64 {
+	return math.MaxInt64
+}
@@ -0,0 +1,17 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build go1.19
+
+package agent
+
+import "runtime/debug"
+
+const memoryLimitSupported = true
+
+// setMemoryLimit sets the soft memory limit of the Go runtime and returns the previous one.
+// A negative limit only returns the current one.
+func setMemoryLimit(limit int64) int64 {
+	return debug.SetMemoryLimit(limit)
+}
@@ -6,20 +6,53 @@ package agent
 
 import (
 	"sync"
+	"time"
 
+	"github.com/gardener/network-problem-detector/pkg/agent/aggregation"
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
 	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 func init() {
 	prometheus.MustRegister(AggregatedObservations)
 	prometheus.MustRegister(AggregatedObservationsLatency)
+	prometheus.MustRegister(SchedulerLag)
+	prometheus.MustRegister(SkippedOverlap)
+	prometheus.MustRegister(RunningChecks)
+	prometheus.MustRegister(SecondsSinceLastSuccess)
+	prometheus.MustRegister(JobInfo)
+	prometheus.MustRegister(DiscoveredPeers)
+	prometheus.MustRegister(ForwardedObservations)
+	prometheus.MustRegister(NodeZoneInfo)
+	prometheus.MustRegister(NodePoolInfo)
+	prometheus.MustRegister(JobLastRun)
+	prometheus.MustRegister(BackpressureDrops)
+	prometheus.MustRegister(OutputWriteDegraded)
+	prometheus.MustRegister(OutputDiskFree)
+	prometheus.MustRegister(ConfigGenerationInfo)
+	prometheus.MustRegister(RelayChecks)
+	prometheus.MustRegister(SharedResults)
+	prometheus.MustRegister(BlackholeDetections)
+	prometheus.MustRegister(DNSQueryLatency)
+	prometheus.MustRegister(FastRechecks)
+	prometheus.MustRegister(PortBindFailures)
+	prometheus.MustRegister(ResolverCacheLookups)
+	prometheus.MustRegister(JobSuspended)
+	prometheus.MustRegister(JobSuspensions)
+	prometheus.MustRegister(ObservationDuration)
+	prometheus.MustRegister(LatencyBreaches)
+	prometheus.MustRegister(InjectedFaults)
+	prometheus.MustRegister(NetworkInfo)
+	prometheus.MustRegister(ProxyRules)
 }
 
 var (
 	AggregatedObservations = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: "nwpd_aggregated_observations",
+			Name: common.MetricAggregatedObservations,
 			Help: "Total counts of observations",
 		},
 		[]string{"src", "dest", "jobid", "status"},
@@ -31,8 +64,396 @@ var (
 		},
 		[]string{"src", "dest", "jobid"},
 	)
+	SchedulerLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_scheduler_lag_seconds",
+			Help: "Delay in seconds between scheduled and actual start of the last job run",
+		},
+		[]string{"jobid"},
+	)
+	SkippedOverlap = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_skipped_overlap_total",
+			Help: "Total counts of job runs skipped because the previous run has not finished",
+		},
+		[]string{"jobid"},
+	)
+	RunningChecks = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_running_checks",
+			Help: "Number of currently running checks of a job",
+		},
+		[]string{"jobid"},
+	)
+	DiscoveredPeers = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_discovered_peers",
+			Help: "Number of peers known to the agent from the last loaded cluster configuration",
+		},
+		[]string{"source", "kind"},
+	)
+	ForwardedObservations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_forwarded_observations_total",
+			Help: "Total counts of observations forwarded from the pod network agent to the host network agent",
+		},
+		[]string{"result"},
+	)
+	BackpressureDrops = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_observations_dropped_total",
+			Help: "Total counts of observations dropped by the backpressure policy of the observation buffer",
+		},
+		[]string{"buffer"},
+	)
+	OutputWriteDegraded = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_output_write_degraded",
+			Help: "1 if the observations are kept in memory because the output directory is not writable (filesystem full or read-only), otherwise 0",
+		},
+		[]string{"buffer"},
+	)
+	OutputDiskFree = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_output_disk_free_percent",
+			Help: "Free space of the filesystem of the output directory in percent",
+		},
+		[]string{"buffer"},
+	)
+	JobLastRun = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_job_last_run_timestamp_seconds",
+			Help: "Unix timestamp of the end of the last completed run of a job",
+		},
+		[]string{"jobid"},
+	)
+	RelayChecks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_relay_checks_total",
+			Help: "Total counts of checks run on behalf of the controller to triangulate failing edges",
+		},
+		[]string{"jobid"},
+	)
+	FastRechecks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_fast_rechecks_total",
+			Help: "Total counts of fast re-checks of failing edges by result (ok, failed or skipped)",
+		},
+		[]string{"jobid", "result"},
+	)
+	PortBindFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_port_bind_failures_total",
+			Help: "Total counts of failures to bind the port of a listener of the agent (http, echo, grpc, admin-grpc or admin-socket)",
+		},
+		[]string{"listener"},
+	)
+	SharedResults = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_shared_connection_results_total",
+			Help: "Total counts of observations reusing the connection result of another job",
+		},
+		[]string{"jobid"},
+	)
+	BlackholeDetections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_blackhole_detections_total",
+			Help: "Total counts of checks with established connection but silently dropped data (failure class '" + runners.FailureClassBlackhole + "')",
+		},
+		[]string{"src", "dest", "jobid"},
+	)
+	DNSQueryLatency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_dns_query_latency_secs",
+			Help: "Duration in seconds of the last DNS query by transport of DNS lookups comparing UDP and TCP",
+		},
+		[]string{"src", "dest", "jobid", "transport"},
+	)
+	ResolverCacheLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_resolver_cache_lookups_total",
+			Help: "Total counts of lookups of the checks in the resolver cache by result ('" + runners.ResolverCacheHit + "', '" +
+				runners.ResolverCacheMiss + "' or '" + runners.ResolverCacheStale + "')",
+		},
+		[]string{"result"},
+	)
+	JobSuspended = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_job_suspended",
+			Help: "1 if the job is suspended by the safety valve, otherwise 0",
+		},
+		[]string{"jobid"},
+	)
+	JobSuspensions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_job_suspensions_total",
+			Help: "Total counts of suspensions of a job by the safety valve",
+		},
+		[]string{"jobid"},
+	)
+	ObservationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "nwpd_observation_duration_seconds",
+			Help:    "Distribution of the durations of successful checks in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+		},
+		[]string{"jobid"},
+	)
+	LatencyBreaches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_latency_breaches_total",
+			Help: "Total counts of successful checks exceeding the expected latency of the job",
+		},
+		[]string{"src", "dest", "jobid"},
+	)
+	InjectedFaults = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_injected_faults_total",
+			Help: "Total counts of checks with injected faults (only if fault injection is enabled for testing)",
+		},
+		[]string{"jobid"},
+	)
+	ConfigGenerationInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: common.MetricConfigGenerationInfo,
+			Help: "Generation of the agent config in use (always 1)",
+		},
+		[]string{"hash"},
+	)
+	NodeZoneInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: common.MetricNodeZoneInfo,
+			Help: "Zone of the nodes from the last loaded cluster configuration (always 1)",
+		},
+		[]string{"node", "zone"},
+	)
+	NetworkInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: common.MetricAgentNetworkInfo,
+			Help: "Interface and gateway of the default route and primary IP of the interface per address family as seen by the agent (always 1)",
+		},
+		[]string{"family", "interface", "gateway", "ip"},
+	)
+	ProxyRules = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_proxy_rules",
+			Help: "Number of rules of the kube-proxy chains of iptables (nftables and legacy backend) and of IPVS services of the last proxy rules check",
+		},
+		[]string{"kind"},
+	)
+	NodePoolInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: common.MetricNodePoolInfo,
+			Help: "Worker pool of the nodes from the last loaded cluster configuration (always 1)",
+		},
+		[]string{"node", "pool"},
+	)
+)
+
+const (
+	// forwardResultOK means the observations have been acknowledged by the host network agent.
+	forwardResultOK = "ok"
+	// forwardResultDropped means the observations have been dropped because the buffer was full.
+	forwardResultDropped = "dropped"
+)
+
+const (
+	// bufferWriter is the buffer of the writer of the own observations
+	bufferWriter = "writer"
+	// bufferForwardedWriter is the buffer of the writer of the observations forwarded by the pod network agent
+	bufferForwardedWriter = "forwarded-writer"
+)
+
+// ReportBackpressureDrop counts an observation dropped by the backpressure policy.
+func ReportBackpressureDrop(buffer string) {
+	BackpressureDrops.WithLabelValues(buffer).Inc()
+}
+
+// ReportOutputWriteDegraded sets if the writer of the buffer is in degraded mode.
+func ReportOutputWriteDegraded(buffer string, degraded bool) {
+	value := 0.0
+	if degraded {
+		value = 1
+	}
+	OutputWriteDegraded.WithLabelValues(buffer).Set(value)
+}
+
+// ReportOutputDiskFree sets the free space of the filesystem of the output directory of the writer of the buffer.
+func ReportOutputDiskFree(buffer string, percent float64) {
+	OutputDiskFree.WithLabelValues(buffer).Set(percent)
+}
+
+// ReportForwardedObservations counts forwarded or dropped observations.
+func ReportForwardedObservations(result string, count int) {
+	ForwardedObservations.WithLabelValues(result).Add(float64(count))
+}
+
+const (
+	// peerSourceController means the peers are injected by the controller (or the deploy command) via the cluster config map.
+	peerSourceController = "controller"
+	// peerSourceSimulation means the peers are generated by the simulation.
+	peerSourceSimulation = "simulation"
 )
 
+// reportDiscoveredPeers sets the number of nodes and agent pods of the cluster configuration.
+func reportDiscoveredPeers(cfg *config.ClusterConfig, source string) {
+	var nodes, pods int
+	if cfg != nil {
+		nodes = len(cfg.Nodes)
+		pods = len(cfg.PodEndpoints)
+	}
+	DiscoveredPeers.WithLabelValues(source, "nodes").Set(float64(nodes))
+	DiscoveredPeers.WithLabelValues(source, "pods").Set(float64(pods))
+}
+
+func reportConfigGeneration(generation string) {
+	ConfigGenerationInfo.Reset()
+	ConfigGenerationInfo.WithLabelValues(generation).Set(1)
+}
+
+// ReportNetworkInfo exports the default routes of the agent, replacing the previous ones.
+func ReportNetworkInfo(info []*nwpd.NetworkInfo) {
+	NetworkInfo.Reset()
+	for _, i := range info {
+		NetworkInfo.WithLabelValues(i.Family, i.Interface, i.Gateway, i.PrimaryIP).Set(1)
+	}
+}
+
+// ReportProxyRules sets the numbers of proxy rules per kind counted by the last proxy rules check.
+func ReportProxyRules(counts map[string]int) {
+	for kind, count := range counts {
+		ProxyRules.WithLabelValues(kind).Set(float64(count))
+	}
+}
+
+// reportNodeZones exports the zones of the nodes of the cluster configuration. Nodes without zone are omitted.
+// The metric allows to aggregate observations by zone, e.g. in the alert rules generated by `nwpdcli generate alerts`.
+func reportNodeZones(cfg *config.ClusterConfig) {
+	NodeZoneInfo.Reset()
+	if cfg == nil {
+		return
+	}
+	for _, node := range cfg.Nodes {
+		if node.Zone != "" {
+			NodeZoneInfo.WithLabelValues(node.Hostname, node.Zone).Set(1)
+		}
+	}
+}
+
+// reportNodePools exports the worker pools of the nodes of the cluster configuration. Nodes without pool get the pool 'unknown'.
+// The metric allows to aggregate observations by worker pool without adding labels to the metrics per edge.
+func reportNodePools(cfg *config.ClusterConfig) {
+	NodePoolInfo.Reset()
+	if cfg == nil {
+		return
+	}
+	for _, node := range cfg.Nodes {
+		pool := node.Pool
+		if pool == "" {
+			pool = common.NodeGroupUnknown
+		}
+		NodePoolInfo.WithLabelValues(node.Hostname, pool).Set(1)
+	}
+}
+
+var SecondsSinceLastSuccess = &lastSuccessCollector{
+	maxDesc: prometheus.NewDesc(
+		"nwpd_seconds_since_last_success",
+		"Maximum over all destinations of the seconds since the last successful observation",
+		[]string{"jobid"}, nil,
+	),
+	perDestDesc: prometheus.NewDesc(
+		"nwpd_seconds_since_last_success_per_dest",
+		"Seconds since the last successful observation",
+		[]string{"src", "dest", "jobid"}, nil,
+	),
+}
+
+// lastSuccessCollector calculates the seconds since the last success from the aggregated edges on each scrape.
+type lastSuccessCollector struct {
+	lock        sync.Mutex
+	aggregator  aggregation.ObservationListenerExtended
+	maxDesc     *prometheus.Desc
+	perDestDesc *prometheus.Desc
+}
+
+var _ prometheus.Collector = &lastSuccessCollector{}
+
+func (c *lastSuccessCollector) setAggregator(aggregator aggregation.ObservationListenerExtended) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.aggregator = aggregator
+}
+
+func (c *lastSuccessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxDesc
+	ch <- c.perDestDesc
+}
+
+func (c *lastSuccessCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.Lock()
+	aggregator := c.aggregator
+	c.lock.Unlock()
+	if aggregator == nil {
+		return
+	}
+
+	maxSeconds := map[string]float64{}
+	for _, edge := range aggregator.ListEdges(0, -1).Items {
+		seconds := edge.SecondsSinceLastSuccess
+		if max, ok := maxSeconds[edge.JobID]; !ok || seconds > max {
+			maxSeconds[edge.JobID] = seconds
+		}
+		ch <- prometheus.MustNewConstMetric(c.perDestDesc, prometheus.GaugeValue, seconds, edge.SrcHost, edge.DestHost, edge.JobID)
+	}
+	for jobID, seconds := range maxSeconds {
+		ch <- prometheus.MustNewConstMetric(c.maxDesc, prometheus.GaugeValue, seconds, jobID)
+	}
+}
+
+// JobInfo exports the labels of the jobs. They are not added to the other metrics to keep their cardinality,
+// but can be joined on the label jobid, e.g. `nwpd_aggregated_observations * on(jobid) group_left(tier) nwpd_job_info`.
+var JobInfo = &jobInfoCollector{}
+
+// jobInfoCollector exports the metric nwpd_job_info with the union of the label names of all jobs.
+type jobInfoCollector struct {
+	lock sync.Mutex
+	jobs []config.Job
+}
+
+var _ prometheus.Collector = &jobInfoCollector{}
+
+func (c *jobInfoCollector) setJobs(jobs []config.Job) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.jobs = jobs
+}
+
+// Describe sends no descriptor, as the label names depend on the jobs. This makes it an unchecked collector.
+func (c *jobInfoCollector) Describe(_ chan<- *prometheus.Desc) {}
+
+func (c *jobInfoCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.Lock()
+	jobs := c.jobs
+	c.lock.Unlock()
+
+	nameSet := common.StringSet{}
+	for _, job := range jobs {
+		for name := range job.Labels {
+			nameSet.Add(name)
+		}
+	}
+	names := nameSet.ToSortedArray()
+	desc := prometheus.NewDesc("nwpd_job_info", "Protocol and labels of the job (always 1)", append([]string{"jobid", "protocol"}, names...), nil)
+	for _, job := range jobs {
+		values := []string{job.JobID, runners.Protocol(job.Args)}
+		for _, name := range names {
+			values = append(values, job.Labels[name])
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, values...)
+	}
+}
+
 type observationKey struct {
 	src   string
 	dest  string
@@ -75,11 +496,19 @@ func (k *observationKeys) remove(isObsolete func(key observationKey) bool) []obs
 	return keys
 }
 
-func IncAggregatedObservation(src, dest, jobid string, ok bool) {
+// IncAggregatedObservation counts an observation. Observations during a maintenance window are counted with
+// the status 'maintenance-ok' or 'maintenance-failed', so that they don't trigger alerts on failed observations.
+// Likewise, observations of destinations within their warm-up period are counted as 'warming-up-ok' or 'warming-up-failed'.
+func IncAggregatedObservation(src, dest, jobid string, ok, maintenance, warmingUp bool) {
 	status := "ok"
 	if !ok {
 		status = "failed"
 	}
+	if warmingUp {
+		status = "warming-up-" + status
+	} else if maintenance {
+		status = "maintenance-" + status
+	}
 	metricKeys.add(src, dest, jobid)
 	AggregatedObservations.WithLabelValues(src, dest, jobid, status).Inc()
 }
@@ -88,7 +517,88 @@ func ReportAggregatedObservationLatency(src, dest, jobid string, seconds float64
 	AggregatedObservationsLatency.WithLabelValues(src, dest, jobid).Set(seconds)
 }
 
+func ReportSchedulerLag(jobid string, seconds float64) {
+	SchedulerLag.WithLabelValues(jobid).Set(seconds)
+}
+
+func ReportSkippedOverlap(jobid string) {
+	SkippedOverlap.WithLabelValues(jobid).Inc()
+}
+
+func ReportRunningChecks(jobid string, count int) {
+	RunningChecks.WithLabelValues(jobid).Set(float64(count))
+}
+
+func ReportJobLastRun(jobid string, lastRun time.Time) {
+	JobLastRun.WithLabelValues(jobid).Set(float64(lastRun.UnixNano()) / 1e9)
+}
+
+func ReportRelayCheck(jobid string) {
+	RelayChecks.WithLabelValues(jobid).Inc()
+}
+
+func ReportFastRecheck(jobid, result string) {
+	FastRechecks.WithLabelValues(jobid, result).Inc()
+}
+
+func ReportPortBindFailure(listener string) {
+	PortBindFailures.WithLabelValues(listener).Inc()
+}
+
+func ReportResolverCacheLookup(result string) {
+	ResolverCacheLookups.WithLabelValues(result).Inc()
+}
+
+// ReportJobSuspended reports a suspension or resumption of the job by the safety valve.
+func ReportJobSuspended(jobid string, suspended bool) {
+	if suspended {
+		JobSuspended.WithLabelValues(jobid).Set(1)
+		JobSuspensions.WithLabelValues(jobid).Inc()
+	} else {
+		JobSuspended.WithLabelValues(jobid).Set(0)
+	}
+}
+
+func ReportSharedResult(jobid string) {
+	SharedResults.WithLabelValues(jobid).Inc()
+}
+
+func ReportBlackholeDetection(src, dest, jobid string) {
+	BlackholeDetections.WithLabelValues(src, dest, jobid).Inc()
+}
+
+func ReportDNSQueryLatency(src, dest, jobid, transport string, seconds float64) {
+	DNSQueryLatency.WithLabelValues(src, dest, jobid, transport).Set(seconds)
+}
+
+func ReportObservationDuration(jobid string, seconds float64) {
+	ObservationDuration.WithLabelValues(jobid).Observe(seconds)
+}
+
+func ReportLatencyBreach(src, dest, jobid string) {
+	LatencyBreaches.WithLabelValues(src, dest, jobid).Inc()
+}
+
+func ReportInjectedFault(jobid string) {
+	InjectedFaults.WithLabelValues(jobid).Inc()
+}
+
 func deleteOutdatedMetricByObsoleteJobIDs(jobIDs []string) {
+	for _, id := range jobIDs {
+		SchedulerLag.DeleteLabelValues(id)
+		JobLastRun.DeleteLabelValues(id)
+		SkippedOverlap.DeleteLabelValues(id)
+		RelayChecks.DeleteLabelValues(id)
+		for _, result := range []string{fastRecheckOk, fastRecheckFailed, fastRecheckSkipped} {
+			FastRechecks.DeleteLabelValues(id, result)
+		}
+		SharedResults.DeleteLabelValues(id)
+		RunningChecks.DeleteLabelValues(id)
+		JobSuspended.DeleteLabelValues(id)
+		JobSuspensions.DeleteLabelValues(id)
+		ObservationDuration.DeleteLabelValues(id)
+		InjectedFaults.DeleteLabelValues(id)
+	}
 	if len(jobIDs) > 0 {
 		keys := metricKeys.remove(func(key observationKey) bool {
 			for _, id := range jobIDs {
@@ -114,5 +624,9 @@ func deleteOutdatedMetricsByKeys(keys []observationKey) {
 		AggregatedObservations.DeleteLabelValues(key.src, key.dest, key.jobid, "ok")
 		AggregatedObservations.DeleteLabelValues(key.src, key.dest, key.jobid, "failed")
 		AggregatedObservationsLatency.DeleteLabelValues(key.src, key.dest, key.jobid)
+		BlackholeDetections.DeleteLabelValues(key.src, key.dest, key.jobid)
+		LatencyBreaches.DeleteLabelValues(key.src, key.dest, key.jobid)
+		DNSQueryLatency.DeleteLabelValues(key.src, key.dest, key.jobid, nwpd.ProtocolUDP)
+		DNSQueryLatency.DeleteLabelValues(key.src, key.dest, key.jobid, nwpd.ProtocolTCP)
 	}
 }
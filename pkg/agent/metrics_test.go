@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+func TestJobInfoCollector(t *testing.T) {
+	collector := &jobInfoCollector{}
+	collector.setJobs([]config.Job{
+		{JobID: "tcp-n2api-ext", Args: []string{"checkTCPPort"}, Labels: map[string]string{"tier": "external", "team": "core"}},
+		{JobID: "tcp-n2n", Args: []string{"checkTCPPort"}, Labels: map[string]string{"tier": "internal"}},
+		{JobID: "ping-n2n", Args: []string{"pingHost"}},
+	})
+
+	registry := prometheus.NewRegistry()
+	assert.NoError(t, registry.Register(collector))
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	assert.Len(t, families, 1)
+	assert.Equal(t, "nwpd_job_info", families[0].GetName())
+
+	labels := map[string]map[string]string{}
+	for _, m := range families[0].GetMetric() {
+		values := map[string]string{}
+		for _, pair := range m.GetLabel() {
+			values[pair.GetName()] = pair.GetValue()
+		}
+		labels[values["jobid"]] = values
+		assert.Equal(t, 1.0, m.GetGauge().GetValue())
+	}
+	assert.Equal(t, map[string]map[string]string{
+		"tcp-n2api-ext": {"jobid": "tcp-n2api-ext", "protocol": "tcp", "team": "core", "tier": "external"},
+		"tcp-n2n":       {"jobid": "tcp-n2n", "protocol": "tcp", "team": "", "tier": "internal"},
+		"ping-n2n":      {"jobid": "ping-n2n", "protocol": "icmp", "team": "", "tier": ""},
+	}, labels)
+}
+
+func TestReportDiscoveredPeers(t *testing.T) {
+	reportDiscoveredPeers(&config.ClusterConfig{
+		Nodes:        []config.Node{{Hostname: "node1"}, {Hostname: "node2"}},
+		PodEndpoints: []config.PodEndpoint{{Nodename: "node1"}},
+	}, peerSourceController)
+	assert.Equal(t, 2.0, testutil.ToFloat64(DiscoveredPeers.WithLabelValues(peerSourceController, "nodes")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(DiscoveredPeers.WithLabelValues(peerSourceController, "pods")))
+
+	reportDiscoveredPeers(nil, peerSourceController)
+	assert.Equal(t, 0.0, testutil.ToFloat64(DiscoveredPeers.WithLabelValues(peerSourceController, "nodes")))
+}
+
+func TestReportNodeZones(t *testing.T) {
+	reportNodeZones(&config.ClusterConfig{
+		Nodes: []config.Node{{Hostname: "node1", Zone: "zone-a"}, {Hostname: "node2", Zone: "zone-b"}, {Hostname: "node3"}},
+	})
+	assert.Equal(t, 2, testutil.CollectAndCount(NodeZoneInfo))
+	assert.Equal(t, 1.0, testutil.ToFloat64(NodeZoneInfo.WithLabelValues("node2", "zone-b")))
+
+	reportNodeZones(nil)
+	assert.Equal(t, 0, testutil.CollectAndCount(NodeZoneInfo))
+}
+
+func TestReportNodePools(t *testing.T) {
+	reportNodePools(&config.ClusterConfig{
+		Nodes: []config.Node{{Hostname: "node1", Pool: "worker-a"}, {Hostname: "node2"}},
+	})
+	assert.Equal(t, 2, testutil.CollectAndCount(NodePoolInfo))
+	assert.Equal(t, 1.0, testutil.ToFloat64(NodePoolInfo.WithLabelValues("node1", "worker-a")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(NodePoolInfo.WithLabelValues("node2", common.NodeGroupUnknown)))
+
+	reportNodePools(nil)
+	assert.Equal(t, 0, testutil.CollectAndCount(NodePoolInfo))
+}
@@ -0,0 +1,35 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"fmt"
+	"net"
+)
+
+// interfaceMTU returns the MTU of the network interface owning the given IP address.
+// For the agent in the pod network this is the interface of the overlay, for the agent in the host network the one of the node.
+func interfaceMTU(ip string) (int, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return 0, fmt.Errorf("invalid IP address %q", ip)
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return 0, err
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.Equal(addr) {
+				return iface.MTU, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("no network interface with IP address %s", ip)
+}
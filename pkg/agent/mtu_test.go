@@ -0,0 +1,27 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterfaceMTU(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip("no loopback interface")
+	}
+	mtu, err := interfaceMTU("127.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, lo.MTU, mtu)
+
+	_, err = interfaceMTU("192.0.2.1")
+	assert.Error(t, err)
+	_, err = interfaceMTU("")
+	assert.Error(t, err)
+}
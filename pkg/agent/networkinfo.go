@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// networkInfoPeriod is the period of re-reading the default routes to detect changes.
+const networkInfoPeriod = 1 * time.Minute
+
+// networkInfoSetter is implemented by the writers recording the network info in the headers of the record files.
+type networkInfoSetter interface {
+	SetNetworkInfo(info []*nwpd.NetworkInfo)
+}
+
+// updateNetworkInfo reads the default routes and the primary IPs of the agent. On changes, the transition is logged
+// and the metric and the network info of the record file headers are updated.
+func (s *server) updateNetworkInfo() {
+	info, err := runners.ReadNetworkInfo()
+	if err != nil {
+		s.log.Warnf("cannot read network info: %s", err)
+		return
+	}
+	s.lock.Lock()
+	old, known := s.networkInfo, s.networkInfoRead
+	s.networkInfo, s.networkInfoRead = info, true
+	writer := s.writer
+	s.lock.Unlock()
+	if known && networkInfoEqual(old, info) {
+		return
+	}
+	if known {
+		s.log.Infof("network info changed from %s to %s", nwpd.FormatNetworkInfo(old), nwpd.FormatNetworkInfo(info))
+	} else {
+		s.log.Infof("network info: %s", nwpd.FormatNetworkInfo(info))
+	}
+	ReportNetworkInfo(info)
+	if w, ok := writer.(networkInfoSetter); ok {
+		w.SetNetworkInfo(info)
+	}
+}
+
+func (s *server) getNetworkInfo() []*nwpd.NetworkInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.networkInfo
+}
+
+func networkInfoEqual(a, b []*nwpd.NetworkInfo) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// portBindFailureExitDelay is the delay before the agent exits after failing to bind a port while the metrics are served,
+// so that the failure can be scraped.
+var portBindFailureExitDelay = 1 * time.Minute
+
+const (
+	listenerHTTP        = "http"
+	listenerEcho        = "echo"
+	listenerGRPC        = "grpc"
+	listenerAdminGRPC   = "admin-grpc"
+	listenerAdminSocket = "admin-socket"
+)
+
+// listen binds the address and counts a failure with the metric nwpd_port_bind_failures_total, so that port conflicts,
+// e.g. with another process on the host network, are visible instead of resulting in missing data.
+func listen(listenerName, network, address string) (net.Listener, error) {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		ReportPortBindFailure(listenerName)
+		return nil, fmt.Errorf("binding %s listener to %s failed, check the port configuration of both daemon sets for conflicts: %w", listenerName, address, err)
+	}
+	return listener, nil
+}
+
+// startMetricsServer binds the http port and serves the metrics. The other handlers are added when the agent runs.
+func (s *server) startMetricsServer() error {
+	port := s.getNetworkCfg().HttpPort
+	if port == 0 {
+		return nil
+	}
+	listener, err := listen(listenerHTTP, "tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	s.httpListener = listener
+	http.Handle("/metrics", promhttp.Handler())
+	s.log.Infof("provide metrics at ':%d/metrics' and edges at ':%d/edges.json'", port, port)
+	go func() {
+		if err := http.Serve(listener, nil); err != nil {
+			s.log.Errorf("http server failed: %s", err)
+		}
+	}()
+	return nil
+}
+
+// bindPortsFailed logs the bind failure and, if the metrics are served, waits until the failure can be scraped
+// before the agent exits.
+func (s *server) bindPortsFailed(log logrus.FieldLogger, err error) error {
+	log.Error(err)
+	if s.httpListener != nil && portBindFailureExitDelay > 0 {
+		log.Errorf("exiting in %s", portBindFailureExitDelay)
+		time.Sleep(portBindFailureExitDelay)
+	}
+	return err
+}
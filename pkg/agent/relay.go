@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// relayTriggerPrefix is the prefix of the trigger reference of observations of relayed checks, followed by the requester.
+const relayTriggerPrefix = "relay/"
+
+// RelayCheck runs a single check of a job for a destination host on behalf of the controller. It allows to check an edge
+// failing on another node from this node to find out if the source or the destination is broken.
+// The observation is only returned and not recorded.
+func (s *server) RelayCheck(ctx context.Context, request *nwpd.RelayCheckRequest) (*nwpd.RelayCheckResponse, error) {
+	if !s.started.Load() {
+		return nil, status.Errorf(codes.Unavailable, "agent not started yet")
+	}
+	obs, err := s.relayCheck(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return &nwpd.RelayCheckResponse{Observation: obs}, nil
+}
+
+// RelayChecks runs the checks of a batched request concurrently and streams back the results as they complete.
+// Compared to single RelayCheck calls, the requester needs only one connection and round trip per agent.
+// Checks which cannot be run are returned with their error, the stream is only aborted if the request is invalid.
+func (s *server) RelayChecks(request *nwpd.RelayChecksRequest, stream nwpd.AgentService_RelayChecksServer) error {
+	if !s.started.Load() {
+		return status.Errorf(codes.Unavailable, "agent not started yet")
+	}
+	if len(request.Checks) > common.MaxRelayChecksPerBatch {
+		return status.Errorf(codes.InvalidArgument, "too many checks in batch: %d > %d", len(request.Checks), common.MaxRelayChecksPerBatch)
+	}
+	ctx := stream.Context()
+	results := make(chan *nwpd.RelayCheckResponse, len(request.Checks))
+	for i, check := range request.Checks {
+		go func(index int32, check *nwpd.RelayCheckRequest) {
+			resp := &nwpd.RelayCheckResponse{Index: index}
+			obs, err := s.relayCheck(ctx, check)
+			if err != nil {
+				st := status.Convert(err)
+				resp.Error = st.Message()
+				resp.Code = int32(st.Code())
+			} else {
+				resp.Observation = obs
+			}
+			results <- resp
+		}(int32(i), check)
+	}
+	for range request.Checks {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case resp := <-results:
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *server) relayCheck(ctx context.Context, request *nwpd.RelayCheckRequest) (*nwpd.Observation, error) {
+	s.lock.Lock()
+	job := s.jobs[request.JobID]
+	s.lock.Unlock()
+	if job == nil {
+		return nil, status.Errorf(codes.NotFound, "unknown job %s", request.JobID)
+	}
+
+	ch := make(chan *nwpd.Observation, 1)
+	done := make(chan bool, 1)
+	go func() {
+		done <- job.RunFor(ch, request.DestHost, relayTriggerPrefix+request.Requester)
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	case ok := <-done:
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "job %s cannot check destination %s", request.JobID, request.DestHost)
+		}
+		ReportRelayCheck(request.JobID)
+		return <-ch, nil
+	}
+}
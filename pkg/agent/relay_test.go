@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestRelayCheck(t *testing.T) {
+	s := &server{
+		log:  logrus.New(),
+		jobs: map[jobid]*runners.InternalJob{"tcp-n2api": newWatchdogTestJob("tcp-n2api", time.Minute, nil, &runners.SimulationConfig{})},
+	}
+	ctx := context.Background()
+	request := &nwpd.RelayCheckRequest{JobID: "tcp-n2api", DestHost: "node1", Requester: "controller"}
+
+	_, err := s.RelayCheck(ctx, request)
+	assert.Equal(t, codes.Unavailable, status.Code(err), "before startup")
+
+	s.started.Store(true)
+	resp, err := s.RelayCheck(ctx, request)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "node1", resp.Observation.DestHost)
+		assert.Equal(t, "relay/controller", resp.Observation.TriggeredBy)
+	}
+
+	_, err = s.RelayCheck(ctx, &nwpd.RelayCheckRequest{JobID: "unknown", DestHost: "node1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = s.RelayCheck(ctx, &nwpd.RelayCheckRequest{JobID: "tcp-n2api", DestHost: "node2"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// startRelayTestServer serves the relayed checks of a started agent with a simulated TCP check of node1.
+func startRelayTestServer(tb testing.TB) string {
+	s := &server{
+		log:  logrus.New(),
+		jobs: map[jobid]*runners.InternalJob{"tcp-n2api": newWatchdogTestJob("tcp-n2api", time.Minute, nil, &runners.SimulationConfig{})},
+	}
+	s.started.Store(true)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	gs := newGRPCServer(s, true)
+	go func() { _ = gs.Serve(listener) }()
+	tb.Cleanup(gs.Stop)
+	return listener.Addr().String()
+}
+
+func TestRelayChecks(t *testing.T) {
+	address := startRelayTestServer(t)
+	cc, err := grpc.Dial(address, grpc.WithInsecure())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cc.Close()
+	client := nwpd.NewAgentServiceClient(cc)
+
+	stream, err := client.RelayChecks(context.Background(), &nwpd.RelayChecksRequest{Checks: []*nwpd.RelayCheckRequest{
+		{JobID: "tcp-n2api", DestHost: "node1", Requester: "controller"},
+		{JobID: "unknown", DestHost: "node1"},
+		{JobID: "tcp-n2api", DestHost: "node2"},
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	responses := map[int32]*nwpd.RelayCheckResponse{}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+		responses[resp.Index] = resp
+	}
+	if assert.Len(t, responses, 3) {
+		assert.Equal(t, "node1", responses[0].Observation.DestHost)
+		assert.Equal(t, "relay/controller", responses[0].Observation.TriggeredBy)
+		assert.Nil(t, responses[1].Observation)
+		assert.Equal(t, int32(codes.NotFound), responses[1].Code)
+		assert.Equal(t, "unknown job unknown", responses[1].Error)
+		assert.Equal(t, int32(codes.InvalidArgument), responses[2].Code)
+	}
+
+	stream, err = client.RelayChecks(context.Background(), &nwpd.RelayChecksRequest{Checks: make([]*nwpd.RelayCheckRequest, common.MaxRelayChecksPerBatch+1)})
+	if assert.NoError(t, err) {
+		_, err = stream.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
+
+// The benchmarks compare relaying checks with a connection and a call per check (as before batching) to a single
+// batched call per agent. Run with 'go test ./pkg/agent -run XXX -bench Relay -benchmem'.
+const relayBenchmarkChecks = 50
+
+func BenchmarkRelayCheckPerCall(b *testing.B) {
+	address := startRelayTestServer(b)
+	request := &nwpd.RelayCheckRequest{JobID: "tcp-n2api", DestHost: "node1", Requester: "controller"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < relayBenchmarkChecks; j++ {
+			cc, err := grpc.Dial(address, grpc.WithInsecure(), grpc.WithBlock())
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := nwpd.NewAgentServiceClient(cc).RelayCheck(context.Background(), request); err != nil {
+				b.Fatal(err)
+			}
+			_ = cc.Close()
+		}
+	}
+}
+
+func BenchmarkRelayChecksBatched(b *testing.B) {
+	address := startRelayTestServer(b)
+	request := &nwpd.RelayChecksRequest{}
+	for j := 0; j < relayBenchmarkChecks; j++ {
+		request.Checks = append(request.Checks, &nwpd.RelayCheckRequest{JobID: "tcp-n2api", DestHost: "node1", Requester: "controller"})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cc, err := grpc.Dial(address, grpc.WithInsecure(), grpc.WithBlock())
+		if err != nil {
+			b.Fatal(err)
+		}
+		stream, err := nwpd.NewAgentServiceClient(cc).RelayChecks(context.Background(), request)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for {
+			if _, err := stream.Recv(); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+		_ = cc.Close()
+	}
+}
@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+const reloadCycles = 100
+
+// TestReloadGoroutineLeak reloads the agent configuration repeatedly while slow checks are running
+// and checks that the goroutines of the stopped jobs are not leaked.
+func TestReloadGoroutineLeak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping reload cycles in short mode")
+	}
+
+	dir := t.TempDir()
+	agentConfigFile := filepath.Join(dir, "agent.config")
+	assert.NoError(t, os.WriteFile(agentConfigFile, []byte(fmt.Sprintf(simulationAgentConfig, filepath.Join(dir, "records"))), 0644))
+
+	simulation, err := runners.ParseSimulationConfig("nodes=10,latency=20ms")
+	assert.NoError(t, err)
+
+	log := logrus.New()
+	log.SetLevel(logrus.WarnLevel)
+	srv, err := newServer(log, agentConfigFile, "", false, simulation)
+	assert.NoError(t, err)
+	srv.logDirectory = filepath.Join(dir, "log")
+	srv.tickPeriod = 5 * time.Millisecond
+	if !assert.NoError(t, srv.setup()) {
+		return
+	}
+	baseline := runtime.NumGoroutine()
+
+	// alternate between updated, removed and added jobs
+	configs := []*config.AgentConfig{srv.currentAgentConfig}
+	for _, change := range []func(cfg *config.AgentConfig){
+		func(cfg *config.AgentConfig) {
+			cfg.PodNetwork.DefaultPeriod = metav1.Duration{Duration: 15 * time.Millisecond}
+		},
+		func(cfg *config.AgentConfig) { cfg.PodNetwork.Jobs = cfg.PodNetwork.Jobs[1:] },
+		func(cfg *config.AgentConfig) {
+			cfg.PodNetwork.Jobs = append(cfg.PodNetwork.Jobs, config.Job{JobID: "tcp-n2n-2", Args: []string{"checkTCPPort", "--node-port", "1012"}})
+		},
+	} {
+		cfg, err := srv.currentAgentConfig.Clone()
+		if !assert.NoError(t, err) {
+			return
+		}
+		change(cfg)
+		configs = append(configs, cfg)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		srv.run()
+		close(stopped)
+	}()
+	for i := 0; i < reloadCycles; i++ {
+		time.Sleep(time.Duration(i%3) * time.Millisecond)
+		srv.reloadLock.Lock()
+		err := srv.applyAgentConfig(configs[i%len(configs)])
+		srv.reloadLock.Unlock()
+		if !assert.NoError(t, err) {
+			break
+		}
+	}
+	close(srv.done)
+	<-stopped
+
+	// the signal handling loop started by run is never stopped
+	baseline++
+	// polling without assert.Eventually, which runs the condition in its own goroutine
+	goroutines := runtime.NumGoroutine()
+	for deadline := time.Now().Add(5 * time.Second); goroutines > baseline && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		goroutines = runtime.NumGoroutine()
+	}
+	assert.LessOrEqual(t, goroutines, baseline, "goroutines leaked")
+}
@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+var (
+	caBundleLock sync.Mutex
+	caBundle     *config.CABundleConfig
+)
+
+// SetCABundle sets the CA bundle used by the TLS-based checks to verify the server certificates, nil for the system pool.
+func SetCABundle(cfg *config.CABundleConfig) {
+	caBundleLock.Lock()
+	defer caBundleLock.Unlock()
+	caBundle = cfg
+}
+
+func getCABundle() *config.CABundleConfig {
+	caBundleLock.Lock()
+	defer caBundleLock.Unlock()
+	return caBundle
+}
+
+// rootCAs returns the pool for verifying server certificates with the CA bundle and the additional PEM files.
+// It returns nil for the system pool if there is neither a CA bundle nor an additional file.
+// The files are read on each call, so that updates of mounted config maps and secrets are picked up.
+func rootCAs(additionalFiles ...string) (*x509.CertPool, error) {
+	bundle := getCABundle()
+	if bundle == nil && len(additionalFiles) == 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	if bundle != nil {
+		if bundle.AppendToSystemPool {
+			systemPool, err := x509.SystemCertPool()
+			if err != nil {
+				return nil, fmt.Errorf("loading system CA certificates failed: %w", err)
+			}
+			pool = systemPool
+		}
+		additionalFiles = append([]string{bundle.File}, additionalFiles...)
+	}
+	for _, file := range additionalFiles {
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no CA certificates found in %s", file)
+		}
+	}
+	return pool, nil
+}
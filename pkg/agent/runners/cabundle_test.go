@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CA bundle", func() {
+	var (
+		server   *httptest.Server
+		endpoint config.Endpoint
+		caFile   string
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		addr := server.Listener.Addr().(*net.TCPAddr)
+		endpoint = config.Endpoint{Hostname: addr.IP.String(), Port: addr.Port}
+		caFile = filepath.Join(GinkgoT().TempDir(), "ca.crt")
+		ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		Expect(os.WriteFile(caFile, ca, 0600)).To(Succeed())
+	})
+	AfterEach(func() {
+		SetCABundle(nil)
+		server.Close()
+	})
+
+	It("uses the system pool without CA bundle", func() {
+		pool, err := rootCAs()
+		Expect(err).To(BeNil())
+		Expect(pool).To(BeNil())
+
+		_, err = httpsGet(endpoint, true, nil)
+		Expect(err).To(MatchError(ContainSubstring("certificate")))
+		result, err := httpsGet(endpoint, false, nil)
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal("200 OK"))
+	})
+
+	It("verifies the server certificate with the CA bundle", func() {
+		for _, appendToSystemPool := range []bool{false, true} {
+			SetCABundle(&config.CABundleConfig{File: caFile, AppendToSystemPool: appendToSystemPool})
+			result, err := httpsGet(endpoint, true, nil)
+			Expect(err).To(BeNil(), "appendToSystemPool %t", appendToSystemPool)
+			Expect(result).To(Equal("200 OK"))
+		}
+	})
+
+	It("fails for an invalid CA bundle", func() {
+		SetCABundle(&config.CABundleConfig{File: caFile + ".missing"})
+		_, err := httpsGet(endpoint, true, nil)
+		Expect(err).To(MatchError(ContainSubstring("no such file")))
+
+		Expect(os.WriteFile(caFile, []byte("invalid"), 0600)).To(Succeed())
+		SetCABundle(&config.CABundleConfig{File: caFile})
+		_, err = httpsGet(endpoint, true, nil)
+		Expect(err).To(MatchError(ContainSubstring("no CA certificates found")))
+
+		_, err = httpsGet(endpoint, false, nil)
+		Expect(err).To(BeNil())
+	})
+
+	It("parses the verify flag", func() {
+		rconfig := RunnerConfig{Job: config.Job{JobID: "https-n2internal"}}
+		actual, err := Parse(config.ClusterConfig{}, rconfig, []string{"checkHTTPSGet", "--endpoints", "internal.example.com", "--verify"}, false)
+		Expect(err).To(BeNil())
+		Expect(actual.TestData()).To(Equal([]config.Endpoint{{Hostname: "internal.example.com", Port: 443}}))
+	})
+})
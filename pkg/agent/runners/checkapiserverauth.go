@@ -0,0 +1,221 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// CheckAPIServerAuthCmd is the runner command for checking authenticated requests to the kube-apiserver.
+	CheckAPIServerAuthCmd = "checkAPIServerAuth"
+	// DefaultServiceAccountTokenFile is the default path of the mounted service account token.
+	DefaultServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	// DefaultServiceAccountCAFile is the default path of the mounted CA certificate of the kube-apiserver.
+	DefaultServiceAccountCAFile = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	// FailureClassAuthNoToken is the failure class of API server auth checks if no service account token is mounted.
+	FailureClassAuthNoToken = "auth-no-token"
+	// FailureClassAuthRejected is the failure class of API server auth checks if the token is not accepted
+	// (401 Unauthorized), e.g. because the token review or an authentication webhook fails.
+	FailureClassAuthRejected = "auth-rejected"
+	// FailureClassAuthForbidden is the failure class of API server auth checks if the request is authenticated,
+	// but not authorized (403 Forbidden).
+	FailureClassAuthForbidden = "auth-forbidden"
+	// FailureClassAuthTimeout is the failure class of API server auth checks if the connection has been established,
+	// but there is no response within the timeout, e.g. because of a slow authentication webhook.
+	FailureClassAuthTimeout = "auth-timeout"
+	// apiServerAuthTimeout is the timeout of a single API server auth check
+	apiServerAuthTimeout = 10 * time.Second
+)
+
+// apiServerAuthPaths are the paths accessible for all authenticated users.
+var apiServerAuthPaths = []string{"/version", "/healthz", "/livez", "/readyz"}
+
+type checkAPIServerAuthArgs struct {
+	runnerArgs   *runnerArgs
+	externalKAPI bool
+	path         string
+	tokenFile    string
+	caFile       string
+}
+
+func (a *checkAPIServerAuthArgs) createRunner(cmd *cobra.Command, args []string) error {
+	paths := common.StringSet{}
+	paths.AddAll(apiServerAuthPaths...)
+	if !paths.Contains(a.path) {
+		return fmt.Errorf("invalid path %q: must be one of %s", a.path, strings.Join(apiServerAuthPaths, ", "))
+	}
+	if a.tokenFile == "" {
+		return fmt.Errorf("missing token file")
+	}
+
+	var endpoints []config.Endpoint
+	if a.externalKAPI {
+		if pe := a.runnerArgs.clusterCfg.KubeAPIServer; pe != nil {
+			endpoints = append(endpoints, *pe)
+		}
+	} else {
+		endpoints = append(endpoints, config.Endpoint{
+			Hostname: common.DomainNameKubernetesService,
+			Port:     443,
+		})
+	}
+
+	config := a.runnerArgs.prepareConfig()
+	if r := NewCheckAPIServerAuth(endpoints, a.path, a.tokenFile, a.caFile, config); r != nil {
+		a.runnerArgs.runner = r
+	}
+	return nil
+}
+
+func createCheckAPIServerAuthCmd(ra *runnerArgs) *cobra.Command {
+	a := &checkAPIServerAuthArgs{runnerArgs: ra}
+	cmd := &cobra.Command{
+		Use:   CheckAPIServerAuthCmd,
+		Short: "performs an authenticated request with the service account token of the agent to the kube-apiserver",
+		RunE:  a.createRunner,
+	}
+	cmd.Flags().BoolVar(&a.externalKAPI, "endpoint-external-kube-apiserver", false, "uses known external endpoint of kube-apiserver instead of the internal one.")
+	cmd.Flags().StringVar(&a.path, "path", "/version", fmt.Sprintf("path of the request (one of %s).", strings.Join(apiServerAuthPaths, ", ")))
+	cmd.Flags().StringVar(&a.tokenFile, "token-file", DefaultServiceAccountTokenFile, "file of the service account token, read on each check.")
+	cmd.Flags().StringVar(&a.caFile, "ca-file", DefaultServiceAccountCAFile, "file of the CA certificate of the kube-apiserver, added to the CA bundle of the agent config if set (empty to skip the verification of the server certificate).")
+	return cmd
+}
+
+func NewCheckAPIServerAuth(endpoints []config.Endpoint, path, tokenFile, caFile string, rconfig RunnerConfig) *checkAPIServerAuth {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	var dialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	var resolvedHost func(endpoint config.Endpoint) string
+	if !rconfig.BypassResolverCache {
+		dialContext = cachedDialContext(nil)
+		resolvedHost = func(endpoint config.Endpoint) string {
+			return endpoint.Hostname
+		}
+	}
+	check := &apiServerAuthCheck{
+		path:        path,
+		tokenFile:   tokenFile,
+		caFile:      caFile,
+		timeout:     apiServerAuthTimeout,
+		dialContext: dialContext,
+	}
+	return &checkAPIServerAuth{
+		robinRound[config.Endpoint]{
+			itemsName:    "endpoints",
+			protocol:     nwpd.ProtocolHTTPS,
+			items:        config.CloneAndShuffle(endpoints),
+			runFunc:      check.run,
+			config:       rconfig,
+			resolvedHost: resolvedHost,
+		},
+	}
+}
+
+type checkAPIServerAuth struct {
+	robinRound[config.Endpoint]
+}
+
+var _ Runner = &checkAPIServerAuth{}
+
+// apiServerAuthCheck sends a request authenticated with the service account token to the kube-apiserver.
+// Failures of the authentication layer are reported with distinct failure classes.
+type apiServerAuthCheck struct {
+	path        string
+	tokenFile   string
+	caFile      string
+	timeout     time.Duration
+	dialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+func (c *apiServerAuthCheck) run(endpoint config.Endpoint) (string, error) {
+	token, err := os.ReadFile(c.tokenFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = fmt.Errorf("%s not found, is the service account token mounted into the pod?", c.tokenFile)
+		}
+		return "", &classifiedError{class: FailureClassAuthNoToken, err: err}
+	}
+	hostname := strings.TrimSuffix(endpoint.Hostname, ".")
+	tlsConfig := &tls.Config{ServerName: hostname, InsecureSkipVerify: c.caFile == ""}
+	if c.caFile != "" {
+		// the CA of the kube-apiserver is added to the CA bundle of the agent config, if set
+		if tlsConfig.RootCAs, err = rootCAs(c.caFile); err != nil {
+			return "", err
+		}
+	}
+	client := &http.Client{
+		Timeout: c.timeout,
+		Transport: &http.Transport{
+			TLSClientConfig:   tlsConfig,
+			DialContext:       c.dialContext,
+			DisableKeepAlives: true,
+		},
+	}
+
+	var connected atomic.Value
+	trace := &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) { connected.Store(true) },
+	}
+	url := fmt.Sprintf("https://%s%s", net.JoinHostPort(hostname, strconv.Itoa(endpoint.Port)), c.path)
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	resp, err := client.Do(req)
+	if err != nil {
+		var netErr net.Error
+		if ok, _ := connected.Load().(bool); ok && errors.As(err, &netErr) && netErr.Timeout() {
+			return "", &classifiedError{class: FailureClassAuthTimeout, err: fmt.Errorf("connected, but no response within %s", c.timeout)}
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return fmt.Sprintf("%s: %s", c.path, apiServerAuthResult(c.path, body)), nil
+	case http.StatusUnauthorized:
+		return "", &classifiedError{class: FailureClassAuthRejected, err: fmt.Errorf("%s: %s", c.path, resp.Status)}
+	case http.StatusForbidden:
+		return "", &classifiedError{class: FailureClassAuthForbidden, err: fmt.Errorf("%s: %s", c.path, resp.Status)}
+	default:
+		return "", fmt.Errorf("%s: %s %s", c.path, resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+// apiServerAuthResult returns the version of the kube-apiserver for path /version and the body otherwise.
+func apiServerAuthResult(path string, body []byte) string {
+	if path == "/version" {
+		version := struct {
+			GitVersion string `json:"gitVersion"`
+		}{}
+		if err := json.Unmarshal(body, &version); err == nil && version.GitVersion != "" {
+			return version.GitVersion
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
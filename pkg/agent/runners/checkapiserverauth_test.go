@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("checkAPIServerAuth", func() {
+	var (
+		rconfig  = RunnerConfig{Job: config.Job{JobID: "auth-p2api-int", BypassResolverCache: true}, Period: time.Minute}
+		dir      string
+		server   *httptest.Server
+		endpoint config.Endpoint
+		check    *apiServerAuthCheck
+		lock     sync.Mutex
+		status   int
+		delay    time.Duration
+		respond  = func(s int, d time.Duration) {
+			lock.Lock()
+			defer lock.Unlock()
+			status, delay = s, d
+		}
+	)
+
+	BeforeEach(func() {
+		respond(http.StatusOK, 0)
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			status, delay := status, delay
+			lock.Unlock()
+			time.Sleep(delay)
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(`{"major": "1", "minor": "24", "gitVersion": "v1.24.3"}`))
+		}))
+		dir = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "token"), []byte("secret\n"), 0600)).To(Succeed())
+		ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		Expect(os.WriteFile(filepath.Join(dir, "ca.crt"), ca, 0600)).To(Succeed())
+		addr := server.Listener.Addr().(*net.TCPAddr)
+		endpoint = config.Endpoint{Hostname: addr.IP.String(), Port: addr.Port}
+		check = &apiServerAuthCheck{
+			path:      "/version",
+			tokenFile: filepath.Join(dir, "token"),
+			caFile:    filepath.Join(dir, "ca.crt"),
+			timeout:   time.Second,
+		}
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("parses the arguments", func() {
+		actual, err := Parse(config.ClusterConfig{}, rconfig, []string{CheckAPIServerAuthCmd}, false)
+		Expect(err).To(BeNil())
+		Expect(actual.TestData()).To(Equal([]config.Endpoint{{Hostname: common.DomainNameKubernetesService, Port: 443}}))
+		Expect(actual.Config().Job.JobID).To(Equal("auth-p2api-int"))
+
+		actual, err = Parse(config.ClusterConfig{}, rconfig, []string{CheckAPIServerAuthCmd, "--endpoint-external-kube-apiserver"}, false)
+		Expect(err).To(BeNil())
+		Expect(actual).To(BeNil())
+		kapi := &config.Endpoint{Hostname: "api.example.com", IP: "1.2.3.4", Port: 443}
+		actual, err = Parse(config.ClusterConfig{KubeAPIServer: kapi}, rconfig, []string{CheckAPIServerAuthCmd, "--endpoint-external-kube-apiserver", "--path", "/readyz"}, false)
+		Expect(err).To(BeNil())
+		Expect(actual.TestData()).To(Equal([]config.Endpoint{*kapi}))
+
+		_, err = Parse(config.ClusterConfig{}, rconfig, []string{CheckAPIServerAuthCmd, "--path", "/api"}, false)
+		Expect(err).To(MatchError(ContainSubstring(`invalid path "/api"`)))
+	})
+
+	It("succeeds for an authenticated request", func() {
+		result, err := check.run(endpoint)
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal("/version: v1.24.3"))
+
+		check.path = "/healthz"
+		result, err = check.run(endpoint)
+		Expect(err).To(BeNil())
+		Expect(result).To(HavePrefix("/healthz: {"))
+	})
+
+	It("verifies the server certificate", func() {
+		// the certificate of the test server is not valid for localhost
+		_, err := check.run(config.Endpoint{Hostname: "localhost", Port: endpoint.Port})
+		Expect(err).To(MatchError(ContainSubstring("certificate")))
+
+		check.caFile = ""
+		_, err = check.run(config.Endpoint{Hostname: "localhost", Port: endpoint.Port})
+		Expect(err).To(BeNil())
+	})
+
+	It("records auth-layer failures with distinct failure classes", func() {
+		expectClass := func(class string) {
+			_, err := check.run(endpoint)
+			Expect(err).To(BeAssignableToTypeOf(&classifiedError{}))
+			Expect(err.(*classifiedError).class).To(Equal(class), err.Error())
+		}
+
+		Expect(os.WriteFile(filepath.Join(dir, "token"), []byte("expired"), 0600)).To(Succeed())
+		expectClass(FailureClassAuthRejected)
+
+		Expect(os.WriteFile(filepath.Join(dir, "token"), []byte("secret"), 0600)).To(Succeed())
+		respond(http.StatusForbidden, 0)
+		expectClass(FailureClassAuthForbidden)
+
+		respond(http.StatusOK, 2*check.timeout)
+		expectClass(FailureClassAuthTimeout)
+		respond(http.StatusOK, 0)
+
+		Expect(os.Remove(filepath.Join(dir, "token"))).To(Succeed())
+		expectClass(FailureClassAuthNoToken)
+	})
+
+	It("does not classify network failures", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		port := listener.Addr().(*net.TCPAddr).Port
+		listener.Close()
+		_, err = check.run(config.Endpoint{Hostname: "127.0.0.1", Port: port})
+		Expect(err).NotTo(BeNil())
+		Expect(err).NotTo(BeAssignableToTypeOf(&classifiedError{}))
+	})
+})
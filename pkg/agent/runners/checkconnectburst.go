@@ -0,0 +1,153 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// MaxConnectBurstParallel is the upper bound for the number of simultaneous connections of a burst
+	MaxConnectBurstParallel = 100
+	// MaxConnectBurstDeadline is the upper bound for the deadline of a burst
+	MaxConnectBurstDeadline = 30 * time.Second
+)
+
+type checkConnectBurstArgs struct {
+	runnerArgs *runnerArgs
+	tcpEndpointArgs
+	parallel int
+	deadline time.Duration
+}
+
+func (a *checkConnectBurstArgs) createRunner(cmd *cobra.Command, args []string) error {
+	if a.parallel < 1 || a.parallel > MaxConnectBurstParallel {
+		return fmt.Errorf("invalid parallel %d: must be in range 1..%d", a.parallel, MaxConnectBurstParallel)
+	}
+	if a.deadline <= 0 || a.deadline > MaxConnectBurstDeadline {
+		return fmt.Errorf("invalid deadline %s: must be in range (0s..%s]", a.deadline, MaxConnectBurstDeadline)
+	}
+	endpoints, err := a.selectEndpoints(a.runnerArgs.clusterCfg)
+	if err != nil {
+		return err
+	}
+
+	config := a.runnerArgs.prepareConfig()
+	if r := NewCheckConnectBurst(endpoints, a.parallel, a.deadline, config); r != nil {
+		r.setEndpointList(a.endpointList(a.runnerArgs.clusterCfg))
+		r.setDestAddressType(a.destAddressType())
+		r.setWarmUp(a.warmUp(a.runnerArgs.clusterCfg))
+		a.runnerArgs.runner = r
+	}
+	return nil
+}
+
+func createCheckConnectBurstCmd(ra *runnerArgs) *cobra.Command {
+	a := &checkConnectBurstArgs{runnerArgs: ra}
+	cmd := &cobra.Command{
+		Use:   "checkConnectBurst",
+		Short: "opens many simultaneous connections to TCP port and checks if all succeed within a deadline",
+		RunE:  a.createRunner,
+	}
+	a.addFlags(cmd)
+	cmd.Flags().IntVar(&a.parallel, "parallel", 20, fmt.Sprintf("number of simultaneous connections (maximum %d).", MaxConnectBurstParallel))
+	cmd.Flags().DurationVar(&a.deadline, "deadline", 5*time.Second, fmt.Sprintf("deadline for all connections of a burst (maximum %s).", MaxConnectBurstDeadline))
+	return cmd
+}
+
+func NewCheckConnectBurst(endpoints []config.Endpoint, parallel int, deadline time.Duration, rconfig RunnerConfig) *checkConnectBurst {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	r := &checkConnectBurst{
+		robinRound: robinRound[config.Endpoint]{
+			itemsName: "endpoints",
+			protocol:  nwpd.ProtocolTCP,
+			items:     config.CloneAndShuffle(endpoints),
+			runFunc: func(endpoint config.Endpoint) (string, error) {
+				return checkConnectBurstFunc(endpoint, parallel, deadline)
+			},
+			config: rconfig,
+		},
+		parallel: parallel,
+		deadline: deadline,
+	}
+	if !rconfig.BypassResolverCache {
+		r.resolvedHost = endpointIPHost
+		r.resolve = resolveEndpointIP
+	}
+	return r
+}
+
+type checkConnectBurst struct {
+	robinRound[config.Endpoint]
+	parallel int
+	deadline time.Duration
+}
+
+var _ Runner = &checkConnectBurst{}
+
+func (r *checkConnectBurst) Description() string {
+	return fmt.Sprintf("%s, %d parallel connections within %s", r.robinRound.Description(), r.parallel, r.deadline)
+}
+
+// CostWeight returns the number of simultaneous connections of a burst.
+func (r *checkConnectBurst) CostWeight() float64 {
+	return float64(r.parallel)
+}
+
+func (r *checkConnectBurst) TestData() any {
+	return []any{r.items, r.parallel, r.deadline}
+}
+
+// checkConnectBurstFunc dials all connections at once and keeps the established ones open until the burst is complete,
+// so that they are really simultaneous in the accept queue of the destination.
+func checkConnectBurstFunc(endpoint config.Endpoint, parallel int, deadline time.Duration) (string, error) {
+	addr := net.JoinHostPort(endpoint.IP, strconv.Itoa(endpoint.Port))
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		lock     sync.Mutex
+		conns    []net.Conn
+		firstErr error
+	)
+	dialer := &net.Dialer{}
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := dialer.DialContext(ctx, "tcp", addr)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			conns = append(conns, conn)
+		}()
+	}
+	wg.Wait()
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	if len(conns) < parallel {
+		return "", fmt.Errorf("%d/%d connections within %s (first error: %s)", len(conns), parallel, deadline, firstErr)
+	}
+	return fmt.Sprintf("connected %d/%d", len(conns), parallel), nil
+}
@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("checkConnectBurst", func() {
+	It("connects all in parallel", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		defer listener.Close()
+		endpoint := config.Endpoint{Hostname: "local", IP: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port}
+
+		result, err := checkConnectBurstFunc(endpoint, 10, 5*time.Second)
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal("connected 10/10"))
+	})
+
+	It("fails if connections are refused", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		endpoint := config.Endpoint{Hostname: "local", IP: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port}
+		listener.Close()
+
+		_, err = checkConnectBurstFunc(endpoint, 5, 1*time.Second)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(HavePrefix("0/5 connections within 1s"))
+	})
+
+	It("weights the cost of the job with the number of connections", func() {
+		endpoints := []config.Endpoint{{Hostname: "node1", IP: "10.0.0.11", Port: 1011}}
+		runner := NewCheckConnectBurst(endpoints, 20, 5*time.Second, RunnerConfig{Job: config.Job{JobID: "burst"}})
+		Expect(NewInternalJob(context.Background(), runner).CostWeight()).To(Equal(20.0))
+	})
+})
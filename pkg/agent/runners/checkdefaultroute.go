@@ -0,0 +1,299 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// CheckDefaultRouteCmd is the runner command for checking the default route and the reachability of its gateway.
+	CheckDefaultRouteCmd = "checkDefaultRoute"
+	// FailureClassNoDefaultRoute is the failure class of default route checks if there is no default route.
+	FailureClassNoDefaultRoute = "no-default-route"
+	// FailureClassGatewayUnreachable is the failure class of default route checks if the gateway does neither answer
+	// pings nor is its link layer address resolved.
+	FailureClassGatewayUnreachable = "gateway-unreachable"
+
+	// flags of the routes in /proc/net/route and /proc/net/ipv6_route
+	routeFlagUp      = 0x0001
+	routeFlagGateway = 0x0002
+	routeFlagReject  = 0x0200
+	// arpFlagComplete is the flag of a resolved entry in /proc/net/arp
+	arpFlagComplete = 0x2
+)
+
+type checkDefaultRouteArgs struct {
+	runnerArgs *runnerArgs
+	family     string
+}
+
+func (a *checkDefaultRouteArgs) createRunner(cmd *cobra.Command, args []string) error {
+	ipv6 := false
+	switch a.family {
+	case "":
+		podIP := net.ParseIP(os.Getenv(common.EnvPodIP))
+		ipv6 = podIP != nil && podIP.To4() == nil
+	case "ipv4":
+	case "ipv6":
+		ipv6 = true
+	default:
+		return fmt.Errorf("invalid family %q: must be 'ipv4' or 'ipv6'", a.family)
+	}
+	a.runnerArgs.runner = NewCheckDefaultRoute(ipv6, a.runnerArgs.prepareConfig())
+	return nil
+}
+
+func createCheckDefaultRouteCmd(ra *runnerArgs) *cobra.Command {
+	a := &checkDefaultRouteArgs{runnerArgs: ra}
+	cmd := &cobra.Command{
+		Use:   CheckDefaultRouteCmd,
+		Short: "checks the default route of the routing table and the reachability of its gateway",
+		RunE:  a.createRunner,
+	}
+	cmd.Flags().StringVar(&a.family, "family", "", "address family of the default route: 'ipv4' or 'ipv6' (default: family of the pod IP).")
+	return cmd
+}
+
+// NewCheckDefaultRoute creates a runner checking the IPv4 or IPv6 default route.
+func NewCheckDefaultRoute(ipv6 bool, rconfig RunnerConfig) *checkDefaultRoute {
+	return &checkDefaultRoute{
+		config: rconfig,
+		ipv6:   ipv6,
+		root:   "/",
+		ping: func(ip string) (string, error) {
+			return pingFunc(config.Node{Hostname: ip, InternalIP: ip})
+		},
+	}
+}
+
+// checkDefaultRoute inspects the routing table for the default route and checks if its gateway is reachable.
+// As some CNI plugins use gateways which only answer ARP requests (e.g. 169.254.1.1 of Calico), an IPv4 gateway
+// without ping reply is reachable if its link layer address is resolved.
+type checkDefaultRoute struct {
+	config RunnerConfig
+	ipv6   bool
+	// root is the root directory of /proc, replaced in tests
+	root string
+	// ping pings the gateway, replaced in tests
+	ping func(ip string) (string, error)
+}
+
+var _ Runner = &checkDefaultRoute{}
+
+func (r *checkDefaultRoute) Config() RunnerConfig {
+	return r.config
+}
+
+func (r *checkDefaultRoute) Description() string {
+	if r.ipv6 {
+		return "IPv6 default route"
+	}
+	return "IPv4 default route"
+}
+
+func (r *checkDefaultRoute) TestData() any {
+	return r.Description()
+}
+
+func (r *checkDefaultRoute) DestHosts() []string {
+	return []string{common.DestHostDefaultGateway}
+}
+
+// defaultRoute is the default route of the routing table.
+type defaultRoute struct {
+	iface string
+	// gateway is nil if the route has no gateway, e.g. for point-to-point links
+	gateway net.IP
+	metric  uint64
+}
+
+func (r *checkDefaultRoute) Run(ctx context.Context, ch chan<- *nwpd.Observation) {
+	if ctx.Err() != nil {
+		return
+	}
+	start := time.Now()
+	obs := &nwpd.Observation{
+		SrcHost:   GetNodeName(),
+		DestHost:  common.DestHostDefaultGateway,
+		Timestamp: timestamppb.New(start),
+		JobID:     r.config.JobID,
+		Labels:    r.config.Labels,
+		Protocol:  nwpd.ProtocolRoute,
+		Period:    durationpb.New(r.config.Period),
+	}
+	result, class, err := r.check(obs)
+	obs.Duration = durationpb.New(time.Since(start))
+	obs.Ok = err == nil
+	obs.FailureClass = class
+	if err != nil {
+		obs.Result = fmt.Sprintf("error: %s", err)
+	} else {
+		obs.Result = result
+	}
+	sendObservation(ctx, ch, obs)
+}
+
+// check looks up the default route and checks its gateway. The gateway is recorded as destination IP of the observation.
+func (r *checkDefaultRoute) check(obs *nwpd.Observation) (string, string, error) {
+	var (
+		route *defaultRoute
+		err   error
+	)
+	if r.ipv6 {
+		route, err = readIPv6DefaultRoute(filepath.Join(r.root, "proc/net/ipv6_route"))
+	} else {
+		route, err = readIPv4DefaultRoute(filepath.Join(r.root, "proc/net/route"))
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if route == nil {
+		return "", FailureClassNoDefaultRoute, fmt.Errorf("no %s", r.Description())
+	}
+	if route.gateway == nil {
+		return fmt.Sprintf("default route dev %s without gateway", route.iface), "", nil
+	}
+	gateway := route.gateway.String()
+	obs.DestIP = gateway
+	prefix := fmt.Sprintf("default route via %s dev %s", gateway, route.iface)
+	target := gateway
+	if route.gateway.IsLinkLocalUnicast() && r.ipv6 {
+		// link local addresses need the zone
+		target += "%" + route.iface
+	}
+	pingResult, pingErr := r.ping(target)
+	if pingErr == nil {
+		return fmt.Sprintf("%s, gateway reachable: %s", prefix, strings.TrimSpace(pingResult)), "", nil
+	}
+	if !r.ipv6 {
+		resolved, err := isNeighborResolved(filepath.Join(r.root, "proc/net/arp"), gateway, route.iface)
+		if err == nil && resolved {
+			return fmt.Sprintf("%s, gateway neighbor resolved (no ping reply: %s)", prefix, pingErr), "", nil
+		}
+	}
+	return "", FailureClassGatewayUnreachable, fmt.Errorf("%s, gateway unreachable: %s", prefix, pingErr)
+}
+
+// readIPv4DefaultRoute returns the default route with the lowest metric from /proc/net/route, nil if there is none.
+func readIPv4DefaultRoute(filename string) (*defaultRoute, error) {
+	var best *defaultRoute
+	err := scanFields(filename, func(fields []string) error {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		if len(fields) < 8 || fields[0] == "Iface" || fields[1] != "00000000" || fields[7] != "00000000" {
+			return nil
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil {
+			return fmt.Errorf("invalid route flags %q in %s", fields[3], filename)
+		}
+		metric, err := strconv.ParseUint(fields[6], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid route metric %q in %s", fields[6], filename)
+		}
+		if flags&routeFlagUp == 0 || flags&routeFlagReject != 0 {
+			return nil
+		}
+		route := &defaultRoute{iface: fields[0], metric: metric}
+		if flags&routeFlagGateway != 0 {
+			gw, err := strconv.ParseUint(fields[2], 16, 32)
+			if err != nil {
+				return fmt.Errorf("invalid gateway %q in %s", fields[2], filename)
+			}
+			// the addresses are in host byte order, which is little endian on all supported architectures
+			route.gateway = make(net.IP, net.IPv4len)
+			binary.LittleEndian.PutUint32(route.gateway, uint32(gw))
+		}
+		if best == nil || route.metric < best.metric {
+			best = route
+		}
+		return nil
+	})
+	return best, err
+}
+
+// readIPv6DefaultRoute returns the default route with the lowest metric from /proc/net/ipv6_route, nil if there is none.
+func readIPv6DefaultRoute(filename string) (*defaultRoute, error) {
+	var best *defaultRoute
+	err := scanFields(filename, func(fields []string) error {
+		// Destination PrefixLen Source PrefixLen NextHop Metric RefCnt Use Flags Iface
+		if len(fields) < 10 || fields[0] != strings.Repeat("0", 32) || fields[1] != "00" {
+			return nil
+		}
+		flags, err := strconv.ParseUint(fields[8], 16, 32)
+		if err != nil {
+			return fmt.Errorf("invalid route flags %q in %s", fields[8], filename)
+		}
+		metric, err := strconv.ParseUint(fields[5], 16, 32)
+		if err != nil {
+			return fmt.Errorf("invalid route metric %q in %s", fields[5], filename)
+		}
+		if flags&routeFlagUp == 0 || flags&routeFlagReject != 0 || fields[9] == "lo" {
+			return nil
+		}
+		route := &defaultRoute{iface: fields[9], metric: metric}
+		if flags&routeFlagGateway != 0 {
+			gw, err := hex.DecodeString(fields[4])
+			if err != nil || len(gw) != net.IPv6len {
+				return fmt.Errorf("invalid gateway %q in %s", fields[4], filename)
+			}
+			route.gateway = gw
+		}
+		if best == nil || route.metric < best.metric {
+			best = route
+		}
+		return nil
+	})
+	return best, err
+}
+
+// scanFields calls the function with the fields of each line of the file.
+func scanFields(filename string, f func(fields []string) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if err := f(strings.Fields(scanner.Text())); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// isNeighborResolved returns true if /proc/net/arp contains a resolved entry of the IP address on the interface.
+func isNeighborResolved(filename, ip, iface string) (bool, error) {
+	resolved := false
+	err := scanFields(filename, func(fields []string) error {
+		// IP-address HW-type Flags HW-address Mask Device
+		if len(fields) < 6 || fields[0] != ip || fields[5] != iface {
+			return nil
+		}
+		if flags, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32); err == nil && flags&arpFlagComplete != 0 {
+			resolved = true
+		}
+		return nil
+	})
+	return resolved, err
+}
@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("checkDefaultRoute", func() {
+	const (
+		routeHeader = "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"
+		// default route via 169.254.1.1 dev eth0
+		calicoRoutes = routeHeader +
+			"eth0\t00000000\t0101FEA9\t0003\t0\t0\t0\t00000000\t0\t0\t0\n" +
+			"eth0\t0101FEA9\t00000000\t0005\t0\t0\t0\tFFFFFFFF\t0\t0\t0\n"
+		arpHeader = "IP address       HW type     Flags       HW address            Mask     Device\n"
+	)
+	var (
+		rconfig = RunnerConfig{Job: config.Job{JobID: "route-p"}}
+		root    string
+	)
+
+	write := func(name, content string) {
+		Expect(os.MkdirAll(filepath.Join(root, "proc/net"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(root, "proc/net", name), []byte(content), 0644)).To(Succeed())
+	}
+	run := func(ipv6 bool, pingErr error) *nwpd.Observation {
+		r := NewCheckDefaultRoute(ipv6, rconfig)
+		r.root = root
+		r.ping = func(ip string) (string, error) {
+			if pingErr != nil {
+				return "", pingErr
+			}
+			return fmt.Sprintf("ICMPv4: 24 bytes from %s: icmp_seq=0 time=1ms\n", ip), nil
+		}
+		ch := make(chan *nwpd.Observation, 1)
+		r.Run(context.Background(), ch)
+		obs := <-ch
+		Expect(obs.DestHost).To(Equal(common.DestHostDefaultGateway))
+		Expect(obs.Protocol).To(Equal(nwpd.ProtocolRoute))
+		return obs
+	}
+
+	BeforeEach(func() {
+		var err error
+		root, err = os.MkdirTemp("", "route")
+		Expect(err).To(BeNil())
+	})
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	It("parses the arguments", func() {
+		actual, err := Parse(config.ClusterConfig{}, rconfig, []string{CheckDefaultRouteCmd, "--family", "ipv6"}, false)
+		Expect(err).To(BeNil())
+		Expect(actual.TestData()).To(Equal("IPv6 default route"))
+
+		_, err = Parse(config.ClusterConfig{}, rconfig, []string{CheckDefaultRouteCmd, "--family", "ip4"}, false)
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("records the gateway answering pings", func() {
+		write("route", calicoRoutes)
+		obs := run(false, nil)
+		Expect(obs.Ok).To(BeTrue(), obs.Result)
+		Expect(obs.DestIP).To(Equal("169.254.1.1"))
+		Expect(obs.Result).To(Equal("default route via 169.254.1.1 dev eth0, gateway reachable: ICMPv4: 24 bytes from 169.254.1.1: icmp_seq=0 time=1ms"))
+	})
+
+	It("accepts a resolved neighbor of a gateway without ping reply", func() {
+		write("route", calicoRoutes)
+		write("arp", arpHeader+"169.254.1.1      0x1         0x2         ee:ee:ee:ee:ee:ee     *        eth0\n")
+		obs := run(false, fmt.Errorf("ICMPv4: ping lost after 1000 ms"))
+		Expect(obs.Ok).To(BeTrue(), obs.Result)
+		Expect(obs.Result).To(Equal("default route via 169.254.1.1 dev eth0, gateway neighbor resolved (no ping reply: ICMPv4: ping lost after 1000 ms)"))
+	})
+
+	It("fails for an unreachable gateway", func() {
+		write("route", calicoRoutes)
+		write("arp", arpHeader+"169.254.1.1      0x1         0x0         00:00:00:00:00:00     *        eth0\n")
+		obs := run(false, fmt.Errorf("ICMPv4: ping lost after 1000 ms"))
+		Expect(obs.Ok).To(BeFalse())
+		Expect(obs.FailureClass).To(Equal(FailureClassGatewayUnreachable))
+		Expect(obs.DestIP).To(Equal("169.254.1.1"))
+	})
+
+	It("fails without default route", func() {
+		write("route", routeHeader+"eth0\t0101FEA9\t00000000\t0005\t0\t0\t0\tFFFFFFFF\t0\t0\t0\n")
+		obs := run(false, nil)
+		Expect(obs.Ok).To(BeFalse())
+		Expect(obs.FailureClass).To(Equal(FailureClassNoDefaultRoute))
+		Expect(obs.Result).To(Equal("error: no IPv4 default route"))
+	})
+
+	It("selects the IPv6 default route with the lowest metric", func() {
+		write("ipv6_route",
+			"00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000002 00000400 00000001 00000000 00000003 eth1\n"+
+				"00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000100 00000001 00000000 00000003 eth0\n"+
+				"00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200 lo\n")
+		obs := run(true, nil)
+		Expect(obs.Ok).To(BeTrue(), obs.Result)
+		Expect(obs.DestIP).To(Equal("fe80::1"))
+		Expect(obs.Result).To(HavePrefix("default route via fe80::1 dev eth0, gateway reachable"))
+	})
+
+	It("reads the network info of the default routes", func() {
+		write("route", calicoRoutes)
+		addrs := func(iface string) ([]net.Addr, error) {
+			Expect(iface).To(Equal("eth0"))
+			return []net.Addr{
+				&net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)},
+				&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)},
+				&net.IPNet{IP: net.ParseIP("10.250.0.5").To4(), Mask: net.CIDRMask(16, 32)},
+			}, nil
+		}
+		info, err := readNetworkInfo(root, addrs)
+		Expect(err).To(BeNil())
+		Expect(info).To(HaveLen(1))
+		Expect(info[0].Family).To(Equal(FamilyIPv4))
+		Expect(info[0].Interface).To(Equal("eth0"))
+		Expect(info[0].Gateway).To(Equal("169.254.1.1"))
+		Expect(info[0].PrimaryIP).To(Equal("10.250.0.5"))
+		Expect(nwpd.FormatNetworkInfo(info)).To(Equal("ipv4 eth0 via 169.254.1.1 (10.250.0.5)"))
+		Expect(nwpd.FormatNetworkInfo(nil)).To(Equal("no default route"))
+	})
+})
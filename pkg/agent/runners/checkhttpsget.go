@@ -5,14 +5,16 @@
 package runners
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"github.com/gardener/network-problem-detector/pkg/common"
 	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
 	"github.com/spf13/cobra"
 )
 
@@ -21,6 +23,7 @@ type checkHTTPSGetArgs struct {
 	internalKAPI bool
 	externalKAPI bool
 	endpoints    []string
+	verify       bool
 }
 
 func (a *checkHTTPSGetArgs) createRunner(cmd *cobra.Command, args []string) error {
@@ -28,20 +31,20 @@ func (a *checkHTTPSGetArgs) createRunner(cmd *cobra.Command, args []string) erro
 	var endpoints []config.Endpoint
 	if len(a.endpoints) > 0 {
 		for _, ep := range a.endpoints {
-			parts := strings.SplitN(ep, ":", 2)
-			if len(parts) != 1 && len(parts) != 2 {
-				return fmt.Errorf("invalid endpoint %s", ep)
-			}
-			port := 443
-			if len(parts) == 2 {
-				var err error
-				port, err = strconv.Atoi(parts[1])
-				if err != nil {
-					return fmt.Errorf("invalid endpoint port %s", parts[1])
+			hostname, port := ep, 443
+			if host, portStr, err := net.SplitHostPort(ep); err == nil {
+				if port, err = config.ParsePort(portStr); err != nil {
+					return fmt.Errorf("invalid endpoint %q: %w", ep, err)
 				}
+				hostname = host
+			} else if strings.Contains(ep, ":") && net.ParseIP(ep) == nil {
+				return fmt.Errorf("invalid endpoint %q: expected format <hostname>[:<port>]", ep)
+			}
+			if hostname == "" {
+				return fmt.Errorf("invalid endpoint %q: missing hostname", ep)
 			}
 			endpoints = append(endpoints, config.Endpoint{
-				Hostname: parts[0],
+				Hostname: hostname,
 				IP:       "",
 				Port:     port,
 			})
@@ -64,7 +67,7 @@ func (a *checkHTTPSGetArgs) createRunner(cmd *cobra.Command, args []string) erro
 	}
 
 	config := a.runnerArgs.prepareConfig()
-	if r := NewCheckHTTPSGet(endpoints, config); r != nil {
+	if r := NewCheckHTTPSGet(endpoints, a.verify, config); r != nil {
 		a.runnerArgs.runner = r
 	}
 	return nil
@@ -80,19 +83,37 @@ func createCheckHTTPSGetArgs(ra *runnerArgs) *cobra.Command {
 	cmd.Flags().StringSliceVar(&a.endpoints, "endpoints", nil, "endpoints in format <hostname>[:<port>].")
 	cmd.Flags().BoolVar(&a.internalKAPI, "endpoint-internal-kube-apiserver", false, "uses known internal endpoint of kube-apiserver.")
 	cmd.Flags().BoolVar(&a.externalKAPI, "endpoint-external-kube-apiserver", false, "uses known external endpoint of kube-apiserver.")
+	cmd.Flags().BoolVar(&a.verify, "verify", false, "verifies the server certificate with the CA bundle of the agent config or the system pool.")
 	return cmd
 }
 
-func NewCheckHTTPSGet(endpoints []config.Endpoint, rconfig RunnerConfig) *checkHTTPSGet {
+func NewCheckHTTPSGet(endpoints []config.Endpoint, verify bool, rconfig RunnerConfig) *checkHTTPSGet {
 	if len(endpoints) == 0 {
 		return nil
 	}
+	var dialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	if rconfig.ReuseConnectionResult {
+		// only the TCP connection is shared, the HTTP request is always sent by the job itself
+		dialContext = sharedDialContext(rconfig.JobID, rconfig.Period)
+	}
+	var resolvedHost func(endpoint config.Endpoint) string
+	if !rconfig.BypassResolverCache {
+		// the URL keeps the host name for SNI, only the connection uses the cached address
+		dialContext = cachedDialContext(dialContext)
+		resolvedHost = func(endpoint config.Endpoint) string {
+			return endpoint.Hostname
+		}
+	}
 	return &checkHTTPSGet{
 		robinRound[config.Endpoint]{
 			itemsName: "endpoints",
+			protocol:  nwpd.ProtocolHTTPS,
 			items:     config.CloneAndShuffle(endpoints),
-			runFunc:   checkHTTPSGetFunc,
-			config:    rconfig,
+			runFunc: func(endpoint config.Endpoint) (string, error) {
+				return httpsGet(endpoint, verify, dialContext)
+			},
+			config:       rconfig,
+			resolvedHost: resolvedHost,
 		},
 	}
 }
@@ -103,9 +124,19 @@ type checkHTTPSGet struct {
 
 var _ Runner = &checkHTTPSGet{}
 
-func checkHTTPSGetFunc(endpoint config.Endpoint) (string, error) {
+// httpsGet sends the GET request using the dial function, or the default one if nil.
+// The server certificate is only verified if verify is set.
+func httpsGet(endpoint config.Endpoint, verify bool, dialContext func(ctx context.Context, network, addr string) (net.Conn, error)) (string, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: !verify}
+	if verify {
+		var err error
+		if tlsConfig.RootCAs, err = rootCAs(); err != nil {
+			return "", err
+		}
+	}
 	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		TLSClientConfig: tlsConfig,
+		DialContext:     dialContext,
 	}
 	client := &http.Client{Transport: tr}
 	url := fmt.Sprintf("https://%s:%d", endpoint.Hostname, endpoint.Port)
@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// DefaultKubeletHealthzPort is the default port of the kubelet healthz endpoint on localhost (plain HTTP)
+	DefaultKubeletHealthzPort = 10248
+	// DefaultKubeletAPIPort is the default port of the kubelet API (HTTPS)
+	DefaultKubeletAPIPort = 10250
+	// kubeletTimeout is the timeout of a single kubelet check
+	kubeletTimeout = 10 * time.Second
+)
+
+type checkKubeletArgs struct {
+	runnerArgs  *runnerArgs
+	healthzPort int
+	apiPort     int
+}
+
+func (a *checkKubeletArgs) createRunner(cmd *cobra.Command, args []string) error {
+	if a.healthzPort < 0 || a.healthzPort > 65535 || a.apiPort < 0 || a.apiPort > 65535 {
+		return fmt.Errorf("invalid ports: must be in range 0..65535")
+	}
+	if a.healthzPort == 0 && a.apiPort == 0 {
+		return fmt.Errorf("healthz port and API port are both disabled")
+	}
+
+	nodeName := GetNodeName()
+	var endpoints []kubeletEndpoint
+	if a.healthzPort != 0 {
+		endpoints = append(endpoints, kubeletEndpoint{
+			Endpoint: config.Endpoint{Hostname: nodeName, IP: "127.0.0.1", Port: a.healthzPort},
+			local:    true,
+		})
+	}
+	if a.apiPort != 0 {
+		for _, n := range a.runnerArgs.clusterCfg.Nodes {
+			if n.Hostname == nodeName {
+				continue
+			}
+			endpoints = append(endpoints, kubeletEndpoint{
+				Endpoint: config.Endpoint{Hostname: n.Hostname, IP: n.InternalIP, Port: a.apiPort},
+			})
+		}
+	}
+
+	config := a.runnerArgs.prepareConfig()
+	if r := NewCheckKubelet(endpoints, config); r != nil {
+		a.runnerArgs.runner = r
+	}
+	return nil
+}
+
+func createCheckKubeletCmd(ra *runnerArgs) *cobra.Command {
+	a := &checkKubeletArgs{runnerArgs: ra}
+	cmd := &cobra.Command{
+		Use:   "checkKubelet",
+		Short: "checks the healthz endpoint of the local kubelet and the reachability of the kubelet API of the other nodes",
+		RunE:  a.createRunner,
+	}
+	cmd.Flags().IntVar(&a.healthzPort, "healthz-port", DefaultKubeletHealthzPort, "port of the kubelet healthz endpoint on localhost (0 to disable the local check).")
+	cmd.Flags().IntVar(&a.apiPort, "api-port", DefaultKubeletAPIPort, "port of the kubelet API on the other nodes (0 to disable the peer checks).")
+	return cmd
+}
+
+// kubeletEndpoint is the local healthz endpoint or the API endpoint of the kubelet of another node.
+type kubeletEndpoint struct {
+	config.Endpoint
+	// local is true for the healthz endpoint of the kubelet on the own node
+	local bool
+}
+
+func NewCheckKubelet(endpoints []kubeletEndpoint, rconfig RunnerConfig) *checkKubelet {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	return &checkKubelet{
+		robinRound[kubeletEndpoint]{
+			itemsName: "kubelet endpoints",
+			protocol:  nwpd.ProtocolKubelet,
+			items:     config.CloneAndShuffle(endpoints),
+			runFunc:   checkKubeletFunc,
+			config:    rconfig,
+		},
+	}
+}
+
+type checkKubelet struct {
+	robinRound[kubeletEndpoint]
+}
+
+var _ Runner = &checkKubelet{}
+
+func checkKubeletFunc(endpoint kubeletEndpoint) (string, error) {
+	if endpoint.local {
+		return kubeletHealthz(endpoint.Endpoint)
+	}
+	return kubeletAPI(endpoint.Endpoint)
+}
+
+// kubeletHealthz checks the healthz endpoint of the local kubelet. It succeeds if the kubelet reports 'ok'.
+func kubeletHealthz(endpoint config.Endpoint) (string, error) {
+	client := &http.Client{Timeout: kubeletTimeout}
+	resp, err := client.Get(fmt.Sprintf("http://%s/healthz", net.JoinHostPort(endpoint.IP, strconv.Itoa(endpoint.Port))))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("healthz: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return fmt.Sprintf("healthz: %s", strings.TrimSpace(string(body))), nil
+}
+
+// kubeletAPI checks the reachability of the kubelet API of another node. As the agent has no credentials for the kubelet,
+// any HTTP response (typically '401 Unauthorized') proves that the kubelet is reachable and serving.
+func kubeletAPI(endpoint config.Endpoint) (string, error) {
+	client := &http.Client{
+		Timeout:   kubeletTimeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	resp, err := client.Get(fmt.Sprintf("https://%s/healthz", net.JoinHostPort(endpoint.IP, strconv.Itoa(endpoint.Port))))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	return fmt.Sprintf("api: %s", resp.Status), nil
+}
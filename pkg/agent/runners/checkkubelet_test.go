@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("checkKubelet", func() {
+	var (
+		rconfig    = RunnerConfig{Job: config.Job{JobID: "kubelet"}, Period: 15 * time.Second}
+		clusterCfg = config.ClusterConfig{
+			Nodes: []config.Node{
+				{Hostname: "node1", InternalIP: "10.0.0.11"},
+				{Hostname: "node2", InternalIP: "10.0.0.12"},
+			},
+		}
+		serverEndpoint = func(server *httptest.Server) config.Endpoint {
+			addr := server.Listener.Addr().(*net.TCPAddr)
+			return config.Endpoint{Hostname: "node", IP: addr.IP.String(), Port: addr.Port}
+		}
+	)
+
+	var (
+		oldNodeName string
+		hasNodeName bool
+	)
+	BeforeEach(func() {
+		oldNodeName, hasNodeName = os.LookupEnv(common.EnvNodeName)
+		Expect(os.Setenv(common.EnvNodeName, "node1")).To(Succeed())
+	})
+	AfterEach(func() {
+		if hasNodeName {
+			os.Setenv(common.EnvNodeName, oldNodeName)
+		} else {
+			os.Unsetenv(common.EnvNodeName)
+		}
+	})
+
+	It("checks the local healthz endpoint and the API of the other nodes", func() {
+		actual, err := Parse(clusterCfg, rconfig, []string{"checkKubelet"}, false)
+		Expect(err).To(BeNil())
+		Expect(actual.TestData()).To(Equal([]kubeletEndpoint{
+			{Endpoint: config.Endpoint{Hostname: "node1", IP: "127.0.0.1", Port: DefaultKubeletHealthzPort}, local: true},
+			{Endpoint: config.Endpoint{Hostname: "node2", IP: "10.0.0.12", Port: DefaultKubeletAPIPort}},
+		}))
+
+		actual, err = Parse(clusterCfg, rconfig, []string{"checkKubelet", "--healthz-port", "0", "--api-port", "10443"}, false)
+		Expect(err).To(BeNil())
+		Expect(actual.TestData()).To(Equal([]kubeletEndpoint{
+			{Endpoint: config.Endpoint{Hostname: "node2", IP: "10.0.0.12", Port: 10443}},
+		}))
+
+		_, err = Parse(clusterCfg, rconfig, []string{"checkKubelet", "--healthz-port", "0", "--api-port", "0"}, false)
+		Expect(err).NotTo(BeNil())
+		_, err = Parse(clusterCfg, rconfig, []string{"checkKubelet", "--api-port", "70000"}, false)
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("succeeds if the local kubelet is healthy", func() {
+		healthy := true
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/healthz"))
+			if !healthy {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("[-]syncloop failed"))
+				return
+			}
+			w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+		endpoint := kubeletEndpoint{Endpoint: serverEndpoint(server), local: true}
+
+		result, err := checkKubeletFunc(endpoint)
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal("healthz: ok"))
+
+		healthy = false
+		_, err = checkKubeletFunc(endpoint)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(Equal("healthz: 500 Internal Server Error [-]syncloop failed"))
+	})
+
+	It("succeeds if the kubelet API of another node responds without credentials", func() {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		endpoint := kubeletEndpoint{Endpoint: serverEndpoint(server)}
+
+		result, err := checkKubeletFunc(endpoint)
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal("api: 401 Unauthorized"))
+
+		server.Close()
+		_, err = checkKubeletFunc(endpoint)
+		Expect(err).NotTo(BeNil())
+	})
+})
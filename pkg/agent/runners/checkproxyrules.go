@@ -0,0 +1,355 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// CheckProxyRulesCmd is the runner command for checking the number of rules programmed by kube-proxy. It is only supported on the host network.
+	CheckProxyRulesCmd = "checkProxyRules"
+	// FailureClassTooFewProxyRules is the failure class of proxy rules checks if fewer rules than the minimum are programmed,
+	// e.g. none at all although the service of the kube-apiserver always exists.
+	FailureClassTooFewProxyRules = "too-few-proxy-rules"
+	// FailureClassTooManyProxyRules is the failure class of proxy rules checks if more rules than the maximum are programmed.
+	FailureClassTooManyProxyRules = "too-many-proxy-rules"
+
+	// ProxyModeAuto selects the IPVS mode if the dummy interface of kube-proxy in IPVS mode exists, the iptables mode otherwise.
+	ProxyModeAuto = "auto"
+	// ProxyModeIPTables checks the rules of the kube-proxy chains of iptables (nftables and legacy backend).
+	ProxyModeIPTables = "iptables"
+	// ProxyModeIPVS checks the virtual services of IPVS.
+	ProxyModeIPVS = "ipvs"
+
+	// ProxyRulesIPTablesNft are the rules of the kube-proxy chains of iptables with nftables backend or of the nftables mode.
+	ProxyRulesIPTablesNft = "iptables-nft"
+	// ProxyRulesIPTablesLegacy are the rules of the kube-proxy chains of iptables with legacy backend.
+	ProxyRulesIPTablesLegacy = "iptables-legacy"
+	// ProxyRulesIPVS are the virtual services of IPVS.
+	ProxyRulesIPVS = "ipvs"
+
+	// DefaultMaxProxyRules is the default maximum number of rules of the selected mode
+	DefaultMaxProxyRules = 100000
+	// kubeProxyChainPrefix is the prefix of the iptables chains of kube-proxy
+	kubeProxyChainPrefix = "KUBE-"
+	// kubeProxyNftTable is the nftables table of kube-proxy in nftables mode
+	kubeProxyNftTable = "kube-proxy"
+	// kubeIPVSInterface is the dummy interface of kube-proxy in IPVS mode holding the service IPs
+	kubeIPVSInterface = "kube-ipvs0"
+)
+
+// ProxyRulesListener is notified about the numbers of proxy rules per kind (ProxyRulesIPTablesNft, ProxyRulesIPTablesLegacy, ProxyRulesIPVS).
+type ProxyRulesListener func(counts map[string]int)
+
+var (
+	proxyRulesListenerLock sync.Mutex
+	proxyRulesListener     ProxyRulesListener
+)
+
+// SetProxyRulesListener sets the listener notified about the numbers of proxy rules of each check.
+func SetProxyRulesListener(listener ProxyRulesListener) {
+	proxyRulesListenerLock.Lock()
+	defer proxyRulesListenerLock.Unlock()
+	proxyRulesListener = listener
+}
+
+func notifyProxyRulesListener(counts map[string]int) {
+	proxyRulesListenerLock.Lock()
+	listener := proxyRulesListener
+	proxyRulesListenerLock.Unlock()
+	if listener != nil {
+		listener(counts)
+	}
+}
+
+type checkProxyRulesArgs struct {
+	runnerArgs *runnerArgs
+	mode       string
+	minRules   int
+	maxRules   int
+}
+
+func (a *checkProxyRulesArgs) createRunner(cmd *cobra.Command, args []string) error {
+	switch a.mode {
+	case ProxyModeAuto, ProxyModeIPTables, ProxyModeIPVS:
+	default:
+		return fmt.Errorf("invalid mode %q: must be '%s', '%s' or '%s'", a.mode, ProxyModeAuto, ProxyModeIPTables, ProxyModeIPVS)
+	}
+	if a.minRules < 0 {
+		return fmt.Errorf("invalid min rules %d: must not be negative", a.minRules)
+	}
+	if a.maxRules >= 0 && a.maxRules < a.minRules {
+		return fmt.Errorf("invalid max rules %d: must not be lower than min rules %d", a.maxRules, a.minRules)
+	}
+	a.runnerArgs.runner = NewCheckProxyRules(a.mode, a.minRules, a.maxRules, a.runnerArgs.prepareConfig())
+	return nil
+}
+
+func createCheckProxyRulesCmd(ra *runnerArgs) *cobra.Command {
+	a := &checkProxyRulesArgs{runnerArgs: ra}
+	cmd := &cobra.Command{
+		Use:   CheckProxyRulesCmd,
+		Short: "counts the iptables rules or IPVS services programmed by kube-proxy and checks them for anomalies (host network only, needs NET_ADMIN)",
+		RunE:  a.createRunner,
+	}
+	cmd.Flags().StringVar(&a.mode, "mode", ProxyModeAuto, "mode of kube-proxy: 'iptables', 'ipvs' or 'auto' (ipvs if the interface '"+kubeIPVSInterface+"' exists).")
+	cmd.Flags().IntVar(&a.minRules, "min-rules", 1, "minimum number of rules (iptables) or virtual services (ipvs) of the mode.")
+	cmd.Flags().IntVar(&a.maxRules, "max-rules", DefaultMaxProxyRules, "maximum number of rules (iptables) or virtual services (ipvs) of the mode (negative to disable).")
+	return cmd
+}
+
+// NewCheckProxyRules creates a runner checking the number of rules programmed by kube-proxy.
+func NewCheckProxyRules(mode string, minRules, maxRules int, rconfig RunnerConfig) *checkProxyRules {
+	return &checkProxyRules{
+		config:        rconfig,
+		mode:          mode,
+		minRules:      minRules,
+		maxRules:      maxRules,
+		root:          "/",
+		iptablesRules: readIPTablesRules,
+	}
+}
+
+// checkProxyRules counts the rules of the kube-proxy chains of iptables and the virtual services of IPVS.
+// The check fails if the count of the mode of kube-proxy is out of range, as kube-proxy failed to program the rules
+// or the rules are bloated. The counts of all kinds are recorded in the result and reported to the listener.
+type checkProxyRules struct {
+	config   RunnerConfig
+	mode     string
+	minRules int
+	maxRules int
+	// root is the root directory of /proc and /sys, replaced in tests
+	root string
+	// iptablesRules returns the rules of the kube-proxy chains with nftables and legacy backend, replaced in tests
+	iptablesRules func() (nft, legacy int, err error)
+}
+
+var _ Runner = &checkProxyRules{}
+
+func (r *checkProxyRules) Config() RunnerConfig {
+	return r.config
+}
+
+func (r *checkProxyRules) Description() string {
+	return fmt.Sprintf("kube-proxy rules (mode %s)", r.mode)
+}
+
+func (r *checkProxyRules) TestData() any {
+	return []any{r.Description(), r.minRules, r.maxRules}
+}
+
+func (r *checkProxyRules) DestHosts() []string {
+	return []string{common.DestHostKubeProxy}
+}
+
+func (r *checkProxyRules) Run(ctx context.Context, ch chan<- *nwpd.Observation) {
+	if ctx.Err() != nil {
+		return
+	}
+	start := time.Now()
+	obs := &nwpd.Observation{
+		SrcHost:   GetNodeName(),
+		DestHost:  common.DestHostKubeProxy,
+		Timestamp: timestamppb.New(start),
+		JobID:     r.config.JobID,
+		Labels:    r.config.Labels,
+		Protocol:  nwpd.ProtocolProxyRules,
+		Period:    durationpb.New(r.config.Period),
+	}
+	result, class, err := r.check()
+	obs.Duration = durationpb.New(time.Since(start))
+	obs.Ok = err == nil
+	obs.FailureClass = class
+	if err != nil {
+		obs.Result = fmt.Sprintf("error: %s", err)
+	} else {
+		obs.Result = result
+	}
+	sendObservation(ctx, ch, obs)
+}
+
+// check counts the rules and compares the count of the mode with the range.
+func (r *checkProxyRules) check() (string, string, error) {
+	mode := r.mode
+	if mode == ProxyModeAuto {
+		mode = ProxyModeIPTables
+		if _, err := os.Stat(filepath.Join(r.root, "sys/class/net", kubeIPVSInterface)); err == nil {
+			mode = ProxyModeIPVS
+		}
+	}
+	services, err := readIPVSServices(filepath.Join(r.root, "proc/net/ip_vs"))
+	if err != nil {
+		return "", "", err
+	}
+	nft, legacy, err := r.iptablesRules()
+	if err != nil {
+		return "", "", err
+	}
+	notifyProxyRulesListener(map[string]int{
+		ProxyRulesIPTablesNft:    nft,
+		ProxyRulesIPTablesLegacy: legacy,
+		ProxyRulesIPVS:           services,
+	})
+
+	count, what := nft+legacy, "iptables rules"
+	if mode == ProxyModeIPVS {
+		count, what = services, "IPVS services"
+	}
+	result := fmt.Sprintf("mode %s: %d %s (iptables-nft %d, iptables-legacy %d, ipvs %d)", mode, count, what, nft, legacy, services)
+	if count < r.minRules {
+		return "", FailureClassTooFewProxyRules, fmt.Errorf("%d %s below minimum %d, %s", count, what, r.minRules, result)
+	}
+	if r.maxRules >= 0 && count > r.maxRules {
+		return "", FailureClassTooManyProxyRules, fmt.Errorf("%d %s exceed maximum %d, %s", count, what, r.maxRules, result)
+	}
+	return result, "", nil
+}
+
+// readIPVSServices returns the number of virtual services in /proc/net/ip_vs, 0 if IPVS is not loaded.
+func readIPVSServices(filename string) (int, error) {
+	services := 0
+	err := scanFields(filename, func(fields []string) error {
+		// Prot LocalAddress:Port Scheduler Flags, followed by the real servers starting with '->'
+		if len(fields) >= 2 {
+			switch fields[0] {
+			case "TCP", "UDP", "SCTP", "FWM":
+				services++
+			}
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return services, err
+}
+
+// iptEntryLayout contains the offsets of the fields of the entries of the legacy iptables tables
+// (struct ipt_entry and struct ip6t_entry), which only differ by the size of the IP header matcher.
+type iptEntryLayout struct {
+	targetOffset int
+	size         int
+}
+
+var (
+	// iptEntryLayoutIPv4 is the layout of struct ipt_entry
+	iptEntryLayoutIPv4 = iptEntryLayout{targetOffset: 88, size: 112}
+	// iptEntryLayoutIPv6 is the layout of struct ip6t_entry
+	iptEntryLayoutIPv6 = iptEntryLayout{targetOffset: 140, size: 168}
+)
+
+const (
+	// xtTargetNameOffset and xtTargetDataOffset are the offsets of the name and the data of struct xt_entry_target
+	xtTargetNameOffset = 2
+	xtTargetDataOffset = 32
+	xtTargetNameLen    = 29
+	xtErrorNameLen     = 32
+	// xtErrorTarget is the target of the heads of the user defined chains and of the end of the table
+	xtErrorTarget = "ERROR"
+)
+
+// countIPTEntries returns the number of rules of the kube-proxy chains in the entries of a legacy iptables table as returned
+// by the socket option IPT_SO_GET_ENTRIES. A user defined chain starts with an entry with target 'ERROR' and the chain
+// name as data and ends with its policy entry, which is not counted.
+func countIPTEntries(data []byte, layout iptEntryLayout) (int, error) {
+	count := 0
+	chain := ""
+	entries := 0
+	endChain := func() {
+		if strings.HasPrefix(chain, kubeProxyChainPrefix) && entries > 0 {
+			count += entries - 1
+		}
+	}
+	for offset := 0; offset < len(data); {
+		if offset+layout.size > len(data) {
+			return 0, fmt.Errorf("truncated iptables entry at offset %d", offset)
+		}
+		targetOffset := int(binary.LittleEndian.Uint16(data[offset+layout.targetOffset:]))
+		nextOffset := int(binary.LittleEndian.Uint16(data[offset+layout.targetOffset+2:]))
+		if nextOffset <= 0 || targetOffset+xtTargetDataOffset > nextOffset || offset+nextOffset > len(data) {
+			return 0, fmt.Errorf("invalid iptables entry at offset %d", offset)
+		}
+		target := data[offset+targetOffset : offset+nextOffset]
+		if cString(target[xtTargetNameOffset:xtTargetNameOffset+xtTargetNameLen]) == xtErrorTarget {
+			endChain()
+			if len(target) < xtTargetDataOffset+xtErrorNameLen {
+				return 0, fmt.Errorf("invalid iptables error target at offset %d", offset)
+			}
+			chain, entries = cString(target[xtTargetDataOffset:xtTargetDataOffset+xtErrorNameLen]), 0
+			if chain == xtErrorTarget {
+				// end of the table
+				return count, nil
+			}
+		} else {
+			entries++
+		}
+		offset += nextOffset
+	}
+	endChain()
+	return count, nil
+}
+
+const (
+	// nlaHeaderLen is the size of struct nlattr
+	nlaHeaderLen = 4
+	// nlaTypeMask masks the flags NLA_F_NESTED and NLA_F_NET_BYTEORDER of the attribute type
+	nlaTypeMask = 0x3fff
+	// nfgenmsgLen is the size of struct nfgenmsg preceding the attributes of the nftables messages
+	nfgenmsgLen = 4
+	// nftaRuleTable and nftaRuleChain are the attributes NFTA_RULE_TABLE and NFTA_RULE_CHAIN of a rule
+	nftaRuleTable = 1
+	nftaRuleChain = 2
+)
+
+// isKubeProxyNftRule returns true if the nftables rule message (without netlink header) belongs to a kube-proxy chain of
+// iptables with nftables backend or to the table of kube-proxy in nftables mode.
+func isKubeProxyNftRule(data []byte) bool {
+	if len(data) < nfgenmsgLen {
+		return false
+	}
+	table, chain := "", ""
+	for attrs := data[nfgenmsgLen:]; len(attrs) >= nlaHeaderLen; {
+		length := int(binary.LittleEndian.Uint16(attrs))
+		if length < nlaHeaderLen || length > len(attrs) {
+			break
+		}
+		switch binary.LittleEndian.Uint16(attrs[2:]) & nlaTypeMask {
+		case nftaRuleTable:
+			table = cString(attrs[nlaHeaderLen:length])
+		case nftaRuleChain:
+			chain = cString(attrs[nlaHeaderLen:length])
+		}
+		// attributes are aligned to 4 bytes
+		aligned := (length + 3) &^ 3
+		if aligned > len(attrs) {
+			break
+		}
+		attrs = attrs[aligned:]
+	}
+	return table == kubeProxyNftTable || strings.HasPrefix(chain, kubeProxyChainPrefix)
+}
+
+// cString returns the string up to the first NUL byte.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return string(b[:i])
+	}
+	return string(b)
+}
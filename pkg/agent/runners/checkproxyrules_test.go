@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("checkProxyRules", func() {
+	const ipvs = "IP Virtual Server version 1.2.1 (size=4096)\n" +
+		"Prot LocalAddress:Port Scheduler Flags\n" +
+		"  -> RemoteAddress:Port Forward Weight ActiveConn InActConn\n" +
+		"TCP  64000001:01BB rr\n" +
+		"  -> 0A000005:1A0A      Masq    1      0          0\n" +
+		"UDP  6400000A:0035 rr\n" +
+		"  -> 60600002:0035      Masq    1      0          0\n" +
+		"  -> 60600003:0035      Masq    1      0          0\n"
+
+	var (
+		rconfig = RunnerConfig{Job: config.Job{JobID: "proxy-rules-n"}}
+		root    string
+	)
+
+	run := func(args []string, nft, legacy int, iptablesErr error) *nwpd.Observation {
+		actual, err := Parse(config.ClusterConfig{}, rconfig, append([]string{CheckProxyRulesCmd}, args...), false)
+		Expect(err).To(BeNil())
+		r := actual.(*checkProxyRules)
+		r.root = root
+		r.iptablesRules = func() (int, int, error) { return nft, legacy, iptablesErr }
+		ch := make(chan *nwpd.Observation, 1)
+		r.Run(context.Background(), ch)
+		obs := <-ch
+		Expect(obs.DestHost).To(Equal(common.DestHostKubeProxy))
+		Expect(obs.Protocol).To(Equal(nwpd.ProtocolProxyRules))
+		return obs
+	}
+
+	BeforeEach(func() {
+		var err error
+		root, err = os.MkdirTemp("", "proxyrules")
+		Expect(err).To(BeNil())
+	})
+	AfterEach(func() {
+		SetProxyRulesListener(nil)
+		os.RemoveAll(root)
+	})
+
+	It("parses the arguments", func() {
+		actual, err := Parse(config.ClusterConfig{}, rconfig, []string{CheckProxyRulesCmd, "--mode", "ipvs", "--min-rules", "10", "--max-rules", "-1"}, false)
+		Expect(err).To(BeNil())
+		Expect(actual.TestData()).To(Equal([]any{"kube-proxy rules (mode ipvs)", 10, -1}))
+
+		for _, args := range [][]string{{"--mode", "userspace"}, {"--min-rules", "-1"}, {"--min-rules", "10", "--max-rules", "5"}} {
+			_, err = Parse(config.ClusterConfig{}, rconfig, append([]string{CheckProxyRulesCmd}, args...), false)
+			Expect(err).NotTo(BeNil(), args)
+		}
+	})
+
+	It("counts the iptables rules and reports all counts", func() {
+		var reported map[string]int
+		SetProxyRulesListener(func(counts map[string]int) { reported = counts })
+		obs := run(nil, 120, 3, nil)
+		Expect(obs.Ok).To(BeTrue(), obs.Result)
+		Expect(obs.Result).To(Equal("mode iptables: 123 iptables rules (iptables-nft 120, iptables-legacy 3, ipvs 0)"))
+		Expect(reported).To(Equal(map[string]int{ProxyRulesIPTablesNft: 120, ProxyRulesIPTablesLegacy: 3, ProxyRulesIPVS: 0}))
+	})
+
+	It("detects the IPVS mode", func() {
+		Expect(os.MkdirAll(filepath.Join(root, "sys/class/net", kubeIPVSInterface), 0755)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(root, "proc/net"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(root, "proc/net/ip_vs"), []byte(ipvs), 0644)).To(Succeed())
+		obs := run(nil, 40, 0, nil)
+		Expect(obs.Ok).To(BeTrue(), obs.Result)
+		Expect(obs.Result).To(Equal("mode ipvs: 2 IPVS services (iptables-nft 40, iptables-legacy 0, ipvs 2)"))
+
+		obs = run([]string{"--mode", "ipvs", "--min-rules", "3"}, 40, 0, nil)
+		Expect(obs.Ok).To(BeFalse())
+		Expect(obs.FailureClass).To(Equal(FailureClassTooFewProxyRules))
+	})
+
+	It("flags anomalies", func() {
+		obs := run([]string{"--mode", "iptables"}, 0, 0, nil)
+		Expect(obs.Ok).To(BeFalse())
+		Expect(obs.FailureClass).To(Equal(FailureClassTooFewProxyRules))
+		Expect(obs.Result).To(Equal("error: 0 iptables rules below minimum 1, mode iptables: 0 iptables rules (iptables-nft 0, iptables-legacy 0, ipvs 0)"))
+
+		obs = run([]string{"--max-rules", "100"}, 101, 0, nil)
+		Expect(obs.Ok).To(BeFalse())
+		Expect(obs.FailureClass).To(Equal(FailureClassTooManyProxyRules))
+
+		obs = run(nil, 0, 0, fmt.Errorf("operation not permitted"))
+		Expect(obs.Ok).To(BeFalse())
+		Expect(obs.FailureClass).To(BeEmpty())
+		Expect(obs.Result).To(Equal("error: operation not permitted"))
+	})
+
+	It("counts the rules of the kube-proxy chains of a legacy iptables table", func() {
+		layout := iptEntryLayoutIPv4
+		entry := func(target string, data string) []byte {
+			b := make([]byte, layout.size+xtTargetDataOffset+xtErrorNameLen)
+			binary.LittleEndian.PutUint16(b[layout.targetOffset:], uint16(layout.size))
+			binary.LittleEndian.PutUint16(b[layout.targetOffset+2:], uint16(len(b)))
+			copy(b[layout.size+xtTargetNameOffset:], target)
+			copy(b[layout.size+xtTargetDataOffset:], data)
+			return b
+		}
+		var table []byte
+		for _, e := range [][]byte{
+			// builtin chain with a jump to KUBE-SERVICES and its policy
+			entry("", ""), entry("", ""),
+			entry(xtErrorTarget, "KUBE-SERVICES"), entry("", ""), entry("", ""), entry("", ""),
+			entry(xtErrorTarget, "CUSTOM"), entry("", ""), entry("", ""),
+			entry(xtErrorTarget, "KUBE-SVC-1"), entry("", ""), entry("", ""),
+			entry(xtErrorTarget, xtErrorTarget),
+		} {
+			table = append(table, e...)
+		}
+		Expect(countIPTEntries(table, layout)).To(Equal(3))
+
+		_, err := countIPTEntries(table[:len(table)-1], layout)
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("selects the nftables rules of kube-proxy", func() {
+		rule := func(table, chain string) []byte {
+			b := make([]byte, nfgenmsgLen)
+			for _, attr := range []struct {
+				typ   uint16
+				value string
+			}{{nftaRuleTable, table}, {nftaRuleChain, chain}} {
+				value := append([]byte(attr.value), 0)
+				a := make([]byte, nlaHeaderLen, nlaHeaderLen+len(value)+3)
+				binary.LittleEndian.PutUint16(a, uint16(nlaHeaderLen+len(value)))
+				binary.LittleEndian.PutUint16(a[2:], attr.typ)
+				a = append(a, value...)
+				for len(a)%4 != 0 {
+					a = append(a, 0)
+				}
+				b = append(b, a...)
+			}
+			return b
+		}
+		Expect(isKubeProxyNftRule(rule("nat", "KUBE-SERVICES"))).To(BeTrue())
+		Expect(isKubeProxyNftRule(rule("kube-proxy", "services"))).To(BeTrue())
+		Expect(isKubeProxyNftRule(rule("filter", "INPUT"))).To(BeFalse())
+		Expect(isKubeProxyNftRule(nil)).To(BeFalse())
+	})
+
+	It("is only supported on the host network", func() {
+		job := config.Job{JobID: "proxy-rules-n", Args: []string{CheckProxyRulesCmd}}
+		Expect(ValidateAgentConfig(&config.AgentConfig{HostNetwork: &config.NetworkConfig{Jobs: []config.Job{job}}})).To(Succeed())
+		Expect(ValidateAgentConfig(&config.AgentConfig{PodNetwork: &config.NetworkConfig{Jobs: []config.Job{job}}})).NotTo(Succeed())
+	})
+})
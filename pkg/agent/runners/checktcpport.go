@@ -5,47 +5,110 @@
 package runners
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
 	"net"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
 	"github.com/spf13/cobra"
 )
 
+const (
+	// MaxTCPConnections is the upper bound for the number of connections per destination of a TCP port check
+	MaxTCPConnections = 10
+	// FailureClassResetAfterConnect is the failure class of TCP port checks with option --verify-data
+	// if the connection is reset right after it has been established.
+	FailureClassResetAfterConnect = "reset-after-connect"
+	// FailureClassBlackhole is the failure class of TCP port checks with option --echo if the connection has been
+	// established, but the payload is not echoed within the timeout, i.e. the path silently drops the data.
+	FailureClassBlackhole = "blackhole"
+	// defaultEchoTimeout is the default time to wait for the echoed payload with option --echo
+	defaultEchoTimeout = 2 * time.Second
+	// verifyDataWindow is the time to wait for a reset of the connection with option --verify-data
+	verifyDataWindow = 1 * time.Second
+	// maxSourcePortAttempts is the maximum number of ports of the source port range tried per connection
+	maxSourcePortAttempts = 10
+	// tcpDialTimeout is the timeout for establishing a connection
+	tcpDialTimeout = 30 * time.Second
+)
+
+// probePayload is sent with option --force-payload to provoke middleboxes killing connections on the first payload.
+var probePayload = []byte("nwpd-probe\n")
+
+// echoPayloadPrefix is the prefix of the payload sent with option --echo. It is followed by a random nonce,
+// so that stale data cannot be mistaken for the echo.
+const echoPayloadPrefix = "nwpd-echo "
+
 type checkTCPPortArgs struct {
-	runnerArgs   *runnerArgs
-	nodePort     int
-	podDS        bool
-	internalKAPI bool
-	externalKAPI bool
-	endpoints    []string
+	runnerArgs *runnerArgs
+	tcpEndpointArgs
+	connections  int
+	netns        string
+	iface        string
+	verifyData   bool
+	forcePayload bool
+	echo         bool
+	echoTimeout  time.Duration
+	sourcePorts  string
 }
 
-func (a *checkTCPPortArgs) createRunner(cmd *cobra.Command, args []string) error {
+// tcpEndpointArgs selects the TCP endpoints of the checkTCPPort and checkConnectBurst commands.
+type tcpEndpointArgs struct {
+	nodePort        int
+	nodeAddressType string
+	podDS           bool
+	internalKAPI    bool
+	externalKAPI    bool
+	lbs             bool
+	urlList         bool
+	services        bool
+	endpoints       []string
+	warmUpPeriod    time.Duration
+}
+
+func (a *tcpEndpointArgs) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&a.endpoints, "endpoints", nil, "endpoints in format <hostname>:<ip>:<port> (IPv6 addresses in brackets).")
+	cmd.Flags().IntVar(&a.nodePort, "node-port", 0, "port on nodes as alternative to specifying endpoints.")
+	cmd.Flags().StringVar(&a.nodeAddressType, "node-address-type", config.NodeAddressTypeInternal, "address type of the nodes used with --node-port ('internal' or 'external').")
+	cmd.Flags().BoolVar(&a.podDS, "endpoints-of-pod-ds", false, "uses known pod endpoints of the 'nwpd-agent-pod-net' service.")
+	cmd.Flags().BoolVar(&a.internalKAPI, "endpoint-internal-kube-apiserver", false, "uses known internal endpoint of kube-apiserver.")
+	cmd.Flags().BoolVar(&a.externalKAPI, "endpoint-external-kube-apiserver", false, "uses known external endpoint of kube-apiserver.")
+	cmd.Flags().BoolVar(&a.lbs, "endpoints-of-load-balancers", false, "uses known external endpoints of services of type LoadBalancer selected for the hairpin check.")
+	cmd.Flags().BoolVar(&a.services, "endpoints-of-services", false, "uses known cluster IP endpoints of the services selected by label (see 'run-controller --service-selector').")
+	cmd.Flags().DurationVar(&a.warmUpPeriod, "warm-up-period", DefaultWarmUpPeriod, "period after the start of a destination pod with --endpoints-of-pod-ds, during which its observations are recorded as warming up and ignored by the aggregation (0 to disable).")
+	cmd.Flags().BoolVar(&a.urlList, "endpoints-of-url-list", false, "uses the endpoints loaded by the controller from the URL given by 'run-controller --endpoints-from-url'.")
+}
+
+func (a *tcpEndpointArgs) selectEndpoints(clusterCfg config.ClusterConfig) ([]config.Endpoint, error) {
+	if err := config.ValidateNodeAddressType(a.nodeAddressType); err != nil {
+		return nil, err
+	}
+	if a.warmUpPeriod < 0 {
+		return nil, fmt.Errorf("invalid warm-up period %s: must not be negative", a.warmUpPeriod)
+	}
 	allowEmpty := false
 	var endpoints []config.Endpoint
 	if len(a.endpoints) > 0 {
 		for _, ep := range a.endpoints {
-			parts := strings.SplitN(ep, ":", 3)
-			if len(parts) != 3 {
-				return fmt.Errorf("invalid endpoint %s", ep)
-			}
-			port, err := strconv.Atoi(parts[2])
+			endpoint, err := config.ParseEndpoint(ep)
 			if err != nil {
-				return fmt.Errorf("invalid endpoint port %s", parts[2])
+				return nil, err
 			}
-			endpoints = append(endpoints, config.Endpoint{
-				Hostname: parts[0],
-				IP:       parts[1],
-				Port:     port,
-			})
+			endpoints = append(endpoints, endpoint)
 		}
 	} else if a.nodePort != 0 {
 		allowEmpty = true
-		for _, n := range a.runnerArgs.clusterCfg.Nodes {
+		for _, n := range config.NodesWithAddressType(clusterCfg.Nodes, a.nodeAddressType) {
 			endpoints = append(endpoints, config.Endpoint{
 				Hostname: n.Hostname,
 				IP:       n.InternalIP,
@@ -54,7 +117,7 @@ func (a *checkTCPPortArgs) createRunner(cmd *cobra.Command, args []string) error
 		}
 	} else if a.podDS {
 		allowEmpty = true
-		for _, pe := range a.runnerArgs.clusterCfg.PodEndpoints {
+		for _, pe := range clusterCfg.PodEndpoints {
 			endpoints = append(endpoints, config.Endpoint{
 				Hostname: pe.Nodename,
 				IP:       pe.PodIP,
@@ -63,27 +126,115 @@ func (a *checkTCPPortArgs) createRunner(cmd *cobra.Command, args []string) error
 		}
 	} else if a.internalKAPI {
 		allowEmpty = true
-		if pe := a.runnerArgs.clusterCfg.InternalKubeAPIServer; pe != nil {
+		if pe := clusterCfg.InternalKubeAPIServer; pe != nil {
 			endpoints = append(endpoints, *pe)
 		}
 	} else if a.externalKAPI {
 		allowEmpty = true
-		if pe := a.runnerArgs.clusterCfg.KubeAPIServer; pe != nil {
+		if pe := clusterCfg.KubeAPIServer; pe != nil {
 			endpoints = append(endpoints, *pe)
 		}
+	} else if a.lbs {
+		allowEmpty = true
+		endpoints = append(endpoints, clusterCfg.LoadBalancers...)
+	} else if a.services {
+		allowEmpty = true
+		endpoints = append(endpoints, clusterCfg.Services...)
+	} else if a.urlList {
+		allowEmpty = true
+		if list := clusterCfg.URLEndpoints; list != nil {
+			endpoints = append(endpoints, list.Endpoints...)
+		}
 	}
 
 	if !allowEmpty && len(endpoints) == 0 {
-		return fmt.Errorf("no endpoints")
+		return nil, fmt.Errorf("no endpoints")
+	}
+	return endpoints, nil
+}
+
+func (a *checkTCPPortArgs) createRunner(cmd *cobra.Command, args []string) error {
+	if a.connections < 1 || a.connections > MaxTCPConnections {
+		return fmt.Errorf("invalid connections %d: must be in range 1..%d", a.connections, MaxTCPConnections)
+	}
+	if err := validateNetNS(a.netns); err != nil {
+		return err
+	}
+	if err := validateInterfaceName(a.iface); err != nil {
+		return err
+	}
+	if a.verifyData && a.connections > 1 {
+		return fmt.Errorf("option --verify-data cannot be combined with --connections > 1")
+	}
+	if a.forcePayload && !a.verifyData {
+		return fmt.Errorf("option --force-payload requires --verify-data")
+	}
+	if a.echo && (a.verifyData || a.connections > 1) {
+		return fmt.Errorf("option --echo cannot be combined with --verify-data or --connections > 1")
+	}
+	if a.echoTimeout <= 0 {
+		return fmt.Errorf("invalid echo timeout %s", a.echoTimeout)
+	}
+	var sourcePorts *sourcePortRange
+	if a.sourcePorts != "" {
+		var err error
+		if sourcePorts, err = parseSourcePortRange(a.sourcePorts); err != nil {
+			return err
+		}
+	}
+	endpoints, err := a.selectEndpoints(a.runnerArgs.clusterCfg)
+	if err != nil {
+		return err
 	}
 
 	config := a.runnerArgs.prepareConfig()
 	if r := NewCheckTCPPort(endpoints, config); r != nil {
+		r.setEndpointList(a.endpointList(a.runnerArgs.clusterCfg))
+		r.setDestAddressType(a.destAddressType())
+		r.setWarmUp(a.warmUp(a.runnerArgs.clusterCfg))
+		r.setNetNS(a.netns)
+		r = r.withConnections(a.connections)
+		if a.verifyData {
+			r = r.withVerifyData(a.forcePayload)
+		}
+		if a.echo {
+			r = r.withEcho(a.echoTimeout)
+		}
+		if sourcePorts != nil {
+			r = r.withSourcePortRange(sourcePorts)
+		}
+		if a.iface != "" {
+			r = r.withInterface(a.iface)
+		}
 		a.runnerArgs.runner = r
 	}
 	return nil
 }
 
+// destAddressType returns the address type of the nodes if they are used for the endpoints.
+func (a *tcpEndpointArgs) destAddressType() string {
+	if len(a.endpoints) > 0 || a.nodePort == 0 {
+		return ""
+	}
+	return a.nodeAddressType
+}
+
+// warmUp returns the warm-up of the pod endpoints if they are used for the endpoints.
+func (a *tcpEndpointArgs) warmUp(clusterCfg config.ClusterConfig) *warmUp {
+	if !a.podDS || len(a.endpoints) > 0 || a.nodePort != 0 {
+		return nil
+	}
+	return newPodWarmUp(clusterCfg.PodEndpoints, a.warmUpPeriod)
+}
+
+// endpointList returns the endpoint list loaded from a URL if it is used for the endpoints.
+func (a *tcpEndpointArgs) endpointList(clusterCfg config.ClusterConfig) *config.EndpointList {
+	if !a.urlList || len(a.endpoints) > 0 || a.nodePort != 0 || a.podDS || a.internalKAPI || a.externalKAPI || a.lbs || a.services {
+		return nil
+	}
+	return clusterCfg.URLEndpoints
+}
+
 func createCheckTCPPortCmd(ra *runnerArgs) *cobra.Command {
 	a := &checkTCPPortArgs{runnerArgs: ra}
 	cmd := &cobra.Command{
@@ -91,11 +242,15 @@ func createCheckTCPPortCmd(ra *runnerArgs) *cobra.Command {
 		Short: "checks connection to TCP port",
 		RunE:  a.createRunner,
 	}
-	cmd.Flags().StringSliceVar(&a.endpoints, "endpoints", nil, "endpoints in format <hostname>:<ip>:<port>.")
-	cmd.Flags().IntVar(&a.nodePort, "node-port", 0, "port on nodes as alternative to specifying endpoints.")
-	cmd.Flags().BoolVar(&a.podDS, "endpoints-of-pod-ds", false, "uses known pod endpoints of the 'nwpd-agent-pod-net' service.")
-	cmd.Flags().BoolVar(&a.internalKAPI, "endpoint-internal-kube-apiserver", false, "uses known internal endpoint of kube-apiserver.")
-	cmd.Flags().BoolVar(&a.externalKAPI, "endpoint-external-kube-apiserver", false, "uses known external endpoint of kube-apiserver.")
+	a.addFlags(cmd)
+	addNetNSFlag(cmd, &a.netns)
+	addInterfaceFlag(cmd, &a.iface)
+	cmd.Flags().BoolVar(&a.verifyData, "verify-data", false, "verifies that the connection stays usable after it has been established, i.e. it is not reset within a short window (failure class '"+FailureClassResetAfterConnect+"').")
+	cmd.Flags().BoolVar(&a.forcePayload, "force-payload", false, "sends a small probe payload with --verify-data. Only use it for endpoints which accept arbitrary data.")
+	cmd.Flags().IntVar(&a.connections, "connections", 1, fmt.Sprintf("number of connections per destination and check from different source ports to detect path dependent problems (e.g. ECMP or conntrack, maximum %d).", MaxTCPConnections))
+	cmd.Flags().BoolVar(&a.echo, "echo", false, "sends a payload after connecting and verifies that it is echoed within the echo timeout. Requires an echo handler on the endpoint (e.g. the echo port of the agents). Connections without echo fail with failure class '"+FailureClassBlackhole+"'.")
+	cmd.Flags().DurationVar(&a.echoTimeout, "echo-timeout", defaultEchoTimeout, "timeout for receiving the echoed payload with --echo.")
+	cmd.Flags().StringVar(&a.sourcePorts, "source-port-range", "", "binds the connections to source ports of the range in format <min>-<max> instead of ephemeral ports, e.g. for validating firewall rules for source ports.")
 	return cmd
 }
 
@@ -103,28 +258,384 @@ func NewCheckTCPPort(endpoints []config.Endpoint, rconfig RunnerConfig) *checkTC
 	if len(endpoints) == 0 {
 		return nil
 	}
-	return &checkTCPPort{
-		robinRound[config.Endpoint]{
+	r := &checkTCPPort{
+		robinRound: robinRound[config.Endpoint]{
 			itemsName: "endpoints",
+			protocol:  nwpd.ProtocolTCP,
 			items:     config.CloneAndShuffle(endpoints),
 			runFunc:   checkTCPPortFunc,
 			config:    rconfig,
+			connectionKey: func(endpoint config.Endpoint) (connectionKey, bool) {
+				return connectionKey{protocol: nwpd.ProtocolTCP, ip: endpoint.IP, port: endpoint.Port}, endpoint.IP != ""
+			},
 		},
+		connections:  1,
+		verifyWindow: verifyDataWindow,
+	}
+	if !rconfig.BypassResolverCache {
+		r.resolvedHost = endpointIPHost
+		r.resolve = resolveEndpointIP
 	}
+	return r
 }
 
 type checkTCPPort struct {
 	robinRound[config.Endpoint]
+	connections  int
+	verifyData   bool
+	forcePayload bool
+	verifyWindow time.Duration
+	echo         bool
+	echoTimeout  time.Duration
+	sourcePorts  *sourcePortRange
 }
 
 var _ Runner = &checkTCPPort{}
 
+// withConnections sets the number of connections per check. With more than one connection, the check is
+// partially failed if some but not all connections fail. Such checks are not shared with other jobs.
+func (r *checkTCPPort) withConnections(connections int) *checkTCPPort {
+	if connections > 1 {
+		r.connections = connections
+		r.connectionKey = nil
+		r.runFunc = func(endpoint config.Endpoint) (string, error) {
+			return checkTCPPortConnectionsFunc(endpoint, connections, r.dialer())
+		}
+	}
+	return r
+}
+
+// withVerifyData verifies that the connection is not reset right after it has been established. Data is only sent to
+// the endpoints if forcePayload is true. Such checks are not shared with other jobs.
+func (r *checkTCPPort) withVerifyData(forcePayload bool) *checkTCPPort {
+	r.verifyData = true
+	r.forcePayload = forcePayload
+	r.connectionKey = nil
+	r.runFunc = func(endpoint config.Endpoint) (string, error) {
+		var payload []byte
+		if r.forcePayload {
+			payload = probePayload
+		}
+		result, _, err := checkTCPPortVerifyDataFunc(endpoint, r.verifyWindow, payload, r.dialer())
+		return result, err
+	}
+	return r
+}
+
+// withEcho verifies that a payload sent after connecting is echoed by the endpoint within the timeout.
+// Such checks are not shared with other jobs.
+func (r *checkTCPPort) withEcho(timeout time.Duration) *checkTCPPort {
+	r.echo = true
+	r.echoTimeout = timeout
+	r.connectionKey = nil
+	r.runFunc = func(endpoint config.Endpoint) (string, error) {
+		result, _, err := checkTCPPortEchoFunc(endpoint, r.echoTimeout, r.dialer())
+		return result, err
+	}
+	return r
+}
+
+// withSourcePortRange binds the connections to source ports of the range. The source port is recorded in the observations
+// of checks with a single connection. Such checks are not shared with other jobs.
+func (r *checkTCPPort) withSourcePortRange(ports *sourcePortRange) *checkTCPPort {
+	r.sourcePorts = ports
+	r.connectionKey = nil
+	r.detailedRunFunc = r.runBound
+	return r
+}
+
+// withInterface binds the connections to the network interface with SO_BINDTODEVICE. The interface is recorded
+// in the observations. Such checks are not shared with other jobs.
+func (r *checkTCPPort) withInterface(iface string) *checkTCPPort {
+	r.setInterface(iface)
+	r.connectionKey = nil
+	r.detailedRunFunc = r.runBound
+	return r
+}
+
+// dialer returns the dialer for the source port range and the interface, nil if the connections are not bound.
+func (r *checkTCPPort) dialer() *tcpDialer {
+	if r.sourcePorts == nil && r.iface == "" {
+		return nil
+	}
+	return &tcpDialer{ports: r.sourcePorts, iface: r.iface}
+}
+
+// runBound runs the check with connections bound to the source port range or the interface and records the source port.
+func (r *checkTCPPort) runBound(endpoint config.Endpoint) (string, checkDetails, error) {
+	var (
+		result  string
+		srcPort int
+		err     error
+	)
+	switch {
+	case r.connections > 1:
+		result, err = r.runFunc(endpoint)
+	case r.echo:
+		result, srcPort, err = checkTCPPortEchoFunc(endpoint, r.echoTimeout, r.dialer())
+	case r.verifyData:
+		var payload []byte
+		if r.forcePayload {
+			payload = probePayload
+		}
+		result, srcPort, err = checkTCPPortVerifyDataFunc(endpoint, r.verifyWindow, payload, r.dialer())
+	default:
+		result, srcPort, err = checkTCPPortBoundFunc(endpoint, r.dialer())
+	}
+	return result, checkDetails{srcPort: srcPort}, err
+}
+
+func (r *checkTCPPort) Description() string {
+	desc := r.robinRound.Description()
+	if r.connections > 1 {
+		desc = fmt.Sprintf("%s, %d connections", desc, r.connections)
+	}
+	if r.forcePayload {
+		desc += ", verify data with payload"
+	} else if r.verifyData {
+		desc += ", verify data"
+	}
+	if r.echo {
+		desc += ", echo"
+	}
+	if r.sourcePorts != nil {
+		desc = fmt.Sprintf("%s, source ports %s", desc, r.sourcePorts)
+	}
+	return desc
+}
+
 func checkTCPPortFunc(endpoint config.Endpoint) (string, error) {
-	addr := fmt.Sprintf("%s:%d", endpoint.IP, endpoint.Port)
-	conn, err := net.DialTimeout("tcp", addr, 30*time.Second)
+	result, _, err := checkTCPPortBoundFunc(endpoint, nil)
+	return result, err
+}
+
+// checkTCPPortBoundFunc connects to the endpoint with the dialer or from an ephemeral port if dialer is nil.
+func checkTCPPortBoundFunc(endpoint config.Endpoint, dialer *tcpDialer) (string, int, error) {
+	addr := net.JoinHostPort(endpoint.IP, strconv.Itoa(endpoint.Port))
+	conn, srcPort, err := dialer.dial(addr)
 	if err != nil {
-		return "", err
+		return "", srcPort, err
 	}
 	conn.Close()
-	return "connected", nil
+	if srcPort != 0 {
+		return fmt.Sprintf("connected from port %d", srcPort), srcPort, nil
+	}
+	return "connected", 0, nil
+}
+
+// checkTCPPortVerifyDataFunc connects to the endpoint, optionally sends the payload and reads from the connection until the window
+// has passed. The check fails with the class reset-after-connect if the connection is reset meanwhile. A graceful close or data
+// sent by the endpoint (e.g. a banner) are accepted.
+func checkTCPPortVerifyDataFunc(endpoint config.Endpoint, window time.Duration, payload []byte, dialer *tcpDialer) (string, int, error) {
+	addr := net.JoinHostPort(endpoint.IP, strconv.Itoa(endpoint.Port))
+	conn, srcPort, err := dialer.dial(addr)
+	if err != nil {
+		// a reset (in contrast to a refused connection) means the handshake had completed
+		return "", srcPort, classifyConnectionError(err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(window)); err != nil {
+		return "", srcPort, err
+	}
+	if len(payload) > 0 {
+		if _, err := conn.Write(payload); err != nil {
+			return "", srcPort, classifyConnectionError(err)
+		}
+	}
+	buf := make([]byte, 256)
+	n, err := conn.Read(buf)
+	switch {
+	case n > 0:
+		return fmt.Sprintf("connected, received %d bytes", n), srcPort, nil
+	case err == nil:
+		return "connected", srcPort, nil
+	case errors.Is(err, io.EOF):
+		return "connected, closed by peer", srcPort, nil
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return fmt.Sprintf("connected, no reset within %s", window), srcPort, nil
+	default:
+		return "", srcPort, classifyConnectionError(err)
+	}
+}
+
+// checkTCPPortEchoFunc connects to the endpoint, sends a payload with a random nonce and waits until it is echoed completely.
+// If the connection is established but the echo is not received within the timeout, the check fails with the class blackhole.
+// A graceful close before the echo usually means the endpoint has no echo handler and is not classified.
+func checkTCPPortEchoFunc(endpoint config.Endpoint, timeout time.Duration, dialer *tcpDialer) (string, int, error) {
+	addr := net.JoinHostPort(endpoint.IP, strconv.Itoa(endpoint.Port))
+	conn, srcPort, err := dialer.dial(addr)
+	if err != nil {
+		return "", srcPort, classifyConnectionError(err)
+	}
+	defer conn.Close()
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		return "", srcPort, err
+	}
+	payload := []byte(fmt.Sprintf("%s%016x\n", echoPayloadPrefix, rand.Uint64()))
+	if _, err := conn.Write(payload); err != nil {
+		return "", srcPort, classifyEchoError(err, timeout, 0, len(payload))
+	}
+	buf := make([]byte, len(payload))
+	n, err := io.ReadFull(conn, buf)
+	if err != nil {
+		return "", srcPort, classifyEchoError(err, timeout, n, len(payload))
+	}
+	if !bytes.Equal(buf, payload) {
+		return "", srcPort, fmt.Errorf("connected, but echo mismatch: received %q", buf)
+	}
+	return fmt.Sprintf("connected, echoed %d bytes in %s", n, time.Since(start).Round(time.Millisecond)), srcPort, nil
+}
+
+// classifyEchoError returns the error with class blackhole if the echo was not received within the timeout.
+func classifyEchoError(err error, timeout time.Duration, received, size int) error {
+	switch {
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return &classifiedError{class: FailureClassBlackhole, err: fmt.Errorf("connected, but no echo within %s (%d/%d bytes received)", timeout, received, size)}
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("connected, but closed by peer before echo (%d/%d bytes received), is there an echo handler?", received, size)
+	default:
+		return classifyConnectionError(err)
+	}
+}
+
+// classifyConnectionError returns the error with class reset-after-connect for resets of an established connection.
+func classifyConnectionError(err error) error {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return &classifiedError{class: FailureClassResetAfterConnect, err: err}
+	}
+	return err
+}
+
+// checkTCPPortConnectionsFunc opens the connections at the same time, so that each one uses a different source port
+// and is hashed independently on ECMP paths and in connection tracking.
+// With a source port range, the connections are bound to different ports of the range.
+func checkTCPPortConnectionsFunc(endpoint config.Endpoint, connections int, dialer *tcpDialer) (string, error) {
+	addr := net.JoinHostPort(endpoint.IP, strconv.Itoa(endpoint.Port))
+	results := make([]string, connections)
+	failed := 0
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+	)
+	for i := 0; i < connections; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, _, err := dialer.dial(addr)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				failed++
+				results[i] = fmt.Sprintf("#%d failed: %s", i+1, err)
+				return
+			}
+			defer conn.Close()
+			results[i] = fmt.Sprintf("#%d connected from %s", i+1, conn.LocalAddr())
+		}(i)
+	}
+	wg.Wait()
+
+	result := strings.Join(results, ", ")
+	switch {
+	case failed == 0:
+		return result, nil
+	case failed < connections:
+		return "", &partialError{msg: fmt.Sprintf("partial: %d/%d connections failed (%s)", failed, connections, result)}
+	default:
+		return "", fmt.Errorf("%d/%d connections failed (%s)", failed, connections, result)
+	}
+}
+
+// tcpDialer opens the connections of the checks, optionally bound to ports of a source port range
+// and to a network interface.
+type tcpDialer struct {
+	ports *sourcePortRange
+	iface string
+}
+
+// dial connects to the address and returns the bound source port, 0 if connected from an ephemeral port.
+// A nil dialer connects from an ephemeral port without binding to an interface.
+func (d *tcpDialer) dial(addr string) (net.Conn, int, error) {
+	var (
+		ports   *sourcePortRange
+		control func(network, address string, c syscall.RawConn) error
+	)
+	if d != nil {
+		ports = d.ports
+		if d.iface != "" {
+			control = bindToDeviceControl(d.iface)
+		}
+	}
+	return ports.dial(addr, control)
+}
+
+// sourcePortRange binds connections to source ports of a range. The ports are used in round robin order,
+// so that consecutive checks do not collide with connections of previous checks still in state TIME_WAIT.
+type sourcePortRange struct {
+	min, max int
+
+	lock sync.Mutex
+	next int
+}
+
+// parseSourcePortRange parses a source port range in format <min>-<max>.
+func parseSourcePortRange(s string) (*sourcePortRange, error) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid source port range %q: expected format <min>-<max>", s)
+	}
+	min, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+	max, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil || min < 1 || max > 65535 || min > max {
+		return nil, fmt.Errorf("invalid source port range %q: expected format <min>-<max> with 1 <= min <= max <= 65535", s)
+	}
+	return &sourcePortRange{min: min, max: max, next: min}, nil
+}
+
+func (r *sourcePortRange) String() string {
+	return fmt.Sprintf("%d-%d", r.min, r.max)
+}
+
+func (r *sourcePortRange) nextPort() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	port := r.next
+	r.next++
+	if r.next > r.max {
+		r.next = r.min
+	}
+	return port
+}
+
+// dial connects to the address from the next free port of the range. Ports which are in use are skipped, at most
+// maxSourcePortAttempts ports are tried. It returns the bound source port, or 0 and connects from an ephemeral port
+// if the range is nil. The optional control function is applied to the sockets before connecting.
+func (r *sourcePortRange) dial(addr string, control func(network, address string, c syscall.RawConn) error) (net.Conn, int, error) {
+	if r == nil {
+		dialer := net.Dialer{Timeout: tcpDialTimeout, Control: control}
+		conn, err := dialer.Dial("tcp", addr)
+		return conn, 0, err
+	}
+	attempts := r.max - r.min + 1
+	if attempts > maxSourcePortAttempts {
+		attempts = maxSourcePortAttempts
+	}
+	var (
+		port int
+		err  error
+	)
+	for i := 0; i < attempts; i++ {
+		port = r.nextPort()
+		dialer := net.Dialer{LocalAddr: &net.TCPAddr{Port: port}, Timeout: tcpDialTimeout, Control: control}
+		var conn net.Conn
+		conn, err = dialer.Dial("tcp", addr)
+		if err == nil {
+			return conn, port, nil
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) && !errors.Is(err, syscall.EADDRNOTAVAIL) {
+			return nil, port, err
+		}
+	}
+	return nil, port, fmt.Errorf("no usable source port in range %s after %d attempts: %w", r, attempts, err)
 }
@@ -0,0 +1,361 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("checkTCPPort", func() {
+	// serve accepts connections and handles them with the given function
+	serve := func(handle func(conn *net.TCPConn)) (net.Listener, config.Endpoint) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				go handle(conn.(*net.TCPConn))
+			}
+		}()
+		return listener, config.Endpoint{Hostname: "local", IP: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port}
+	}
+
+	It("opens multiple connections from different source ports", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		defer listener.Close()
+		endpoint := config.Endpoint{Hostname: "local", IP: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port}
+
+		result, err := checkTCPPortConnectionsFunc(endpoint, 3, nil)
+		Expect(err).To(BeNil())
+		Expect(result).To(ContainSubstring("#1 connected from 127.0.0.1:"))
+		Expect(result).To(ContainSubstring("#3 connected from 127.0.0.1:"))
+	})
+
+	It("marks observations of partially failed checks", func() {
+		calls := 0
+		r := NewCheckTCPPort([]config.Endpoint{{Hostname: "local", IP: "127.0.0.1", Port: 1}}, RunnerConfig{})
+		r.runFunc = func(_ config.Endpoint) (string, error) {
+			calls++
+			if calls == 1 {
+				return "", &partialError{msg: "partial: 1/2 connections failed"}
+			}
+			return "", net.ErrClosed
+		}
+		ch := make(chan *nwpd.Observation, 2)
+		r.Run(context.Background(), ch)
+		r.Run(context.Background(), ch)
+		obs := <-ch
+		Expect(obs.Ok).To(BeFalse())
+		Expect(obs.Partial).To(BeTrue())
+		obs = <-ch
+		Expect(obs.Ok).To(BeFalse())
+		Expect(obs.Partial).To(BeFalse())
+	})
+
+	It("describes the number of connections", func() {
+		r := NewCheckTCPPort([]config.Endpoint{{Hostname: "local", IP: "127.0.0.1", Port: 1}}, RunnerConfig{})
+		Expect(r.Description()).To(Equal("1 endpoints"))
+		Expect(r.withConnections(4).Description()).To(Equal("1 endpoints, 4 connections"))
+	})
+
+	Context("verify data", func() {
+		It("accepts connections staying open", func() {
+			listener, endpoint := serve(func(conn *net.TCPConn) {
+				time.Sleep(500 * time.Millisecond)
+				conn.Close()
+			})
+			defer listener.Close()
+
+			result, _, err := checkTCPPortVerifyDataFunc(endpoint, 100*time.Millisecond, nil, nil)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal("connected, no reset within 100ms"))
+			result, _, err = checkTCPPortVerifyDataFunc(endpoint, 100*time.Millisecond, probePayload, nil)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal("connected, no reset within 100ms"))
+		})
+
+		It("accepts echoed data", func() {
+			listener, endpoint := serve(func(conn *net.TCPConn) {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			})
+			defer listener.Close()
+
+			result, _, err := checkTCPPortVerifyDataFunc(endpoint, time.Second, probePayload, nil)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(fmt.Sprintf("connected, received %d bytes", len(probePayload))))
+		})
+
+		It("classifies resets after connect", func() {
+			listener, endpoint := serve(func(conn *net.TCPConn) {
+				time.Sleep(50 * time.Millisecond)
+				_ = conn.SetLinger(0)
+				conn.Close()
+			})
+			defer listener.Close()
+
+			r := NewCheckTCPPort([]config.Endpoint{endpoint}, RunnerConfig{}).withVerifyData(false)
+			r.verifyWindow = time.Second
+			ch := make(chan *nwpd.Observation, 1)
+			r.Run(context.Background(), ch)
+			obs := <-ch
+			Expect(obs.Ok).To(BeFalse())
+			Expect(obs.FailureClass).To(Equal(FailureClassResetAfterConnect))
+			Expect(obs.Result).To(HavePrefix("error: " + FailureClassResetAfterConnect))
+		})
+
+		It("validates the options", func() {
+			cfg := config.ClusterConfig{}
+			actual, err := Parse(cfg, RunnerConfig{}, []string{"checkTCPPort", "--endpoints", "a:127.0.0.1:80", "--verify-data", "--force-payload"}, false)
+			Expect(err).To(BeNil())
+			Expect(actual.Description()).To(Equal("1 endpoints, verify data with payload"))
+			_, err = Parse(cfg, RunnerConfig{}, []string{"checkTCPPort", "--endpoints", "a:127.0.0.1:80", "--force-payload"}, false)
+			Expect(err).NotTo(BeNil())
+			_, err = Parse(cfg, RunnerConfig{}, []string{"checkTCPPort", "--endpoints", "a:127.0.0.1:80", "--verify-data", "--connections", "2"}, false)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("echo", func() {
+		It("accepts echoed payloads", func() {
+			listener, endpoint := serve(func(conn *net.TCPConn) {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			})
+			defer listener.Close()
+
+			result, _, err := checkTCPPortEchoFunc(endpoint, time.Second, nil)
+			Expect(err).To(BeNil())
+			Expect(result).To(HavePrefix(fmt.Sprintf("connected, echoed %d bytes in ", len(echoPayloadPrefix)+17)))
+		})
+
+		It("classifies connections without echo as blackhole", func() {
+			listener, endpoint := serve(func(conn *net.TCPConn) {
+				defer conn.Close()
+				_, _ = io.Copy(io.Discard, conn)
+			})
+			defer listener.Close()
+
+			r := NewCheckTCPPort([]config.Endpoint{endpoint}, RunnerConfig{}).withEcho(100 * time.Millisecond)
+			ch := make(chan *nwpd.Observation, 1)
+			r.Run(context.Background(), ch)
+			obs := <-ch
+			Expect(obs.Ok).To(BeFalse())
+			Expect(obs.FailureClass).To(Equal(FailureClassBlackhole))
+			Expect(obs.Result).To(Equal("error: blackhole: connected, but no echo within 100ms (0/27 bytes received)"))
+		})
+
+		It("does not classify endpoints closing the connection", func() {
+			listener, endpoint := serve(func(conn *net.TCPConn) {
+				conn.Close()
+			})
+			defer listener.Close()
+
+			_, _, err := checkTCPPortEchoFunc(endpoint, time.Second, nil)
+			Expect(err).NotTo(BeNil())
+			var classified *classifiedError
+			if errors.As(err, &classified) {
+				Expect(classified.class).NotTo(Equal(FailureClassBlackhole))
+			}
+		})
+
+		It("validates the options", func() {
+			cfg := config.ClusterConfig{}
+			actual, err := Parse(cfg, RunnerConfig{}, []string{"checkTCPPort", "--endpoints", "a:127.0.0.1:80", "--echo", "--echo-timeout", "5s"}, false)
+			Expect(err).To(BeNil())
+			Expect(actual.Description()).To(Equal("1 endpoints, echo"))
+			Expect(actual.(*checkTCPPort).echoTimeout).To(Equal(5 * time.Second))
+			_, err = Parse(cfg, RunnerConfig{}, []string{"checkTCPPort", "--endpoints", "a:127.0.0.1:80", "--echo", "--verify-data"}, false)
+			Expect(err).NotTo(BeNil())
+			_, err = Parse(cfg, RunnerConfig{}, []string{"checkTCPPort", "--endpoints", "a:127.0.0.1:80", "--echo", "--connections", "2"}, false)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("source port range", func() {
+		// freePort returns a currently unused local port
+		freePort := func() int {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).To(BeNil())
+			defer listener.Close()
+			return listener.Addr().(*net.TCPAddr).Port
+		}
+
+		It("parses the range", func() {
+			ports, err := parseSourcePortRange("40000-40009")
+			Expect(err).To(BeNil())
+			Expect(ports.String()).To(Equal("40000-40009"))
+			Expect(ports.nextPort()).To(Equal(40000))
+			Expect(ports.nextPort()).To(Equal(40001))
+			for _, invalid := range []string{"40000", "0-10", "10-65536", "20-10", "a-b"} {
+				_, err := parseSourcePortRange(invalid)
+				Expect(err).NotTo(BeNil(), invalid)
+			}
+		})
+
+		It("records the source port", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).To(BeNil())
+			defer listener.Close()
+			endpoint := config.Endpoint{Hostname: "local", IP: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port}
+			port := freePort()
+
+			actual, err := Parse(config.ClusterConfig{}, RunnerConfig{}, []string{"checkTCPPort", "--endpoints",
+				fmt.Sprintf("local:127.0.0.1:%d", endpoint.Port), "--source-port-range", fmt.Sprintf("%d-%d", port, port)}, false)
+			Expect(err).To(BeNil())
+			Expect(actual.Description()).To(Equal(fmt.Sprintf("1 endpoints, source ports %d-%d", port, port)))
+			ch := make(chan *nwpd.Observation, 1)
+			actual.Run(context.Background(), ch)
+			obs := <-ch
+			Expect(obs.Ok).To(BeTrue(), obs.Result)
+			Expect(obs.SrcPort).To(Equal(int32(port)))
+			Expect(obs.Result).To(Equal(fmt.Sprintf("connected from port %d", port)))
+		})
+
+		It("skips ports in use", func() {
+			used, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).To(BeNil())
+			defer used.Close()
+			port := used.Addr().(*net.TCPAddr).Port
+
+			_, srcPort, err := checkTCPPortBoundFunc(config.Endpoint{IP: "127.0.0.1", Port: port}, &tcpDialer{ports: &sourcePortRange{min: port, max: port, next: port}})
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(HavePrefix("no usable source port in range"))
+			Expect(srcPort).To(Equal(port))
+		})
+
+		It("validates the range", func() {
+			_, err := Parse(config.ClusterConfig{}, RunnerConfig{}, []string{"checkTCPPort", "--endpoints", "a:127.0.0.1:80", "--source-port-range", "100"}, false)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("interface", func() {
+		It("binds the connections to the interface and records it", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).To(BeNil())
+			defer listener.Close()
+			port := listener.Addr().(*net.TCPAddr).Port
+
+			actual, err := Parse(config.ClusterConfig{}, RunnerConfig{}, []string{"checkTCPPort", "--endpoints",
+				fmt.Sprintf("local:127.0.0.1:%d", port), "--interface", "lo"}, false)
+			Expect(err).To(BeNil())
+			Expect(actual.Description()).To(Equal("1 endpoints, interface lo"))
+			ch := make(chan *nwpd.Observation, 1)
+			actual.Run(context.Background(), ch)
+			obs := <-ch
+			if strings.Contains(obs.Result, "not permitted") || strings.Contains(obs.Result, "not supported") {
+				Skip("binding to interfaces not available: " + obs.Result)
+			}
+			Expect(obs.Ok).To(BeTrue(), obs.Result)
+			Expect(obs.Interface).To(Equal("lo"))
+		})
+
+		It("validates the interface name", func() {
+			for _, invalid := range []string{"eth0/1", "eth 0", "a-very-long-interface"} {
+				_, err := Parse(config.ClusterConfig{}, RunnerConfig{}, []string{"checkTCPPort", "--endpoints", "a:127.0.0.1:80", "--interface", invalid}, false)
+				Expect(err).NotTo(BeNil(), invalid)
+			}
+		})
+
+		It("is only supported on the host network", func() {
+			job := config.Job{JobID: "tcp-eth1", Args: []string{"checkTCPPort", "--endpoints", "a:127.0.0.1:80", "--interface=eth1"}}
+			Expect(ValidateAgentConfig(&config.AgentConfig{HostNetwork: &config.NetworkConfig{Jobs: []config.Job{job}}})).To(Succeed())
+			err := ValidateAgentConfig(&config.AgentConfig{PodNetwork: &config.NetworkConfig{Jobs: []config.Job{job}}})
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(Equal("job tcp-eth1: checkTCPPort --interface is only supported on the host network"))
+		})
+	})
+
+	It("records source and version of the endpoint list loaded from a URL", func() {
+		list := &config.EndpointList{
+			Source:    "https://config.example.com/endpoints",
+			Version:   "v7",
+			Endpoints: []config.Endpoint{{Hostname: "db", IP: "127.0.0.1", Port: 1}},
+		}
+		actual, err := Parse(config.ClusterConfig{URLEndpoints: list}, RunnerConfig{}, []string{"checkTCPPort", "--endpoints-of-url-list"}, false)
+		Expect(err).To(BeNil())
+		r := actual.(*checkTCPPort)
+		r.runFunc = func(_ config.Endpoint) (string, error) { return "ok", nil }
+		ch := make(chan *nwpd.Observation, 1)
+		r.Run(context.Background(), ch)
+		obs := <-ch
+		Expect(obs.DestHost).To(Equal("db"))
+		Expect(obs.ListSource).To(Equal(list.Source))
+		Expect(obs.ListVersion).To(Equal("v7"))
+	})
+
+	It("checks the nodes by their external IPs", func() {
+		cfg := config.ClusterConfig{Nodes: []config.Node{
+			{Hostname: "node1", InternalIP: "10.0.0.1", ExternalIP: "127.0.0.1"},
+			{Hostname: "node2", InternalIP: "10.0.0.2"},
+		}}
+		actual, err := Parse(cfg, RunnerConfig{}, []string{"checkTCPPort", "--node-port", "1", "--node-address-type", "external"}, false)
+		Expect(err).To(BeNil())
+		r := actual.(*checkTCPPort)
+		Expect(r.items).To(Equal([]config.Endpoint{{Hostname: "node1", IP: "127.0.0.1", Port: 1}}))
+		r.runFunc = func(_ config.Endpoint) (string, error) { return "ok", nil }
+		ch := make(chan *nwpd.Observation, 1)
+		r.Run(context.Background(), ch)
+		obs := <-ch
+		Expect(obs.DestHost).To(Equal("node1"))
+		Expect(obs.DestAddressType).To(Equal(config.NodeAddressTypeExternal))
+
+		actual, err = Parse(cfg, RunnerConfig{}, []string{"checkTCPPort", "--node-port", "1"}, false)
+		Expect(err).To(BeNil())
+		Expect(actual.(*checkTCPPort).items).To(HaveLen(2))
+		Expect(actual.(*checkTCPPort).destAddressType).To(Equal(config.NodeAddressTypeInternal))
+		_, err = Parse(cfg, RunnerConfig{}, []string{"checkTCPPort", "--node-port", "1", "--node-address-type", "public"}, false)
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("records the observations of recently started pods as warming up", func() {
+		started := metav1.NewTime(time.Now().Add(-30 * time.Second))
+		startedBefore := metav1.NewTime(time.Now().Add(-5 * time.Minute))
+		cfg := config.ClusterConfig{PodEndpoints: []config.PodEndpoint{
+			{Nodename: "node1", Podname: "pod1", PodIP: "127.0.0.1", Port: 1, StartTime: &started},
+			{Nodename: "node2", Podname: "pod2", PodIP: "127.0.0.2", Port: 1, StartTime: &startedBefore},
+			{Nodename: "node3", Podname: "pod3", PodIP: "127.0.0.3", Port: 1},
+		}}
+		actual, err := Parse(cfg, RunnerConfig{}, []string{"checkTCPPort", "--endpoints-of-pod-ds"}, false)
+		Expect(err).To(BeNil())
+		r := actual.(*checkTCPPort)
+		r.runFunc = func(_ config.Endpoint) (string, error) { return "", fmt.Errorf("connection refused") }
+		warmingUp := map[string]bool{}
+		for range r.items {
+			ch := make(chan *nwpd.Observation, 1)
+			r.Run(context.Background(), ch)
+			obs := <-ch
+			Expect(obs.Ok).To(BeFalse())
+			warmingUp[obs.DestHost] = obs.WarmingUp
+		}
+		Expect(warmingUp).To(Equal(map[string]bool{"node1": true, "node2": false, "node3": false}))
+
+		actual, err = Parse(cfg, RunnerConfig{}, []string{"checkTCPPort", "--endpoints-of-pod-ds", "--warm-up-period", "0"}, false)
+		Expect(err).To(BeNil())
+		Expect(actual.(*checkTCPPort).warmUp).To(BeNil())
+		actual, err = Parse(cfg, RunnerConfig{}, []string{"checkTCPPort", "--endpoints-of-pod-ds", "--warm-up-period", "10s"}, false)
+		Expect(err).To(BeNil())
+		Expect(actual.(*checkTCPPort).warmUp.isWarmingUp("node1", time.Now())).To(BeFalse())
+		_, err = Parse(cfg, RunnerConfig{}, []string{"checkTCPPort", "--endpoints-of-pod-ds", "--warm-up-period", "-1s"}, false)
+		Expect(err).NotTo(BeNil())
+	})
+})
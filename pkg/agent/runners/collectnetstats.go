@@ -0,0 +1,352 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// CollectNetStatsCmd is the runner command for sampling the kernel network counters. It is only supported on the host network.
+	CollectNetStatsCmd = "collectNetStats"
+	// DefaultNetStatsPeriod is the default period of sampling the kernel network counters
+	DefaultNetStatsPeriod = 1 * time.Minute
+	// DefaultNetStatsExcludeInterfaces excludes the loopback interface and the host side of the pod interfaces of common CNI plugins
+	DefaultNetStatsExcludeInterfaces = `^(lo|veth.*|cali.*|lxc.*)$`
+	// NetStatsTCPDestHost is the destination host of the observations with the TCP counters
+	NetStatsTCPDestHost = common.DestHostPrefixNetStats + "tcp"
+)
+
+// netStatsThresholds are the maximum deltas of the counters per period. Negative values disable the check.
+type netStatsThresholds struct {
+	drops          int64
+	errors         int64
+	tcpRetransmits int64
+}
+
+type collectNetStatsArgs struct {
+	runnerArgs        *runnerArgs
+	interfaces        string
+	excludeInterfaces string
+	thresholds        netStatsThresholds
+}
+
+func (a *collectNetStatsArgs) createRunner(cmd *cobra.Command, args []string) error {
+	var include *regexp.Regexp
+	if a.interfaces != "" {
+		var err error
+		if include, err = regexp.Compile(a.interfaces); err != nil {
+			return fmt.Errorf("invalid --interfaces: %w", err)
+		}
+	}
+	var exclude *regexp.Regexp
+	if a.excludeInterfaces != "" {
+		var err error
+		if exclude, err = regexp.Compile(a.excludeInterfaces); err != nil {
+			return fmt.Errorf("invalid --exclude-interfaces: %w", err)
+		}
+	}
+
+	config := a.runnerArgs.prepareConfig()
+	if a.runnerArgs.period == 0 {
+		config.Period = DefaultNetStatsPeriod
+	}
+	a.runnerArgs.runner = NewCollectNetStats(include, exclude, a.thresholds, config)
+	return nil
+}
+
+func createCollectNetStatsCmd(ra *runnerArgs) *cobra.Command {
+	a := &collectNetStatsArgs{runnerArgs: ra}
+	cmd := &cobra.Command{
+		Use:   CollectNetStatsCmd,
+		Short: "samples the drop and error counters of the network interfaces and the TCP retransmits of the node (host network only)",
+		RunE:  a.createRunner,
+	}
+	cmd.Flags().StringVar(&a.interfaces, "interfaces", "", "regular expression for the names of the interfaces to sample (default all).")
+	cmd.Flags().StringVar(&a.excludeInterfaces, "exclude-interfaces", DefaultNetStatsExcludeInterfaces, "regular expression for the names of the interfaces to skip.")
+	cmd.Flags().Int64Var(&a.thresholds.drops, "max-drops", 100, "maximum number of dropped packets (rx and tx) of an interface per period (negative to disable).")
+	cmd.Flags().Int64Var(&a.thresholds.errors, "max-errors", 10, "maximum number of errors (rx and tx) of an interface per period (negative to disable).")
+	cmd.Flags().Int64Var(&a.thresholds.tcpRetransmits, "max-tcp-retransmits", 1000, "maximum number of retransmitted TCP segments per period (negative to disable).")
+	return cmd
+}
+
+// interfaceCounters are the counters of /sys/class/net/<interface>/statistics.
+type interfaceCounters struct {
+	rxDropped int64
+	txDropped int64
+	rxErrors  int64
+	txErrors  int64
+}
+
+// tcpCounters are the counters of the 'Tcp:' lines of /proc/net/snmp.
+type tcpCounters struct {
+	outSegs     int64
+	retransSegs int64
+	inErrs      int64
+}
+
+type netStatsSample struct {
+	interfaces map[string]interfaceCounters
+	tcp        tcpCounters
+}
+
+// NewCollectNetStats creates a runner sampling the kernel network counters. The included interfaces are all if include is nil.
+func NewCollectNetStats(include, exclude *regexp.Regexp, thresholds netStatsThresholds, rconfig RunnerConfig) *collectNetStats {
+	return &collectNetStats{
+		config:     rconfig,
+		include:    include,
+		exclude:    exclude,
+		thresholds: thresholds,
+		root:       "/",
+	}
+}
+
+// collectNetStats samples the counters each period and records the deltas to the previous sample as observations,
+// one per interface and one with the TCP counters. It records no observations for the first sample.
+type collectNetStats struct {
+	config     RunnerConfig
+	include    *regexp.Regexp
+	exclude    *regexp.Regexp
+	thresholds netStatsThresholds
+	// root is the root directory of /proc and /sys, replaced in tests
+	root string
+
+	lock sync.Mutex
+	last *netStatsSample
+}
+
+var _ Runner = &collectNetStats{}
+
+func (r *collectNetStats) Config() RunnerConfig {
+	return r.config
+}
+
+func (r *collectNetStats) Description() string {
+	include := "all"
+	if r.include != nil {
+		include = r.include.String()
+	}
+	exclude := "none"
+	if r.exclude != nil {
+		exclude = r.exclude.String()
+	}
+	return fmt.Sprintf("network counters of interfaces %s excluding %s", include, exclude)
+}
+
+func (r *collectNetStats) TestData() any {
+	return []any{r.Description(), r.thresholds}
+}
+
+// DestHosts returns the interfaces of the last sample and the destination host of the TCP counters.
+func (r *collectNetStats) DestHosts() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.last == nil {
+		return nil
+	}
+	hosts := []string{NetStatsTCPDestHost}
+	for name := range r.last.interfaces {
+		hosts = append(hosts, common.DestHostPrefixNetStats+name)
+	}
+	sort.Strings(hosts[1:])
+	return hosts
+}
+
+func (r *collectNetStats) Run(ctx context.Context, ch chan<- *nwpd.Observation) {
+	if ctx.Err() != nil {
+		return
+	}
+	start := time.Now()
+	sample, err := r.sample()
+	duration := time.Since(start)
+
+	r.lock.Lock()
+	last := r.last
+	if err == nil {
+		r.last = sample
+	}
+	r.lock.Unlock()
+
+	newObs := func(destHost string) *nwpd.Observation {
+		return &nwpd.Observation{
+			SrcHost:   GetNodeName(),
+			DestHost:  destHost,
+			Timestamp: timestamppb.New(start),
+			JobID:     r.config.JobID,
+			Labels:    r.config.Labels,
+			Protocol:  nwpd.ProtocolNetStats,
+			Duration:  durationpb.New(duration),
+			Period:    durationpb.New(r.config.Period),
+		}
+	}
+	if err != nil {
+		obs := newObs(NetStatsTCPDestHost)
+		obs.Result = fmt.Sprintf("error: %s", err)
+		sendObservation(ctx, ch, obs)
+		return
+	}
+	if last == nil {
+		return
+	}
+
+	names := make([]string, 0, len(sample.interfaces))
+	for name := range sample.interfaces {
+		if _, ok := last.interfaces[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		obs := newObs(common.DestHostPrefixNetStats + name)
+		obs.Result, obs.Ok = r.interfaceResult(sample.interfaces[name], last.interfaces[name])
+		if !sendObservation(ctx, ch, obs) {
+			return
+		}
+	}
+	obs := newObs(NetStatsTCPDestHost)
+	obs.Result, obs.Ok = r.tcpResult(sample.tcp, last.tcp)
+	sendObservation(ctx, ch, obs)
+}
+
+func (r *collectNetStats) interfaceResult(current, last interfaceCounters) (string, bool) {
+	rxDropped := counterDelta(current.rxDropped, last.rxDropped)
+	txDropped := counterDelta(current.txDropped, last.txDropped)
+	rxErrors := counterDelta(current.rxErrors, last.rxErrors)
+	txErrors := counterDelta(current.txErrors, last.txErrors)
+	result := fmt.Sprintf("rx_dropped=%d tx_dropped=%d rx_errors=%d tx_errors=%d", rxDropped, txDropped, rxErrors, txErrors)
+	if exceeds(rxDropped+txDropped, r.thresholds.drops) {
+		return fmt.Sprintf("error: %d dropped packets exceed %d (%s)", rxDropped+txDropped, r.thresholds.drops, result), false
+	}
+	if exceeds(rxErrors+txErrors, r.thresholds.errors) {
+		return fmt.Sprintf("error: %d errors exceed %d (%s)", rxErrors+txErrors, r.thresholds.errors, result), false
+	}
+	return result, true
+}
+
+func (r *collectNetStats) tcpResult(current, last tcpCounters) (string, bool) {
+	retransSegs := counterDelta(current.retransSegs, last.retransSegs)
+	outSegs := counterDelta(current.outSegs, last.outSegs)
+	inErrs := counterDelta(current.inErrs, last.inErrs)
+	result := fmt.Sprintf("retrans_segs=%d out_segs=%d in_errs=%d", retransSegs, outSegs, inErrs)
+	if exceeds(retransSegs, r.thresholds.tcpRetransmits) {
+		return fmt.Sprintf("error: %d retransmitted segments exceed %d (%s)", retransSegs, r.thresholds.tcpRetransmits, result), false
+	}
+	return result, true
+}
+
+// counterDelta returns the increase of the counter. After a reset of the counter, e.g. by recreating the interface,
+// the current value is the increase.
+func counterDelta(current, last int64) int64 {
+	if current < last {
+		return current
+	}
+	return current - last
+}
+
+func exceeds(delta, threshold int64) bool {
+	return threshold >= 0 && delta > threshold
+}
+
+func (r *collectNetStats) sample() (*netStatsSample, error) {
+	tcp, err := readTCPCounters(filepath.Join(r.root, "proc/net/snmp"))
+	if err != nil {
+		return nil, err
+	}
+	sample := &netStatsSample{interfaces: map[string]interfaceCounters{}, tcp: tcp}
+	dir := filepath.Join(r.root, "sys/class/net")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if r.include != nil && !r.include.MatchString(name) || r.exclude != nil && r.exclude.MatchString(name) {
+			continue
+		}
+		counters, err := readInterfaceCounters(filepath.Join(dir, name, "statistics"))
+		if err != nil {
+			// the interface may have been deleted meanwhile
+			continue
+		}
+		sample.interfaces[name] = counters
+	}
+	return sample, nil
+}
+
+func readInterfaceCounters(dir string) (interfaceCounters, error) {
+	var counters interfaceCounters
+	for name, value := range map[string]*int64{
+		"rx_dropped": &counters.rxDropped,
+		"tx_dropped": &counters.txDropped,
+		"rx_errors":  &counters.rxErrors,
+		"tx_errors":  &counters.txErrors,
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return counters, err
+		}
+		if *value, err = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err != nil {
+			return counters, fmt.Errorf("invalid counter %s of %s: %w", name, dir, err)
+		}
+	}
+	return counters, nil
+}
+
+// readTCPCounters reads the TCP counters from the header and value lines starting with 'Tcp:'.
+func readTCPCounters(filename string) (tcpCounters, error) {
+	var counters tcpCounters
+	file, err := os.Open(filename)
+	if err != nil {
+		return counters, err
+	}
+	defer file.Close()
+
+	var lines [][]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 && fields[0] == "Tcp:" {
+			lines = append(lines, fields[1:])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return counters, err
+	}
+	if len(lines) != 2 || len(lines[0]) != len(lines[1]) {
+		return counters, fmt.Errorf("missing TCP counters in %s", filename)
+	}
+	values := map[string]*int64{
+		"OutSegs":     &counters.outSegs,
+		"RetransSegs": &counters.retransSegs,
+		"InErrs":      &counters.inErrs,
+	}
+	for i, name := range lines[0] {
+		if value, ok := values[name]; ok {
+			if *value, err = strconv.ParseInt(lines[1][i], 10, 64); err != nil {
+				return counters, fmt.Errorf("invalid TCP counter %s in %s: %w", name, filename, err)
+			}
+			delete(values, name)
+		}
+	}
+	if len(values) > 0 {
+		return counters, fmt.Errorf("missing TCP counters in %s", filename)
+	}
+	return counters, nil
+}
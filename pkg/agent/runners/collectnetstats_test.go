@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("collectNetStats", func() {
+	var (
+		rconfig = RunnerConfig{Job: config.Job{JobID: "netstats"}, Period: 15 * time.Second}
+		root    string
+	)
+
+	writeInterface := func(name string, rxDropped, txDropped, rxErrors, txErrors int) {
+		dir := filepath.Join(root, "sys/class/net", name, "statistics")
+		Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+		for counter, value := range map[string]int{"rx_dropped": rxDropped, "tx_dropped": txDropped, "rx_errors": rxErrors, "tx_errors": txErrors} {
+			Expect(os.WriteFile(filepath.Join(dir, counter), []byte(fmt.Sprintf("%d\n", value)), 0644)).To(Succeed())
+		}
+	}
+	writeSNMP := func(outSegs, retransSegs int) {
+		Expect(os.MkdirAll(filepath.Join(root, "proc/net"), 0755)).To(Succeed())
+		content := "Ip: Forwarding DefaultTTL\nIp: 1 64\n" +
+			"Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens InSegs OutSegs RetransSegs InErrs\n" +
+			fmt.Sprintf("Tcp: 1 200 120000 -1 42 1000 %d %d 0\n", outSegs, retransSegs)
+		Expect(os.WriteFile(filepath.Join(root, "proc/net/snmp"), []byte(content), 0644)).To(Succeed())
+	}
+	run := func(r *collectNetStats) []*nwpd.Observation {
+		ch := make(chan *nwpd.Observation, 10)
+		r.Run(context.Background(), ch)
+		close(ch)
+		var result []*nwpd.Observation
+		for obs := range ch {
+			result = append(result, obs)
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		var err error
+		root, err = os.MkdirTemp("", "netstats")
+		Expect(err).To(BeNil())
+	})
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	It("parses the arguments", func() {
+		actual, err := Parse(config.ClusterConfig{}, rconfig, []string{CollectNetStatsCmd}, false)
+		Expect(err).To(BeNil())
+		Expect(actual.Config().Period).To(Equal(DefaultNetStatsPeriod))
+		Expect(actual.TestData()).To(Equal([]any{
+			"network counters of interfaces all excluding " + DefaultNetStatsExcludeInterfaces,
+			netStatsThresholds{drops: 100, errors: 10, tcpRetransmits: 1000},
+		}))
+
+		actual, err = Parse(config.ClusterConfig{}, rconfig, []string{CollectNetStatsCmd, "--period", "30s", "--interfaces", "^eth", "--exclude-interfaces", "", "--max-drops", "-1"}, false)
+		Expect(err).To(BeNil())
+		Expect(actual.Config().Period).To(Equal(30 * time.Second))
+		Expect(actual.TestData()).To(Equal([]any{
+			"network counters of interfaces ^eth excluding none",
+			netStatsThresholds{drops: -1, errors: 10, tcpRetransmits: 1000},
+		}))
+
+		_, err = Parse(config.ClusterConfig{}, rconfig, []string{CollectNetStatsCmd, "--interfaces", "("}, false)
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("records the deltas of the counters and flags exceeded thresholds", func() {
+		writeSNMP(1000, 10)
+		writeInterface("eth0", 5, 0, 0, 0)
+		writeInterface("lo", 0, 0, 0, 0)
+		writeInterface("veth1234", 0, 0, 0, 0)
+		actual, err := Parse(config.ClusterConfig{}, rconfig, []string{CollectNetStatsCmd, "--max-tcp-retransmits", "50"}, false)
+		Expect(err).To(BeNil())
+		r := actual.(*collectNetStats)
+		r.root = root
+
+		Expect(run(r)).To(BeEmpty(), "no deltas for the first sample")
+		Expect(r.DestHosts()).To(Equal([]string{NetStatsTCPDestHost, "netstats-eth0"}))
+
+		writeSNMP(2000, 100)
+		writeInterface("eth0", 205, 0, 1, 0)
+		writeInterface("eth1", 0, 0, 0, 0)
+		observations := run(r)
+		Expect(observations).To(HaveLen(2), "new interfaces are recorded from the next sample")
+		Expect(observations[0].DestHost).To(Equal("netstats-eth0"))
+		Expect(observations[0].Protocol).To(Equal(nwpd.ProtocolNetStats))
+		Expect(observations[0].Ok).To(BeFalse())
+		Expect(observations[0].Result).To(Equal("error: 200 dropped packets exceed 100 (rx_dropped=200 tx_dropped=0 rx_errors=1 tx_errors=0)"))
+		Expect(observations[1].DestHost).To(Equal(NetStatsTCPDestHost))
+		Expect(observations[1].Ok).To(BeFalse())
+		Expect(observations[1].Result).To(HavePrefix("error: 90 retransmitted segments exceed 50"))
+
+		writeSNMP(3000, 110)
+		// the counters of a recreated interface start again
+		writeInterface("eth0", 3, 0, 0, 0)
+		observations = run(r)
+		Expect(observations).To(HaveLen(3))
+		for _, obs := range observations {
+			Expect(obs.Ok).To(BeTrue(), obs.Result)
+		}
+		Expect(observations[0].Result).To(Equal("rx_dropped=3 tx_dropped=0 rx_errors=0 tx_errors=0"))
+		Expect(observations[2].Result).To(Equal("retrans_segs=10 out_segs=1000 in_errs=0"))
+	})
+
+	It("records a failed observation if the counters cannot be read", func() {
+		r := NewCollectNetStats(nil, nil, netStatsThresholds{}, rconfig)
+		r.root = root
+		observations := run(r)
+		Expect(observations).To(HaveLen(1))
+		Expect(observations[0].Ok).To(BeFalse())
+		Expect(strings.HasPrefix(observations[0].Result, "error: ")).To(BeTrue())
+	})
+
+	It("is only supported on the host network", func() {
+		job := config.Job{JobID: "netstats", Args: []string{CollectNetStatsCmd}}
+		Expect(ValidateAgentConfig(&config.AgentConfig{HostNetwork: &config.NetworkConfig{Jobs: []config.Job{job}}})).To(Succeed())
+		Expect(ValidateAgentConfig(&config.AgentConfig{PodNetwork: &config.NetworkConfig{Jobs: []config.Job{job}}})).NotTo(Succeed())
+	})
+})
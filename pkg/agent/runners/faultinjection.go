@@ -0,0 +1,240 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+// FailureClassInjected is the default failure class of the observations of injected failures.
+const FailureClassInjected = "injected"
+
+// FaultPolicy contains the synthetic faults applied to the checks of the runners for end-to-end testing of the detector.
+type FaultPolicy struct {
+	Faults []Fault `json:"faults"`
+}
+
+// Fault is a synthetic failure or delay of the checks matching all given selectors.
+// The selectors jobID, srcHost and destHost support '*' for globbing, empty selectors match all checks.
+type Fault struct {
+	JobID    string `json:"jobID,omitempty"`
+	SrcHost  string `json:"srcHost,omitempty"`
+	DestHost string `json:"destHost,omitempty"`
+	// DestZone matches the destinations in the zone of the cluster config (nodes and their pods)
+	DestZone string `json:"destZone,omitempty"`
+	// Fail fails all matching checks without running them
+	Fail bool `json:"fail,omitempty"`
+	// FailRate fails the matching checks with this probability (0..1) without running them
+	FailRate float64 `json:"failRate,omitempty"`
+	// FailureClass is the failure class of the failed checks (default 'injected')
+	FailureClass string `json:"failureClass,omitempty"`
+	// Delay is added to the duration of the matching checks by waiting before running them
+	Delay *metav1.Duration `json:"delay,omitempty"`
+}
+
+// ParseFaultPolicy parses and validates a fault policy in YAML format.
+func ParseFaultPolicy(data []byte) (*FaultPolicy, error) {
+	policy := &FaultPolicy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("invalid fault policy: %w", err)
+	}
+	for i, f := range policy.Faults {
+		if !f.Fail && f.FailRate == 0 && f.Delay == nil {
+			return nil, fmt.Errorf("invalid fault %d: one of fail, failRate or delay is required", i)
+		}
+		if f.FailRate < 0 || f.FailRate > 1 {
+			return nil, fmt.Errorf("invalid fault %d: failRate must be in range 0..1", i)
+		}
+		if f.Delay != nil && f.Delay.Duration < 0 {
+			return nil, fmt.Errorf("invalid fault %d: delay must not be negative", i)
+		}
+	}
+	return policy, nil
+}
+
+// compiledFault is a fault with compiled selectors.
+type compiledFault struct {
+	Fault
+	jobID    *regexp.Regexp
+	srcHost  *regexp.Regexp
+	destHost *regexp.Regexp
+}
+
+func globRegexp(pattern string) *regexp.Regexp {
+	if pattern == "" {
+		return nil
+	}
+	return regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$")
+}
+
+func matchGlob(re *regexp.Regexp, value string) bool {
+	return re == nil || re.MatchString(value)
+}
+
+// faultInjector applies the faults of the policy to the checks. It is only created if fault injection is enabled.
+type faultInjector struct {
+	lock   sync.RWMutex
+	faults []*compiledFault
+	// zones maps the node and pod names of the cluster config to the zone of the node
+	zones map[string]string
+	// onInjected is called for each check with an injected fault
+	onInjected func(jobID string)
+}
+
+// injectedFault is the outcome of the faults for a single check.
+type injectedFault struct {
+	fail         bool
+	failureClass string
+	delay        time.Duration
+}
+
+var (
+	faultInjectionLock sync.Mutex
+	// faultInjection contains the *faultInjector, so that the checks only need an atomic load while it is disabled
+	faultInjection atomic.Value
+)
+
+// EnableFaultInjection makes the runners consult the fault policy before running the checks.
+// onInjected is called for each check with an injected fault (may be nil). Without calling it, the fault injection is inert.
+func EnableFaultInjection(onInjected func(jobID string)) {
+	faultInjectionLock.Lock()
+	defer faultInjectionLock.Unlock()
+	if getFaultInjector() == nil {
+		faultInjection.Store(&faultInjector{onInjected: onInjected})
+	}
+}
+
+// DisableFaultInjection removes the fault injection, e.g. at the end of a test.
+func DisableFaultInjection() {
+	faultInjectionLock.Lock()
+	defer faultInjectionLock.Unlock()
+	faultInjection.Store((*faultInjector)(nil))
+}
+
+func getFaultInjector() *faultInjector {
+	injector, _ := faultInjection.Load().(*faultInjector)
+	return injector
+}
+
+// FaultInjectionEnabled returns true if EnableFaultInjection has been called.
+func FaultInjectionEnabled() bool {
+	return getFaultInjector() != nil
+}
+
+// SetFaultPolicy replaces the faults applied to the checks. A nil policy removes all faults.
+// It fails if the fault injection is not enabled.
+func SetFaultPolicy(policy *FaultPolicy) error {
+	injector := getFaultInjector()
+	if injector == nil {
+		return fmt.Errorf("fault injection is not enabled")
+	}
+	var faults []*compiledFault
+	if policy != nil {
+		for _, f := range policy.Faults {
+			faults = append(faults, &compiledFault{
+				Fault:    f,
+				jobID:    globRegexp(f.JobID),
+				srcHost:  globRegexp(f.SrcHost),
+				destHost: globRegexp(f.DestHost),
+			})
+		}
+	}
+	injector.lock.Lock()
+	defer injector.lock.Unlock()
+	injector.faults = faults
+	return nil
+}
+
+// SetFaultZones sets the zones of the destinations from the cluster config for the selector destZone.
+// It does nothing if the fault injection is not enabled.
+func SetFaultZones(cfg *config.ClusterConfig) {
+	injector := getFaultInjector()
+	if injector == nil || cfg == nil {
+		return
+	}
+	zones := map[string]string{}
+	for _, node := range cfg.Nodes {
+		if node.Zone != "" {
+			zones[node.Hostname] = node.Zone
+		}
+	}
+	for _, pod := range cfg.PodEndpoints {
+		if zone := zones[pod.Nodename]; zone != "" {
+			zones[pod.Podname] = zone
+		}
+	}
+	injector.lock.Lock()
+	defer injector.lock.Unlock()
+	injector.zones = zones
+}
+
+// lookupFault returns the combined faults matching the check, nil if the fault injection is disabled or no fault matches.
+// A check fails if any matching fault fails it, the delays of all matching faults are added.
+func lookupFault(jobID, srcHost, destHost string) *injectedFault {
+	injector := getFaultInjector()
+	if injector == nil {
+		return nil
+	}
+	injector.lock.RLock()
+	var result *injectedFault
+	for _, f := range injector.faults {
+		if !matchGlob(f.jobID, jobID) || !matchGlob(f.srcHost, srcHost) || !matchGlob(f.destHost, destHost) {
+			continue
+		}
+		if f.DestZone != "" && injector.zones[destHost] != f.DestZone {
+			continue
+		}
+		if result == nil {
+			result = &injectedFault{}
+		}
+		if !result.fail && (f.Fail || (f.FailRate > 0 && rand.Float64() < f.FailRate)) {
+			result.fail = true
+			result.failureClass = f.FailureClass
+			if result.failureClass == "" {
+				result.failureClass = FailureClassInjected
+			}
+		}
+		if f.Delay != nil {
+			result.delay += f.Delay.Duration
+		}
+	}
+	onInjected := injector.onInjected
+	injector.lock.RUnlock()
+	if result == nil || (!result.fail && result.delay == 0) {
+		return nil
+	}
+	if onInjected != nil {
+		onInjected(jobID)
+	}
+	return result
+}
+
+// apply waits for the delay of the fault. It returns the error of an injected failure, nil if the check should be run.
+func (f *injectedFault) apply(ctx context.Context) error {
+	if f.delay > 0 {
+		timer := time.NewTimer(f.delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+	if f.fail {
+		return &classifiedError{class: f.failureClass, err: fmt.Errorf("injected fault")}
+	}
+	return nil
+}
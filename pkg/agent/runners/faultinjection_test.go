@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/atomic"
+)
+
+var _ = Describe("fault injection", func() {
+	var injected atomic.Int32
+
+	setPolicy := func(yaml string) {
+		policy, err := ParseFaultPolicy([]byte(yaml))
+		Expect(err).To(BeNil())
+		Expect(SetFaultPolicy(policy)).To(Succeed())
+	}
+	run := func(r Runner) *nwpd.Observation {
+		ch := make(chan *nwpd.Observation, 1)
+		r.Run(context.Background(), ch)
+		return <-ch
+	}
+
+	BeforeEach(func() {
+		injected.Store(0)
+		sharedConnectionResults = newConnectionResults()
+		EnableFaultInjection(func(string) { injected.Inc() })
+	})
+
+	AfterEach(func() {
+		DisableFaultInjection()
+	})
+
+	It("validates the fault policy", func() {
+		_, err := ParseFaultPolicy([]byte("faults:\n- jobID: tcp-n2n"))
+		Expect(err).NotTo(BeNil())
+		_, err = ParseFaultPolicy([]byte("faults:\n- failRate: 1.5"))
+		Expect(err).NotTo(BeNil())
+		_, err = ParseFaultPolicy([]byte("faults:\n- fail: true\n  unknown: x"))
+		Expect(err).NotTo(BeNil())
+		policy, err := ParseFaultPolicy([]byte("faults:\n- destHost: node-*\n  delay: 50ms"))
+		Expect(err).To(BeNil())
+		Expect(policy.Faults[0].Delay.Duration).To(Equal(50 * time.Millisecond))
+	})
+
+	It("is inert if not enabled", func() {
+		setPolicy("faults:\n- fail: true")
+		DisableFaultInjection()
+		Expect(FaultInjectionEnabled()).To(BeFalse())
+		Expect(lookupFault("tcp-n2n", "node-a", "node-b")).To(BeNil())
+		Expect(SetFaultPolicy(nil)).NotTo(Succeed())
+	})
+
+	It("fails the checks to a node", func() {
+		setPolicy("faults:\n- jobID: tcp-*\n  destHost: node-b\n  fail: true\n  failureClass: blackhole")
+		fault := lookupFault("tcp-n2n", "node-a", "node-b")
+		Expect(fault).NotTo(BeNil())
+		Expect(fault.fail).To(BeTrue())
+		Expect(fault.failureClass).To(Equal("blackhole"))
+		Expect(lookupFault("tcp-n2n", "node-a", "node-c")).To(BeNil())
+		Expect(lookupFault("ping-n2n", "node-a", "node-b")).To(BeNil())
+		Expect(injected.Load()).To(Equal(int32(1)))
+	})
+
+	It("fails the checks with the fail rate", func() {
+		setPolicy("faults:\n- failRate: 0.5")
+		failed := 0
+		for i := 0; i < 1000; i++ {
+			if fault := lookupFault("tcp-n2n", "node-a", "node-b"); fault != nil && fault.fail {
+				Expect(fault.failureClass).To(Equal(FailureClassInjected))
+				failed++
+			}
+		}
+		Expect(failed).To(BeNumerically("~", 500, 100))
+		Expect(injected.Load()).To(Equal(int32(failed)))
+	})
+
+	It("delays the checks to a zone", func() {
+		setPolicy("faults:\n- destZone: zone-b\n  delay: 20ms\n- destZone: zone-b\n  delay: 10ms")
+		SetFaultZones(&config.ClusterConfig{
+			Nodes:        []config.Node{{Hostname: "node-a", Zone: "zone-a"}, {Hostname: "node-b", Zone: "zone-b"}},
+			PodEndpoints: []config.PodEndpoint{{Nodename: "node-b", Podname: "pod-b"}},
+		})
+		Expect(lookupFault("tcp-n2n", "node-b", "node-a")).To(BeNil())
+		fault := lookupFault("tcp-p2p", "node-a", "pod-b")
+		Expect(fault).NotTo(BeNil())
+		Expect(fault.fail).To(BeFalse())
+		Expect(fault.delay).To(Equal(30 * time.Millisecond))
+	})
+
+	It("applies the faults to the checks of the runners", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		defer listener.Close()
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+			}
+		}()
+		endpoint := config.Endpoint{Hostname: "local", IP: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port}
+		job := NewCheckTCPPort([]config.Endpoint{endpoint}, RunnerConfig{Job: config.Job{JobID: "tcp-local"}, Period: time.Minute})
+
+		setPolicy("faults:\n- destHost: local\n  delay: 50ms")
+		obs := run(job)
+		Expect(obs.Ok).To(BeTrue())
+		Expect(obs.Duration.AsDuration()).To(BeNumerically(">=", 50*time.Millisecond))
+
+		setPolicy("faults:\n- jobID: tcp-local\n  fail: true")
+		obs = run(job)
+		Expect(obs.Ok).To(BeFalse())
+		Expect(obs.FailureClass).To(Equal(FailureClassInjected))
+		Expect(obs.Result).To(ContainSubstring("injected fault"))
+
+		Expect(SetFaultPolicy(nil)).To(Succeed())
+		Expect(run(job).Ok).To(BeTrue())
+		Expect(injected.Load()).To(Equal(int32(2)))
+	})
+})
@@ -0,0 +1,32 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	interfaceFlag = "interface"
+	// maxInterfaceNameLength is the maximum length of a Linux network interface name (IFNAMSIZ - 1)
+	maxInterfaceNameLength = 15
+)
+
+func addInterfaceFlag(cmd *cobra.Command, iface *string) {
+	cmd.Flags().StringVar(iface, interfaceFlag, "", "optional name of the network interface to bind the checks to, e.g. eth1 (only supported on the host network, needs capability NET_RAW)")
+}
+
+func validateInterfaceName(iface string) error {
+	if len(iface) > maxInterfaceNameLength {
+		return fmt.Errorf("invalid interface %q: name exceeds %d characters", iface, maxInterfaceNameLength)
+	}
+	if strings.ContainsAny(iface, "/ \t\n") {
+		return fmt.Errorf("invalid interface %q: name must not contain '/' or whitespace", iface)
+	}
+	return nil
+}
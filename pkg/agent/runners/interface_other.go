@@ -0,0 +1,20 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux
+
+package runners
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// bindToDeviceControl is not supported, as SO_BINDTODEVICE is only available on Linux.
+func bindToDeviceControl(iface string) func(network, address string, c syscall.RawConn) error {
+	return func(_, _ string, _ syscall.RawConn) error {
+		return fmt.Errorf("cannot bind to interface %s: not supported on %s", iface, runtime.GOOS)
+	}
+}
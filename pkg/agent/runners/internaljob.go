@@ -7,6 +7,8 @@
 package runners
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"go.uber.org/atomic"
@@ -18,25 +20,103 @@ import (
 type RunnerConfig struct {
 	config.Job
 	Period time.Duration
+	// SampleStrategy and SampleSize select the destinations checked per round (see config.NetworkConfig)
+	SampleStrategy config.SampleStrategy
+	SampleSize     int
 }
 
+// Runner runs the checks of a job. The context of a run is cancelled if the job is stopped or the run exceeds its deadline.
+// Runners must not start new checks nor block on sending observations once the context is done.
 type Runner interface {
-	Run(ch chan<- *nwpd.Observation)
+	Run(ctx context.Context, ch chan<- *nwpd.Observation)
 	Config() RunnerConfig
 	Description() string
 	TestData() any
 	DestHosts() []string
 }
 
+// TriggerableRunner is a runner which can run the check for a given destination host.
+type TriggerableRunner interface {
+	Runner
+	// RunFor runs the check for the destination host and references the triggering observation.
+	// It returns false if the destination host is unknown.
+	RunFor(ctx context.Context, ch chan<- *nwpd.Observation, destHost string, triggeredBy string) bool
+}
+
+// CostWeighted is implemented by runners whose single check is more expensive than a single connection,
+// e.g. a burst of simultaneous connections. The weight scales the cost estimate of the job for the safety valve.
+type CostWeighted interface {
+	CostWeight() float64
+}
+
+// minRunTimeout is the minimum deadline of a single run of a job. The deadline is the period of the job if it is longer.
+const minRunTimeout = 30 * time.Second
+
+// sendObservation sends the observation unless the context is done before the channel accepts it.
+func sendObservation(ctx context.Context, ch chan<- *nwpd.Observation, obs *nwpd.Observation) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case ch <- obs:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// TriggerFunc returns the ID of the triggering observation if the triggered job should run for the destination host.
+type TriggerFunc func(destHost string) (triggeredBy string, ok bool)
+
+// TickStatus is the result of a scheduler tick of a job.
+type TickStatus int
+
+const (
+	// TickIdle means the job is not due.
+	TickIdle TickStatus = iota
+	// TickStarted means the runner has been started.
+	TickStarted
+	// TickSkippedOverlap means the job is due, but the previous run has not finished yet.
+	// The run is skipped and the job is scheduled again after its period.
+	TickSkippedOverlap
+	// TickOutsideSchedule means the job is outside the time window of its schedule.
+	TickOutsideSchedule
+	// TickSuspended means the job has been suspended by the safety valve.
+	TickSuspended
+)
+
 type InternalJob struct {
-	runner  Runner
-	active  atomic.Bool
-	lastRun atomic.Value
+	runner        Runner
+	active        atomic.Bool
+	lastRun       atomic.Value
+	runStarted    atomic.Value
+	lastCompleted atomic.Value
+	triggerOffset int
+	schedule      *config.CompiledSchedule
+	// scheduleOpened is the time the current window of the schedule was entered
+	scheduleOpened atomic.Value
+	inSchedule     atomic.Bool
+	// busyTime is the total duration of the completed runs
+	busyTime atomic.Int64
+	// suspendedUntil is the end of the suspension of the job by the safety valve
+	suspendedUntil atomic.Value
+	// predecessor is the replaced job with the same job ID as long as its last run has not finished
+	predecessor *InternalJob
+	// ctx is cancelled when the job is stopped
+	ctx    context.Context
+	cancel context.CancelFunc
+	// workersLock guards starting workers against cancelling the job, so that no worker is added while waiting for them
+	workersLock sync.Mutex
+	workers     sync.WaitGroup
 }
 
-func NewInternalJob(runner Runner) *InternalJob {
+// NewInternalJob creates a job for the runner. The context of the job is derived from the given context.
+func NewInternalJob(ctx context.Context, runner Runner) *InternalJob {
+	ctx, cancel := context.WithCancel(ctx)
 	return &InternalJob{
 		runner: runner,
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
 
@@ -60,24 +140,304 @@ func (j *InternalJob) DestHosts() []string {
 	return j.runner.DestHosts()
 }
 
+// IsActive returns true if the runner of the job is currently running.
+func (j *InternalJob) IsActive() bool {
+	return j.active.Load()
+}
+
+// RunningChecks returns the number of currently running checks of the job including the replaced jobs.
+func (j *InternalJob) RunningChecks() int {
+	count := 0
+	for job := j; job != nil; job = job.predecessor {
+		if job.active.Load() {
+			count++
+		}
+	}
+	return count
+}
+
+// SetPredecessor sets the replaced job with the same job ID.
+// The job is not started before the last run of the predecessor has finished.
+func (j *InternalJob) SetPredecessor(predecessor *InternalJob) {
+	j.predecessor = predecessor
+}
+
+// busy returns true if the job or one of its predecessors is running.
+// Finished predecessors are dropped.
+func (j *InternalJob) busy() bool {
+	if j.predecessor != nil && !j.predecessor.busy() {
+		j.predecessor = nil
+	}
+	return j.predecessor != nil || j.active.Load()
+}
+
+// SetSchedule restricts the job to the time window of the schedule. A nil schedule is always active.
+func (j *InternalJob) SetSchedule(schedule *config.CompiledSchedule) {
+	j.schedule = schedule
+}
+
+// Schedule returns the schedule of the job or nil if the job is always active.
+func (j *InternalJob) Schedule() *config.CompiledSchedule {
+	return j.schedule
+}
+
+// InSchedule returns true if the job is inside the time window of its schedule. On entering the window,
+// the time is stored as start of the window.
+func (j *InternalJob) InSchedule(now time.Time) bool {
+	if j.schedule == nil {
+		return true
+	}
+	active := j.schedule.Active(now)
+	if j.inSchedule.Swap(active) != active && active {
+		j.scheduleOpened.Store(&now)
+	}
+	return active
+}
+
+// ScheduleOpened returns the time the current window of the schedule was entered or nil if the job has no schedule
+// or the window has not been entered since the start of the job.
+func (j *InternalJob) ScheduleOpened() *time.Time {
+	v := j.scheduleOpened.Load()
+	if v == nil {
+		return nil
+	}
+	return v.(*time.Time)
+}
+
 func (j *InternalJob) SetLastRun(lastRun *time.Time) {
 	j.lastRun.Store(lastRun)
 }
 
-func (j *InternalJob) Tick(ch chan<- *nwpd.Observation) error {
-	if j.runner == nil || j.active.Load() {
-		return nil
+// Tick starts the runner if the job is due and not already active.
+// It returns TickStarted and the scheduling lag (delay between scheduled and actual start) if the runner was started.
+// If the job is due while the previous run is still active, the run is skipped and TickSkippedOverlap is returned.
+func (j *InternalJob) Tick(ch chan<- *nwpd.Observation) (time.Duration, TickStatus) {
+	if j.runner == nil || j.ctx.Err() != nil {
+		return 0, TickIdle
 	}
 
 	now := time.Now()
-	if now.After(j.getNextRun()) && j.active.CAS(false, true) {
+	if j.suspended(now) {
+		return 0, TickSuspended
+	}
+	if !j.InSchedule(now) {
+		return 0, TickOutsideSchedule
+	}
+	nextRun := j.getNextRun()
+	if !now.After(nextRun) {
+		return 0, TickIdle
+	}
+	if j.busy() || !j.active.CAS(false, true) {
+		// skip the run instead of delaying it to keep the period for the following runs
 		j.lastRun.Store(&now)
+		return 0, TickSkippedOverlap
+	}
+	var lag time.Duration
+	if opened := j.ScheduleOpened(); opened != nil && opened.After(nextRun) {
+		// the job was due outside the schedule
+		lag = now.Sub(*opened)
+	} else if !nextRun.IsZero() {
+		lag = now.Sub(nextRun)
+	}
+	if !j.startWorker() {
+		j.active.Store(false)
+		return 0, TickIdle
+	}
+	j.lastRun.Store(&now)
+	j.runStarted.Store(now)
+	go func() {
+		defer j.workers.Done()
+		defer j.completed()
+		ctx, cancel := j.runContext()
+		defer cancel()
+		j.runner.Run(ctx, ch)
+	}()
+	return lag, TickStarted
+}
+
+// TickTriggered starts the runner for the next destination host accepted by the trigger function
+// if the job is due and not already active. Destination hosts are checked in robin round order.
+// It returns true if the runner was started.
+func (j *InternalJob) TickTriggered(ch chan<- *nwpd.Observation, trigger TriggerFunc) bool {
+	runner, ok := j.runner.(TriggerableRunner)
+	if !ok || j.busy() {
+		return false
+	}
+
+	now := time.Now()
+	if j.suspended(now) || !j.InSchedule(now) || !now.After(j.getNextRun()) {
+		return false
+	}
+	hosts := runner.DestHosts()
+	for i := range hosts {
+		idx := (j.triggerOffset + i) % len(hosts)
+		triggeredBy, ok := trigger(normalise(hosts[idx]))
+		if !ok {
+			continue
+		}
+		if !j.active.CAS(false, true) {
+			return false
+		}
+		if !j.startWorker() {
+			j.active.Store(false)
+			return false
+		}
+		j.triggerOffset = idx + 1
+		j.lastRun.Store(&now)
+		j.runStarted.Store(now)
+		destHost := hosts[idx]
 		go func() {
-			defer j.active.Store(false)
-			j.runner.Run(ch)
+			defer j.workers.Done()
+			defer j.completed()
+			ctx, cancel := j.runContext()
+			defer cancel()
+			runner.RunFor(ctx, ch, destHost, triggeredBy)
 		}()
+		return true
+	}
+	return false
+}
+
+// RunFor runs the check of the job once for the destination host independent of the schedule, e.g. for a relayed check.
+// The destination host is compared normalised. It returns false if the runner cannot run single destinations,
+// the destination host is unknown, the job is suspended or has been stopped.
+func (j *InternalJob) RunFor(ch chan<- *nwpd.Observation, destHost string, triggeredBy string) bool {
+	runner, ok := j.runner.(TriggerableRunner)
+	if !ok || j.suspended(time.Now()) {
+		return false
+	}
+	for _, host := range runner.DestHosts() {
+		if normalise(host) == destHost {
+			if !j.startWorker() {
+				return false
+			}
+			defer j.workers.Done()
+			ctx, cancel := j.runContext()
+			defer cancel()
+			return runner.RunFor(ctx, ch, host, triggeredBy)
+		}
+	}
+	return false
+}
+
+// runContext returns the context of a single run. Its deadline is the period of the job, but at least minRunTimeout.
+func (j *InternalJob) runContext() (context.Context, context.CancelFunc) {
+	timeout := j.Period()
+	if timeout < minRunTimeout {
+		timeout = minRunTimeout
+	}
+	return context.WithTimeout(j.ctx, timeout)
+}
+
+// startWorker registers a goroutine running a check. It returns false if the job has been stopped.
+func (j *InternalJob) startWorker() bool {
+	j.workersLock.Lock()
+	defer j.workersLock.Unlock()
+	if j.ctx.Err() != nil {
+		return false
+	}
+	j.workers.Add(1)
+	return true
+}
+
+// Cancel cancels the context of the job. Running checks are asked to stop and no new checks are started.
+func (j *InternalJob) Cancel() {
+	j.workersLock.Lock()
+	defer j.workersLock.Unlock()
+	j.cancel()
+}
+
+// Wait waits until all running checks of a cancelled job have finished, at most for the timeout.
+// It returns false if checks are still running after the timeout.
+func (j *InternalJob) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		j.workers.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Stop cancels the job and waits until its running checks have finished, at most for the timeout.
+// It returns false if checks are still running after the timeout.
+func (j *InternalJob) Stop(timeout time.Duration) bool {
+	j.Cancel()
+	return j.Wait(timeout)
+}
+
+func (j *InternalJob) completed() {
+	now := time.Now()
+	if started, ok := j.runStarted.Load().(time.Time); ok {
+		j.busyTime.Add(int64(now.Sub(started)))
+	}
+	j.lastCompleted.Store(&now)
+	j.active.Store(false)
+}
+
+// BusyTime returns the total time the job has been running checks including the current run.
+func (j *InternalJob) BusyTime(now time.Time) time.Duration {
+	busy := time.Duration(j.busyTime.Load())
+	if since, active := j.ActiveSince(); active && now.After(since) {
+		busy += now.Sub(since)
+	}
+	return busy
+}
+
+// CostWeight returns the cost weight of a single check of the job (1 if the runner is not CostWeighted).
+func (j *InternalJob) CostWeight() float64 {
+	if r, ok := j.runner.(CostWeighted); ok {
+		return r.CostWeight()
+	}
+	return 1
+}
+
+// SetSuspendedUntil suspends the job until the given time. Nil resumes the job.
+func (j *InternalJob) SetSuspendedUntil(until *time.Time) {
+	j.suspendedUntil.Store(until)
+}
+
+// SuspendedUntil returns the end of the suspension or nil if the job is not suspended.
+func (j *InternalJob) SuspendedUntil() *time.Time {
+	v, _ := j.suspendedUntil.Load().(*time.Time)
+	return v
+}
+
+func (j *InternalJob) suspended(now time.Time) bool {
+	until := j.SuspendedUntil()
+	return until != nil && now.Before(*until)
+}
+
+// GetLastCompleted returns the end time of the last completed run.
+func (j *InternalJob) GetLastCompleted() *time.Time {
+	v := j.lastCompleted.Load()
+	if v == nil {
+		return nil
+	}
+	return v.(*time.Time)
+}
+
+// SetLastCompleted sets the end time of the last completed run, e.g. from the replaced job.
+func (j *InternalJob) SetLastCompleted(lastCompleted *time.Time) {
+	j.lastCompleted.Store(lastCompleted)
+}
+
+// ActiveSince returns the start time of the current run if the runner is running.
+func (j *InternalJob) ActiveSince() (time.Time, bool) {
+	if !j.active.Load() {
+		return time.Time{}, false
+	}
+	v := j.runStarted.Load()
+	if v == nil {
+		return time.Time{}, false
 	}
-	return nil
+	return v.(time.Time), true
 }
 
 func (j *InternalJob) GetLastRun() *time.Time {
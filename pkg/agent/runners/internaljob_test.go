@@ -0,0 +1,211 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("internal job", func() {
+	It("runs triggered job only for triggered destinations", func() {
+		endpoints := []config.Endpoint{
+			{Hostname: "node1", IP: "10.0.0.11", Port: 1011},
+			{Hostname: "node2", IP: "10.0.0.12", Port: 1011},
+			{Hostname: "node3", IP: "10.0.0.13", Port: 1011},
+		}
+		rconfig := RunnerConfig{Job: config.Job{JobID: "triggered"}, Period: 1 * time.Millisecond}
+		job := NewInternalJob(context.Background(), NewSimulatedRunner(NewCheckTCPPort(endpoints, rconfig), &SimulationConfig{}))
+
+		ch := make(chan *nwpd.Observation, 10)
+		never := func(destHost string) (string, bool) { return "", false }
+		Expect(job.TickTriggered(ch, never)).To(BeFalse())
+
+		onlyNode2 := func(destHost string) (string, bool) {
+			return "trigger/src/" + destHost + "/1", destHost == "node2"
+		}
+		for i := 0; i < 3; i++ {
+			time.Sleep(2 * time.Millisecond)
+			Expect(job.TickTriggered(ch, onlyNode2)).To(BeTrue())
+			obs := <-ch
+			Expect(obs.DestHost).To(Equal("node2"))
+			Expect(obs.TriggeredBy).To(Equal("trigger/src/node2/1"))
+			Eventually(job.active.Load).Should(BeFalse())
+		}
+	})
+	It("runs a single check for a destination on demand", func() {
+		endpoints := []config.Endpoint{
+			{Hostname: "node1.", IP: "10.0.0.11", Port: 1011},
+			{Hostname: "node2.", IP: "10.0.0.12", Port: 1011},
+		}
+		rconfig := RunnerConfig{Job: config.Job{JobID: "relayed"}, Period: 1 * time.Hour}
+		job := NewInternalJob(context.Background(), NewSimulatedRunner(NewCheckTCPPort(endpoints, rconfig), &SimulationConfig{}))
+
+		ch := make(chan *nwpd.Observation, 10)
+		Expect(job.RunFor(ch, "node2", "relay/controller")).To(BeTrue())
+		obs := <-ch
+		Expect(obs.DestHost).To(Equal("node2"))
+		Expect(obs.TriggeredBy).To(Equal("relay/controller"))
+		Expect(job.RunFor(ch, "node3", "relay/controller")).To(BeFalse())
+		Expect(job.GetLastCompleted()).To(BeNil())
+	})
+	It("records start and end of the runs", func() {
+		endpoints := []config.Endpoint{{Hostname: "node1", IP: "10.0.0.11", Port: 1011}}
+		rconfig := RunnerConfig{Job: config.Job{JobID: "slow"}, Period: 1 * time.Millisecond}
+		sim := &SimulationConfig{Latency: 20 * time.Millisecond}
+		job := NewInternalJob(context.Background(), NewSimulatedRunner(NewCheckTCPPort(endpoints, rconfig), sim))
+
+		ch := make(chan *nwpd.Observation, 10)
+		start := time.Now()
+		_, status := job.Tick(ch)
+		Expect(status).To(Equal(TickStarted))
+		since, active := job.ActiveSince()
+		Expect(active).To(BeTrue())
+		Expect(since).NotTo(BeTemporally("<", start))
+		Expect(job.GetLastCompleted()).To(BeNil())
+
+		Eventually(job.IsActive).Should(BeFalse())
+		_, active = job.ActiveSince()
+		Expect(active).To(BeFalse())
+		Expect(*job.GetLastCompleted()).To(BeTemporally(">=", since.Add(20*time.Millisecond)))
+	})
+	It("skips runs outside the schedule", func() {
+		endpoints := []config.Endpoint{{Hostname: "node1", IP: "10.0.0.11", Port: 1011}}
+		rconfig := RunnerConfig{Job: config.Job{JobID: "burst"}, Period: 1 * time.Millisecond}
+		job := NewInternalJob(context.Background(), NewSimulatedRunner(NewCheckTCPPort(endpoints, rconfig), &SimulationConfig{}))
+		now := time.Now().UTC()
+		outside, err := (&config.JobSchedule{Start: now.Add(2 * time.Hour).Format("15:04"), End: now.Add(3 * time.Hour).Format("15:04")}).Compile()
+		Expect(err).To(BeNil())
+		job.SetSchedule(outside)
+
+		ch := make(chan *nwpd.Observation, 10)
+		_, status := job.Tick(ch)
+		Expect(status).To(Equal(TickOutsideSchedule))
+		Expect(job.ScheduleOpened()).To(BeNil())
+
+		inside, err := (&config.JobSchedule{Start: now.Add(-1 * time.Hour).Format("15:04"), End: now.Add(1 * time.Hour).Format("15:04")}).Compile()
+		Expect(err).To(BeNil())
+		job.SetSchedule(inside)
+		_, status = job.Tick(ch)
+		Expect(status).To(Equal(TickStarted))
+		Expect(job.ScheduleOpened()).NotTo(BeNil())
+		Eventually(job.IsActive).Should(BeFalse())
+	})
+	It("skips runs while the previous run is active", func() {
+		endpoints := []config.Endpoint{{Hostname: "node1", IP: "10.0.0.11", Port: 1011}}
+		rconfig := RunnerConfig{Job: config.Job{JobID: "slow"}, Period: 1 * time.Millisecond}
+		sim := &SimulationConfig{Latency: 20 * time.Millisecond}
+		job := NewInternalJob(context.Background(), NewSimulatedRunner(NewCheckTCPPort(endpoints, rconfig), sim))
+
+		ch := make(chan *nwpd.Observation, 1000)
+		started, skipped := 0, 0
+		tick := func() {
+			switch _, status := job.Tick(ch); status {
+			case TickStarted:
+				started++
+			case TickSkippedOverlap:
+				skipped++
+			}
+			Expect(job.RunningChecks()).To(BeNumerically("<=", 1))
+		}
+		tick()
+		goroutines := runtime.NumGoroutine()
+		for i := 0; i < 200; i++ {
+			time.Sleep(2 * time.Millisecond)
+			tick()
+			Expect(runtime.NumGoroutine()).To(BeNumerically("<=", goroutines))
+		}
+		Expect(started).To(BeNumerically(">", 1))
+		Expect(skipped).To(BeNumerically(">", started))
+		Eventually(job.IsActive).Should(BeFalse())
+	})
+
+	It("waits for the last run of a replaced job", func() {
+		endpoints := []config.Endpoint{{Hostname: "node1", IP: "10.0.0.11", Port: 1011}}
+		rconfig := RunnerConfig{Job: config.Job{JobID: "slow"}, Period: 1 * time.Millisecond}
+		sim := &SimulationConfig{Latency: 50 * time.Millisecond}
+		oldJob := NewInternalJob(context.Background(), NewSimulatedRunner(NewCheckTCPPort(endpoints, rconfig), sim))
+
+		ch := make(chan *nwpd.Observation, 10)
+		_, status := oldJob.Tick(ch)
+		Expect(status).To(Equal(TickStarted))
+
+		job := NewInternalJob(context.Background(), NewSimulatedRunner(NewCheckTCPPort(endpoints, rconfig), sim))
+		job.SetPredecessor(oldJob)
+		_, status = job.Tick(ch)
+		Expect(status).To(Equal(TickSkippedOverlap))
+		Expect(job.RunningChecks()).To(Equal(1))
+
+		Eventually(oldJob.IsActive).Should(BeFalse())
+		time.Sleep(2 * time.Millisecond)
+		_, status = job.Tick(ch)
+		Expect(status).To(Equal(TickStarted))
+		Expect(job.RunningChecks()).To(Equal(1))
+	})
+
+	It("stops the running checks of a cancelled job", func() {
+		endpoints := []config.Endpoint{{Hostname: "node1", IP: "10.0.0.11", Port: 1011}}
+		rconfig := RunnerConfig{Job: config.Job{JobID: "slow"}, Period: 1 * time.Millisecond}
+		sim := &SimulationConfig{Latency: 50 * time.Millisecond}
+		job := NewInternalJob(context.Background(), NewSimulatedRunner(NewCheckTCPPort(endpoints, rconfig), sim))
+
+		// nobody receives the observations
+		ch := make(chan *nwpd.Observation)
+		_, status := job.Tick(ch)
+		Expect(status).To(Equal(TickStarted))
+		Expect(job.Wait(10 * time.Millisecond)).To(BeFalse())
+
+		Expect(job.Stop(time.Second)).To(BeTrue())
+		Expect(job.IsActive()).To(BeFalse())
+		time.Sleep(2 * time.Millisecond)
+		_, status = job.Tick(ch)
+		Expect(status).To(Equal(TickIdle))
+		Expect(job.RunFor(ch, "node1", "relay/controller")).To(BeFalse())
+	})
+
+	It("skips runs of a suspended job", func() {
+		endpoints := []config.Endpoint{{Hostname: "node1", IP: "10.0.0.11", Port: 1011}}
+		rconfig := RunnerConfig{Job: config.Job{JobID: "suspended"}, Period: 1 * time.Millisecond}
+		sim := &SimulationConfig{Latency: 10 * time.Millisecond}
+		job := NewInternalJob(context.Background(), NewSimulatedRunner(NewCheckTCPPort(endpoints, rconfig), sim))
+		Expect(job.CostWeight()).To(Equal(1.0))
+
+		ch := make(chan *nwpd.Observation, 10)
+		until := time.Now().Add(1 * time.Hour)
+		job.SetSuspendedUntil(&until)
+		_, status := job.Tick(ch)
+		Expect(status).To(Equal(TickSuspended))
+		Expect(job.RunFor(ch, "node1", "relay/controller")).To(BeFalse())
+		Expect(job.BusyTime(time.Now())).To(BeZero())
+
+		job.SetSuspendedUntil(nil)
+		_, status = job.Tick(ch)
+		Expect(status).To(Equal(TickStarted))
+		Eventually(job.IsActive).Should(BeFalse())
+		Expect(job.BusyTime(time.Now())).To(BeNumerically(">=", 10*time.Millisecond))
+	})
+
+	It("stops the jobs with the parent context", func() {
+		endpoints := []config.Endpoint{{Hostname: "node1", IP: "10.0.0.11", Port: 1011}}
+		rconfig := RunnerConfig{Job: config.Job{JobID: "blocked"}, Period: 1 * time.Millisecond}
+		ctx, cancel := context.WithCancel(context.Background())
+		job := NewInternalJob(ctx, NewSimulatedRunner(NewCheckTCPPort(endpoints, rconfig), &SimulationConfig{}))
+
+		ch := make(chan *nwpd.Observation)
+		_, status := job.Tick(ch)
+		Expect(status).To(Equal(TickStarted))
+		Consistently(job.IsActive, 20*time.Millisecond).Should(BeTrue())
+
+		cancel()
+		Expect(job.Wait(time.Second)).To(BeTrue())
+		Expect(job.IsActive()).To(BeFalse())
+	})
+})
@@ -0,0 +1,23 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+func addNetNSFlag(cmd *cobra.Command, netns *string) {
+	cmd.Flags().StringVar(netns, "netns", "", "optional path of a network namespace to run the checks in, e.g. /host/proc/<pid>/ns/net (needs host /proc and capabilities SYS_ADMIN and SYS_PTRACE)")
+}
+
+func validateNetNS(netns string) error {
+	if netns != "" && !filepath.IsAbs(netns) {
+		return fmt.Errorf("invalid netns %q: must be an absolute path", netns)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build linux
+
+package runners
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// runInNetNS runs the function on a locked OS thread switched to the network namespace given by path,
+// e.g. /host/proc/<pid>/ns/net for the namespace of a pod. Sockets stay in the namespace they are created in,
+// so connections must be opened within the function. Needs the capability SYS_ADMIN (and SYS_PTRACE for
+// namespaces of other processes).
+func runInNetNS(path string, f func() error) error {
+	target, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening netns %s failed: %w", path, err)
+	}
+	defer target.Close()
+
+	runtime.LockOSThread()
+	origin, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+	if err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("opening netns of agent failed: %w", err)
+	}
+	defer origin.Close()
+	if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("entering netns %s failed: %w", path, err)
+	}
+	defer func() {
+		if err := unix.Setns(int(origin.Fd()), unix.CLONE_NEWNET); err != nil {
+			// the thread stays locked and is terminated when the goroutine exits
+			return
+		}
+		runtime.UnlockOSThread()
+	}()
+	return f()
+}
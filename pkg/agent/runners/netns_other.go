@@ -0,0 +1,17 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux
+
+package runners
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// runInNetNS is not supported, as network namespaces are only available on Linux.
+func runInNetNS(path string, _ func() error) error {
+	return fmt.Errorf("cannot enter netns %s: not supported on %s", path, runtime.GOOS)
+}
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+const (
+	// FamilyIPv4 is the address family of the IPv4 network info.
+	FamilyIPv4 = "ipv4"
+	// FamilyIPv6 is the address family of the IPv6 network info.
+	FamilyIPv6 = "ipv6"
+)
+
+// ReadNetworkInfo returns the interface and gateway of the default route and the primary IP address of the interface
+// per address family as seen by the agent. Families without default route are omitted.
+func ReadNetworkInfo() ([]*nwpd.NetworkInfo, error) {
+	return readNetworkInfo("/", interfaceAddrs)
+}
+
+func interfaceAddrs(name string) ([]net.Addr, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return iface.Addrs()
+}
+
+func readNetworkInfo(root string, addrs func(iface string) ([]net.Addr, error)) ([]*nwpd.NetworkInfo, error) {
+	var result []*nwpd.NetworkInfo
+	for _, family := range []string{FamilyIPv4, FamilyIPv6} {
+		var (
+			route *defaultRoute
+			err   error
+		)
+		if family == FamilyIPv6 {
+			route, err = readIPv6DefaultRoute(filepath.Join(root, "proc/net/ipv6_route"))
+		} else {
+			route, err = readIPv4DefaultRoute(filepath.Join(root, "proc/net/route"))
+		}
+		if err != nil {
+			// /proc/net/ipv6_route does not exist if IPv6 is disabled
+			if family == FamilyIPv6 && os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if route == nil {
+			continue
+		}
+		info := &nwpd.NetworkInfo{Family: family, Interface: route.iface}
+		if route.gateway != nil {
+			info.Gateway = route.gateway.String()
+		}
+		if list, err := addrs(route.iface); err == nil {
+			info.PrimaryIP = primaryIP(list, family == FamilyIPv6)
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// primaryIP returns the first global unicast address of the family, empty if there is none.
+func primaryIP(addrs []net.Addr, ipv6 bool) string {
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || !ipnet.IP.IsGlobalUnicast() || (ipnet.IP.To4() == nil) != ipv6 {
+			continue
+		}
+		return ipnet.IP.String()
+	}
+	return ""
+}
@@ -5,12 +5,45 @@
 package runners
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"math/rand"
 	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
 	"github.com/spf13/cobra"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// dnsServerKubeDNS selects the discovered kube-dns service (CoreDNS) as DNS server
+	dnsServerKubeDNS = "kube-dns"
+	// dnsServerNodeLocalDNS selects node-local-dns as DNS server
+	dnsServerNodeLocalDNS = "node-local-dns"
+
+	// FailureClassTCP53Blocked is the failure class of DNS lookups with option --compare-tcp if the query succeeded over UDP,
+	// but failed over TCP
+	FailureClassTCP53Blocked = "tcp53-blocked"
+	// FailureClassTruncatedNoTCP is the failure class of DNS lookups with option --compare-tcp if the UDP response was
+	// truncated and could not be retried over TCP
+	FailureClassTruncatedNoTCP = "truncated-no-tcp"
+
+	// dnsQueryTimeout is the timeout of a single DNS query with option --compare-tcp
+	dnsQueryTimeout = 5 * time.Second
+	// maxUDPResponseSize is the size of the buffer for DNS responses over UDP
+	maxUDPResponseSize = 4096
+	// resolvConfPath is the path of the resolver configuration providing the nameserver if no DNS server is specified
+	resolvConfPath = "/etc/resolv.conf"
 )
 
 type nslookupArgs struct {
@@ -18,6 +51,8 @@ type nslookupArgs struct {
 	internalKAPI bool
 	externalKAPI bool
 	names        []string
+	dnsServer    string
+	compareTCP   bool
 }
 
 func (a *nslookupArgs) createRunner(cmd *cobra.Command, args []string) error {
@@ -42,10 +77,34 @@ func (a *nslookupArgs) createRunner(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no DNS names")
 	}
 
+	var server *config.Endpoint
+	switch a.dnsServer {
+	case "":
+	case dnsServerKubeDNS:
+		server = a.runnerArgs.clusterCfg.KubeDNS
+	case dnsServerNodeLocalDNS:
+		server = a.runnerArgs.clusterCfg.NodeLocalDNS
+	default:
+		return fmt.Errorf("invalid DNS server %q (supported: %s, %s)", a.dnsServer, dnsServerKubeDNS, dnsServerNodeLocalDNS)
+	}
+	if a.dnsServer != "" && server == nil {
+		// DNS server has not been discovered
+		return nil
+	}
+
 	config := a.runnerArgs.prepareConfig()
-	if r := NewNSLookup(names, config); r != nil {
-		a.runnerArgs.runner = r
+	r := NewNSLookup(names, server, config)
+	if r == nil {
+		return nil
+	}
+	if a.compareTCP {
+		address, err := nameserverAddress(server, resolvConfPath)
+		if err != nil {
+			return err
+		}
+		r = r.withCompareTCP(address)
 	}
+	a.runnerArgs.runner = r
 	return nil
 }
 
@@ -59,10 +118,14 @@ func createNSLookupCmd(ra *runnerArgs) *cobra.Command {
 	cmd.Flags().StringSliceVar(&a.names, "names", nil, "DNS names")
 	cmd.Flags().BoolVar(&a.internalKAPI, "name-internal-kube-apiserver", false, "uses DNS name 'kubernetes.default.svc.cluster.local.'")
 	cmd.Flags().BoolVar(&a.externalKAPI, "name-external-kube-apiserver", false, "uses known external DNS name of kube-apiserver.")
+	cmd.Flags().StringVar(&a.dnsServer, "dns-server", "", fmt.Sprintf("queries the discovered DNS server '%s' or '%s' instead of the system resolver. The job is skipped if the server has not been discovered.", dnsServerKubeDNS, dnsServerNodeLocalDNS))
+	cmd.Flags().BoolVar(&a.compareTCP, "compare-tcp", false, "queries the DNS server (or the first nameserver of "+resolvConfPath+") over UDP and over TCP and compares the responses. "+
+		"Fails with failure class '"+FailureClassTCP53Blocked+"' if only the query over TCP fails, and '"+FailureClassTruncatedNoTCP+"' if a truncated UDP response cannot be retried over TCP.")
 	return cmd
 }
 
-func NewNSLookup(names []string, rconfig RunnerConfig) *nslookup {
+// NewNSLookup creates a runner looking up the given names. If server is nil, the system resolver is used.
+func NewNSLookup(names []string, server *config.Endpoint, rconfig RunnerConfig) *nslookup {
 	if len(names) == 0 {
 		return nil
 	}
@@ -71,10 +134,11 @@ func NewNSLookup(names []string, rconfig RunnerConfig) *nslookup {
 		dnsNames = append(dnsNames, dnsName(name))
 	}
 	return &nslookup{
-		robinRound[dnsName]{
+		robinRound: robinRound[dnsName]{
 			itemsName: "names",
+			protocol:  nwpd.ProtocolUDP,
 			items:     config.CloneAndShuffle(dnsNames),
-			runFunc:   lookupFunc,
+			runFunc:   newLookupFunc(server),
 			config:    rconfig,
 		},
 	}
@@ -88,12 +152,49 @@ func (n dnsName) DestHost() string {
 
 type nslookup struct {
 	robinRound[dnsName]
+	// compareTCPAddress is the address of the nameserver queried over UDP and TCP if set
+	compareTCPAddress string
 }
 
 var _ Runner = &nslookup{}
 
-func lookupFunc(name dnsName) (string, error) {
-	ips, err := net.LookupIP(string(name))
+// withCompareTCP queries the names over UDP and TCP from the nameserver at the given address instead of using the resolver.
+// The durations of both queries are recorded in the observations.
+func (r *nslookup) withCompareTCP(address string) *nslookup {
+	r.compareTCPAddress = address
+	r.detailedRunFunc = func(name dnsName) (string, checkDetails, error) {
+		return compareUDPAndTCP(address, name)
+	}
+	return r
+}
+
+func (r *nslookup) Description() string {
+	desc := r.robinRound.Description()
+	if r.compareTCPAddress != "" {
+		desc += ", compare UDP and TCP on " + r.compareTCPAddress
+	}
+	return desc
+}
+
+func newLookupFunc(server *config.Endpoint) func(name dnsName) (string, error) {
+	resolver := net.DefaultResolver
+	if server != nil {
+		address := net.JoinHostPort(server.IP, strconv.Itoa(server.Port))
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{}
+				return d.DialContext(ctx, network, address)
+			},
+		}
+	}
+	return func(name dnsName) (string, error) {
+		return lookup(resolver, name)
+	}
+}
+
+func lookup(resolver *net.Resolver, name dnsName) (string, error) {
+	ips, err := resolver.LookupIP(context.Background(), "ip", string(name))
 	if err != nil {
 		return "", err
 	}
@@ -106,3 +207,169 @@ func lookupFunc(name dnsName) (string, error) {
 	}
 	return sb.String(), nil
 }
+
+// nameserverAddress returns the address of the DNS server or of the first nameserver of the resolver configuration.
+func nameserverAddress(server *config.Endpoint, resolvConf string) (string, error) {
+	if server != nil {
+		return net.JoinHostPort(server.IP, strconv.Itoa(server.Port)), nil
+	}
+	f, err := os.Open(resolvConf)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53"), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no nameserver found in %s", resolvConf)
+}
+
+// dnsResponse is the parsed response of a DNS query.
+type dnsResponse struct {
+	rcode     dnsmessage.RCode
+	truncated bool
+	ips       []string
+	duration  time.Duration
+}
+
+func (r *dnsResponse) answer() string {
+	if r.rcode != dnsmessage.RCodeSuccess {
+		return r.rcode.String()
+	}
+	return strings.Join(r.ips, ",")
+}
+
+// compareUDPAndTCP queries the name over UDP and over TCP and compares the responses.
+// Different addresses are only noted in the result, as they may be rotated by the DNS server.
+func compareUDPAndTCP(address string, name dnsName) (string, checkDetails, error) {
+	details := checkDetails{}
+	udp, err := queryDNS("udp", address, name)
+	if err != nil {
+		return "", details, fmt.Errorf("query over UDP failed: %w", err)
+	}
+	details.udpDuration = udp.duration
+	tcp, err := queryDNS("tcp", address, name)
+	if err != nil {
+		if udp.truncated {
+			return "", details, &classifiedError{class: FailureClassTruncatedNoTCP, err: fmt.Errorf("UDP response truncated, retry over TCP failed: %w", err)}
+		}
+		return "", details, &classifiedError{class: FailureClassTCP53Blocked, err: fmt.Errorf("query over UDP succeeded, but over TCP failed: %w", err)}
+	}
+	details.tcpDuration = tcp.duration
+	if udp.rcode != tcp.rcode && !udp.truncated {
+		return "", details, fmt.Errorf("different response codes over UDP (%s) and TCP (%s)", udp.rcode, tcp.rcode)
+	}
+	if tcp.rcode != dnsmessage.RCodeSuccess {
+		return "", details, fmt.Errorf("lookup failed: %s", tcp.rcode)
+	}
+	result := fmt.Sprintf("udp %s, tcp %s: %s", udp.duration.Round(time.Millisecond), tcp.duration.Round(time.Millisecond), tcp.answer())
+	if udp.truncated {
+		result += " (UDP response truncated)"
+	} else if udp.answer() != tcp.answer() {
+		result += fmt.Sprintf(" (UDP answer differs: %s)", udp.answer())
+	}
+	return result, details, nil
+}
+
+// queryDNS sends a query for the IPv4 addresses of the name to the DNS server over the given network ("udp" or "tcp").
+func queryDNS(network, address string, name dnsName) (*dnsResponse, error) {
+	qname, err := dnsmessage.NewName(fullQualified(string(name)))
+	if err != nil {
+		return nil, err
+	}
+	id := uint16(rand.Intn(1 << 16))
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}
+	query, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	conn, err := net.DialTimeout(network, address, dnsQueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(start.Add(dnsQueryTimeout)); err != nil {
+		return nil, err
+	}
+	var data []byte
+	if network == "tcp" {
+		data, err = exchangeTCP(conn, query)
+	} else {
+		data, err = exchangeUDP(conn, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	response := &dnsResponse{duration: time.Since(start)}
+
+	var parser dnsmessage.Parser
+	header, err := parser.Start(data)
+	if err != nil {
+		return nil, err
+	}
+	if header.ID != id {
+		return nil, fmt.Errorf("response ID mismatch")
+	}
+	response.rcode = header.RCode
+	response.truncated = header.Truncated
+	if response.truncated {
+		// the answers of a truncated response may be incomplete
+		return response, nil
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return nil, err
+	}
+	answers, err := parser.AllAnswers()
+	if err != nil {
+		return nil, err
+	}
+	for _, answer := range answers {
+		if a, ok := answer.Body.(*dnsmessage.AResource); ok {
+			response.ips = append(response.ips, net.IP(a.A[:]).String())
+		}
+	}
+	sort.Strings(response.ips)
+	return response, nil
+}
+
+func exchangeUDP(conn net.Conn, query []byte) ([]byte, error) {
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxUDPResponseSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// exchangeTCP sends the query with a two byte length prefix and reads the response in the same format.
+func exchangeTCP(conn net.Conn, query []byte) ([]byte, error) {
+	buf := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(buf, uint16(len(query)))
+	copy(buf[2:], query)
+	if _, err := conn.Write(buf); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint16(buf[:2]))
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
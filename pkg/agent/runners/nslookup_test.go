@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeDNSServer answers A queries with a fixed address over UDP and optionally over TCP on the same port.
+type fakeDNSServer struct {
+	udp       net.PacketConn
+	tcp       net.Listener
+	truncated bool
+}
+
+func newFakeDNSServer(withTCP, truncated bool) *fakeDNSServer {
+	s := &fakeDNSServer{truncated: truncated}
+	var err error
+	s.udp, err = net.ListenPacket("udp", "127.0.0.1:0")
+	Expect(err).To(BeNil())
+	if withTCP {
+		s.tcp, err = net.Listen("tcp", s.udp.LocalAddr().String())
+		Expect(err).To(BeNil())
+		go s.serveTCP()
+	}
+	go s.serveUDP()
+	return s
+}
+
+func (s *fakeDNSServer) address() string {
+	return s.udp.LocalAddr().String()
+}
+
+func (s *fakeDNSServer) close() {
+	s.udp.Close()
+	if s.tcp != nil {
+		s.tcp.Close()
+	}
+}
+
+func (s *fakeDNSServer) serveUDP() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if response := s.respond(buf[:n], s.truncated); response != nil {
+			_, _ = s.udp.WriteTo(response, addr)
+		}
+	}
+}
+
+func (s *fakeDNSServer) serveTCP() {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			size := make([]byte, 2)
+			if _, err := io.ReadFull(conn, size); err != nil {
+				return
+			}
+			query := make([]byte, binary.BigEndian.Uint16(size))
+			if _, err := io.ReadFull(conn, query); err != nil {
+				return
+			}
+			response := s.respond(query, false)
+			binary.BigEndian.PutUint16(size, uint16(len(response)))
+			_, _ = conn.Write(append(size, response...))
+		}()
+	}
+}
+
+func (s *fakeDNSServer) respond(query []byte, truncated bool) []byte {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil {
+		return nil
+	}
+	msg.Header.Response = true
+	msg.Header.Truncated = truncated
+	if !truncated {
+		msg.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: msg.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 30},
+			Body:   &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}},
+		}}
+	}
+	response, err := msg.Pack()
+	if err != nil {
+		return nil
+	}
+	return response
+}
+
+var _ = Describe("nslookup", func() {
+	Context("compare UDP and TCP", func() {
+		It("succeeds if the query succeeds over both transports", func() {
+			server := newFakeDNSServer(true, false)
+			defer server.close()
+
+			result, details, err := compareUDPAndTCP(server.address(), "example.com.")
+			Expect(err).To(BeNil())
+			Expect(result).To(HaveSuffix(": 10.0.0.1"))
+			Expect(details.udpDuration).NotTo(BeZero())
+			Expect(details.tcpDuration).NotTo(BeZero())
+		})
+
+		It("fails with failure class tcp53-blocked if TCP is not reachable", func() {
+			server := newFakeDNSServer(false, false)
+			defer server.close()
+
+			_, details, err := compareUDPAndTCP(server.address(), "example.com.")
+			var classified *classifiedError
+			Expect(errors.As(err, &classified)).To(BeTrue())
+			Expect(classified.class).To(Equal(FailureClassTCP53Blocked))
+			Expect(details.udpDuration).NotTo(BeZero())
+			Expect(details.tcpDuration).To(BeZero())
+		})
+
+		It("fails with failure class truncated-no-tcp if a truncated response cannot be retried", func() {
+			server := newFakeDNSServer(false, true)
+			defer server.close()
+
+			_, _, err := compareUDPAndTCP(server.address(), "example.com.")
+			var classified *classifiedError
+			Expect(errors.As(err, &classified)).To(BeTrue())
+			Expect(classified.class).To(Equal(FailureClassTruncatedNoTCP))
+		})
+
+		It("retries truncated responses over TCP", func() {
+			server := newFakeDNSServer(true, true)
+			defer server.close()
+
+			result, _, err := compareUDPAndTCP(server.address(), "example.com")
+			Expect(err).To(BeNil())
+			Expect(result).To(ContainSubstring("10.0.0.1 (UDP response truncated)"))
+		})
+
+		It("records both durations in the observations", func() {
+			server := newFakeDNSServer(true, false)
+			defer server.close()
+
+			r := NewNSLookup([]string{"example.com."}, nil, RunnerConfig{}).withCompareTCP(server.address())
+			Expect(r.Description()).To(Equal("1 names, compare UDP and TCP on " + server.address()))
+			ch := make(chan *nwpd.Observation, 1)
+			r.Run(context.Background(), ch)
+			obs := <-ch
+			Expect(obs.Ok).To(BeTrue())
+			Expect(obs.UdpDuration).NotTo(BeNil())
+			Expect(obs.TcpDuration).NotTo(BeNil())
+		})
+	})
+
+	It("finds the nameserver address", func() {
+		Expect(nameserverAddress(&config.Endpoint{IP: "100.64.0.10", Port: 53}, "")).To(Equal("100.64.0.10:53"))
+
+		dir, err := os.MkdirTemp("", "nslookup")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "resolv.conf")
+		Expect(os.WriteFile(path, []byte("search svc.cluster.local\nnameserver 100.64.0.10\nnameserver 10.0.0.2\n"), 0o644)).To(Succeed())
+		Expect(nameserverAddress(nil, path)).To(Equal("100.64.0.10:53"))
+
+		Expect(os.WriteFile(path, []byte("search svc.cluster.local\n"), 0o644)).To(Succeed())
+		_, err = nameserverAddress(nil, path)
+		Expect(err).NotTo(BeNil())
+	})
+})
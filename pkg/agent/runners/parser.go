@@ -5,10 +5,13 @@
 package runners
 
 import (
+	"fmt"
 	"math"
+	"strings"
 	"time"
 
 	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
 	"github.com/spf13/cobra"
 )
 
@@ -41,11 +44,39 @@ func GetNewRoot(ra *runnerArgs) *cobra.Command {
 	root.PersistentFlags().BoolVar(&ra.scalePeriod, "scale-period", false, "scales period by number of nodes")
 	root.AddCommand(createPingHostCmd(ra))
 	root.AddCommand(createCheckTCPPortCmd(ra))
+	root.AddCommand(createCheckConnectBurstCmd(ra))
 	root.AddCommand(createCheckHTTPSGetArgs(ra))
 	root.AddCommand(createNSLookupCmd(ra))
+	root.AddCommand(createCheckKubeletCmd(ra))
+	root.AddCommand(createCollectNetStatsCmd(ra))
+	root.AddCommand(createCheckDefaultRouteCmd(ra))
+	root.AddCommand(createCheckAPIServerAuthCmd(ra))
+	root.AddCommand(createCheckProxyRulesCmd(ra))
 	return root
 }
 
+// protocols maps the runner commands to the protocol of their checks.
+var protocols = map[string]string{
+	"checkTCPPort":        nwpd.ProtocolTCP,
+	"checkConnectBurst":   nwpd.ProtocolTCP,
+	"checkHTTPSGet":       nwpd.ProtocolHTTPS,
+	"checkKubelet":        nwpd.ProtocolKubelet,
+	CollectNetStatsCmd:    nwpd.ProtocolNetStats,
+	CheckDefaultRouteCmd:  nwpd.ProtocolRoute,
+	CheckAPIServerAuthCmd: nwpd.ProtocolHTTPS,
+	CheckProxyRulesCmd:    nwpd.ProtocolProxyRules,
+	"nslookup":            nwpd.ProtocolUDP,
+	"pingHost":            nwpd.ProtocolICMP,
+}
+
+// Protocol returns the protocol of the checks of a job with the given arguments or an empty string if unknown.
+func Protocol(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return protocols[args[0]]
+}
+
 func Parse(clusterCfg config.ClusterConfig, config RunnerConfig, args []string, shuffle bool) (Runner, error) {
 	ra := &runnerArgs{}
 	root := GetNewRoot(ra)
@@ -73,3 +104,50 @@ func Parse(clusterCfg config.ClusterConfig, config RunnerConfig, args []string,
 	}
 	return ra.runner, nil
 }
+
+// ValidateAgentConfig validates the agent config including the arguments of the jobs.
+func ValidateAgentConfig(cfg *config.AgentConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	for _, networkCfg := range []*config.NetworkConfig{cfg.HostNetwork, cfg.PodNetwork} {
+		if networkCfg == nil {
+			continue
+		}
+		for _, job := range networkCfg.Jobs {
+			if err := ValidateJobArgs(job); err != nil {
+				return fmt.Errorf("job %s: %w", job.JobID, err)
+			}
+			if what := HostNetworkOnly(job.Args); networkCfg == cfg.PodNetwork && what != "" {
+				return fmt.Errorf("job %s: %s is only supported on the host network", job.JobID, what)
+			}
+		}
+	}
+	return nil
+}
+
+// HostNetworkOnly returns the runner command or option of the job arguments which is only supported on the host network,
+// or an empty string if the job can run on both networks.
+func HostNetworkOnly(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	if args[0] == CollectNetStatsCmd || args[0] == CheckProxyRulesCmd {
+		return args[0]
+	}
+	for _, arg := range args[1:] {
+		if arg == "--"+interfaceFlag || strings.HasPrefix(arg, "--"+interfaceFlag+"=") {
+			return args[0] + " --" + interfaceFlag
+		}
+	}
+	return ""
+}
+
+// ValidateJobArgs parses the arguments of the job with an empty cluster config.
+func ValidateJobArgs(job config.Job) error {
+	if len(job.Args) == 0 {
+		return fmt.Errorf("missing args")
+	}
+	_, err := Parse(config.ClusterConfig{}, RunnerConfig{Job: job}, job.Args, false)
+	return err
+}
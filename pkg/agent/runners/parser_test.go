@@ -41,6 +41,19 @@ var _ = Describe("parser", func() {
 				IP:       "1.2.3.4",
 				Port:     443,
 			},
+			KubeDNS: &config.Endpoint{
+				Hostname: common.DomainNameKubeDNSService,
+				IP:       "100.64.0.10",
+				Port:     53,
+			},
+			LoadBalancers: []config.Endpoint{
+				{Hostname: "lb-default-web", IP: "1.2.3.10", Port: 80},
+			},
+			URLEndpoints: &config.EndpointList{
+				Source:    "https://config.example.com/endpoints",
+				Version:   "1",
+				Endpoints: []config.Endpoint{{Hostname: "db", IP: "10.1.0.5", Port: 5432}},
+			},
 		}
 		config2     = RunnerConfig{Job: config.Job{JobID: "test"}, Period: 10 * time.Second}
 		clusterCfg2 = config.ClusterConfig{
@@ -102,13 +115,17 @@ var _ = Describe("parser", func() {
 		Entry("pingHost - invalid option", clusterCfg1, config1,
 			[]string{"pingHost", "--foo"}, "unknown flag: --foo"),
 		Entry("pingHost - invalid host", clusterCfg1, config1,
-			[]string{"pingHost", "--hosts", "node3"}, "invalid host node3"),
+			[]string{"pingHost", "--hosts", "node3"}, `invalid host "node3"`),
+		Entry("pingHost - relative netns path", clusterCfg1, config1,
+			[]string{"pingHost", "--netns", "ns/net"}, "invalid netns \"ns/net\""),
+		Entry("pingHost - invalid interface name", clusterCfg1, config1,
+			[]string{"pingHost", "--interface", "eth0/1"}, "invalid interface \"eth0/1\""),
 		Entry("checkTCPPort", clusterCfg1, config1,
 			[]string{"checkTCPPort", "--period", "10s", "--endpoints", "server:10.0.0.9:55555"}, NewCheckTCPPort(endpoints1, config2)),
 		Entry("checkTCPPort - missing endpoints", clusterCfg1, config1,
 			[]string{"checkTCPPort"}, "no endpoints"),
 		Entry("checkTCPPort - invalid endpoint", clusterCfg1, config1,
-			[]string{"checkTCPPort", "--endpoints", "server:10.0.0.9:x"}, "invalid endpoint port x"),
+			[]string{"checkTCPPort", "--endpoints", "server:10.0.0.9:x"}, `invalid port "x"`),
 		Entry("checkTCPPort with node port", clusterCfg1, config1,
 			[]string{"checkTCPPort", "--node-port", "55555"}, NewCheckTCPPort(endpoints2, config1)),
 		Entry("checkTCPPort with pod endpoints", clusterCfg1, config1,
@@ -117,18 +134,49 @@ var _ = Describe("parser", func() {
 			[]string{"checkTCPPort", "--endpoint-internal-kube-apiserver"}, NewCheckTCPPort(endpointsInternalKubeApiServer, config1)),
 		Entry("checkTCPPort with external kube-apiserver endpoints", clusterCfg1, config1,
 			[]string{"checkTCPPort", "--endpoint-external-kube-apiserver"}, NewCheckTCPPort(endpointsKubeApiServer, config1)),
+		Entry("checkTCPPort with load balancer endpoints", clusterCfg1, config1,
+			[]string{"checkTCPPort", "--endpoints-of-load-balancers"}, NewCheckTCPPort(clusterCfg1.LoadBalancers, config1)),
+		Entry("checkTCPPort with endpoints loaded from URL", clusterCfg1, config1,
+			[]string{"checkTCPPort", "--endpoints-of-url-list"}, NewCheckTCPPort(clusterCfg1.URLEndpoints.Endpoints, config1)),
+		Entry("checkTCPPort with multiple connections", clusterCfg1, config1,
+			[]string{"checkTCPPort", "--node-port", "55555", "--connections", "4"}, NewCheckTCPPort(endpoints2, config1)),
+		Entry("checkTCPPort - too many connections", clusterCfg1, config1,
+			[]string{"checkTCPPort", "--node-port", "55555", "--connections", "1000"}, "invalid connections 1000"),
+		Entry("checkTCPPort with netns", clusterCfg1, config1,
+			[]string{"checkTCPPort", "--node-port", "55555", "--netns", "/host/proc/1234/ns/net"}, NewCheckTCPPort(endpoints2, config1)),
+		Entry("checkTCPPort - relative netns path", clusterCfg1, config1,
+			[]string{"checkTCPPort", "--node-port", "55555", "--netns", "ns/net"}, "invalid netns \"ns/net\""),
+		Entry("checkConnectBurst", clusterCfg1, config1,
+			[]string{"checkConnectBurst", "--endpoints", "server:10.0.0.9:55555", "--parallel", "50"}, NewCheckConnectBurst(endpoints1, 50, 5*time.Second, config1)),
+		Entry("checkConnectBurst with node port", clusterCfg1, config1,
+			[]string{"checkConnectBurst", "--node-port", "55555"}, NewCheckConnectBurst(endpoints2, 20, 5*time.Second, config1)),
+		Entry("checkConnectBurst - too many connections", clusterCfg1, config1,
+			[]string{"checkConnectBurst", "--node-port", "55555", "--parallel", "1000"}, "invalid parallel 1000"),
+		Entry("checkConnectBurst - invalid deadline", clusterCfg1, config1,
+			[]string{"checkConnectBurst", "--node-port", "55555", "--deadline", "1m"}, "invalid deadline 1m0s"),
 		Entry("checkHTTPSGet", clusterCfg1, config1,
 			[]string{"checkHTTPSGet", "--period", "10s", "--endpoints", "server:55555,server2"}, NewCheckTCPPort(httpsEndpoints1, config2)),
 		Entry("checkHTTPSGet - missing endpoints", clusterCfg1, config1,
 			[]string{"checkHTTPSGet"}, "no endpoints"),
 		Entry("checkHTTPSGet - invalid endpoint", clusterCfg1, config1,
-			[]string{"checkHTTPSGet", "--endpoints", "server:x"}, "invalid endpoint port x"),
+			[]string{"checkHTTPSGet", "--endpoints", "server:x"}, `invalid port "x"`),
 		Entry("checkHTTPSGet with internal kube-apiserver endpoints", clusterCfg1, config1,
-			[]string{"checkHTTPSGet", "--endpoint-internal-kube-apiserver"}, NewCheckHTTPSGet(httpsEndpointsInternalKubeApiServer, config1)),
+			[]string{"checkHTTPSGet", "--endpoint-internal-kube-apiserver"}, NewCheckHTTPSGet(httpsEndpointsInternalKubeApiServer, false, config1)),
 		Entry("checkHTTPSGet with external kube-apiserver endpoints", clusterCfg1, config1,
-			[]string{"checkHTTPSGet", "--endpoint-external-kube-apiserver"}, NewCheckHTTPSGet(endpointsKubeApiServer, config1)),
+			[]string{"checkHTTPSGet", "--endpoint-external-kube-apiserver"}, NewCheckHTTPSGet(endpointsKubeApiServer, false, config1)),
 		Entry("nslookup with host names", clusterCfg1, config1,
 			[]string{"nslookup", "--names", "eu.gcr.io,foo.bar.", "--name-internal-kube-apiserver", "--name-external-kube-apiserver"},
-			NewNSLookup(dnsnames, config1)),
+			NewNSLookup(dnsnames, nil, config1)),
+		Entry("nslookup with kube-dns server", clusterCfg1, config1,
+			[]string{"nslookup", "--name-internal-kube-apiserver", "--dns-server", "kube-dns"},
+			NewNSLookup([]string{common.DomainNameKubernetesService}, clusterCfg1.KubeDNS, config1)),
+		Entry("nslookup - invalid DNS server", clusterCfg1, config1,
+			[]string{"nslookup", "--name-internal-kube-apiserver", "--dns-server", "foo"}, "invalid DNS server \"foo\""),
 	)
+
+	It("should skip nslookup if DNS server has not been discovered", func() {
+		actual, err := Parse(clusterCfg1, config1, []string{"nslookup", "--name-internal-kube-apiserver", "--dns-server", "node-local-dns"}, false)
+		Expect(err).To(BeNil())
+		Expect(actual).To(BeNil())
+	})
 })
@@ -5,40 +5,71 @@
 package runners
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
+	"net"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
 	"github.com/go-ping/ping"
 	"github.com/spf13/cobra"
 	"go.uber.org/atomic"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
+// pingTimeout is the time to wait for the echo reply
+const pingTimeout = 1 * time.Second
+
+// pingPayload is the payload of the echo requests sent with option --interface
+var pingPayload = []byte("nwpd-ping")
+
 type pingHostArgs struct {
-	runnerArgs *runnerArgs
-	hosts      []string
+	runnerArgs      *runnerArgs
+	hosts           []string
+	nodeAddressType string
+	netns           string
+	iface           string
 }
 
 func (a *pingHostArgs) createRunner(cmd *cobra.Command, args []string) error {
+	if err := validateNetNS(a.netns); err != nil {
+		return err
+	}
+	if err := validateInterfaceName(a.iface); err != nil {
+		return err
+	}
+	if err := config.ValidateNodeAddressType(a.nodeAddressType); err != nil {
+		return err
+	}
 	var nodes []config.Node
+	destAddressType := ""
 	if len(a.hosts) > 0 {
 		for _, host := range a.hosts {
-			parts := strings.SplitN(host, ":", 2)
-			if len(parts) != 2 {
-				return fmt.Errorf("invalid job: %s: invalid host %s", strings.Join(a.runnerArgs.args, " "), host)
+			node, err := config.ParseNode(host)
+			if err != nil {
+				return fmt.Errorf("invalid job: %s: %w", strings.Join(a.runnerArgs.args, " "), err)
 			}
-			nodes = append(nodes, config.Node{
-				Hostname:   parts[0],
-				InternalIP: parts[1],
-			})
+			nodes = append(nodes, node)
 		}
 	} else {
-		nodes = a.runnerArgs.clusterCfg.Nodes
+		nodes = config.NodesWithAddressType(a.runnerArgs.clusterCfg.Nodes, a.nodeAddressType)
+		destAddressType = a.nodeAddressType
 	}
 
 	config := a.runnerArgs.prepareConfig()
 	if r := NewPingHost(nodes, config); r != nil {
+		r.setNetNS(a.netns)
+		r.setDestAddressType(destAddressType)
+		if a.iface != "" {
+			r = r.withInterface(a.iface)
+		}
 		a.runnerArgs.runner = r
 	}
 	return nil
@@ -52,6 +83,9 @@ func createPingHostCmd(ra *runnerArgs) *cobra.Command {
 		RunE:  a.createRunner,
 	}
 	cmd.Flags().StringSliceVar(&a.hosts, "hosts", nil, "Optional hosts in format <hostname>:<ip>. If not specified, the nodelist is used.")
+	cmd.Flags().StringVar(&a.nodeAddressType, "node-address-type", config.NodeAddressTypeInternal, "address type of the nodes of the nodelist ('internal' or 'external').")
+	addNetNSFlag(cmd, &a.netns)
+	addInterfaceFlag(cmd, &a.iface)
 	return cmd
 }
 
@@ -62,9 +96,13 @@ func NewPingHost(nodes []config.Node, rconfig RunnerConfig) *pingHost {
 	return &pingHost{
 		robinRound[config.Node]{
 			itemsName: "nodes",
+			protocol:  nwpd.ProtocolICMP,
 			items:     config.CloneAndShuffle(nodes),
 			runFunc:   pingFunc,
 			config:    rconfig,
+			connectionKey: func(node config.Node) (connectionKey, bool) {
+				return connectionKey{protocol: nwpd.ProtocolICMP, ip: node.InternalIP}, node.InternalIP != ""
+			},
 		},
 	}
 }
@@ -75,33 +113,119 @@ type pingHost struct {
 
 var _ Runner = &pingHost{}
 
+// withInterface sends the echo requests from a socket bound to the network interface. The interface is recorded
+// in the observations. Such checks are not shared with other jobs.
+func (r *pingHost) withInterface(iface string) *pingHost {
+	r.setInterface(iface)
+	r.connectionKey = nil
+	r.runFunc = func(node config.Node) (string, error) {
+		return pingBoundFunc(node, iface)
+	}
+	return r
+}
+
 func pingFunc(node config.Node) (string, error) {
-	pinger, err := ping.NewPinger(node.InternalIP)
-	if err != nil {
+	pinger := ping.New(node.InternalIP)
+	pinger.SetNetwork(ipNetwork(node.InternalIP))
+	if err := pinger.Resolve(); err != nil {
 		return "", err
 	}
+	family := "ICMPv6"
+	if pinger.IPAddr().IP.To4() != nil {
+		family = "ICMPv4"
+	}
 	pinger.SetPrivileged(true)
 	pinger.Count = 1
-	pinger.Timeout = 1 * time.Second
+	pinger.Timeout = pingTimeout
 
 	result := atomic.String{}
 	pinger.OnRecv = func(pkt *ping.Packet) {
-		result.Store(fmt.Sprintf("%d bytes from %s: icmp_seq=%d time=%v\n",
-			pkt.Nbytes, pkt.IPAddr, pkt.Seq, pkt.Rtt))
+		result.Store(fmt.Sprintf("%s: %d bytes from %s: icmp_seq=%d time=%v\n",
+			family, pkt.Nbytes, pkt.IPAddr, pkt.Seq, pkt.Rtt))
 	}
 
 	pinger.OnDuplicateRecv = func(pkt *ping.Packet) {
-		result.Store(fmt.Sprintf("%d bytes from %s: icmp_seq=%d time=%v ttl=%v (DUP!)\n",
-			pkt.Nbytes, pkt.IPAddr, pkt.Seq, pkt.Rtt, pkt.Ttl))
+		result.Store(fmt.Sprintf("%s: %d bytes from %s: icmp_seq=%d time=%v ttl=%v (DUP!)\n",
+			family, pkt.Nbytes, pkt.IPAddr, pkt.Seq, pkt.Rtt, pkt.Ttl))
 	}
 
-	err = pinger.Run()
+	err := pinger.Run()
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%s: %w", family, err)
 	}
 	stats := pinger.Statistics()
 	if stats.PacketsRecv == 1 {
 		return result.Load(), nil
 	}
-	return "", fmt.Errorf("ping lost after %d ms", pinger.Timeout.Milliseconds())
+	return "", fmt.Errorf("%s: ping lost after %d ms", family, pinger.Timeout.Milliseconds())
+}
+
+// pingBoundFunc sends a single echo request from a raw ICMP socket bound to the network interface.
+// It is used instead of go-ping, which cannot bind its sockets to an interface.
+func pingBoundFunc(node config.Node, iface string) (string, error) {
+	addr, err := net.ResolveIPAddr(ipNetwork(node.InternalIP), node.InternalIP)
+	if err != nil {
+		return "", err
+	}
+	family, network, proto := "ICMPv4", "ip4:icmp", 1
+	var requestType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if addr.IP.To4() == nil {
+		family, network, proto = "ICMPv6", "ip6:ipv6-icmp", 58
+		requestType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+
+	lc := net.ListenConfig{Control: bindToDeviceControl(iface)}
+	conn, err := lc.ListenPacket(context.Background(), network, "")
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", family, err)
+	}
+	defer conn.Close()
+
+	echo := &icmp.Echo{ID: rand.Intn(0xffff), Seq: 1, Data: pingPayload}
+	request, err := (&icmp.Message{Type: requestType, Body: echo}).Marshal(nil)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", family, err)
+	}
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(pingTimeout)); err != nil {
+		return "", fmt.Errorf("%s: %w", family, err)
+	}
+	if _, err := conn.WriteTo(request, addr); err != nil {
+		return "", fmt.Errorf("%s: %w", family, err)
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return "", fmt.Errorf("%s: ping lost after %d ms", family, pingTimeout.Milliseconds())
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", family, err)
+		}
+		// the raw socket receives all ICMP messages of the interface, skip the ones not replying to the request
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil || msg.Type != replyType {
+			continue
+		}
+		reply, ok := msg.Body.(*icmp.Echo)
+		if !ok || reply.ID != echo.ID || reply.Seq != echo.Seq || peer.String() != addr.String() {
+			continue
+		}
+		return fmt.Sprintf("%s: %d bytes from %s via %s: icmp_seq=%d time=%v\n",
+			family, n, peer, iface, reply.Seq, time.Since(start)), nil
+	}
+}
+
+// ipNetwork selects the network for the address family of an IP address.
+// For host names, the network is selected after resolving them.
+func ipNetwork(addr string) string {
+	ip := net.ParseIP(addr)
+	switch {
+	case ip == nil:
+		return "ip"
+	case ip.To4() != nil:
+		return "ip4"
+	default:
+		return "ip6"
+	}
 }
@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"strings"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("pingHost", func() {
+	DescribeTable("selects network by address family",
+		func(addr, expected string) {
+			Expect(ipNetwork(addr)).To(Equal(expected))
+		},
+		Entry("IPv4", "10.0.0.1", "ip4"),
+		Entry("IPv6", "fd00::1", "ip6"),
+		Entry("IPv4-mapped IPv6", "::ffff:10.0.0.1", "ip4"),
+		Entry("hostname", "node1", "ip"),
+	)
+
+	DescribeTable("pings loopback",
+		func(addr, family string) {
+			result, err := pingFunc(config.Node{Hostname: "localhost", InternalIP: addr})
+			if err != nil && (strings.Contains(err.Error(), "permitted") || strings.Contains(err.Error(), "address family not supported")) {
+				Skip("raw ICMP sockets not available: " + err.Error())
+			}
+			Expect(err).To(BeNil())
+			Expect(result).To(HavePrefix(family + ":"))
+		},
+		Entry("ICMPv4", "127.0.0.1", "ICMPv4"),
+		Entry("ICMPv6", "::1", "ICMPv6"),
+	)
+
+	It("pings loopback bound to the interface", func() {
+		result, err := pingBoundFunc(config.Node{Hostname: "localhost", InternalIP: "127.0.0.1"}, "lo")
+		if err != nil && (strings.Contains(err.Error(), "permitted") || strings.Contains(err.Error(), "not supported")) {
+			Skip("raw ICMP sockets not available: " + err.Error())
+		}
+		Expect(err).To(BeNil())
+		Expect(result).To(HavePrefix("ICMPv4: "))
+		Expect(result).To(ContainSubstring(" via lo: icmp_seq=1 "))
+	})
+})
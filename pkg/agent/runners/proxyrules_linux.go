@@ -0,0 +1,156 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build linux
+
+package runners
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// iptSoGetInfo and iptSoGetEntries are the socket options IPT_SO_GET_INFO and IPT_SO_GET_ENTRIES (same values for IPv6)
+	iptSoGetInfo    = 64
+	iptSoGetEntries = 65
+	// xtTableMaxNameLen is the size of the table name of struct ipt_getinfo and struct ipt_get_entries
+	xtTableMaxNameLen = 32
+	// iptGetInfoLen is the size of struct ipt_getinfo, the size of the entries is its last field
+	iptGetInfoLen = 84
+	// iptGetEntriesLen is the size of struct ipt_get_entries without the entries (aligned to 8 bytes)
+	iptGetEntriesLen = 40
+)
+
+// readIPTablesRules returns the number of rules of the kube-proxy chains of iptables with nftables and legacy backend.
+// Only existing legacy tables are read to avoid loading their kernel modules. Needs the capability NET_ADMIN.
+func readIPTablesRules() (nft, legacy int, err error) {
+	nft, err = readNftRules()
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading nftables rules failed: %w", err)
+	}
+	for _, family := range []struct {
+		namesFile string
+		domain    int
+		level     int
+		layout    iptEntryLayout
+	}{
+		{"/proc/net/ip_tables_names", unix.AF_INET, unix.SOL_IP, iptEntryLayoutIPv4},
+		{"/proc/net/ip6_tables_names", unix.AF_INET6, unix.SOL_IPV6, iptEntryLayoutIPv6},
+	} {
+		data, err := os.ReadFile(family.namesFile)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, table := range strings.Fields(string(data)) {
+			count, err := readLegacyTableRules(family.domain, family.level, table, family.layout)
+			if err != nil {
+				return 0, 0, fmt.Errorf("reading iptables table %s failed: %w", table, err)
+			}
+			legacy += count
+		}
+	}
+	return nft, legacy, nil
+}
+
+// readLegacyTableRules reads the entries of a legacy iptables table and counts the rules of the kube-proxy chains.
+func readLegacyTableRules(domain, level int, table string, layout iptEntryLayout) (int, error) {
+	fd, err := unix.Socket(domain, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.IPPROTO_RAW)
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Close(fd)
+
+	info := make([]byte, iptGetInfoLen)
+	copy(info, table)
+	if err := getsockopt(fd, level, iptSoGetInfo, info); err != nil {
+		return 0, err
+	}
+	size := binary.LittleEndian.Uint32(info[iptGetInfoLen-4:])
+	entries := make([]byte, iptGetEntriesLen+int(size))
+	copy(entries, table)
+	binary.LittleEndian.PutUint32(entries[xtTableMaxNameLen:], size)
+	if err := getsockopt(fd, level, iptSoGetEntries, entries); err != nil {
+		// the table has changed between both calls, the next check reads it again
+		return 0, err
+	}
+	return countIPTEntries(entries[iptGetEntriesLen:], layout)
+}
+
+func getsockopt(fd, level, opt int, buf []byte) error {
+	length := uint32(len(buf))
+	_, _, errno := syscall.Syscall6(syscall.SYS_GETSOCKOPT, uintptr(fd), uintptr(level), uintptr(opt),
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&length)), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// readNftRules dumps the nftables rules of all families via netlink and counts the rules of the kube-proxy chains.
+func readNftRules() (int, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_NETFILTER)
+	if err != nil {
+		if err == unix.EPROTONOSUPPORT {
+			// kernel without nftables
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer unix.Close(fd)
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return 0, err
+	}
+
+	// struct nlmsghdr followed by struct nfgenmsg with family AF_UNSPEC and version NFNETLINK_V0
+	req := make([]byte, unix.NLMSG_HDRLEN+nfgenmsgLen)
+	binary.LittleEndian.PutUint32(req[0:], uint32(len(req)))
+	binary.LittleEndian.PutUint16(req[4:], uint16(unix.NFNL_SUBSYS_NFTABLES<<8|unix.NFT_MSG_GETRULE))
+	binary.LittleEndian.PutUint16(req[6:], uint16(unix.NLM_F_REQUEST|unix.NLM_F_DUMP))
+	binary.LittleEndian.PutUint32(req[8:], 1)
+	req[unix.NLMSG_HDRLEN] = unix.AF_UNSPEC
+	req[unix.NLMSG_HDRLEN+1] = unix.NFNETLINK_V0
+	if err := unix.Sendto(fd, req, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	buf := make([]byte, 1<<16)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return 0, err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return 0, err
+		}
+		for _, msg := range msgs {
+			switch msg.Header.Type {
+			case unix.NLMSG_DONE:
+				return count, nil
+			case unix.NLMSG_ERROR:
+				if len(msg.Data) >= 4 {
+					if errno := -int32(binary.LittleEndian.Uint32(msg.Data)); errno != 0 {
+						return 0, syscall.Errno(errno)
+					}
+				}
+				return count, nil
+			case unix.NFNL_SUBSYS_NFTABLES<<8 | unix.NFT_MSG_NEWRULE:
+				if isKubeProxyNftRule(msg.Data) {
+					count++
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux
+
+package runners
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// readIPTablesRules is not supported, as iptables and nftables are only available on Linux.
+func readIPTablesRules() (nft, legacy int, err error) {
+	return 0, 0, fmt.Errorf("reading iptables rules is not supported on %s", runtime.GOOS)
+}
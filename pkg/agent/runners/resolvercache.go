@@ -0,0 +1,449 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	"golang.org/x/net/dns/dnsmessage"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// ResolverCacheHit is the result of a lookup in the resolver cache answered by an entry within its TTL.
+	ResolverCacheHit = "hit"
+	// ResolverCacheMiss is the result of a lookup in the resolver cache which has been resolved synchronously.
+	ResolverCacheMiss = "miss"
+	// ResolverCacheStale is the result of a lookup in the resolver cache answered by the addresses of an expired or
+	// failed resolution while the host name is refreshed asynchronously.
+	ResolverCacheStale = "stale"
+
+	// resolverEntryRetention is the time after which host names not used by any check are dropped from the cache.
+	resolverEntryRetention = 10 * time.Minute
+	// resolverRefreshInterval is the interval of looking for expired entries to refresh.
+	resolverRefreshInterval = 1 * time.Second
+	// resolverLookupTimeout is the timeout of a single resolution.
+	resolverLookupTimeout = 10 * time.Second
+)
+
+// ResolverCacheListener is notified about the result of each lookup in the resolver cache.
+type ResolverCacheListener func(result string)
+
+// hostLookupFunc resolves a host name and returns its addresses and the TTL of the answers, 0 if unknown.
+type hostLookupFunc func(ctx context.Context, host string) (addrs []string, ttl time.Duration, err error)
+
+// resolverEntry is a cached resolution of a host name. An entry without expiry has been registered, but not resolved yet.
+type resolverEntry struct {
+	addrs []string
+	// err is the error of the last resolution. The addresses of the last successful resolution are kept.
+	err        error
+	expires    time.Time
+	lastUsed   time.Time
+	refreshing bool
+}
+
+// resolverCache resolves the host names of the destinations of the jobs asynchronously and shares the addresses between them,
+// so that the checks neither wait for nor load the DNS server on each check. Each resolution is recorded as observation of the
+// pseudo job 'dns-refresh'.
+type resolverCache struct {
+	lock     sync.Mutex
+	entries  map[string]*resolverEntry
+	minTTL   time.Duration
+	maxTTL   time.Duration
+	lookup   hostLookupFunc
+	now      func() time.Time
+	ch       chan<- *nwpd.Observation
+	listener ResolverCacheListener
+	stop     chan struct{}
+}
+
+var (
+	resolverCacheLock   sync.Mutex
+	sharedResolverCache *resolverCache
+)
+
+func newResolverCache(cfg *config.ResolverCacheConfig, ch chan<- *nwpd.Observation, listener ResolverCacheListener) *resolverCache {
+	return &resolverCache{
+		entries:  map[string]*resolverEntry{},
+		minTTL:   cfg.MinTTLOrDefault(),
+		maxTTL:   cfg.MaxTTLOrDefault(),
+		lookup:   lookupHostWithTTL,
+		now:      time.Now,
+		ch:       ch,
+		listener: listener,
+		stop:     make(chan struct{}),
+	}
+}
+
+// StartResolverCache starts the resolver cache shared by all jobs not bypassing it. If it is already running, only the TTL range
+// is updated. The resolutions are recorded as observations sent to the channel, the lookups of the checks are reported to the listener.
+func StartResolverCache(cfg *config.ResolverCacheConfig, ch chan<- *nwpd.Observation, listener ResolverCacheListener) {
+	resolverCacheLock.Lock()
+	defer resolverCacheLock.Unlock()
+	if c := sharedResolverCache; c != nil {
+		c.lock.Lock()
+		c.minTTL = cfg.MinTTLOrDefault()
+		c.maxTTL = cfg.MaxTTLOrDefault()
+		c.lock.Unlock()
+		return
+	}
+	sharedResolverCache = newResolverCache(cfg, ch, listener)
+	go sharedResolverCache.run()
+}
+
+// StopResolverCache stops the shared resolver cache. Afterwards, the checks resolve host names themselves.
+func StopResolverCache() {
+	resolverCacheLock.Lock()
+	defer resolverCacheLock.Unlock()
+	if sharedResolverCache != nil {
+		close(sharedResolverCache.stop)
+		sharedResolverCache = nil
+	}
+}
+
+// getResolverCache returns the shared resolver cache or nil if it is not running.
+func getResolverCache() *resolverCache {
+	resolverCacheLock.Lock()
+	defer resolverCacheLock.Unlock()
+	return sharedResolverCache
+}
+
+// PreResolve registers the host names of the destinations of the runner in the shared resolver cache, so that they are resolved
+// before the first check. It does nothing if the cache is not running or the job bypasses it.
+func PreResolve(runner Runner) {
+	c := getResolverCache()
+	if c == nil || runner.Config().BypassResolverCache {
+		return
+	}
+	if r, ok := runner.(interface{ resolvedHosts() []string }); ok {
+		c.register(r.resolvedHosts())
+	}
+}
+
+// register adds entries for the host names to be resolved by the next refresh.
+func (c *resolverCache) register(hosts []string) {
+	now := c.now()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, host := range hosts {
+		if host == "" || net.ParseIP(host) != nil {
+			continue
+		}
+		if e := c.entries[host]; e != nil {
+			e.lastUsed = now
+		} else {
+			c.entries[host] = &resolverEntry{lastUsed: now}
+		}
+	}
+}
+
+// resolve returns the cached addresses of the host name. On a miss, the host name is resolved synchronously and the result is cached.
+// The addresses of an expired entry are returned while it is refreshed asynchronously.
+func (c *resolverCache) resolve(host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	now := c.now()
+	c.lock.Lock()
+	e := c.entries[host]
+	if e != nil && !e.expires.IsZero() {
+		e.lastUsed = now
+		valid := now.Before(e.expires)
+		switch {
+		case valid && e.err == nil:
+			addrs := e.addrs
+			c.lock.Unlock()
+			c.report(ResolverCacheHit)
+			return addrs, nil
+		case len(e.addrs) > 0:
+			if !valid {
+				c.refreshAsync(host, e)
+			}
+			addrs := e.addrs
+			c.lock.Unlock()
+			c.report(ResolverCacheStale)
+			return addrs, nil
+		case valid:
+			// the failure is cached for the minimum TTL to avoid a resolution on each check
+			err := e.err
+			c.lock.Unlock()
+			c.report(ResolverCacheHit)
+			return nil, err
+		}
+	}
+	c.lock.Unlock()
+	c.report(ResolverCacheMiss)
+	return c.refresh(host)
+}
+
+func (c *resolverCache) report(result string) {
+	if c.listener != nil {
+		c.listener(result)
+	}
+}
+
+// refreshAsync starts the refresh of an entry unless it is already refreshing. It must be called with the lock held.
+func (c *resolverCache) refreshAsync(host string, e *resolverEntry) {
+	if e.refreshing {
+		return
+	}
+	e.refreshing = true
+	go c.refresh(host)
+}
+
+// refresh resolves the host name, updates its entry and records the resolution as observation.
+func (c *resolverCache) refresh(host string) ([]string, error) {
+	start := c.now()
+	ctx, cancel := context.WithTimeout(context.Background(), resolverLookupTimeout)
+	addrs, ttl, err := c.lookup(ctx, host)
+	cancel()
+	if err == nil && len(addrs) == 0 {
+		err = fmt.Errorf("no addresses for %s", host)
+	}
+	end := c.now()
+
+	c.lock.Lock()
+	ttl = c.clampTTL(ttl, err)
+	e := c.entries[host]
+	if e == nil {
+		e = &resolverEntry{lastUsed: start}
+		c.entries[host] = e
+	}
+	e.err = err
+	if err == nil {
+		e.addrs = addrs
+	}
+	e.expires = end.Add(ttl)
+	e.refreshing = false
+	c.lock.Unlock()
+
+	obs := &nwpd.Observation{
+		SrcHost:   GetNodeName(),
+		DestHost:  host,
+		Timestamp: timestamppb.New(start),
+		JobID:     config.ResolverCacheJobID,
+		Protocol:  nwpd.ProtocolUDP,
+		Duration:  durationpb.New(end.Sub(start)),
+		Period:    durationpb.New(ttl),
+		Ok:        err == nil,
+	}
+	if err != nil {
+		obs.Result = fmt.Sprintf("error: %s", err)
+	} else {
+		obs.Result = fmt.Sprintf("%s (ttl %s)", strings.Join(addrs, ","), ttl)
+	}
+	if c.ch != nil {
+		select {
+		case c.ch <- obs:
+		case <-c.stop:
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// clampTTL limits the TTL of the answers to the configured range. Failures are cached for the minimum TTL,
+// answers without TTL (e.g. from /etc/hosts) for the maximum TTL. It must be called with the lock held.
+func (c *resolverCache) clampTTL(ttl time.Duration, err error) time.Duration {
+	switch {
+	case err != nil:
+		return c.minTTL
+	case ttl == 0 || ttl > c.maxTTL:
+		return c.maxTTL
+	case ttl < c.minTTL:
+		return c.minTTL
+	default:
+		return ttl
+	}
+}
+
+// run refreshes expired entries until the cache is stopped.
+func (c *resolverCache) run() {
+	ticker := time.NewTicker(resolverRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.refreshExpired()
+		}
+	}
+}
+
+// refreshExpired starts the refresh of registered or expired entries and drops the entries not used anymore.
+func (c *resolverCache) refreshExpired() {
+	now := c.now()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for host, e := range c.entries {
+		if now.Sub(e.lastUsed) > resolverEntryRetention {
+			delete(c.entries, host)
+			continue
+		}
+		if !now.Before(e.expires) {
+			c.refreshAsync(host, e)
+		}
+	}
+}
+
+// resolveEndpointIP returns the endpoint with a DNS name given instead of the IP address replaced by an address from the
+// shared resolver cache. The endpoint is returned unchanged if the cache is not running.
+func resolveEndpointIP(endpoint config.Endpoint) (config.Endpoint, error) {
+	c := getResolverCache()
+	if c == nil || net.ParseIP(endpoint.IP) != nil {
+		return endpoint, nil
+	}
+	addrs, err := c.resolve(endpoint.IP)
+	if err != nil {
+		return endpoint, err
+	}
+	endpoint.IP = addrs[0]
+	return endpoint, nil
+}
+
+// endpointIPHost returns the DNS name given instead of the IP address of the endpoint, empty if it is an IP address.
+func endpointIPHost(endpoint config.Endpoint) string {
+	if net.ParseIP(endpoint.IP) != nil {
+		return ""
+	}
+	return endpoint.IP
+}
+
+// cachedDialContext wraps the dial function to connect to an address of the host name from the shared resolver cache.
+// If the dial function is nil, the default dialer is used. The host name is resolved by the dial function if the cache is not running.
+func cachedDialContext(dialContext func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dialContext == nil {
+		dialContext = (&net.Dialer{Timeout: 30 * time.Second}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c := getResolverCache()
+		if c == nil {
+			return dialContext(ctx, network, addr)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := c.resolve(host)
+		if err != nil {
+			return nil, err
+		}
+		return dialContext(ctx, network, net.JoinHostPort(addrs[0], port))
+	}
+}
+
+// lookupHostWithTTL resolves the host name with the Go resolver and records the minimum TTL of the answers of the DNS responses.
+func lookupHostWithTTL(ctx context.Context, host string) ([]string, time.Duration, error) {
+	recorder := &ttlRecorder{}
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{}
+			conn, err := d.DialContext(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			if udp, ok := conn.(*net.UDPConn); ok {
+				return &ttlPacketConn{UDPConn: udp, recorder: recorder}, nil
+			}
+			return &ttlStreamConn{Conn: conn, recorder: recorder}, nil
+		},
+	}
+	addrs, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.Strings(addrs)
+	return addrs, recorder.get(), nil
+}
+
+// ttlRecorder records the minimum TTL of the answers of DNS responses.
+type ttlRecorder struct {
+	lock  sync.Mutex
+	ttl   time.Duration
+	found bool
+}
+
+func (r *ttlRecorder) record(msg []byte) {
+	var parser dnsmessage.Parser
+	if _, err := parser.Start(msg); err != nil {
+		return
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return
+	}
+	for {
+		header, err := parser.AnswerHeader()
+		if err != nil {
+			return
+		}
+		ttl := time.Duration(header.TTL) * time.Second
+		r.lock.Lock()
+		if !r.found || ttl < r.ttl {
+			r.ttl = ttl
+			r.found = true
+		}
+		r.lock.Unlock()
+		if err := parser.SkipAnswer(); err != nil {
+			return
+		}
+	}
+}
+
+// get returns the minimum TTL, 0 if no answer has been recorded.
+func (r *ttlRecorder) get() time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.ttl
+}
+
+// ttlPacketConn records the TTLs of the DNS responses received over UDP. It must stay a net.PacketConn,
+// as the Go resolver uses the message framing of TCP otherwise.
+type ttlPacketConn struct {
+	*net.UDPConn
+	recorder *ttlRecorder
+}
+
+func (c *ttlPacketConn) Read(b []byte) (int, error) {
+	n, err := c.UDPConn.Read(b)
+	if n > 0 {
+		c.recorder.record(b[:n])
+	}
+	return n, err
+}
+
+// ttlStreamConn records the TTLs of the DNS responses received over TCP, which are prefixed by their two byte length.
+type ttlStreamConn struct {
+	net.Conn
+	recorder *ttlRecorder
+	buf      []byte
+}
+
+func (c *ttlStreamConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.buf = append(c.buf, b[:n]...)
+	for len(c.buf) >= 2 {
+		size := int(binary.BigEndian.Uint16(c.buf))
+		if len(c.buf) < 2+size {
+			break
+		}
+		c.recorder.record(c.buf[2 : 2+size])
+		c.buf = c.buf[2+size:]
+	}
+	return n, err
+}
@@ -0,0 +1,260 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/dns/dnsmessage"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("resolver cache", func() {
+	var (
+		lock     sync.Mutex
+		now      time.Time
+		lookups  map[string]int
+		answers  map[string][]string
+		ttl      time.Duration
+		results  map[string]int
+		obsChan  chan *nwpd.Observation
+		cache    *resolverCache
+		minTTL   = 5 * time.Second
+		maxTTL   = time.Minute
+		lookedUp = func(host string) int {
+			lock.Lock()
+			defer lock.Unlock()
+			return lookups[host]
+		}
+		advance = func(d time.Duration) {
+			lock.Lock()
+			defer lock.Unlock()
+			now = now.Add(d)
+		}
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+		lookups = map[string]int{}
+		answers = map[string][]string{"db.example.com": {"10.0.0.1"}, "localhost": {"127.0.0.1"}}
+		ttl = 30 * time.Second
+		results = map[string]int{}
+		obsChan = make(chan *nwpd.Observation, 10)
+		cfg := &config.ResolverCacheConfig{MinTTL: &metav1.Duration{Duration: minTTL}, MaxTTL: &metav1.Duration{Duration: maxTTL}}
+		cache = newResolverCache(cfg, obsChan, func(result string) {
+			lock.Lock()
+			defer lock.Unlock()
+			results[result]++
+		})
+		cache.now = func() time.Time {
+			lock.Lock()
+			defer lock.Unlock()
+			return now
+		}
+		cache.lookup = func(_ context.Context, host string) ([]string, time.Duration, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			lookups[host]++
+			if addrs, ok := answers[host]; ok {
+				return addrs, ttl, nil
+			}
+			return nil, 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+	})
+	AfterEach(func() {
+		resolverCacheLock.Lock()
+		sharedResolverCache = nil
+		resolverCacheLock.Unlock()
+	})
+
+	It("resolves synchronously on a miss and caches the addresses for the TTL", func() {
+		addrs, err := cache.resolve("db.example.com")
+		Expect(err).To(BeNil())
+		Expect(addrs).To(Equal([]string{"10.0.0.1"}))
+		obs := <-obsChan
+		Expect(obs.JobID).To(Equal(config.ResolverCacheJobID))
+		Expect(obs.DestHost).To(Equal("db.example.com"))
+		Expect(obs.Ok).To(BeTrue())
+		Expect(obs.Result).To(Equal("10.0.0.1 (ttl 30s)"))
+
+		advance(29 * time.Second)
+		addrs, err = cache.resolve("db.example.com")
+		Expect(err).To(BeNil())
+		Expect(addrs).To(Equal([]string{"10.0.0.1"}))
+		Expect(lookedUp("db.example.com")).To(Equal(1))
+		Expect(results).To(Equal(map[string]int{ResolverCacheMiss: 1, ResolverCacheHit: 1}))
+
+		addrs, err = cache.resolve("10.0.0.2")
+		Expect(err).To(BeNil())
+		Expect(addrs).To(Equal([]string{"10.0.0.2"}))
+		Expect(lookedUp("10.0.0.2")).To(Equal(0))
+	})
+
+	It("returns the stale addresses of an expired entry while refreshing asynchronously", func() {
+		_, err := cache.resolve("db.example.com")
+		Expect(err).To(BeNil())
+		<-obsChan
+
+		lock.Lock()
+		answers["db.example.com"] = []string{"10.0.0.3"}
+		lock.Unlock()
+		advance(31 * time.Second)
+		addrs, err := cache.resolve("db.example.com")
+		Expect(err).To(BeNil())
+		Expect(addrs).To(Equal([]string{"10.0.0.1"}))
+		obs := <-obsChan
+		Expect(obs.Result).To(Equal("10.0.0.3 (ttl 30s)"))
+
+		addrs, err = cache.resolve("db.example.com")
+		Expect(err).To(BeNil())
+		Expect(addrs).To(Equal([]string{"10.0.0.3"}))
+		Expect(lookedUp("db.example.com")).To(Equal(2))
+		Expect(results).To(Equal(map[string]int{ResolverCacheMiss: 1, ResolverCacheStale: 1, ResolverCacheHit: 1}))
+	})
+
+	It("keeps the addresses if a refresh fails", func() {
+		_, err := cache.resolve("db.example.com")
+		Expect(err).To(BeNil())
+		<-obsChan
+
+		lock.Lock()
+		delete(answers, "db.example.com")
+		lock.Unlock()
+		advance(31 * time.Second)
+		cache.refreshExpired()
+		obs := <-obsChan
+		Expect(obs.Ok).To(BeFalse())
+		Expect(obs.Result).To(Equal("error: lookup db.example.com: no such host"))
+
+		addrs, err := cache.resolve("db.example.com")
+		Expect(err).To(BeNil())
+		Expect(addrs).To(Equal([]string{"10.0.0.1"}))
+		Expect(results[ResolverCacheStale]).To(Equal(1))
+	})
+
+	It("caches failures for the minimum TTL", func() {
+		_, err := cache.resolve("unknown.example.com")
+		Expect(err).To(MatchError("lookup unknown.example.com: no such host"))
+		Expect((<-obsChan).Period.AsDuration()).To(Equal(minTTL))
+
+		advance(minTTL - time.Second)
+		_, err = cache.resolve("unknown.example.com")
+		Expect(err).NotTo(BeNil())
+		Expect(lookedUp("unknown.example.com")).To(Equal(1))
+
+		advance(time.Second)
+		_, err = cache.resolve("unknown.example.com")
+		Expect(err).NotTo(BeNil())
+		Expect(lookedUp("unknown.example.com")).To(Equal(2))
+	})
+
+	It("limits the TTL to the configured range", func() {
+		Expect(cache.clampTTL(time.Second, nil)).To(Equal(minTTL))
+		Expect(cache.clampTTL(30*time.Second, nil)).To(Equal(30 * time.Second))
+		Expect(cache.clampTTL(time.Hour, nil)).To(Equal(maxTTL))
+		Expect(cache.clampTTL(0, nil)).To(Equal(maxTTL))
+		Expect(cache.clampTTL(30*time.Second, fmt.Errorf("failed"))).To(Equal(minTTL))
+	})
+
+	It("resolves registered host names asynchronously and drops unused ones", func() {
+		cache.register([]string{"db.example.com", "10.0.0.2", ""})
+		Expect(cache.entries).To(HaveLen(1))
+		cache.refreshExpired()
+		Expect((<-obsChan).DestHost).To(Equal("db.example.com"))
+		Expect(lookedUp("db.example.com")).To(Equal(1))
+
+		addrs, err := cache.resolve("db.example.com")
+		Expect(err).To(BeNil())
+		Expect(addrs).To(Equal([]string{"10.0.0.1"}))
+		Expect(results).To(Equal(map[string]int{ResolverCacheHit: 1}))
+
+		advance(resolverEntryRetention + time.Second)
+		cache.refreshExpired()
+		cache.lock.Lock()
+		defer cache.lock.Unlock()
+		Expect(cache.entries).To(BeEmpty())
+	})
+
+	It("is used by the TCP checks unless the job bypasses it", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		defer listener.Close()
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+			}
+		}()
+		endpoint := config.Endpoint{Hostname: "local", IP: "localhost", Port: listener.Addr().(*net.TCPAddr).Port}
+		sharedResolverCache = cache
+
+		cached := NewCheckTCPPort([]config.Endpoint{endpoint}, RunnerConfig{Job: config.Job{JobID: "tcp-cached"}})
+		PreResolve(cached)
+		Expect(cache.entries).To(HaveKey("localhost"))
+		ch := make(chan *nwpd.Observation, 1)
+		cached.Run(context.Background(), ch)
+		obs := <-ch
+		Expect(obs.Ok).To(BeTrue(), obs.Result)
+		Expect(obs.DestIP).To(Equal("127.0.0.1"))
+		Expect(lookedUp("localhost")).To(Equal(1))
+		Expect((<-obsChan).DestHost).To(Equal("localhost"))
+
+		bypassing := NewCheckTCPPort([]config.Endpoint{endpoint}, RunnerConfig{Job: config.Job{JobID: "tcp-bypass", BypassResolverCache: true}})
+		Expect(bypassing.resolvedHosts()).To(BeEmpty())
+		bypassing.Run(context.Background(), ch)
+		obs = <-ch
+		Expect(obs.Ok).To(BeTrue(), obs.Result)
+		Expect(obs.DestIP).To(Equal("localhost"))
+		Expect(lookedUp("localhost")).To(Equal(1))
+	})
+
+	It("records the minimum TTL of the answers of DNS responses over TCP", func() {
+		name := dnsmessage.MustNewName("db.example.com.")
+		msg := dnsmessage.Message{
+			Header:    dnsmessage.Header{Response: true},
+			Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+			Answers: []dnsmessage.Resource{
+				{Header: dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: 60}, Body: &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}}},
+				{Header: dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: 20}, Body: &dnsmessage.AResource{A: [4]byte{10, 0, 0, 2}}},
+			},
+		}
+		data, err := msg.Pack()
+		Expect(err).To(BeNil())
+		framed := make([]byte, 2+len(data))
+		binary.BigEndian.PutUint16(framed, uint16(len(data)))
+		copy(framed[2:], data)
+
+		client, server := net.Pipe()
+		defer client.Close()
+		go func() {
+			defer server.Close()
+			// the response arrives in chunks not aligned with the framing
+			server.Write(framed[:5])
+			server.Write(framed[5:])
+		}()
+		recorder := &ttlRecorder{}
+		conn := &ttlStreamConn{Conn: client, recorder: recorder}
+		buf := make([]byte, 2)
+		_, err = io.ReadFull(conn, buf)
+		Expect(err).To(BeNil())
+		Expect(recorder.get()).To(Equal(time.Duration(0)))
+		_, err = io.ReadFull(conn, make([]byte, binary.BigEndian.Uint16(buf)))
+		Expect(err).To(BeNil())
+		Expect(recorder.get()).To(Equal(20 * time.Second))
+	})
+})
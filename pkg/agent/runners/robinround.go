@@ -5,6 +5,8 @@
 package runners
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -17,12 +19,103 @@ import (
 
 type runFunc[T config.WithDestHost] func(item T) (result string, err error)
 
+// checkDetails are details of a check recorded in the observation in addition to the result.
+type checkDetails struct {
+	// srcPort is the source port the check was bound to, 0 if none was bound
+	srcPort int
+	// udpDuration and tcpDuration are the durations of DNS queries over both UDP and TCP
+	udpDuration time.Duration
+	tcpDuration time.Duration
+}
+
+// detailedRunFunc is a run function which returns details of the check in addition to the result.
+type detailedRunFunc[T config.WithDestHost] func(item T) (result string, details checkDetails, err error)
+
+// partialError is returned by a run function if some but not all connections of a check failed.
+type partialError struct {
+	msg string
+}
+
+func (e *partialError) Error() string {
+	return e.msg
+}
+
+// classifiedError is returned by a run function for failures of a distinguished class.
+type classifiedError struct {
+	class string
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return fmt.Sprintf("%s: %s", e.class, e.err)
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
 type robinRound[T config.WithDestHost] struct {
 	itemsName string
+	protocol  string
 	runFunc   runFunc[T]
 	items     []T
 	next      int
 	config    RunnerConfig
+	// listSource and listVersion identify the endpoint list if the items are loaded from a URL
+	listSource  string
+	listVersion string
+	// netns is the path of the network namespace to run the checks in
+	netns string
+	// iface is the name of the network interface the checks are bound to
+	iface string
+	// destAddressType is the address type of the destination nodes (internal or external), empty if not checking nodes
+	destAddressType string
+	// warmUp marks the observations of destinations whose pods have been started recently, nil if not applicable
+	warmUp *warmUp
+	// detailedRunFunc is used instead of runFunc if set, so that the details of the checks are recorded in the observations
+	detailedRunFunc detailedRunFunc[T]
+	// connectionKey returns the key for sharing the result of the check of an item with other jobs.
+	// It is nil if the check cannot be shared.
+	connectionKey func(item T) (connectionKey, bool)
+	// sampler selects the items of the rounds if a sample strategy is configured
+	sampler *sampler
+	// round contains the indices of the items of the current round if a sample strategy is configured
+	round []int
+	// resolvedHost returns the host name of an item resolved with the shared resolver cache, empty if it has none.
+	// It is nil if the job bypasses the cache.
+	resolvedHost func(item T) string
+	// resolve returns the item with its host name replaced by an address from the shared resolver cache.
+	// It is nil if the run function resolves the host name itself.
+	resolve func(item T) (T, error)
+}
+
+// setEndpointList records source and version of the endpoint list in the observations.
+func (r *robinRound[T]) setEndpointList(list *config.EndpointList) {
+	if list != nil {
+		r.listSource = list.Source
+		r.listVersion = list.Version
+	}
+}
+
+// setNetNS runs the checks in the network namespace given by path.
+func (r *robinRound[T]) setNetNS(path string) {
+	r.netns = path
+}
+
+// setInterface records the network interface the checks are bound to in the observations.
+// The run functions are responsible for binding their sockets to it.
+func (r *robinRound[T]) setInterface(iface string) {
+	r.iface = iface
+}
+
+// setDestAddressType records the address type of the destination nodes in the observations.
+func (r *robinRound[T]) setDestAddressType(addressType string) {
+	r.destAddressType = addressType
+}
+
+// setWarmUp records the observations of destinations within their warm-up period as warming up.
+func (r *robinRound[T]) setWarmUp(w *warmUp) {
+	r.warmUp = w
 }
 
 func (r *robinRound[T]) Config() RunnerConfig {
@@ -30,6 +123,9 @@ func (r *robinRound[T]) Config() RunnerConfig {
 }
 
 func (r *robinRound[T]) Description() string {
+	if r.iface != "" {
+		return fmt.Sprintf("%d %s, interface %s", len(r.items), r.itemsName, r.iface)
+	}
 	return fmt.Sprintf("%d %s", len(r.items), r.itemsName)
 }
 
@@ -45,27 +141,176 @@ func (r *robinRound[T]) DestHosts() []string {
 	return hosts
 }
 
-func (r *robinRound[T]) Run(ch chan<- *nwpd.Observation) {
-	item := r.items[r.next]
-	r.next = (r.next + 1) % len(r.items)
+// resolvedHosts returns the host names of the items to be resolved by the shared resolver cache.
+func (r *robinRound[T]) resolvedHosts() []string {
+	if r.resolvedHost == nil {
+		return nil
+	}
+	var hosts []string
+	for _, item := range r.items {
+		if host := r.resolvedHost(item); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
 
+func (r *robinRound[T]) Run(ctx context.Context, ch chan<- *nwpd.Observation) {
+	r.run(ctx, ch, r.nextItem(), "")
+}
+
+// nextItem returns the next item of the current round. With a sample strategy, the items of a new round are
+// selected by the sampler and reported to the sample listener.
+func (r *robinRound[T]) nextItem() T {
+	if !isSampled(r.config, len(r.items)) {
+		item := r.items[r.next%len(r.items)]
+		r.next = (r.next + 1) % len(r.items)
+		return item
+	}
+	if r.next >= len(r.round) {
+		if r.sampler == nil {
+			r.sampler = newSampler(r.config.SampleStrategy, r.config.SampleSize)
+		}
+		destHosts := r.DestHosts()
+		r.round = r.sampler.sample(GetNodeName(), destHosts)
+		r.next = 0
+		selected := make([]string, len(r.round))
+		for i, idx := range r.round {
+			selected[i] = destHosts[idx]
+		}
+		notifySampleListener(r.config.JobID, r.sampler.rounds, selected)
+	}
+	item := r.items[r.round[r.next]]
+	r.next++
+	return item
+}
+
+func (r *robinRound[T]) RunFor(ctx context.Context, ch chan<- *nwpd.Observation, destHost string, triggeredBy string) bool {
+	for _, item := range r.items {
+		if item.DestHost() == destHost {
+			r.run(ctx, ch, item, triggeredBy)
+			return true
+		}
+	}
+	return false
+}
+
+func (r *robinRound[T]) run(ctx context.Context, ch chan<- *nwpd.Observation, item T, triggeredBy string) {
+	if ctx.Err() != nil {
+		return
+	}
+	var resolveErr error
+	if r.resolve != nil && r.netns == "" {
+		// the cache resolves in the network namespace of the agent
+		item, resolveErr = r.resolve(item)
+	}
 	nodeName := GetNodeName()
 	obs := &nwpd.Observation{
-		SrcHost:   nodeName,
-		DestHost:  normalise(item.DestHost()),
-		Timestamp: timestamppb.Now(),
-		JobID:     r.config.JobID,
+		SrcHost:         nodeName,
+		DestHost:        normalise(item.DestHost()),
+		Timestamp:       timestamppb.Now(),
+		JobID:           r.config.JobID,
+		TriggeredBy:     triggeredBy,
+		Labels:          r.config.Labels,
+		Protocol:        r.protocol,
+		ListSource:      r.listSource,
+		ListVersion:     r.listVersion,
+		Netns:           r.netns,
+		Interface:       r.iface,
+		DestAddressType: r.destAddressType,
+	}
+	obs.WarmingUp = r.warmUp.isWarmingUp(obs.DestHost, obs.Timestamp.AsTime())
+	if a, ok := any(item).(config.WithDestAddress); ok {
+		obs.DestAddress = a.DestAddress()
+	}
+	if a, ok := any(item).(config.WithDestIPPort); ok {
+		obs.DestIP = a.DestIP()
+		obs.DestPort = int32(a.DestPort())
 	}
 
-	start := time.Now()
-	result, err := r.runFunc(item)
-	obs.Duration = durationpb.New(time.Since(start))
+	var (
+		result   string
+		details  checkDetails
+		duration time.Duration
+		sharedBy string
+		err      = resolveErr
+	)
+	var injectedDelay time.Duration
+	if fault := lookupFault(obs.JobID, nodeName, obs.DestHost); fault != nil && err == nil {
+		start := time.Now()
+		err = fault.apply(ctx)
+		injectedDelay = time.Since(start)
+	}
+	if err == nil {
+		result, details, duration, sharedBy, err = r.runShared(item)
+	}
+	duration += injectedDelay
+	obs.SrcPort = int32(details.srcPort)
+	if details.udpDuration > 0 {
+		obs.UdpDuration = durationpb.New(details.udpDuration)
+	}
+	if details.tcpDuration > 0 {
+		obs.TcpDuration = durationpb.New(details.tcpDuration)
+	}
+	obs.Duration = durationpb.New(duration)
+	obs.SharedResultOf = sharedBy
 	obs.Period = durationpb.New(r.config.Period * time.Duration(len(r.items)))
 	obs.Ok = err == nil
+	if _, ok := err.(*partialError); ok {
+		obs.Partial = true
+	}
+	var classified *classifiedError
+	if errors.As(err, &classified) {
+		obs.FailureClass = classified.class
+	}
 	if err != nil {
 		obs.Result = fmt.Sprintf("error: %s", err)
 	} else {
 		obs.Result = result
 	}
-	ch <- obs
+	sendObservation(ctx, ch, obs)
+}
+
+// runShared runs the check of the item or reuses the result of another job if the job has the option reuseConnectionResult.
+// It returns the ID of the job whose result was reused, empty if the check was run.
+func (r *robinRound[T]) runShared(item T) (result string, details checkDetails, duration time.Duration, sharedBy string, err error) {
+	if r.config.ReuseConnectionResult && r.connectionKey != nil {
+		if key, ok := r.connectionKey(item); ok {
+			key.netns = r.netns
+			result, duration, sharedBy, err = sharedConnectionResults.run(key, r.config.JobID, r.config.Period, true, func() (string, error) {
+				result, _, err := r.runItem(item)
+				return result, err
+			})
+			return
+		}
+	}
+	start := time.Now()
+	result, details, err = r.runItem(item)
+	duration = time.Since(start)
+	var shared *sharedResultError
+	if errors.As(err, &shared) {
+		sharedBy = shared.sharedBy
+	}
+	return
+}
+
+func (r *robinRound[T]) runItem(item T) (result string, details checkDetails, err error) {
+	call := func() (string, checkDetails, error) {
+		if r.detailedRunFunc != nil {
+			return r.detailedRunFunc(item)
+		}
+		result, err := r.runFunc(item)
+		return result, checkDetails{}, err
+	}
+	if r.netns == "" {
+		return call()
+	}
+	nsErr := runInNetNS(r.netns, func() error {
+		result, details, err = call()
+		return err
+	})
+	if err == nil {
+		err = nsErr
+	}
+	return
 }
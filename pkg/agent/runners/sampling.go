@@ -0,0 +1,152 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+// SampleListener is notified about the destination hosts selected for a new round of a job with a sample strategy.
+type SampleListener func(jobID string, round int, destHosts []string)
+
+var (
+	sampleListenerLock sync.Mutex
+	sampleListener     SampleListener
+)
+
+// SetSampleListener sets the listener notified about the destination hosts selected per round.
+func SetSampleListener(listener SampleListener) {
+	sampleListenerLock.Lock()
+	defer sampleListenerLock.Unlock()
+	sampleListener = listener
+}
+
+func notifySampleListener(jobID string, round int, destHosts []string) {
+	sampleListenerLock.Lock()
+	listener := sampleListener
+	sampleListenerLock.Unlock()
+	if listener != nil {
+		listener(jobID, round, destHosts)
+	}
+}
+
+// isSampled returns true if only a sample of the items is checked per round.
+func isSampled(cfg RunnerConfig, items int) bool {
+	switch cfg.SampleStrategy {
+	case config.SampleRandom, config.SampleConsistentHash:
+		return cfg.SampleSize > 0 && cfg.SampleSize < items
+	default:
+		return false
+	}
+}
+
+// sampler selects the destinations of the next round.
+type sampler struct {
+	strategy config.SampleStrategy
+	size     int
+	// rounds is the number of selected rounds
+	rounds int
+	// lastSelected is the round a destination host was selected last (strategy random)
+	lastSelected map[string]int
+	rand         *rand.Rand
+}
+
+func newSampler(strategy config.SampleStrategy, size int) *sampler {
+	return &sampler{
+		strategy:     strategy,
+		size:         size,
+		lastSelected: map[string]int{},
+		rand:         rand.New(rand.NewSource(randomSeed())),
+	}
+}
+
+// randomSeed returns a seed from the cryptographic random source, so that agents started at the same time
+// do not select the same samples.
+func randomSeed() int64 {
+	var seed int64
+	if err := binary.Read(crand.Reader, binary.LittleEndian, &seed); err != nil {
+		return time.Now().UnixNano()
+	}
+	return seed
+}
+
+// sample returns the indices of the destination hosts of the next round.
+func (s *sampler) sample(srcHost string, destHosts []string) []int {
+	s.rounds++
+	if s.size >= len(destHosts) {
+		all := make([]int, len(destHosts))
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+	if s.strategy == config.SampleConsistentHash {
+		return s.consistentHash(srcHost, destHosts)
+	}
+	return s.weightedRandom(destHosts)
+}
+
+// weightedRandom selects the destination hosts by weighted random sampling without replacement (Efraimidis-Spirakis).
+// The weight of a destination host is the number of rounds since it was selected last.
+func (s *sampler) weightedRandom(destHosts []string) []int {
+	type candidate struct {
+		index int
+		key   float64
+	}
+	candidates := make([]candidate, len(destHosts))
+	for i, host := range destHosts {
+		weight := float64(s.rounds - s.lastSelected[host])
+		candidates[i] = candidate{index: i, key: math.Pow(s.rand.Float64(), 1/weight)}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].key > candidates[j].key })
+
+	selected := make([]int, s.size)
+	for i := range selected {
+		selected[i] = candidates[i].index
+		s.lastSelected[destHosts[candidates[i].index]] = s.rounds
+	}
+	// forget destination hosts which have been removed
+	if len(s.lastSelected) > len(destHosts) {
+		current := map[string]struct{}{}
+		for _, host := range destHosts {
+			current[host] = struct{}{}
+		}
+		for host := range s.lastSelected {
+			if _, ok := current[host]; !ok {
+				delete(s.lastSelected, host)
+			}
+		}
+	}
+	return selected
+}
+
+// consistentHash orders the destination hosts by a hash of source and destination host and selects the next window.
+func (s *sampler) consistentHash(srcHost string, destHosts []string) []int {
+	hashes := make([]uint64, len(destHosts))
+	order := make([]int, len(destHosts))
+	for i, host := range destHosts {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(srcHost + "/" + host))
+		hashes[i] = h.Sum64()
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return hashes[order[i]] < hashes[order[j]] })
+
+	start := ((s.rounds - 1) * s.size) % len(order)
+	selected := make([]int, s.size)
+	for i := range selected {
+		selected[i] = order[(start+i)%len(order)]
+	}
+	return selected
+}
@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("sampling", func() {
+	destHosts := func(n int) []string {
+		var hosts []string
+		for i := 0; i < n; i++ {
+			hosts = append(hosts, fmt.Sprintf("node-%d", i))
+		}
+		return hosts
+	}
+
+	It("selects distinct random samples and eventually all destination hosts", func() {
+		hosts := destHosts(20)
+		s := newSampler(config.SampleRandom, 5)
+		seen := map[int]int{}
+		for round := 0; round < 40; round++ {
+			selected := s.sample("src", hosts)
+			Expect(selected).To(HaveLen(5))
+			unique := map[int]struct{}{}
+			for _, idx := range selected {
+				unique[idx] = struct{}{}
+				seen[idx]++
+			}
+			Expect(unique).To(HaveLen(5))
+		}
+		Expect(seen).To(HaveLen(20))
+		// the weights prefer destination hosts not selected for a long time
+		for idx, count := range seen {
+			Expect(count).To(BeNumerically(">=", 4), "host %d", idx)
+		}
+	})
+
+	It("forgets removed destination hosts", func() {
+		s := newSampler(config.SampleRandom, 2)
+		s.sample("src", destHosts(10))
+		s.sample("src", destHosts(4))
+		Expect(len(s.lastSelected)).To(BeNumerically("<=", 4))
+	})
+
+	It("covers all destination hosts with consistent hash windows", func() {
+		hosts := destHosts(10)
+		s := newSampler(config.SampleConsistentHash, 4)
+		var rounds [][]int
+		seen := map[int]struct{}{}
+		for round := 0; round < 3; round++ {
+			selected := s.sample("src", hosts)
+			Expect(selected).To(HaveLen(4))
+			rounds = append(rounds, selected)
+			for _, idx := range selected {
+				seen[idx] = struct{}{}
+			}
+		}
+		Expect(seen).To(HaveLen(10))
+
+		// the order is stable for the same source host
+		other := newSampler(config.SampleConsistentHash, 4)
+		Expect(other.sample("src", hosts)).To(Equal(rounds[0]))
+	})
+
+	It("selects all destination hosts if the sample size is not smaller", func() {
+		s := newSampler(config.SampleRandom, 5)
+		Expect(s.sample("src", destHosts(3))).To(Equal([]int{0, 1, 2}))
+	})
+
+	It("checks sampled destination hosts per round", func() {
+		var endpoints []config.Endpoint
+		for _, host := range destHosts(6) {
+			endpoints = append(endpoints, config.Endpoint{Hostname: host, IP: "127.0.0.1", Port: 1})
+		}
+		cfg := RunnerConfig{Job: config.Job{JobID: "sampled"}, Period: time.Second, SampleStrategy: config.SampleConsistentHash, SampleSize: 2}
+		r := NewCheckTCPPort(endpoints, cfg)
+		r.runFunc = func(_ config.Endpoint) (string, error) { return "ok", nil }
+
+		type sampleRound struct {
+			round     int
+			destHosts []string
+		}
+		var notified []sampleRound
+		SetSampleListener(func(jobID string, round int, destHosts []string) {
+			Expect(jobID).To(Equal("sampled"))
+			notified = append(notified, sampleRound{round: round, destHosts: destHosts})
+		})
+		defer SetSampleListener(nil)
+
+		ch := make(chan *nwpd.Observation, 6)
+		var checked []string
+		for i := 0; i < 6; i++ {
+			r.Run(context.Background(), ch)
+			obs := <-ch
+			checked = append(checked, obs.DestHost)
+			Expect(obs.Period.AsDuration()).To(Equal(6 * time.Second))
+		}
+		Expect(checked).To(ConsistOf(destHosts(6)))
+		Expect(notified).To(HaveLen(3))
+		for i, n := range notified {
+			Expect(n.round).To(Equal(i + 1))
+			Expect(n.destHosts).To(Equal(checked[2*i : 2*i+2]))
+		}
+	})
+})
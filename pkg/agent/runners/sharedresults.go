@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// sharedResultRetention is the maximum age of a shared connection result.
+const sharedResultRetention = 10 * time.Minute
+
+// connectionKey identifies the socket-level check of a destination.
+type connectionKey struct {
+	protocol string
+	ip       string
+	port     int
+	netns    string
+}
+
+// connectionOutcome is the result of a socket-level check. The fields are set before done is closed.
+type connectionOutcome struct {
+	jobID    string
+	result   string
+	err      error
+	duration time.Duration
+	at       time.Time
+	done     chan struct{}
+}
+
+// sharedResultError is the error of a check failed by a shared connection result.
+type sharedResultError struct {
+	err      error
+	sharedBy string
+}
+
+func (e *sharedResultError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sharedResultError) Unwrap() error {
+	return e.err
+}
+
+// connectionResults shares the results of socket-level checks between jobs with the option reuseConnectionResult,
+// so that the same destination is not checked multiple times per period by different jobs.
+type connectionResults struct {
+	lock    sync.Mutex
+	entries map[connectionKey]*connectionOutcome
+}
+
+var sharedConnectionResults = newConnectionResults()
+
+func newConnectionResults() *connectionResults {
+	return &connectionResults{entries: map[connectionKey]*connectionOutcome{}}
+}
+
+// run returns the result of another job for the key if it is not older than maxAge or still in progress.
+// Otherwise it runs the check and records its result. If reuseSuccess is false, only failures are reused,
+// e.g. if the caller needs the connection itself. sharedBy is the job ID of the reused result, empty if the check was run.
+func (c *connectionResults) run(key connectionKey, jobID string, maxAge time.Duration, reuseSuccess bool,
+	check func() (string, error)) (result string, duration time.Duration, sharedBy string, err error) {
+	c.lock.Lock()
+	if e := c.entries[key]; e != nil && e.jobID != jobID {
+		select {
+		case <-e.done:
+			if time.Since(e.at) <= maxAge && (reuseSuccess || e.err != nil) {
+				c.lock.Unlock()
+				return e.result, e.duration, e.jobID, e.err
+			}
+		default:
+			if reuseSuccess {
+				c.lock.Unlock()
+				<-e.done
+				return e.result, e.duration, e.jobID, e.err
+			}
+		}
+	}
+	e := &connectionOutcome{jobID: jobID, done: make(chan struct{})}
+	c.entries[key] = e
+	c.prune()
+	c.lock.Unlock()
+
+	start := time.Now()
+	e.result, e.err = check()
+	e.at = time.Now()
+	e.duration = e.at.Sub(start)
+	close(e.done)
+	return e.result, e.duration, "", e.err
+}
+
+// prune removes outdated entries. It must be called with the lock held.
+func (c *connectionResults) prune() {
+	for key, e := range c.entries {
+		select {
+		case <-e.done:
+			if time.Since(e.at) > sharedResultRetention {
+				delete(c.entries, key)
+			}
+		default:
+		}
+	}
+}
+
+// sharedDialContext returns a dial function for TCP connections reusing failed connection results of other jobs.
+// The outcome of its own dials is shared with other jobs. The host name is resolved to share the result by IP address.
+func sharedDialContext(jobID string, maxAge time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		portNumber, err := net.LookupPort(network, port)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("no addresses for %s", host)
+		}
+		var conn net.Conn
+		key := connectionKey{protocol: nwpd.ProtocolTCP, ip: ips[0], port: portNumber}
+		_, _, sharedBy, err := sharedConnectionResults.run(key, jobID, maxAge, false, func() (string, error) {
+			var err error
+			conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ips[0], port))
+			if err != nil {
+				return "", err
+			}
+			return "connected", nil
+		})
+		if sharedBy != "" {
+			return nil, &sharedResultError{err: err, sharedBy: sharedBy}
+		}
+		return conn, err
+	}
+}
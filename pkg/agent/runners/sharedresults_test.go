@@ -0,0 +1,165 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/atomic"
+)
+
+var _ = Describe("shared connection results", func() {
+	var accepted atomic.Int32
+
+	// countingListener accepts and closes connections and counts them
+	countingListener := func() (net.Listener, config.Endpoint) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				accepted.Inc()
+				conn.Close()
+			}
+		}()
+		return listener, config.Endpoint{Hostname: "local", IP: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port}
+	}
+	tcpJob := func(jobID string, reuse bool, period time.Duration, endpoint config.Endpoint) *checkTCPPort {
+		return NewCheckTCPPort([]config.Endpoint{endpoint}, RunnerConfig{Job: config.Job{JobID: jobID, ReuseConnectionResult: reuse}, Period: period})
+	}
+	run := func(r Runner) *nwpd.Observation {
+		ch := make(chan *nwpd.Observation, 1)
+		r.Run(context.Background(), ch)
+		return <-ch
+	}
+
+	BeforeEach(func() {
+		sharedConnectionResults = newConnectionResults()
+		accepted.Store(0)
+	})
+
+	It("reuses the TCP connection result of another job within the period", func() {
+		listener, endpoint := countingListener()
+		defer listener.Close()
+		jobA := tcpJob("tcp-a", true, time.Minute, endpoint)
+		jobB := tcpJob("tcp-b", true, time.Minute, endpoint)
+		jobC := tcpJob("tcp-c", false, time.Minute, endpoint)
+
+		obs := run(jobA)
+		Expect(obs.Ok).To(BeTrue())
+		Expect(obs.SharedResultOf).To(BeEmpty())
+		obs = run(jobB)
+		Expect(obs.Ok).To(BeTrue())
+		Expect(obs.SharedResultOf).To(Equal("tcp-a"))
+		Expect(obs.Result).To(Equal("connected"))
+		Eventually(accepted.Load).Should(Equal(int32(1)))
+
+		// jobs without the option always dial
+		obs = run(jobC)
+		Expect(obs.SharedResultOf).To(BeEmpty())
+		Eventually(accepted.Load).Should(Equal(int32(2)))
+
+		// a job never reuses its own result
+		Expect(run(jobA).SharedResultOf).To(BeEmpty())
+		Expect(run(jobB).SharedResultOf).To(Equal("tcp-a"))
+		Eventually(accepted.Load).Should(Equal(int32(3)))
+		Consistently(accepted.Load, 50*time.Millisecond).Should(Equal(int32(3)))
+	})
+
+	It("does not reuse results older than the period", func() {
+		listener, endpoint := countingListener()
+		defer listener.Close()
+		run(tcpJob("tcp-a", true, time.Millisecond, endpoint))
+		time.Sleep(5 * time.Millisecond)
+		Expect(run(tcpJob("tcp-b", true, time.Millisecond, endpoint)).SharedResultOf).To(BeEmpty())
+		Eventually(accepted.Load).Should(Equal(int32(2)))
+	})
+
+	It("does not share checks with multiple connections", func() {
+		listener, endpoint := countingListener()
+		defer listener.Close()
+		run(tcpJob("tcp-a", true, time.Minute, endpoint))
+		Expect(run(tcpJob("tcp-b", true, time.Minute, endpoint).withConnections(2)).SharedResultOf).To(BeEmpty())
+		Eventually(accepted.Load).Should(Equal(int32(3)))
+	})
+
+	It("waits for a check of another job in progress", func() {
+		results := newConnectionResults()
+		key := connectionKey{protocol: nwpd.ProtocolTCP, ip: "10.0.0.1", port: 443}
+		release := make(chan struct{})
+		var checks atomic.Int32
+		check := func() (string, error) {
+			checks.Inc()
+			<-release
+			return "connected", nil
+		}
+		done := make(chan string)
+		go func() {
+			_, _, sharedBy, _ := results.run(key, "tcp-a", time.Minute, true, check)
+			done <- sharedBy
+		}()
+		Eventually(checks.Load).Should(Equal(int32(1)))
+		go func() {
+			_, _, sharedBy, _ := results.run(key, "tcp-b", time.Minute, true, check)
+			done <- sharedBy
+		}()
+		close(release)
+		Expect([]string{<-done, <-done}).To(ConsistOf("", "tcp-a"))
+		Expect(checks.Load()).To(Equal(int32(1)))
+	})
+
+	It("shares TCP connections with HTTPS jobs but never the HTTP status", func() {
+		var connections atomic.Int32
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				connections.Inc()
+			}
+		}
+		server.StartTLS()
+		defer server.Close()
+		port := server.Listener.Addr().(*net.TCPAddr).Port
+		endpoint := config.Endpoint{Hostname: "127.0.0.1", IP: "127.0.0.1", Port: port}
+		httpsJob := NewCheckHTTPSGet([]config.Endpoint{endpoint}, false, RunnerConfig{Job: config.Job{JobID: "https", ReuseConnectionResult: true}, Period: time.Minute})
+
+		Expect(run(tcpJob("tcp-a", true, time.Minute, endpoint)).Ok).To(BeTrue())
+		obs := run(httpsJob)
+		Expect(obs.Ok).To(BeTrue())
+		Expect(obs.Result).To(Equal("204 No Content"))
+		Expect(obs.SharedResultOf).To(BeEmpty())
+		Eventually(connections.Load).Should(Equal(int32(2)))
+
+		// the dial of the HTTPS job is reused
+		Expect(run(tcpJob("tcp-b", true, time.Minute, endpoint)).SharedResultOf).To(Equal("https"))
+		Consistently(connections.Load, 50*time.Millisecond).Should(Equal(int32(2)))
+	})
+
+	It("fails HTTPS jobs on shared failed TCP connections", func() {
+		listener, endpoint := countingListener()
+		listener.Close()
+		endpoint.Hostname = endpoint.IP
+		obs := run(tcpJob("tcp-a", true, time.Minute, endpoint))
+		Expect(obs.Ok).To(BeFalse())
+
+		httpsJob := NewCheckHTTPSGet([]config.Endpoint{endpoint}, false, RunnerConfig{Job: config.Job{JobID: "https", ReuseConnectionResult: true}, Period: time.Minute})
+		obs = run(httpsJob)
+		Expect(obs.Ok).To(BeFalse())
+		Expect(obs.SharedResultOf).To(Equal("tcp-a"))
+		Expect(obs.Result).To(ContainSubstring("connection refused"))
+	})
+})
@@ -0,0 +1,173 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+// SimulationConfig contains the parameters for replacing the checks of the runners with synthetic ones.
+// It is used for load and scale testing of the agent without a real cluster.
+type SimulationConfig struct {
+	// Nodes is the number of simulated nodes (and agent pods on the pod network).
+	Nodes int
+	// FailRate is the probability of a failed check.
+	FailRate float64
+	// Latency is the mean duration of a check.
+	Latency time.Duration
+	// LatencyJitter is the maximum deviation from the mean duration.
+	LatencyJitter time.Duration
+}
+
+// ParseSimulationConfig parses a simulation specification like `nodes=1000,failRate=0.02,latency=5ms±3ms`.
+func ParseSimulationConfig(spec string) (*SimulationConfig, error) {
+	cfg := &SimulationConfig{
+		Nodes:   10,
+		Latency: 1 * time.Millisecond,
+	}
+	for _, part := range strings.Split(spec, ",") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid simulation parameter %q: expected <key>=<value>", part)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		var err error
+		switch key {
+		case "nodes":
+			cfg.Nodes, err = strconv.Atoi(value)
+			if err == nil && cfg.Nodes < 1 {
+				err = fmt.Errorf("must be >= 1")
+			}
+		case "failRate":
+			cfg.FailRate, err = strconv.ParseFloat(value, 64)
+			if err == nil && (cfg.FailRate < 0 || cfg.FailRate > 1) {
+				err = fmt.Errorf("must be in range [0,1]")
+			}
+		case "latency":
+			cfg.Latency, cfg.LatencyJitter, err = parseLatency(value)
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid simulation parameter %q: %s", part, err)
+		}
+	}
+	return cfg, nil
+}
+
+func parseLatency(value string) (time.Duration, time.Duration, error) {
+	parts := strings.SplitN(strings.ReplaceAll(value, "+-", "±"), "±", 2)
+	latency, err := time.ParseDuration(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	var jitter time.Duration
+	if len(parts) == 2 {
+		jitter, err = time.ParseDuration(parts[1])
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if latency < 0 || jitter < 0 {
+		return 0, 0, fmt.Errorf("must not be negative")
+	}
+	return latency, jitter, nil
+}
+
+// ClusterConfig creates a synthetic cluster configuration with the simulated nodes and agent pods.
+func (c *SimulationConfig) ClusterConfig() *config.ClusterConfig {
+	clusterConfig := &config.ClusterConfig{
+		InternalKubeAPIServer: &config.Endpoint{
+			Hostname: common.DomainNameKubernetesService,
+			IP:       "100.64.0.1",
+			Port:     443,
+		},
+		KubeAPIServer: &config.Endpoint{
+			Hostname: "api.simulated.local",
+			IP:       "192.0.2.1",
+			Port:     443,
+		},
+		KubeDNS: &config.Endpoint{
+			Hostname: common.DomainNameKubeDNSService,
+			IP:       "100.64.0.10",
+			Port:     53,
+		},
+		NodeLocalDNS: &config.Endpoint{
+			Hostname: common.NameNodeLocalDNS,
+			IP:       common.NodeLocalDNSIP,
+			Port:     53,
+		},
+	}
+	for i := 0; i < c.Nodes; i++ {
+		nodename := fmt.Sprintf("node-%05d", i)
+		clusterConfig.Nodes = append(clusterConfig.Nodes, config.Node{
+			Hostname:   nodename,
+			InternalIP: fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff),
+		})
+		clusterConfig.PodEndpoints = append(clusterConfig.PodEndpoints, config.PodEndpoint{
+			Nodename: nodename,
+			Podname:  fmt.Sprintf("%s-%05d", common.NameDaemonSetAgentPodNet, i),
+			PodIP:    fmt.Sprintf("100.%d.%d.%d", 96+i>>16&0x1f, i>>8&0xff, i&0xff),
+			Port:     common.PodNetPodGRPCPort,
+		})
+	}
+	return clusterConfig
+}
+
+type simulatedDest string
+
+func (d simulatedDest) DestHost() string {
+	return string(d)
+}
+
+type simulatedRunner struct {
+	robinRound[simulatedDest]
+}
+
+var _ Runner = &simulatedRunner{}
+
+// NewSimulatedRunner creates a runner with the same configuration and destinations as the given runner,
+// but performing synthetic checks according to the simulation configuration.
+func NewSimulatedRunner(runner Runner, sim *SimulationConfig) Runner {
+	var dests []simulatedDest
+	for _, host := range runner.DestHosts() {
+		dests = append(dests, simulatedDest(host))
+	}
+	if len(dests) == 0 {
+		return nil
+	}
+	return &simulatedRunner{
+		robinRound[simulatedDest]{
+			itemsName: "simulated destinations",
+			items:     dests,
+			runFunc:   sim.runFunc,
+			config:    runner.Config(),
+		},
+	}
+}
+
+func (c *SimulationConfig) runFunc(_ simulatedDest) (string, error) {
+	latency := c.Latency
+	if c.LatencyJitter > 0 {
+		latency += time.Duration(rand.Int63n(int64(2*c.LatencyJitter)+1)) - c.LatencyJitter
+	}
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if rand.Float64() < c.FailRate {
+		return "", fmt.Errorf("simulated failure")
+	}
+	return "simulated", nil
+}
@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("simulation", func() {
+	DescribeTable("parse simulation config",
+		func(spec string, expected *SimulationConfig, expectedErr bool) {
+			actual, err := ParseSimulationConfig(spec)
+			if expectedErr {
+				Expect(err).NotTo(BeNil())
+				return
+			}
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal(expected))
+		},
+		Entry("defaults", "", &SimulationConfig{Nodes: 10, Latency: 1 * time.Millisecond}, false),
+		Entry("all parameters", "nodes=1000,failRate=0.02,latency=5ms±3ms",
+			&SimulationConfig{Nodes: 1000, FailRate: 0.02, Latency: 5 * time.Millisecond, LatencyJitter: 3 * time.Millisecond}, false),
+		Entry("ascii jitter", "latency=5ms+-3ms",
+			&SimulationConfig{Nodes: 10, Latency: 5 * time.Millisecond, LatencyJitter: 3 * time.Millisecond}, false),
+		Entry("invalid nodes", "nodes=0", nil, true),
+		Entry("invalid fail rate", "failRate=1.5", nil, true),
+		Entry("invalid latency", "latency=5", nil, true),
+		Entry("unknown key", "foo=bar", nil, true),
+		Entry("missing value", "nodes", nil, true),
+	)
+
+	It("generates cluster config", func() {
+		sim := &SimulationConfig{Nodes: 300}
+		clusterConfig := sim.ClusterConfig()
+		Expect(clusterConfig.Nodes).To(HaveLen(300))
+		Expect(clusterConfig.PodEndpoints).To(HaveLen(300))
+		Expect(clusterConfig.Nodes[299].InternalIP).To(Equal("10.0.1.43"))
+		Expect(clusterConfig.PodEndpoints[299].PodIP).To(Equal("100.96.1.43"))
+	})
+})
@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package runners
+
+import (
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+// DefaultWarmUpPeriod is the default period after the start of a destination pod, during which its observations are
+// recorded as warming up.
+const DefaultWarmUpPeriod = 90 * time.Second
+
+// warmUp marks the observations of destinations whose pods have been started recently, e.g. during a rollout of the
+// agent daemon set, as the pods may not listen yet.
+type warmUp struct {
+	period time.Duration
+	// startTimes maps the destination hosts to the start times of their pods
+	startTimes map[string]time.Time
+}
+
+// newPodWarmUp returns the warm-up of the pod endpoints, nil if the period is disabled or no start times are known.
+func newPodWarmUp(pods []config.PodEndpoint, period time.Duration) *warmUp {
+	if period <= 0 {
+		return nil
+	}
+	startTimes := map[string]time.Time{}
+	for _, pod := range pods {
+		if pod.StartTime != nil {
+			startTimes[normalise(pod.Nodename)] = pod.StartTime.Time
+		}
+	}
+	if len(startTimes) == 0 {
+		return nil
+	}
+	return &warmUp{period: period, startTimes: startTimes}
+}
+
+// isWarmingUp returns true if the pod of the destination host has been started within the warm-up period.
+func (w *warmUp) isWarmingUp(destHost string, now time.Time) bool {
+	if w == nil {
+		return false
+	}
+	start, ok := w.startTimes[destHost]
+	return ok && now.Sub(start) < w.period
+}
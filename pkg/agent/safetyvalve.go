@@ -0,0 +1,237 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/aggregation/types"
+	"github.com/gardener/network-problem-detector/pkg/agent/cgroup"
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// obsCounts counts the observations of a job.
+type obsCounts struct {
+	total  int
+	failed int
+}
+
+// safetyValve suspends expensive jobs while the agent is overloaded, so that a check does not become the problem it should detect.
+// The load is measured by the scheduler lag of the jobs and the CPU throttling of the agent container. It is evaluated per window
+// (the period of the watchdog), the cost of a job is estimated from its busy time, cost weight and error rate in the window.
+type safetyValve struct {
+	lock sync.Mutex
+	cfg  *config.SafetyValveConfig
+
+	windowStart time.Time
+	maxLag      map[jobid]time.Duration
+	counts      map[jobid]*obsCounts
+	busyTime    map[jobid]time.Duration
+	cpu         *cgroup.Stats
+}
+
+// suspension is a job suspended by the safety valve.
+type suspension struct {
+	job    *runners.InternalJob
+	until  time.Time
+	cost   float64
+	reason string
+}
+
+func newSafetyValve() *safetyValve {
+	return &safetyValve{
+		maxLag:   map[jobid]time.Duration{},
+		counts:   map[jobid]*obsCounts{},
+		busyTime: map[jobid]time.Duration{},
+	}
+}
+
+// setConfig enables the safety valve with the config or disables it if nil.
+func (v *safetyValve) setConfig(cfg *config.SafetyValveConfig) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.cfg = cfg
+}
+
+// observeLag records the scheduler lag of a started run.
+func (v *safetyValve) observeLag(id jobid, lag time.Duration) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if v.cfg != nil && lag > v.maxLag[id] {
+		v.maxLag[id] = lag
+	}
+}
+
+// observe counts the observation for the error rate of its job. Observations within the warm-up period of the
+// destination are not counted, as their failures are expected.
+func (v *safetyValve) observe(obs *nwpd.Observation) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if v.cfg == nil || obs.JobID == config.SafetyValveJobID || obs.WarmingUp {
+		return
+	}
+	c := v.counts[obs.JobID]
+	if c == nil {
+		c = &obsCounts{}
+		v.counts[obs.JobID] = c
+	}
+	c.total++
+	if !obs.Ok {
+		c.failed++
+	}
+}
+
+// evaluate closes the current window. It returns the jobs to resume, as their suspension has expired or the safety valve
+// is disabled, and the job to suspend if the agent has been overloaded in the window. cpu are the current cgroup statistics
+// of the agent container (nil if not available).
+func (v *safetyValve) evaluate(jobs []*runners.InternalJob, now time.Time, cpu *cgroup.Stats) (resume []*runners.InternalJob, suspend *suspension) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	for _, job := range jobs {
+		if until := job.SuspendedUntil(); until != nil && (v.cfg == nil || !now.Before(*until)) {
+			resume = append(resume, job)
+		}
+	}
+	if v.cfg == nil {
+		v.reset(now, nil, nil)
+		return
+	}
+
+	window := now.Sub(v.windowStart)
+	var reasons []string
+	if !v.windowStart.IsZero() && window > 0 {
+		reasons = v.overloadReasons(cpu)
+	}
+	if len(reasons) > 0 {
+		var worst *runners.InternalJob
+		worstCost := 0.0
+		for _, job := range jobs {
+			if job.SuspendedUntil() != nil {
+				continue
+			}
+			if cost := v.cost(job, now, window); cost > worstCost {
+				worst, worstCost = job, cost
+			}
+		}
+		if worst != nil && worstCost >= v.cfg.MinCostOrDefault() {
+			suspend = &suspension{
+				job:    worst,
+				until:  now.Add(v.cfg.CooldownOrDefault()),
+				cost:   worstCost,
+				reason: strings.Join(reasons, ", "),
+			}
+		}
+	}
+	v.reset(now, jobs, cpu)
+	return
+}
+
+// overloadReasons returns the reasons why the agent has been overloaded in the current window.
+func (v *safetyValve) overloadReasons(cpu *cgroup.Stats) []string {
+	var reasons []string
+	var lagging []string
+	for id, lag := range v.maxLag {
+		if lag > v.cfg.MaxSchedulerLagOrDefault() {
+			lagging = append(lagging, fmt.Sprintf("%s: %s", id, lag.Round(time.Millisecond)))
+		}
+	}
+	if len(lagging) > 0 {
+		sort.Strings(lagging)
+		reasons = append(reasons, fmt.Sprintf("scheduler lag (%s)", strings.Join(lagging, ", ")))
+	}
+	if cpu != nil && v.cpu != nil && cpu.CPUPeriods > v.cpu.CPUPeriods && cpu.CPUThrottledPeriods >= v.cpu.CPUThrottledPeriods {
+		ratio := float64(cpu.CPUThrottledPeriods-v.cpu.CPUThrottledPeriods) / float64(cpu.CPUPeriods-v.cpu.CPUPeriods)
+		if ratio > v.cfg.MaxThrottledRatioOrDefault() {
+			reasons = append(reasons, fmt.Sprintf("CPU throttled in %.0f%% of periods", 100*ratio))
+		}
+	}
+	return reasons
+}
+
+// cost estimates the cost of the job in the current window: the share of the window it has been running checks,
+// multiplied by its cost weight and increased by its error rate, as failing checks of an overloaded agent hint at self-inflicted load.
+func (v *safetyValve) cost(job *runners.InternalJob, now time.Time, window time.Duration) float64 {
+	busy := job.BusyTime(now)
+	if last, ok := v.busyTime[job.JobID()]; ok && busy >= last {
+		// a replaced job starts with zero busy time
+		busy -= last
+	}
+	cost := float64(busy) / float64(window) * job.CostWeight()
+	if c := v.counts[job.JobID()]; c != nil && c.total > 0 {
+		cost *= 1 + float64(c.failed)/float64(c.total)
+	}
+	return cost
+}
+
+// reset starts a new window.
+func (v *safetyValve) reset(now time.Time, jobs []*runners.InternalJob, cpu *cgroup.Stats) {
+	v.windowStart = now
+	v.maxLag = map[jobid]time.Duration{}
+	v.counts = map[jobid]*obsCounts{}
+	v.busyTime = map[jobid]time.Duration{}
+	for _, job := range jobs {
+		v.busyTime[job.JobID()] = job.BusyTime(now)
+	}
+	v.cpu = cpu
+}
+
+// checkSafetyValve evaluates the safety valve and suspends or resumes the jobs. Changes are reported by log, events, metrics
+// and observations of the job SafetyValveJobID. It must be called from the run loop, as the observations are handled directly.
+func (s *server) checkSafetyValve() {
+	s.lock.Lock()
+	jobs := make([]*runners.InternalJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	s.lock.Unlock()
+
+	now := time.Now()
+	resume, suspend := s.safetyValve.evaluate(jobs, now, SelfMetrics.stats())
+	for _, job := range resume {
+		job.SetSuspendedUntil(nil)
+		// the watchdog measures the time without completed run from the end of the suspension
+		job.SetLastCompleted(&now)
+		ReportJobSuspended(job.JobID(), false)
+		msg := fmt.Sprintf("job %s of agent %s resumed after suspension by safety valve", job.JobID(), runners.GetNodeName())
+		s.log.Info(msg)
+		if s.aggregator != nil {
+			s.aggregator.ReportEvent(types.Info, "JobResumed", msg)
+		}
+		s.handleObservation(safetyValveObservation(job.JobID(), now, true, "resumed"))
+	}
+	if suspend != nil {
+		suspend.job.SetSuspendedUntil(&suspend.until)
+		ReportJobSuspended(suspend.job.JobID(), true)
+		msg := fmt.Sprintf("job %s of agent %s suspended by safety valve until %s: agent overloaded by %s, cost estimate %.2f",
+			suspend.job.JobID(), runners.GetNodeName(), suspend.until.UTC().Format(time.RFC3339), suspend.reason, suspend.cost)
+		s.log.Warn(msg)
+		if s.aggregator != nil {
+			s.aggregator.ReportEvent(types.Warn, "JobSuspended", msg)
+		}
+		s.handleObservation(safetyValveObservation(suspend.job.JobID(), now, false,
+			fmt.Sprintf("suspended until %s: %s, cost estimate %.2f", suspend.until.UTC().Format(time.RFC3339), suspend.reason, suspend.cost)))
+	}
+}
+
+// safetyValveObservation creates the observation of a suspension (not ok) or resumption (ok) of the job.
+func safetyValveObservation(id jobid, now time.Time, ok bool, result string) *nwpd.Observation {
+	return &nwpd.Observation{
+		SrcHost:   runners.GetNodeName(),
+		DestHost:  runners.GetNodeName(),
+		Timestamp: timestamppb.New(now),
+		JobID:     config.SafetyValveJobID,
+		Ok:        ok,
+		Result:    fmt.Sprintf("job %s %s", id, result),
+	}
+}
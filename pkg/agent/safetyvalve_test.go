@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/cgroup"
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func newSimulatedJob(t *testing.T, id string, latency time.Duration) *runners.InternalJob {
+	endpoints := []config.Endpoint{{Hostname: "node1", IP: "10.0.0.11", Port: 1011}}
+	rconfig := runners.RunnerConfig{Job: config.Job{JobID: id}, Period: 1 * time.Millisecond}
+	runner := runners.NewSimulatedRunner(runners.NewCheckTCPPort(endpoints, rconfig), &runners.SimulationConfig{Latency: latency})
+	if !assert.NotNil(t, runner) {
+		t.FailNow()
+	}
+	return runners.NewInternalJob(context.Background(), runner)
+}
+
+// runOnce runs the job once and waits for its end.
+func runOnce(t *testing.T, job *runners.InternalJob) {
+	ch := make(chan *nwpd.Observation, 10)
+	_, status := job.Tick(ch)
+	assert.Equal(t, runners.TickStarted, status)
+	assert.Eventually(t, func() bool { return !job.IsActive() }, time.Second, time.Millisecond)
+}
+
+func TestSafetyValve(t *testing.T) {
+	cheap := newSimulatedJob(t, "cheap", 2*time.Millisecond)
+	expensive := newSimulatedJob(t, "expensive", 40*time.Millisecond)
+	jobs := []*runners.InternalJob{cheap, expensive}
+
+	valve := newSafetyValve()
+	cooldown := 2 * time.Minute
+	valve.setConfig(&config.SafetyValveConfig{MinCost: 0.1, Cooldown: &metav1.Duration{Duration: cooldown}})
+
+	start := time.Now()
+	resume, suspend := valve.evaluate(jobs, start, nil)
+	assert.Empty(t, resume)
+	assert.Nil(t, suspend, "first window")
+
+	runOnce(t, cheap)
+	runOnce(t, expensive)
+	valve.observeLag("cheap", 1*time.Second)
+	now := start.Add(100 * time.Millisecond)
+	_, suspend = valve.evaluate(jobs, now, nil)
+	assert.Nil(t, suspend, "not overloaded")
+
+	runOnce(t, cheap)
+	runOnce(t, expensive)
+	valve.observeLag("cheap", 10*time.Second)
+	valve.observe(&nwpd.Observation{JobID: "expensive", Ok: false})
+	now = now.Add(100 * time.Millisecond)
+	_, suspend = valve.evaluate(jobs, now, nil)
+	if assert.NotNil(t, suspend, "overloaded by scheduler lag") {
+		assert.Equal(t, expensive, suspend.job)
+		assert.Equal(t, now.Add(cooldown), suspend.until)
+		assert.Contains(t, suspend.reason, "scheduler lag (cheap: 10s)")
+		// 40ms busy in a window of 100ms, doubled by the error rate
+		assert.GreaterOrEqual(t, suspend.cost, 0.8)
+		expensive.SetSuspendedUntil(&suspend.until)
+	}
+
+	// CPU throttling in 60% of the periods
+	resume, suspend = valve.evaluate(jobs, now.Add(10*time.Second), &cgroup.Stats{CPUPeriods: 100, CPUThrottledPeriods: 10})
+	assert.Empty(t, resume)
+	assert.Nil(t, suspend)
+	runOnce(t, cheap)
+	resume, suspend = valve.evaluate(jobs, now.Add(10*time.Second+10*time.Millisecond), &cgroup.Stats{CPUPeriods: 200, CPUThrottledPeriods: 70})
+	assert.Empty(t, resume)
+	if assert.NotNil(t, suspend, "overloaded by CPU throttling") {
+		assert.Equal(t, cheap, suspend.job, "suspended jobs are skipped")
+		assert.Contains(t, suspend.reason, "CPU throttled in 60% of periods")
+	}
+
+	resume, _ = valve.evaluate(jobs, now.Add(cooldown), nil)
+	assert.Equal(t, []*runners.InternalJob{expensive}, resume, "resumed after cooldown")
+
+	valve.setConfig(nil)
+	resume, suspend = valve.evaluate(jobs, now.Add(time.Second), nil)
+	assert.Equal(t, []*runners.InternalJob{expensive}, resume, "resumed if disabled")
+	assert.Nil(t, suspend)
+}
@@ -0,0 +1,227 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/cgroup"
+)
+
+const (
+	// memoryLimitSourceEnv means the memory limit is set by the environment variable GOMEMLIMIT.
+	memoryLimitSourceEnv = "env"
+	// memoryLimitSourceCgroup means the memory limit is derived from the memory limit of the container.
+	memoryLimitSourceCgroup = "cgroup"
+	// memoryLimitSourceNone means there is no memory limit.
+	memoryLimitSourceNone = "none"
+	// memoryLimitSourceUnsupported means the Go version does not support a memory limit.
+	memoryLimitSourceUnsupported = "unsupported"
+)
+
+func init() {
+	prometheus.MustRegister(SelfMetrics)
+}
+
+// SelfMetrics exports the resource usage of the agent container read from its cgroup and of the Go runtime.
+var SelfMetrics = &selfCollector{
+	cpuUsageDesc: prometheus.NewDesc("nwpd_self_cpu_usage_seconds_total",
+		"Total CPU time consumed by the agent container", nil, nil),
+	cpuPeriodsDesc: prometheus.NewDesc("nwpd_self_cpu_periods_total",
+		"Number of elapsed enforcement periods of the CPU limit of the agent container", nil, nil),
+	cpuThrottledPeriodsDesc: prometheus.NewDesc("nwpd_self_cpu_throttled_periods_total",
+		"Number of enforcement periods in which the agent container was throttled", nil, nil),
+	cpuThrottledSecondsDesc: prometheus.NewDesc("nwpd_self_cpu_throttled_seconds_total",
+		"Total time the agent container was throttled", nil, nil),
+	cpuLimitDesc: prometheus.NewDesc("nwpd_self_cpu_limit_cores",
+		"CPU limit of the agent container in cores (only if limited)", nil, nil),
+	memoryWorkingSetDesc: prometheus.NewDesc("nwpd_self_memory_working_set_bytes",
+		"Memory working set of the agent container (usage without inactive file pages)", nil, nil),
+	memoryLimitDesc: prometheus.NewDesc("nwpd_self_memory_limit_bytes",
+		"Memory limit of the agent container (only if limited)", nil, nil),
+	goMemoryDesc: prometheus.NewDesc("nwpd_self_go_memory_bytes",
+		"Memory obtained from the operating system by the Go runtime", nil, nil),
+	gcPauseDesc: prometheus.NewDesc("nwpd_self_gc_pause_seconds_total",
+		"Total stop-the-world pause time of the garbage collector", nil, nil),
+	gcCyclesDesc: prometheus.NewDesc("nwpd_self_gc_cycles_total",
+		"Number of completed garbage collection cycles", nil, nil),
+	goMemLimitDesc: prometheus.NewDesc("nwpd_self_gomemlimit_info",
+		"Effective soft memory limit of the Go runtime in bytes (label limit) and its source (env, cgroup, none or unsupported)",
+		[]string{"limit", "source"}, nil),
+	goMaxProcsDesc: prometheus.NewDesc("nwpd_self_gomaxprocs_info",
+		"Effective GOMAXPROCS of the Go runtime (label procs) and its source (env, flag, cgroup or default)",
+		[]string{"procs", "source"}, nil),
+	gcPercentDesc: prometheus.NewDesc("nwpd_self_gogc_info",
+		"Effective garbage collection target percentage GOGC of the Go runtime (label percent) and its source (env, flag or default)",
+		[]string{"percent", "source"}, nil),
+}
+
+// selfCollector reads the cgroup statistics on each scrape.
+type selfCollector struct {
+	lock              sync.Mutex
+	reader            *cgroup.Reader
+	memoryLimit       int64
+	memoryLimitSource string
+	goMaxProcs        int
+	goMaxProcsSource  string
+	gcPercent         int
+	gcPercentSource   string
+
+	cpuUsageDesc            *prometheus.Desc
+	cpuPeriodsDesc          *prometheus.Desc
+	cpuThrottledPeriodsDesc *prometheus.Desc
+	cpuThrottledSecondsDesc *prometheus.Desc
+	cpuLimitDesc            *prometheus.Desc
+	memoryWorkingSetDesc    *prometheus.Desc
+	memoryLimitDesc         *prometheus.Desc
+	goMemoryDesc            *prometheus.Desc
+	gcPauseDesc             *prometheus.Desc
+	gcCyclesDesc            *prometheus.Desc
+	goMemLimitDesc          *prometheus.Desc
+	goMaxProcsDesc          *prometheus.Desc
+	gcPercentDesc           *prometheus.Desc
+}
+
+var _ prometheus.Collector = &selfCollector{}
+
+func (c *selfCollector) setup(reader *cgroup.Reader, memoryLimit int64, memoryLimitSource string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.reader = reader
+	c.memoryLimit = memoryLimit
+	c.memoryLimitSource = memoryLimitSource
+}
+
+func (c *selfCollector) setupRuntime(goMaxProcs int, goMaxProcsSource string, gcPercent int, gcPercentSource string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.goMaxProcs = goMaxProcs
+	c.goMaxProcsSource = goMaxProcsSource
+	c.gcPercent = gcPercent
+	c.gcPercentSource = gcPercentSource
+}
+
+// stats reads the cgroup statistics of the agent container. It returns nil if they are not available.
+func (c *selfCollector) stats() *cgroup.Stats {
+	c.lock.Lock()
+	reader := c.reader
+	c.lock.Unlock()
+	if reader == nil {
+		return nil
+	}
+	stats, err := reader.Read()
+	if err != nil {
+		return nil
+	}
+	return stats
+}
+
+func (c *selfCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cpuUsageDesc
+	ch <- c.cpuPeriodsDesc
+	ch <- c.cpuThrottledPeriodsDesc
+	ch <- c.cpuThrottledSecondsDesc
+	ch <- c.cpuLimitDesc
+	ch <- c.memoryWorkingSetDesc
+	ch <- c.memoryLimitDesc
+	ch <- c.goMemoryDesc
+	ch <- c.gcPauseDesc
+	ch <- c.gcCyclesDesc
+	ch <- c.goMemLimitDesc
+	ch <- c.goMaxProcsDesc
+	ch <- c.gcPercentDesc
+}
+
+func (c *selfCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.Lock()
+	reader := c.reader
+	memoryLimit := c.memoryLimit
+	memoryLimitSource := c.memoryLimitSource
+	goMaxProcs, goMaxProcsSource := c.goMaxProcs, c.goMaxProcsSource
+	gcPercent, gcPercentSource := c.gcPercent, c.gcPercentSource
+	c.lock.Unlock()
+
+	if reader != nil {
+		if stats, err := reader.Read(); err == nil {
+			ch <- prometheus.MustNewConstMetric(c.cpuUsageDesc, prometheus.CounterValue, stats.CPUUsageSeconds)
+			ch <- prometheus.MustNewConstMetric(c.cpuPeriodsDesc, prometheus.CounterValue, float64(stats.CPUPeriods))
+			ch <- prometheus.MustNewConstMetric(c.cpuThrottledPeriodsDesc, prometheus.CounterValue, float64(stats.CPUThrottledPeriods))
+			ch <- prometheus.MustNewConstMetric(c.cpuThrottledSecondsDesc, prometheus.CounterValue, stats.CPUThrottledSeconds)
+			if stats.CPULimitCores > 0 {
+				ch <- prometheus.MustNewConstMetric(c.cpuLimitDesc, prometheus.GaugeValue, stats.CPULimitCores)
+			}
+			ch <- prometheus.MustNewConstMetric(c.memoryWorkingSetDesc, prometheus.GaugeValue, float64(stats.MemoryWorkingSetBytes))
+			if stats.MemoryLimitBytes > 0 {
+				ch <- prometheus.MustNewConstMetric(c.memoryLimitDesc, prometheus.GaugeValue, float64(stats.MemoryLimitBytes))
+			}
+		}
+	}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	ch <- prometheus.MustNewConstMetric(c.gcPauseDesc, prometheus.CounterValue, float64(memStats.PauseTotalNs)/1e9)
+	ch <- prometheus.MustNewConstMetric(c.gcCyclesDesc, prometheus.CounterValue, float64(memStats.NumGC))
+	ch <- prometheus.MustNewConstMetric(c.goMemoryDesc, prometheus.GaugeValue, float64(memStats.Sys))
+
+	if memoryLimitSource != "" {
+		ch <- prometheus.MustNewConstMetric(c.goMemLimitDesc, prometheus.GaugeValue, 1,
+			strconv.FormatInt(memoryLimit, 10), memoryLimitSource)
+	}
+	if goMaxProcsSource != "" {
+		ch <- prometheus.MustNewConstMetric(c.goMaxProcsDesc, prometheus.GaugeValue, 1, strconv.Itoa(goMaxProcs), goMaxProcsSource)
+	}
+	if gcPercentSource != "" {
+		ch <- prometheus.MustNewConstMetric(c.gcPercentDesc, prometheus.GaugeValue, 1, strconv.Itoa(gcPercent), gcPercentSource)
+	}
+}
+
+// setupSelfMetrics enables the cgroup metrics and sets the soft memory limit of the Go runtime to the given ratio
+// of the memory limit of the container, unless it is set by the environment variable GOMEMLIMIT or the ratio is 0.
+// GOMAXPROCS and GOGC are set as described for applyGOMAXPROCS and applyGCPercent.
+func setupSelfMetrics(log logrus.FieldLogger, cgroupRoot string, memoryLimitRatio float64, goMaxProcs, gcPercent int) {
+	reader, err := cgroup.NewReader(cgroupRoot)
+	if err != nil {
+		log.Warnf("self metrics of the container not available: %s", err)
+		reader = nil
+	}
+	limit, source := applyMemoryLimit(reader, memoryLimitRatio, os.Getenv("GOMEMLIMIT") != "")
+	if source == memoryLimitSourceNone {
+		log.Infof("no memory limit for the Go runtime")
+	} else {
+		log.Infof("memory limit for the Go runtime: %d bytes (source: %s)", limit, source)
+	}
+	SelfMetrics.setup(reader, limit, source)
+
+	procs, procsSource := applyGOMAXPROCS(reader, goMaxProcs, os.Getenv("GOMAXPROCS") != "")
+	percent, percentSource := applyGCPercent(gcPercent, os.Getenv("GOGC") != "")
+	log.Infof("GOMAXPROCS: %d (source: %s), GOGC: %d (source: %s)", procs, procsSource, percent, percentSource)
+	SelfMetrics.setupRuntime(procs, procsSource, percent, percentSource)
+}
+
+// applyMemoryLimit returns the effective soft memory limit of the Go runtime and its source after setting it if needed.
+func applyMemoryLimit(reader *cgroup.Reader, ratio float64, fromEnv bool) (int64, string) {
+	if !memoryLimitSupported {
+		return math.MaxInt64, memoryLimitSourceUnsupported
+	}
+	if fromEnv {
+		// the Go runtime has already applied the environment variable
+		return setMemoryLimit(-1), memoryLimitSourceEnv
+	}
+	if reader != nil && ratio > 0 {
+		if containerLimit, err := reader.MemoryLimit(); err == nil && containerLimit > 0 {
+			limit := int64(float64(containerLimit) * ratio)
+			setMemoryLimit(limit)
+			return limit, memoryLimitSourceCgroup
+		}
+	}
+	return setMemoryLimit(-1), memoryLimitSourceNone
+}
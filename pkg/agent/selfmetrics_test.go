@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/cgroup"
+)
+
+func TestApplyMemoryLimit(t *testing.T) {
+	if !memoryLimitSupported {
+		t.Skip("memory limit not supported")
+	}
+	previous := setMemoryLimit(-1)
+	defer setMemoryLimit(previous)
+
+	root := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("memory\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "memory.max"), []byte("67108864\n"), 0644))
+	reader, err := cgroup.NewReader(root)
+	assert.NoError(t, err)
+
+	limit, source := applyMemoryLimit(reader, 0.9, false)
+	assert.Equal(t, memoryLimitSourceCgroup, source)
+	assert.Equal(t, int64(60397977), limit)
+	assert.Equal(t, limit, setMemoryLimit(-1))
+
+	setMemoryLimit(previous)
+	limit, source = applyMemoryLimit(reader, 0.9, true)
+	assert.Equal(t, memoryLimitSourceEnv, source)
+	assert.Equal(t, previous, limit)
+
+	limit, source = applyMemoryLimit(reader, 0, false)
+	assert.Equal(t, memoryLimitSourceNone, source)
+	assert.Equal(t, previous, limit)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "memory.max"), []byte("max\n"), 0644))
+	_, source = applyMemoryLimit(reader, 0.9, false)
+	assert.Equal(t, memoryLimitSourceNone, source)
+}
+
+func TestApplyGOMAXPROCS(t *testing.T) {
+	previous := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(previous)
+
+	root := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "cpu.max"), []byte("5000 100000\n"), 0644))
+	reader, err := cgroup.NewReader(root)
+	assert.NoError(t, err)
+
+	procs, source := applyGOMAXPROCS(reader, 0, false)
+	assert.Equal(t, runtimeSettingSourceCgroup, source)
+	assert.Equal(t, 1, procs)
+	assert.Equal(t, 1, runtime.GOMAXPROCS(0))
+
+	procs, source = applyGOMAXPROCS(reader, 3, false)
+	assert.Equal(t, runtimeSettingSourceFlag, source)
+	assert.Equal(t, 3, procs)
+	assert.Equal(t, 3, runtime.GOMAXPROCS(0))
+
+	procs, source = applyGOMAXPROCS(reader, 0, true)
+	assert.Equal(t, runtimeSettingSourceEnv, source)
+	assert.Equal(t, 3, procs)
+
+	procs, source = applyGOMAXPROCS(reader, -1, false)
+	assert.Equal(t, runtimeSettingSourceDefault, source)
+	assert.Equal(t, 3, procs)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "cpu.max"), []byte("max 100000\n"), 0644))
+	_, source = applyGOMAXPROCS(reader, 0, false)
+	assert.Equal(t, runtimeSettingSourceDefault, source)
+}
+
+func TestApplyGCPercent(t *testing.T) {
+	previous := debug.SetGCPercent(defaultGCPercent)
+	defer debug.SetGCPercent(previous)
+
+	percent, source := applyGCPercent(0, false)
+	assert.Equal(t, runtimeSettingSourceDefault, source)
+	assert.Equal(t, defaultGCPercent, percent)
+
+	percent, source = applyGCPercent(200, false)
+	assert.Equal(t, runtimeSettingSourceFlag, source)
+	assert.Equal(t, 200, percent)
+
+	percent, source = applyGCPercent(50, true)
+	assert.Equal(t, runtimeSettingSourceEnv, source)
+	assert.Equal(t, 200, percent, "value of the runtime is kept")
+	assert.Equal(t, 200, debug.SetGCPercent(200))
+}
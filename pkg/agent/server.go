@@ -9,11 +9,13 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -22,19 +24,27 @@ import (
 	"github.com/gardener/network-problem-detector/pkg/agent/aggregation"
 	"github.com/gardener/network-problem-detector/pkg/agent/db"
 	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/agent/version"
 	"github.com/gardener/network-problem-detector/pkg/common"
 	"github.com/gardener/network-problem-detector/pkg/common/config"
 	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 	"go.uber.org/atomic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type jobid = string
 
+// drainTimeout is the maximum time to wait for active jobs at the end of a one-shot run
+const drainTimeout = 30 * time.Second
+
+// jobStopTimeout is the maximum time to wait for the running checks of removed or replaced jobs
+const jobStopTimeout = 10 * time.Second
+
 type server struct {
 	lock                 sync.Mutex
 	reloadLock           sync.Mutex
@@ -42,30 +52,66 @@ type server struct {
 	agentConfigFile      string
 	clusterConfigFile    string
 	hostNetwork          bool
+	simulation           *runners.SimulationConfig
+	logDirectory         string
 	jobs                 map[jobid]*runners.InternalJob
 	revision             atomic.Int64
 	currentAgentConfig   *config.AgentConfig
 	currentClusterConfig *config.ClusterConfig
+	configGeneration     string
+	configLoaded         time.Time
+	maintenance          *config.MaintenanceMatcher
 	obsChan              chan *nwpd.Observation
 	writer               nwpd.ObservationWriter
+	forwarder            *obsForwarder
+	forwardedWriter      nwpd.ObservationWriter
+	forwardedSequences   *sequenceTracker
 	aggregator           aggregation.ObservationListenerExtended
+	eventExporter        *aggregation.EventExporter
+	changeFilter         changeFilter
+	fastRechecker        *fastRechecker
+	safetyValve          *safetyValve
+	faultPolicy          *faultPolicyWatch
+	networkInfo          []*nwpd.NetworkInfo
+	networkInfoRead      bool
 	tickPeriod           time.Duration
-	done                 chan struct{}
+	startupDelay         time.Duration
+	started              atomic.Bool
+	runFor               time.Duration
+	summary              *summaryCollector
+	watchdog             *jobWatchdog
+	httpListener         net.Listener
+	echoListener         net.Listener
+	// unknownFilesGracePeriod is the minimum age of files in the output directory not belonging to any data file prefix
+	// of the agent config before they are deleted. Zero keeps them.
+	unknownFilesGracePeriod time.Duration
+	dataFileNamings         []*config.FileNaming
+	done                    chan struct{}
+	// ctx is the root context of the jobs, cancelled on stop
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	nwpd.UnimplementedAgentServiceServer
 }
 
-func newServer(log logrus.FieldLogger, agentConfigFile, clusterConfigFile string, hostNetwork bool) (*server, error) {
-	return &server{
-		log:               log,
-		agentConfigFile:   agentConfigFile,
-		clusterConfigFile: clusterConfigFile,
-		hostNetwork:       hostNetwork,
-		jobs:              map[jobid]*runners.InternalJob{},
-		obsChan:           make(chan *nwpd.Observation, 100),
-		tickPeriod:        200 * time.Millisecond,
-		done:              make(chan struct{}),
-	}, nil
+func newServer(log logrus.FieldLogger, agentConfigFile, clusterConfigFile string, hostNetwork bool, simulation *runners.SimulationConfig) (*server, error) {
+	s := &server{
+		log:                log,
+		agentConfigFile:    agentConfigFile,
+		clusterConfigFile:  clusterConfigFile,
+		hostNetwork:        hostNetwork,
+		simulation:         simulation,
+		logDirectory:       common.PathLogDir,
+		jobs:               map[jobid]*runners.InternalJob{},
+		forwardedSequences: newSequenceTracker(),
+		obsChan:            make(chan *nwpd.Observation, 100),
+		tickPeriod:         200 * time.Millisecond,
+		done:               make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.fastRechecker = newFastRechecker(config.DefaultFastRecheckConcurrency, s.runFastRecheck)
+	s.safetyValve = newSafetyValve()
+	return s, nil
 }
 
 func (s *server) isHostNetwork() bool {
@@ -85,35 +131,34 @@ func (s *server) getNetworkCfg() *config.NetworkConfig {
 }
 
 func (s *server) setup() error {
-	cfg, err := config.LoadAgentConfig(s.agentConfigFile)
+	runners.SetSampleListener(func(jobID string, round int, destHosts []string) {
+		s.log.Debugf("job %s: round %d checks the sampled destinations %s", jobID, round, strings.Join(destHosts, ", "))
+	})
+	runners.SetProxyRulesListener(ReportProxyRules)
+	cfg, err := s.loadAgentConfig()
 	if err != nil {
 		return err
 	}
-	s.currentClusterConfig, err = config.LoadClusterConfig(s.clusterConfigFile)
+	s.currentClusterConfig, err = s.loadClusterConfig()
 	if err != nil {
 		return err
 	}
+	s.setMaintenanceWindows(s.currentClusterConfig)
 
 	options := &aggregation.ObsAggregationOptions{
 		Log:                        s.log.WithField("sub", "aggr"),
 		ReportPeriod:               1 * time.Minute,
 		TimeWindow:                 30 * time.Minute,
-		LogDirectory:               common.PathLogDir,
+		LogDirectory:               s.logDirectory,
 		HostNetwork:                s.hostNetwork,
-		K8sExporterEnabled:         cfg.K8sExporter != nil && cfg.K8sExporter.Enabled,
+		K8sExporterEnabled:         cfg.K8sExporter != nil && cfg.K8sExporter.Enabled && s.simulation == nil,
 		K8sExporterHeartbeatPeriod: 3 * time.Minute,
 	}
 	if cfg.AggregationReportPeriod != nil {
 		options.ReportPeriod = cfg.AggregationReportPeriod.Duration
-		if options.ReportPeriod < 30*time.Second {
-			return fmt.Errorf("Invalid AggregationReportPeriod, must be >= 30s")
-		}
 	}
 	if cfg.AggregationTimeWindow != nil {
 		options.TimeWindow = cfg.AggregationTimeWindow.Duration
-		if options.TimeWindow < 5*time.Minute {
-			return fmt.Errorf("Invalid AggregationTimeWindow, must be >= 5m")
-		}
 	}
 	if cfg.K8sExporter != nil && cfg.K8sExporter.HeartbeatPeriod != nil {
 		options.K8sExporterHeartbeatPeriod = cfg.K8sExporter.HeartbeatPeriod.Duration
@@ -125,11 +170,72 @@ func (s *server) setup() error {
 	if err != nil {
 		return err
 	}
+	SecondsSinceLastSuccess.setAggregator(s.aggregator)
+	if cfg.EventExporter != nil && cfg.EventExporter.Enabled && s.simulation == nil {
+		s.eventExporter, err = aggregation.NewEventExporter(s.log.WithField("sub", "events"), s.hostNetwork, cfg.EventExporter)
+		if err != nil {
+			return err
+		}
+		s.eventExporter.UpdateNodes(s.currentClusterConfig.Nodes)
+	}
 
 	return s.applyAgentConfig(cfg)
 }
 
+func (s *server) loadClusterConfig() (*config.ClusterConfig, error) {
+	if s.simulation != nil {
+		cfg := s.simulation.ClusterConfig()
+		reportDiscoveredPeers(cfg, peerSourceSimulation)
+		reportNodeZones(cfg)
+		reportNodePools(cfg)
+		return cfg, nil
+	}
+	cfg, err := config.LoadClusterConfig(s.clusterConfigFile)
+	if err == nil {
+		reportDiscoveredPeers(cfg, peerSourceController)
+		reportNodeZones(cfg)
+		reportNodePools(cfg)
+	}
+	return cfg, err
+}
+
+// loadAgentConfig loads and validates the agent config. If it is invalid, the last valid agent config stored by the controller
+// next to it is used.
+func (s *server) loadAgentConfig() (*config.AgentConfig, error) {
+	cfg, err := config.LoadAgentConfig(s.agentConfigFile)
+	if err == nil {
+		err = runners.ValidateAgentConfig(cfg)
+	}
+	if err == nil {
+		return cfg, nil
+	}
+	lastKnownGoodFile := path.Join(path.Dir(s.agentConfigFile), common.AgentConfigLastKnownGoodFilename)
+	if _, statErr := os.Stat(lastKnownGoodFile); statErr != nil {
+		return nil, err
+	}
+	lastKnownGood, lkgErr := config.LoadAgentConfig(lastKnownGoodFile)
+	if lkgErr == nil {
+		lkgErr = runners.ValidateAgentConfig(lastKnownGood)
+	}
+	if lkgErr != nil {
+		return nil, fmt.Errorf("%w (last known good config: %s)", err, lkgErr)
+	}
+	s.log.Warnf("invalid agent configuration %s, using last known good configuration %s: %s", s.agentConfigFile, lastKnownGoodFile, err)
+	return lastKnownGood, nil
+}
+
 func (s *server) applyAgentConfig(cfg *config.AgentConfig) error {
+	if err := runners.ValidateAgentConfig(cfg); err != nil {
+		return err
+	}
+	for _, warning := range cfg.Warnings() {
+		s.log.Warnf("agent configuration: %s", warning)
+	}
+	generation, err := cfg.GenerationOrComputed()
+	if err != nil {
+		return err
+	}
+
 	oldJobs := s.getNetworkCfg().Jobs
 	if clone, err := cfg.Clone(); err != nil {
 		return err
@@ -138,30 +244,69 @@ func (s *server) applyAgentConfig(cfg *config.AgentConfig) error {
 	}
 
 	networkCfg := s.getNetworkCfg()
+	namings, err := dataFileNamings(cfg, networkCfg, runners.GetNodeName())
+	if err != nil {
+		return err
+	}
+	JobInfo.setJobs(networkCfg.Jobs)
+	s.lock.Lock()
+	s.configGeneration = generation
+	s.configLoaded = time.Now()
+	s.dataFileNamings = namings
+	s.lock.Unlock()
+	reportConfigGeneration(generation)
+	if s.fastRechecker != nil {
+		s.fastRechecker.setConcurrency(cfg.FastRecheckConcurrencyOrDefault())
+	}
+	s.safetyValve.setConfig(cfg.SafetyValve)
+	runners.SetCABundle(cfg.CABundle)
+	if cfg.ResolverCache != nil {
+		runners.StartResolverCache(cfg.ResolverCache, s.obsChan, ReportResolverCacheLookup)
+	} else {
+		runners.StopResolverCache()
+	}
 	if cfg.OutputDir != "" && s.writer == nil {
-		prefix := "agent"
-		if networkCfg.DataFilePrefix != "" {
-			prefix = networkCfg.DataFilePrefix
-		}
-		var err error
-		s.writer, err = db.NewObsWriter(s.log.WithField("sub", "writer"), cfg.OutputDir, prefix, cfg.RetentionHours)
+		writer, err := db.NewObsWriter(s.log.WithField("sub", "writer"), cfg.OutputDir, namings[0], cfg.RetentionHoursFor(networkCfg),
+			cfg.ObservationBuffer, func() { ReportBackpressureDrop(bufferWriter) })
 		if err != nil {
 			return err
 		}
+		writer.SetDegradedListener(func(degraded bool) { ReportOutputWriteDegraded(bufferWriter, degraded) })
+		writer.SetDiskFreeListener(func(percent float64) { ReportOutputDiskFree(bufferWriter, percent) })
+		writer.SetNetworkInfo(s.getNetworkInfo())
+		if s.unknownFilesGracePeriod > 0 {
+			writer.SetUnknownFilesCleanup(s.getDataFileNamings, s.unknownFilesGracePeriod)
+		}
+		s.writer = writer
+	}
+	setDetailLevel(s.writer, networkCfg.DetailLevelOrDefault())
+	if err := s.setupForwarding(cfg); err != nil {
+		return err
 	}
 
 	validDestHosts := common.StringSet{}
 	applied := common.StringSet{}
+	var stopped []*runners.InternalJob
+	defer func() {
+		s.waitForStoppedJobs(stopped)
+	}()
 	for _, j := range networkCfg.Jobs {
 		job, err := s.parseJob(&j)
 		if err != nil {
 			return err
 		}
+		var oldJob *runners.InternalJob
 		if job != nil {
-			s.addOrReplaceJob(job)
+			oldJob = s.addOrReplaceJob(job)
 			for _, s := range job.DestHosts() {
 				validDestHosts.Add(s)
 			}
+		} else {
+			oldJob = s.deleteJob(j.JobID)
+		}
+		if oldJob != nil {
+			oldJob.Cancel()
+			stopped = append(stopped, oldJob)
 		}
 		applied.Add(j.JobID)
 	}
@@ -170,8 +315,9 @@ func (s *server) applyAgentConfig(cfg *config.AgentConfig) error {
 	for _, j := range oldJobs {
 		if !applied.Contains(j.JobID) {
 			obsoleteJobIDs = append(obsoleteJobIDs, j.JobID)
-			if err := s.deleteJob(j.JobID); err != nil {
-				return err
+			if oldJob := s.deleteJob(j.JobID); oldJob != nil {
+				oldJob.Cancel()
+				stopped = append(stopped, oldJob)
 			}
 		}
 	}
@@ -184,30 +330,53 @@ func (s *server) applyAgentConfig(cfg *config.AgentConfig) error {
 			DestHosts: validDestHosts,
 		})
 	}
-	go func() {
-		// second cleanup later to deal with potential blocked requests
-		// wait for request timeout
-		time.Sleep(1 * time.Minute)
+	// second cleanup later to deal with potential blocked requests
+	// wait for request timeout
+	time.AfterFunc(1*time.Minute, func() {
 		deleteOutdatedMetricByObsoleteJobIDs(obsoleteJobIDs)
 		deleteOutdatedMetricByValidDestHosts(validDestHosts)
-	}()
+	})
 
 	return nil
 }
 
+// waitForStoppedJobs waits until the running checks of the cancelled jobs have finished, at most for jobStopTimeout in total.
+// The replacing jobs are not started before.
+func (s *server) waitForStoppedJobs(jobs []*runners.InternalJob) {
+	deadline := time.Now().Add(jobStopTimeout)
+	for _, job := range jobs {
+		if !job.Wait(time.Until(deadline)) {
+			s.log.Warnf("checks of stopped job %s still running after %s", job.JobID(), jobStopTimeout)
+		}
+	}
+}
+
+// setDetailLevel sets the detail level of the stored observations if supported by the writer.
+func setDetailLevel(writer nwpd.ObservationWriter, level config.DetailLevel) {
+	if w, ok := writer.(interface{ SetDetailLevel(config.DetailLevel) }); ok {
+		w.SetDetailLevel(level)
+	}
+}
+
 func (s *server) parseJob(job *config.Job) (*runners.InternalJob, error) {
 	n := len(job.Args)
 	if n == 0 {
 		return nil, fmt.Errorf("no job args")
 	}
 
+	if what := runners.HostNetworkOnly(job.Args); !s.hostNetwork && what != "" {
+		return nil, fmt.Errorf("invalid job %s: %s is only supported on the host network", job.JobID, what)
+	}
+
 	defaultPeriod := 1 * time.Second
 	if s.getNetworkCfg().DefaultPeriod.Duration != 0 {
 		defaultPeriod = s.getNetworkCfg().DefaultPeriod.Duration
 	}
 	rconfig := runners.RunnerConfig{
-		Job:    *job,
-		Period: defaultPeriod,
+		Job:            *job,
+		Period:         defaultPeriod,
+		SampleStrategy: s.getNetworkCfg().SampleStrategy,
+		SampleSize:     s.getNetworkCfg().SampleSize,
 	}
 	clusterCfg := config.ClusterConfig{}
 	if s.currentClusterConfig != nil {
@@ -220,23 +389,46 @@ func (s *server) parseJob(job *config.Job) (*runners.InternalJob, error) {
 	if runner == nil {
 		return nil, nil
 	}
-	return runners.NewInternalJob(runner), nil
+	if s.simulation != nil {
+		runner = runners.NewSimulatedRunner(runner, s.simulation)
+		if runner == nil {
+			return nil, nil
+		}
+	} else {
+		runners.PreResolve(runner)
+	}
+	schedule, err := job.Schedule.Compile()
+	if err != nil {
+		return nil, fmt.Errorf("invalid job %s: %s", job.JobID, err)
+	}
+	internalJob := runners.NewInternalJob(s.ctx, runner)
+	internalJob.SetSchedule(schedule)
+	return internalJob, nil
 }
 
-func (s *server) addOrReplaceJob(job *runners.InternalJob) {
+// addOrReplaceJob adds the job and returns the replaced job with the same job ID or nil.
+func (s *server) addOrReplaceJob(job *runners.InternalJob) *runners.InternalJob {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
 	prefix := "starting"
-	if oldJob := s.jobs[job.JobID()]; oldJob != nil {
+	oldJob := s.jobs[job.JobID()]
+	if oldJob != nil {
 		prefix = "restarting"
 		job.SetLastRun(oldJob.GetLastRun())
+		job.SetLastCompleted(oldJob.GetLastCompleted())
+		job.SetSuspendedUntil(oldJob.SuspendedUntil())
+		job.SetPredecessor(oldJob)
 	} else {
-		virtualLastRun := time.Now().Add(-time.Duration(float64(job.Period()) * rand.Float64()))
+		now := time.Now()
+		virtualLastRun := now.Add(-time.Duration(float64(job.Period()) * rand.Float64()))
 		job.SetLastRun(&virtualLastRun)
+		// the watchdog measures the time without completed run from the start of the job
+		job.SetLastCompleted(&now)
 	}
 	s.jobs[job.JobID()] = job
 	s.logStart(job, prefix)
+	return oldJob
 }
 
 func (s *server) logStart(job *runners.InternalJob, prefix string) {
@@ -244,19 +436,24 @@ func (s *server) logStart(job *runners.InternalJob, prefix string) {
 	if desc != "" {
 		desc += ", "
 	}
+	if schedule := job.Schedule(); schedule != nil {
+		desc += fmt.Sprintf("schedule=%s, ", schedule)
+	}
 	s.log.Infof("%s job %s: %s [%speriod=%.1fs]", prefix, job.Config().JobID, strings.Join(job.Config().Args, " "),
 		desc, job.Period().Seconds())
 }
 
-func (s *server) deleteJob(jobID string) error {
+// deleteJob removes the job and returns it or nil if there is no job with the job ID.
+func (s *server) deleteJob(jobID string) *runners.InternalJob {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	if oldJob := s.jobs[jobID]; oldJob != nil {
+	oldJob := s.jobs[jobID]
+	if oldJob != nil {
 		delete(s.jobs, jobID)
 		s.log.Infof("deleted job %s", jobID)
 	}
-	return nil
+	return oldJob
 }
 
 func (s *server) GetObservations(_ context.Context, request *nwpd.GetObservationsRequest) (*nwpd.GetObservationsResponse, error) {
@@ -277,11 +474,30 @@ func (s *server) GetObservations(_ context.Context, request *nwpd.GetObservation
 	if err != nil {
 		return nil, err
 	}
+	s.addJobLabels(result)
 	return &nwpd.GetObservationsResponse{
 		Observations: result,
 	}, nil
 }
 
+// addJobLabels sets the labels of the current jobs, as they are not persisted with the observations.
+func (s *server) addJobLabels(observations []*nwpd.Observation) {
+	labels := map[string]map[string]string{}
+	for _, job := range s.getNetworkCfg().Jobs {
+		if len(job.Labels) > 0 {
+			labels[job.JobID] = job.Labels
+		}
+	}
+	if len(labels) == 0 {
+		return
+	}
+	for _, obs := range observations {
+		if obs.Labels == nil {
+			obs.Labels = labels[obs.JobID]
+		}
+	}
+}
+
 type edge struct {
 	src  string
 	dest string
@@ -294,7 +510,7 @@ func (s *server) GetAggregatedObservations(ctx context.Context, request *nwpd.Ge
 	}
 	result := resp.Observations
 	if len(result) == 0 {
-		return &nwpd.GetAggregatedObservationsResponse{}, nil
+		return &nwpd.GetAggregatedObservationsResponse{Mtu: s.mtu(), ConfigGeneration: s.getConfigGeneration()}, nil
 	}
 	rstart := result[0].Timestamp.AsTime()
 	rdelta := 1 * time.Minute
@@ -324,6 +540,10 @@ func (s *server) GetAggregatedObservations(ctx context.Context, request *nwpd.Ge
 			currEnd = rstart.Add(rdelta)
 			addAggregations()
 		}
+		if obs.WarmingUp {
+			// the destination pod has been started recently, so that the observation is neutral
+			continue
+		}
 
 		edge := edge{src: obs.SrcHost, dest: obs.DestHost}
 		aggr := currAggr[edge]
@@ -357,34 +577,105 @@ func (s *server) GetAggregatedObservations(ctx context.Context, request *nwpd.Ge
 
 	return &nwpd.GetAggregatedObservationsResponse{
 		AggregatedObservations: aggregated,
+		Mtu:                    s.mtu(),
+		ConfigGeneration:       s.getConfigGeneration(),
 	}, nil
 }
 
+func (s *server) getConfigGeneration() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.configGeneration
+}
+
+// GetStatus returns the status of the agent including the generation of the agent config in use.
+func (s *server) GetStatus(_ context.Context, _ *nwpd.GetStatusRequest) (*nwpd.AgentStatus, error) {
+	podName, _ := os.Hostname()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	status := &nwpd.AgentStatus{
+		NodeName:         runners.GetNodeName(),
+		PodName:          podName,
+		HostNetwork:      s.hostNetwork,
+		Version:          version.Version,
+		ConfigGeneration: s.configGeneration,
+	}
+	if !s.configLoaded.IsZero() {
+		status.ConfigLoaded = timestamppb.New(s.configLoaded)
+	}
+	return status, nil
+}
+
+// mtu returns the MTU of the network interface of the agent for the MTU consistency check of the controller.
+func (s *server) mtu() int32 {
+	podIP := os.Getenv(common.EnvPodIP)
+	if podIP == "" {
+		return 0
+	}
+	mtu, err := interfaceMTU(podIP)
+	if err != nil {
+		s.log.Warnf("cannot determine MTU: %s", err)
+		return 0
+	}
+	return int32(mtu)
+}
+
 func (s *server) stop() {
+	s.stopJobs()
+	runners.StopResolverCache()
+	if s.forwarder != nil {
+		s.forwarder.Stop()
+		s.forwarder = nil
+	}
 	if s.writer != nil {
 		s.writer.Stop()
 		s.writer = nil
 	}
+	s.lock.Lock()
+	forwardedWriter := s.forwardedWriter
+	s.forwardedWriter = nil
+	s.lock.Unlock()
+	if forwardedWriter != nil {
+		forwardedWriter.Stop()
+	}
+}
+
+// stopJobs cancels the root context of the jobs and waits until their running checks have finished, at most for jobStopTimeout.
+func (s *server) stopJobs() {
+	s.cancel()
+	s.lock.Lock()
+	jobs := make([]*runners.InternalJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		job.Cancel()
+		jobs = append(jobs, job)
+	}
+	s.lock.Unlock()
+	s.waitForStoppedJobs(jobs)
 }
 
 func (s *server) reloadConfig() {
 	s.reloadLock.Lock()
 	defer s.reloadLock.Unlock()
 
-	agentConfig, err := config.LoadAgentConfig(s.agentConfigFile)
+	agentConfig, err := s.loadAgentConfig()
 	if err != nil {
-		s.log.Warnf("cannot load agent configuration from %s", s.agentConfigFile)
+		s.log.Warnf("cannot load agent configuration from %s: %s", s.agentConfigFile, err)
 		return
 	}
-	clusterConfig, err := config.LoadClusterConfig(s.clusterConfigFile)
+	clusterConfig, err := s.loadClusterConfig()
 	if err != nil {
-		s.log.Warnf("cannot load cluster configuration from %s", s.clusterConfigFile)
+		s.log.Warnf("cannot load cluster configuration from %s: %s", s.clusterConfigFile, err)
 		return
 	}
 	changed := !reflect.DeepEqual(clusterConfig, s.currentClusterConfig) || !reflect.DeepEqual(agentConfig, s.currentAgentConfig)
 	if changed {
 		s.log.Infof("reloaded configuration from %s and %s", s.agentConfigFile, s.clusterConfigFile)
 		s.currentClusterConfig = clusterConfig
+		s.setMaintenanceWindows(clusterConfig)
+		runners.SetFaultZones(clusterConfig)
+		if s.eventExporter != nil {
+			s.eventExporter.UpdateNodes(clusterConfig.Nodes)
+		}
 		err = s.applyAgentConfig(agentConfig)
 		if err != nil {
 			s.log.Warnf("cannot apply new agent configuration from %s", s.agentConfigFile)
@@ -401,13 +692,35 @@ func (s *server) run() {
 	signal.Notify(interrupt, os.Interrupt, os.Kill)
 
 	ticker := time.NewTicker(s.tickPeriod)
+	go s.waitForStartupGate()
+	watchdogTicker := time.NewTicker(watchdogPeriod)
+	defer watchdogTicker.Stop()
+	s.updateNetworkInfo()
+	networkInfoTicker := time.NewTicker(networkInfoPeriod)
+	defer networkInfoTicker.Stop()
 
-	if port := s.getNetworkCfg().HttpPort; port != 0 {
-		s.log.Infof("provide metrics at ':%d/metrics'", port)
-		http.Handle("/metrics", promhttp.Handler())
-		go func() {
-			http.ListenAndServe(fmt.Sprintf(":%d", port), nil)
-		}()
+	var runForExpired <-chan time.Time
+	if s.runFor > 0 {
+		start := time.Now()
+		var err error
+		s.summary, err = newSummaryCollector(start, start.Add(s.runFor+drainTimeout))
+		if err != nil {
+			log.Fatal(err)
+		}
+		timer := time.NewTimer(s.runFor)
+		defer timer.Stop()
+		runForExpired = timer.C
+	}
+
+	if s.httpListener != nil {
+		http.HandleFunc("/edges.json", s.edgesHandler)
+		if s.watchdog != nil {
+			http.HandleFunc("/readyz", s.watchdog.readyHandler)
+		}
+	}
+	if s.echoListener != nil {
+		defer s.echoListener.Close()
+		go serveEcho(s.log, s.echoListener)
 	}
 	if s.writer != nil {
 		go s.writer.Run()
@@ -420,8 +733,10 @@ func (s *server) run() {
 	if err := watcher.Add(path.Dir(s.agentConfigFile)); err != nil {
 		log.Fatal(err)
 	}
-	if err := watcher.Add(path.Dir(s.clusterConfigFile)); err != nil {
-		log.Fatal(err)
+	if s.simulation == nil {
+		if err := watcher.Add(path.Dir(s.clusterConfigFile)); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	for {
@@ -435,27 +750,13 @@ func (s *server) run() {
 			s.stop()
 			return
 		case obs := <-s.obsChan:
-			logObservation := s.currentAgentConfig.LogObservations
-			if logObservation {
-				fields := logrus.Fields{
-					"src":   obs.SrcHost,
-					"dest":  obs.DestHost,
-					"ok":    obs.Ok,
-					"jobid": obs.JobID,
-					"time":  obs.Timestamp.AsTime(),
-				}
-				s.log.WithFields(fields).Info(obs.Result)
-			}
-			IncAggregatedObservation(obs.SrcHost, obs.DestHost, obs.JobID, obs.Ok)
-			if obs.Ok && obs.Duration != nil {
-				ReportAggregatedObservationLatency(obs.SrcHost, obs.DestHost, obs.JobID, obs.Duration.AsDuration().Seconds())
-			}
-			if s.writer != nil {
-				s.writer.Add(obs)
-			}
-			if s.aggregator != nil {
-				s.aggregator.Add(obs)
-			}
+			s.handleObservation(obs)
+		case <-runForExpired:
+			ticker.Stop()
+			s.log.Infof("run time of %s expired, waiting for active jobs", s.runFor)
+			s.drainJobs(drainTimeout)
+			s.stop()
+			return
 		case err := <-watcher.Errors:
 			s.log.Warning("watcher failed: %s", err)
 			s.stop()
@@ -465,15 +766,362 @@ func (s *server) run() {
 			go s.reloadConfig()
 		case <-ticker.C:
 			s.triggerJobs()
+		case <-watchdogTicker.C:
+			s.checkSafetyValve()
+			s.checkJobs()
+			if err := s.reloadFaultPolicy(); err != nil {
+				s.log.Warnf("cannot load fault policy: %s", err)
+			}
+		case <-networkInfoTicker.C:
+			s.updateNetworkInfo()
+		}
+	}
+}
+
+// setMaintenanceWindows updates the maintenance windows from the cluster configuration.
+func (s *server) setMaintenanceWindows(cfg *config.ClusterConfig) {
+	matcher := config.NewMaintenanceMatcher(cfg)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.maintenance = matcher
+}
+
+// inMaintenance checks if the observation was taken during a matching maintenance window.
+func (s *server) inMaintenance(obs *nwpd.Observation) bool {
+	s.lock.Lock()
+	matcher := s.maintenance
+	s.lock.Unlock()
+	return matcher.Matches(obs.Timestamp.AsTime(), obs.JobID, obs.SrcHost, obs.DestHost)
+}
+
+func (s *server) handleObservation(obs *nwpd.Observation) {
+	if s.inMaintenance(obs) {
+		obs.Maintenance = true
+	}
+	cfg := s.jobConfig(obs.JobID)
+	if expected := cfg.ExpectedLatency.For(obs.DestHost); expected > 0 && obs.Ok && obs.Duration != nil && obs.Duration.AsDuration() > expected {
+		obs.LatencyBreach = true
+	}
+	logObservation := s.currentAgentConfig.LogObservations
+	if factor := s.currentAgentConfig.LogDroppingFactorFor(s.getNetworkCfg()); logObservation && factor > 0 {
+		logObservation = rand.Float64() >= factor
+	}
+	if logObservation {
+		fields := logrus.Fields{
+			"src":   obs.SrcHost,
+			"dest":  obs.DestHost,
+			"ok":    obs.Ok,
+			"jobid": obs.JobID,
+			"time":  obs.Timestamp.AsTime(),
 		}
+		if obs.Maintenance {
+			fields["maintenance"] = true
+		}
+		if obs.LatencyBreach {
+			fields["latencyBreach"] = true
+		}
+		if obs.WarmingUp {
+			fields["warmingUp"] = true
+		}
+		if obs.SharedResultOf != "" {
+			fields["sharedResultOf"] = obs.SharedResultOf
+		}
+		for name, value := range obs.Labels {
+			fields["label."+name] = value
+		}
+		s.log.WithFields(fields).Info(obs.Result)
+	}
+	IncAggregatedObservation(obs.SrcHost, obs.DestHost, obs.JobID, obs.Ok, obs.Maintenance, obs.WarmingUp)
+	s.safetyValve.observe(obs)
+	if obs.SharedResultOf != "" {
+		ReportSharedResult(obs.JobID)
+	}
+	if obs.FailureClass == runners.FailureClassBlackhole {
+		// the path accepts connections but drops the data, which is hidden from connection checks
+		ReportBlackholeDetection(obs.SrcHost, obs.DestHost, obs.JobID)
+		s.log.Errorf("blackhole detected by job %s on edge %s->%s: %s", obs.JobID, obs.SrcHost, obs.DestHost, obs.Result)
+	}
+	if obs.UdpDuration != nil {
+		ReportDNSQueryLatency(obs.SrcHost, obs.DestHost, obs.JobID, nwpd.ProtocolUDP, obs.UdpDuration.AsDuration().Seconds())
+	}
+	if obs.TcpDuration != nil {
+		ReportDNSQueryLatency(obs.SrcHost, obs.DestHost, obs.JobID, nwpd.ProtocolTCP, obs.TcpDuration.AsDuration().Seconds())
+	}
+	// the latency of a shared result has already been reported by the job which has run the check
+	if obs.Ok && obs.Duration != nil && obs.SharedResultOf == "" {
+		ReportAggregatedObservationLatency(obs.SrcHost, obs.DestHost, obs.JobID, obs.Duration.AsDuration().Seconds())
+		ReportObservationDuration(obs.JobID, obs.Duration.AsDuration().Seconds())
+	}
+	if obs.LatencyBreach {
+		ReportLatencyBreach(obs.SrcHost, obs.DestHost, obs.JobID)
+	}
+	if s.summary != nil {
+		s.summary.add(obs)
+	}
+	if (s.writer != nil || s.forwarder != nil) && s.emitObservation(obs) {
+		if s.writer != nil {
+			s.writer.Add(obs)
+		}
+		if s.forwarder != nil {
+			s.forwarder.Add(obs)
+		}
+	}
+	if s.aggregator != nil {
+		s.aggregator.Add(obs)
+	}
+	if s.eventExporter != nil {
+		s.eventExporter.Add(obs)
+	}
+	if s.fastRechecker != nil {
+		s.fastRechecker.observe(obs, cfg.FastRecheck)
 	}
 }
 
+// jobConfig returns the config of the job or an empty config if the job is unknown.
+func (s *server) jobConfig(jobID string) config.Job {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if job := s.jobs[jobID]; job != nil {
+		return job.Config().Job
+	}
+	return config.Job{}
+}
+
+// runFastRecheck runs a fast re-check of the job for the destination host. The observation is handled like the scheduled ones.
+func (s *server) runFastRecheck(jobID, destHost, triggeredBy string) bool {
+	s.lock.Lock()
+	job := s.jobs[jobID]
+	s.lock.Unlock()
+	if job == nil {
+		return false
+	}
+	return job.RunFor(s.obsChan, destHost, triggeredBy)
+}
+
+// drainJobs waits until no job is active anymore and handles the pending observations.
+func (s *server) drainJobs(timeout time.Duration) {
+	deadline := time.After(timeout)
+	poll := time.NewTicker(50 * time.Millisecond)
+	defer poll.Stop()
+	for {
+		select {
+		case obs := <-s.obsChan:
+			s.handleObservation(obs)
+		case <-poll.C:
+			if !s.hasActiveJobs() && len(s.obsChan) == 0 {
+				return
+			}
+		case <-deadline:
+			s.log.Warnf("jobs still active after %s, ignoring their observations", timeout)
+			return
+		}
+	}
+}
+
+func (s *server) hasActiveJobs() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, job := range s.jobs {
+		if job.IsActive() {
+			return true
+		}
+	}
+	return false
+}
+
+// emitObservation checks if the observation should be stored. If EmitOnChangeOnly is set, only changes of the edge result are stored.
+func (s *server) emitObservation(obs *nwpd.Observation) bool {
+	if !s.getNetworkCfg().EmitOnChangeOnly {
+		s.changeFilter.reset()
+		return true
+	}
+	return s.changeFilter.pass(obs)
+}
+
 func (s *server) triggerJobs() {
+	if !s.started.Load() {
+		return
+	}
+
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
 	for _, job := range s.jobs {
-		job.Tick(s.obsChan)
+		if trigger := job.Config().TriggeredBy; trigger != nil {
+			job.TickTriggered(s.obsChan, s.triggerFunc(trigger))
+			continue
+		}
+		lag, status := job.Tick(s.obsChan)
+		switch status {
+		case runners.TickStarted:
+			ReportSchedulerLag(job.JobID(), lag.Seconds())
+			s.safetyValve.observeLag(job.JobID(), lag)
+		case runners.TickSkippedOverlap:
+			s.log.Debugf("skipped run of job %s as previous run has not finished", job.JobID())
+			ReportSkippedOverlap(job.JobID())
+		}
+		ReportRunningChecks(job.JobID(), job.RunningChecks())
+	}
+}
+
+// triggerFunc looks up the edge state of the trigger job for a destination host.
+func (s *server) triggerFunc(trigger *config.JobTrigger) runners.TriggerFunc {
+	srcHost := runners.GetNodeName()
+	return func(destHost string) (string, bool) {
+		if s.aggregator == nil {
+			return "", false
+		}
+		state, obs := s.aggregator.EdgeState(trigger.JobID, srcHost, destHost)
+		switch {
+		case state == aggregation.EdgeStateFailing:
+		case state == aggregation.EdgeStateDegraded && trigger.Condition == config.TriggerOnDegraded:
+		default:
+			return "", false
+		}
+		return obs.ID(), true
+	}
+}
+
+// setupForwarding starts forwarding the observations to the host network agent in the pod network agent, or
+// the writer for the forwarded observations in the host network agent, if enabled for the pod network.
+func (s *server) setupForwarding(cfg *config.AgentConfig) error {
+	if cfg.PodNetwork == nil || !cfg.PodNetwork.ForwardObservations || s.simulation != nil {
+		return nil
+	}
+	if !s.isHostNetwork() {
+		if s.forwarder != nil {
+			return nil
+		}
+		nodeIP := os.Getenv(common.EnvNodeIP)
+		if nodeIP == "" {
+			return fmt.Errorf("cannot forward observations: missing env variable %s", common.EnvNodeIP)
+		}
+		port := common.HostNetPodGRPCPort
+		if cfg.HostNetwork != nil && cfg.HostNetwork.GRPCPort != 0 {
+			port = cfg.HostNetwork.GRPCPort
+		}
+		address := net.JoinHostPort(nodeIP, strconv.Itoa(port))
+		sender, _ := os.Hostname()
+		forwarder, err := newObsForwarder(s.log.WithField("sub", "forwarder"), address, sender)
+		if err != nil {
+			return err
+		}
+		s.log.Infof("forwarding observations to %s", address)
+		s.forwarder = forwarder
+		go forwarder.Run()
+		return nil
+	}
+
+	if cfg.OutputDir == "" {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.forwardedWriter != nil {
+		// the forwarded observations are stored with the detail level of the pod network
+		setDetailLevel(s.forwardedWriter, cfg.PodNetwork.DetailLevelOrDefault())
+		return nil
+	}
+	naming, err := forwardedFileNaming(cfg.PodNetwork, runners.GetNodeName())
+	if err != nil {
+		return err
+	}
+	writer, err := db.NewObsWriter(s.log.WithField("sub", "forwarded-writer"), cfg.OutputDir, naming, cfg.RetentionHoursFor(cfg.PodNetwork),
+		cfg.ObservationBuffer, func() { ReportBackpressureDrop(bufferForwardedWriter) })
+	if err != nil {
+		return err
+	}
+	writer.SetDetailLevel(cfg.PodNetwork.DetailLevelOrDefault())
+	writer.SetDegradedListener(func(degraded bool) { ReportOutputWriteDegraded(bufferForwardedWriter, degraded) })
+	writer.SetDiskFreeListener(func(percent float64) { ReportOutputDiskFree(bufferForwardedWriter, percent) })
+	s.forwardedWriter = writer
+	go writer.Run()
+	return nil
+}
+
+// ForwardObservations persists the observations forwarded by the pod network agent on the same node.
+// Observations already persisted (e.g. if the acknowledgement was lost) are skipped by their sequence numbers.
+func (s *server) ForwardObservations(_ context.Context, request *nwpd.ForwardObservationsRequest) (*nwpd.ForwardObservationsResponse, error) {
+	s.lock.Lock()
+	writer := s.forwardedWriter
+	s.lock.Unlock()
+	if writer == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "forwarding of observations is not enabled")
+	}
+	if request.FirstSequence == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid first sequence number 0")
+	}
+	skip, last, missing := s.forwardedSequences.accept(request.Sender, request.Instance, request.FirstSequence, len(request.Observations))
+	if missing > 0 {
+		s.log.Warnf("%d observations of %s have been dropped before forwarding", missing, request.Sender)
+	}
+	for _, obs := range request.Observations[skip:] {
+		writer.Add(obs)
+	}
+	return &nwpd.ForwardObservationsResponse{LastSequence: last}, nil
+}
+
+// getDataFileNamings returns the file namings of the observation files of the current agent config.
+func (s *server) getDataFileNamings() []*config.FileNaming {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.dataFileNamings
+}
+
+// dataFilePrefix returns the data file prefix of the network config or the default prefix if not set.
+func dataFilePrefix(networkCfg *config.NetworkConfig, defaultPrefix string) string {
+	if networkCfg != nil && networkCfg.DataFilePrefix != "" {
+		return networkCfg.DataFilePrefix
+	}
+	return defaultPrefix
+}
+
+// fileNaming returns the file naming of the observation files of the network config.
+func fileNaming(networkCfg *config.NetworkConfig, defaultPrefix, node string) (*config.FileNaming, error) {
+	template := ""
+	if networkCfg != nil {
+		template = networkCfg.FilenameTemplate
+	}
+	return config.NewFileNaming(template, dataFilePrefix(networkCfg, defaultPrefix), node)
+}
+
+// forwardedFileNaming returns the file naming of the observation files forwarded by the pod network agent.
+func forwardedFileNaming(podNetworkCfg *config.NetworkConfig, node string) (*config.FileNaming, error) {
+	template := ""
+	if podNetworkCfg != nil {
+		template = podNetworkCfg.FilenameTemplate
+	}
+	return config.NewFileNaming(template, dataFilePrefix(podNetworkCfg, common.NameDaemonSetAgentPodNet)+common.DataFileSuffixForwarded, node)
+}
+
+// dataFileNamings returns the file namings of the observation files written by the agents of both daemon sets on the node
+// into the shared output directory, including the files of the forwarded observations. The first one is the file naming
+// of the given network config.
+func dataFileNamings(cfg *config.AgentConfig, networkCfg *config.NetworkConfig, node string) ([]*config.FileNaming, error) {
+	var namings []*config.FileNaming
+	add := func(naming *config.FileNaming, err error) error {
+		if err != nil {
+			return err
+		}
+		namings = append(namings, naming)
+		return nil
+	}
+	if err := add(fileNaming(networkCfg, "agent", node)); err != nil {
+		return nil, err
+	}
+	if cfg.HostNetwork != nil {
+		if err := add(fileNaming(cfg.HostNetwork, "agent", node)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.PodNetwork != nil {
+		if err := add(fileNaming(cfg.PodNetwork, "agent", node)); err != nil {
+			return nil, err
+		}
+		if err := add(forwardedFileNaming(cfg.PodNetwork, node)); err != nil {
+			return nil, err
+		}
 	}
+	return namings, nil
 }
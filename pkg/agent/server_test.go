@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestLoadAgentConfigLastKnownGood(t *testing.T) {
+	dir := t.TempDir()
+	agentConfigFile := filepath.Join(dir, common.AgentConfigFilename)
+	lastKnownGoodFile := filepath.Join(dir, common.AgentConfigLastKnownGoodFilename)
+	valid := "podNetwork:\n  jobs:\n  - jobID: ping-p2n\n    args: [pingHost]\n"
+	invalid := "podNetwork:\n  jobs:\n  - jobID: ping-p2n\n    args: [pingHosts]\n"
+
+	srv, err := newServer(logrus.New(), agentConfigFile, "", false, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(agentConfigFile, []byte(invalid), 0644))
+	_, err = srv.loadAgentConfig()
+	assert.Error(t, err, "no last known good config")
+
+	assert.NoError(t, os.WriteFile(lastKnownGoodFile, []byte(valid), 0644))
+	cfg, err := srv.loadAgentConfig()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"pingHost"}, cfg.PodNetwork.Jobs[0].Args)
+	}
+
+	assert.NoError(t, os.WriteFile(agentConfigFile, []byte(valid+"  - jobID: tcp-p2n\n    args: [checkTCPPort, --node-port, \"1234\"]\n"), 0644))
+	cfg, err = srv.loadAgentConfig()
+	if assert.NoError(t, err) {
+		assert.Len(t, cfg.PodNetwork.Jobs, 2)
+	}
+}
+
+func TestLatencyBreach(t *testing.T) {
+	srv, err := newServer(logrus.New(), "", "", false, nil)
+	assert.NoError(t, err)
+	srv.currentAgentConfig = &config.AgentConfig{}
+	endpoints := []config.Endpoint{{Hostname: "node1", IP: "10.0.0.11", Port: 1011}, {Hostname: "node2", IP: "10.0.0.12", Port: 1012}}
+	rconfig := runners.RunnerConfig{
+		Job: config.Job{JobID: "tcp-slo", ExpectedLatency: &config.ExpectedLatency{
+			Default:      &metav1.Duration{Duration: 5 * time.Millisecond},
+			Destinations: map[string]metav1.Duration{"node2": {Duration: 50 * time.Millisecond}},
+		}},
+		Period: time.Second,
+	}
+	srv.jobs["tcp-slo"] = runners.NewInternalJob(context.Background(), runners.NewCheckTCPPort(endpoints, rconfig))
+
+	observe := func(jobID, dest string, ok bool, duration time.Duration) *nwpd.Observation {
+		obs := &nwpd.Observation{JobID: jobID, SrcHost: "node0", DestHost: dest, Ok: ok, Duration: durationpb.New(duration)}
+		srv.handleObservation(obs)
+		return obs
+	}
+	breaches := func(dest string) float64 {
+		return testutil.ToFloat64(LatencyBreaches.WithLabelValues("node0", dest, "tcp-slo"))
+	}
+
+	assert.False(t, observe("tcp-slo", "node1", true, 3*time.Millisecond).LatencyBreach)
+	assert.True(t, observe("tcp-slo", "node1", true, 8*time.Millisecond).LatencyBreach)
+	assert.False(t, observe("tcp-slo", "node1", false, 8*time.Millisecond).LatencyBreach, "failed checks are no breaches")
+	assert.False(t, observe("tcp-slo", "node2", true, 8*time.Millisecond).LatencyBreach, "expected latency of destination")
+	assert.True(t, observe("tcp-slo", "node2", true, 80*time.Millisecond).LatencyBreach)
+	assert.False(t, observe("other", "node1", true, time.Second).LatencyBreach, "job without expected latency")
+	assert.Equal(t, 1.0, breaches("node1"))
+	assert.Equal(t, 1.0, breaches("node2"))
+}
@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/analysis"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+const simulationAgentConfig = `
+outputDir: %s
+retentionHours: 1
+podNetwork:
+  dataFilePrefix: simulation
+  defaultPeriod: 10ms
+  jobs:
+  - jobID: tcp-n2api-int
+    args: ["checkTCPPort", "--endpoint-internal-kube-apiserver"]
+  - jobID: tcp-n2p
+    args: ["checkTCPPort", "--endpoints-of-pod-ds"]
+  - jobID: tcp-n2n
+    args: ["checkTCPPort", "--node-port", "1011"]
+  - jobID: ping-n2n
+    args: ["pingHost"]
+`
+
+const (
+	simulationDuration = 3 * time.Second
+	// resource ceilings for the simulation
+	maxHeapAllocBytes = 64 * 1024 * 1024
+	maxGoroutines     = 50
+)
+
+// TestSimulation runs the agent with synthetic runners for 1000 nodes and checks the resource consumption.
+func TestSimulation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping simulation in short mode")
+	}
+
+	dir := t.TempDir()
+	outputDir := filepath.Join(dir, "records")
+	agentConfigFile := filepath.Join(dir, "agent.config")
+	assert.NoError(t, os.WriteFile(agentConfigFile, []byte(fmt.Sprintf(simulationAgentConfig, outputDir)), 0644))
+
+	simulation, err := runners.ParseSimulationConfig("nodes=1000,failRate=0.02,latency=5ms±3ms")
+	assert.NoError(t, err)
+
+	log := logrus.New()
+	log.SetLevel(logrus.WarnLevel)
+	srv, err := newServer(log, agentConfigFile, "", false, simulation)
+	assert.NoError(t, err)
+	srv.logDirectory = filepath.Join(dir, "log")
+	// tick faster than in production to increase the load
+	srv.tickPeriod = 10 * time.Millisecond
+	if !assert.NoError(t, srv.setup()) {
+		return
+	}
+	assert.Len(t, srv.jobs, 4)
+
+	stopped := make(chan struct{})
+	go func() {
+		srv.run()
+		close(stopped)
+	}()
+	time.Sleep(simulationDuration)
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	goroutines := runtime.NumGoroutine()
+	close(srv.done)
+	<-stopped
+
+	t.Logf("heap alloc: %d bytes, goroutines: %d", mem.HeapAlloc, goroutines)
+	assert.Less(t, mem.HeapAlloc, uint64(maxHeapAllocBytes), "heap ceiling exceeded")
+	assert.Less(t, goroutines, maxGoroutines, "goroutine ceiling exceeded")
+
+	source, err := analysis.OpenDir(outputDir)
+	assert.NoError(t, err)
+	count := 0
+	failed := 0
+	jobIDs := map[string]bool{}
+	err = source.Iterate(analysis.Filter{}, func(obs *nwpd.Observation) error {
+		count++
+		if !obs.Ok {
+			failed++
+		}
+		jobIDs[obs.JobID] = true
+		return nil
+	})
+	assert.NoError(t, err)
+	t.Logf("observations: %d, failed: %d", count, failed)
+	assert.Greater(t, count, 0)
+	assert.Len(t, jobIDs, 4)
+}
@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+const startupProbeInterval = 1 * time.Second
+
+// waitForStartupGate delays the start of the checks until the internal kube-apiserver is reachable
+// or the startup delay has passed. On node boot, DNS and routes may not be ready yet and the first checks would fail spuriously.
+func (s *server) waitForStartupGate() {
+	if s.startupDelay <= 0 {
+		s.started.Store(true)
+		return
+	}
+
+	start := time.Now()
+	deadline := start.Add(s.startupDelay)
+	reason := "startup delay expired"
+	for {
+		if s.probeSelfConnectivity() {
+			reason = "internal kube-apiserver reachable"
+			break
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		if remaining > startupProbeInterval {
+			remaining = startupProbeInterval
+		}
+		time.Sleep(remaining)
+	}
+	s.log.Infof("startup gate satisfied after %.1fs (%s), starting checks", time.Since(start).Seconds(), reason)
+	s.started.Store(true)
+}
+
+// probeSelfConnectivity tries to connect to the internal kube-apiserver.
+func (s *server) probeSelfConnectivity() bool {
+	if s.simulation != nil {
+		return false
+	}
+	clusterConfig := s.currentClusterConfig
+	if clusterConfig == nil || clusterConfig.InternalKubeAPIServer == nil {
+		return false
+	}
+	ep := clusterConfig.InternalKubeAPIServer
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ep.IP, strconv.Itoa(ep.Port)), startupProbeInterval)
+	if err != nil {
+		s.log.Debugf("startup probe failed: %s", err)
+		return false
+	}
+	conn.Close()
+	return true
+}
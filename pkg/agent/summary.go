@@ -0,0 +1,244 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jamiealquiza/tachymeter"
+
+	"github.com/gardener/network-problem-detector/pkg/analysis"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+const (
+	// maxWorstEdges is the maximum number of edges with failures listed per job
+	maxWorstEdges = 5
+	// latencySamples is the number of latest durations per job used for the percentiles
+	latencySamples = 10000
+)
+
+// Verdict is the overall result of a one-shot run.
+type Verdict string
+
+const (
+	// VerdictPassed means the failure ratios of all jobs are below the thresholds.
+	VerdictPassed Verdict = "passed"
+	// VerdictDegraded means the failure ratio of at least one job is above the degraded threshold.
+	VerdictDegraded Verdict = "degraded"
+	// VerdictFailed means the failure ratio of at least one job is above the failed threshold or there are no observations.
+	VerdictFailed Verdict = "failed"
+)
+
+// ExitCode returns the exit code of run-agent for the verdict.
+func (v Verdict) ExitCode() int {
+	switch v {
+	case VerdictPassed:
+		return 0
+	case VerdictDegraded:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// SummaryThresholds are the failure ratios of a job which result in a degraded or failed verdict.
+type SummaryThresholds struct {
+	DegradedRatio float64
+	FailedRatio   float64
+}
+
+// RunSummary is the machine-readable summary of a one-shot run.
+type RunSummary struct {
+	Start       time.Time    `json:"start"`
+	End         time.Time    `json:"end"`
+	HostNetwork bool         `json:"hostNetwork"`
+	Verdict     Verdict      `json:"verdict"`
+	ExitCode    int          `json:"exitCode"`
+	Jobs        []JobSummary `json:"jobs"`
+}
+
+// JobSummary contains the results of a job over all edges.
+type JobSummary struct {
+	JobID        string  `json:"jobID"`
+	Samples      int     `json:"samples"`
+	Failures     int     `json:"failures"`
+	FailureRatio float64 `json:"failureRatio"`
+	// LatencyMillis contains the percentiles of the durations of successful checks.
+	LatencyMillis *LatencyPercentiles `json:"latencyMillis,omitempty"`
+	// WorstEdges are the edges with the highest failure ratios.
+	WorstEdges []EdgeSummary `json:"worstEdges,omitempty"`
+}
+
+// LatencyPercentiles contains percentiles of durations in milliseconds.
+type LatencyPercentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// EdgeSummary contains the results of a job on an edge.
+type EdgeSummary struct {
+	SrcHost      string  `json:"srcHost"`
+	DestHost     string  `json:"destHost"`
+	Samples      int     `json:"samples"`
+	Failures     int     `json:"failures"`
+	FailureRatio float64 `json:"failureRatio"`
+}
+
+// summaryCollector aggregates all observations of a one-shot run.
+type summaryCollector struct {
+	start      time.Time
+	aggregator *analysis.Aggregator
+	latencies  map[string]*tachymeter.Tachymeter
+}
+
+// newSummaryCollector creates a collector for observations in the time range [start, end].
+func newSummaryCollector(start, end time.Time) (*summaryCollector, error) {
+	// one bucket per minute keeps the bucket counters small
+	buckets := int(math.Ceil(end.Sub(start).Minutes()))
+	aggregator, err := analysis.NewAggregator(analysis.Options{
+		Filter: analysis.Filter{
+			Start: start,
+			End:   end,
+		},
+		Buckets: buckets,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &summaryCollector{
+		start:      start,
+		aggregator: aggregator,
+		latencies:  map[string]*tachymeter.Tachymeter{},
+	}, nil
+}
+
+func (c *summaryCollector) add(obs *nwpd.Observation) {
+	c.aggregator.Add(obs)
+	if obs.Ok && obs.Duration != nil {
+		t := c.latencies[obs.JobID]
+		if t == nil {
+			t = tachymeter.New(&tachymeter.Config{Size: latencySamples})
+			c.latencies[obs.JobID] = t
+		}
+		t.AddTime(obs.Duration.AsDuration())
+	}
+}
+
+func (c *summaryCollector) summary(end time.Time, hostNetwork bool, thresholds SummaryThresholds) *RunSummary {
+	report := c.aggregator.Report()
+	summary := &RunSummary{
+		Start:       c.start,
+		End:         end,
+		HostNetwork: hostNetwork,
+		Verdict:     VerdictPassed,
+	}
+	total := 0
+	for _, jobID := range report.Jobs {
+		js := JobSummary{JobID: jobID}
+		for edge, ed := range report.Edges {
+			jr := ed.JobResults[jobID]
+			if jr == nil {
+				continue
+			}
+			es := EdgeSummary{
+				SrcHost:  edge.Src,
+				DestHost: edge.Dest,
+				Samples:  jr.OkCount() + jr.FailedCount(),
+				Failures: jr.FailedCount(),
+			}
+			es.FailureRatio = ratio(es.Failures, es.Samples)
+			js.Samples += es.Samples
+			js.Failures += es.Failures
+			if es.Failures > 0 {
+				js.WorstEdges = append(js.WorstEdges, es)
+			}
+		}
+		sort.Slice(js.WorstEdges, func(i, j int) bool {
+			a, b := js.WorstEdges[i], js.WorstEdges[j]
+			if a.FailureRatio != b.FailureRatio {
+				return a.FailureRatio > b.FailureRatio
+			}
+			if a.SrcHost != b.SrcHost {
+				return a.SrcHost < b.SrcHost
+			}
+			return a.DestHost < b.DestHost
+		})
+		if len(js.WorstEdges) > maxWorstEdges {
+			js.WorstEdges = js.WorstEdges[:maxWorstEdges]
+		}
+		js.FailureRatio = ratio(js.Failures, js.Samples)
+		if t := c.latencies[jobID]; t != nil {
+			metrics := t.Calc()
+			js.LatencyMillis = &LatencyPercentiles{
+				P50: millis(metrics.Time.P50),
+				P95: millis(metrics.Time.P95),
+				P99: millis(metrics.Time.P99),
+				Max: millis(metrics.Time.Max),
+			}
+		}
+		switch {
+		case js.FailureRatio > thresholds.FailedRatio:
+			summary.Verdict = VerdictFailed
+		case js.FailureRatio > thresholds.DegradedRatio && summary.Verdict == VerdictPassed:
+			summary.Verdict = VerdictDegraded
+		}
+		total += js.Samples
+		summary.Jobs = append(summary.Jobs, js)
+	}
+	if total == 0 {
+		summary.Verdict = VerdictFailed
+	}
+	summary.ExitCode = summary.Verdict.ExitCode()
+	return summary
+}
+
+// SummarizeObservations returns the summary of observations collected in the time range [start, end] outside of an agent,
+// e.g. by the external prober.
+func SummarizeObservations(observations []*nwpd.Observation, start, end time.Time, hostNetwork bool, thresholds SummaryThresholds) (*RunSummary, error) {
+	collector, err := newSummaryCollector(start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, obs := range observations {
+		collector.add(obs)
+	}
+	return collector.summary(end, hostNetwork, thresholds), nil
+}
+
+// WriteSummary writes the summary as JSON to the file or to stdout if filename is empty.
+func WriteSummary(summary *RunSummary, filename string) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if filename == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("writing summary file %s failed: %w", filename, err)
+	}
+	return nil
+}
+
+func ratio(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/analysis"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestSummaryCollector(t *testing.T) {
+	start := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	thresholds := SummaryThresholds{DegradedRatio: 0.1, FailedRatio: 0.3}
+
+	collect := func(failures map[string]int) *RunSummary {
+		c, err := newSummaryCollector(start, start.Add(10*time.Minute))
+		assert.NoError(t, err)
+		for _, dest := range []string{"node1", "node2", "node3"} {
+			for i := 0; i < 10; i++ {
+				c.add(&nwpd.Observation{
+					JobID:     "tcp-n2n",
+					SrcHost:   "node1",
+					DestHost:  dest,
+					Timestamp: timestamppb.New(start.Add(time.Duration(i) * time.Minute)),
+					Duration:  durationpb.New(time.Duration(i+1) * time.Millisecond),
+					Ok:        i >= failures[dest],
+				})
+			}
+		}
+		return c.summary(start.Add(10*time.Minute), true, thresholds)
+	}
+
+	summary := collect(nil)
+	assert.Equal(t, VerdictPassed, summary.Verdict)
+	assert.Equal(t, 0, summary.ExitCode)
+	if assert.Len(t, summary.Jobs, 1) {
+		js := summary.Jobs[0]
+		assert.Equal(t, "tcp-n2n", js.JobID)
+		assert.Equal(t, 30, js.Samples)
+		assert.Equal(t, 0, js.Failures)
+		assert.Empty(t, js.WorstEdges)
+		if assert.NotNil(t, js.LatencyMillis) {
+			assert.Equal(t, 10.0, js.LatencyMillis.Max)
+		}
+	}
+
+	summary = collect(map[string]int{"node2": 3, "node3": 1})
+	assert.Equal(t, VerdictDegraded, summary.Verdict)
+	assert.Equal(t, 1, summary.ExitCode)
+	if assert.Len(t, summary.Jobs, 1) {
+		js := summary.Jobs[0]
+		assert.Equal(t, 4, js.Failures)
+		assert.Equal(t, []EdgeSummary{
+			{SrcHost: "node1", DestHost: "node2", Samples: 10, Failures: 3, FailureRatio: 0.3},
+			{SrcHost: "node1", DestHost: "node3", Samples: 10, Failures: 1, FailureRatio: 0.1},
+		}, js.WorstEdges)
+	}
+
+	summary = collect(map[string]int{"node1": 10})
+	assert.Equal(t, VerdictFailed, summary.Verdict)
+	assert.Equal(t, 2, summary.ExitCode)
+
+	c, err := newSummaryCollector(start, start.Add(10*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, VerdictFailed, c.summary(start.Add(10*time.Minute), true, thresholds).Verdict, "no observations")
+}
+
+// TestRunFor checks that a one-shot run stops by itself and all observations of the summary are flushed to the records.
+func TestRunFor(t *testing.T) {
+	dir := t.TempDir()
+	outputDir := filepath.Join(dir, "records")
+	agentConfigFile := filepath.Join(dir, "agent.config")
+	assert.NoError(t, os.WriteFile(agentConfigFile, []byte(fmt.Sprintf(simulationAgentConfig, outputDir)), 0644))
+
+	simulation, err := runners.ParseSimulationConfig("nodes=10,latency=1ms")
+	assert.NoError(t, err)
+
+	log := logrus.New()
+	log.SetLevel(logrus.WarnLevel)
+	srv, err := newServer(log, agentConfigFile, "", false, simulation)
+	assert.NoError(t, err)
+	srv.logDirectory = filepath.Join(dir, "log")
+	srv.tickPeriod = 10 * time.Millisecond
+	srv.runFor = 1 * time.Second
+	if !assert.NoError(t, srv.setup()) {
+		return
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		srv.run()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("run did not stop")
+	}
+
+	summary := srv.summary.summary(time.Now(), false, SummaryThresholds{DegradedRatio: 0.01, FailedRatio: 0.05})
+	assert.Equal(t, VerdictPassed, summary.Verdict)
+	samples := 0
+	for _, js := range summary.Jobs {
+		samples += js.Samples
+	}
+	assert.Greater(t, samples, 0)
+
+	source, err := analysis.OpenDir(outputDir)
+	assert.NoError(t, err)
+	count := 0
+	err = source.Iterate(analysis.Filter{}, func(obs *nwpd.Observation) error {
+		count++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, samples, count, "all observations must be flushed")
+}
@@ -0,0 +1,172 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/aggregation/types"
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+)
+
+const (
+	// watchdogPeriod is the period of checking the jobs for missing runs
+	watchdogPeriod = 10 * time.Second
+	// watchdogMinThreshold is the minimum time without completed run before a job is considered stuck.
+	// It avoids false alarms for jobs with short periods whose single checks take longer (e.g. on timeouts).
+	watchdogMinThreshold = 1 * time.Minute
+)
+
+// jobHealth is the state of a job determined by the watchdog.
+type jobHealth int
+
+const (
+	// jobHealthy means the job has completed a run recently.
+	jobHealthy jobHealth = iota
+	// jobPaused means the job is deliberately not running, e.g. before the startup gate has passed,
+	// while a triggered job waits for its trigger, outside the schedule of the job or while it is suspended by the safety valve.
+	jobPaused
+	// jobStuck means the job has not completed a run within multiplier × period although it should have.
+	jobStuck
+)
+
+// jobWatchdog detects jobs which stopped producing observations, e.g. because of a deadlocked runner.
+type jobWatchdog struct {
+	multiplier float64
+
+	lock  sync.Mutex
+	stuck map[jobid]string
+}
+
+func newJobWatchdog(multiplier float64) *jobWatchdog {
+	return &jobWatchdog{
+		multiplier: multiplier,
+		stuck:      map[jobid]string{},
+	}
+}
+
+// health determines the state of the job from the scheduler state. Jobs are paused before the startup gate has passed
+// (started is false), triggered jobs while no run is active and jobs outside their schedule. A job is stuck if its current run is active for
+// longer than the threshold or if it has not completed a run within the threshold.
+func (w *jobWatchdog) health(job *runners.InternalJob, now time.Time, started bool) (jobHealth, string) {
+	if !started {
+		return jobPaused, "waiting for startup"
+	}
+	if until := job.SuspendedUntil(); until != nil && now.Before(*until) {
+		return jobPaused, "suspended by safety valve"
+	}
+	threshold := time.Duration(w.multiplier * float64(job.Period()))
+	if threshold < watchdogMinThreshold {
+		threshold = watchdogMinThreshold
+	}
+	if since, active := job.ActiveSince(); active && now.Sub(since) > threshold {
+		return jobStuck, fmt.Sprintf("run active for %s", now.Sub(since).Round(time.Second))
+	}
+	if job.Config().TriggeredBy != nil {
+		return jobPaused, "waiting for trigger"
+	}
+	if !job.InSchedule(now) {
+		return jobPaused, "outside schedule"
+	}
+	last := job.GetLastCompleted()
+	if opened := job.ScheduleOpened(); opened != nil && (last == nil || opened.After(*last)) {
+		// the time outside the schedule does not count
+		last = opened
+	}
+	if last != nil && now.Sub(*last) > threshold {
+		return jobStuck, fmt.Sprintf("no completed run for %s", now.Sub(*last).Round(time.Second))
+	}
+	return jobHealthy, ""
+}
+
+// check updates the state of all jobs and returns the jobs which became stuck or recovered since the last check.
+// Deleted jobs are dropped silently.
+func (w *jobWatchdog) check(jobs []*runners.InternalJob, now time.Time, started bool) (newlyStuck map[jobid]string, recovered []jobid) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	current := map[jobid]bool{}
+	stuck := map[jobid]string{}
+	for _, job := range jobs {
+		current[job.JobID()] = true
+		if last := job.GetLastCompleted(); last != nil {
+			ReportJobLastRun(job.JobID(), *last)
+		}
+		if health, reason := w.health(job, now, started); health == jobStuck {
+			stuck[job.JobID()] = reason
+		}
+	}
+	newlyStuck = map[jobid]string{}
+	for id, reason := range stuck {
+		if _, ok := w.stuck[id]; !ok {
+			newlyStuck[id] = reason
+		}
+	}
+	for id := range w.stuck {
+		if _, ok := stuck[id]; !ok && current[id] {
+			recovered = append(recovered, id)
+		}
+	}
+	sort.Strings(recovered)
+	w.stuck = stuck
+	return
+}
+
+// stuckJobs returns the sorted job IDs with reason of the stuck jobs.
+func (w *jobWatchdog) stuckJobs() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var result []string
+	for id, reason := range w.stuck {
+		result = append(result, fmt.Sprintf("%s (%s)", id, reason))
+	}
+	sort.Strings(result)
+	return result
+}
+
+// readyHandler serves the readiness of the agent. It is not ready as long as any job is stuck.
+func (w *jobWatchdog) readyHandler(rw http.ResponseWriter, _ *http.Request) {
+	if stuck := w.stuckJobs(); len(stuck) > 0 {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(rw, "stuck jobs: %s\n", strings.Join(stuck, ", "))
+		return
+	}
+	fmt.Fprintln(rw, "ok")
+}
+
+// checkJobs runs the watchdog for the current jobs and reports changes by log and events.
+func (s *server) checkJobs() {
+	if s.watchdog == nil {
+		return
+	}
+	s.lock.Lock()
+	jobs := make([]*runners.InternalJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	s.lock.Unlock()
+
+	newlyStuck, recovered := s.watchdog.check(jobs, time.Now(), s.started.Load())
+	for id, reason := range newlyStuck {
+		msg := fmt.Sprintf("job %s of agent %s is stuck: %s", id, runners.GetNodeName(), reason)
+		s.log.Error(msg)
+		if s.aggregator != nil {
+			s.aggregator.ReportEvent(types.Warn, "JobStuck", msg)
+		}
+	}
+	for _, id := range recovered {
+		msg := fmt.Sprintf("job %s of agent %s has recovered", id, runners.GetNodeName())
+		s.log.Info(msg)
+		if s.aggregator != nil {
+			s.aggregator.ReportEvent(types.Info, "JobRecovered", msg)
+		}
+	}
+}
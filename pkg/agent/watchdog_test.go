@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func newWatchdogTestJob(jobID string, period time.Duration, trigger *config.JobTrigger, sim *runners.SimulationConfig) *runners.InternalJob {
+	endpoints := []config.Endpoint{{Hostname: "node1", IP: "10.0.0.11", Port: 1011}}
+	rconfig := runners.RunnerConfig{Job: config.Job{JobID: jobID, TriggeredBy: trigger}, Period: period}
+	return runners.NewInternalJob(context.Background(), runners.NewSimulatedRunner(runners.NewCheckTCPPort(endpoints, rconfig), sim))
+}
+
+func TestJobWatchdogHealth(t *testing.T) {
+	w := newJobWatchdog(5)
+	now := time.Now()
+	job := newWatchdogTestJob("tcp-n2api", 30*time.Second, nil, &runners.SimulationConfig{})
+	lastCompleted := now.Add(-2 * time.Minute)
+	job.SetLastCompleted(&lastCompleted)
+
+	health, _ := w.health(job, now, false)
+	assert.Equal(t, jobPaused, health, "before startup")
+	health, _ = w.health(job, now, true)
+	assert.Equal(t, jobHealthy, health)
+	health, reason := w.health(job, now.Add(1*time.Minute), true)
+	assert.Equal(t, jobStuck, health)
+	assert.Equal(t, "no completed run for 3m0s", reason)
+
+	// the minimum threshold applies to jobs with short periods
+	short := newWatchdogTestJob("ping-n2n", 1*time.Second, nil, &runners.SimulationConfig{})
+	short.SetLastCompleted(&now)
+	health, _ = w.health(short, now.Add(30*time.Second), true)
+	assert.Equal(t, jobHealthy, health)
+	health, _ = w.health(short, now.Add(61*time.Second), true)
+	assert.Equal(t, jobStuck, health)
+
+	// triggered jobs only run if their trigger fires
+	triggered := newWatchdogTestJob("mtr-n2n", 1*time.Second, &config.JobTrigger{JobID: "ping-n2n", Condition: config.TriggerOnFailure}, &runners.SimulationConfig{})
+	triggered.SetLastCompleted(&lastCompleted)
+	health, _ = w.health(triggered, now.Add(1*time.Hour), true)
+	assert.Equal(t, jobPaused, health)
+
+	// jobs with schedule are paused outside their window and the time outside does not count
+	scheduled := newWatchdogTestJob("burst-n2n", 30*time.Second, nil, &runners.SimulationConfig{})
+	start := time.Date(2022, 8, 1, 1, 0, 0, 0, time.UTC)
+	schedule, err := (&config.JobSchedule{Start: "01:00", End: "05:00"}).Compile()
+	assert.NoError(t, err)
+	scheduled.SetSchedule(schedule)
+	lastCompleted = start.Add(-20 * time.Hour)
+	scheduled.SetLastCompleted(&lastCompleted)
+	health, reason = w.health(scheduled, start.Add(-1*time.Hour), true)
+	assert.Equal(t, jobPaused, health)
+	assert.Equal(t, "outside schedule", reason)
+	health, _ = w.health(scheduled, start.Add(1*time.Minute), true)
+	assert.Equal(t, jobHealthy, health)
+	health, _ = w.health(scheduled, start.Add(4*time.Minute), true)
+	assert.Equal(t, jobStuck, health)
+
+	// a hanging run makes any job stuck
+	slow := newWatchdogTestJob("tcp-n2n", 1*time.Second, nil, &runners.SimulationConfig{Latency: 500 * time.Millisecond})
+	ch := make(chan *nwpd.Observation, 10)
+	_, status := slow.Tick(ch)
+	assert.Equal(t, runners.TickStarted, status)
+	health, _ = w.health(slow, time.Now().Add(2*time.Minute), true)
+	assert.Equal(t, jobStuck, health)
+}
+
+func TestJobWatchdogCheck(t *testing.T) {
+	w := newJobWatchdog(2)
+	now := time.Now()
+	job1 := newWatchdogTestJob("job1", 1*time.Minute, nil, &runners.SimulationConfig{})
+	job2 := newWatchdogTestJob("job2", 1*time.Minute, nil, &runners.SimulationConfig{})
+	job1.SetLastCompleted(&now)
+	job2.SetLastCompleted(&now)
+	jobs := []*runners.InternalJob{job1, job2}
+
+	newlyStuck, recovered := w.check(jobs, now.Add(1*time.Minute), true)
+	assert.Empty(t, newlyStuck)
+	assert.Empty(t, recovered)
+
+	newlyStuck, recovered = w.check(jobs, now.Add(3*time.Minute), true)
+	assert.Equal(t, map[jobid]string{"job1": "no completed run for 3m0s", "job2": "no completed run for 3m0s"}, newlyStuck)
+	assert.Empty(t, recovered)
+	assert.Equal(t, []string{"job1 (no completed run for 3m0s)", "job2 (no completed run for 3m0s)"}, w.stuckJobs())
+
+	rec := httptest.NewRecorder()
+	w.readyHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "job1")
+
+	// job1 completes a run, job2 is deleted
+	completed := now.Add(3 * time.Minute)
+	job1.SetLastCompleted(&completed)
+	newlyStuck, recovered = w.check([]*runners.InternalJob{job1}, now.Add(4*time.Minute), true)
+	assert.Empty(t, newlyStuck)
+	assert.Equal(t, []jobid{"job1"}, recovered)
+	assert.Empty(t, w.stuckJobs())
+
+	rec = httptest.NewRecorder()
+	w.readyHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -9,16 +9,16 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"regexp"
+	"path/filepath"
 	"strings"
+	"text/tabwriter"
 	"time"
 
-	"github.com/gardener/network-problem-detector/pkg/agent/db"
+	"github.com/gardener/network-problem-detector/pkg/analysis"
 	"github.com/gardener/network-problem-detector/pkg/common"
-	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
 
 	svg "github.com/ajstarks/svgo"
-	"github.com/jamiealquiza/tachymeter"
 	"github.com/spf13/cobra"
 )
 
@@ -33,56 +33,8 @@ type aggrCommand struct {
 	jobFilter         string
 	srcFilter         string
 	destFilter        string
-
-	jobFilterPattern  *regexp.Regexp
-	srcFilterPattern  *regexp.Regexp
-	destFilterPattern *regexp.Regexp
-}
-
-type edge struct {
-	src  string
-	dest string
-}
-
-type edgeData struct {
-	jobResults map[string]*results
-}
-
-type results struct {
-	bucketsData      []*bucketData
-	lastOkMillis     int64
-	lastFailedMillis int64
-	count            int
-	cumulativeDelta  int64
-	tachy            *tachymeter.Tachymeter
-}
-
-type bucketData struct {
-	okCount            uint16
-	failedCount        uint16
-	durationCumulative time.Duration
-	minDuration        time.Duration
-	maxDuration        time.Duration
-}
-
-func (r *results) incr(bucket int, ok bool, duration time.Duration) {
-	bd := r.bucketsData[bucket]
-	if bd == nil {
-		bd = &bucketData{}
-		r.bucketsData[bucket] = bd
-	}
-	if ok {
-		bd.durationCumulative += duration
-		if bd.okCount == 0 || bd.minDuration > duration {
-			bd.minDuration = duration
-		}
-		if bd.okCount == 0 || bd.maxDuration < duration {
-			bd.maxDuration = duration
-		}
-		bd.okCount++
-	} else {
-		bd.failedCount++
-	}
+	groupBy           string
+	clusterConfig     string
 }
 
 func CreateAggregateCmd() *cobra.Command {
@@ -103,16 +55,23 @@ func CreateAggregateCmd() *cobra.Command {
 	cmd.Flags().StringVar(&ac.jobFilter, "job", "", "filter observations by job id (use '*' for globbing)")
 	cmd.Flags().StringVar(&ac.srcFilter, "src", "", "filter observations by source (use '*' for globbing)")
 	cmd.Flags().StringVar(&ac.destFilter, "dest", "", "filter observations by destination (use '*' for globbing)")
+	cmd.Flags().StringVar(&ac.groupBy, "group-by", analysis.GroupByNode, "aggregate by 'node', 'zone' or 'pool' (worker pool) of source and destination")
+	cmd.Flags().StringVar(&ac.clusterConfig, "cluster-config", "", "cluster config file with the zones and worker pools of the nodes (default: "+common.ClusterConfigFilename+" in the input directory)")
 	return cmd
 }
 
 func (ac *aggrCommand) aggr(cmd *cobra.Command, args []string) error {
-	filenames, err := db.GetAnyRecordFiles(ac.directory, true)
+	source, err := analysis.OpenDir(ac.directory)
 	if err != nil {
 		return err
 	}
 
-	if err := ac.prepareFilterExpressions(); err != nil {
+	filter, err := ac.prepareFilter()
+	if err != nil {
+		return err
+	}
+	group, err := ac.prepareGrouping()
+	if err != nil {
 		return err
 	}
 
@@ -120,14 +79,14 @@ func (ac *aggrCommand) aggr(cmd *cobra.Command, args []string) error {
 	startMillis := endMillis - int64(ac.minutes*60000)
 
 	if ac.end != "" {
-		endMillis, err = parseTimestamp(ac.end)
+		endMillis, err = analysis.ParseTimestamp(ac.end)
 		if err != nil {
 			return err
 		}
 		startMillis = endMillis - int64(ac.minutes*60000)
 	}
 	if ac.start != "" {
-		startMillis, err = parseTimestamp(ac.start)
+		startMillis, err = analysis.ParseTimestamp(ac.start)
 		if err != nil {
 			return err
 		}
@@ -143,118 +102,40 @@ func (ac *aggrCommand) aggr(cmd *cobra.Command, args []string) error {
 		time.UnixMilli(startMillis).UTC().Format("2006-01-02T15:04:05Z"),
 		time.UnixMilli(endMillis).UTC().Format("2006-01-02T15:04:05Z"),
 		ac.buckets)
-	bucketMillis := (endMillis - startMillis) / int64(ac.buckets)
-	data := map[edge]*edgeData{}
-	count := 0
-	var dataStartMillis, dataEndMillis int64
-
-	for _, filename := range filenames {
-		err := db.IterateRecordFile(filename, func(obs *nwpd.Observation) error {
-			timeMillis := obs.Timestamp.AsTime().UnixMilli()
-
-			if dataStartMillis == 0 || dataStartMillis > timeMillis {
-				dataStartMillis = timeMillis
-			}
-			if dataEndMillis < timeMillis {
-				dataEndMillis = timeMillis
-			}
-			if timeMillis < startMillis || timeMillis > endMillis {
-				return nil
-			}
-			count++
-
-			if filtered(obs.SrcHost, ac.srcFilterPattern) {
-				return nil
-			}
-			if filtered(obs.DestHost, ac.destFilterPattern) {
-				return nil
-			}
-			if filtered(obs.JobID, ac.jobFilterPattern) {
-				return nil
-			}
-
-			edge := edge{
-				src:  obs.SrcHost,
-				dest: obs.DestHost,
-			}
-			ed := data[edge]
-			if ed == nil {
-				ed = &edgeData{
-					jobResults: map[string]*results{},
-				}
-				data[edge] = ed
-			}
-			jr := ed.jobResults[obs.JobID]
-			if jr == nil {
-				jr = &results{
-					bucketsData: make([]*bucketData, ac.buckets),
-					tachy:       tachymeter.New(&tachymeter.Config{Size: 20}),
-				}
-				ed.jobResults[obs.JobID] = jr
-			}
-			aggrBucket := int((timeMillis - startMillis) * int64(ac.buckets) / (endMillis - startMillis))
-			jr.incr(aggrBucket, obs.Ok, obs.Duration.AsDuration())
-			last := jr.lastOkMillis
-			if jr.lastFailedMillis > last {
-				last = jr.lastFailedMillis
-			}
-			if last > 0 {
-				jr.cumulativeDelta += timeMillis - last
-				jr.count++
-			}
-			if obs.Ok {
-				jr.lastOkMillis = timeMillis
-				if obs.Duration != nil {
-					jr.tachy.AddTime(obs.Duration.AsDuration())
-				}
-			} else {
-				jr.lastFailedMillis = timeMillis
-			}
-			return nil
-		})
-		if err != nil {
-			return err
-		}
-	}
-	jobs := common.StringSet{}
-	srcNodes := common.StringSet{}
-	destNodes := common.StringSet{}
-	for e, ed := range data {
-		srcNodes.Add(e.src)
-		destNodes.Add(e.dest)
-		for jobID := range ed.jobResults {
-			jobs.Add(jobID)
-		}
+	filter.Start = time.UnixMilli(startMillis)
+	filter.End = time.UnixMilli(endMillis)
+	report, err := analysis.Aggregate(source, analysis.Options{
+		Filter:  *filter,
+		Buckets: ac.buckets,
+		Group:   group,
+	})
+	if err != nil {
+		return err
 	}
-	sortedJobs := jobs.ToSortedArray()
-	sortedSrcNodes := srcNodes.ToSortedArray()
-	sortedDestNodes := destNodes.ToSortedArray()
-	for _, jobID := range sortedJobs {
+
+	for _, jobID := range report.Jobs {
 		fmt.Printf("Job: %s\n", jobID)
-		for _, src := range sortedSrcNodes {
-			for _, dest := range sortedDestNodes {
-				ed := data[edge{
-					src:  src,
-					dest: dest,
-				}]
-				if ed != nil {
-					jr := ed.jobResults[jobID]
-					if jr != nil {
-						ac.printJobResultLine(src, dest, jr)
-					}
+		for _, src := range report.SrcNodes {
+			for _, dest := range report.DestNodes {
+				if jr := report.Lookup(src, dest, jobID); jr != nil {
+					ac.printJobResultLine(src, dest, jr)
 				}
 			}
 		}
+		if group != nil {
+			fmt.Printf("\n")
+			printFailureMatrix(os.Stdout, report, jobID)
+		}
 		fmt.Printf("\n")
 	}
 	if ac.openMetricsOutput != "" {
-		err = ac.writeOpenMetricsFile(sortedJobs, sortedSrcNodes, sortedDestNodes, startMillis/1000, bucketMillis, data)
+		err = ac.writeOpenMetricsFile(report)
 		if err != nil {
 			return err
 		}
 	}
 	if ac.svgOutput != "" {
-		err = ac.writeSVGFile(sortedJobs, sortedSrcNodes, sortedDestNodes, startMillis/1000, bucketMillis, data)
+		err = ac.writeSVGFile(report)
 		if err != nil {
 			return err
 		}
@@ -262,66 +143,116 @@ func (ac *aggrCommand) aggr(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func (ac *aggrCommand) prepareFilterExpressions() error {
+func (ac *aggrCommand) prepareFilter() (*analysis.Filter, error) {
 	var err error
-	if ac.jobFilterPattern, err = buildFilter(ac.jobFilter); err != nil {
-		return err
+	filter := &analysis.Filter{}
+	if filter.JobID, err = analysis.GlobMatcher(ac.jobFilter); err != nil {
+		return nil, err
 	}
-	if ac.srcFilterPattern, err = buildFilter(ac.srcFilter); err != nil {
-		return err
+	if filter.SrcHost, err = analysis.GlobMatcher(ac.srcFilter); err != nil {
+		return nil, err
 	}
-	if ac.destFilterPattern, err = buildFilter(ac.destFilter); err != nil {
-		return err
+	if filter.DestHost, err = analysis.GlobMatcher(ac.destFilter); err != nil {
+		return nil, err
 	}
-	return nil
+	return filter, nil
+}
+
+// prepareGrouping returns the grouping of the hosts for option --group-by. The cluster config is
+// loaded from the input directory as stored by the collect command if not specified explicitly.
+func (ac *aggrCommand) prepareGrouping() (analysis.Grouping, error) {
+	if ac.groupBy == analysis.GroupByNode {
+		return nil, nil
+	}
+	filename := ac.clusterConfig
+	if filename == "" {
+		filename = filepath.Join(ac.directory, common.ClusterConfigFilename)
+	}
+	cfg, err := config.LoadClusterConfig(filename)
+	if err != nil {
+		return nil, fmt.Errorf("loading cluster config for grouping by %s failed: %w", ac.groupBy, err)
+	}
+	return analysis.NodeGrouping(cfg, ac.groupBy)
+}
+
+// printFailureMatrix prints the ratio of failed observations of the job with source groups as rows and destination groups as columns.
+func printFailureMatrix(w io.Writer, report *analysis.Report, jobID string) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "src \\ dest")
+	for _, dest := range report.DestNodes {
+		fmt.Fprintf(tw, "\t%s", dest)
+	}
+	fmt.Fprintln(tw)
+	for _, src := range report.SrcNodes {
+		fmt.Fprint(tw, src)
+		for _, dest := range report.DestNodes {
+			cell := "-"
+			if jr := report.Lookup(src, dest, jobID); jr != nil {
+				failed := jr.FailedCount()
+				if total := failed + jr.OkCount(); total > 0 {
+					cell = fmt.Sprintf("%.1f%% (%d/%d)", 100*float64(failed)/float64(total), failed, total)
+				}
+			}
+			fmt.Fprintf(tw, "\t%s", cell)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// edgeLabels returns the labels of source and destination for the Open Metrics output.
+func (ac *aggrCommand) edgeLabels(src, dest string) string {
+	if ac.groupBy == analysis.GroupByNode {
+		return fmt.Sprintf("src=%q,dest=%q", src, dest)
+	}
+	return fmt.Sprintf("src_%s=%q,dest_%s=%q", ac.groupBy, src, ac.groupBy, dest)
 }
 
-func (ac *aggrCommand) printJobResultLine(src, dest string, jr *results) {
+func (ac *aggrCommand) printJobResultLine(src, dest string, jr *analysis.Results) {
 	var sb strings.Builder
-	for i := 0; i < ac.buckets; i++ {
-		bd := jr.bucketsData[i]
-		if bd == nil || (bd.okCount == 0 && bd.failedCount == 0) {
+	for _, bd := range jr.Buckets {
+		if bd == nil || (bd.OkCount == 0 && bd.FailedCount == 0) {
 			sb.WriteString(" ")
-		} else if bd.failedCount == 0 {
+		} else if bd.FailedCount == 0 {
 			sb.WriteString(".")
-		} else if bd.okCount < bd.failedCount {
+		} else if bd.OkCount < bd.FailedCount {
 			sb.WriteString("E")
 		} else {
 			sb.WriteString("e")
 		}
 	}
 	latence := ""
-	if jr.count > 0 {
-		metrics := jr.tachy.Calc()
-		if metrics.Time.P95.Milliseconds() > 0 {
-			latence = fmt.Sprintf(" (period=%.1f s, min/mean/p95=%d/%d/%d ms)", float64(jr.cumulativeDelta)/float64(jr.count)/1000,
-				metrics.Time.Min.Milliseconds(), metrics.Time.HMean.Milliseconds(), metrics.Time.P95.Milliseconds())
+	if jr.Count > 0 {
+		latency := jr.Latency()
+		if latency.P95.Milliseconds() > 0 {
+			latence = fmt.Sprintf(" (period=%.1f s, min/mean/p95=%d/%d/%d ms)", jr.MeanPeriod().Seconds(),
+				latency.Min.Milliseconds(), latency.HMean.Milliseconds(), latency.P95.Milliseconds())
 		} else {
-			latence = fmt.Sprintf(" (period=%.1f s)", float64(jr.cumulativeDelta)/float64(jr.count)/1000)
+			latence = fmt.Sprintf(" (period=%.1f s)", jr.MeanPeriod().Seconds())
 		}
 	}
 	fmt.Printf("%s -> %s: %s%s\n", src, dest, sb.String(), latence)
 }
 
-func (ac *aggrCommand) writeOpenMetricsFile(jobs, srcNodes, destNodes []string, startUnixSecs, bucketMillis int64, data map[edge]*edgeData) error {
+func (ac *aggrCommand) writeOpenMetricsFile(report *analysis.Report) error {
 	f, err := os.OpenFile(ac.openMetricsOutput, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 	err = ac.writeMetrics(f, "nwpd_aggregation_counter", "gauge",
-		"The number of ok or failed checks per aggregated bucket with labels source, destination, jobID, success.",
-		jobs, srcNodes, destNodes, data, startUnixSecs, bucketMillis,
-		func(w io.StringWriter, name, src, dest, jobID string, bd *bucketData, t int64) error {
-			if bd.okCount > 0 {
-				if _, err := w.WriteString(fmt.Sprintf("%s{src=%q,dest=%q,job=%q,status=\"ok\"} %d %d\n",
-					name, src, dest, jobID, bd.okCount, t)); err != nil {
+		"The number of ok or failed checks per aggregated bucket with labels source, destination (or their zones or worker pools), jobID, success.",
+		report,
+		func(w io.StringWriter, name, edge, jobID string, bd *analysis.Bucket, t int64) error {
+			if bd.OkCount > 0 {
+				if _, err := w.WriteString(fmt.Sprintf("%s{%s,job=%q,status=\"ok\"} %d %d\n",
+					name, edge, jobID, bd.OkCount, t)); err != nil {
 					return err
 				}
 			}
-			if bd.failedCount > 0 {
-				if _, err := w.WriteString(fmt.Sprintf("%s{src=%q,dest=%q,job=%q,status=\"failed\"} %d %d\n",
-					name, src, dest, jobID, bd.failedCount, t)); err != nil {
+			if bd.FailedCount > 0 {
+				if _, err := w.WriteString(fmt.Sprintf("%s{%s,job=%q,status=\"failed\"} %d %d\n",
+					name, edge, jobID, bd.FailedCount, t)); err != nil {
 					return err
 				}
 			}
@@ -331,21 +262,21 @@ func (ac *aggrCommand) writeOpenMetricsFile(jobs, srcNodes, destNodes []string,
 		return err
 	}
 	err = ac.writeMetrics(f, "nwpd_aggregation_latence_ms", "gauge",
-		"The mean latence per aggregated bucket with labels source, destination, jobID.",
-		jobs, srcNodes, destNodes, data, startUnixSecs, bucketMillis,
-		func(w io.StringWriter, name, src, dest, jobID string, bd *bucketData, t int64) error {
-			if bd.okCount > 0 {
-				d := (bd.durationCumulative / time.Duration(bd.okCount)).Milliseconds()
-				if _, err := w.WriteString(fmt.Sprintf("%s{src=%q,dest=%q,job=%q,value=\"mean\"} %d %d\n",
-					name, src, dest, jobID, d, t)); err != nil {
+		"The mean latence per aggregated bucket with labels source, destination (or their zones or worker pools), jobID.",
+		report,
+		func(w io.StringWriter, name, edge, jobID string, bd *analysis.Bucket, t int64) error {
+			if bd.OkCount > 0 {
+				d := (bd.DurationCumulative / time.Duration(bd.OkCount)).Milliseconds()
+				if _, err := w.WriteString(fmt.Sprintf("%s{%s,job=%q,value=\"mean\"} %d %d\n",
+					name, edge, jobID, d, t)); err != nil {
 					return err
 				}
-				if _, err := w.WriteString(fmt.Sprintf("%s{src=%q,dest=%q,job=%q,value=\"min\"} %d %d\n",
-					name, src, dest, jobID, bd.minDuration.Milliseconds(), t)); err != nil {
+				if _, err := w.WriteString(fmt.Sprintf("%s{%s,job=%q,value=\"min\"} %d %d\n",
+					name, edge, jobID, bd.MinDuration.Milliseconds(), t)); err != nil {
 					return err
 				}
-				if _, err := w.WriteString(fmt.Sprintf("%s{src=%q,dest=%q,job=%q,value=\"max\"} %d %d\n",
-					name, src, dest, jobID, bd.maxDuration.Milliseconds(), t)); err != nil {
+				if _, err := w.WriteString(fmt.Sprintf("%s{%s,job=%q,value=\"max\"} %d %d\n",
+					name, edge, jobID, bd.MaxDuration.Milliseconds(), t)); err != nil {
 					return err
 				}
 			}
@@ -358,9 +289,8 @@ func (ac *aggrCommand) writeOpenMetricsFile(jobs, srcNodes, destNodes []string,
 	return err
 }
 
-func (ac *aggrCommand) writeMetrics(f *os.File, name, metricsType, description string,
-	jobs, srcNodes, destNodes []string, data map[edge]*edgeData, startUnixSecs, bucketMillis int64,
-	linePrinter func(w io.StringWriter, name, src, dest, jobId string, bd *bucketData, t int64) error) error {
+func (ac *aggrCommand) writeMetrics(f *os.File, name, metricsType, description string, report *analysis.Report,
+	linePrinter func(w io.StringWriter, name, edge, jobId string, bd *analysis.Bucket, t int64) error) error {
 	var err error
 	_, err = f.WriteString(fmt.Sprintf("# HELP %s %s\n", name, description))
 	if err != nil {
@@ -370,25 +300,20 @@ func (ac *aggrCommand) writeMetrics(f *os.File, name, metricsType, description s
 	if err != nil {
 		return err
 	}
-	for _, src := range srcNodes {
-		for _, dest := range destNodes {
-			ed := data[edge{
-				src:  src,
-				dest: dest,
-			}]
-			if ed == nil {
-				continue
-			}
-			for _, jobID := range jobs {
-				jr := ed.jobResults[jobID]
-				if jr != nil {
-					for i := 0; i < ac.buckets; i++ {
-						bd := jr.bucketsData[i]
-						if bd != nil {
-							t := startUnixSecs + (bucketMillis*int64(i)+bucketMillis/2)/1000
-							if err := linePrinter(f, name, src, dest, jobID, bd, t); err != nil {
-								return err
-							}
+	startUnixSecs := report.Start.Unix()
+	bucketMillis := report.BucketDuration.Milliseconds()
+	for _, src := range report.SrcNodes {
+		for _, dest := range report.DestNodes {
+			for _, jobID := range report.Jobs {
+				jr := report.Lookup(src, dest, jobID)
+				if jr == nil {
+					continue
+				}
+				for i, bd := range jr.Buckets {
+					if bd != nil {
+						t := startUnixSecs + (bucketMillis*int64(i)+bucketMillis/2)/1000
+						if err := linePrinter(f, name, ac.edgeLabels(src, dest), jobID, bd, t); err != nil {
+							return err
 						}
 					}
 				}
@@ -398,7 +323,7 @@ func (ac *aggrCommand) writeMetrics(f *os.File, name, metricsType, description s
 	return nil
 }
 
-func (ac *aggrCommand) writeSVGFile(jobs, srcNodes, destNodes []string, startUnixSecs, bucketMillis int64, data map[edge]*edgeData) error {
+func (ac *aggrCommand) writeSVGFile(report *analysis.Report) error {
 	f, err := os.OpenFile(ac.svgOutput, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
 	if err != nil {
 		return err
@@ -422,9 +347,11 @@ func (ac *aggrCommand) writeSVGFile(jobs, srcNodes, destNodes []string, startUni
 		}
 	}
 
+	srcNodes := report.SrcNodes
+	destNodes := report.DestNodes
 	top := 300
 	left := 300
-	bucketWidth := ac.buckets + 2
+	bucketWidth := report.Buckets + 2
 	height := top + 10*len(srcNodes)
 	width := left + bucketWidth*len(destNodes)
 	canvas := svg.New(f)
@@ -439,25 +366,25 @@ func (ac *aggrCommand) writeSVGFile(jobs, srcNodes, destNodes []string, startUni
 		canvas.Text(left-5, y0+9, src, "text-anchor:end;font-size:10px;font-family:sans-serif;")
 		canvas.Line(0, y0, width, y0, "stroke:black")
 		for id, dest := range destNodes {
-			ed := data[edge{
-				src:  src,
-				dest: dest,
+			ed := report.Edges[analysis.Edge{
+				Src:  src,
+				Dest: dest,
 			}]
 			if ed == nil {
 				continue
 			}
-			for i := 0; i < ac.buckets; i++ {
+			for i := 0; i < report.Buckets; i++ {
 				x := left + id*bucketWidth + i + 1
 				badJobs := common.StringSet{}
 				var okCount, failedCount int
-				for _, jobID := range jobs {
-					jr := ed.jobResults[jobID]
+				for _, jobID := range report.Jobs {
+					jr := ed.JobResults[jobID]
 					if jr != nil {
-						bd := jr.bucketsData[i]
+						bd := jr.Buckets[i]
 						if bd != nil {
-							okCount += int(bd.okCount)
-							failedCount += int(bd.failedCount)
-							if bd.failedCount > 0 {
+							okCount += int(bd.OkCount)
+							failedCount += int(bd.FailedCount)
+							if bd.FailedCount > 0 {
 								badJobs.Add(jobID)
 							}
 						}
@@ -484,49 +411,3 @@ func (ac *aggrCommand) writeSVGFile(jobs, srcNodes, destNodes []string, startUni
 	_, err = f.WriteString("</body>\n</html>\n")
 	return err
 }
-
-// parseTimestamp parses a timestamp and returns time in Unix mills
-func parseTimestamp(value string) (int64, error) {
-	location := time.Now().Location()
-	input := value
-	if len(value) < 15 {
-		input = time.Now().Format("2006-01-02T") + value
-	}
-	supportedLayouts := []string{
-		"2006-01-02T15:04:05Z07:00",
-		"2006-01-02T15:04:05Z07",
-		"2006-01-02T15:04:05-07:00",
-		"2006-01-02T15:04:05-07",
-		"2006-01-02T15:04:05MST",
-		"2006-01-02T15:04:05",
-		"2006-01-02T15:04Z07:00",
-		"2006-01-02T15:04Z07",
-		"2006-01-02T15:04-07:00",
-		"2006-01-02T15:04-07",
-		"2006-01-02T15:04MST",
-		"2006-01-02T15:04",
-	}
-	for _, layout := range supportedLayouts {
-		t, err := time.ParseInLocation(layout, input, location)
-		if err == nil {
-			return t.Local().UnixMilli(), nil
-		}
-	}
-	supported := strings.Join(supportedLayouts, ", ")
-	supported2 := strings.ReplaceAll(supported, "2006-01-02T", "")
-	return 0, fmt.Errorf("invalid time stamp format: %s (Supported formats are: %s, %s)", value, supported, supported2)
-}
-
-func buildFilter(filter string) (*regexp.Regexp, error) {
-	if filter == "" {
-		return nil, nil
-	}
-	return regexp.Compile("^" + strings.ReplaceAll(filter, "*", ".*") + "$")
-}
-
-func filtered(value string, pattern *regexp.Regexp) bool {
-	if pattern == nil {
-		return false
-	}
-	return !pattern.MatchString(value)
-}
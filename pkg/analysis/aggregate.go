@@ -0,0 +1,296 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package analysis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+
+	"github.com/jamiealquiza/tachymeter"
+)
+
+// Options are the options for aggregating observations.
+type Options struct {
+	// Filter restricts the observations to aggregate. Filter.Start and Filter.End are mandatory.
+	Filter Filter
+	// Buckets is the number of histogram buckets the time range is divided into.
+	Buckets int
+	// Group maps the source and destination hosts to groups, e.g. to the worker pools of the nodes.
+	// The observations are aggregated per host if nil.
+	Group Grouping
+}
+
+// Edge is a source/destination pair.
+type Edge struct {
+	Src  string
+	Dest string
+}
+
+// EdgeData contains the aggregated results of an edge per job ID.
+type EdgeData struct {
+	JobResults map[string]*Results
+}
+
+// Results contains the aggregated observations of a job on an edge.
+type Results struct {
+	// Buckets are the histogram buckets. An entry is nil if there are no observations in the bucket.
+	Buckets []*Bucket
+	// LastOkMillis is the Unix time in milliseconds of the last successful observation.
+	LastOkMillis int64
+	// LastFailedMillis is the Unix time in milliseconds of the last failed observation.
+	LastFailedMillis int64
+	// Count is the number of time deltas between consecutive observations.
+	Count int
+	// CumulativeDeltaMillis is the sum of the time deltas between consecutive observations.
+	CumulativeDeltaMillis int64
+
+	tachy *tachymeter.Tachymeter
+}
+
+// Bucket contains the counts and durations of a histogram bucket.
+type Bucket struct {
+	OkCount            uint16
+	FailedCount        uint16
+	DurationCumulative time.Duration
+	MinDuration        time.Duration
+	MaxDuration        time.Duration
+}
+
+// Latency contains statistics of the durations of successful observations.
+type Latency struct {
+	Min   time.Duration
+	HMean time.Duration
+	P95   time.Duration
+}
+
+// Report is the result of an aggregation.
+type Report struct {
+	// Start is the start of the aggregated time range.
+	Start time.Time
+	// End is the end of the aggregated time range.
+	End time.Time
+	// BucketDuration is the time range covered by a bucket.
+	BucketDuration time.Duration
+	// Buckets is the number of histogram buckets.
+	Buckets int
+	// Jobs are the sorted job IDs.
+	Jobs []string
+	// SrcNodes are the sorted source hosts (or groups if aggregated with Options.Group).
+	SrcNodes []string
+	// DestNodes are the sorted destination hosts (or groups if aggregated with Options.Group).
+	DestNodes []string
+	// Edges contains the results for all edges with observations.
+	Edges map[Edge]*EdgeData
+}
+
+// Lookup returns the results for the given edge and job ID or nil.
+func (r *Report) Lookup(src, dest, jobID string) *Results {
+	ed := r.Edges[Edge{Src: src, Dest: dest}]
+	if ed == nil {
+		return nil
+	}
+	return ed.JobResults[jobID]
+}
+
+// MeanPeriod returns the mean time between consecutive observations.
+func (r *Results) MeanPeriod() time.Duration {
+	if r.Count == 0 {
+		return 0
+	}
+	return time.Duration(r.CumulativeDeltaMillis/int64(r.Count)) * time.Millisecond
+}
+
+// OkCount returns the number of successful observations.
+func (r *Results) OkCount() int {
+	count := 0
+	for _, bd := range r.Buckets {
+		if bd != nil {
+			count += int(bd.OkCount)
+		}
+	}
+	return count
+}
+
+// FailedCount returns the number of failed observations.
+func (r *Results) FailedCount() int {
+	count := 0
+	for _, bd := range r.Buckets {
+		if bd != nil {
+			count += int(bd.FailedCount)
+		}
+	}
+	return count
+}
+
+// Latency returns the latency statistics of the last successful observations.
+func (r *Results) Latency() Latency {
+	metrics := r.tachy.Calc()
+	return Latency{
+		Min:   metrics.Time.Min,
+		HMean: metrics.Time.HMean,
+		P95:   metrics.Time.P95,
+	}
+}
+
+func (r *Results) incr(bucket int, ok bool, duration time.Duration) {
+	bd := r.Buckets[bucket]
+	if bd == nil {
+		bd = &Bucket{}
+		r.Buckets[bucket] = bd
+	}
+	if ok {
+		bd.DurationCumulative += duration
+		if bd.OkCount == 0 || bd.MinDuration > duration {
+			bd.MinDuration = duration
+		}
+		if bd.OkCount == 0 || bd.MaxDuration < duration {
+			bd.MaxDuration = duration
+		}
+		bd.OkCount++
+	} else {
+		bd.FailedCount++
+	}
+}
+
+func (r *Results) add(obs *nwpd.Observation, bucket int) {
+	timeMillis := obs.Timestamp.AsTime().UnixMilli()
+	r.incr(bucket, obs.Ok, obs.Duration.AsDuration())
+	last := r.LastOkMillis
+	if r.LastFailedMillis > last {
+		last = r.LastFailedMillis
+	}
+	if last > 0 {
+		r.CumulativeDeltaMillis += timeMillis - last
+		r.Count++
+	}
+	if obs.Ok {
+		r.LastOkMillis = timeMillis
+		if obs.Duration != nil {
+			r.tachy.AddTime(obs.Duration.AsDuration())
+		}
+	} else {
+		r.LastFailedMillis = timeMillis
+	}
+}
+
+// Aggregator aggregates observations incrementally.
+type Aggregator struct {
+	options     Options
+	startMillis int64
+	endMillis   int64
+	report      *Report
+}
+
+// NewAggregator creates an aggregator for observations in the time range of the filter.
+func NewAggregator(options Options) (*Aggregator, error) {
+	if options.Filter.Start.IsZero() || options.Filter.End.IsZero() {
+		return nil, fmt.Errorf("missing start or end of time range")
+	}
+	if !options.Filter.Start.Before(options.Filter.End) {
+		return nil, fmt.Errorf("invalid time range")
+	}
+	if options.Buckets <= 0 {
+		return nil, fmt.Errorf("invalid number of buckets: %d", options.Buckets)
+	}
+
+	startMillis := options.Filter.Start.UnixMilli()
+	endMillis := options.Filter.End.UnixMilli()
+	return &Aggregator{
+		options:     options,
+		startMillis: startMillis,
+		endMillis:   endMillis,
+		report: &Report{
+			Start:          options.Filter.Start,
+			End:            options.Filter.End,
+			BucketDuration: time.Duration((endMillis-startMillis)/int64(options.Buckets)) * time.Millisecond,
+			Buckets:        options.Buckets,
+			Edges:          map[Edge]*EdgeData{},
+		},
+	}, nil
+}
+
+// Add adds the observation if it matches the filter.
+func (a *Aggregator) Add(obs *nwpd.Observation) {
+	if !a.options.Filter.Matches(obs) {
+		return
+	}
+	a.add(obs)
+}
+
+func (a *Aggregator) add(obs *nwpd.Observation) {
+	if obs.WarmingUp {
+		// the destination pod has been started recently, so that the observation is neutral
+		return
+	}
+	edge := Edge{
+		Src:  obs.SrcHost,
+		Dest: obs.DestHost,
+	}
+	if a.options.Group != nil {
+		edge = Edge{
+			Src:  a.options.Group(obs.SrcHost),
+			Dest: a.options.Group(obs.DestHost),
+		}
+	}
+	ed := a.report.Edges[edge]
+	if ed == nil {
+		ed = &EdgeData{
+			JobResults: map[string]*Results{},
+		}
+		a.report.Edges[edge] = ed
+	}
+	jr := ed.JobResults[obs.JobID]
+	if jr == nil {
+		jr = &Results{
+			Buckets: make([]*Bucket, a.options.Buckets),
+			tachy:   tachymeter.New(&tachymeter.Config{Size: 20}),
+		}
+		ed.JobResults[obs.JobID] = jr
+	}
+	timeMillis := obs.Timestamp.AsTime().UnixMilli()
+	bucket := int((timeMillis - a.startMillis) * int64(a.options.Buckets) / (a.endMillis - a.startMillis))
+	if bucket >= a.options.Buckets {
+		bucket = a.options.Buckets - 1
+	}
+	jr.add(obs, bucket)
+}
+
+// Aggregate aggregates the observations of the source into histogram buckets per edge and job ID.
+func Aggregate(source Source, options Options) (*Report, error) {
+	aggregator, err := NewAggregator(options)
+	if err != nil {
+		return nil, err
+	}
+	err = source.Iterate(options.Filter, func(obs *nwpd.Observation) error {
+		aggregator.add(obs)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return aggregator.Report(), nil
+}
+
+// Report returns the report of the observations added so far.
+func (a *Aggregator) Report() *Report {
+	report := a.report
+	jobs := common.StringSet{}
+	srcNodes := common.StringSet{}
+	destNodes := common.StringSet{}
+	for e, ed := range report.Edges {
+		srcNodes.Add(e.Src)
+		destNodes.Add(e.Dest)
+		for jobID := range ed.JobResults {
+			jobs.Add(jobID)
+		}
+	}
+	report.Jobs = jobs.ToSortedArray()
+	report.SrcNodes = srcNodes.ToSortedArray()
+	report.DestNodes = destNodes.ToSortedArray()
+	return report
+}
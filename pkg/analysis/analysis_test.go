@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package analysis
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/db"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// The fixture in testdata contains observations of the jobs 'tcp-n2n' and 'ping-n2n' between node1 and node2
+// for 10 minutes starting at 2022-08-01T10:00:00Z. The last three pings from node2 to node1 failed.
+var fixtureStart = time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+
+func countObservations(t *testing.T, source Source, filter Filter) int {
+	count := 0
+	err := source.Iterate(filter, func(obs *nwpd.Observation) error {
+		count++
+		return nil
+	})
+	assert.NoError(t, err)
+	return count
+}
+
+func TestOpenDir(t *testing.T) {
+	source, err := OpenDir("testdata")
+	assert.NoError(t, err)
+	assert.Len(t, source.Filenames(), 2)
+}
+
+func TestIterateWithFilter(t *testing.T) {
+	source, err := OpenDir("testdata")
+	assert.NoError(t, err)
+
+	pingJobs, err := GlobMatcher("ping-*")
+	assert.NoError(t, err)
+
+	for _, testCase := range []struct {
+		name     string
+		filter   Filter
+		expected int
+	}{
+		{name: "all", filter: Filter{}, expected: 80},
+		{name: "failures only", filter: Filter{FailuresOnly: true}, expected: 3},
+		{name: "job glob", filter: Filter{JobID: pingJobs}, expected: 40},
+		{name: "exact source", filter: Filter{SrcHost: ExactMatcher("node2")}, expected: 40},
+		{name: "substring destination", filter: Filter{DestHost: SubstringMatcher("1")}, expected: 40},
+		{name: "time range", filter: Filter{Start: fixtureStart.Add(5 * time.Minute), End: fixtureStart.Add(7 * time.Minute)}, expected: 24},
+	} {
+		assert.Equal(t, testCase.expected, countObservations(t, source, testCase.filter), testCase.name)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	expected := []*nwpd.Observation{
+		{
+			JobID:     "job1",
+			SrcHost:   "src",
+			DestHost:  "dest1",
+			Timestamp: timestamppb.New(fixtureStart),
+			Duration:  durationpb.New(15 * time.Millisecond),
+			Ok:        true,
+			Period:    durationpb.New(10 * time.Second),
+		},
+		{
+			JobID:                 "job2",
+			SrcHost:               "src",
+			DestHost:              "dest2",
+			Timestamp:             timestamppb.New(fixtureStart.Add(1500 * time.Millisecond)),
+			Duration:              durationpb.New(30 * time.Second),
+			Ok:                    false,
+			Period:                durationpb.New(1 * time.Minute),
+			TriggeredBy:           nwpd.ObservationID("job1", "src", "dest2", fixtureStart.UnixMilli()),
+			PreviousStateDuration: durationpb.New(5 * time.Minute),
+		},
+	}
+	assert.NoError(t, db.WriteRecordFile(filepath.Join(dir, "test-2022-08-01-10.records"), expected))
+
+	source, err := OpenDir(dir)
+	assert.NoError(t, err)
+	var actual []*nwpd.Observation
+	err = source.Iterate(Filter{}, func(obs *nwpd.Observation) error {
+		actual = append(actual, obs)
+		return nil
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, actual, len(expected)) {
+		for i := range expected {
+			assert.Equal(t, expected[i].String(), actual[i].String())
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	source, err := OpenDir("testdata")
+	assert.NoError(t, err)
+
+	_, err = Aggregate(source, Options{Buckets: 10})
+	assert.Error(t, err, "missing time range")
+
+	report, err := Aggregate(source, Options{
+		Filter: Filter{
+			Start: fixtureStart,
+			End:   fixtureStart.Add(10 * time.Minute),
+		},
+		Buckets: 10,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping-n2n", "tcp-n2n"}, report.Jobs)
+	assert.Equal(t, []string{"node1", "node2"}, report.SrcNodes)
+	assert.Equal(t, []string{"node1", "node2"}, report.DestNodes)
+	assert.Equal(t, 1*time.Minute, report.BucketDuration)
+	assert.Len(t, report.Edges, 4)
+
+	jr := report.Lookup("node2", "node1", "ping-n2n")
+	if assert.NotNil(t, jr) {
+		assert.Equal(t, 1*time.Minute, jr.MeanPeriod())
+		for i, bd := range jr.Buckets {
+			failed := i >= 7
+			assert.Equal(t, !failed, bd.OkCount == 1, "bucket %d", i)
+			assert.Equal(t, failed, bd.FailedCount == 1, "bucket %d", i)
+		}
+	}
+	jr = report.Lookup("node1", "node2", "tcp-n2n")
+	if assert.NotNil(t, jr) {
+		assert.Equal(t, 10*time.Millisecond, jr.Latency().Min)
+		assert.Equal(t, 10*time.Millisecond, jr.Buckets[0].MinDuration)
+		assert.Equal(t, 19*time.Millisecond, jr.Buckets[9].MaxDuration)
+	}
+	assert.Nil(t, report.Lookup("node1", "node2", "unknown"))
+}
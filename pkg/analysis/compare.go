@@ -0,0 +1,210 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// ChangeType classifies the change of the success rate between two snapshots.
+type ChangeType string
+
+const (
+	// ChangeRegression means the success rate dropped by at least the threshold.
+	ChangeRegression ChangeType = "regression"
+	// ChangeImprovement means the success rate increased by at least the threshold.
+	ChangeImprovement ChangeType = "improvement"
+	// ChangeUnchanged means the success rate changed by less than the threshold.
+	ChangeUnchanged ChangeType = "unchanged"
+	// ChangeNew means there are only observations in the after snapshot.
+	ChangeNew ChangeType = "new"
+	// ChangeRemoved means there are only observations in the before snapshot.
+	ChangeRemoved ChangeType = "removed"
+)
+
+// Rate contains the counts and the success rate of the observations of a snapshot.
+type Rate struct {
+	OkCount     int     `json:"okCount"`
+	FailedCount int     `json:"failedCount"`
+	SuccessRate float64 `json:"successRate"`
+	// MeanLatencyMillis is the harmonic mean of the durations of the last successful observations.
+	MeanLatencyMillis int64 `json:"meanLatencyMillis,omitempty"`
+}
+
+// Comparison is the comparison of an edge or a destination of a job between two snapshots.
+type Comparison struct {
+	JobID string `json:"jobID"`
+	// Src is the source host. It is empty for the comparison of a destination over all sources.
+	Src    string     `json:"src,omitempty"`
+	Dest   string     `json:"dest"`
+	Before *Rate      `json:"before,omitempty"`
+	After  *Rate      `json:"after,omitempty"`
+	Change ChangeType `json:"change"`
+	// Delta is the change of the success rate.
+	Delta float64 `json:"delta"`
+}
+
+// ComparisonReport is the result of comparing two snapshots.
+type ComparisonReport struct {
+	// Threshold is the minimum change of the success rate to classify as regression or improvement.
+	Threshold float64 `json:"threshold"`
+	// Edges are the comparisons per job and edge sorted by job ID, source and destination host.
+	Edges []Comparison `json:"edges"`
+	// Destinations are the comparisons per job and destination host over all sources.
+	Destinations []Comparison `json:"destinations"`
+}
+
+// Count returns the number of edge comparisons with the given change type.
+func (r *ComparisonReport) Count(change ChangeType) int {
+	count := 0
+	for _, c := range r.Edges {
+		if c.Change == change {
+			count++
+		}
+	}
+	return count
+}
+
+// TimeRange returns the time range of the observations of the source matching the filter.
+func TimeRange(source Source, filter Filter) (start, end time.Time, err error) {
+	err = source.Iterate(filter, func(obs *nwpd.Observation) error {
+		t := obs.Timestamp.AsTime()
+		if start.IsZero() || t.Before(start) {
+			start = t
+		}
+		if end.IsZero() || t.After(end) {
+			end = t
+		}
+		return nil
+	})
+	return
+}
+
+// AggregateSnapshot aggregates all observations of the source matching the filter with one bucket per minute.
+func AggregateSnapshot(source Source, filter Filter) (*Report, error) {
+	start, end, err := TimeRange(source, filter)
+	if err != nil {
+		return nil, err
+	}
+	if start.IsZero() {
+		return nil, fmt.Errorf("no observations found")
+	}
+	// small buckets, as the counts of a bucket are limited to 16 bit
+	end = end.Add(1 * time.Second)
+	filter.Start = start
+	filter.End = end
+	return Aggregate(source, Options{
+		Filter:  filter,
+		Buckets: int(end.Sub(start)/time.Minute) + 1,
+	})
+}
+
+type comparisonKey struct {
+	jobID string
+	src   string
+	dest  string
+}
+
+type rateCounter map[comparisonKey]*Rate
+
+func (c rateCounter) add(key comparisonKey, jr *Results) {
+	rate := c[key]
+	if rate == nil {
+		rate = &Rate{}
+		c[key] = rate
+	}
+	rate.OkCount += jr.OkCount()
+	rate.FailedCount += jr.FailedCount()
+	if key.src != "" && rate.OkCount > 0 {
+		// latencies are only available per edge
+		rate.MeanLatencyMillis = jr.Latency().HMean.Milliseconds()
+	}
+}
+
+func countRates(report *Report) (edges, destinations rateCounter) {
+	edges = rateCounter{}
+	destinations = rateCounter{}
+	for edge, ed := range report.Edges {
+		for jobID, jr := range ed.JobResults {
+			edges.add(comparisonKey{jobID: jobID, src: edge.Src, dest: edge.Dest}, jr)
+			destinations.add(comparisonKey{jobID: jobID, dest: edge.Dest}, jr)
+		}
+	}
+	return
+}
+
+// Compare compares the success rates per edge and per destination of two aggregated snapshots.
+func Compare(before, after *Report, threshold float64) *ComparisonReport {
+	beforeEdges, beforeDestinations := countRates(before)
+	afterEdges, afterDestinations := countRates(after)
+	return &ComparisonReport{
+		Threshold:    threshold,
+		Edges:        compareRates(beforeEdges, afterEdges, threshold),
+		Destinations: compareRates(beforeDestinations, afterDestinations, threshold),
+	}
+}
+
+func compareRates(before, after rateCounter, threshold float64) []Comparison {
+	keys := map[comparisonKey]struct{}{}
+	for key := range before {
+		keys[key] = struct{}{}
+	}
+	for key := range after {
+		keys[key] = struct{}{}
+	}
+
+	result := []Comparison{}
+	for key := range keys {
+		c := Comparison{
+			JobID:  key.jobID,
+			Src:    key.src,
+			Dest:   key.dest,
+			Before: withSuccessRate(before[key]),
+			After:  withSuccessRate(after[key]),
+		}
+		switch {
+		case c.Before == nil:
+			c.Change = ChangeNew
+		case c.After == nil:
+			c.Change = ChangeRemoved
+		default:
+			c.Delta = c.After.SuccessRate - c.Before.SuccessRate
+			switch {
+			case c.Delta < 0 && c.Delta <= -threshold:
+				c.Change = ChangeRegression
+			case c.Delta > 0 && c.Delta >= threshold:
+				c.Change = ChangeImprovement
+			default:
+				c.Change = ChangeUnchanged
+			}
+		}
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.JobID != b.JobID {
+			return a.JobID < b.JobID
+		}
+		if a.Src != b.Src {
+			return a.Src < b.Src
+		}
+		return a.Dest < b.Dest
+	})
+	return result
+}
+
+func withSuccessRate(rate *Rate) *Rate {
+	if rate == nil {
+		return nil
+	}
+	if total := rate.OkCount + rate.FailedCount; total > 0 {
+		rate.SuccessRate = float64(rate.OkCount) / float64(total)
+	}
+	return rate
+}
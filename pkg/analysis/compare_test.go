@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package analysis
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/db"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestCompare(t *testing.T) {
+	before, err := OpenDir("testdata")
+	assert.NoError(t, err)
+	beforeReport, err := AggregateSnapshot(before, Filter{})
+	assert.NoError(t, err)
+	assert.Equal(t, fixtureStart, beforeReport.Start)
+
+	// after: the pings from node2 to node1 work again, the TCP checks from node1 to node2 fail half of the time
+	// and the TCP checks from node2 to node2 are dropped
+	start := fixtureStart.Add(1 * time.Hour)
+	var observations []*nwpd.Observation
+	for i := 0; i < 10; i++ {
+		timestamp := timestamppb.New(start.Add(time.Duration(i) * time.Minute))
+		for _, edge := range []Edge{{"node1", "node1"}, {"node1", "node2"}, {"node2", "node1"}, {"node2", "node2"}} {
+			observations = append(observations, &nwpd.Observation{
+				JobID: "ping-n2n", SrcHost: edge.Src, DestHost: edge.Dest, Timestamp: timestamp,
+				Duration: durationpb.New(1 * time.Millisecond), Ok: true,
+			})
+			if edge.Src == "node2" && edge.Dest == "node2" {
+				continue
+			}
+			observations = append(observations, &nwpd.Observation{
+				JobID: "tcp-n2n", SrcHost: edge.Src, DestHost: edge.Dest, Timestamp: timestamp,
+				Duration: durationpb.New(1 * time.Millisecond), Ok: edge.Dest != "node2" || i%2 == 0,
+			})
+		}
+	}
+	dir := t.TempDir()
+	assert.NoError(t, db.WriteRecordFile(filepath.Join(dir, "test-2022-08-01-11.records"), observations))
+	after, err := OpenDir(dir)
+	assert.NoError(t, err)
+	afterReport, err := AggregateSnapshot(after, Filter{})
+	assert.NoError(t, err)
+
+	report := Compare(beforeReport, afterReport, 0.05)
+	changes := map[string]ChangeType{}
+	for _, c := range report.Edges {
+		changes[c.JobID+"/"+c.Src+"/"+c.Dest] = c.Change
+	}
+	assert.Equal(t, map[string]ChangeType{
+		"ping-n2n/node1/node1": ChangeUnchanged,
+		"ping-n2n/node1/node2": ChangeUnchanged,
+		"ping-n2n/node2/node1": ChangeImprovement,
+		"ping-n2n/node2/node2": ChangeUnchanged,
+		"tcp-n2n/node1/node1":  ChangeUnchanged,
+		"tcp-n2n/node1/node2":  ChangeRegression,
+		"tcp-n2n/node2/node1":  ChangeUnchanged,
+		"tcp-n2n/node2/node2":  ChangeRemoved,
+	}, changes)
+	assert.Equal(t, 1, report.Count(ChangeRegression))
+
+	for _, c := range report.Destinations {
+		if c.JobID == "tcp-n2n" && c.Dest == "node2" {
+			assert.Equal(t, ChangeRegression, c.Change)
+			assert.Equal(t, 1.0, c.Before.SuccessRate)
+			assert.Equal(t, 0.5, c.After.SuccessRate)
+			assert.Equal(t, 10, c.After.OkCount+c.After.FailedCount)
+		}
+	}
+
+	_, err = AggregateSnapshot(after, Filter{JobID: ExactMatcher("unknown")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package analysis
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/db"
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// CoverageStatus classifies the data of a node.
+type CoverageStatus string
+
+const (
+	// CoverageOK means there are recent observations from the agents of both networks.
+	CoverageOK CoverageStatus = "ok"
+	// CoverageStale means the last observation of the agent of at least one network is too old.
+	CoverageStale CoverageStatus = "stale"
+	// CoverageMissing means there are no observations from the agent of at least one network.
+	CoverageMissing CoverageStatus = "missing"
+)
+
+// NetworkCoverage contains the observations of the agent of a node in the host or pod network.
+type NetworkCoverage struct {
+	// Samples is the number of observations.
+	Samples int `json:"samples"`
+	// Last is the timestamp of the last observation. It is zero if there are no observations.
+	Last time.Time `json:"last,omitempty"`
+	// NetworkInfo contains the default routes of the agent from the header of its newest record file.
+	NetworkInfo []*nwpd.NetworkInfo `json:"networkInfo,omitempty"`
+}
+
+// NodeCoverage contains the observations of the agents of a node.
+type NodeCoverage struct {
+	Node        string          `json:"node"`
+	HostNetwork NetworkCoverage `json:"hostNetwork"`
+	PodNetwork  NetworkCoverage `json:"podNetwork"`
+	Status      CoverageStatus  `json:"status"`
+}
+
+// Coverage returns the coverage of the given nodes by the observations of the source matching the filter.
+// The network of an agent is derived from the prefix of the record files written by the daemon sets.
+// An agent is stale if its last observation is older than staleAfter compared to the newest observation of all agents,
+// so that the result does not depend on when the collected observations are analysed.
+func Coverage(source Source, filter Filter, nodes []string, staleAfter time.Duration) ([]NodeCoverage, error) {
+	type networks struct {
+		host, pod NetworkCoverage
+		// hostStart and podStart are the start times of the record files with the network info
+		hostStart, podStart time.Time
+	}
+	counts := map[string]*networks{}
+	get := func(node string) *networks {
+		n := counts[node]
+		if n == nil {
+			n = &networks{}
+			counts[node] = n
+		}
+		return n
+	}
+	var newest time.Time
+	for _, filename := range source.Filenames() {
+		hostNetwork, ok := recordFileNetwork(filename)
+		if !ok {
+			continue
+		}
+		header, err := db.ReadRecordFileHeader(filename)
+		if err != nil {
+			return nil, err
+		}
+		if header.GetNode() != "" && len(header.GetNetworkInfo()) > 0 {
+			n := get(header.GetNode())
+			nc, start := &n.pod, &n.podStart
+			if hostNetwork {
+				nc, start = &n.host, &n.hostStart
+			}
+			if t := header.GetStart().AsTime(); nc.NetworkInfo == nil || !t.Before(*start) {
+				nc.NetworkInfo, *start = header.GetNetworkInfo(), t
+			}
+		}
+		err = db.IterateRecordFile(filename, func(obs *nwpd.Observation) error {
+			if !filter.Matches(obs) {
+				return nil
+			}
+			n := get(obs.SrcHost)
+			nc := &n.pod
+			if hostNetwork {
+				nc = &n.host
+			}
+			t := obs.Timestamp.AsTime()
+			nc.Samples++
+			if t.After(nc.Last) {
+				nc.Last = t
+			}
+			if t.After(newest) {
+				newest = t
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var result []NodeCoverage
+	for _, node := range nodes {
+		nc := NodeCoverage{Node: node}
+		if n := counts[node]; n != nil {
+			nc.HostNetwork = n.host
+			nc.PodNetwork = n.pod
+		}
+		nc.Status = CoverageOK
+		for _, c := range []NetworkCoverage{nc.HostNetwork, nc.PodNetwork} {
+			if c.Samples == 0 {
+				nc.Status = CoverageMissing
+				break
+			}
+			if staleAfter > 0 && newest.Sub(c.Last) > staleAfter {
+				nc.Status = CoverageStale
+			}
+		}
+		result = append(result, nc)
+	}
+	return result, nil
+}
+
+// recordFileNetwork returns if the record file was written by the agent in the host network or in the pod network.
+// Observations forwarded by the pod network agent are stored with the prefix of the pod network and an additional suffix.
+func recordFileNetwork(filename string) (hostNetwork bool, ok bool) {
+	name := filepath.Base(filename)
+	switch {
+	case strings.HasPrefix(name, common.NameDaemonSetAgentHostNet+"-"):
+		return true, true
+	case strings.HasPrefix(name, common.NameDaemonSetAgentPodNet+"-"):
+		return false, true
+	default:
+		return false, false
+	}
+}
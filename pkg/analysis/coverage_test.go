@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package analysis
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/db"
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestCoverage(t *testing.T) {
+	start := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	observations := func(src string, minutes int) []*nwpd.Observation {
+		var result []*nwpd.Observation
+		for i := 0; i < minutes; i++ {
+			result = append(result, &nwpd.Observation{
+				JobID: "tcp-n2n", SrcHost: src, DestHost: "node1", Timestamp: timestamppb.New(start.Add(time.Duration(i) * time.Minute)), Ok: true,
+			})
+		}
+		return result
+	}
+	dir := t.TempDir()
+	for i, file := range []struct {
+		prefix       string
+		observations []*nwpd.Observation
+	}{
+		{prefix: common.NameDaemonSetAgentHostNet, observations: append(observations("node1", 30), observations("node2", 30)...)},
+		{prefix: common.NameDaemonSetAgentPodNet, observations: append(observations("node1", 30), observations("node2", 10)...)},
+		{prefix: common.NameDaemonSetAgentPodNet, observations: observations("node3", 30)},
+		{prefix: "other", observations: observations("node3", 30)},
+		// observations forwarded by the pod network agent to the host network agent
+		{prefix: common.NameDaemonSetAgentPodNet + common.DataFileSuffixForwarded, observations: observations("node4", 30)},
+	} {
+		// record files in subdirectories as stored by the collect command
+		subdir := filepath.Join(dir, fmt.Sprintf("agent%d", i))
+		assert.NoError(t, os.Mkdir(subdir, 0755))
+		filename := filepath.Join(subdir, file.prefix+"-2022-08-01-10.records")
+		assert.NoError(t, db.WriteRecordFile(filename, file.observations))
+	}
+	source, err := OpenDir(dir)
+	assert.NoError(t, err)
+
+	coverage, err := Coverage(source, Filter{}, []string{"node1", "node2", "node3", "node4"}, 5*time.Minute)
+	assert.NoError(t, err)
+	last := start.Add(29 * time.Minute)
+	assert.Equal(t, []NodeCoverage{
+		{Node: "node1", HostNetwork: NetworkCoverage{Samples: 30, Last: last}, PodNetwork: NetworkCoverage{Samples: 30, Last: last}, Status: CoverageOK},
+		{Node: "node2", HostNetwork: NetworkCoverage{Samples: 30, Last: last}, PodNetwork: NetworkCoverage{Samples: 10, Last: start.Add(9 * time.Minute)}, Status: CoverageStale},
+		{Node: "node3", PodNetwork: NetworkCoverage{Samples: 30, Last: last}, Status: CoverageMissing},
+		{Node: "node4", PodNetwork: NetworkCoverage{Samples: 30, Last: last}, Status: CoverageMissing},
+	}, coverage)
+
+	coverage, err = Coverage(source, Filter{End: start.Add(9 * time.Minute)}, []string{"node2"}, 5*time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, CoverageOK, coverage[0].Status)
+}
+
+func TestCoverageNetworkInfo(t *testing.T) {
+	start := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	write := func(hour int, iface string) {
+		fileStart := start.Add(time.Duration(hour) * time.Hour)
+		filename := filepath.Join(dir, fmt.Sprintf("%s-2022-08-01-%02d.records", common.NameDaemonSetAgentHostNet, fileStart.Hour()))
+		header := db.NewRecordFileHeader("node1", common.NameDaemonSetAgentHostNet, fileStart)
+		header.NetworkInfo = []*nwpd.NetworkInfo{{Family: "ipv4", Interface: iface, Gateway: "10.0.0.1", PrimaryIP: "10.0.0.5"}}
+		obs := &nwpd.Observation{JobID: "tcp-n2n", SrcHost: "node1", DestHost: "node2", Timestamp: timestamppb.New(fileStart), Ok: true}
+		assert.NoError(t, db.WriteRecordFileWithHeader(filename, header, []*nwpd.Observation{obs}))
+	}
+	write(1, "bond0")
+	write(0, "eth0")
+	source, err := OpenDir(dir)
+	assert.NoError(t, err)
+
+	coverage, err := Coverage(source, Filter{}, []string{"node1"}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, coverage[0].HostNetwork.Samples)
+	assert.Len(t, coverage[0].HostNetwork.NetworkInfo, 1)
+	assert.Equal(t, "bond0", coverage[0].HostNetwork.NetworkInfo[0].Interface)
+	assert.Nil(t, coverage[0].PodNetwork.NetworkInfo)
+}
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package analysis_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/analysis"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func ExampleOpenDir() {
+	source, err := analysis.OpenDir("testdata")
+	if err != nil {
+		panic(err)
+	}
+	err = source.Iterate(analysis.Filter{FailuresOnly: true}, func(obs *nwpd.Observation) error {
+		fmt.Printf("%s %s->%s %s\n", obs.Timestamp.AsTime().Format(time.RFC3339), obs.SrcHost, obs.DestHost, obs.JobID)
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	// Output:
+	// 2022-08-01T10:07:00Z node2->node1 ping-n2n
+	// 2022-08-01T10:08:00Z node2->node1 ping-n2n
+	// 2022-08-01T10:09:00Z node2->node1 ping-n2n
+}
+
+func ExampleAggregate() {
+	source, err := analysis.OpenDir("testdata")
+	if err != nil {
+		panic(err)
+	}
+	jobs, err := analysis.GlobMatcher("ping-*")
+	if err != nil {
+		panic(err)
+	}
+	start := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	report, err := analysis.Aggregate(source, analysis.Options{
+		Filter: analysis.Filter{
+			Start: start,
+			End:   start.Add(10 * time.Minute),
+			JobID: jobs,
+		},
+		Buckets: 5,
+	})
+	if err != nil {
+		panic(err)
+	}
+	for _, src := range report.SrcNodes {
+		for _, dest := range report.DestNodes {
+			jr := report.Lookup(src, dest, "ping-n2n")
+			failed := 0
+			for _, bd := range jr.Buckets {
+				failed += int(bd.FailedCount)
+			}
+			fmt.Printf("%s -> %s: %d failed, period %s\n", src, dest, failed, jr.MeanPeriod())
+		}
+	}
+	// Output:
+	// node1 -> node1: 0 failed, period 1m0s
+	// node1 -> node2: 0 failed, period 1m0s
+	// node2 -> node1: 3 failed, period 1m0s
+	// node2 -> node2: 0 failed, period 1m0s
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+const (
+	// GroupByNode aggregates the observations per source and destination host.
+	GroupByNode = "node"
+	// GroupByZone aggregates the observations per zone of the source and destination node.
+	GroupByZone = "zone"
+	// GroupByPool aggregates the observations per worker pool of the source and destination node.
+	GroupByPool = "pool"
+	// GroupExternal is the group of destinations which are no nodes of the cluster (e.g. the kube-apiserver).
+	GroupExternal = "external"
+)
+
+// Grouping maps a host to its group.
+type Grouping func(host string) string
+
+// NodeGrouping returns the grouping of the hosts by zone or worker pool of the nodes in the cluster config.
+// Nodes without zone or pool are mapped to 'unknown', hosts which are no nodes to 'external'.
+// It returns nil for grouping by node.
+func NodeGrouping(cfg *config.ClusterConfig, groupBy string) (Grouping, error) {
+	var groupOf func(node config.Node) string
+	switch groupBy {
+	case GroupByNode:
+		return nil, nil
+	case GroupByZone:
+		groupOf = func(node config.Node) string { return node.Zone }
+	case GroupByPool:
+		groupOf = func(node config.Node) string { return node.Pool }
+	default:
+		return nil, fmt.Errorf("invalid grouping %q: must be %q, %q or %q", groupBy, GroupByNode, GroupByZone, GroupByPool)
+	}
+	if cfg == nil {
+		return nil, fmt.Errorf("grouping by %s needs the cluster config", groupBy)
+	}
+	groups := map[string]string{}
+	for _, node := range cfg.Nodes {
+		group := groupOf(node)
+		if group == "" {
+			group = common.NodeGroupUnknown
+		}
+		groups[node.Hostname] = group
+	}
+	return func(host string) string {
+		if group, ok := groups[host]; ok {
+			return group
+		}
+		return GroupExternal
+	}, nil
+}
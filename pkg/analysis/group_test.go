@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+func TestNodeGrouping(t *testing.T) {
+	cfg := &config.ClusterConfig{
+		Nodes: []config.Node{
+			{Hostname: "node1", Zone: "zone-a", Pool: "worker-a"},
+			{Hostname: "node2", Zone: "zone-a"},
+		},
+	}
+
+	group, err := NodeGrouping(cfg, GroupByNode)
+	assert.NoError(t, err)
+	assert.Nil(t, group)
+
+	group, err = NodeGrouping(cfg, GroupByPool)
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-a", group("node1"))
+	assert.Equal(t, common.NodeGroupUnknown, group("node2"))
+	assert.Equal(t, GroupExternal, group("kube-apiserver"))
+
+	group, err = NodeGrouping(cfg, GroupByZone)
+	assert.NoError(t, err)
+	assert.Equal(t, "zone-a", group("node2"))
+
+	_, err = NodeGrouping(nil, GroupByPool)
+	assert.Error(t, err)
+	_, err = NodeGrouping(cfg, "region")
+	assert.Error(t, err)
+}
+
+func TestAggregateGrouped(t *testing.T) {
+	source, err := OpenDir("testdata")
+	assert.NoError(t, err)
+	group, err := NodeGrouping(&config.ClusterConfig{Nodes: []config.Node{{Hostname: "node1", Pool: "worker-a"}, {Hostname: "node2"}}}, GroupByPool)
+	assert.NoError(t, err)
+
+	report, err := Aggregate(source, Options{
+		Filter: Filter{
+			Start: fixtureStart,
+			End:   fixtureStart.Add(10 * time.Minute),
+		},
+		Buckets: 10,
+		Group:   group,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{common.NodeGroupUnknown, "worker-a"}, report.SrcNodes)
+	assert.Equal(t, []string{common.NodeGroupUnknown, "worker-a"}, report.DestNodes)
+
+	jr := report.Lookup(common.NodeGroupUnknown, "worker-a", "ping-n2n")
+	if assert.NotNil(t, jr) {
+		assert.Equal(t, 3, jr.FailedCount())
+		assert.Equal(t, 7, jr.OkCount())
+	}
+}
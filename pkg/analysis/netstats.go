@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package analysis
+
+import (
+	"sort"
+
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// NetStats are the samples of the kernel network counters recorded by jobs with 'collectNetStats' per source host.
+type NetStats struct {
+	samples map[string][]*nwpd.Observation
+}
+
+// LoadNetStats loads the samples of the source hosts matching the filter from its start time on. The end time is not
+// applied, as the samples covering the last observations are taken after them.
+func LoadNetStats(source Source, filter Filter) (*NetStats, error) {
+	stats := &NetStats{samples: map[string][]*nwpd.Observation{}}
+	netStatsFilter := Filter{
+		Start:    filter.Start,
+		SrcHost:  filter.SrcHost,
+		Protocol: ExactMatcher(nwpd.ProtocolNetStats),
+	}
+	err := source.Iterate(netStatsFilter, func(obs *nwpd.Observation) error {
+		stats.samples[obs.SrcHost] = append(stats.samples[obs.SrcHost], obs)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, samples := range stats.samples {
+		sort.SliceStable(samples, func(i, j int) bool {
+			return samples[i].Timestamp.AsTime().Before(samples[j].Timestamp.AsTime())
+		})
+	}
+	return stats, nil
+}
+
+// Covering returns the samples of the source host of the observation whose period contains the time of the observation,
+// i.e. per interface and for the TCP counters the first sample taken at or after the observation within its period.
+func (s *NetStats) Covering(obs *nwpd.Observation) []*nwpd.Observation {
+	samples := s.samples[obs.SrcHost]
+	t := obs.Timestamp.AsTime()
+	first := sort.Search(len(samples), func(i int) bool {
+		return !samples[i].Timestamp.AsTime().Before(t)
+	})
+	var result []*nwpd.Observation
+	seen := map[string]bool{}
+	for _, sample := range samples[first:] {
+		if sample.Timestamp.AsTime().Add(-sample.Period.AsDuration()).After(t) {
+			// later samples cover later periods
+			break
+		}
+		if !seen[sample.DestHost] {
+			seen[sample.DestHost] = true
+			result = append(result, sample)
+		}
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package analysis
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/db"
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestNetStatsCovering(t *testing.T) {
+	start := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	var observations []*nwpd.Observation
+	for minute := 1; minute <= 3; minute++ {
+		for _, dest := range []string{"netstats-eth0", "netstats-tcp"} {
+			observations = append(observations, &nwpd.Observation{
+				JobID: "netstats", SrcHost: "node1", DestHost: dest, Protocol: nwpd.ProtocolNetStats,
+				Timestamp: timestamppb.New(start.Add(time.Duration(minute) * time.Minute)), Period: durationpb.New(time.Minute),
+			})
+		}
+	}
+	failed := &nwpd.Observation{JobID: "tcp-n2n", SrcHost: "node1", DestHost: "node2", Protocol: nwpd.ProtocolTCP,
+		Timestamp: timestamppb.New(start.Add(90 * time.Second))}
+	observations = append(observations, failed)
+	dir := t.TempDir()
+	assert.NoError(t, db.WriteRecordFile(filepath.Join(dir, common.NameDaemonSetAgentHostNet+"-2022-08-01-10.records"), observations))
+	source, err := OpenDir(dir)
+	assert.NoError(t, err)
+
+	stats, err := LoadNetStats(source, Filter{JobID: ExactMatcher("tcp-n2n")})
+	assert.NoError(t, err)
+	covering := stats.Covering(failed)
+	if assert.Len(t, covering, 2) {
+		for _, sample := range covering {
+			assert.Equal(t, start.Add(2*time.Minute), sample.Timestamp.AsTime())
+		}
+	}
+
+	// no samples of the other node and none after the last sample
+	assert.Empty(t, stats.Covering(&nwpd.Observation{SrcHost: "node2", Timestamp: failed.Timestamp}))
+	assert.Empty(t, stats.Covering(&nwpd.Observation{SrcHost: "node1", Timestamp: timestamppb.New(start.Add(4 * time.Minute))}))
+}
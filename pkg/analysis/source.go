@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package analysis provides reading, filtering and aggregation of observations stored in record files
+// as written by the agents and downloaded by the collect command.
+package analysis
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/db"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// Source provides access to observations stored in record files.
+type Source interface {
+	// Filenames returns the record files of the source.
+	Filenames() []string
+	// Iterate calls the visitor for each observation matching the filter.
+	Iterate(filter Filter, visitor db.ObservationVisitor) error
+}
+
+// Matcher matches a value of an observation field.
+type Matcher func(value string) bool
+
+// Filter restricts the observations to visit.
+type Filter struct {
+	// Start is the inclusive lower time limit. It is ignored if zero.
+	Start time.Time
+	// End is the inclusive upper time limit. It is ignored if zero.
+	End time.Time
+	// JobID matches the job ID. It is ignored if nil.
+	JobID Matcher
+	// SrcHost matches the source host. It is ignored if nil.
+	SrcHost Matcher
+	// DestHost matches the destination host. It is ignored if nil.
+	DestHost Matcher
+	// Protocol matches the protocol. It is ignored if nil.
+	Protocol Matcher
+	// DestPort is the destination port. It is ignored if zero.
+	DestPort int32
+	// FailuresOnly restricts to failed observations.
+	FailuresOnly bool
+}
+
+// Matches returns true if the observation is accepted by the filter.
+func (f *Filter) Matches(obs *nwpd.Observation) bool {
+	t := obs.Timestamp.AsTime()
+	if !f.Start.IsZero() && t.Before(f.Start) {
+		return false
+	}
+	if !f.End.IsZero() && t.After(f.End) {
+		return false
+	}
+	if f.FailuresOnly && obs.Ok {
+		return false
+	}
+	if f.SrcHost != nil && !f.SrcHost(obs.SrcHost) {
+		return false
+	}
+	if f.DestHost != nil && !f.DestHost(obs.DestHost) {
+		return false
+	}
+	if f.JobID != nil && !f.JobID(obs.JobID) {
+		return false
+	}
+	if f.Protocol != nil && !f.Protocol(obs.Protocol) {
+		return false
+	}
+	if f.DestPort != 0 && obs.DestPort != f.DestPort {
+		return false
+	}
+	return true
+}
+
+// GlobMatcher creates a matcher for a pattern using '*' for globbing. Returns nil for an empty pattern.
+func GlobMatcher(pattern string) (Matcher, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile("^" + strings.ReplaceAll(pattern, "*", ".*") + "$")
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString, nil
+}
+
+// SubstringMatcher creates a matcher for values containing the given string. Returns nil for an empty string.
+func SubstringMatcher(s string) Matcher {
+	if s == "" {
+		return nil
+	}
+	return func(value string) bool {
+		return strings.Contains(value, s)
+	}
+}
+
+// ExactMatcher creates a matcher for values equal to the given string. Returns nil for an empty string.
+func ExactMatcher(s string) Matcher {
+	if s == "" {
+		return nil
+	}
+	return func(value string) bool {
+		return value == s
+	}
+}
+
+type dirSource struct {
+	filenames []string
+}
+
+var _ Source = &dirSource{}
+
+// OpenDir opens a directory with record files. Record files in direct subdirectories are included,
+// as the collect command stores them in a subdirectory per node.
+func OpenDir(directory string) (Source, error) {
+	filenames, err := db.GetAnyRecordFiles(directory, true)
+	if err != nil {
+		return nil, err
+	}
+	return &dirSource{filenames: filenames}, nil
+}
+
+func (s *dirSource) Filenames() []string {
+	return s.filenames
+}
+
+func (s *dirSource) Iterate(filter Filter, visitor db.ObservationVisitor) error {
+	for _, filename := range s.filenames {
+		err := db.IterateRecordFile(filename, func(obs *nwpd.Observation) error {
+			if !filter.Matches(obs) {
+				return nil
+			}
+			return visitor(obs)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
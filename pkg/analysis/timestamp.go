@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ParseTimestamp parses a timestamp and returns time in Unix mills.
+// If the date is omitted, the current day is used.
+func ParseTimestamp(value string) (int64, error) {
+	location := time.Now().Location()
+	input := value
+	if len(value) < 15 {
+		input = time.Now().Format("2006-01-02T") + value
+	}
+	supportedLayouts := []string{
+		"2006-01-02T15:04:05Z07:00",
+		"2006-01-02T15:04:05Z07",
+		"2006-01-02T15:04:05-07:00",
+		"2006-01-02T15:04:05-07",
+		"2006-01-02T15:04:05MST",
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04Z07",
+		"2006-01-02T15:04-07:00",
+		"2006-01-02T15:04-07",
+		"2006-01-02T15:04MST",
+		"2006-01-02T15:04",
+	}
+	for _, layout := range supportedLayouts {
+		t, err := time.ParseInLocation(layout, input, location)
+		if err == nil {
+			return t.Local().UnixMilli(), nil
+		}
+	}
+	supported := strings.Join(supportedLayouts, ", ")
+	supported2 := strings.ReplaceAll(supported, "2006-01-02T", "")
+	return 0, fmt.Errorf("invalid time stamp format: %s (Supported formats are: %s, %s)", value, supported, supported2)
+}
@@ -20,12 +20,15 @@ import (
 
 	"github.com/gardener/network-problem-detector/pkg/agent/db"
 	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/deploy"
 	"go.uber.org/atomic"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
 )
 
 type collectCommand struct {
@@ -105,10 +108,52 @@ func (cc *collectCommand) collect(cmd *cobra.Command, args []string) error {
 	if cc.failedNodes.Load() > 0 {
 		log.Warnf("%d nodes not completed (see log messages above)", cc.failedNodes.Load())
 	}
+	cc.collectClusterEvents(ctx, log)
+	cc.collectClusterConfig(ctx, log)
 
 	return nil
 }
 
+// collectClusterConfig stores the cluster config with the known nodes in the output directory.
+// The shards of a sharded cluster config are merged.
+func (cc *collectCommand) collectClusterConfig(ctx context.Context, log logrus.FieldLogger) {
+	cfg, err := deploy.LoadClusterConfigFromConfigMaps(ctx, cc.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem))
+	if err != nil {
+		log.Warnf("loading cluster config failed: %s", err)
+		return
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		log.Warnf("marshal cluster config failed: %s", err)
+		return
+	}
+	filename := filepath.Join(cc.directory, common.ClusterConfigFilename)
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		log.Warnf("writing cluster config failed: %s", err)
+		return
+	}
+	log.Infof("Written cluster config to %s", filename)
+}
+
+// collectClusterEvents stores the node lifecycle events recorded by the controller in the output directory.
+func (cc *collectCommand) collectClusterEvents(ctx context.Context, log logrus.FieldLogger) {
+	cm, err := cc.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem).Get(ctx, common.NameClusterEventsConfigMap, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Infof("no cluster events found (configmap %s/%s is created by the controller)", common.NamespaceKubeSystem, common.NameClusterEventsConfigMap)
+		} else {
+			log.Warnf("loading cluster events failed: %s", err)
+		}
+		return
+	}
+	filename := filepath.Join(cc.directory, common.ClusterEventsFilename)
+	if err := os.WriteFile(filename, []byte(cm.Data[common.ClusterEventsFilename]), 0644); err != nil {
+		log.Warnf("writing cluster events failed: %s", err)
+		return
+	}
+	log.Infof("Written cluster events to %s", filename)
+}
+
 func (cc *collectCommand) loadFrom(log logrus.FieldLogger, dir string, pod *corev1.Pod) {
 	log.Infof("Loading observations")
 	kubeconfigOpt := ""
@@ -22,6 +22,8 @@ type ClientsetBase struct {
 	Kubeconfig string
 	InCluster  bool
 	Clientset  *kubernetes.Clientset
+	// RestConfig is the config of the clientset, e.g. for creating other clients
+	RestConfig *rest.Config
 }
 
 func (b *ClientsetBase) AddKubeConfigFlag(flags *pflag.FlagSet) {
@@ -57,6 +59,7 @@ func (b *ClientsetBase) SetupClientSet() error {
 			return fmt.Errorf("error on config from kubeconfig file %s: %s", b.Kubeconfig, err)
 		}
 	}
+	b.RestConfig = config
 	b.Clientset, err = kubernetes.NewForConfig(config)
 	if err != nil {
 		return fmt.Errorf("error creating clientset: %s", err)
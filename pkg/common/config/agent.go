@@ -5,20 +5,37 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type AgentConfig struct {
+	// Generation is a hash of the config stamped by the writer of the config map (see StampGeneration).
+	// The agents expose it to detect agents running an outdated config after a partial rollout.
+	Generation string `json:"generation,omitempty"`
 	// OutputDir is the directory to store the observations.
 	OutputDir string `json:"outputDir,omitempty"`
-	// RetentionHours defines how many hours to keep old observations.
+	// RetentionHours defines how many hours to keep old observations (1-168). It can be overridden per network.
 	RetentionHours int `json:"retentionHours,omitempty"`
 	// LogObservations defines if observations should be logged additionally (for debug purposes)
 	LogObservations bool `json:"logObservations"`
+	// LogDroppingFactor is the fraction of observations randomly dropped from the log if LogObservations is set (0-1, 0 logs all).
+	// It can be overridden per network.
+	LogDroppingFactor float64 `json:"logDroppingFactor,omitempty"`
 	// K8sExporter defines configuration of the K8s exporter for writing node conditions and events
 	K8sExporter *K8sExporterConfig `json:"k8sExporter,omitempty"`
+	// EventExporter defines the configuration of the exporter of findings as Kubernetes events summarizing a window.
+	// Changes are only applied on restart of the agent.
+	EventExporter *EventExporterConfig `json:"eventExporter,omitempty"`
 	// AggregationReportPeriod defines how often aggregated report is logged.
 	AggregationReportPeriod *metav1.Duration `json:"aggregationReportPeriod,omitempty"`
 	// AggregationTimeWindow defines when a aggregation edge outdates if no new observations arrive
@@ -27,6 +44,287 @@ type AgentConfig struct {
 	HostNetwork *NetworkConfig `json:"hostNetwork,omitempty"`
 	// PodNetwork is the configuration specific for daemon set in node network
 	PodNetwork *NetworkConfig `json:"podNetwork,omitempty"`
+	// ObservationBuffer configures the in-memory buffer of observations waiting to be written to the output directory.
+	// Changes are only applied on restart of the agent.
+	ObservationBuffer *ObservationBufferConfig `json:"observationBuffer,omitempty"`
+	// FastRecheckConcurrency is the maximum number of concurrently running fast re-checks of all jobs
+	// (default DefaultFastRecheckConcurrency). Re-checks exceeding it are skipped.
+	FastRecheckConcurrency int `json:"fastRecheckConcurrency,omitempty"`
+	// ResolverCache enables the resolver cache shared by the jobs of the agent if set. The host names of the destinations are
+	// resolved asynchronously, and the checks use the cached addresses.
+	ResolverCache *ResolverCacheConfig `json:"resolverCache,omitempty"`
+	// SafetyValve enables the automatic suspension of expensive jobs while the agent is overloaded if set.
+	SafetyValve *SafetyValveConfig `json:"safetyValve,omitempty"`
+	// CABundle sets the CA certificates for verifying the server certificates of the TLS-based checks if set,
+	// e.g. for internal endpoints with a private CA. Otherwise the system pool is used.
+	CABundle *CABundleConfig `json:"caBundle,omitempty"`
+}
+
+// BackpressurePolicy defines how to handle a new observation if the observation buffer is full.
+type BackpressurePolicy string
+
+const (
+	// BackpressureBlock blocks until the buffer has space again. This delays the checks.
+	BackpressureBlock BackpressurePolicy = "block"
+	// BackpressureDropOldest drops the oldest buffered observation.
+	BackpressureDropOldest BackpressurePolicy = "drop-oldest"
+	// BackpressureDropNewest drops the new observation.
+	BackpressureDropNewest BackpressurePolicy = "drop-newest"
+)
+
+const (
+	// MinRetentionHours is the minimum retention of the observations in hours.
+	MinRetentionHours = 1
+	// MaxRetentionHours is the maximum retention of the observations in hours (one week).
+	MaxRetentionHours = 168
+	// LogDroppingFactorWarningThreshold is the log dropping factor above which a warning is given, as nearly all observations are dropped from the log.
+	LogDroppingFactorWarningThreshold = 0.99
+)
+
+const (
+	// DefaultObservationBufferSize is the default maximum number of buffered observations.
+	DefaultObservationBufferSize = 100
+	// DefaultBackpressurePolicy is the default backpressure policy.
+	DefaultBackpressurePolicy = BackpressureBlock
+	// DefaultDegradedBufferSize is the default maximum number of observations kept in memory while the output directory is not writable.
+	DefaultDegradedBufferSize = 10000
+	// DefaultMinFreeDiskPercent is the default free space of the filesystem of the output directory in percent below which
+	// the observations are kept in memory.
+	DefaultMinFreeDiskPercent = 2.0
+)
+
+// SampleStrategy defines which destinations of a job are checked per round.
+type SampleStrategy string
+
+const (
+	// SampleAll checks all destinations per round.
+	SampleAll SampleStrategy = "all"
+	// SampleRandom checks a weighted random sample of the destinations per round. Destinations not selected for
+	// a longer time get a higher weight, so that all destinations are covered eventually.
+	SampleRandom SampleStrategy = "random"
+	// SampleConsistentHash checks consecutive windows of the destinations ordered by a hash of source and destination.
+	// All destinations are covered every ceil(destinations / sample size) rounds, and the order differs per source.
+	SampleConsistentHash SampleStrategy = "consistent-hash"
+)
+
+// DetailLevel defines the metadata stored per observation.
+type DetailLevel string
+
+const (
+	// DetailLevelMinimal only stores job, source, destination, time, period and the ok/failed state (including partial failures,
+	// maintenance, trigger and previous state duration) of the observations.
+	DetailLevelMinimal DetailLevel = "minimal"
+	// DetailLevelStandard stores all fields of the observations except the result message.
+	DetailLevelStandard DetailLevel = "standard"
+	// DetailLevelFull stores all fields of the observations including the result message, e.g. the error of a failed check.
+	DetailLevelFull DetailLevel = "full"
+)
+
+type ObservationBufferConfig struct {
+	// Size is the maximum number of buffered observations (default 100).
+	Size int `json:"size,omitempty"`
+	// Policy is the backpressure policy if the buffer is full: 'block' (default), 'drop-oldest' or 'drop-newest'.
+	Policy BackpressurePolicy `json:"policy,omitempty"`
+	// DegradedSize is the maximum number of observations kept in memory while the output directory is not writable
+	// because the filesystem is full or read-only (default 10000). If exceeded, the oldest observations are dropped.
+	DegradedSize int `json:"degradedSize,omitempty"`
+	// MinFreeDiskPercent is the free space of the filesystem of the output directory in percent below which the writer
+	// switches to the degraded mode before the filesystem is full (default 2, 0 to only switch on write errors).
+	MinFreeDiskPercent *float64 `json:"minFreeDiskPercent,omitempty"`
+}
+
+// Validate checks size and policy.
+func (c *ObservationBufferConfig) Validate() error {
+	if c.Size < 0 {
+		return fmt.Errorf("invalid observation buffer size %d: must not be negative", c.Size)
+	}
+	if c.DegradedSize < 0 {
+		return fmt.Errorf("invalid degraded observation buffer size %d: must not be negative", c.DegradedSize)
+	}
+	if c.MinFreeDiskPercent != nil && (*c.MinFreeDiskPercent < 0 || *c.MinFreeDiskPercent >= 100) {
+		return fmt.Errorf("invalid minimum free disk space %g%%: must be in range [0,100)", *c.MinFreeDiskPercent)
+	}
+	switch c.Policy {
+	case "", BackpressureBlock, BackpressureDropOldest, BackpressureDropNewest:
+		return nil
+	default:
+		return fmt.Errorf("invalid backpressure policy %q: must be %q, %q or %q", c.Policy, BackpressureBlock, BackpressureDropOldest, BackpressureDropNewest)
+	}
+}
+
+// SizeOrDefault returns the size or the default size if not set.
+func (c *ObservationBufferConfig) SizeOrDefault() int {
+	if c == nil || c.Size == 0 {
+		return DefaultObservationBufferSize
+	}
+	return c.Size
+}
+
+// DegradedSizeOrDefault returns the degraded size or the default degraded size if not set.
+func (c *ObservationBufferConfig) DegradedSizeOrDefault() int {
+	if c == nil || c.DegradedSize == 0 {
+		return DefaultDegradedBufferSize
+	}
+	return c.DegradedSize
+}
+
+// MinFreeDiskPercentOrDefault returns the minimum free disk space in percent or the default if not set.
+func (c *ObservationBufferConfig) MinFreeDiskPercentOrDefault() float64 {
+	if c == nil || c.MinFreeDiskPercent == nil {
+		return DefaultMinFreeDiskPercent
+	}
+	return *c.MinFreeDiskPercent
+}
+
+// PolicyOrDefault returns the policy or the default policy if not set.
+func (c *ObservationBufferConfig) PolicyOrDefault() BackpressurePolicy {
+	if c == nil || c.Policy == "" {
+		return DefaultBackpressurePolicy
+	}
+	return c.Policy
+}
+
+const (
+	// ResolverCacheJobID is the job ID of the observations recording the resolutions of the resolver cache.
+	ResolverCacheJobID = "dns-refresh"
+	// DefaultResolverCacheMinTTL is the default floor of the TTL of cached resolutions.
+	DefaultResolverCacheMinTTL = 5 * time.Second
+	// DefaultResolverCacheMaxTTL is the default ceiling of the TTL of cached resolutions.
+	DefaultResolverCacheMaxTTL = 5 * time.Minute
+	// MinResolverCacheTTL is the smallest allowed floor of the TTL of cached resolutions.
+	MinResolverCacheTTL = 1 * time.Second
+)
+
+// ResolverCacheConfig configures the resolver cache of the agent. The resolutions are cached for the minimum TTL of the
+// DNS answers limited to the range MinTTL..MaxTTL. Failed resolutions and answers without TTL (e.g. from /etc/hosts)
+// are cached for MinTTL and MaxTTL respectively.
+type ResolverCacheConfig struct {
+	// MinTTL is the floor of the TTL (default 5s, at least 1s).
+	MinTTL *metav1.Duration `json:"minTTL,omitempty"`
+	// MaxTTL is the ceiling of the TTL (default 5m).
+	MaxTTL *metav1.Duration `json:"maxTTL,omitempty"`
+}
+
+// Validate checks the range of the TTLs.
+func (c *ResolverCacheConfig) Validate() error {
+	if c.MinTTLOrDefault() < MinResolverCacheTTL {
+		return fmt.Errorf("invalid resolver cache minTTL %s: must be >= %s", c.MinTTLOrDefault(), MinResolverCacheTTL)
+	}
+	if c.MaxTTLOrDefault() < c.MinTTLOrDefault() {
+		return fmt.Errorf("invalid resolver cache maxTTL %s: must be >= minTTL %s", c.MaxTTLOrDefault(), c.MinTTLOrDefault())
+	}
+	return nil
+}
+
+// MinTTLOrDefault returns the floor of the TTL or DefaultResolverCacheMinTTL if not set.
+func (c *ResolverCacheConfig) MinTTLOrDefault() time.Duration {
+	if c == nil || c.MinTTL == nil {
+		return DefaultResolverCacheMinTTL
+	}
+	return c.MinTTL.Duration
+}
+
+// MaxTTLOrDefault returns the ceiling of the TTL or DefaultResolverCacheMaxTTL if not set.
+func (c *ResolverCacheConfig) MaxTTLOrDefault() time.Duration {
+	if c == nil || c.MaxTTL == nil {
+		return DefaultResolverCacheMaxTTL
+	}
+	return c.MaxTTL.Duration
+}
+
+// CABundleConfig configures the CA certificates used by the TLS-based checks.
+type CABundleConfig struct {
+	// File is the path of the PEM encoded CA certificates. It is read on each check to pick up updates of the mounted file.
+	File string `json:"file"`
+	// AppendToSystemPool if true, the CA certificates are added to the system pool instead of replacing it.
+	AppendToSystemPool bool `json:"appendToSystemPool,omitempty"`
+}
+
+// Validate checks that the file is set.
+func (c *CABundleConfig) Validate() error {
+	if c.File == "" {
+		return fmt.Errorf("invalid CA bundle: missing file")
+	}
+	return nil
+}
+
+const (
+	// SafetyValveJobID is the job ID of the observations recording the suspensions of jobs by the safety valve.
+	SafetyValveJobID = "safety-valve"
+	// DefaultSafetyValveMaxSchedulerLag is the default scheduler lag above which the agent is considered overloaded.
+	DefaultSafetyValveMaxSchedulerLag = 5 * time.Second
+	// DefaultSafetyValveMaxThrottledRatio is the default ratio of throttled CPU periods above which the agent is considered overloaded.
+	DefaultSafetyValveMaxThrottledRatio = 0.5
+	// DefaultSafetyValveMinCost is the default minimum cost estimate of a job to be suspended.
+	DefaultSafetyValveMinCost = 0.2
+	// DefaultSafetyValveCooldown is the default time a job stays suspended.
+	DefaultSafetyValveCooldown = 10 * time.Minute
+	// MinSafetyValveCooldown is the smallest allowed cooldown of a suspended job.
+	MinSafetyValveCooldown = 1 * time.Minute
+)
+
+// SafetyValveConfig configures the suspension of jobs which harm the node. The agent is overloaded if the scheduler lag of a job
+// exceeds MaxSchedulerLag or the agent container is throttled in more than MaxThrottledRatio of the CPU periods. While overloaded,
+// the job with the highest cost estimate is suspended for the cooldown, if its cost is at least MinCost.
+// The cost of a job is the share of time it is running checks, multiplied by the parallelism of its checks and increased by its error rate.
+type SafetyValveConfig struct {
+	// MaxSchedulerLag is the scheduler lag of a job above which the agent is overloaded (default 5s).
+	MaxSchedulerLag *metav1.Duration `json:"maxSchedulerLag,omitempty"`
+	// MaxThrottledRatio is the ratio of throttled CPU periods above which the agent is overloaded (0-1, default 0.5).
+	MaxThrottledRatio float64 `json:"maxThrottledRatio,omitempty"`
+	// MinCost is the minimum cost estimate of a job to be suspended (default 0.2).
+	MinCost float64 `json:"minCost,omitempty"`
+	// Cooldown is the time a job stays suspended before it is resumed (default 10m, at least 1m).
+	Cooldown *metav1.Duration `json:"cooldown,omitempty"`
+}
+
+// Validate checks the thresholds and the cooldown.
+func (c *SafetyValveConfig) Validate() error {
+	if c.MaxSchedulerLagOrDefault() <= 0 {
+		return fmt.Errorf("invalid safety valve maxSchedulerLag %s: must be > 0", c.MaxSchedulerLagOrDefault())
+	}
+	if c.MaxThrottledRatio < 0 || c.MaxThrottledRatio > 1 {
+		return fmt.Errorf("invalid safety valve maxThrottledRatio %f: must be in range 0..1", c.MaxThrottledRatio)
+	}
+	if c.MinCost < 0 {
+		return fmt.Errorf("invalid safety valve minCost %f: must be >= 0", c.MinCost)
+	}
+	if c.CooldownOrDefault() < MinSafetyValveCooldown {
+		return fmt.Errorf("invalid safety valve cooldown %s: must be >= %s", c.CooldownOrDefault(), MinSafetyValveCooldown)
+	}
+	return nil
+}
+
+// MaxSchedulerLagOrDefault returns the maximum scheduler lag or DefaultSafetyValveMaxSchedulerLag if not set.
+func (c *SafetyValveConfig) MaxSchedulerLagOrDefault() time.Duration {
+	if c == nil || c.MaxSchedulerLag == nil {
+		return DefaultSafetyValveMaxSchedulerLag
+	}
+	return c.MaxSchedulerLag.Duration
+}
+
+// MaxThrottledRatioOrDefault returns the maximum ratio of throttled CPU periods or DefaultSafetyValveMaxThrottledRatio if not set.
+func (c *SafetyValveConfig) MaxThrottledRatioOrDefault() float64 {
+	if c == nil || c.MaxThrottledRatio == 0 {
+		return DefaultSafetyValveMaxThrottledRatio
+	}
+	return c.MaxThrottledRatio
+}
+
+// MinCostOrDefault returns the minimum cost estimate or DefaultSafetyValveMinCost if not set.
+func (c *SafetyValveConfig) MinCostOrDefault() float64 {
+	if c == nil || c.MinCost == 0 {
+		return DefaultSafetyValveMinCost
+	}
+	return c.MinCost
+}
+
+// CooldownOrDefault returns the cooldown or DefaultSafetyValveCooldown if not set.
+func (c *SafetyValveConfig) CooldownOrDefault() time.Duration {
+	if c == nil || c.Cooldown == nil {
+		return DefaultSafetyValveCooldown
+	}
+	return c.Cooldown.Duration
 }
 
 func (c *AgentConfig) Clone() (*AgentConfig, error) {
@@ -41,22 +339,513 @@ func (c *AgentConfig) Clone() (*AgentConfig, error) {
 	return clone, nil
 }
 
+// ComputeGeneration returns the hash of the config without the generation field.
+func (c *AgentConfig) ComputeGeneration() (string, error) {
+	clone, err := c.Clone()
+	if err != nil {
+		return "", err
+	}
+	clone.Generation = ""
+	data, err := json.Marshal(clone)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6]), nil
+}
+
+// StampGeneration sets the generation to the hash of the config.
+func (c *AgentConfig) StampGeneration() error {
+	generation, err := c.ComputeGeneration()
+	if err != nil {
+		return err
+	}
+	c.Generation = generation
+	return nil
+}
+
+// GenerationOrComputed returns the stamped generation or the computed one if the config is not stamped.
+func (c *AgentConfig) GenerationOrComputed() (string, error) {
+	if c.Generation != "" {
+		return c.Generation, nil
+	}
+	return c.ComputeGeneration()
+}
+
 type NetworkConfig struct {
 	// DataFilePrefix is the prefix for observation data files.
 	DataFilePrefix string `json:"dataFilePrefix,omitempty"`
+	// FilenameTemplate is the template of the observation file names (default '{prefix}-{date}-{hour}.records'),
+	// see ValidateFilenameTemplate for the placeholders. It is only applied on restart of the agent.
+	FilenameTemplate string `json:"filenameTemplate,omitempty"`
+	// RetentionHours overrides the global retention of the observations of this network if != 0.
+	RetentionHours int `json:"retentionHours,omitempty"`
+	// LogDroppingFactor overrides the global log dropping factor for the observations of this network if set.
+	LogDroppingFactor *float64 `json:"logDroppingFactor,omitempty"`
 	// GRPCPort is the port of the GRPC server. If 0, a dynamic port is used.
 	GRPCPort int `json:"grpcPort,omitempty"`
+	// ExposedPort is the GRPC port if it is exposed as host port for reachability tests from outside the cluster.
+	// It is only informational for external tooling.
+	ExposedPort int `json:"exposedPort,omitempty"`
 	// HttpPort is the port of the http server.
 	HttpPort int `json:"httpPort,omitempty"`
+	// EchoPort is the port of the TCP echo handler for checks with 'checkTCPPort --echo' by other agents. If 0, it is disabled.
+	EchoPort int `json:"echoPort,omitempty"`
+	// AdminGRPCPort is the port of the admin GRPC listener bound to 127.0.0.1.
+	// If set or if AdminSocket is true, the listener on GRPCPort is bound to the pod IP (the node IP on the host network)
+	// and only serves the methods needed by other agents and the controller. All methods are only served by the admin listeners.
+	AdminGRPCPort int `json:"adminGRPCPort,omitempty"`
+	// AdminSocket if true, the admin GRPC listener is a unix socket in the output directory (see AdminSocketPath).
+	AdminSocket bool `json:"adminSocket,omitempty"`
 	// Jobs are the jobs to execute.
 	Jobs []Job `json:"jobs,omitempty"`
 	// DefaultPeriod is the period used for a new job if it doesn't specify the period.
 	DefaultPeriod metav1.Duration `json:"defaultPeriod,omitempty"`
+	// EmitOnChangeOnly if true, an observation is only stored when the result of an edge changes between ok and failed.
+	// Metrics are still updated for every check.
+	EmitOnChangeOnly bool `json:"emitOnChangeOnly,omitempty"`
+	// ForwardObservations if true, the agent in the pod network forwards its observations to the agent in the host network
+	// on the same node, which stores them in its output directory. Used if the output directory of the pod network agent is
+	// not persisted on the host. It is only evaluated for the pod network.
+	ForwardObservations bool `json:"forwardObservations,omitempty"`
+	// SampleStrategy selects the destinations of the jobs checked per round: 'all' (default), 'random' or 'consistent-hash'.
+	SampleStrategy SampleStrategy `json:"sampleStrategy,omitempty"`
+	// SampleSize is the number of destinations checked per round for the sample strategies 'random' and 'consistent-hash'.
+	SampleSize int `json:"sampleSize,omitempty"`
+	// DetailLevel controls the metadata stored per observation: 'minimal', 'standard' (default) or 'full'.
+	// Metrics and the in-memory aggregation are not affected.
+	DetailLevel DetailLevel `json:"detailLevel,omitempty"`
+}
+
+// Validate checks the agent config. The arguments of the jobs are not checked, as they are parsed by the runners.
+func (c *AgentConfig) Validate() error {
+	if c.AggregationReportPeriod != nil && c.AggregationReportPeriod.Duration < 30*time.Second {
+		return fmt.Errorf("Invalid AggregationReportPeriod, must be >= 30s")
+	}
+	if c.AggregationTimeWindow != nil && c.AggregationTimeWindow.Duration < 5*time.Minute {
+		return fmt.Errorf("Invalid AggregationTimeWindow, must be >= 5m")
+	}
+	if err := c.ValidateRetentionAndLogDropping(); err != nil {
+		return err
+	}
+	if c.FastRecheckConcurrency < 0 {
+		return fmt.Errorf("invalid fast recheck concurrency %d: must be >= 0", c.FastRecheckConcurrency)
+	}
+	for _, networkCfg := range []*NetworkConfig{c.HostNetwork, c.PodNetwork} {
+		if networkCfg == nil {
+			continue
+		}
+		if err := ValidateJobs(networkCfg.Jobs); err != nil {
+			return err
+		}
+		if err := networkCfg.ValidateSampling(); err != nil {
+			return err
+		}
+		if err := networkCfg.ValidatePorts(); err != nil {
+			return err
+		}
+		if err := networkCfg.ValidateDetailLevel(); err != nil {
+			return err
+		}
+		if err := networkCfg.ValidateFileNaming(); err != nil {
+			return err
+		}
+	}
+	if err := validateDistinctFileNamings(c.HostNetwork, c.PodNetwork); err != nil {
+		return err
+	}
+	if c.ObservationBuffer != nil {
+		if err := c.ObservationBuffer.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.ResolverCache != nil {
+		if err := c.ResolverCache.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.EventExporter != nil {
+		if err := c.EventExporter.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.SafetyValve != nil {
+		if err := c.SafetyValve.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.CABundle != nil {
+		if err := c.CABundle.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateRetentionAndLogDropping checks the ranges of the retention hours and the log dropping factors, globally and
+// per network.
+func (c *AgentConfig) ValidateRetentionAndLogDropping() error {
+	if err := validateRetentionHours(c.RetentionHours); err != nil {
+		return err
+	}
+	if err := validateLogDroppingFactor(c.LogDroppingFactor); err != nil {
+		return err
+	}
+	for _, networkCfg := range []*NetworkConfig{c.HostNetwork, c.PodNetwork} {
+		if networkCfg == nil {
+			continue
+		}
+		if err := validateRetentionHours(networkCfg.RetentionHours); err != nil {
+			return err
+		}
+		if networkCfg.LogDroppingFactor != nil {
+			if err := validateLogDroppingFactor(*networkCfg.LogDroppingFactor); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateRetentionHours checks the range of the retention hours. 0 means unset.
+func validateRetentionHours(hours int) error {
+	if hours != 0 && (hours < MinRetentionHours || hours > MaxRetentionHours) {
+		return fmt.Errorf("invalid retention hours %d: must be between %d and %d", hours, MinRetentionHours, MaxRetentionHours)
+	}
+	return nil
+}
+
+func validateLogDroppingFactor(factor float64) error {
+	if factor < 0 || factor > 1 {
+		return fmt.Errorf("invalid log dropping factor %g: must be between 0 and 1", factor)
+	}
+	return nil
+}
+
+// Warnings returns settings which are valid, but probably unintended.
+func (c *AgentConfig) Warnings() []string {
+	var warnings []string
+	check := func(name string, factor float64) {
+		if factor > LogDroppingFactorWarningThreshold {
+			warnings = append(warnings, fmt.Sprintf("%s %g drops nearly all observations from the log", name, factor))
+		}
+	}
+	check("logDroppingFactor", c.LogDroppingFactor)
+	for name, networkCfg := range map[string]*NetworkConfig{"hostNetwork": c.HostNetwork, "podNetwork": c.PodNetwork} {
+		if networkCfg != nil && networkCfg.LogDroppingFactor != nil {
+			check(name+".logDroppingFactor", *networkCfg.LogDroppingFactor)
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// FastRecheckConcurrencyOrDefault returns the maximum number of concurrently running fast re-checks.
+func (c *AgentConfig) FastRecheckConcurrencyOrDefault() int {
+	if c.FastRecheckConcurrency == 0 {
+		return DefaultFastRecheckConcurrency
+	}
+	return c.FastRecheckConcurrency
+}
+
+// RetentionHoursFor returns the retention hours of the observations of the network, i.e. the override of the network config
+// or the global value.
+func (c *AgentConfig) RetentionHoursFor(networkCfg *NetworkConfig) int {
+	if networkCfg != nil && networkCfg.RetentionHours != 0 {
+		return networkCfg.RetentionHours
+	}
+	return c.RetentionHours
+}
+
+// LogDroppingFactorFor returns the log dropping factor of the network, i.e. the override of the network config or the global value.
+func (c *AgentConfig) LogDroppingFactorFor(networkCfg *NetworkConfig) float64 {
+	if networkCfg != nil && networkCfg.LogDroppingFactor != nil {
+		return *networkCfg.LogDroppingFactor
+	}
+	return c.LogDroppingFactor
+}
+
+// ValidateDetailLevel checks the detail level.
+func (c *NetworkConfig) ValidateDetailLevel() error {
+	switch c.DetailLevel {
+	case "", DetailLevelMinimal, DetailLevelStandard, DetailLevelFull:
+		return nil
+	default:
+		return fmt.Errorf("invalid detail level %q: must be %q, %q or %q", c.DetailLevel, DetailLevelMinimal, DetailLevelStandard, DetailLevelFull)
+	}
+}
+
+// DetailLevelOrDefault returns the detail level or DetailLevelStandard if not set.
+func (c *NetworkConfig) DetailLevelOrDefault() DetailLevel {
+	if c == nil || c.DetailLevel == "" {
+		return DetailLevelStandard
+	}
+	return c.DetailLevel
+}
+
+// ValidateSampling checks sample strategy and size.
+func (c *NetworkConfig) ValidateSampling() error {
+	switch c.SampleStrategy {
+	case "", SampleAll:
+		return nil
+	case SampleRandom, SampleConsistentHash:
+		if c.SampleSize < 1 {
+			return fmt.Errorf("invalid sample size %d for sample strategy %q: must be positive", c.SampleSize, c.SampleStrategy)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid sample strategy %q: must be %q, %q or %q", c.SampleStrategy, SampleAll, SampleRandom, SampleConsistentHash)
+	}
+}
+
+// ValidatePorts checks that the ports of the listeners of the agent are distinct, as the agent fails to start otherwise.
+func (c *NetworkConfig) ValidatePorts() error {
+	used := map[int]string{}
+	for _, p := range []struct {
+		name string
+		port int
+	}{
+		{"grpcPort", c.GRPCPort},
+		{"httpPort", c.HttpPort},
+		{"echoPort", c.EchoPort},
+		{"adminGRPCPort", c.AdminGRPCPort},
+	} {
+		if p.port == 0 {
+			continue
+		}
+		if p.port < 0 || p.port > 65535 {
+			return fmt.Errorf("invalid %s %d", p.name, p.port)
+		}
+		if other, ok := used[p.port]; ok {
+			return fmt.Errorf("invalid %s %d: already used as %s", p.name, p.port, other)
+		}
+		used[p.port] = p.name
+	}
+	return nil
+}
+
+// HasAdminListener returns true if the GRPC server is split into the cluster and admin listeners.
+func (c *NetworkConfig) HasAdminListener() bool {
+	return c.AdminGRPCPort != 0 || c.AdminSocket
+}
+
+// AdminSocketPath returns the path of the unix socket of the admin GRPC listener in the given output directory.
+func (c *NetworkConfig) AdminSocketPath(outputDir string) string {
+	prefix := "agent"
+	if c.DataFilePrefix != "" {
+		prefix = c.DataFilePrefix
+	}
+	// the socket must not start with the prefix, otherwise it is deleted with the outdated observation files
+	return filepath.Join(outputDir, "admin-"+prefix+".sock")
 }
 
 type Job struct {
 	JobID string   `json:"jobID"`
 	Args  []string `json:"args,omitempty"`
+	// TriggeredBy makes the job conditional. It only runs for destinations where the edge of the trigger job is in the given state.
+	// The period of the job is used as rate limit.
+	TriggeredBy *JobTrigger `json:"triggeredBy,omitempty"`
+	// Labels are attached to the observations of the job and exported with the metric `nwpd_job_info`.
+	// To limit the metric cardinality, at most MaxJobLabels labels with values of low cardinality should be used.
+	Labels map[string]string `json:"labels,omitempty"`
+	// ReuseConnectionResult shares the socket-level result of the checks (TCP connect or ICMP echo) with other jobs
+	// having this option, if they check the same protocol, IP address and port within the period of the job.
+	ReuseConnectionResult bool `json:"reuseConnectionResult,omitempty"`
+	// FastRecheck re-checks a failing edge immediately a few times instead of waiting for the next period.
+	FastRecheck *FastRecheck `json:"fastRecheck,omitempty"`
+	// Schedule restricts the job to a daily time window. If not set, the job is always active.
+	Schedule *JobSchedule `json:"schedule,omitempty"`
+	// BypassResolverCache resolves the host names of the destinations on each check instead of using the resolver cache
+	// of the agent, e.g. for checks whose purpose is DNS itself.
+	BypassResolverCache bool `json:"bypassResolverCache,omitempty"`
+	// ExpectedLatency is the latency SLO of the job. Successful checks exceeding it are marked as latency breach.
+	ExpectedLatency *ExpectedLatency `json:"expectedLatency,omitempty"`
+}
+
+const (
+	// DefaultFastRecheckInterval is the default interval between the fast re-checks of a failing edge.
+	DefaultFastRecheckInterval = 2 * time.Second
+	// MinFastRecheckInterval is the minimum interval between the fast re-checks of a failing edge.
+	MinFastRecheckInterval = 500 * time.Millisecond
+	// MaxFastRecheckCount is the maximum number of fast re-checks after a failure.
+	MaxFastRecheckCount = 10
+	// DefaultFastRecheckConcurrency is the default maximum number of concurrently running fast re-checks per agent.
+	DefaultFastRecheckConcurrency = 10
+)
+
+// FastRecheck configures immediate re-checks of an edge after a failure to confirm or clear it faster than the period of the job.
+// The re-checks are recorded like other observations and reference the failing observation as trigger.
+type FastRecheck struct {
+	// Count is the maximum number of re-checks after a failure (1-10). The re-checks stop on the first success.
+	// If all fail, the edge is confirmed down and not re-checked again until it is ok.
+	Count int `json:"count"`
+	// Interval is the interval between the re-checks (default 2s, at least 500ms).
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// IntervalOrDefault returns the interval or DefaultFastRecheckInterval if not set.
+func (r *FastRecheck) IntervalOrDefault() time.Duration {
+	if r.Interval == nil {
+		return DefaultFastRecheckInterval
+	}
+	return r.Interval.Duration
+}
+
+// Validate checks count and interval of the fast re-checks.
+func (r *FastRecheck) Validate() error {
+	if r.Count < 1 || r.Count > MaxFastRecheckCount {
+		return fmt.Errorf("invalid fast recheck count %d: must be between 1 and %d", r.Count, MaxFastRecheckCount)
+	}
+	if r.IntervalOrDefault() < MinFastRecheckInterval {
+		return fmt.Errorf("invalid fast recheck interval %s: must be >= %s", r.IntervalOrDefault(), MinFastRecheckInterval)
+	}
+	return nil
+}
+
+// ExpectedLatency is the latency SLO of a job, which successful checks should not exceed.
+type ExpectedLatency struct {
+	// Default is the expected latency of all destinations without a specific expected latency.
+	Default *metav1.Duration `json:"default,omitempty"`
+	// Destinations are the expected latencies of specific destinations by destination host.
+	Destinations map[string]metav1.Duration `json:"destinations,omitempty"`
+}
+
+// For returns the expected latency of the destination host or 0 if there is none.
+func (l *ExpectedLatency) For(destHost string) time.Duration {
+	if l == nil {
+		return 0
+	}
+	if d, ok := l.Destinations[destHost]; ok {
+		return d.Duration
+	}
+	if l.Default != nil {
+		return l.Default.Duration
+	}
+	return 0
+}
+
+// Validate checks that at least one expected latency is set and all are positive.
+func (l *ExpectedLatency) Validate() error {
+	if l.Default == nil && len(l.Destinations) == 0 {
+		return fmt.Errorf("expected latency without default or destinations")
+	}
+	if l.Default != nil && l.Default.Duration <= 0 {
+		return fmt.Errorf("invalid default expected latency %s: must be > 0", l.Default.Duration)
+	}
+	for dest, d := range l.Destinations {
+		if d.Duration <= 0 {
+			return fmt.Errorf("invalid expected latency %s of destination %s: must be > 0", d.Duration, dest)
+		}
+	}
+	return nil
+}
+
+const (
+	// MaxJobLabels is the maximum number of labels of a job.
+	MaxJobLabels = 5
+	// MaxJobLabelValueLength is the maximum length of a job label value.
+	MaxJobLabelValueLength = 63
+)
+
+var (
+	jobLabelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	// reservedJobLabelNames are the label names used by the metrics of the agent
+	reservedJobLabelNames = map[string]bool{"jobid": true, "protocol": true, "src": true, "dest": true, "status": true}
+)
+
+// ValidateJobLabels checks that the label names are valid Prometheus label names not used by the agent metrics
+// and restricts the number of labels and the length of the values.
+func ValidateJobLabels(labels map[string]string) error {
+	if len(labels) > MaxJobLabels {
+		return fmt.Errorf("too many labels (%d > %d)", len(labels), MaxJobLabels)
+	}
+	for name, value := range labels {
+		if !jobLabelNameRegexp.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if reservedJobLabelNames[name] {
+			return fmt.Errorf("reserved label name %q", name)
+		}
+		if len(value) > MaxJobLabelValueLength {
+			return fmt.Errorf("value of label %s too long (%d > %d)", name, len(value), MaxJobLabelValueLength)
+		}
+	}
+	return nil
+}
+
+// TriggerCondition is the state of the trigger job edge to run a triggered job.
+type TriggerCondition string
+
+const (
+	// TriggerOnFailure runs the triggered job if the last observation of the trigger job failed.
+	TriggerOnFailure TriggerCondition = "onFailure"
+	// TriggerOnDegraded runs the triggered job if any observation of the trigger job failed in the current report period.
+	TriggerOnDegraded TriggerCondition = "onDegraded"
+)
+
+type JobTrigger struct {
+	// JobID is the ID of the trigger job.
+	JobID string `json:"jobID"`
+	// Condition is the state of the trigger job edge.
+	Condition TriggerCondition `json:"condition"`
+}
+
+// ValidateJobs checks for duplicate job IDs and validates the job labels, fast re-checks, expected latencies, schedules and triggers.
+// Triggers must reference existing jobs with a known condition and must not form cycles.
+func ValidateJobs(jobs []Job) error {
+	triggers := map[string]string{}
+	for _, job := range jobs {
+		if _, ok := triggers[job.JobID]; ok {
+			return fmt.Errorf("duplicate job ID %s", job.JobID)
+		}
+		if job.JobID == ResolverCacheJobID {
+			return fmt.Errorf("job ID %s is reserved for the observations of the resolver cache", job.JobID)
+		}
+		if job.JobID == SafetyValveJobID {
+			return fmt.Errorf("job ID %s is reserved for the observations of the safety valve", job.JobID)
+		}
+		triggers[job.JobID] = ""
+		if err := ValidateJobLabels(job.Labels); err != nil {
+			return fmt.Errorf("job %s: %w", job.JobID, err)
+		}
+		if job.FastRecheck != nil {
+			if err := job.FastRecheck.Validate(); err != nil {
+				return fmt.Errorf("job %s: %w", job.JobID, err)
+			}
+		}
+		if job.ExpectedLatency != nil {
+			if err := job.ExpectedLatency.Validate(); err != nil {
+				return fmt.Errorf("job %s: %w", job.JobID, err)
+			}
+		}
+		if _, err := job.Schedule.Compile(); err != nil {
+			return fmt.Errorf("job %s: %w", job.JobID, err)
+		}
+	}
+	for _, job := range jobs {
+		if job.TriggeredBy == nil {
+			continue
+		}
+		switch job.TriggeredBy.Condition {
+		case TriggerOnFailure, TriggerOnDegraded:
+		default:
+			return fmt.Errorf("job %s: invalid trigger condition %q", job.JobID, job.TriggeredBy.Condition)
+		}
+		if _, ok := triggers[job.TriggeredBy.JobID]; !ok {
+			return fmt.Errorf("job %s: unknown trigger job ID %s", job.JobID, job.TriggeredBy.JobID)
+		}
+		triggers[job.JobID] = job.TriggeredBy.JobID
+	}
+	for _, job := range jobs {
+		visited := map[string]bool{job.JobID: true}
+		for id := triggers[job.JobID]; id != ""; id = triggers[id] {
+			if visited[id] {
+				return fmt.Errorf("job %s: cyclic trigger dependency", job.JobID)
+			}
+			visited[id] = true
+		}
+	}
+	return nil
 }
 
 type K8sExporterConfig struct {
@@ -65,3 +854,97 @@ type K8sExporterConfig struct {
 	// HeartbeatPeriod defines the update frequency of the node conditions.
 	HeartbeatPeriod *metav1.Duration `json:"heartbeatPeriod,omitempty"`
 }
+
+// EventCategory is a category of problems exported as Kubernetes events by the event exporter.
+type EventCategory string
+
+const (
+	// EventCategoryNodes are the failed checks to nodes or pods of the cluster, summarized by destination zone.
+	EventCategoryNodes EventCategory = "nodes"
+	// EventCategoryIngress are the failed checks to load balancers and ingress endpoints.
+	EventCategoryIngress EventCategory = "ingress"
+	// EventCategoryExternal are the failed checks to all other destinations, e.g. the kube-apiserver or the instance metadata service.
+	EventCategoryExternal EventCategory = "external"
+)
+
+// EventCategories are all categories of the event exporter.
+var EventCategories = []EventCategory{EventCategoryNodes, EventCategoryIngress, EventCategoryExternal}
+
+const (
+	// DefaultEventExporterWindow is the default window summarized by the events.
+	DefaultEventExporterWindow = 5 * time.Minute
+	// DefaultEventExporterMaxEvents is the default maximum number of events created or updated per window.
+	DefaultEventExporterMaxEvents = 3
+	// DefaultEventExporterMaxMessageSize is the default maximum size of the event messages.
+	DefaultEventExporterMaxMessageSize = 1024
+	// minEventExporterMessageSize is the minimum value of the maximum size of the event messages.
+	minEventExporterMessageSize = 128
+)
+
+// EventExporterConfig is the configuration of the event exporter. Per window, it creates or updates at most one event
+// per node and category summarizing the failed checks of the agent.
+type EventExporterConfig struct {
+	// Enabled if true, the event exporter is active.
+	Enabled bool `json:"enabled"`
+	// Window is the period summarized by an event (default 5m).
+	Window *metav1.Duration `json:"window,omitempty"`
+	// Categories restricts the exported categories (default all).
+	Categories []EventCategory `json:"categories,omitempty"`
+	// MaxEventsPerWindow is the maximum number of events created or updated per window (default 3).
+	MaxEventsPerWindow int `json:"maxEventsPerWindow,omitempty"`
+	// MaxMessageSize is the maximum size of an event message in bytes (default 1024).
+	MaxMessageSize int `json:"maxMessageSize,omitempty"`
+}
+
+// Validate checks the event exporter config.
+func (c *EventExporterConfig) Validate() error {
+	if c.Window != nil && c.Window.Duration < 1*time.Minute {
+		return fmt.Errorf("invalid event exporter window %s: must be >= 1m", c.Window.Duration)
+	}
+	for _, category := range c.Categories {
+		switch category {
+		case EventCategoryNodes, EventCategoryIngress, EventCategoryExternal:
+		default:
+			return fmt.Errorf("invalid event exporter category %q: must be %q, %q or %q", category, EventCategoryNodes, EventCategoryIngress, EventCategoryExternal)
+		}
+	}
+	if c.MaxEventsPerWindow < 0 {
+		return fmt.Errorf("invalid event exporter max events per window %d: must not be negative", c.MaxEventsPerWindow)
+	}
+	if c.MaxMessageSize != 0 && c.MaxMessageSize < minEventExporterMessageSize {
+		return fmt.Errorf("invalid event exporter max message size %d: must be >= %d", c.MaxMessageSize, minEventExporterMessageSize)
+	}
+	return nil
+}
+
+// WindowOrDefault returns the window or the default window if not set.
+func (c *EventExporterConfig) WindowOrDefault() time.Duration {
+	if c.Window == nil {
+		return DefaultEventExporterWindow
+	}
+	return c.Window.Duration
+}
+
+// CategoriesOrDefault returns the categories or all categories if not set.
+func (c *EventExporterConfig) CategoriesOrDefault() []EventCategory {
+	if len(c.Categories) == 0 {
+		return EventCategories
+	}
+	return c.Categories
+}
+
+// MaxEventsPerWindowOrDefault returns the maximum number of events per window or the default if not set.
+func (c *EventExporterConfig) MaxEventsPerWindowOrDefault() int {
+	if c.MaxEventsPerWindow == 0 {
+		return DefaultEventExporterMaxEvents
+	}
+	return c.MaxEventsPerWindow
+}
+
+// MaxMessageSizeOrDefault returns the maximum message size or the default if not set.
+func (c *EventExporterConfig) MaxMessageSizeOrDefault() int {
+	if c.MaxMessageSize == 0 {
+		return DefaultEventExporterMaxMessageSize
+	}
+	return c.MaxMessageSize
+}
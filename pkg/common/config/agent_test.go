@@ -0,0 +1,269 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestValidateJobs(t *testing.T) {
+	trigger := func(jobID string, condition TriggerCondition) *JobTrigger {
+		return &JobTrigger{JobID: jobID, Condition: condition}
+	}
+	for _, testCase := range []struct {
+		name  string
+		jobs  []Job
+		valid bool
+	}{
+		{name: "no triggers", jobs: []Job{{JobID: "a"}, {JobID: "b"}}, valid: true},
+		{name: "valid trigger", jobs: []Job{{JobID: "a"}, {JobID: "b", TriggeredBy: trigger("a", TriggerOnFailure)}}, valid: true},
+		{name: "chained triggers", jobs: []Job{
+			{JobID: "a"},
+			{JobID: "b", TriggeredBy: trigger("a", TriggerOnDegraded)},
+			{JobID: "c", TriggeredBy: trigger("b", TriggerOnFailure)},
+		}, valid: true},
+		{name: "duplicate job ID", jobs: []Job{{JobID: "a"}, {JobID: "a"}}},
+		{name: "unknown trigger job", jobs: []Job{{JobID: "a", TriggeredBy: trigger("x", TriggerOnFailure)}}},
+		{name: "invalid condition", jobs: []Job{{JobID: "a"}, {JobID: "b", TriggeredBy: trigger("a", "onSuccess")}}},
+		{name: "self trigger", jobs: []Job{{JobID: "a", TriggeredBy: trigger("a", TriggerOnFailure)}}},
+		{name: "labels", jobs: []Job{{JobID: "a", Labels: map[string]string{"tier": "external", "team_name": ""}}}, valid: true},
+		{name: "invalid label name", jobs: []Job{{JobID: "a", Labels: map[string]string{"dependency-name": "x"}}}},
+		{name: "reserved label name", jobs: []Job{{JobID: "a", Labels: map[string]string{"jobid": "x"}}}},
+		{name: "internal label name", jobs: []Job{{JobID: "a", Labels: map[string]string{"__name__": "x"}}}},
+		{name: "too many labels", jobs: []Job{{JobID: "a", Labels: map[string]string{"a": "", "b": "", "c": "", "d": "", "e": "", "f": ""}}}},
+		{name: "fast recheck", jobs: []Job{{JobID: "a", FastRecheck: &FastRecheck{Count: 3}}}, valid: true},
+		{name: "fast recheck count", jobs: []Job{{JobID: "a", FastRecheck: &FastRecheck{Count: 11}}}},
+		{name: "fast recheck interval", jobs: []Job{{JobID: "a", FastRecheck: &FastRecheck{Count: 1, Interval: &metav1.Duration{Duration: 100 * time.Millisecond}}}}},
+		{name: "expected latency", jobs: []Job{{JobID: "a", ExpectedLatency: &ExpectedLatency{
+			Default:      &metav1.Duration{Duration: 5 * time.Millisecond},
+			Destinations: map[string]metav1.Duration{"db": {Duration: 20 * time.Millisecond}},
+		}}}, valid: true},
+		{name: "empty expected latency", jobs: []Job{{JobID: "a", ExpectedLatency: &ExpectedLatency{}}}},
+		{name: "zero expected latency", jobs: []Job{{JobID: "a", ExpectedLatency: &ExpectedLatency{Default: &metav1.Duration{}}}}},
+		{name: "negative destination latency", jobs: []Job{{JobID: "a", ExpectedLatency: &ExpectedLatency{
+			Destinations: map[string]metav1.Duration{"db": {Duration: -time.Millisecond}},
+		}}}},
+		{name: "reserved job ID", jobs: []Job{{JobID: ResolverCacheJobID}}},
+		{name: "reserved safety valve job ID", jobs: []Job{{JobID: SafetyValveJobID}}},
+		{name: "cycle", jobs: []Job{
+			{JobID: "a", TriggeredBy: trigger("c", TriggerOnFailure)},
+			{JobID: "b", TriggeredBy: trigger("a", TriggerOnFailure)},
+			{JobID: "c", TriggeredBy: trigger("b", TriggerOnFailure)},
+		}},
+	} {
+		err := ValidateJobs(testCase.jobs)
+		if testCase.valid {
+			assert.NoError(t, err, testCase.name)
+		} else {
+			assert.Error(t, err, testCase.name)
+		}
+	}
+}
+
+func TestObservationBufferConfig(t *testing.T) {
+	var cfg *ObservationBufferConfig
+	assert.Equal(t, DefaultObservationBufferSize, cfg.SizeOrDefault())
+	assert.Equal(t, BackpressureBlock, cfg.PolicyOrDefault())
+	assert.Equal(t, DefaultDegradedBufferSize, cfg.DegradedSizeOrDefault())
+	assert.Equal(t, DefaultMinFreeDiskPercent, cfg.MinFreeDiskPercentOrDefault())
+
+	minFree := 0.0
+	cfg = &ObservationBufferConfig{Size: 1000, Policy: BackpressureDropOldest, DegradedSize: 500, MinFreeDiskPercent: &minFree}
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, 1000, cfg.SizeOrDefault())
+	assert.Equal(t, 500, cfg.DegradedSizeOrDefault())
+	assert.Equal(t, 0.0, cfg.MinFreeDiskPercentOrDefault())
+	assert.Equal(t, BackpressureDropOldest, cfg.PolicyOrDefault())
+
+	assert.Error(t, (&ObservationBufferConfig{Size: -1}).Validate())
+	assert.Error(t, (&ObservationBufferConfig{DegradedSize: -1}).Validate())
+	assert.Error(t, (&ObservationBufferConfig{Policy: "drop-all"}).Validate())
+	invalidMinFree := 100.0
+	assert.Error(t, (&ObservationBufferConfig{MinFreeDiskPercent: &invalidMinFree}).Validate())
+}
+
+func TestResolverCacheConfig(t *testing.T) {
+	var cfg *ResolverCacheConfig
+	assert.Equal(t, DefaultResolverCacheMinTTL, cfg.MinTTLOrDefault())
+	assert.Equal(t, DefaultResolverCacheMaxTTL, cfg.MaxTTLOrDefault())
+
+	cfg = &ResolverCacheConfig{MinTTL: &metav1.Duration{Duration: 10 * time.Second}, MaxTTL: &metav1.Duration{Duration: time.Minute}}
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, 10*time.Second, cfg.MinTTLOrDefault())
+	assert.Equal(t, time.Minute, cfg.MaxTTLOrDefault())
+
+	assert.Error(t, (&ResolverCacheConfig{MinTTL: &metav1.Duration{Duration: 100 * time.Millisecond}}).Validate())
+	assert.Error(t, (&ResolverCacheConfig{MaxTTL: &metav1.Duration{Duration: time.Second}}).Validate())
+}
+
+func TestCABundleConfig(t *testing.T) {
+	assert.NoError(t, (&AgentConfig{CABundle: &CABundleConfig{File: "/config/ca-bundle/ca.crt", AppendToSystemPool: true}}).Validate())
+	assert.Error(t, (&AgentConfig{CABundle: &CABundleConfig{}}).Validate())
+}
+
+func TestSafetyValveConfig(t *testing.T) {
+	var cfg *SafetyValveConfig
+	assert.Equal(t, DefaultSafetyValveMaxSchedulerLag, cfg.MaxSchedulerLagOrDefault())
+	assert.Equal(t, DefaultSafetyValveMaxThrottledRatio, cfg.MaxThrottledRatioOrDefault())
+	assert.Equal(t, DefaultSafetyValveMinCost, cfg.MinCostOrDefault())
+	assert.Equal(t, DefaultSafetyValveCooldown, cfg.CooldownOrDefault())
+
+	cfg = &SafetyValveConfig{MaxThrottledRatio: 0.8, MinCost: 1.5, Cooldown: &metav1.Duration{Duration: 5 * time.Minute}}
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, 0.8, cfg.MaxThrottledRatioOrDefault())
+	assert.Equal(t, 1.5, cfg.MinCostOrDefault())
+	assert.Equal(t, 5*time.Minute, cfg.CooldownOrDefault())
+
+	assert.Error(t, (&SafetyValveConfig{MaxSchedulerLag: &metav1.Duration{}}).Validate())
+	assert.Error(t, (&SafetyValveConfig{MaxThrottledRatio: 1.5}).Validate())
+	assert.Error(t, (&SafetyValveConfig{MinCost: -1}).Validate())
+	assert.Error(t, (&SafetyValveConfig{Cooldown: &metav1.Duration{Duration: 10 * time.Second}}).Validate())
+}
+
+func TestGeneration(t *testing.T) {
+	cfg := &AgentConfig{OutputDir: "/records", PodNetwork: &NetworkConfig{GRPCPort: 1234}}
+	generation, err := cfg.GenerationOrComputed()
+	assert.NoError(t, err)
+	assert.Len(t, generation, 12)
+
+	assert.NoError(t, cfg.StampGeneration())
+	assert.Equal(t, generation, cfg.Generation)
+	again, err := cfg.ComputeGeneration()
+	assert.NoError(t, err)
+	assert.Equal(t, generation, again, "generation field is ignored")
+
+	cfg.PodNetwork.GRPCPort = 1235
+	changed, err := cfg.ComputeGeneration()
+	assert.NoError(t, err)
+	assert.NotEqual(t, generation, changed)
+	stamped, err := cfg.GenerationOrComputed()
+	assert.NoError(t, err)
+	assert.Equal(t, generation, stamped)
+}
+
+func TestValidateSampling(t *testing.T) {
+	for _, testCase := range []struct {
+		cfg   NetworkConfig
+		valid bool
+	}{
+		{cfg: NetworkConfig{}, valid: true},
+		{cfg: NetworkConfig{SampleStrategy: SampleAll}, valid: true},
+		{cfg: NetworkConfig{SampleStrategy: SampleRandom, SampleSize: 10}, valid: true},
+		{cfg: NetworkConfig{SampleStrategy: SampleConsistentHash, SampleSize: 1}, valid: true},
+		{cfg: NetworkConfig{SampleStrategy: SampleRandom}},
+		{cfg: NetworkConfig{SampleStrategy: "sharded", SampleSize: 10}},
+	} {
+		err := testCase.cfg.ValidateSampling()
+		if testCase.valid {
+			assert.NoError(t, err, testCase.cfg.SampleStrategy)
+		} else {
+			assert.Error(t, err, testCase.cfg.SampleStrategy)
+		}
+	}
+}
+
+func TestValidatePorts(t *testing.T) {
+	assert.NoError(t, (&NetworkConfig{}).ValidatePorts())
+	assert.NoError(t, (&NetworkConfig{GRPCPort: 1011, HttpPort: 1012, EchoPort: 1013, AdminGRPCPort: 1014}).ValidatePorts())
+	err := (&NetworkConfig{GRPCPort: 1011, HttpPort: 1011}).ValidatePorts()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "already used as grpcPort")
+	}
+	assert.Error(t, (&NetworkConfig{EchoPort: 1013, AdminGRPCPort: 1013}).ValidatePorts())
+	assert.Error(t, (&NetworkConfig{HttpPort: 70000}).ValidatePorts())
+}
+
+func TestDetailLevel(t *testing.T) {
+	var cfg *NetworkConfig
+	assert.Equal(t, DetailLevelStandard, cfg.DetailLevelOrDefault())
+	assert.NoError(t, (&NetworkConfig{}).ValidateDetailLevel())
+	for _, level := range []DetailLevel{DetailLevelMinimal, DetailLevelStandard, DetailLevelFull} {
+		cfg = &NetworkConfig{DetailLevel: level}
+		assert.NoError(t, cfg.ValidateDetailLevel())
+		assert.Equal(t, level, cfg.DetailLevelOrDefault())
+	}
+	assert.Error(t, (&NetworkConfig{DetailLevel: "verbose"}).ValidateDetailLevel())
+}
+
+func TestValidateEventExporter(t *testing.T) {
+	for i, testCase := range []struct {
+		cfg   EventExporterConfig
+		valid bool
+	}{
+		{cfg: EventExporterConfig{Enabled: true}, valid: true},
+		{cfg: EventExporterConfig{Window: &metav1.Duration{Duration: 10 * time.Minute}, Categories: []EventCategory{EventCategoryIngress}, MaxEventsPerWindow: 1, MaxMessageSize: 256}, valid: true},
+		{cfg: EventExporterConfig{Window: &metav1.Duration{Duration: 30 * time.Second}}},
+		{cfg: EventExporterConfig{Categories: []EventCategory{"dns"}}},
+		{cfg: EventExporterConfig{MaxEventsPerWindow: -1}},
+		{cfg: EventExporterConfig{MaxMessageSize: 64}},
+	} {
+		err := testCase.cfg.Validate()
+		if testCase.valid {
+			assert.NoError(t, err, i)
+		} else {
+			assert.Error(t, err, i)
+		}
+	}
+	assert.Equal(t, DefaultEventExporterWindow, (&EventExporterConfig{}).WindowOrDefault())
+	assert.Equal(t, EventCategories, (&EventExporterConfig{}).CategoriesOrDefault())
+}
+
+func TestRetentionAndLogDroppingPerNetwork(t *testing.T) {
+	for i, testCase := range []struct {
+		cfg   AgentConfig
+		valid bool
+	}{
+		{cfg: AgentConfig{}, valid: true},
+		{cfg: AgentConfig{RetentionHours: 4, LogDroppingFactor: 0.5}, valid: true},
+		{cfg: AgentConfig{RetentionHours: 168, LogDroppingFactor: 1}, valid: true},
+		{cfg: AgentConfig{RetentionHours: 169}},
+		{cfg: AgentConfig{RetentionHours: -1}},
+		{cfg: AgentConfig{LogDroppingFactor: 1.1}},
+		{cfg: AgentConfig{LogDroppingFactor: -0.1}},
+		{cfg: AgentConfig{HostNetwork: &NetworkConfig{RetentionHours: 24}, PodNetwork: &NetworkConfig{RetentionHours: 2}}, valid: true},
+		{cfg: AgentConfig{PodNetwork: &NetworkConfig{RetentionHours: 200}}},
+		{cfg: AgentConfig{PodNetwork: &NetworkConfig{LogDroppingFactor: pointer.Float64(2)}}},
+	} {
+		err := testCase.cfg.Validate()
+		if testCase.valid {
+			assert.NoError(t, err, i)
+		} else {
+			assert.Error(t, err, i)
+		}
+	}
+
+	cfg := &AgentConfig{
+		RetentionHours:    4,
+		LogDroppingFactor: 0.5,
+		HostNetwork:       &NetworkConfig{RetentionHours: 24, LogDroppingFactor: pointer.Float64(0)},
+		PodNetwork:        &NetworkConfig{},
+	}
+	assert.Equal(t, 24, cfg.RetentionHoursFor(cfg.HostNetwork))
+	assert.Equal(t, 4, cfg.RetentionHoursFor(cfg.PodNetwork))
+	assert.Equal(t, 4, cfg.RetentionHoursFor(nil))
+	assert.Equal(t, 0.0, cfg.LogDroppingFactorFor(cfg.HostNetwork))
+	assert.Equal(t, 0.5, cfg.LogDroppingFactorFor(cfg.PodNetwork))
+	assert.Equal(t, 0.5, cfg.LogDroppingFactorFor(nil))
+	assert.Empty(t, cfg.Warnings())
+
+	cfg.LogDroppingFactor = 0.995
+	cfg.PodNetwork.LogDroppingFactor = pointer.Float64(1)
+	assert.Equal(t, []string{"logDroppingFactor 0.995 drops nearly all observations from the log", "podNetwork.logDroppingFactor 1 drops nearly all observations from the log"}, cfg.Warnings())
+}
+
+func TestExpectedLatencyFor(t *testing.T) {
+	var none *ExpectedLatency
+	assert.Zero(t, none.For("db"))
+	latency := &ExpectedLatency{Destinations: map[string]metav1.Duration{"db": {Duration: 20 * time.Millisecond}}}
+	assert.Equal(t, 20*time.Millisecond, latency.For("db"))
+	assert.Zero(t, latency.For("api"))
+	latency.Default = &metav1.Duration{Duration: 5 * time.Millisecond}
+	assert.Equal(t, 5*time.Millisecond, latency.For("api"))
+}
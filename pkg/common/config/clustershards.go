@@ -0,0 +1,37 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ClusterConfigShardFilename returns the name of the file of a shard of the cluster config stored in the given file.
+// The shards are stored next to the cluster config, e.g. 'cluster-config-shard-0.yaml' for 'cluster-config.yaml'.
+func ClusterConfigShardFilename(configFile string, shard int) string {
+	ext := filepath.Ext(configFile)
+	return fmt.Sprintf("%s-shard-%d%s", strings.TrimSuffix(configFile, ext), shard, ext)
+}
+
+// MergeShards adds the nodes and pod endpoints of the shards to a sharded cluster config. The shards must belong
+// to the same generation of the cluster config, otherwise an error is returned, e.g. while the config maps are updated.
+// The merged cluster config is no longer sharded.
+func (cc *ClusterConfig) MergeShards(shards []*ClusterConfig) error {
+	if len(shards) != cc.Shards {
+		return fmt.Errorf("expected %d shards of cluster config, got %d", cc.Shards, len(shards))
+	}
+	for i, shard := range shards {
+		if shard.ShardsGeneration != cc.ShardsGeneration {
+			return fmt.Errorf("shard %d of cluster config has generation %q instead of %q", i, shard.ShardsGeneration, cc.ShardsGeneration)
+		}
+		cc.Nodes = append(cc.Nodes, shard.Nodes...)
+		cc.PodEndpoints = append(cc.PodEndpoints, shard.PodEndpoints...)
+	}
+	cc.Shards = 0
+	cc.ShardsGeneration = ""
+	return nil
+}
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterConfigShardFilename(t *testing.T) {
+	assert.Equal(t, "/etc/cluster-config-shard-0.yaml", ClusterConfigShardFilename("/etc/cluster-config.yaml", 0))
+	assert.Equal(t, "cluster-config-shard-3", ClusterConfigShardFilename("cluster-config", 3))
+}
+
+func TestLoadShardedClusterConfig(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "cluster-config.yaml")
+	write := func(filename, content string) {
+		assert.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+	}
+	write(configFile, "shards: 2\nshardsGeneration: gen1\nnodeSelector: pool=a\n")
+	write(ClusterConfigShardFilename(configFile, 0), "shardsGeneration: gen1\nnodes:\n- hostname: node1\n  internalIP: 10.0.0.1\n  zone: z1\n")
+	compressed, err := Gzip([]byte("shardsGeneration: gen1\nnodes:\n- hostname: node2\n  internalIP: 10.0.0.2\npodEndpoints:\n- nodename: node2\n  podname: pod2\n  podIP: 100.64.0.2\n  port: 8880\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(ClusterConfigShardFilename(configFile, 1)+CompressedFileSuffix, compressed, 0644))
+
+	cfg, err := LoadClusterConfig(configFile)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []Node{{Hostname: "node1", InternalIP: "10.0.0.1", Zone: "z1"}, {Hostname: "node2", InternalIP: "10.0.0.2"}}, cfg.Nodes)
+		assert.Len(t, cfg.PodEndpoints, 1)
+		assert.Equal(t, "pool=a", cfg.NodeSelector)
+		assert.Zero(t, cfg.Shards)
+		assert.Empty(t, cfg.ShardsGeneration)
+	}
+
+	// shards of a previous generation while the config maps are updated
+	write(ClusterConfigShardFilename(configFile, 0), "shardsGeneration: gen0\n")
+	_, err = LoadClusterConfig(configFile)
+	assert.Error(t, err)
+
+	// missing shard
+	write(configFile, "shards: 3\nshardsGeneration: gen1\n")
+	_, err = LoadClusterConfig(configFile)
+	assert.Error(t, err)
+}
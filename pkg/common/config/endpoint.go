@@ -4,24 +4,74 @@
 
 package config
 
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WithDestHost is implemented by the check targets. The destination host is the stable identity of the destination,
+// i.e. an edge is identified by source node name, destination host and job ID.
 type WithDestHost interface {
 	DestHost() string
 }
 
+// WithDestAddress is optionally implemented by check targets to report the dialed address separately from the destination host.
+type WithDestAddress interface {
+	DestAddress() string
+}
+
+// WithDestIPPort is optionally implemented by check targets to report IP address and port of the destination
+// as structured fields. The IP address is empty if only the hostname is known, the port is 0 if not applicable.
+type WithDestIPPort interface {
+	DestIP() string
+	DestPort() int
+}
+
+const (
+	// NodeAddressTypeInternal selects the internal IP of the nodes.
+	NodeAddressTypeInternal = "internal"
+	// NodeAddressTypeExternal selects the external IP of the nodes.
+	NodeAddressTypeExternal = "external"
+)
+
 type Node struct {
 	Hostname   string `json:"hostname"`
 	InternalIP string `json:"internalIP"`
+	// ExternalIP is the first address of type ExternalIP of the node status, empty if the node has none
+	ExternalIP string `json:"externalIP,omitempty"`
+	// Zone is the value of the label topology.kubernetes.io/zone of the node
+	Zone string `json:"zone,omitempty"`
+	// Pool is the value of the label worker.gardener.cloud/pool of the node
+	Pool string `json:"pool,omitempty"`
 }
 
 func (n Node) DestHost() string {
 	return n.Hostname
 }
 
+func (n Node) DestAddress() string {
+	return n.InternalIP
+}
+
+func (n Node) DestIP() string {
+	return n.InternalIP
+}
+
+func (n Node) DestPort() int {
+	return 0
+}
+
 type PodEndpoint struct {
 	Nodename string `json:"nodename"`
 	Podname  string `json:"podname"`
 	PodIP    string `json:"podIP"`
 	Port     int32  `json:"port"`
+	// StartTime is the start time of the pod, used for the warm-up period of the checks. It is nil if unknown.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
 }
 
 type Endpoint struct {
@@ -30,10 +80,135 @@ type Endpoint struct {
 	Port     int    `json:"port"`
 }
 
+// ParseEndpoint parses an endpoint in the format <hostname>:<ip>:<port> as used by the option '--endpoints' of the job
+// 'checkTCPPort'. An IPv6 address must be enclosed in brackets, e.g. 'db:[fd00::1]:5432'. Instead of an IP address, a
+// DNS name can be given, which is resolved on each check.
+func ParseEndpoint(s string) (Endpoint, error) {
+	hostname, rest, found := strings.Cut(s, ":")
+	if !found {
+		return Endpoint{}, fmt.Errorf("invalid endpoint %q: expected format <hostname>:<ip>:<port>", s)
+	}
+	if err := validateEndpointHostname(hostname); err != nil {
+		return Endpoint{}, fmt.Errorf("invalid endpoint %q: %w", s, err)
+	}
+	ip, portStr, err := net.SplitHostPort(rest)
+	if err != nil {
+		if strings.Count(rest, ":") > 1 && !strings.HasPrefix(rest, "[") {
+			return Endpoint{}, fmt.Errorf("invalid endpoint %q: IPv6 addresses must be enclosed in brackets", s)
+		}
+		return Endpoint{}, fmt.Errorf("invalid endpoint %q: expected format <hostname>:<ip>:<port>", s)
+	}
+	if err := validateEndpointIP(ip, strings.HasPrefix(rest, "[")); err != nil {
+		return Endpoint{}, fmt.Errorf("invalid endpoint %q: %w", s, err)
+	}
+	port, err := ParsePort(portStr)
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("invalid endpoint %q: %w", s, err)
+	}
+	return Endpoint{Hostname: hostname, IP: ip, Port: port}, nil
+}
+
+// ParseNode parses a host in the format <hostname>:<ip> as used by the option '--hosts' of the job 'pingHost'.
+// An IPv6 address may be given without brackets.
+// ValidateNodeAddressType checks that the address type is 'internal' or 'external'.
+func ValidateNodeAddressType(addressType string) error {
+	if addressType != NodeAddressTypeInternal && addressType != NodeAddressTypeExternal {
+		return fmt.Errorf("invalid node address type %q: must be '%s' or '%s'", addressType, NodeAddressTypeInternal, NodeAddressTypeExternal)
+	}
+	return nil
+}
+
+// NodesWithAddressType returns the nodes with their internal IP replaced by the IP of the address type.
+// For the external address type, nodes without external IP are omitted.
+func NodesWithAddressType(nodes []Node, addressType string) []Node {
+	if addressType != NodeAddressTypeExternal {
+		return nodes
+	}
+	var result []Node
+	for _, node := range nodes {
+		if node.ExternalIP != "" {
+			node.InternalIP = node.ExternalIP
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+func ParseNode(s string) (Node, error) {
+	hostname, ip, found := strings.Cut(s, ":")
+	if !found {
+		return Node{}, fmt.Errorf("invalid host %q: expected format <hostname>:<ip>", s)
+	}
+	if err := validateEndpointHostname(hostname); err != nil {
+		return Node{}, fmt.Errorf("invalid host %q: %w", s, err)
+	}
+	bracketed := strings.HasPrefix(ip, "[") && strings.HasSuffix(ip, "]")
+	if bracketed {
+		ip = ip[1 : len(ip)-1]
+	} else if net.ParseIP(ip) != nil {
+		bracketed = strings.Contains(ip, ":")
+	}
+	if err := validateEndpointIP(ip, bracketed); err != nil {
+		return Node{}, fmt.Errorf("invalid host %q: %w", s, err)
+	}
+	return Node{Hostname: hostname, InternalIP: ip}, nil
+}
+
+// ParsePort parses a port number in the range 1-65535.
+func ParsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q: must be a number between 1 and 65535", s)
+	}
+	return port, nil
+}
+
+func validateEndpointHostname(hostname string) error {
+	if hostname == "" {
+		return fmt.Errorf("missing hostname")
+	}
+	if strings.ContainsAny(hostname, "[]/ \t\r\n") {
+		return fmt.Errorf("invalid hostname %q", hostname)
+	}
+	return nil
+}
+
+// validateEndpointIP checks the IP address or DNS name of an endpoint. Bracketed addresses must be IPv6 addresses.
+func validateEndpointIP(ip string, bracketed bool) error {
+	if ip == "" {
+		return fmt.Errorf("missing ip")
+	}
+	parsed := net.ParseIP(ip)
+	if bracketed {
+		if parsed == nil || parsed.To4() != nil {
+			return fmt.Errorf("invalid IPv6 address %q", ip)
+		}
+		return nil
+	}
+	if parsed == nil && strings.ContainsAny(ip, "[]:/% \t\r\n") {
+		return fmt.Errorf("invalid ip %q", ip)
+	}
+	return nil
+}
+
+// String returns the endpoint in the format parsed by ParseEndpoint.
+func (e Endpoint) String() string {
+	return e.Hostname + ":" + net.JoinHostPort(e.IP, strconv.Itoa(e.Port))
+}
+
 func (e Endpoint) DestHost() string {
 	return e.Hostname
 }
 
+// DestAddress returns the IP and port or the hostname and port if the IP is not known.
+func (e Endpoint) DestAddress() string {
+	host := e.IP
+	if host == "" {
+		host = e.Hostname
+	}
+	return net.JoinHostPort(host, strconv.Itoa(e.Port))
+}
+
 type ClusterConfig struct {
 	// Nodes are the known nodes.
 	Nodes []Node `json:"nodes,omitempty"`
@@ -43,6 +218,27 @@ type ClusterConfig struct {
 	InternalKubeAPIServer *Endpoint `json:"internalKubeAPIServer,omitempty"`
 	// KubeAPIServer is the discovered external address of the kube-apiserver (relies on Gardener shoot-info)
 	KubeAPIServer *Endpoint `json:"kubeAPIServer,omitempty"`
+	// KubeDNS is the discovered cluster IP of the kube-dns service (CoreDNS)
+	KubeDNS *Endpoint `json:"kubeDNS,omitempty"`
+	// NodeLocalDNS is the node-local-dns endpoint if node-local-dns is deployed
+	NodeLocalDNS *Endpoint `json:"nodeLocalDNS,omitempty"`
+	// LoadBalancers are the external endpoints of the services of type LoadBalancer selected for the hairpin check
+	LoadBalancers []Endpoint `json:"loadBalancers,omitempty"`
+	// Services are the cluster IP endpoints of the services selected by label for the checks from the pod network
+	Services []Endpoint `json:"services,omitempty"`
+	// URLEndpoints are the endpoints loaded by the controller from a URL
+	URLEndpoints *EndpointList `json:"urlEndpoints,omitempty"`
+	// MaintenanceWindows are the current and future maintenance windows
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+	// NodeSelector is the label selector restricting the known nodes, empty if all nodes are known
+	NodeSelector string `json:"nodeSelector,omitempty"`
+	// Trimmed is set if the controller has dropped the optional metadata of the nodes (zone and pool) to stay below the size limit of the config map
+	Trimmed bool `json:"trimmed,omitempty"`
+	// Shards is the number of shards holding the nodes and pod endpoints if the controller has split the cluster config
+	// to stay below the size limit of the config map
+	Shards int `json:"shards,omitempty"`
+	// ShardsGeneration is the hash of the shards of the cluster config, which must be the same in all shards
+	ShardsGeneration string `json:"shardsGeneration,omitempty"`
 }
 
 func (cc ClusterConfig) Shuffled() ClusterConfig {
@@ -51,5 +247,21 @@ func (cc ClusterConfig) Shuffled() ClusterConfig {
 		PodEndpoints:          CloneAndShuffle(cc.PodEndpoints),
 		InternalKubeAPIServer: cc.InternalKubeAPIServer,
 		KubeAPIServer:         cc.KubeAPIServer,
+		KubeDNS:               cc.KubeDNS,
+		NodeLocalDNS:          cc.NodeLocalDNS,
+		LoadBalancers:         CloneAndShuffle(cc.LoadBalancers),
+		Services:              CloneAndShuffle(cc.Services),
+		URLEndpoints:          cc.URLEndpoints,
+		MaintenanceWindows:    cc.MaintenanceWindows,
+		NodeSelector:          cc.NodeSelector,
+		Trimmed:               cc.Trimmed,
 	}
 }
+
+func (e Endpoint) DestIP() string {
+	return e.IP
+}
+
+func (e Endpoint) DestPort() int {
+	return e.Port
+}
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	for _, testCase := range []struct {
+		input    string
+		expected Endpoint
+		err      string
+	}{
+		{input: "db:10.0.0.1:5432", expected: Endpoint{Hostname: "db", IP: "10.0.0.1", Port: 5432}},
+		{input: "db:[fd00::1]:5432", expected: Endpoint{Hostname: "db", IP: "fd00::1", Port: 5432}},
+		{input: "ingress-web:web.example.com:443", expected: Endpoint{Hostname: "ingress-web", IP: "web.example.com", Port: 443}},
+		{input: "db:fd00::1:5432", err: "IPv6 addresses must be enclosed in brackets"},
+		{input: "db:[10.0.0.1]:5432", err: "invalid IPv6 address"},
+		{input: "db:[web.example.com]:5432", err: "invalid IPv6 address"},
+		{input: "db:10.0.0.1", err: "expected format"},
+		{input: "db:10.0.0.1:", err: "invalid port"},
+		{input: "db:10.0.0.1:http", err: "invalid port"},
+		{input: "db:10.0.0.1:0", err: "invalid port"},
+		{input: "db:10.0.0.1:65536", err: "invalid port"},
+		{input: "db::5432", err: "missing ip"},
+		{input: ":10.0.0.1:5432", err: "missing hostname"},
+		{input: "db", err: "expected format"},
+		{input: "", err: "expected format"},
+		{input: "d b:10.0.0.1:5432", err: "invalid hostname"},
+		{input: "db:10.0.0.1%eth0:5432", err: "invalid ip"},
+	} {
+		endpoint, err := ParseEndpoint(testCase.input)
+		if testCase.err != "" {
+			if assert.Error(t, err, testCase.input) {
+				assert.Contains(t, err.Error(), testCase.err, testCase.input)
+			}
+			continue
+		}
+		if assert.NoError(t, err, testCase.input) {
+			assert.Equal(t, testCase.expected, endpoint, testCase.input)
+			assert.Equal(t, testCase.input, endpoint.String())
+		}
+	}
+}
+
+func TestParseNode(t *testing.T) {
+	node, err := ParseNode("n1:10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, Node{Hostname: "n1", InternalIP: "10.0.0.1"}, node)
+	node, err = ParseNode("n1:fd00::1")
+	assert.NoError(t, err)
+	assert.Equal(t, Node{Hostname: "n1", InternalIP: "fd00::1"}, node)
+	node, err = ParseNode("n1:[fd00::1]")
+	assert.NoError(t, err)
+	assert.Equal(t, Node{Hostname: "n1", InternalIP: "fd00::1"}, node)
+
+	for _, invalid := range []string{"n1", "n1:", ":10.0.0.1", "n1:fd00::1:x", "n1:[10.0.0.1]"} {
+		_, err := ParseNode(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func FuzzParseEndpoint(f *testing.F) {
+	for _, seed := range []string{
+		"db:10.0.0.1:5432",
+		"db:[fd00::1]:5432",
+		"db:fd00::1:5432",
+		"ingress-web:web.example.com:443",
+		"db:10.0.0.1",
+		"db::",
+		":::",
+		"[]:[]:[]",
+		"db:[::ffff:10.0.0.1]:80",
+		"db:10.0.0.1:99999999999999999999",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		endpoint, err := ParseEndpoint(input)
+		if err != nil {
+			if !strings.Contains(err.Error(), "invalid endpoint") {
+				t.Errorf("unclear error for %q: %s", input, err)
+			}
+			return
+		}
+		if endpoint.Hostname == "" || endpoint.IP == "" || endpoint.Port < 1 || endpoint.Port > 65535 {
+			t.Errorf("incomplete endpoint %#v for %q", endpoint, input)
+		}
+		// the formatted endpoint must be parsed to the same endpoint
+		parsed, err := ParseEndpoint(endpoint.String())
+		if err != nil {
+			t.Errorf("formatted endpoint %q of %q is invalid: %s", endpoint.String(), input, err)
+		} else if parsed != endpoint {
+			t.Errorf("formatted endpoint %q of %q parsed to %#v instead of %#v", endpoint.String(), input, parsed, endpoint)
+		}
+	})
+}
+
+func TestNodesWithAddressType(t *testing.T) {
+	nodes := []Node{
+		{Hostname: "node-a", InternalIP: "10.0.0.1", ExternalIP: "1.2.3.4", Zone: "zone-a"},
+		{Hostname: "node-b", InternalIP: "10.0.0.2"},
+	}
+	assert.Equal(t, nodes, NodesWithAddressType(nodes, NodeAddressTypeInternal))
+	assert.Equal(t, []Node{{Hostname: "node-a", InternalIP: "1.2.3.4", ExternalIP: "1.2.3.4", Zone: "zone-a"}},
+		NodesWithAddressType(nodes, NodeAddressTypeExternal))
+	assert.Equal(t, "10.0.0.1", nodes[0].InternalIP, "unchanged")
+
+	assert.NoError(t, ValidateNodeAddressType(NodeAddressTypeExternal))
+	assert.Error(t, ValidateNodeAddressType("public"))
+}
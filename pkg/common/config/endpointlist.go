@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+)
+
+// EndpointList is a list of endpoints loaded from a URL with its source and version.
+type EndpointList struct {
+	// Source is the URL the list was loaded from.
+	Source string `json:"source"`
+	// Version is the version of the list given by the document, the ETag header or a hash of the content.
+	Version string `json:"version"`
+	// Endpoints are the endpoints to check.
+	Endpoints []Endpoint `json:"endpoints,omitempty"`
+}
+
+// endpointListDocument is the format of the document served at the URL.
+type endpointListDocument struct {
+	Version   string     `json:"version,omitempty"`
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// ParseEndpointList parses an endpoint list document in YAML or JSON format with the fields 'version' (optional)
+// and 'endpoints' (items with 'hostname', 'ip' and 'port'). If the document has no version, the ETag is used
+// or a hash of the content if the ETag is empty.
+func ParseEndpointList(data []byte, source, etag string) (*EndpointList, error) {
+	doc := &endpointListDocument{}
+	if err := yaml.UnmarshalStrict(data, doc); err != nil {
+		return nil, fmt.Errorf("unmarshalling endpoint list from %s failed: %w", source, err)
+	}
+	hostnames := map[string]bool{}
+	for _, ep := range doc.Endpoints {
+		if ep.Hostname == "" || ep.IP == "" {
+			return nil, fmt.Errorf("endpoint list from %s: missing hostname or ip of endpoint %s:%s:%d", source, ep.Hostname, ep.IP, ep.Port)
+		}
+		if ep.Port < 1 || ep.Port > 65535 {
+			return nil, fmt.Errorf("endpoint list from %s: invalid port %d of endpoint %s", source, ep.Port, ep.Hostname)
+		}
+		if hostnames[ep.Hostname] {
+			return nil, fmt.Errorf("endpoint list from %s: duplicate hostname %s", source, ep.Hostname)
+		}
+		hostnames[ep.Hostname] = true
+	}
+	version := doc.Version
+	if version == "" {
+		version = etag
+	}
+	if version == "" {
+		sum := sha256.Sum256(data)
+		version = hex.EncodeToString(sum[:6])
+	}
+	return &EndpointList{Source: source, Version: version, Endpoints: doc.Endpoints}, nil
+}
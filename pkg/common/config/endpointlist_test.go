@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEndpointList(t *testing.T) {
+	source := "https://config.example.com/endpoints"
+	data := []byte(`
+version: "42"
+endpoints:
+- hostname: db
+  ip: 10.1.0.5
+  port: 5432
+- hostname: cache
+  ip: 10.1.0.6
+  port: 6379
+`)
+	list, err := ParseEndpointList(data, source, `"etag"`)
+	assert.NoError(t, err)
+	assert.Equal(t, &EndpointList{
+		Source:  source,
+		Version: "42",
+		Endpoints: []Endpoint{
+			{Hostname: "db", IP: "10.1.0.5", Port: 5432},
+			{Hostname: "cache", IP: "10.1.0.6", Port: 6379},
+		},
+	}, list)
+
+	data = []byte(`{"endpoints": [{"hostname": "db", "ip": "10.1.0.5", "port": 5432}]}`)
+	list, err = ParseEndpointList(data, source, `"etag"`)
+	assert.NoError(t, err)
+	assert.Equal(t, `"etag"`, list.Version)
+	list, err = ParseEndpointList(data, source, "")
+	assert.NoError(t, err)
+	assert.Len(t, list.Version, 12)
+	other, err := ParseEndpointList([]byte(`{"endpoints": [{"hostname": "db", "ip": "10.1.0.6", "port": 5432}]}`), source, "")
+	assert.NoError(t, err)
+	assert.NotEqual(t, list.Version, other.Version)
+
+	for _, invalid := range []string{
+		`{"endpoints": [{"hostname": "db", "ip": "10.1.0.5"}]}`,
+		`{"endpoints": [{"hostname": "db", "port": 5432}]}`,
+		`{"endpoints": [{"hostname": "db", "ip": "10.1.0.5", "port": 5432}, {"hostname": "db", "ip": "10.1.0.6", "port": 5432}]}`,
+		`{"endpoints": [{"name": "db"}]}`,
+		`[`,
+	} {
+		_, err = ParseEndpointList([]byte(invalid), source, "")
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// NodeEventType is the type of a node lifecycle event observed by the controller.
+type NodeEventType string
+
+const (
+	// NodeEventAdded is recorded when a node is created.
+	NodeEventAdded NodeEventType = "added"
+	// NodeEventRemoved is recorded when a node is deleted.
+	NodeEventRemoved NodeEventType = "removed"
+	// NodeEventReady is recorded when the status of the Ready condition of a node changes.
+	NodeEventReady NodeEventType = "ready"
+	// NodeEventTaints is recorded when the taints of a node change.
+	NodeEventTaints NodeEventType = "taints"
+)
+
+// NodeEvent is a node lifecycle event with the old and the new state.
+type NodeEvent struct {
+	Time time.Time     `json:"time"`
+	Node string        `json:"node"`
+	Type NodeEventType `json:"type"`
+	Old  string        `json:"old,omitempty"`
+	New  string        `json:"new,omitempty"`
+}
+
+// AppendNodeEvents appends the events and drops the oldest ones to keep at most limit events.
+func AppendNodeEvents(events []NodeEvent, limit int, added ...NodeEvent) []NodeEvent {
+	events = append(events, added...)
+	if limit > 0 && len(events) > limit {
+		events = append([]NodeEvent(nil), events[len(events)-limit:]...)
+	}
+	return events
+}
+
+// UnmarshalNodeEvents parses the events in JSON format. Empty data results in no events.
+func UnmarshalNodeEvents(data []byte) ([]NodeEvent, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var events []NodeEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("unmarshalling node events failed: %w", err)
+	}
+	return events, nil
+}
+
+// LoadNodeEvents loads the events from a file written by MarshalNodeEvents.
+func LoadNodeEvents(filename string) ([]NodeEvent, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalNodeEvents(data)
+}
+
+// MarshalNodeEvents serializes the events in JSON format with one event per line.
+func MarshalNodeEvents(events []NodeEvent) ([]byte, error) {
+	data := []byte("[")
+	for i, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			data = append(data, ',')
+		}
+		data = append(data, '\n')
+		data = append(data, line...)
+	}
+	return append(data, "\n]\n"...), nil
+}
@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeEvents(t *testing.T) {
+	start := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	var events []NodeEvent
+	for i := 0; i < 5; i++ {
+		events = AppendNodeEvents(events, 3, NodeEvent{
+			Time: start.Add(time.Duration(i) * time.Minute),
+			Node: fmt.Sprintf("node%d", i),
+			Type: NodeEventAdded,
+			New:  "True",
+		})
+	}
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, "node2", events[0].Node)
+		assert.Equal(t, "node4", events[2].Node)
+	}
+
+	data, err := MarshalNodeEvents(events)
+	assert.NoError(t, err)
+	actual, err := UnmarshalNodeEvents(data)
+	assert.NoError(t, err)
+	assert.Equal(t, events, actual)
+
+	actual, err = UnmarshalNodeEvents(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, actual)
+	_, err = UnmarshalNodeEvents([]byte("{"))
+	assert.Error(t, err)
+}
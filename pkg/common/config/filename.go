@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultFilenameTemplate is the template of the observation files if NetworkConfig.FilenameTemplate is not set.
+	DefaultFilenameTemplate = "{prefix}-{date}-{hour}.records"
+	// RecordFileSuffix is the suffix of all observation files.
+	RecordFileSuffix = ".records"
+
+	placeholderPrefix = "{prefix}"
+	placeholderNode   = "{node}"
+	placeholderDate   = "{date}"
+	placeholderHour   = "{hour}"
+)
+
+var (
+	placeholderRegexp  = regexp.MustCompile(`\{[^}]*\}`)
+	filenameSafeRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]*$`)
+	unsafeCharsRegexp  = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+)
+
+// ValidateFilenameTemplate checks the template of the observation file names. The placeholders are
+// {prefix} (data file prefix), {node} (node name), {date} (YYYY-MM-DD) and {hour} (HH, all in UTC).
+// As the observation files of both networks and all hours share the output directory, the template must contain
+// {prefix} and {date}. Without {hour}, the observations of a day are appended to the same file.
+// All other characters must be letters, digits, '.', '_' or '-' and the template must end with '.records'.
+func ValidateFilenameTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	counts := map[string]int{}
+	for _, p := range placeholderRegexp.FindAllString(template, -1) {
+		switch p {
+		case placeholderPrefix, placeholderNode, placeholderDate, placeholderHour:
+			counts[p]++
+		default:
+			return fmt.Errorf("invalid filename template %q: unknown placeholder %s", template, p)
+		}
+	}
+	for _, p := range []string{placeholderPrefix, placeholderDate} {
+		if counts[p] == 0 {
+			return fmt.Errorf("invalid filename template %q: missing placeholder %s", template, p)
+		}
+	}
+	for p, n := range counts {
+		if n > 1 {
+			return fmt.Errorf("invalid filename template %q: placeholder %s used more than once", template, p)
+		}
+	}
+	if literals := placeholderRegexp.ReplaceAllString(template, ""); !filenameSafeRegexp.MatchString(literals) {
+		return fmt.Errorf("invalid filename template %q: only letters, digits, '.', '_' and '-' are allowed", template)
+	}
+	if strings.HasPrefix(template, ".") {
+		return fmt.Errorf("invalid filename template %q: must not start with '.'", template)
+	}
+	if !strings.HasSuffix(template, RecordFileSuffix) || template == RecordFileSuffix {
+		return fmt.Errorf("invalid filename template %q: must end with %q", template, RecordFileSuffix)
+	}
+	return nil
+}
+
+// ValidateFileNaming checks the filename template and the data file prefix.
+func (c *NetworkConfig) ValidateFileNaming() error {
+	if err := ValidateFilenameTemplate(c.FilenameTemplate); err != nil {
+		return err
+	}
+	if !filenameSafeRegexp.MatchString(c.DataFilePrefix) {
+		return fmt.Errorf("invalid data file prefix %q: only letters, digits, '.', '_' and '-' are allowed", c.DataFilePrefix)
+	}
+	return nil
+}
+
+// validateDistinctFileNamings checks that the observation files of the host and the pod network cannot have the same names
+// in the shared output directory if a filename template is set.
+func validateDistinctFileNamings(hostNetwork, podNetwork *NetworkConfig) error {
+	if hostNetwork == nil || podNetwork == nil || hostNetwork.DataFilePrefix == "" || podNetwork.DataFilePrefix == "" ||
+		hostNetwork.FilenameTemplate == "" && podNetwork.FilenameTemplate == "" {
+		return nil
+	}
+	host, err := NewFileNaming(hostNetwork.FilenameTemplate, hostNetwork.DataFilePrefix, "node")
+	if err != nil {
+		return err
+	}
+	pod, err := NewFileNaming(podNetwork.FilenameTemplate, podNetwork.DataFilePrefix, "node")
+	if err != nil {
+		return err
+	}
+	hour := time.Date(2022, 6, 1, 13, 0, 0, 0, time.UTC)
+	if host.Matches(pod.Filename(hour)) || pod.Matches(host.Filename(hour)) {
+		return fmt.Errorf("invalid filename templates: the observation files of host and pod network have the same names")
+	}
+	return nil
+}
+
+// FileNaming creates and recognizes the names of the observation files of a data file prefix.
+type FileNaming struct {
+	template string
+	prefix   string
+	node     string
+	pattern  *regexp.Regexp
+}
+
+// NewFileNaming creates the file naming for the template (DefaultFilenameTemplate if empty), the data file prefix and the node name.
+// Characters of the node name which are not filename-safe are replaced by '_'.
+func NewFileNaming(template, prefix, node string) (*FileNaming, error) {
+	if template == "" {
+		template = DefaultFilenameTemplate
+	}
+	if err := ValidateFilenameTemplate(template); err != nil {
+		return nil, err
+	}
+	if !filenameSafeRegexp.MatchString(prefix) || prefix == "" {
+		return nil, fmt.Errorf("invalid data file prefix %q", prefix)
+	}
+	node = unsafeCharsRegexp.ReplaceAllString(node, "_")
+	if node == "" && strings.Contains(template, placeholderNode) {
+		return nil, fmt.Errorf("filename template %q needs the node name", template)
+	}
+	pattern := regexp.QuoteMeta(template)
+	pattern = strings.Replace(pattern, regexp.QuoteMeta(placeholderPrefix), regexp.QuoteMeta(prefix), 1)
+	pattern = strings.Replace(pattern, regexp.QuoteMeta(placeholderNode), regexp.QuoteMeta(node), 1)
+	pattern = strings.Replace(pattern, regexp.QuoteMeta(placeholderDate), `\d{4}-\d{2}-\d{2}`, 1)
+	pattern = strings.Replace(pattern, regexp.QuoteMeta(placeholderHour), `\d{2}`, 1)
+	return &FileNaming{
+		template: template,
+		prefix:   prefix,
+		node:     node,
+		pattern:  regexp.MustCompile("^" + pattern + "$"),
+	}, nil
+}
+
+// Filename returns the name of the observation file for the hour.
+func (n *FileNaming) Filename(hour time.Time) string {
+	hour = hour.UTC()
+	return strings.NewReplacer(
+		placeholderPrefix, n.prefix,
+		placeholderNode, n.node,
+		placeholderDate, hour.Format("2006-01-02"),
+		placeholderHour, hour.Format("15"),
+	).Replace(n.template)
+}
+
+// Prefix returns the data file prefix.
+func (n *FileNaming) Prefix() string {
+	return n.prefix
+}
+
+// Node returns the sanitized node name.
+func (n *FileNaming) Node() string {
+	return n.node
+}
+
+// Matches returns true if the name is the name of an observation file of this file naming.
+func (n *FileNaming) Matches(name string) bool {
+	return n.pattern.MatchString(name)
+}
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateFilenameTemplate(t *testing.T) {
+	for _, testCase := range []struct {
+		template string
+		valid    bool
+	}{
+		{template: "", valid: true},
+		{template: DefaultFilenameTemplate, valid: true},
+		{template: "{prefix}-{node}-{date}.records", valid: true},
+		{template: "nwpd_{date}T{hour}_{node}_{prefix}.records", valid: true},
+		{template: "{prefix}-{node}-{date}.jsonl"},
+		{template: "{node}-{date}-{hour}.records"},
+		{template: "{prefix}-{node}.records"},
+		{template: "{prefix}-{date}-{date}.records"},
+		{template: "{prefix}-{day}.records"},
+		{template: "{prefix}/{date}.records"},
+		{template: "{prefix} {date}.records"},
+		{template: ".{prefix}-{date}.records"},
+	} {
+		err := ValidateFilenameTemplate(testCase.template)
+		if testCase.valid {
+			assert.NoError(t, err, testCase.template)
+		} else {
+			assert.Error(t, err, testCase.template)
+		}
+	}
+
+	cfg := &AgentConfig{PodNetwork: &NetworkConfig{FilenameTemplate: "{prefix}.records"}}
+	assert.Error(t, cfg.Validate())
+	cfg = &AgentConfig{PodNetwork: &NetworkConfig{DataFilePrefix: "nwpd/pod"}}
+	assert.Error(t, cfg.Validate())
+
+	cfg = &AgentConfig{
+		HostNetwork: &NetworkConfig{DataFilePrefix: "nwpd-host", FilenameTemplate: "{prefix}-{node}-{date}.records"},
+		PodNetwork:  &NetworkConfig{DataFilePrefix: "nwpd-pod", FilenameTemplate: "{prefix}-{node}-{date}.records"},
+	}
+	assert.NoError(t, cfg.Validate())
+	cfg.PodNetwork = &NetworkConfig{DataFilePrefix: "nwpd-host-node", FilenameTemplate: "{prefix}-{date}.records"}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestFileNaming(t *testing.T) {
+	hour := time.Date(2022, 6, 1, 13, 0, 0, 0, time.UTC)
+
+	naming, err := NewFileNaming("", "nwpd-agent-pod-net", "node1")
+	assert.NoError(t, err)
+	assert.Equal(t, "nwpd-agent-pod-net-2022-06-01-13.records", naming.Filename(hour))
+	assert.True(t, naming.Matches("nwpd-agent-pod-net-2022-06-01-13.records"))
+	assert.False(t, naming.Matches("nwpd-agent-pod-net-forwarded-2022-06-01-13.records"))
+	assert.False(t, naming.Matches("nwpd-agent-pod-net-2022-06-01.records"))
+
+	naming, err = NewFileNaming("{prefix}-{node}-{date}.records", "nwpd", "shoot--foo/worker:1")
+	assert.NoError(t, err)
+	assert.Equal(t, "nwpd-shoot--foo_worker_1-2022-06-01.records", naming.Filename(hour))
+	assert.Equal(t, naming.Filename(hour), naming.Filename(hour.Add(10*time.Hour)))
+	assert.True(t, naming.Matches("nwpd-shoot--foo_worker_1-2022-05-31.records"))
+	assert.False(t, naming.Matches("nwpd-node2-2022-05-31.records"))
+
+	_, err = NewFileNaming("{prefix}-{node}-{date}.records", "nwpd", "")
+	assert.Error(t, err)
+	_, err = NewFileNaming("", "nwpd/pod", "node1")
+	assert.Error(t, err)
+}
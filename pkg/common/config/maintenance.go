@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// MaintenanceWindow is a time range of planned maintenance. Observations taken during the window are still recorded,
+// but marked with the maintenance flag and excluded from alerting and node conditions.
+// The scope is restricted by zones, nodes and jobs. An empty list matches everything, the lists are combined by 'and'.
+type MaintenanceWindow struct {
+	// Name is an optional name used for logging.
+	Name string `json:"name,omitempty"`
+	// Start is the start of the window.
+	Start metav1.Time `json:"start"`
+	// End is the end of the window.
+	End metav1.Time `json:"end"`
+	// Zones matches observations with source or destination node in one of the zones.
+	Zones []string `json:"zones,omitempty"`
+	// Nodes matches observations with source or destination host in the list.
+	Nodes []string `json:"nodes,omitempty"`
+	// Jobs matches observations of the given job IDs.
+	Jobs []string `json:"jobs,omitempty"`
+}
+
+// Validate checks that the window has a start and ends after it.
+func (w *MaintenanceWindow) Validate() error {
+	if w.Start.IsZero() || w.End.IsZero() {
+		return fmt.Errorf("maintenance window %s: missing start or end", w.Name)
+	}
+	if !w.End.After(w.Start.Time) {
+		return fmt.Errorf("maintenance window %s: end %s is not after start %s", w.Name,
+			w.End.UTC().Format(time.RFC3339), w.Start.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// IsExpired returns true if the window has ended.
+func (w *MaintenanceWindow) IsExpired(now time.Time) bool {
+	return !now.Before(w.End.Time)
+}
+
+// ParseMaintenanceWindows parses and validates a list of maintenance windows in YAML or JSON format.
+// Empty data results in no windows.
+func ParseMaintenanceWindows(data string) ([]MaintenanceWindow, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var windows []MaintenanceWindow
+	if err := yaml.Unmarshal([]byte(data), &windows); err != nil {
+		return nil, fmt.Errorf("unmarshalling maintenance windows failed: %w", err)
+	}
+	for i := range windows {
+		if err := windows[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return windows, nil
+}
+
+// PruneMaintenanceWindows returns the windows which have not expired sorted by start time.
+func PruneMaintenanceWindows(windows []MaintenanceWindow, now time.Time) []MaintenanceWindow {
+	var result []MaintenanceWindow
+	for _, w := range windows {
+		if !w.IsExpired(now) {
+			result = append(result, w)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Start.Before(&result[j].Start)
+	})
+	return result
+}
+
+// MaintenanceMatcher checks if observations fall into a maintenance window.
+// Overlapping windows are allowed, an observation is flagged if any window matches.
+type MaintenanceMatcher struct {
+	windows []MaintenanceWindow
+	zones   map[string]string
+}
+
+// NewMaintenanceMatcher creates a matcher for the maintenance windows of the cluster configuration.
+// It returns nil if there are no windows.
+func NewMaintenanceMatcher(cfg *ClusterConfig) *MaintenanceMatcher {
+	if cfg == nil || len(cfg.MaintenanceWindows) == 0 {
+		return nil
+	}
+	zones := map[string]string{}
+	for _, n := range cfg.Nodes {
+		if n.Zone != "" {
+			zones[n.Hostname] = n.Zone
+		}
+	}
+	return &MaintenanceMatcher{windows: cfg.MaintenanceWindows, zones: zones}
+}
+
+// Matches returns true if the observation of the job and edge at the given time is inside a maintenance window.
+func (m *MaintenanceMatcher) Matches(t time.Time, jobID, srcHost, destHost string) bool {
+	if m == nil {
+		return false
+	}
+	for i := range m.windows {
+		w := &m.windows[i]
+		if t.Before(w.Start.Time) || !t.Before(w.End.Time) {
+			continue
+		}
+		if len(w.Jobs) > 0 && !contains(w.Jobs, jobID) {
+			continue
+		}
+		if len(w.Nodes) > 0 && !contains(w.Nodes, srcHost) && !contains(w.Nodes, destHost) {
+			continue
+		}
+		if len(w.Zones) > 0 && !m.inZones(w.Zones, srcHost) && !m.inZones(w.Zones, destHost) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func (m *MaintenanceMatcher) inZones(zones []string, host string) bool {
+	zone, ok := m.zones[host]
+	return ok && contains(zones, zone)
+}
+
+func contains(list []string, value string) bool {
+	for _, s := range list {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseMaintenanceWindows(t *testing.T) {
+	windows, err := ParseMaintenanceWindows(`
+- name: upgrade-zone-a
+  start: "2022-08-01T10:00:00Z"
+  end: "2022-08-01T12:00:00Z"
+  zones: [zone-a]
+- name: reboot
+  start: "2022-08-01T09:00:00Z"
+  end: "2022-08-01T09:30:00Z"
+  nodes: [node1]
+`)
+	assert.NoError(t, err)
+	if assert.Len(t, windows, 2) {
+		assert.Equal(t, "upgrade-zone-a", windows[0].Name)
+		assert.Equal(t, []string{"zone-a"}, windows[0].Zones)
+		assert.Equal(t, []string{"node1"}, windows[1].Nodes)
+	}
+
+	pruned := PruneMaintenanceWindows(windows, time.Date(2022, 8, 1, 9, 0, 0, 0, time.UTC))
+	if assert.Len(t, pruned, 2) {
+		assert.Equal(t, "reboot", pruned[0].Name)
+	}
+	pruned = PruneMaintenanceWindows(windows, time.Date(2022, 8, 1, 9, 30, 0, 0, time.UTC))
+	if assert.Len(t, pruned, 1) {
+		assert.Equal(t, "upgrade-zone-a", pruned[0].Name)
+	}
+
+	windows, err = ParseMaintenanceWindows("")
+	assert.NoError(t, err)
+	assert.Empty(t, windows)
+	_, err = ParseMaintenanceWindows(`[{"start": "2022-08-01T10:00:00Z", "end": "2022-08-01T09:00:00Z"}]`)
+	assert.Error(t, err)
+	_, err = ParseMaintenanceWindows(`[{"start": "2022-08-01T10:00:00Z"}]`)
+	assert.Error(t, err)
+}
+
+func TestMaintenanceMatcher(t *testing.T) {
+	start := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	window := func(offset, length time.Duration) MaintenanceWindow {
+		return MaintenanceWindow{Start: metav1.NewTime(start.Add(offset)), End: metav1.NewTime(start.Add(offset + length))}
+	}
+	zoneA := window(0, 2*time.Hour)
+	zoneA.Zones = []string{"zone-a"}
+	node3 := window(time.Hour, 2*time.Hour)
+	node3.Nodes = []string{"node3"}
+	node3.Jobs = []string{"tcp-n2n"}
+	cfg := &ClusterConfig{
+		Nodes: []Node{
+			{Hostname: "node1", Zone: "zone-a"},
+			{Hostname: "node2", Zone: "zone-b"},
+			{Hostname: "node3", Zone: "zone-b"},
+		},
+		MaintenanceWindows: []MaintenanceWindow{zoneA, node3},
+	}
+	m := NewMaintenanceMatcher(cfg)
+
+	for _, tc := range []struct {
+		offset   time.Duration
+		jobID    string
+		src      string
+		dest     string
+		expected bool
+	}{
+		{offset: -time.Second, jobID: "tcp-n2n", src: "node1", dest: "node2"},
+		{offset: 0, jobID: "tcp-n2n", src: "node1", dest: "node2", expected: true},
+		{offset: 0, jobID: "tcp-n2n", src: "node2", dest: "node1", expected: true},
+		{offset: 0, jobID: "tcp-n2n", src: "node2", dest: "node3"},
+		{offset: 0, jobID: "tcp-n2api-ext", src: "node2", dest: "api"},
+		// overlapping windows
+		{offset: 90 * time.Minute, jobID: "tcp-n2n", src: "node1", dest: "node3", expected: true},
+		{offset: 90 * time.Minute, jobID: "tcp-n2n", src: "node2", dest: "node3", expected: true},
+		{offset: 90 * time.Minute, jobID: "ping-n2n", src: "node2", dest: "node3"},
+		{offset: 2 * time.Hour, jobID: "tcp-n2n", src: "node1", dest: "node2"},
+		{offset: 2 * time.Hour, jobID: "tcp-n2n", src: "node3", dest: "node2", expected: true},
+		{offset: 3 * time.Hour, jobID: "tcp-n2n", src: "node3", dest: "node2"},
+	} {
+		assert.Equal(t, tc.expected, m.Matches(start.Add(tc.offset), tc.jobID, tc.src, tc.dest), "%+v", tc)
+	}
+
+	var nilMatcher *MaintenanceMatcher
+	assert.Nil(t, NewMaintenanceMatcher(&ClusterConfig{}))
+	assert.False(t, nilMatcher.Matches(start, "tcp-n2n", "node1", "node2"))
+}
@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// NetworkCheckGroup is the API group of the custom resource NetworkCheck.
+	NetworkCheckGroup = "network-problem-detector.gardener.cloud"
+	// NetworkCheckVersion is the API version of the custom resource NetworkCheck.
+	NetworkCheckVersion = "v1alpha1"
+	// NetworkCheckResource is the resource name of the custom resource NetworkCheck.
+	NetworkCheckResource = "networkchecks"
+)
+
+// CheckNetwork selects the daemon set running the job of a network check.
+type CheckNetwork string
+
+const (
+	// CheckNetworkHost runs the job in the daemon set on the host network.
+	CheckNetworkHost CheckNetwork = "host"
+	// CheckNetworkPod runs the job in the daemon set on the pod network.
+	CheckNetworkPod CheckNetwork = "pod"
+)
+
+// NetworkCheckPhase is the phase of a network check.
+type NetworkCheckPhase string
+
+const (
+	// NetworkCheckApplied means the job of the check is part of the agent config.
+	NetworkCheckApplied NetworkCheckPhase = "Applied"
+	// NetworkCheckInvalid means the check is invalid or conflicts with another job. It is not part of the agent config.
+	NetworkCheckInvalid NetworkCheckPhase = "Invalid"
+)
+
+// NetworkCheck is a job declared by the custom resource NetworkCheck. The controller reconciles all network checks
+// into the agent config in addition to the jobs of the agent config map.
+type NetworkCheck struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NetworkCheckSpec   `json:"spec"`
+	Status NetworkCheckStatus `json:"status,omitempty"`
+}
+
+// NetworkCheckSpec mirrors the fields of a job of the agent config.
+type NetworkCheckSpec struct {
+	// Networks are the daemon sets running the job. If empty, the job runs on the pod network only.
+	Networks []CheckNetwork `json:"networks,omitempty"`
+	// JobID is the ID of the job. If empty, '<namespace>.<name>' of the network check is used.
+	JobID string `json:"jobID,omitempty"`
+	// Args are the arguments of the job like in the agent config.
+	Args []string `json:"args"`
+	// TriggeredBy makes the job conditional like in the agent config.
+	TriggeredBy *JobTrigger `json:"triggeredBy,omitempty"`
+	// Labels are attached to the observations of the job like in the agent config.
+	Labels map[string]string `json:"labels,omitempty"`
+	// ReuseConnectionResult shares the socket-level result of the checks with other jobs like in the agent config.
+	ReuseConnectionResult bool `json:"reuseConnectionResult,omitempty"`
+	// FastRecheck re-checks failing edges before the next period like in the agent config.
+	FastRecheck *FastRecheck `json:"fastRecheck,omitempty"`
+	// Schedule restricts the job to a daily time window like in the agent config.
+	Schedule *JobSchedule `json:"schedule,omitempty"`
+	// ExpectedLatency is the latency SLO of the job like in the agent config.
+	ExpectedLatency *ExpectedLatency `json:"expectedLatency,omitempty"`
+}
+
+// NetworkCheckStatus is the status of a network check set by the controller.
+type NetworkCheckStatus struct {
+	// ObservedGeneration is the generation of the network check the status refers to.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Phase is 'Applied' or 'Invalid'.
+	Phase NetworkCheckPhase `json:"phase,omitempty"`
+	// Message describes why the check is invalid.
+	Message string `json:"message,omitempty"`
+	// JobID is the ID of the job in the agent config.
+	JobID string `json:"jobID,omitempty"`
+}
+
+// NetworksOrDefault returns the networks or the pod network if not set.
+func (c *NetworkCheck) NetworksOrDefault() []CheckNetwork {
+	if len(c.Spec.Networks) == 0 {
+		return []CheckNetwork{CheckNetworkPod}
+	}
+	return c.Spec.Networks
+}
+
+// JobIDOrDefault returns the job ID or '<namespace>.<name>' if not set.
+func (c *NetworkCheck) JobIDOrDefault() string {
+	if c.Spec.JobID != "" {
+		return c.Spec.JobID
+	}
+	return fmt.Sprintf("%s.%s", c.Namespace, c.Name)
+}
+
+// Job returns the job of the network check.
+func (c *NetworkCheck) Job() Job {
+	return Job{
+		JobID:                 c.JobIDOrDefault(),
+		Args:                  c.Spec.Args,
+		TriggeredBy:           c.Spec.TriggeredBy,
+		Labels:                c.Spec.Labels,
+		ReuseConnectionResult: c.Spec.ReuseConnectionResult,
+		FastRecheck:           c.Spec.FastRecheck,
+		Schedule:              c.Spec.Schedule,
+		ExpectedLatency:       c.Spec.ExpectedLatency,
+	}
+}
+
+// Validate checks the networks, the labels and the trigger condition of the network check. The arguments are not checked,
+// as they are parsed by the runners, and the trigger job is checked in the agent config.
+func (c *NetworkCheck) Validate() error {
+	seen := map[CheckNetwork]bool{}
+	for _, network := range c.Spec.Networks {
+		switch network {
+		case CheckNetworkHost, CheckNetworkPod:
+		default:
+			return fmt.Errorf("invalid network %q: must be %q or %q", network, CheckNetworkHost, CheckNetworkPod)
+		}
+		if seen[network] {
+			return fmt.Errorf("duplicate network %q", network)
+		}
+		seen[network] = true
+	}
+	if len(c.Spec.Args) == 0 {
+		return fmt.Errorf("missing args")
+	}
+	if err := ValidateJobLabels(c.Spec.Labels); err != nil {
+		return err
+	}
+	if c.Spec.FastRecheck != nil {
+		if err := c.Spec.FastRecheck.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Spec.ExpectedLatency != nil {
+		if err := c.Spec.ExpectedLatency.Validate(); err != nil {
+			return err
+		}
+	}
+	if _, err := c.Spec.Schedule.Compile(); err != nil {
+		return err
+	}
+	if c.Spec.TriggeredBy != nil {
+		switch c.Spec.TriggeredBy.Condition {
+		case TriggerOnFailure, TriggerOnDegraded:
+		default:
+			return fmt.Errorf("invalid trigger condition %q", c.Spec.TriggeredBy.Condition)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// JobSchedule restricts a job to a daily time window, e.g. for disruptive checks which should only run off-peak.
+// Outside the window, the scheduler skips the job.
+type JobSchedule struct {
+	// Days are the weekdays of the window start ('Mon', 'Tue', ..., 'Sun'). If empty, the window is active every day.
+	Days []string `json:"days,omitempty"`
+	// Start is the start of the window in the format 'HH:MM'.
+	Start string `json:"start"`
+	// End is the exclusive end of the window in the format 'HH:MM' ('24:00' for the end of the day).
+	// If it is before the start, the window ends on the next day.
+	End string `json:"end"`
+	// TimeZone is the IANA time zone of start and end, e.g. 'Europe/Berlin' (default 'UTC').
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// CompiledSchedule is a validated job schedule.
+type CompiledSchedule struct {
+	days     map[time.Weekday]bool
+	start    int
+	end      int
+	location *time.Location
+	source   string
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Compile validates the schedule and loads its time zone. It returns nil for a nil schedule.
+func (s *JobSchedule) Compile() (*CompiledSchedule, error) {
+	if s == nil {
+		return nil, nil
+	}
+	start, err := parseTimeOfDay(s.Start, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule start: %w", err)
+	}
+	end, err := parseTimeOfDay(s.End, true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule end: %w", err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("invalid schedule: start and end are equal (%s)", s.Start)
+	}
+	zone := s.TimeZone
+	if zone == "" {
+		zone = "UTC"
+	}
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule time zone %q: %w", s.TimeZone, err)
+	}
+	var days map[time.Weekday]bool
+	if len(s.Days) > 0 {
+		days = map[time.Weekday]bool{}
+		for _, day := range s.Days {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return nil, fmt.Errorf("invalid schedule day %q: must be one of Mon, Tue, Wed, Thu, Fri, Sat, Sun", day)
+			}
+			days[weekday] = true
+		}
+	}
+	source := fmt.Sprintf("%s-%s %s", s.Start, s.End, zone)
+	if len(s.Days) > 0 {
+		source = strings.Join(s.Days, ",") + " " + source
+	}
+	return &CompiledSchedule{days: days, start: start, end: end, location: location, source: source}, nil
+}
+
+// parseTimeOfDay parses 'HH:MM' to minutes since midnight. '24:00' is only allowed as end.
+func parseTimeOfDay(value string, end bool) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err == nil {
+		return t.Hour()*60 + t.Minute(), nil
+	}
+	if end && value == "24:00" {
+		return 24 * 60, nil
+	}
+	return 0, fmt.Errorf("%q is not in the format HH:MM", value)
+}
+
+// Active returns true if the time is inside the window.
+func (c *CompiledSchedule) Active(t time.Time) bool {
+	if c == nil {
+		return true
+	}
+	local := t.In(c.location)
+	minutes := local.Hour()*60 + local.Minute()
+	if c.start < c.end {
+		return c.onDay(local.Weekday()) && minutes >= c.start && minutes < c.end
+	}
+	// the window ends on the next day
+	if minutes >= c.start {
+		return c.onDay(local.Weekday())
+	}
+	return minutes < c.end && c.onDay(local.AddDate(0, 0, -1).Weekday())
+}
+
+func (c *CompiledSchedule) onDay(day time.Weekday) bool {
+	return c.days == nil || c.days[day]
+}
+
+// String returns the schedule for logging.
+func (c *CompiledSchedule) String() string {
+	return c.source
+}
@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobSchedule(t *testing.T) {
+	for _, testCase := range []struct {
+		schedule JobSchedule
+		err      string
+	}{
+		{schedule: JobSchedule{Start: "01:00", End: "05:00"}},
+		{schedule: JobSchedule{Days: []string{"Sat", "sun"}, Start: "00:00", End: "24:00", TimeZone: "Europe/Berlin"}},
+		{schedule: JobSchedule{Start: "1:00", End: "05:00"}},
+		{schedule: JobSchedule{Start: "24:00", End: "05:00"}, err: "invalid schedule start"},
+		{schedule: JobSchedule{Start: "01:00", End: "5 am"}, err: "invalid schedule end"},
+		{schedule: JobSchedule{Start: "01:00", End: "01:00"}, err: "start and end are equal"},
+		{schedule: JobSchedule{Start: "01:00", End: "05:00", TimeZone: "Mars/Olympus"}, err: "invalid schedule time zone"},
+		{schedule: JobSchedule{Days: []string{"Someday"}, Start: "01:00", End: "05:00"}, err: "invalid schedule day"},
+	} {
+		_, err := testCase.schedule.Compile()
+		if testCase.err == "" {
+			assert.NoError(t, err, testCase.schedule)
+		} else if assert.Error(t, err, testCase.schedule) {
+			assert.Contains(t, err.Error(), testCase.err)
+		}
+	}
+
+	var none *JobSchedule
+	compiled, err := none.Compile()
+	assert.NoError(t, err)
+	assert.True(t, compiled.Active(time.Now()))
+}
+
+func TestJobScheduleActive(t *testing.T) {
+	// 2022-08-01 is a Monday
+	at := func(day int, hour, minute int) time.Time {
+		return time.Date(2022, 8, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	nightly, err := (&JobSchedule{Start: "01:00", End: "05:00"}).Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, "01:00-05:00 UTC", nightly.String())
+	assert.False(t, nightly.Active(at(1, 0, 59)))
+	assert.True(t, nightly.Active(at(1, 1, 0)))
+	assert.True(t, nightly.Active(at(1, 4, 59)))
+	assert.False(t, nightly.Active(at(1, 5, 0)))
+
+	// the window of Saturday ends on Sunday morning
+	weekend, err := (&JobSchedule{Days: []string{"Sat"}, Start: "22:00", End: "02:00"}).Compile()
+	assert.NoError(t, err)
+	assert.False(t, weekend.Active(at(5, 23, 0)))
+	assert.True(t, weekend.Active(at(6, 23, 0)))
+	assert.True(t, weekend.Active(at(7, 1, 30)))
+	assert.False(t, weekend.Active(at(7, 2, 0)))
+	assert.False(t, weekend.Active(at(7, 23, 0)))
+
+	// start and end are interpreted in the time zone of the schedule
+	berlin, err := (&JobSchedule{Start: "01:00", End: "05:00", TimeZone: "Europe/Berlin"}).Compile()
+	assert.NoError(t, err)
+	assert.True(t, berlin.Active(at(1, 0, 0)))
+	assert.False(t, berlin.Active(at(1, 3, 0)))
+}
@@ -7,7 +7,11 @@
 package config
 
 import (
+	"bytes"
+	"compress/gzip"
+	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"math/rand"
 	"time"
@@ -35,8 +39,41 @@ func LoadAgentConfig(configFile string) (*AgentConfig, error) {
 	return cfg, nil
 }
 
+// CompressedFileSuffix is the suffix of gzip compressed config files.
+const CompressedFileSuffix = ".gz"
+
+// LoadClusterConfig loads the cluster config from the file. If the file does not exist, it is loaded from the
+// gzip compressed file with the suffix CompressedFileSuffix, as the controller compresses the config of large clusters.
+// The shards of a sharded cluster config are loaded from the files ClusterConfigShardFilename in the same directory.
 func LoadClusterConfig(configFile string) (*ClusterConfig, error) {
+	cfg, err := loadClusterConfigFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	var shards []*ClusterConfig
+	for i := 0; i < cfg.Shards; i++ {
+		shard, err := loadClusterConfigFile(ClusterConfigShardFilename(configFile, i))
+		if err != nil {
+			return nil, fmt.Errorf("loading shard %d of cluster config failed: %w", i, err)
+		}
+		shards = append(shards, shard)
+	}
+	if err := cfg.MergeShards(shards); err != nil {
+		return nil, fmt.Errorf("%s: %w", configFile, err)
+	}
+	return cfg, nil
+}
+
+func loadClusterConfigFile(configFile string) (*ClusterConfig, error) {
 	data, err := ioutil.ReadFile(configFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		var compressed []byte
+		if compressed, err = ioutil.ReadFile(configFile + CompressedFileSuffix); err == nil {
+			data, err = Gunzip(compressed)
+		} else if errors.Is(err, fs.ErrNotExist) {
+			err = fmt.Errorf("neither %s nor %s%s exists", configFile, configFile, CompressedFileSuffix)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -49,6 +86,32 @@ func LoadClusterConfig(configFile string) (*ClusterConfig, error) {
 	return cfg, nil
 }
 
+// Gzip compresses the data.
+func Gzip(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Gunzip decompresses the data compressed by Gzip.
+func Gunzip(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed data: %w", err)
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
 func CloneAndShuffle[T any](items []T) []T {
 	if DisableShuffleForTesting {
 		return items
@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// VersionedAgentConfigMapName returns the name of the immutable agent config map for the given config generation.
+func VersionedAgentConfigMapName(generation string) string {
+	return NameAgentConfigMap + "-" + generation
+}
+
+// LoadAgentConfigMap loads the agent config map. If the mutable config map does not exist,
+// the newest immutable config map is returned.
+func LoadAgentConfigMap(ctx context.Context, configMaps corev1client.ConfigMapInterface) (*corev1.ConfigMap, error) {
+	cm, err := configMaps.Get(ctx, NameAgentConfigMap, metav1.GetOptions{})
+	if err == nil || !errors.IsNotFound(err) {
+		return cm, err
+	}
+	list, listErr := configMaps.List(ctx, metav1.ListOptions{LabelSelector: LabelKeyAgentConfigGeneration})
+	if listErr != nil {
+		return nil, listErr
+	}
+	var newest *corev1.ConfigMap
+	for i := range list.Items {
+		item := &list.Items[i]
+		if newest == nil || newest.CreationTimestamp.Before(&item.CreationTimestamp) {
+			newest = item
+		}
+	}
+	if newest == nil {
+		return nil, fmt.Errorf("neither config map %s nor any immutable agent config map found: %w", NameAgentConfigMap, err)
+	}
+	return newest, nil
+}
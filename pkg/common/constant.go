@@ -13,12 +13,27 @@ const (
 	NameKubernetesService = "kubernetes"
 	// DomainNameKubernetesService is the Kubernetes service domain name
 	DomainNameKubernetesService = "kubernetes.default.svc.cluster.local."
+	// NameKubeDNSService is the name of the cluster DNS service (CoreDNS)
+	NameKubeDNSService = "kube-dns"
+	// DomainNameKubeDNSService is the cluster DNS service domain name
+	DomainNameKubeDNSService = NameKubeDNSService + "." + NamespaceKubeSystem + ".svc.cluster.local."
+	// NameNodeLocalDNS is the name of the node-local-dns daemon set
+	NameNodeLocalDNS = "node-local-dns"
+	// NodeLocalDNSIP is the link-local address node-local-dns listens on
+	NodeLocalDNSIP = "169.254.20.10"
 	// NameGardenerShootInfo is the name of the shoot info config map from Gardener
 	NameGardenerShootInfo = "shoot-info"
 	// AgentConfigFilename is the name of the config file
 	AgentConfigFilename = "agent-config.yaml"
+	// AgentConfigLastKnownGoodFilename is the name of the last valid agent config file stored by the controller in the agent config map.
+	// The agents fall back to it if the agent config is invalid.
+	AgentConfigLastKnownGoodFilename = "agent-config.last-known-good.yaml"
 	// ClusterConfigFilename is the name of the config file
 	ClusterConfigFilename = "cluster-config.yaml"
+	// ClusterConfigCompressedFilename is the name of the gzip compressed config file used instead of ClusterConfigFilename for large clusters
+	ClusterConfigCompressedFilename = ClusterConfigFilename + ".gz"
+	// ClusterEventsFilename is the name of the file with the node lifecycle events in the config map and in the collected observations
+	ClusterEventsFilename = "cluster-events.json"
 	// EnvNodeName is the env variable to get the node name in an agent pod
 	EnvNodeName = "NODE_NAME"
 	// EnvNodeIP is the env variable to get the node ip in an agent pod
@@ -27,12 +42,18 @@ const (
 	EnvPodIP = "POD_IP"
 	// LabelKeyK8sApp is the label key used to mark the pods
 	LabelKeyK8sApp = "k8s-app"
+	// LabelKeyWorkerPool is the label key of Gardener nodes containing the name of the worker pool
+	LabelKeyWorkerPool = "worker.gardener.cloud/pool"
 	// ApplicationName is the application name
 	ApplicationName = "network-problem-detector"
 	// NameAgentConfigMap name of the config map for the agents
 	NameAgentConfigMap = ApplicationName + "-config"
+	// LabelKeyAgentConfigGeneration is the label key of the immutable agent config maps containing the config generation
+	LabelKeyAgentConfigGeneration = "network-problem-detector.gardener.cloud/config-generation"
 	// NameClusterConfigMap name of the config map for the agents containing current nodes and agent pods
 	NameClusterConfigMap = ApplicationName + "-cluster-config"
+	// NameClusterEventsConfigMap name of the config map containing the node lifecycle events observed by the controller
+	NameClusterEventsConfigMap = ApplicationName + "-cluster-events"
 	// NameDaemonSetAgentHostNet name of the daemon set running in the host network
 	NameDaemonSetAgentHostNet = ApplicationName + "-host"
 	// NameDaemonSetAgentPodNet name of the daemon set running in the pod network
@@ -43,6 +64,16 @@ const (
 	PathLogDir = "/var/log/nwpd"
 	// PathOutputDir path of output directory with observations in pods
 	PathOutputDir = PathLogDir + "/records"
+	// PathHostProc is the mount path of the /proc directory of the host in the host network agent pod (for entering network namespaces)
+	PathHostProc = "/host/proc"
+	// PathCABundleDir is the mount path of the CA bundle for the TLS-based checks in the agent pods
+	PathCABundleDir = "/config/ca-bundle"
+	// CABundleFilename is the file name of the mounted CA bundle
+	CABundleFilename = "ca.crt"
+	// DataFileSuffixForwarded is appended to the data file prefix of the pod network for the observations forwarded to the host network agent
+	DataFileSuffixForwarded = "-forwarded"
+	// MaxRelayChecksPerBatch is the maximum number of checks of a batched request of relayed checks
+	MaxRelayChecksPerBatch = 100
 	// MaxLogfileSize is the maximum size of a log file written to the host file system
 	MaxLogfileSize = 10 * 1000 * 1000
 	// PodNetPodGRPCPort is the port used for the GRPC server of the pods running in the pod network
@@ -53,4 +84,49 @@ const (
 	HostNetPodGRPCPort = 1011
 	// HostNetPodHttpPort is the port used for the metrics http server of the pods running in the host network
 	HostNetPodHttpPort = 1012
+	// HostNetPodEchoPort is the port used for the TCP echo handler of the pods running in the host network
+	HostNetPodEchoPort = 1013
+	// DefaultIMDSEndpoint is the default address of the instance metadata service of the cloud provider
+	DefaultIMDSEndpoint = "169.254.169.254:80"
+	// AnnotationCheckLoadBalancer is the annotation selecting a service of type LoadBalancer for the hairpin check.
+	// The value is either "true" for the first TCP port or the port number to check.
+	AnnotationCheckLoadBalancer = "network-problem-detector.gardener.cloud/check-load-balancer"
+	// DefaultServiceSelector is the default label selector of the services checked from the pod network
+	DefaultServiceSelector = "nwpd.gardener.cloud/check=true"
+	// AnnotationMaintenanceWindows is the annotation of the cluster config map with the maintenance windows in YAML or JSON format.
+	// The controller propagates the windows into the cluster configuration and prunes expired ones.
+	AnnotationMaintenanceWindows = "network-problem-detector.gardener.cloud/maintenance-windows"
+	// AnnotationConfigValidationErrors is the annotation of the agent config map with the validation errors found by the controller.
+	AnnotationConfigValidationErrors = "network-problem-detector.gardener.cloud/config-validation-errors"
+	// AnnotationNetworkCheckJobs is the annotation of the agent config map with the jobs added by the controller for the
+	// custom resources NetworkCheck as comma separated list of '<network>/<jobID>'.
+	AnnotationNetworkCheckJobs = "network-problem-detector.gardener.cloud/network-check-jobs"
+	// DestHostPrefixLoadBalancer is the prefix of the destination host names used for observations of load balancers
+	DestHostPrefixLoadBalancer = "lb-"
+	// DestHostPrefixIngress is the prefix of the destination host names used for observations of ingress endpoints given at deploy time
+	DestHostPrefixIngress = "ingress-"
+	// DestHostPrefixService is the prefix of the destination host names used for observations of services selected by label
+	DestHostPrefixService = "svc-"
+	// DestHostPrefixNetStats is the prefix of the destination host names used for the samples of the kernel network counters
+	// of the interfaces and of TCP
+	DestHostPrefixNetStats = "netstats-"
+	// DestHostIMDS is the destination host name used for observations of the instance metadata service
+	DestHostIMDS = "imds"
+	// DestHostDefaultGateway is the destination host name used for observations of the default route and its gateway
+	DestHostDefaultGateway = "default-gateway"
+	// DestHostKubeProxy is the destination host name used for observations of the rules programmed by kube-proxy
+	DestHostKubeProxy = "kube-proxy"
+	// MetricAggregatedObservations is the name of the counter of observations by source, destination, job and status.
+	// It is used by the generated alert rules, so renaming it must be reflected there.
+	MetricAggregatedObservations = "nwpd_aggregated_observations"
+	// MetricNodeZoneInfo is the name of the metric mapping the nodes of the cluster config to their zones (always 1)
+	MetricNodeZoneInfo = "nwpd_node_zone_info"
+	// NodeGroupUnknown is the worker pool or zone of nodes without the respective label in reports and metrics
+	NodeGroupUnknown = "unknown"
+	// MetricNodePoolInfo is the name of the metric mapping the nodes of the cluster config to their worker pools (always 1)
+	MetricNodePoolInfo = "nwpd_node_pool_info"
+	// MetricConfigGenerationInfo is the name of the metric with the generation of the agent config in use (always 1)
+	MetricConfigGenerationInfo = "nwpd_config_generation_info"
+	// MetricAgentNetworkInfo is the name of the metric with the default routes and primary IPs of the agent (always 1)
+	MetricAgentNetworkInfo = "nwpd_agent_network_info"
 )
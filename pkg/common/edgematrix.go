@@ -0,0 +1,29 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import "time"
+
+// EdgeMatrix contains the health of all edges of the last polling cycle of the controller.
+// It is served by the controller as JSON at /edges.json.
+type EdgeMatrix struct {
+	// Timestamp is the time of the polling cycle
+	Timestamp time.Time `json:"timestamp"`
+	// Window is the time window of the aggregated observations, e.g. "1m0s"
+	Window string `json:"window"`
+	// PolledAgents is the number of agents which responded
+	PolledAgents int `json:"polledAgents"`
+	// Edges are sorted by job ID, source and destination host
+	Edges []EdgeHealth `json:"edges"`
+}
+
+// EdgeHealth contains the number of successful and failed checks of an edge in the window.
+type EdgeHealth struct {
+	JobID       string `json:"jobID"`
+	SrcHost     string `json:"srcHost"`
+	DestHost    string `json:"destHost"`
+	OkCount     int    `json:"okCount"`
+	FailedCount int    `json:"failedCount"`
+}
@@ -20,6 +20,11 @@ const _ = grpc.SupportPackageIsVersion7
 type AgentServiceClient interface {
 	GetObservations(ctx context.Context, in *GetObservationsRequest, opts ...grpc.CallOption) (*GetObservationsResponse, error)
 	GetAggregatedObservations(ctx context.Context, in *GetObservationsRequest, opts ...grpc.CallOption) (*GetAggregatedObservationsResponse, error)
+	ForwardObservations(ctx context.Context, in *ForwardObservationsRequest, opts ...grpc.CallOption) (*ForwardObservationsResponse, error)
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*AgentStatus, error)
+	RelayCheck(ctx context.Context, in *RelayCheckRequest, opts ...grpc.CallOption) (*RelayCheckResponse, error)
+	RelayChecks(ctx context.Context, in *RelayChecksRequest, opts ...grpc.CallOption) (AgentService_RelayChecksClient, error)
+	SetFaultPolicy(ctx context.Context, in *SetFaultPolicyRequest, opts ...grpc.CallOption) (*SetFaultPolicyResponse, error)
 }
 
 type agentServiceClient struct {
@@ -48,12 +53,85 @@ func (c *agentServiceClient) GetAggregatedObservations(ctx context.Context, in *
 	return out, nil
 }
 
+func (c *agentServiceClient) ForwardObservations(ctx context.Context, in *ForwardObservationsRequest, opts ...grpc.CallOption) (*ForwardObservationsResponse, error) {
+	out := new(ForwardObservationsResponse)
+	err := c.cc.Invoke(ctx, "/nwpd.AgentService/ForwardObservations", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentServiceClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*AgentStatus, error) {
+	out := new(AgentStatus)
+	err := c.cc.Invoke(ctx, "/nwpd.AgentService/GetStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentServiceClient) RelayCheck(ctx context.Context, in *RelayCheckRequest, opts ...grpc.CallOption) (*RelayCheckResponse, error) {
+	out := new(RelayCheckResponse)
+	err := c.cc.Invoke(ctx, "/nwpd.AgentService/RelayCheck", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentServiceClient) RelayChecks(ctx context.Context, in *RelayChecksRequest, opts ...grpc.CallOption) (AgentService_RelayChecksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AgentService_ServiceDesc.Streams[0], "/nwpd.AgentService/RelayChecks", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentServiceRelayChecksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AgentService_RelayChecksClient interface {
+	Recv() (*RelayCheckResponse, error)
+	grpc.ClientStream
+}
+
+type agentServiceRelayChecksClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentServiceRelayChecksClient) Recv() (*RelayCheckResponse, error) {
+	m := new(RelayCheckResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentServiceClient) SetFaultPolicy(ctx context.Context, in *SetFaultPolicyRequest, opts ...grpc.CallOption) (*SetFaultPolicyResponse, error) {
+	out := new(SetFaultPolicyResponse)
+	err := c.cc.Invoke(ctx, "/nwpd.AgentService/SetFaultPolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AgentServiceServer is the server API for AgentService service.
 // All implementations must embed UnimplementedAgentServiceServer
 // for forward compatibility
 type AgentServiceServer interface {
 	GetObservations(context.Context, *GetObservationsRequest) (*GetObservationsResponse, error)
 	GetAggregatedObservations(context.Context, *GetObservationsRequest) (*GetAggregatedObservationsResponse, error)
+	ForwardObservations(context.Context, *ForwardObservationsRequest) (*ForwardObservationsResponse, error)
+	GetStatus(context.Context, *GetStatusRequest) (*AgentStatus, error)
+	RelayCheck(context.Context, *RelayCheckRequest) (*RelayCheckResponse, error)
+	RelayChecks(*RelayChecksRequest, AgentService_RelayChecksServer) error
+	SetFaultPolicy(context.Context, *SetFaultPolicyRequest) (*SetFaultPolicyResponse, error)
 	mustEmbedUnimplementedAgentServiceServer()
 }
 
@@ -67,6 +145,21 @@ func (UnimplementedAgentServiceServer) GetObservations(context.Context, *GetObse
 func (UnimplementedAgentServiceServer) GetAggregatedObservations(context.Context, *GetObservationsRequest) (*GetAggregatedObservationsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetAggregatedObservations not implemented")
 }
+func (UnimplementedAgentServiceServer) ForwardObservations(context.Context, *ForwardObservationsRequest) (*ForwardObservationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ForwardObservations not implemented")
+}
+func (UnimplementedAgentServiceServer) GetStatus(context.Context, *GetStatusRequest) (*AgentStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedAgentServiceServer) RelayCheck(context.Context, *RelayCheckRequest) (*RelayCheckResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RelayCheck not implemented")
+}
+func (UnimplementedAgentServiceServer) RelayChecks(*RelayChecksRequest, AgentService_RelayChecksServer) error {
+	return status.Errorf(codes.Unimplemented, "method RelayChecks not implemented")
+}
+func (UnimplementedAgentServiceServer) SetFaultPolicy(context.Context, *SetFaultPolicyRequest) (*SetFaultPolicyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetFaultPolicy not implemented")
+}
 func (UnimplementedAgentServiceServer) mustEmbedUnimplementedAgentServiceServer() {}
 
 // UnsafeAgentServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -116,6 +209,99 @@ func _AgentService_GetAggregatedObservations_Handler(srv interface{}, ctx contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AgentService_ForwardObservations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ForwardObservationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).ForwardObservations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nwpd.AgentService/ForwardObservations",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).ForwardObservations(ctx, req.(*ForwardObservationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentService_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nwpd.AgentService/GetStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentService_RelayCheck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RelayCheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).RelayCheck(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nwpd.AgentService/RelayCheck",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).RelayCheck(ctx, req.(*RelayCheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentService_RelayChecks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RelayChecksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServiceServer).RelayChecks(m, &agentServiceRelayChecksServer{stream})
+}
+
+type AgentService_RelayChecksServer interface {
+	Send(*RelayCheckResponse) error
+	grpc.ServerStream
+}
+
+type agentServiceRelayChecksServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentServiceRelayChecksServer) Send(m *RelayCheckResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _AgentService_SetFaultPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetFaultPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).SetFaultPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nwpd.AgentService/SetFaultPolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).SetFaultPolicy(ctx, req.(*SetFaultPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // AgentService_ServiceDesc is the grpc.ServiceDesc for AgentService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -131,7 +317,29 @@ var AgentService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetAggregatedObservations",
 			Handler:    _AgentService_GetAggregatedObservations_Handler,
 		},
+		{
+			MethodName: "ForwardObservations",
+			Handler:    _AgentService_ForwardObservations_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _AgentService_GetStatus_Handler,
+		},
+		{
+			MethodName: "RelayCheck",
+			Handler:    _AgentService_RelayCheck_Handler,
+		},
+		{
+			MethodName: "SetFaultPolicy",
+			Handler:    _AgentService_SetFaultPolicy_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "RelayChecks",
+			Handler:       _AgentService_RelayChecks_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/common/nwpd/nwpd.proto",
 }
@@ -5,10 +5,57 @@
 package nwpd
 
 import (
+	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	// ProtocolTCP is the protocol of TCP connection checks.
+	ProtocolTCP = "tcp"
+	// ProtocolUDP is the protocol of DNS lookups.
+	ProtocolUDP = "udp"
+	// ProtocolICMP is the protocol of pings.
+	ProtocolICMP = "icmp"
+	// ProtocolHTTPS is the protocol of HTTPS requests.
+	ProtocolHTTPS = "https"
+	// ProtocolKubelet is the protocol of kubelet checks (HTTP to the local healthz endpoint, HTTPS to the API of other nodes).
+	ProtocolKubelet = "kubelet"
+	// ProtocolNetStats is the protocol of the samples of the kernel network counters of a node.
+	ProtocolNetStats = "netstats"
+	// ProtocolRoute is the protocol of the checks of the default route and its gateway.
+	ProtocolRoute = "route"
+	// ProtocolProxyRules is the protocol of the checks of the iptables rules and IPVS services programmed by kube-proxy.
+	ProtocolProxyRules = "proxyrules"
+)
+
+// ObservationID builds the ID of an observation from job ID, source host, destination host and timestamp.
+func ObservationID(jobID, srcHost, destHost string, timeMillis int64) string {
+	return fmt.Sprintf("%s/%s/%s/%d", jobID, srcHost, destHost, timeMillis)
+}
+
+// ParseObservationID splits an observation ID into job ID, source host, destination host and timestamp.
+func ParseObservationID(id string) (jobID, srcHost, destHost string, timeMillis int64, err error) {
+	parts := strings.Split(id, "/")
+	if len(parts) != 4 {
+		err = fmt.Errorf("invalid observation ID %q", id)
+		return
+	}
+	timeMillis, err = strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		err = fmt.Errorf("invalid observation ID %q: %s", id, err)
+		return
+	}
+	return parts[0], parts[1], parts[2], timeMillis, nil
+}
+
+// ID returns the ID of the observation.
+func (x *Observation) ID() string {
+	return ObservationID(x.JobID, x.SrcHost, x.DestHost, x.Timestamp.AsTime().UnixMilli())
+}
+
 type ObservationListener interface {
 	Add(obs *Observation)
 }
@@ -49,3 +96,22 @@ func (o Observations) Swap(i, j int) {
 	o[i] = o[j]
 	o[j] = h
 }
+
+// FormatNetworkInfo returns a short description of the network info, e.g. 'ipv4 eth0 via 10.0.0.1 (10.0.0.5)'.
+func FormatNetworkInfo(info []*NetworkInfo) string {
+	if len(info) == 0 {
+		return "no default route"
+	}
+	var parts []string
+	for _, i := range info {
+		s := fmt.Sprintf("%s %s", i.Family, i.Interface)
+		if i.Gateway != "" {
+			s += " via " + i.Gateway
+		}
+		if i.PrimaryIP != "" {
+			s += fmt.Sprintf(" (%s)", i.PrimaryIP)
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+const (
+	// PortNameGRPC is the name of the container port of the GRPC server of the agents
+	PortNameGRPC = "grpc"
+	// PortNameMetrics is the name of the container port of the metrics http server of the agents
+	PortNameMetrics = "metrics"
+	// PortNameEcho is the name of the container port of the TCP echo handler of the host network agents
+	PortNameEcho = "echo"
+)
+
+// PortSpec describes a port of the agent container of one of the daemon sets.
+// The services reference the container ports by name.
+type PortSpec struct {
+	// Name is the name of the container port.
+	Name string
+	// Protocol is the protocol of the port.
+	Protocol string
+	// Number is the default port number.
+	Number int
+	// HostNetwork is true for a port of the daemon set in the host network, false for one in the pod network.
+	HostNetwork bool
+	// ServicePort is the port of the load-balanced service of the daemon set, 0 if the port is not exposed by the services.
+	ServicePort int
+	// Optional ports are only provided if enabled by the deploy config.
+	Optional bool
+}
+
+// InService returns true if the port is exposed by the services of the daemon set.
+func (p PortSpec) InService() bool {
+	return p.ServicePort != 0
+}
+
+// AgentPorts are the ports of the agent containers. The services, the containers and the network configs of the
+// agent config are built from this table, so that adding a port is a change at a single place.
+var AgentPorts = []PortSpec{
+	{Name: PortNameGRPC, Protocol: "TCP", Number: HostNetPodGRPCPort, HostNetwork: true, ServicePort: 80},
+	{Name: PortNameMetrics, Protocol: "TCP", Number: HostNetPodHttpPort, HostNetwork: true, ServicePort: 8080},
+	{Name: PortNameEcho, Protocol: "TCP", Number: HostNetPodEchoPort, HostNetwork: true, Optional: true},
+	{Name: PortNameGRPC, Protocol: "TCP", Number: PodNetPodGRPCPort, ServicePort: 80},
+	{Name: PortNameMetrics, Protocol: "TCP", Number: PodNetPodHttpPort, ServicePort: 8080},
+}
+
+// AgentPortsOf returns the ports of the daemon set in the host or the pod network.
+func AgentPortsOf(hostNetwork bool) []PortSpec {
+	var result []PortSpec
+	for _, p := range AgentPorts {
+		if p.HostNetwork == hostNetwork {
+			result = append(result, p)
+		}
+	}
+	return result
+}
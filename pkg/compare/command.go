@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/analysis"
+
+	"github.com/spf13/cobra"
+)
+
+type compareCommand struct {
+	before     string
+	after      string
+	changeTime string
+	window     time.Duration
+	threshold  float64
+	jsonOutput bool
+	all        bool
+	failOnRegr bool
+	jobFilter  string
+	srcFilter  string
+	destFilter string
+}
+
+func CreateCompareCmd() *cobra.Command {
+	cc := &compareCommand{}
+	cmd := &cobra.Command{
+		Use:   "compare",
+		Short: "compare success rates of two observation snapshots",
+		Long: `compares the success rates per edge and per destination of two directories with collected observations
+(e.g. before and after a network change) and reports regressions and improvements`,
+		RunE: cc.compare,
+	}
+	cmd.Flags().StringVar(&cc.before, "before", "", "database directory with the collected observations before the change.")
+	cmd.Flags().StringVar(&cc.after, "after", "", "database directory with the collected observations after the change (defaults to the before directory if --change-time is set).")
+	cmd.Flags().StringVar(&cc.changeTime, "change-time", "", "optional timestamp of the change (e.g. format '2022-01-23T23:49:11' or '23:49:11'). Only observations before it are used from the before directory and only observations after it from the after directory.")
+	cmd.Flags().DurationVar(&cc.window, "window", 0, "if > 0, restricts the observations to the time window before and after the change time.")
+	cmd.Flags().Float64Var(&cc.threshold, "threshold", 0.01, "minimum change of the success rate (0..1) to report a regression or improvement.")
+	cmd.Flags().BoolVar(&cc.jsonOutput, "json", false, "if true, the comparison is printed in JSON format.")
+	cmd.Flags().BoolVar(&cc.all, "all", false, "if true, unchanged edges are printed, too (human-readable output only).")
+	cmd.Flags().BoolVar(&cc.failOnRegr, "fail-on-regression", false, "if true, the command fails if any edge has a regression.")
+	cmd.Flags().StringVar(&cc.jobFilter, "job", "", "filter observations by job id (use '*' for globbing)")
+	cmd.Flags().StringVar(&cc.srcFilter, "src", "", "filter observations by source (use '*' for globbing)")
+	cmd.Flags().StringVar(&cc.destFilter, "dest", "", "filter observations by destination (use '*' for globbing)")
+	return cmd
+}
+
+func (cc *compareCommand) compare(cmd *cobra.Command, args []string) error {
+	if cc.after == "" && cc.changeTime != "" {
+		cc.after = cc.before
+	}
+	if cc.before == "" || cc.after == "" {
+		return fmt.Errorf("missing option --before or --after")
+	}
+	if cc.threshold < 0 || cc.threshold > 1 {
+		return fmt.Errorf("invalid threshold %f: must be in range 0..1", cc.threshold)
+	}
+	if cc.window != 0 && cc.changeTime == "" {
+		return fmt.Errorf("option --window requires --change-time")
+	}
+
+	filter, err := cc.prepareFilter()
+	if err != nil {
+		return err
+	}
+	beforeFilter, afterFilter := *filter, *filter
+	if cc.changeTime != "" {
+		millis, err := analysis.ParseTimestamp(cc.changeTime)
+		if err != nil {
+			return err
+		}
+		changeTime := time.UnixMilli(millis)
+		beforeFilter.End = changeTime.Add(-1 * time.Millisecond)
+		afterFilter.Start = changeTime
+		if cc.window > 0 {
+			beforeFilter.Start = changeTime.Add(-cc.window)
+			afterFilter.End = changeTime.Add(cc.window)
+		}
+	}
+	before, err := cc.loadSnapshot(cc.before, &beforeFilter)
+	if err != nil {
+		return err
+	}
+	after, err := cc.loadSnapshot(cc.after, &afterFilter)
+	if err != nil {
+		return err
+	}
+
+	report := analysis.Compare(before, after, cc.threshold)
+	if cc.jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printReport(os.Stdout, report, cc.all)
+	}
+	if count := report.Count(analysis.ChangeRegression); cc.failOnRegr && count > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d edges with regressions", count)
+	}
+	return nil
+}
+
+func (cc *compareCommand) prepareFilter() (*analysis.Filter, error) {
+	var err error
+	filter := &analysis.Filter{}
+	if filter.JobID, err = analysis.GlobMatcher(cc.jobFilter); err != nil {
+		return nil, err
+	}
+	if filter.SrcHost, err = analysis.GlobMatcher(cc.srcFilter); err != nil {
+		return nil, err
+	}
+	if filter.DestHost, err = analysis.GlobMatcher(cc.destFilter); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func (cc *compareCommand) loadSnapshot(directory string, filter *analysis.Filter) (*analysis.Report, error) {
+	source, err := analysis.OpenDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	report, err := analysis.AggregateSnapshot(source, *filter)
+	if err != nil {
+		return nil, fmt.Errorf("loading snapshot %s failed: %w", directory, err)
+	}
+	return report, nil
+}
+
+func printReport(w io.Writer, report *analysis.ComparisonReport, all bool) {
+	fmt.Fprintf(w, "Edges: %d regressions, %d improvements, %d unchanged, %d new, %d removed (threshold %.1f%%)\n",
+		report.Count(analysis.ChangeRegression), report.Count(analysis.ChangeImprovement), report.Count(analysis.ChangeUnchanged),
+		report.Count(analysis.ChangeNew), report.Count(analysis.ChangeRemoved), report.Threshold*100)
+	for _, section := range []struct {
+		name        string
+		comparisons []analysis.Comparison
+	}{
+		{name: "Destinations", comparisons: report.Destinations},
+		{name: "Edges", comparisons: report.Edges},
+	} {
+		fmt.Fprintf(w, "\n%s:\n", section.name)
+		for _, change := range []analysis.ChangeType{analysis.ChangeRegression, analysis.ChangeImprovement,
+			analysis.ChangeNew, analysis.ChangeRemoved, analysis.ChangeUnchanged} {
+			if change == analysis.ChangeUnchanged && !all {
+				continue
+			}
+			for _, c := range section.comparisons {
+				if c.Change == change {
+					fmt.Fprintf(w, "%-11s %s\n", c.Change, formatComparison(c))
+				}
+			}
+		}
+	}
+}
+
+func formatComparison(c analysis.Comparison) string {
+	edge := c.Dest
+	if c.Src != "" {
+		edge = c.Src + " -> " + c.Dest
+	}
+	return fmt.Sprintf("%s [%s]: %s => %s", edge, c.JobID, formatRate(c.Before), formatRate(c.After))
+}
+
+func formatRate(rate *analysis.Rate) string {
+	if rate == nil {
+		return "-"
+	}
+	s := fmt.Sprintf("%.1f%% (%d/%d)", rate.SuccessRate*100, rate.OkCount, rate.OkCount+rate.FailedCount)
+	if rate.MeanLatencyMillis > 0 {
+		s += fmt.Sprintf(" %dms", rate.MeanLatencyMillis)
+	}
+	return s
+}
@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/deploy"
+)
+
+const eventReasonClusterConfigSize = "ClusterConfigSize"
+
+// clusterConfigSizeWarning returns a warning if the complete cluster config exceeds deploy.ClusterConfigSizeWarningRatio
+// of the size limit, otherwise an empty string.
+func clusterConfigSizeWarning(layout *deploy.ClusterConfigLayout) string {
+	ratio := float64(layout.FullSize) / deploy.MaxClusterConfigMapDataSize
+	if ratio <= deploy.ClusterConfigSizeWarningRatio {
+		return ""
+	}
+	msg := fmt.Sprintf("cluster config uses %.0f%% of the size limit of the config map (%d of %d bytes)",
+		100*ratio, layout.FullSize, deploy.MaxClusterConfigMapDataSize)
+	switch {
+	case len(layout.Shards) > 0:
+		msg += fmt.Sprintf(", split into %d shards", len(layout.Shards))
+	case layout.Trimmed:
+		msg += ", zones and pools of the nodes dropped"
+	}
+	return msg
+}
+
+// reportClusterConfigSize reports the size of the cluster config by metrics and warns by log and event on the cluster config map
+// if it is close to the size limit.
+func reportClusterConfigSize(ctx context.Context, log logrus.FieldLogger, events typedcorev1.EventsGetter, cm *corev1.ConfigMap,
+	layout *deploy.ClusterConfigLayout, now time.Time) {
+	reportClusterConfigLayout(layout)
+	msg := clusterConfigSizeWarning(layout)
+	if msg == "" {
+		return
+	}
+	log.Warn(msg)
+	timestamp := metav1.NewTime(now)
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: cm.Name + ".",
+			Namespace:    cm.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      "v1",
+			Kind:            "ConfigMap",
+			Namespace:       cm.Namespace,
+			Name:            cm.Name,
+			UID:             cm.UID,
+			ResourceVersion: cm.ResourceVersion,
+		},
+		Reason:         eventReasonClusterConfigSize,
+		Message:        msg,
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: common.NameDeploymentAgentController},
+		FirstTimestamp: timestamp,
+		LastTimestamp:  timestamp,
+		Count:          1,
+	}
+	if _, err := events.Events(cm.Namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		log.Warnf("reporting event %s failed: %s", eventReasonClusterConfigSize, err)
+	}
+}
+
+// storeClusterConfigShards creates or updates the config maps of the shards. They are stored before the cluster config map,
+// so that the agents never see a cluster config referencing missing shards.
+func storeClusterConfigShards(ctx context.Context, configMaps typedcorev1.ConfigMapInterface, shards [][]byte, compressThreshold int) error {
+	for i, data := range shards {
+		name := deploy.ClusterConfigShardMapName(i)
+		cm, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+		create := errors.IsNotFound(err)
+		if create {
+			cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: common.NamespaceKubeSystem}}
+		} else if err != nil {
+			return fmt.Errorf("loading configmap %s/%s failed: %s", common.NamespaceKubeSystem, name, err)
+		}
+		changed, err := deploy.SetClusterConfigShardData(cm, i, data, compressThreshold)
+		if err != nil {
+			return err
+		}
+		switch {
+		case create:
+			_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+		case changed:
+			_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return fmt.Errorf("storing configmap %s/%s failed: %s", common.NamespaceKubeSystem, name, err)
+		}
+	}
+	return nil
+}
+
+// deleteObsoleteClusterConfigShards deletes the config maps of the shards from index 'from' to 'to' (exclusive)
+// after the cluster config map has been updated to fewer shards.
+func deleteObsoleteClusterConfigShards(ctx context.Context, log logrus.FieldLogger, configMaps typedcorev1.ConfigMapInterface, from, to int) {
+	for i := from; i < to; i++ {
+		name := deploy.ClusterConfigShardMapName(i)
+		if err := configMaps.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			log.Warnf("deleting configmap %s/%s failed: %s", common.NamespaceKubeSystem, name, err)
+			continue
+		}
+		log.Infof("deleted configmap %s/%s", common.NamespaceKubeSystem, name)
+	}
+}
@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/deploy"
+)
+
+// fakeShardConfigMaps implements Get, Create, Update and Delete for config maps by name.
+type fakeShardConfigMaps struct {
+	typedcorev1.ConfigMapInterface
+	items   map[string]*corev1.ConfigMap
+	writes  int
+	deleted []string
+}
+
+func (f *fakeShardConfigMaps) Get(_ context.Context, name string, _ metav1.GetOptions) (*corev1.ConfigMap, error) {
+	if cm, ok := f.items[name]; ok {
+		return cm.DeepCopy(), nil
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("configmaps"), name)
+}
+
+func (f *fakeShardConfigMaps) Create(_ context.Context, cm *corev1.ConfigMap, _ metav1.CreateOptions) (*corev1.ConfigMap, error) {
+	f.writes++
+	f.items[cm.Name] = cm.DeepCopy()
+	return cm, nil
+}
+
+func (f *fakeShardConfigMaps) Update(ctx context.Context, cm *corev1.ConfigMap, _ metav1.UpdateOptions) (*corev1.ConfigMap, error) {
+	return f.Create(ctx, cm, metav1.CreateOptions{})
+}
+
+func (f *fakeShardConfigMaps) Delete(_ context.Context, name string, _ metav1.DeleteOptions) error {
+	if _, ok := f.items[name]; !ok {
+		return apierrors.NewNotFound(corev1.Resource("configmaps"), name)
+	}
+	delete(f.items, name)
+	f.deleted = append(f.deleted, name)
+	return nil
+}
+
+// fakeEventsGetter returns the same events for all namespaces.
+type fakeEventsGetter struct {
+	events *fakeEvents
+}
+
+func (f *fakeEventsGetter) Events(_ string) typedcorev1.EventInterface {
+	return f.events
+}
+
+func TestStoreClusterConfigShards(t *testing.T) {
+	ctx := context.Background()
+	log := logrus.New()
+	configMaps := &fakeShardConfigMaps{items: map[string]*corev1.ConfigMap{}}
+
+	shards := [][]byte{[]byte("shardsGeneration: a\n"), []byte("shardsGeneration: a\n"), []byte("shardsGeneration: a\n")}
+	assert.NoError(t, storeClusterConfigShards(ctx, configMaps, shards, 0))
+	assert.Len(t, configMaps.items, 3)
+	assert.Equal(t, 3, configMaps.writes)
+	assert.NoError(t, storeClusterConfigShards(ctx, configMaps, shards, 0))
+	assert.Equal(t, 3, configMaps.writes, "unchanged shards must not be updated")
+
+	shards = [][]byte{[]byte("shardsGeneration: b\n"), []byte("shardsGeneration: b\n")}
+	assert.NoError(t, storeClusterConfigShards(ctx, configMaps, shards, 0))
+	assert.Equal(t, 5, configMaps.writes)
+	deleteObsoleteClusterConfigShards(ctx, log, configMaps, len(shards), 4)
+	assert.Equal(t, []string{deploy.ClusterConfigShardMapName(2)}, configMaps.deleted)
+	assert.Len(t, configMaps.items, 2)
+}
+
+func TestReportClusterConfigSize(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: common.NameClusterConfigMap, Namespace: common.NamespaceKubeSystem}}
+	getter := &fakeEventsGetter{events: &fakeEvents{}}
+	report := func(layout *deploy.ClusterConfigLayout) {
+		reportClusterConfigSize(context.Background(), logrus.New(), getter, cm, layout, time.Now())
+	}
+
+	report(&deploy.ClusterConfigLayout{FullSize: deploy.MaxClusterConfigMapDataSize / 2})
+	assert.Empty(t, getter.events.created)
+
+	report(&deploy.ClusterConfigLayout{FullSize: deploy.MaxClusterConfigMapDataSize * 9 / 10})
+	if assert.Len(t, getter.events.created, 1) {
+		event := getter.events.created[0]
+		assert.Equal(t, corev1.EventTypeWarning, event.Type)
+		assert.Equal(t, eventReasonClusterConfigSize, event.Reason)
+		assert.Equal(t, common.NameClusterConfigMap, event.InvolvedObject.Name)
+		assert.Contains(t, event.Message, "90%")
+	}
+
+	report(&deploy.ClusterConfigLayout{FullSize: 2 * deploy.MaxClusterConfigMapDataSize, Shards: make([][]byte, 3)})
+	if assert.Len(t, getter.events.created, 2) {
+		assert.Contains(t, getter.events.created[1].Message, "split into 3 shards")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/deploy"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/atomic"
 
@@ -21,7 +22,41 @@ type controllerCommand struct {
 	common.ClientsetBase
 	httpPort int
 
-	lastLoop atomic.Int64
+	pollPeriod      time.Duration
+	pollConcurrency int
+	pollTimeout     time.Duration
+
+	configDivergenceThreshold time.Duration
+
+	triangulateRelays   int
+	triangulateMaxEdges int
+
+	findingEvents               bool
+	findingEventsSustainedPolls int
+	findingEventsInterval       time.Duration
+
+	clusterEventsLimit int
+
+	clusterConfigCompressThreshold int
+
+	nodeLabelSelector    string
+	loadBalancerSelector string
+	serviceSelector      string
+
+	endpointStalenessPeriod      time.Duration
+	endpointStalenessGracePeriod time.Duration
+
+	maintenanceCheckPeriod time.Duration
+
+	endpointsFromURL         string
+	endpointsRefreshInterval time.Duration
+
+	agentConfigValidationPeriod time.Duration
+
+	networkChecksPeriod time.Duration
+
+	lastLoop   atomic.Int64
+	edgeMatrix atomic.Value
 }
 
 func CreateRunControllerCmd() *cobra.Command {
@@ -35,17 +70,48 @@ func CreateRunControllerCmd() *cobra.Command {
 	cc.AddKubeConfigFlag(cmd.Flags())
 	cc.AddInClusterFlag(cmd.Flags())
 	cmd.Flags().IntVar(&cc.httpPort, "http-port", 0, "if != 0, starts http server for metrics and healthz checks.")
+	cmd.Flags().IntVar(&cc.clusterEventsLimit, "cluster-events-limit", 1000, "maximum number of node lifecycle events kept in the configmap "+common.NameClusterEventsConfigMap+".")
+	cmd.Flags().IntVar(&cc.clusterConfigCompressThreshold, "cluster-config-compress-threshold", deploy.DefaultClusterConfigCompressThreshold, "size in bytes of the cluster config above which it is stored gzip compressed in the configmap "+common.NameClusterConfigMap+".")
+	cmd.Flags().StringVar(&cc.nodeLabelSelector, "node-label-selector", "", "if set, only nodes matching this label selector are added to the cluster config (e.g. 'worker.gardener.cloud/pool in (a,b)'). Must match the node selector of the agent daemon sets.")
+	cmd.Flags().StringVar(&cc.loadBalancerSelector, "load-balancer-selector", "", "label selector for services of type LoadBalancer to check in addition to the annotated ones.")
+	cmd.Flags().StringVar(&cc.serviceSelector, "service-selector", common.DefaultServiceSelector, "label selector for services to check from the pod network by their cluster IP (empty to disable).")
+	cmd.Flags().DurationVar(&cc.endpointStalenessPeriod, "endpoint-staleness-period", 0, "if != 0, checks endpoint slices for ready endpoints referencing non-ready or non-existent pods with this period.")
+	cmd.Flags().DurationVar(&cc.endpointStalenessGracePeriod, "endpoint-staleness-grace-period", 1*time.Minute, "minimum duration an endpoint must be stale before it is reported.")
+	cmd.Flags().DurationVar(&cc.maintenanceCheckPeriod, "maintenance-check-period", 1*time.Minute, "period to check the annotation "+common.AnnotationMaintenanceWindows+" of the configmap "+common.NameClusterConfigMap+" for changed or expired maintenance windows (0 to check only on cluster changes).")
+	cmd.Flags().StringVar(&cc.endpointsFromURL, "endpoints-from-url", "", "if set, loads an endpoint list from this URL into the cluster config for jobs with 'checkTCPPort --endpoints-of-url-list'.")
+	cmd.Flags().DurationVar(&cc.endpointsRefreshInterval, "endpoints-refresh-interval", 5*time.Minute, "refresh interval of the endpoint list loaded from the URL.")
+	cmd.Flags().DurationVar(&cc.pollPeriod, "poll-period", 0, "if != 0, polls the aggregated observations from all agents with this period.")
+	cmd.Flags().IntVar(&cc.pollConcurrency, "poll-concurrency", 10, "maximum number of agents polled concurrently.")
+	cmd.Flags().DurationVar(&cc.pollTimeout, "poll-timeout", 5*time.Second, "timeout for polling a single agent.")
+	cmd.Flags().IntVar(&cc.triangulateRelays, "triangulate-relays", 0, "if != 0, failing edges of the polled agents are triangulated by relayed checks of the destination from this number of agents on other nodes.")
+	cmd.Flags().IntVar(&cc.triangulateMaxEdges, "triangulate-max-edges", 10, "maximum number of failing edges triangulated per polling cycle.")
+	cmd.Flags().BoolVar(&cc.findingEvents, "finding-events", false, "if true, edges of the polled agents failing in consecutive polling cycles and asymmetric links are emitted as Kubernetes events on the affected nodes (requires --poll-period).")
+	cmd.Flags().IntVar(&cc.findingEventsSustainedPolls, "finding-events-sustained-polls", 3, "number of consecutive polling cycles an edge must fail before an event is emitted.")
+	cmd.Flags().DurationVar(&cc.findingEventsInterval, "finding-events-interval", 30*time.Minute, "minimum interval between two events of the same finding.")
+	cmd.Flags().DurationVar(&cc.configDivergenceThreshold, "config-divergence-threshold", 10*time.Minute, "polled agents running different generations of the agent config for longer than this duration are logged as warning.")
+	cmd.Flags().DurationVar(&cc.agentConfigValidationPeriod, "agent-config-validation-period", 0, "if != 0, validates the agent configmap "+common.NameAgentConfigMap+" with this period, stores the last valid config for the agents and reports invalid configs.")
+	cmd.Flags().DurationVar(&cc.networkChecksPeriod, "network-checks-period", 0, "if != 0, reconciles the custom resources NetworkCheck into the agent configmap "+common.NameAgentConfigMap+" with this period (requires the CRD).")
 
 	return cmd
 }
 
 func (cc *controllerCommand) runController(cmd *cobra.Command, args []string) error {
 	log := logrus.WithField("cmd", "controller")
+	if cc.endpointsFromURL != "" && cc.endpointsRefreshInterval < 10*time.Second {
+		return fmt.Errorf("invalid endpoints refresh interval %s: must be >= 10s", cc.endpointsRefreshInterval)
+	}
+	if cc.findingEvents && cc.pollPeriod == 0 {
+		return fmt.Errorf("option --finding-events requires --poll-period")
+	}
+	if cc.findingEvents && cc.findingEventsSustainedPolls < 1 {
+		return fmt.Errorf("invalid finding events sustained polls %d: must be >= 1", cc.findingEventsSustainedPolls)
+	}
 
 	if cc.httpPort != 0 {
 		log.Infof("provide metrics at ':%d/metrics'", cc.httpPort)
 		http.Handle("/metrics", promhttp.Handler())
 		http.HandleFunc("/healthz", cc.healthzHandler)
+		http.HandleFunc("/edges.json", cc.edgesHandler)
 		go func() {
 			http.ListenAndServe(fmt.Sprintf(":%d", cc.httpPort), nil)
 		}()
@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+// eventReasonInvalidAgentConfig is the reason of the events reported for an invalid agent config map.
+const eventReasonInvalidAgentConfig = "InvalidAgentConfig"
+
+// agentConfigValidator validates the agent config map, which may have been edited by hand.
+// The last valid content is stored in the config map as last known good config, which the agents use if the config is invalid.
+type agentConfigValidator struct {
+	configMaps typedcorev1.ConfigMapInterface
+	events     typedcorev1.EventInterface
+	now        func() time.Time
+	// reportedInvalid is the invalid content an event has been reported for
+	reportedInvalid string
+}
+
+func newAgentConfigValidator(configMaps typedcorev1.ConfigMapInterface, events typedcorev1.EventInterface) *agentConfigValidator {
+	return &agentConfigValidator{configMaps: configMaps, events: events, now: time.Now}
+}
+
+// validateAgentConfig runs the validation of the agent on the content of the agent config.
+func validateAgentConfig(content string) error {
+	if content == "" {
+		return fmt.Errorf("missing key %s", common.AgentConfigFilename)
+	}
+	cfg := &config.AgentConfig{}
+	if err := yaml.Unmarshal([]byte(content), cfg); err != nil {
+		return fmt.Errorf("unmarshalling %s failed: %w", common.AgentConfigFilename, err)
+	}
+	return runners.ValidateAgentConfig(cfg)
+}
+
+// check validates the agent config map. If it is valid, its content is stored as last known good config
+// and the validation errors annotation is removed. Otherwise the errors are written to the annotation and an event is reported once per content.
+// Agent config maps deployed with '--immutable-config' are not checked, as they cannot be edited.
+func (v *agentConfigValidator) check(ctx context.Context, log logrus.FieldLogger) error {
+	cm, err := v.configMaps.Get(ctx, common.NameAgentConfigMap, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	content := cm.Data[common.AgentConfigFilename]
+	validationErr := validateAgentConfig(content)
+	reportAgentConfigValidation(validationErr == nil)
+
+	changed := false
+	if validationErr == nil {
+		v.reportedInvalid = ""
+		if cm.Data[common.AgentConfigLastKnownGoodFilename] != content {
+			cm.Data[common.AgentConfigLastKnownGoodFilename] = content
+			changed = true
+		}
+		if _, ok := cm.Annotations[common.AnnotationConfigValidationErrors]; ok {
+			delete(cm.Annotations, common.AnnotationConfigValidationErrors)
+			changed = true
+		}
+	} else {
+		msg := validationErr.Error()
+		if cm.Annotations[common.AnnotationConfigValidationErrors] != msg {
+			if cm.Annotations == nil {
+				cm.Annotations = map[string]string{}
+			}
+			cm.Annotations[common.AnnotationConfigValidationErrors] = msg
+			changed = true
+		}
+		if v.reportedInvalid != content {
+			log.Errorf("invalid agent config in configmap %s/%s, agents keep the last known good config: %s", cm.Namespace, cm.Name, msg)
+			if err := v.reportEvent(ctx, cm, msg); err != nil {
+				log.Warnf("reporting event for configmap %s/%s failed: %s", cm.Namespace, cm.Name, err)
+			} else {
+				v.reportedInvalid = content
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if _, err := v.configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating configmap %s/%s failed: %w", cm.Namespace, cm.Name, err)
+	}
+	if validationErr == nil {
+		log.Infof("stored last known good agent config in configmap %s/%s", cm.Namespace, cm.Name)
+	}
+	return nil
+}
+
+func (v *agentConfigValidator) reportEvent(ctx context.Context, cm *corev1.ConfigMap, msg string) error {
+	now := metav1.NewTime(v.now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: cm.Name + ".",
+			Namespace:    cm.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      "v1",
+			Kind:            "ConfigMap",
+			Namespace:       cm.Namespace,
+			Name:            cm.Name,
+			UID:             cm.UID,
+			ResourceVersion: cm.ResourceVersion,
+		},
+		Reason:         eventReasonInvalidAgentConfig,
+		Message:        "invalid agent config, agents keep the last known good config: " + msg,
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: common.NameDeploymentAgentController},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err := v.events.Create(ctx, event, metav1.CreateOptions{})
+	return err
+}
+
+func (cc *controllerCommand) validateAgentConfigLoop(log logrus.FieldLogger, stopCh <-chan struct{}) {
+	validator := newAgentConfigValidator(cc.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem), cc.Clientset.CoreV1().Events(common.NamespaceKubeSystem))
+	ticker := time.NewTicker(cc.agentConfigValidationPeriod)
+	defer ticker.Stop()
+	for {
+		if err := validator.check(context.Background(), log); err != nil {
+			log.Errorf("validating agent config failed: %s", err)
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
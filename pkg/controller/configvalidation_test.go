@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+)
+
+// fakeConfigMaps implements Get and Update for a single config map. Other config maps are not found.
+type fakeConfigMaps struct {
+	typedcorev1.ConfigMapInterface
+	cm      *corev1.ConfigMap
+	updates int
+}
+
+func (f *fakeConfigMaps) Get(_ context.Context, name string, _ metav1.GetOptions) (*corev1.ConfigMap, error) {
+	if f.cm == nil || f.cm.Name != name {
+		return nil, apierrors.NewNotFound(corev1.Resource("configmaps"), name)
+	}
+	return f.cm.DeepCopy(), nil
+}
+
+func (f *fakeConfigMaps) Update(_ context.Context, cm *corev1.ConfigMap, _ metav1.UpdateOptions) (*corev1.ConfigMap, error) {
+	f.updates++
+	f.cm = cm.DeepCopy()
+	return cm, nil
+}
+
+// fakeEvents implements Create.
+type fakeEvents struct {
+	typedcorev1.EventInterface
+	created []*corev1.Event
+}
+
+func (f *fakeEvents) Create(_ context.Context, event *corev1.Event, _ metav1.CreateOptions) (*corev1.Event, error) {
+	f.created = append(f.created, event)
+	return event, nil
+}
+
+func TestAgentConfigValidator(t *testing.T) {
+	valid := `podNetwork:
+  jobs:
+  - jobID: tcp-p2n
+    args: [checkTCPPort, --node-port, "1234"]
+`
+	invalidArgs := `podNetwork:
+  jobs:
+  - jobID: tcp-p2n
+    args: [checkTCPPort, --node-prot, "1234"]
+`
+	configMaps := &fakeConfigMaps{cm: &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: common.NameAgentConfigMap, Namespace: common.NamespaceKubeSystem},
+		Data:       map[string]string{common.AgentConfigFilename: valid},
+	}}
+	events := &fakeEvents{}
+	validator := newAgentConfigValidator(configMaps, events)
+	ctx := context.Background()
+	log := logrus.New()
+
+	assert.NoError(t, validator.check(ctx, log))
+	assert.Equal(t, valid, configMaps.cm.Data[common.AgentConfigLastKnownGoodFilename])
+	assert.Equal(t, 1, configMaps.updates)
+	assert.NoError(t, validator.check(ctx, log))
+	assert.Equal(t, 1, configMaps.updates, "unchanged config map must not be updated")
+
+	for _, invalid := range []string{invalidArgs, "podNetwork: [", "aggregationReportPeriod: 10s\n"} {
+		configMaps.cm.Data[common.AgentConfigFilename] = invalid
+		assert.NoError(t, validator.check(ctx, log))
+		assert.Equal(t, valid, configMaps.cm.Data[common.AgentConfigLastKnownGoodFilename], invalid)
+		assert.NotEmpty(t, configMaps.cm.Annotations[common.AnnotationConfigValidationErrors], invalid)
+	}
+	assert.Contains(t, configMaps.cm.Annotations[common.AnnotationConfigValidationErrors], "AggregationReportPeriod")
+	assert.Len(t, events.created, 3)
+	assert.Equal(t, eventReasonInvalidAgentConfig, events.created[0].Reason)
+	assert.Equal(t, corev1.EventTypeWarning, events.created[0].Type)
+	assert.Equal(t, common.NameAgentConfigMap, events.created[0].InvolvedObject.Name)
+	assert.Contains(t, events.created[0].Message, "unknown flag: --node-prot")
+
+	// an event is reported once per invalid content
+	assert.NoError(t, validator.check(ctx, log))
+	assert.Len(t, events.created, 3)
+
+	fixed := valid + "  - jobID: ping-p2n\n    args: [pingHost]\n"
+	configMaps.cm.Data[common.AgentConfigFilename] = fixed
+	assert.NoError(t, validator.check(ctx, log))
+	assert.Equal(t, fixed, configMaps.cm.Data[common.AgentConfigLastKnownGoodFilename])
+	assert.NotContains(t, configMaps.cm.Annotations, common.AnnotationConfigValidationErrors)
+}
@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// buildEdgeMatrix sums up the successful and failed checks per job and edge over the aggregations of all polled agents.
+func buildEdgeMatrix(now time.Time, window time.Duration, responses map[string]*nwpd.GetAggregatedObservationsResponse) *common.EdgeMatrix {
+	edges := map[jobEdge]*common.EdgeHealth{}
+	get := func(jobID string, aggr *nwpd.AggregatedObservation) *common.EdgeHealth {
+		key := jobEdge{JobID: jobID, SrcHost: aggr.SrcHost, DestHost: aggr.DestHost}
+		edge := edges[key]
+		if edge == nil {
+			edge = &common.EdgeHealth{JobID: jobID, SrcHost: aggr.SrcHost, DestHost: aggr.DestHost}
+			edges[key] = edge
+		}
+		return edge
+	}
+	for _, resp := range responses {
+		for _, aggr := range resp.AggregatedObservations {
+			for jobID, count := range aggr.JobsOkCount {
+				get(jobID, aggr).OkCount += int(count)
+			}
+			for jobID, count := range aggr.JobsNotOkCount {
+				get(jobID, aggr).FailedCount += int(count)
+			}
+		}
+	}
+	matrix := &common.EdgeMatrix{
+		Timestamp:    now.UTC(),
+		Window:       window.String(),
+		PolledAgents: len(responses),
+		Edges:        []common.EdgeHealth{},
+	}
+	for _, edge := range edges {
+		matrix.Edges = append(matrix.Edges, *edge)
+	}
+	sort.Slice(matrix.Edges, func(i, j int) bool {
+		a, b := matrix.Edges[i], matrix.Edges[j]
+		if a.JobID != b.JobID {
+			return a.JobID < b.JobID
+		}
+		if a.SrcHost != b.SrcHost {
+			return a.SrcHost < b.SrcHost
+		}
+		return a.DestHost < b.DestHost
+	})
+	return matrix
+}
+
+// edgesHandler serves the edge matrix of the last polling cycle.
+func (cc *controllerCommand) edgesHandler(w http.ResponseWriter, _ *http.Request) {
+	matrix, _ := cc.edgeMatrix.Load().(*common.EdgeMatrix)
+	if matrix == nil {
+		http.Error(w, "no polling cycle completed (requires --poll-period)", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(matrix)
+}
@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestBuildEdgeMatrix(t *testing.T) {
+	now := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	responses := map[string]*nwpd.GetAggregatedObservationsResponse{
+		"pod-a": {AggregatedObservations: []*nwpd.AggregatedObservation{
+			{SrcHost: "node-a", DestHost: "node-b", JobsOkCount: map[string]int32{"tcp-p2p": 2}, JobsNotOkCount: map[string]int32{"tcp-p2p": 1}},
+			{SrcHost: "node-a", DestHost: "node-b", JobsOkCount: map[string]int32{"tcp-p2p": 3}},
+		}},
+		"host-a": {AggregatedObservations: []*nwpd.AggregatedObservation{
+			{SrcHost: "node-a", DestHost: "node-c", JobsNotOkCount: map[string]int32{"ping-n2n": 4}},
+		}},
+	}
+	matrix := buildEdgeMatrix(now, time.Minute, responses)
+	assert.Equal(t, &common.EdgeMatrix{
+		Timestamp:    now,
+		Window:       "1m0s",
+		PolledAgents: 2,
+		Edges: []common.EdgeHealth{
+			{JobID: "ping-n2n", SrcHost: "node-a", DestHost: "node-c", FailedCount: 4},
+			{JobID: "tcp-p2p", SrcHost: "node-a", DestHost: "node-b", OkCount: 5, FailedCount: 1},
+		},
+	}, matrix)
+}
+
+func TestEdgesHandler(t *testing.T) {
+	cc := &controllerCommand{}
+	rec := httptest.NewRecorder()
+	cc.edgesHandler(rec, httptest.NewRequest(http.MethodGet, "/edges.json", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	cc.edgeMatrix.Store(buildEdgeMatrix(time.Now(), time.Minute, nil))
+	rec = httptest.NewRecorder()
+	cc.edgesHandler(rec, httptest.NewRequest(http.MethodGet, "/edges.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	matrix := &common.EdgeMatrix{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), matrix))
+	assert.Empty(t, matrix.Edges)
+	assert.NotNil(t, matrix.Edges)
+}
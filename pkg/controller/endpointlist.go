@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+const (
+	// endpointListFetchTimeout is the timeout for fetching the endpoint list
+	endpointListFetchTimeout = 30 * time.Second
+	// maxEndpointListSize is the maximum size of the endpoint list document
+	maxEndpointListSize = 1 << 20
+)
+
+// endpointListFetcher periodically loads the endpoint list from a URL and keeps the last good list on failures.
+type endpointListFetcher struct {
+	url    string
+	client *http.Client
+
+	lock sync.Mutex
+	last *config.EndpointList
+}
+
+func newEndpointListFetcher(url string) *endpointListFetcher {
+	return &endpointListFetcher{
+		url:    url,
+		client: &http.Client{Timeout: endpointListFetchTimeout},
+	}
+}
+
+// fetch loads and parses the endpoint list.
+func (f *endpointListFetcher) fetch(ctx context.Context) (*config.EndpointList, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching endpoint list from %s failed with status %s", f.url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxEndpointListSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxEndpointListSize {
+		return nil, fmt.Errorf("endpoint list from %s exceeds %d bytes", f.url, maxEndpointListSize)
+	}
+	return config.ParseEndpointList(data, f.url, resp.Header.Get("ETag"))
+}
+
+// refresh fetches the endpoint list and returns true if it has changed.
+// On failure, the last good list is kept.
+func (f *endpointListFetcher) refresh(ctx context.Context) (bool, error) {
+	list, err := f.fetch(ctx)
+	if err != nil {
+		return false, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	changed := !reflect.DeepEqual(f.last, list)
+	f.last = list
+	return changed, nil
+}
+
+// current returns the last good list. Until the first successful fetch, the given list from the cluster config
+// is used as fallback if it has been loaded from the same URL.
+func (f *endpointListFetcher) current(fallback *config.EndpointList) *config.EndpointList {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.last == nil && fallback != nil && fallback.Source == f.url {
+		return fallback
+	}
+	return f.last
+}
+
+func (cc *controllerCommand) refreshEndpointListLoop(log logrus.FieldLogger, fetcher *endpointListFetcher,
+	controller *nodePodController, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(cc.endpointsRefreshInterval)
+	defer ticker.Stop()
+	for {
+		changed, err := fetcher.refresh(context.Background())
+		reportEndpointListFetch(err == nil)
+		if err != nil {
+			log.Warnf("keeping last endpoint list: %s", err)
+		} else if changed {
+			list := fetcher.current(nil)
+			log.Infof("loaded endpoint list version %s with %d endpoints from %s", list.Version, len(list.Endpoints), list.Source)
+			controller.hasUpdates.Store(true)
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+func TestEndpointListFetcher(t *testing.T) {
+	body := `{"version": "1", "endpoints": [{"hostname": "db", "ip": "10.1.0.5", "port": 5432}]}`
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	fetcher := newEndpointListFetcher(server.URL)
+	fallback := &config.EndpointList{Source: server.URL, Version: "0"}
+	assert.Equal(t, fallback, fetcher.current(fallback))
+	assert.Nil(t, fetcher.current(&config.EndpointList{Source: "https://other.example.com", Version: "0"}))
+
+	changed, err := fetcher.refresh(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	list := fetcher.current(fallback)
+	if assert.NotNil(t, list) {
+		assert.Equal(t, "1", list.Version)
+		assert.Equal(t, server.URL, list.Source)
+		assert.Len(t, list.Endpoints, 1)
+	}
+
+	changed, err = fetcher.refresh(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	// the last good list is kept on failures
+	status = http.StatusInternalServerError
+	_, err = fetcher.refresh(context.Background())
+	assert.Error(t, err)
+	status = http.StatusOK
+	body = `{"endpoints": [{"hostname": "db"}]}`
+	_, err = fetcher.refresh(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, list, fetcher.current(nil))
+
+	body = `{"version": "2", "endpoints": []}`
+	changed, err = fetcher.refresh(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "2", fetcher.current(nil).Version)
+}
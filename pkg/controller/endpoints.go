@@ -0,0 +1,174 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// staleEndpointReason describes why an endpoint is stale.
+type staleEndpointReason string
+
+const (
+	// staleReasonPodNotFound means the endpoint references a pod which does not exist.
+	staleReasonPodNotFound staleEndpointReason = "pod-not-found"
+	// staleReasonPodReplaced means the endpoint references a deleted pod replaced by a pod with the same name.
+	staleReasonPodReplaced staleEndpointReason = "pod-replaced"
+	// staleReasonPodNotReady means the endpoint is ready, but the referenced pod is not.
+	staleReasonPodNotReady staleEndpointReason = "pod-not-ready"
+)
+
+// staleEndpoint is a ready endpoint of an endpoint slice which references a non-ready or non-existent pod.
+// Connections to it fail because of control plane lag, not because of a network problem.
+type staleEndpoint struct {
+	Namespace string
+	Service   string
+	Slice     string
+	Address   string
+	Pod       string
+	Reason    staleEndpointReason
+}
+
+func (e staleEndpoint) key() string {
+	return e.Namespace + "/" + e.Slice + "/" + e.Address + "/" + e.Pod
+}
+
+// findStaleEndpoints compares the ready endpoints of the endpoint slices with the readiness of the referenced pods.
+func findStaleEndpoints(slices []discoveryv1.EndpointSlice, pods []corev1.Pod) []staleEndpoint {
+	podsByName := map[types.NamespacedName]*corev1.Pod{}
+	for i := range pods {
+		pod := &pods[i]
+		podsByName[types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}] = pod
+	}
+
+	var result []staleEndpoint
+	for _, slice := range slices {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.TargetRef == nil || endpoint.TargetRef.Kind != "Pod" || len(endpoint.Addresses) == 0 {
+				continue
+			}
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			namespace := endpoint.TargetRef.Namespace
+			if namespace == "" {
+				namespace = slice.Namespace
+			}
+			var reason staleEndpointReason
+			pod := podsByName[types.NamespacedName{Namespace: namespace, Name: endpoint.TargetRef.Name}]
+			switch {
+			case pod == nil:
+				reason = staleReasonPodNotFound
+			case endpoint.TargetRef.UID != "" && endpoint.TargetRef.UID != pod.UID:
+				reason = staleReasonPodReplaced
+			case !isPodReady(pod):
+				reason = staleReasonPodNotReady
+			default:
+				continue
+			}
+			result = append(result, staleEndpoint{
+				Namespace: slice.Namespace,
+				Service:   slice.Labels[discoveryv1.LabelServiceName],
+				Slice:     slice.Name,
+				Address:   endpoint.Addresses[0],
+				Pod:       endpoint.TargetRef.Name,
+				Reason:    reason,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].key() < result[j].key() })
+	return result
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// endpointStalenessChecker reports stale endpoints which are stale for longer than the grace period,
+// as the endpoint slice controller needs some time to update the endpoints.
+type endpointStalenessChecker struct {
+	gracePeriod time.Duration
+	firstSeen   map[string]time.Time
+}
+
+func newEndpointStalenessChecker(gracePeriod time.Duration) *endpointStalenessChecker {
+	return &endpointStalenessChecker{
+		gracePeriod: gracePeriod,
+		firstSeen:   map[string]time.Time{},
+	}
+}
+
+// check returns the stale endpoints which have been seen stale for at least the grace period.
+func (c *endpointStalenessChecker) check(now time.Time, slices []discoveryv1.EndpointSlice, pods []corev1.Pod) []staleEndpoint {
+	firstSeen := map[string]time.Time{}
+	var result []staleEndpoint
+	for _, endpoint := range findStaleEndpoints(slices, pods) {
+		key := endpoint.key()
+		seen, ok := c.firstSeen[key]
+		if !ok {
+			seen = now
+		}
+		firstSeen[key] = seen
+		if now.Sub(seen) >= c.gracePeriod {
+			result = append(result, endpoint)
+		}
+	}
+	c.firstSeen = firstSeen
+	return result
+}
+
+// checkEndpointStalenessLoop periodically checks all endpoint slices for stale endpoints until the stop channel is closed.
+func (cc *controllerCommand) checkEndpointStalenessLoop(log logrus.FieldLogger, stopCh <-chan struct{}) {
+	checker := newEndpointStalenessChecker(cc.endpointStalenessGracePeriod)
+	ticker := time.NewTicker(cc.endpointStalenessPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+		stale, err := cc.checkEndpointStaleness(context.Background(), checker)
+		if err != nil {
+			log.Errorf("checking endpoint staleness failed: %s", err)
+			continue
+		}
+		reportStaleEndpoints(stale)
+		for _, endpoint := range stale {
+			log.Warnf("stale endpoint %s of service %s/%s (endpointslice %s): %s %s (not a network problem)",
+				endpoint.Address, endpoint.Namespace, endpoint.Service, endpoint.Slice, endpoint.Reason, endpoint.Pod)
+		}
+	}
+}
+
+func (cc *controllerCommand) checkEndpointStaleness(ctx context.Context, checker *endpointStalenessChecker) ([]staleEndpoint, error) {
+	// resource version "0" allows the kube-apiserver to serve the lists from its watch cache
+	options := metav1.ListOptions{ResourceVersion: "0"}
+	slices, err := cc.Clientset.DiscoveryV1().EndpointSlices(metav1.NamespaceAll).List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := cc.Clientset.CoreV1().Pods(metav1.NamespaceAll).List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	return checker.check(time.Now(), slices.Items, pods.Items), nil
+}
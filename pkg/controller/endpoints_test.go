@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+)
+
+func newPod(name string, uid types.UID, ready corev1.ConditionStatus) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, UID: uid},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}},
+		},
+	}
+}
+
+func newEndpoint(address, podname string, uid types.UID, ready *bool) discoveryv1.Endpoint {
+	return discoveryv1.Endpoint{
+		Addresses:  []string{address},
+		Conditions: discoveryv1.EndpointConditions{Ready: ready},
+		TargetRef:  &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: podname, UID: uid},
+	}
+}
+
+func TestFindStaleEndpoints(t *testing.T) {
+	slices := []discoveryv1.EndpointSlice{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "web-abcde",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "web"},
+			},
+			Endpoints: []discoveryv1.Endpoint{
+				newEndpoint("100.96.0.1", "web-1", "uid1", pointer.Bool(true)),
+				newEndpoint("100.96.0.2", "web-2", "uid2", nil),
+				newEndpoint("100.96.0.3", "web-3", "uid3", pointer.Bool(true)),
+				newEndpoint("100.96.0.4", "web-4", "uid4", pointer.Bool(true)),
+				newEndpoint("100.96.0.5", "web-5", "uid5", pointer.Bool(false)),
+				{Addresses: []string{"10.0.0.1"}},
+			},
+		},
+	}
+	pods := []corev1.Pod{
+		newPod("web-1", "uid1", corev1.ConditionTrue),
+		newPod("web-2", "uid2", corev1.ConditionFalse),
+		newPod("web-4", "uid4-new", corev1.ConditionTrue),
+		newPod("web-5", "uid5", corev1.ConditionFalse),
+	}
+
+	assert.Equal(t, []staleEndpoint{
+		{Namespace: "default", Service: "web", Slice: "web-abcde", Address: "100.96.0.2", Pod: "web-2", Reason: staleReasonPodNotReady},
+		{Namespace: "default", Service: "web", Slice: "web-abcde", Address: "100.96.0.3", Pod: "web-3", Reason: staleReasonPodNotFound},
+		{Namespace: "default", Service: "web", Slice: "web-abcde", Address: "100.96.0.4", Pod: "web-4", Reason: staleReasonPodReplaced},
+	}, findStaleEndpoints(slices, pods))
+}
+
+func TestEndpointStalenessChecker(t *testing.T) {
+	slices := []discoveryv1.EndpointSlice{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-abcde"},
+			Endpoints:  []discoveryv1.Endpoint{newEndpoint("100.96.0.1", "web-1", "uid1", nil)},
+		},
+	}
+	notReady := []corev1.Pod{newPod("web-1", "uid1", corev1.ConditionFalse)}
+	ready := []corev1.Pod{newPod("web-1", "uid1", corev1.ConditionTrue)}
+
+	now := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	checker := newEndpointStalenessChecker(1 * time.Minute)
+	assert.Empty(t, checker.check(now, slices, notReady), "within grace period")
+	assert.Len(t, checker.check(now.Add(1*time.Minute), slices, notReady), 1)
+	assert.Empty(t, checker.check(now.Add(2*time.Minute), slices, ready))
+	assert.Empty(t, checker.check(now.Add(3*time.Minute), slices, notReady), "grace period restarts")
+}
@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+// nodeEventRecorder keeps the node lifecycle events until they are stored.
+type nodeEventRecorder struct {
+	lock    sync.Mutex
+	enabled bool
+	pending []config.NodeEvent
+	now     func() time.Time
+}
+
+func newNodeEventRecorder() *nodeEventRecorder {
+	return &nodeEventRecorder{now: time.Now}
+}
+
+// enable starts recording. Events of the initial informer sync are not recorded.
+func (r *nodeEventRecorder) enable() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.enabled = true
+}
+
+func (r *nodeEventRecorder) record(events ...config.NodeEvent) {
+	if len(events) == 0 {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.enabled {
+		r.pending = append(r.pending, events...)
+	}
+}
+
+// onAdd records the creation of a node.
+func (r *nodeEventRecorder) onAdd(node *corev1.Node) {
+	r.record(config.NodeEvent{Time: r.now(), Node: node.Name, Type: config.NodeEventAdded, New: readyStatus(node)})
+}
+
+// onUpdate records changes of the Ready condition and the taints of a node.
+func (r *nodeEventRecorder) onUpdate(oldNode, newNode *corev1.Node) {
+	var events []config.NodeEvent
+	if oldReady, newReady := readyStatus(oldNode), readyStatus(newNode); oldReady != newReady {
+		events = append(events, config.NodeEvent{Time: r.now(), Node: newNode.Name, Type: config.NodeEventReady, Old: oldReady, New: newReady})
+	}
+	if oldTaints, newTaints := taintsString(oldNode), taintsString(newNode); oldTaints != newTaints {
+		events = append(events, config.NodeEvent{Time: r.now(), Node: newNode.Name, Type: config.NodeEventTaints, Old: oldTaints, New: newTaints})
+	}
+	r.record(events...)
+}
+
+// onDelete records the deletion of a node.
+func (r *nodeEventRecorder) onDelete(node *corev1.Node) {
+	r.record(config.NodeEvent{Time: r.now(), Node: node.Name, Type: config.NodeEventRemoved, Old: readyStatus(node)})
+}
+
+// peek returns the pending events.
+func (r *nodeEventRecorder) peek() []config.NodeEvent {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]config.NodeEvent(nil), r.pending...)
+}
+
+// ack removes the first count pending events after they have been stored.
+func (r *nodeEventRecorder) ack(count int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.pending = r.pending[count:]
+}
+
+func readyStatus(node *corev1.Node) string {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return string(cond.Status)
+		}
+	}
+	return string(corev1.ConditionUnknown)
+}
+
+func taintsString(node *corev1.Node) string {
+	var taints []string
+	for _, taint := range node.Spec.Taints {
+		s := taint.Key
+		if taint.Value != "" {
+			s += "=" + taint.Value
+		}
+		taints = append(taints, s+":"+string(taint.Effect))
+	}
+	sort.Strings(taints)
+	return strings.Join(taints, ",")
+}
+
+// storeNodeEvents appends the pending node events to the bounded event log in the cluster events config map.
+func (cc *controllerCommand) storeNodeEvents(ctx context.Context, log logrus.FieldLogger, recorder *nodeEventRecorder) {
+	pending := recorder.peek()
+	if len(pending) == 0 {
+		return
+	}
+	if err := cc.appendNodeEvents(ctx, pending); err != nil {
+		log.Errorf("storing %d node events failed: %s", len(pending), err)
+		return
+	}
+	recorder.ack(len(pending))
+	log.Infof("stored %d node events in configmap %s/%s", len(pending), common.NamespaceKubeSystem, common.NameClusterEventsConfigMap)
+}
+
+func (cc *controllerCommand) appendNodeEvents(ctx context.Context, added []config.NodeEvent) error {
+	configmaps := cc.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem)
+	cm, err := configmaps.Get(ctx, common.NameClusterEventsConfigMap, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	create := err != nil
+	if create {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      common.NameClusterEventsConfigMap,
+				Namespace: common.NamespaceKubeSystem,
+			},
+		}
+	}
+	events, err := config.UnmarshalNodeEvents([]byte(cm.Data[common.ClusterEventsFilename]))
+	if err != nil {
+		return fmt.Errorf("invalid content of configmap %s/%s: %w", cm.Namespace, cm.Name, err)
+	}
+	events = config.AppendNodeEvents(events, cc.clusterEventsLimit, added...)
+	data, err := config.MarshalNodeEvents(events)
+	if err != nil {
+		return err
+	}
+	cm.Data = map[string]string{common.ClusterEventsFilename: string(data)}
+	if create {
+		_, err = configmaps.Create(ctx, cm, metav1.CreateOptions{})
+	} else {
+		_, err = configmaps.Update(ctx, cm, metav1.UpdateOptions{})
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+func newNode(name string, ready corev1.ConditionStatus, taints ...corev1.Taint) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.NodeSpec{Taints: taints},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+		},
+	}
+}
+
+func TestNodeEventRecorder(t *testing.T) {
+	now := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	recorder := newNodeEventRecorder()
+	recorder.now = func() time.Time { return now }
+
+	node := newNode("node1", corev1.ConditionTrue)
+	recorder.onAdd(node)
+	assert.Empty(t, recorder.peek(), "events before initial sync must not be recorded")
+
+	recorder.enable()
+	recorder.onAdd(newNode("node2", corev1.ConditionFalse))
+	recorder.onUpdate(node, node)
+	notReady := newNode("node1", corev1.ConditionUnknown,
+		corev1.Taint{Key: "node.kubernetes.io/unreachable", Effect: corev1.TaintEffectNoSchedule},
+		corev1.Taint{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoExecute})
+	recorder.onUpdate(node, notReady)
+	recorder.onDelete(notReady)
+
+	assert.Equal(t, []config.NodeEvent{
+		{Time: now, Node: "node2", Type: config.NodeEventAdded, New: "False"},
+		{Time: now, Node: "node1", Type: config.NodeEventReady, Old: "True", New: "Unknown"},
+		{Time: now, Node: "node1", Type: config.NodeEventTaints, New: "dedicated=infra:NoExecute,node.kubernetes.io/unreachable:NoSchedule"},
+		{Time: now, Node: "node1", Type: config.NodeEventRemoved, Old: "Unknown"},
+	}, recorder.peek())
+
+	recorder.ack(3)
+	recorder.onAdd(node)
+	pending := recorder.peek()
+	if assert.Len(t, pending, 2) {
+		assert.Equal(t, config.NodeEventRemoved, pending[0].Type)
+		assert.Equal(t, config.NodeEventAdded, pending[1].Type)
+	}
+}
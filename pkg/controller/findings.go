@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+const (
+	// reasonEdgeFailing is the event reason of an edge failing in consecutive polling cycles.
+	reasonEdgeFailing = "NetworkEdgeFailing"
+	// reasonLinkAsymmetric is the event reason of a failing edge whose reverse edge is ok.
+	reasonLinkAsymmetric = "NetworkLinkAsymmetric"
+
+	// maxFindingEventsPerPoll limits the findings emitted per polling cycle, e.g. during a cluster wide outage.
+	// Further findings are emitted in the next cycles.
+	maxFindingEventsPerPoll = 20
+)
+
+// jobEdge is an edge of a job independent of the reporting daemon set.
+type jobEdge struct {
+	JobID    string
+	SrcHost  string
+	DestHost string
+}
+
+// finding is a network problem detected from the polled observations, emitted as event on the affected nodes.
+type finding struct {
+	Reason string
+	Edge   failingEdge
+	// Polls is the number of consecutive polling cycles the edge has been failing
+	Polls int
+	Since time.Time
+}
+
+// key identifies the finding for deduplication.
+func (f finding) key() string {
+	return f.Reason + "/" + f.Edge.String()
+}
+
+// Nodes returns the hosts of the edge, which are only used if they are known nodes.
+func (f finding) Nodes() []string {
+	return []string{f.Edge.SrcHost, f.Edge.DestHost}
+}
+
+func (f finding) Message() string {
+	switch f.Reason {
+	case reasonLinkAsymmetric:
+		return fmt.Sprintf("asymmetric link for job %s: %s->%s failing since %s (%d polls), %s->%s ok",
+			f.Edge.JobID, f.Edge.SrcHost, f.Edge.DestHost, f.Since.UTC().Format(time.RFC3339), f.Polls, f.Edge.DestHost, f.Edge.SrcHost)
+	default:
+		return fmt.Sprintf("edge %s failing since %s (%d polls)", f.Edge, f.Since.UTC().Format(time.RFC3339), f.Polls)
+	}
+}
+
+// findingTracker detects edges failing for several consecutive polling cycles and limits the rate of their findings.
+type findingTracker struct {
+	// sustainedPolls is the number of consecutive polling cycles an edge must fail before it is a finding
+	sustainedPolls int
+	// interval is the minimum interval between two events of the same finding
+	interval time.Duration
+	failing  map[failingEdge]finding
+	emitted  map[string]time.Time
+}
+
+func newFindingTracker(sustainedPolls int, interval time.Duration) *findingTracker {
+	return &findingTracker{
+		sustainedPolls: sustainedPolls,
+		interval:       interval,
+		failing:        map[failingEdge]finding{},
+		emitted:        map[string]time.Time{},
+	}
+}
+
+// update processes the failing and the ok edges of a polling cycle and returns the findings to emit.
+// A finding is only returned again after the interval, even if the edge recovered in between.
+func (t *findingTracker) update(now time.Time, failing []failingEdge, ok map[jobEdge]bool) []finding {
+	current := map[failingEdge]finding{}
+	for _, edge := range failing {
+		f, found := t.failing[edge]
+		if !found {
+			f = finding{Edge: edge, Since: now}
+		}
+		f.Polls++
+		current[edge] = f
+	}
+	t.failing = current
+	for key, last := range t.emitted {
+		if now.Sub(last) >= t.interval {
+			delete(t.emitted, key)
+		}
+	}
+
+	var result []finding
+	for edge, f := range current {
+		if f.Polls < t.sustainedPolls {
+			continue
+		}
+		f.Reason = reasonEdgeFailing
+		if ok[jobEdge{JobID: edge.JobID, SrcHost: edge.DestHost, DestHost: edge.SrcHost}] {
+			f.Reason = reasonLinkAsymmetric
+		}
+		if _, found := t.emitted[f.key()]; !found {
+			result = append(result, f)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].key() < result[j].key() })
+	if len(result) > maxFindingEventsPerPoll {
+		result = result[:maxFindingEventsPerPoll]
+	}
+	for _, f := range result {
+		t.emitted[f.key()] = now
+	}
+	return result
+}
+
+// findOkEdges returns the edges with at least one successful check reported by the polled agents.
+func findOkEdges(responses map[string]*nwpd.GetAggregatedObservationsResponse) map[jobEdge]bool {
+	edges := map[jobEdge]bool{}
+	for _, resp := range responses {
+		for _, aggr := range resp.AggregatedObservations {
+			for jobID, okCount := range aggr.JobsOkCount {
+				if okCount > 0 {
+					edges[jobEdge{JobID: jobID, SrcHost: aggr.SrcHost, DestHost: aggr.DestHost}] = true
+				}
+			}
+		}
+	}
+	return edges
+}
+
+// emitFindings emits the findings as warning events on the affected nodes.
+// Hosts which are no nodes (e.g. the kube-apiserver) are skipped. It returns the number of emitted events.
+func emitFindings(recorder record.EventRecorder, findings []finding, nodes []*corev1.Node) int {
+	nodesByName := map[string]*corev1.Node{}
+	for _, node := range nodes {
+		nodesByName[node.Name] = node
+	}
+	count := 0
+	for _, f := range findings {
+		seen := map[string]bool{}
+		for _, name := range f.Nodes() {
+			node := nodesByName[name]
+			if node == nil || seen[name] {
+				continue
+			}
+			seen[name] = true
+			ref := &corev1.ObjectReference{Kind: "Node", Name: node.Name, UID: node.UID}
+			recorder.Event(ref, corev1.EventTypeWarning, f.Reason, f.Message())
+			reportFindingEvent(f.Reason)
+			count++
+		}
+	}
+	return count
+}
+
+// newFindingEventRecorder creates an event recorder for the findings of the controller.
+// The event broadcaster additionally aggregates similar events and limits their rate per node.
+func newFindingEventRecorder(log logrus.FieldLogger, clientset kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(log.Debugf)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	return broadcaster.NewRecorder(runtime.NewScheme(), corev1.EventSource{Component: common.NameDeploymentAgentController})
+}
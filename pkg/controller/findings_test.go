@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestFindingTracker(t *testing.T) {
+	tracker := newFindingTracker(2, 30*time.Minute)
+	start := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	failing := []failingEdge{
+		{JobID: "tcp-n2n", SrcHost: "node-a", DestHost: "node-b"},
+		{JobID: "tcp-n2api-ext", SrcHost: "node-a", DestHost: "api"},
+	}
+	ok := findOkEdges(map[string]*nwpd.GetAggregatedObservationsResponse{
+		"host-b": {AggregatedObservations: []*nwpd.AggregatedObservation{
+			{SrcHost: "node-b", DestHost: "node-a", JobsOkCount: map[string]int32{"tcp-n2n": 2}},
+		}},
+	})
+
+	assert.Empty(t, tracker.update(start, failing, ok), "not sustained yet")
+	found := tracker.update(start.Add(1*time.Minute), failing, ok)
+	if assert.Len(t, found, 2) {
+		assert.Equal(t, reasonEdgeFailing, found[0].Reason)
+		assert.Equal(t, "edge tcp-n2api-ext node-a->api failing since 2022-08-01T10:00:00Z (2 polls)", found[0].Message())
+		assert.Equal(t, reasonLinkAsymmetric, found[1].Reason)
+		assert.Equal(t, "asymmetric link for job tcp-n2n: node-a->node-b failing since 2022-08-01T10:00:00Z (2 polls), node-b->node-a ok", found[1].Message())
+	}
+	assert.Empty(t, tracker.update(start.Add(2*time.Minute), failing, ok), "deduplicated within the interval")
+
+	// a recovered edge starts again, but is still deduplicated within the interval
+	assert.Empty(t, tracker.update(start.Add(3*time.Minute), failing[:1], ok))
+	assert.Empty(t, tracker.update(start.Add(4*time.Minute), failing, ok))
+	found = tracker.update(start.Add(31*time.Minute), failing, ok)
+	assert.Len(t, found, 2, "emitted again after the interval")
+}
+
+func TestFindingTrackerLimit(t *testing.T) {
+	tracker := newFindingTracker(1, 30*time.Minute)
+	start := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	var failing []failingEdge
+	for i := 0; i < maxFindingEventsPerPoll+5; i++ {
+		failing = append(failing, failingEdge{JobID: "tcp-n2n", SrcHost: "node-a", DestHost: fmt.Sprintf("node-%02d", i)})
+	}
+	assert.Len(t, tracker.update(start, failing, nil), maxFindingEventsPerPoll)
+	assert.Len(t, tracker.update(start.Add(1*time.Minute), failing, nil), 5, "remaining findings in the next cycle")
+}
+
+func TestEmitFindings(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	nodes := []*corev1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-a", UID: "uid-a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-b", UID: "uid-b"}},
+	}
+	since := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	findings := []finding{
+		{Reason: reasonLinkAsymmetric, Edge: failingEdge{JobID: "tcp-n2n", SrcHost: "node-a", DestHost: "node-b"}, Polls: 3, Since: since},
+		{Reason: reasonEdgeFailing, Edge: failingEdge{JobID: "tcp-n2api-ext", SrcHost: "node-a", DestHost: "api"}, Polls: 3, Since: since},
+	}
+	assert.Equal(t, 3, emitFindings(recorder, findings, nodes), "one event per affected node")
+	close(recorder.Events)
+	var events []string
+	for event := range recorder.Events {
+		events = append(events, event)
+	}
+	assert.Equal(t, []string{
+		"Warning NetworkLinkAsymmetric " + findings[0].Message(),
+		"Warning NetworkLinkAsymmetric " + findings[0].Message(),
+		"Warning NetworkEdgeFailing " + findings[1].Message(),
+	}, events)
+}
@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxListedStalePods limits the number of pods listed in the log message on diverging generations.
+const maxListedStalePods = 10
+
+// generationTracker detects agents running different generations of the agent config for too long,
+// e.g. after a partial rollout of the config map.
+type generationTracker struct {
+	threshold      time.Duration
+	divergentSince time.Time
+	reported       bool
+}
+
+// generationReport is the result of an update of the generation tracker.
+type generationReport struct {
+	// Counts is the number of agents per generation.
+	Counts map[string]int
+	// Divergence is the duration the agents run different generations, 0 if consistent.
+	Divergence time.Duration
+	// Exceeded is true if the divergence exceeded the threshold with this update.
+	Exceeded bool
+	// Converged is true if the agents run the same generation again after the threshold was exceeded.
+	Converged bool
+	// Stale are the sorted pods not running the most common generation.
+	Stale []string
+}
+
+// update processes the config generations of the polled agents by pod name.
+func (t *generationTracker) update(now time.Time, generations map[string]string) *generationReport {
+	report := &generationReport{Counts: map[string]int{}}
+	for _, generation := range generations {
+		report.Counts[generation]++
+	}
+	if len(report.Counts) <= 1 {
+		report.Converged = t.reported
+		t.divergentSince = time.Time{}
+		t.reported = false
+		return report
+	}
+
+	if t.divergentSince.IsZero() {
+		t.divergentSince = now
+	}
+	report.Divergence = now.Sub(t.divergentSince)
+	if !t.reported && report.Divergence >= t.threshold {
+		report.Exceeded = true
+		t.reported = true
+	}
+	common := report.mostCommon()
+	for pod, generation := range generations {
+		if generation != common {
+			report.Stale = append(report.Stale, pod)
+		}
+	}
+	sort.Strings(report.Stale)
+	return report
+}
+
+// mostCommon returns the generation used by most agents, on a tie the smallest one.
+func (r *generationReport) mostCommon() string {
+	result, maxCount := "", 0
+	for generation, count := range r.Counts {
+		if count > maxCount || count == maxCount && generation < result {
+			result, maxCount = generation, count
+		}
+	}
+	return result
+}
+
+func (r *generationReport) String() string {
+	var generations []string
+	for generation, count := range r.Counts {
+		generations = append(generations, fmt.Sprintf("%s=%d", generation, count))
+	}
+	sort.Strings(generations)
+	stale := r.Stale
+	more := ""
+	if len(stale) > maxListedStalePods {
+		more = fmt.Sprintf(" and %d more", len(stale)-maxListedStalePods)
+		stale = stale[:maxListedStalePods]
+	}
+	return fmt.Sprintf("agents per config generation: %s, pods with other generations: %s%s",
+		strings.Join(generations, ","), strings.Join(stale, ","), more)
+}
@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerationTracker(t *testing.T) {
+	tracker := &generationTracker{threshold: 10 * time.Minute}
+	start := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+
+	report := tracker.update(start, map[string]string{"pod-a": "aaa", "pod-b": "aaa"})
+	assert.Equal(t, map[string]int{"aaa": 2}, report.Counts)
+	assert.Zero(t, report.Divergence)
+	assert.False(t, report.Exceeded || report.Converged)
+
+	rollout := map[string]string{"pod-a": "bbb", "pod-b": "aaa", "pod-c": "bbb"}
+	report = tracker.update(start.Add(1*time.Minute), rollout)
+	assert.Zero(t, report.Divergence)
+	assert.False(t, report.Exceeded)
+	assert.Equal(t, []string{"pod-b"}, report.Stale)
+
+	report = tracker.update(start.Add(11*time.Minute), rollout)
+	assert.Equal(t, 10*time.Minute, report.Divergence)
+	assert.True(t, report.Exceeded)
+	assert.Equal(t, "agents per config generation: aaa=1,bbb=2, pods with other generations: pod-b", report.String())
+
+	report = tracker.update(start.Add(12*time.Minute), rollout)
+	assert.False(t, report.Exceeded, "only reported once")
+
+	report = tracker.update(start.Add(13*time.Minute), map[string]string{"pod-a": "bbb", "pod-b": "bbb", "pod-c": "bbb"})
+	assert.True(t, report.Converged)
+	assert.Zero(t, report.Divergence)
+
+	report = tracker.update(start.Add(14*time.Minute), map[string]string{"pod-a": "bbb"})
+	assert.False(t, report.Converged)
+}
@@ -0,0 +1,250 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/deploy"
+)
+
+func init() {
+	prometheus.MustRegister(PolledAgents)
+	prometheus.MustRegister(PollDuration)
+	prometheus.MustRegister(PollTimeouts)
+	prometheus.MustRegister(StaleEndpoints)
+	prometheus.MustRegister(EndpointListFetches)
+	prometheus.MustRegister(NodeMTU)
+	prometheus.MustRegister(MTUOutliers)
+	prometheus.MustRegister(ConfigGenerations)
+	prometheus.MustRegister(ConfigDivergence)
+	prometheus.MustRegister(RelayChecks)
+	prometheus.MustRegister(TriangulatedEdges)
+	prometheus.MustRegister(AgentConfigValid)
+	prometheus.MustRegister(NetworkChecks)
+	prometheus.MustRegister(FindingEvents)
+	prometheus.MustRegister(ClusterConfigSize)
+	prometheus.MustRegister(ClusterConfigSizeLimit)
+	prometheus.MustRegister(ClusterConfigTrimmed)
+	prometheus.MustRegister(ClusterConfigShards)
+}
+
+var (
+	PolledAgents = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_controller_polled_agents",
+			Help: "Number of agents in the last polling cycle by result",
+		},
+		[]string{"result"},
+	)
+	PollDuration = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nwpd_controller_poll_duration_seconds",
+			Help: "Duration of the last polling cycle over all agents in seconds",
+		},
+	)
+	PollTimeouts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_controller_poll_timeouts_total",
+			Help: "Total counts of timed out agent polls",
+		},
+		[]string{"pod"},
+	)
+	StaleEndpoints = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_controller_stale_endpoints",
+			Help: "Number of ready endpoints of endpoint slices referencing non-ready or non-existent pods",
+		},
+		[]string{"namespace", "service", "reason"},
+	)
+	NodeMTU = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_controller_node_mtu_bytes",
+			Help: "MTU of the network interface of the agent per node and network (host or pod) reported in the last polling cycle",
+		},
+		[]string{"node", "network"},
+	)
+	MTUOutliers = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_controller_mtu_outlier_nodes",
+			Help: "Number of nodes whose MTU differs from the MTU of the majority of nodes per network (host or pod)",
+		},
+		[]string{"network"},
+	)
+	ConfigGenerations = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_controller_config_generation_agents",
+			Help: "Number of polled agents per generation of the agent config in the last polling cycle",
+		},
+		[]string{"hash"},
+	)
+	ConfigDivergence = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nwpd_controller_config_divergence_seconds",
+			Help: "Duration the polled agents run different generations of the agent config, 0 if consistent",
+		},
+	)
+	RelayChecks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_controller_relay_checks_total",
+			Help: "Total counts of relayed checks requested to triangulate failing edges by result",
+		},
+		[]string{"result"},
+	)
+	TriangulatedEdges = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_controller_triangulated_edges",
+			Help: "Number of failing edges triangulated in the last polling cycle by verdict",
+		},
+		[]string{"verdict"},
+	)
+	AgentConfigValid = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nwpd_controller_agent_config_valid",
+			Help: "1 if the agent config map passed the last validation, 0 if it is invalid",
+		},
+	)
+	NetworkChecks = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nwpd_controller_network_checks",
+			Help: "Number of custom resources NetworkCheck by phase in the last reconciliation",
+		},
+		[]string{"phase"},
+	)
+	FindingEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_controller_finding_events_total",
+			Help: "Total counts of Kubernetes events emitted on nodes for findings of the polled agents by reason",
+		},
+		[]string{"reason"},
+	)
+	ClusterConfigSize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nwpd_controller_cluster_config_size_bytes",
+			Help: "Stored size of the complete cluster config in bytes (compressed if above the compress threshold) before trimming or sharding",
+		},
+	)
+	ClusterConfigSizeLimit = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nwpd_controller_cluster_config_size_limit_bytes",
+			Help: "Maximum size of the cluster config stored in a config map in bytes",
+		},
+	)
+	ClusterConfigTrimmed = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nwpd_controller_cluster_config_trimmed",
+			Help: "1 if the optional metadata of the nodes has been dropped from the cluster config to stay below the size limit, otherwise 0",
+		},
+	)
+	ClusterConfigShards = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nwpd_controller_cluster_config_shards",
+			Help: "Number of shard config maps of the cluster config, 0 if it is not sharded",
+		},
+	)
+	EndpointListFetches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nwpd_controller_endpoint_list_fetches_total",
+			Help: "Total counts of fetches of the endpoint list from the URL by result",
+		},
+		[]string{"result"},
+	)
+)
+
+func reportPollResult(result *pollResult, agents int, duration time.Duration) {
+	PolledAgents.WithLabelValues("ok").Set(float64(len(result.Responses)))
+	PolledAgents.WithLabelValues("failed").Set(float64(len(result.Failed)))
+	PolledAgents.WithLabelValues("timeout").Set(float64(len(result.TimedOut)))
+	PolledAgents.WithLabelValues("total").Set(float64(agents))
+	PollDuration.Set(duration.Seconds())
+	for _, podname := range result.TimedOut {
+		PollTimeouts.WithLabelValues(podname).Inc()
+	}
+}
+
+func reportStaleEndpoints(endpoints []staleEndpoint) {
+	StaleEndpoints.Reset()
+	for _, endpoint := range endpoints {
+		StaleEndpoints.WithLabelValues(endpoint.Namespace, endpoint.Service, string(endpoint.Reason)).Inc()
+	}
+}
+
+func reportEndpointListFetch(ok bool) {
+	result := "ok"
+	if !ok {
+		result = "failed"
+	}
+	EndpointListFetches.WithLabelValues(result).Inc()
+}
+
+func reportNodeMTUs(mtus []nodeMTU, findings []mtuFinding) {
+	NodeMTU.Reset()
+	for _, m := range mtus {
+		NodeMTU.WithLabelValues(m.Node, m.Network).Set(float64(m.MTU))
+	}
+	MTUOutliers.WithLabelValues(networkHost).Set(0)
+	MTUOutliers.WithLabelValues(networkPod).Set(0)
+	for _, f := range findings {
+		MTUOutliers.WithLabelValues(f.Network).Set(float64(len(f.Outliers)))
+	}
+}
+
+func reportConfigGenerations(report *generationReport) {
+	ConfigGenerations.Reset()
+	for generation, count := range report.Counts {
+		ConfigGenerations.WithLabelValues(generation).Set(float64(count))
+	}
+	ConfigDivergence.Set(report.Divergence.Seconds())
+}
+
+func reportRelayCheck(result string) {
+	RelayChecks.WithLabelValues(result).Inc()
+}
+
+func reportTriangulations(triangulations []*triangulation) {
+	counts := map[relayVerdict]int{}
+	for _, t := range triangulations {
+		counts[t.Verdict]++
+	}
+	for _, verdict := range []relayVerdict{verdictDestination, verdictSource, verdictInconclusive, verdictUnknown} {
+		TriangulatedEdges.WithLabelValues(string(verdict)).Set(float64(counts[verdict]))
+	}
+}
+
+func reportAgentConfigValidation(valid bool) {
+	if valid {
+		AgentConfigValid.Set(1)
+	} else {
+		AgentConfigValid.Set(0)
+	}
+}
+
+func reportNetworkChecks(statuses map[string]config.NetworkCheckStatus) {
+	counts := map[config.NetworkCheckPhase]int{}
+	for _, status := range statuses {
+		counts[status.Phase]++
+	}
+	for _, phase := range []config.NetworkCheckPhase{config.NetworkCheckApplied, config.NetworkCheckInvalid} {
+		NetworkChecks.WithLabelValues(string(phase)).Set(float64(counts[phase]))
+	}
+}
+
+func reportFindingEvent(reason string) {
+	FindingEvents.WithLabelValues(reason).Inc()
+}
+
+func reportClusterConfigLayout(layout *deploy.ClusterConfigLayout) {
+	ClusterConfigSize.Set(float64(layout.FullSize))
+	ClusterConfigSizeLimit.Set(deploy.MaxClusterConfigMapDataSize)
+	if layout.Trimmed {
+		ClusterConfigTrimmed.Set(1)
+	} else {
+		ClusterConfigTrimmed.Set(0)
+	}
+	ClusterConfigShards.Set(float64(len(layout.Shards)))
+}
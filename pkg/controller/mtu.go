@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+const (
+	networkHost = "host"
+	networkPod  = "pod"
+)
+
+// nodeMTU is the MTU of the network interface reported by the agent of a node in the host or pod network.
+type nodeMTU struct {
+	Node    string
+	Network string
+	MTU     int
+}
+
+// mtuFinding contains the nodes of a network whose MTU differs from the MTU of the majority of the nodes.
+type mtuFinding struct {
+	Network  string
+	Expected int
+	Outliers []nodeMTU
+}
+
+func (f mtuFinding) String() string {
+	var nodes []string
+	for _, o := range f.Outliers {
+		nodes = append(nodes, fmt.Sprintf("%s=%d", o.Node, o.MTU))
+	}
+	return fmt.Sprintf("%s network: expected MTU %d, outliers %s", f.Network, f.Expected, strings.Join(nodes, ","))
+}
+
+// collectNodeMTUs returns the MTUs reported by the polled agents sorted by network and node.
+// Agents without known MTU (e.g. older versions) are ignored.
+func collectNodeMTUs(pods []*corev1.Pod, responses map[string]*nwpd.GetAggregatedObservationsResponse) []nodeMTU {
+	var result []nodeMTU
+	for _, pod := range pods {
+		resp := responses[pod.Name]
+		if resp == nil || resp.Mtu <= 0 || pod.Spec.NodeName == "" {
+			continue
+		}
+		var network string
+		switch pod.Labels[common.LabelKeyK8sApp] {
+		case common.NameDaemonSetAgentHostNet:
+			network = networkHost
+		case common.NameDaemonSetAgentPodNet:
+			network = networkPod
+		default:
+			continue
+		}
+		result = append(result, nodeMTU{Node: pod.Spec.NodeName, Network: network, MTU: int(resp.Mtu)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Network != result[j].Network {
+			return result[i].Network < result[j].Network
+		}
+		return result[i].Node < result[j].Node
+	})
+	return result
+}
+
+// findMTUOutliers compares the MTUs of the nodes per network. The expected MTU is the one used by most nodes,
+// on a tie the smaller one, as a too large MTU drops large packets while a too small one only costs throughput.
+func findMTUOutliers(mtus []nodeMTU) []mtuFinding {
+	counts := map[string]map[int]int{}
+	for _, m := range mtus {
+		if counts[m.Network] == nil {
+			counts[m.Network] = map[int]int{}
+		}
+		counts[m.Network][m.MTU]++
+	}
+	var findings []mtuFinding
+	for _, network := range []string{networkHost, networkPod} {
+		if len(counts[network]) < 2 {
+			continue
+		}
+		expected, maxCount := 0, 0
+		for mtu, count := range counts[network] {
+			if count > maxCount || count == maxCount && mtu < expected {
+				expected, maxCount = mtu, count
+			}
+		}
+		finding := mtuFinding{Network: network, Expected: expected}
+		for _, m := range mtus {
+			if m.Network == network && m.MTU != expected {
+				finding.Outliers = append(finding.Outliers, m)
+			}
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
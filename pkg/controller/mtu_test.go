@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestCollectNodeMTUs(t *testing.T) {
+	pod := func(name, app, node string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{common.LabelKeyK8sApp: app}},
+			Spec:       corev1.PodSpec{NodeName: node},
+		}
+	}
+	pods := []*corev1.Pod{
+		pod("pod-b", common.NameDaemonSetAgentPodNet, "node-b"),
+		pod("pod-a", common.NameDaemonSetAgentPodNet, "node-a"),
+		pod("host-a", common.NameDaemonSetAgentHostNet, "node-a"),
+		pod("host-b", common.NameDaemonSetAgentHostNet, "node-b"),
+		pod("pod-c", common.NameDaemonSetAgentPodNet, "node-c"),
+	}
+	responses := map[string]*nwpd.GetAggregatedObservationsResponse{
+		"pod-a":  {Mtu: 1400},
+		"pod-b":  {Mtu: 1450},
+		"host-a": {Mtu: 1500},
+		"host-b": {},
+	}
+	assert.Equal(t, []nodeMTU{
+		{Node: "node-a", Network: networkHost, MTU: 1500},
+		{Node: "node-a", Network: networkPod, MTU: 1400},
+		{Node: "node-b", Network: networkPod, MTU: 1450},
+	}, collectNodeMTUs(pods, responses))
+}
+
+func TestFindMTUOutliers(t *testing.T) {
+	mtus := []nodeMTU{
+		{Node: "node-a", Network: networkHost, MTU: 1500},
+		{Node: "node-b", Network: networkHost, MTU: 1500},
+		{Node: "node-a", Network: networkPod, MTU: 1400},
+		{Node: "node-b", Network: networkPod, MTU: 1500},
+		{Node: "node-c", Network: networkPod, MTU: 1400},
+	}
+	findings := findMTUOutliers(mtus)
+	if assert.Len(t, findings, 1) {
+		assert.Equal(t, mtuFinding{Network: networkPod, Expected: 1400, Outliers: []nodeMTU{{Node: "node-b", Network: networkPod, MTU: 1500}}}, findings[0])
+		assert.Equal(t, "pod network: expected MTU 1400, outliers node-b=1500", findings[0].String())
+	}
+
+	// on a tie, the smaller MTU is expected
+	findings = findMTUOutliers(mtus[:4])
+	if assert.Len(t, findings, 1) {
+		assert.Equal(t, 1400, findings[0].Expected)
+	}
+	assert.Empty(t, findMTUOutliers(mtus[:2]))
+}
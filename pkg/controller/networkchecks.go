@@ -0,0 +1,362 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+// networkCheckResource is the resource of the custom resource NetworkCheck.
+var networkCheckResource = schema.GroupVersionResource{
+	Group:    config.NetworkCheckGroup,
+	Version:  config.NetworkCheckVersion,
+	Resource: config.NetworkCheckResource,
+}
+
+// networkCheckClient lists the network checks of all namespaces and updates their status.
+type networkCheckClient interface {
+	List(ctx context.Context) ([]config.NetworkCheck, error)
+	UpdateStatus(ctx context.Context, check *config.NetworkCheck) error
+}
+
+type dynamicNetworkCheckClient struct {
+	client dynamic.NamespaceableResourceInterface
+}
+
+var _ networkCheckClient = &dynamicNetworkCheckClient{}
+
+func (c *dynamicNetworkCheckClient) List(ctx context.Context) ([]config.NetworkCheck, error) {
+	list, err := c.client.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	checks := make([]config.NetworkCheck, len(list.Items))
+	for i, item := range list.Items {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &checks[i]); err != nil {
+			return nil, fmt.Errorf("converting network check %s/%s failed: %w", item.GetNamespace(), item.GetName(), err)
+		}
+	}
+	return checks, nil
+}
+
+func (c *dynamicNetworkCheckClient) UpdateStatus(ctx context.Context, check *config.NetworkCheck) error {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(check)
+	if err != nil {
+		return err
+	}
+	_, err = c.client.Namespace(check.Namespace).UpdateStatus(ctx, &unstructured.Unstructured{Object: obj}, metav1.UpdateOptions{})
+	return err
+}
+
+// networkCheckReconciler reconciles the network checks into the agent config map. The jobs of the network checks are
+// added to the jobs of the config map, which may still be edited directly. The IDs of the added jobs are stored in an
+// annotation of the config map, so that they can be replaced in the next reconciliation without touching the other jobs.
+type networkCheckReconciler struct {
+	checks     networkCheckClient
+	configMaps typedcorev1.ConfigMapInterface
+}
+
+func newNetworkCheckReconciler(checks networkCheckClient, configMaps typedcorev1.ConfigMapInterface) *networkCheckReconciler {
+	return &networkCheckReconciler{checks: checks, configMaps: configMaps}
+}
+
+// networkCheckJobKey is the key of a job added for a network check in the annotation of the agent config map.
+func networkCheckJobKey(network config.CheckNetwork, jobID string) string {
+	return fmt.Sprintf("%s/%s", network, jobID)
+}
+
+// mergeNetworkChecks replaces the jobs added for network checks in the agent config by the jobs of the given checks and
+// returns the status of the checks by namespace/name and the keys of the added jobs.
+// Checks which are invalid or conflict with other jobs are skipped. For conflicting job IDs, the first check in the order
+// of namespace and name wins.
+func mergeNetworkChecks(cfg *config.AgentConfig, ownedKeys []string, checks []config.NetworkCheck) (map[string]config.NetworkCheckStatus, []string) {
+	owned := map[string]bool{}
+	for _, key := range ownedKeys {
+		owned[key] = true
+	}
+	networks := map[config.CheckNetwork]*config.NetworkConfig{
+		config.CheckNetworkHost: cfg.HostNetwork,
+		config.CheckNetworkPod:  cfg.PodNetwork,
+	}
+	for network, networkCfg := range networks {
+		if networkCfg == nil {
+			continue
+		}
+		var jobs []config.Job
+		for _, job := range networkCfg.Jobs {
+			if !owned[networkCheckJobKey(network, job.JobID)] {
+				jobs = append(jobs, job)
+			}
+		}
+		networkCfg.Jobs = jobs
+	}
+
+	sort.Slice(checks, func(i, j int) bool {
+		if checks[i].Namespace != checks[j].Namespace {
+			return checks[i].Namespace < checks[j].Namespace
+		}
+		return checks[i].Name < checks[j].Name
+	})
+	statuses := map[string]config.NetworkCheckStatus{}
+	invalid := func(check *config.NetworkCheck, format string, args ...any) {
+		statuses[check.Namespace+"/"+check.Name] = config.NetworkCheckStatus{
+			ObservedGeneration: check.Generation,
+			Phase:              config.NetworkCheckInvalid,
+			Message:            fmt.Sprintf(format, args...),
+			JobID:              check.JobIDOrDefault(),
+		}
+	}
+	jobIDs := func(network config.CheckNetwork) map[string]bool {
+		ids := map[string]bool{}
+		for _, job := range networks[network].Jobs {
+			ids[job.JobID] = true
+		}
+		return ids
+	}
+
+	var added []*config.NetworkCheck
+	for i := range checks {
+		check := &checks[i]
+		job := check.Job()
+		if err := check.Validate(); err != nil {
+			invalid(check, "%s", err)
+			continue
+		}
+		if err := runners.ValidateJobArgs(job); err != nil {
+			invalid(check, "invalid args: %s", err)
+			continue
+		}
+		var conflict error
+		for _, network := range check.NetworksOrDefault() {
+			if networks[network] == nil {
+				conflict = fmt.Errorf("network %s is not configured in the agent config", network)
+				break
+			}
+			if jobIDs(network)[job.JobID] {
+				conflict = fmt.Errorf("job ID %s already used on network %s", job.JobID, network)
+				break
+			}
+		}
+		if conflict != nil {
+			invalid(check, "%s", conflict)
+			continue
+		}
+		for _, network := range check.NetworksOrDefault() {
+			networks[network].Jobs = append(networks[network].Jobs, job)
+		}
+		added = append(added, check)
+	}
+
+	// triggers may reference jobs of the config map or of other checks, so they are checked after all checks have been added.
+	// Removing an invalid check may break the triggers of other checks, so the checks are validated until none is removed.
+	reject := func(check *config.NetworkCheck, err error) {
+		invalid(check, "%s", err)
+		removeJob(networks, check.NetworksOrDefault(), check.JobIDOrDefault())
+	}
+	for removed := true; removed; {
+		removed = false
+		var valid []*config.NetworkCheck
+		for _, check := range added {
+			if trigger := check.Spec.TriggeredBy; trigger != nil {
+				if network := missingTrigger(networks, check.NetworksOrDefault(), trigger.JobID); network != "" {
+					reject(check, fmt.Errorf("unknown trigger job ID %s on network %s", trigger.JobID, network))
+					removed = true
+					continue
+				}
+			}
+			valid = append(valid, check)
+		}
+		added = valid
+		if removed {
+			continue
+		}
+		// all triggers exist, so remaining errors are cyclic triggers, which are rejected for all checks with triggers
+		for _, network := range []config.CheckNetwork{config.CheckNetworkHost, config.CheckNetworkPod} {
+			if networks[network] == nil {
+				continue
+			}
+			if err := config.ValidateJobs(networks[network].Jobs); err != nil {
+				valid = nil
+				for _, check := range added {
+					if check.Spec.TriggeredBy != nil {
+						reject(check, err)
+						removed = true
+						continue
+					}
+					valid = append(valid, check)
+				}
+				added = valid
+				break
+			}
+		}
+	}
+
+	var keys []string
+	for _, check := range added {
+		jobID := check.JobIDOrDefault()
+		statuses[check.Namespace+"/"+check.Name] = config.NetworkCheckStatus{
+			ObservedGeneration: check.Generation,
+			Phase:              config.NetworkCheckApplied,
+			JobID:              jobID,
+		}
+		for _, network := range check.NetworksOrDefault() {
+			keys = append(keys, networkCheckJobKey(network, jobID))
+		}
+	}
+	sort.Strings(keys)
+	return statuses, keys
+}
+
+// missingTrigger returns the first network without the trigger job or an empty string if all networks have the job.
+func missingTrigger(networks map[config.CheckNetwork]*config.NetworkConfig, checkNetworks []config.CheckNetwork, triggerJobID string) config.CheckNetwork {
+	for _, network := range checkNetworks {
+		found := false
+		for _, job := range networks[network].Jobs {
+			if job.JobID == triggerJobID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return network
+		}
+	}
+	return ""
+}
+
+func removeJob(networks map[config.CheckNetwork]*config.NetworkConfig, checkNetworks []config.CheckNetwork, jobID string) {
+	for _, network := range checkNetworks {
+		var jobs []config.Job
+		for _, job := range networks[network].Jobs {
+			if job.JobID != jobID {
+				jobs = append(jobs, job)
+			}
+		}
+		networks[network].Jobs = jobs
+	}
+}
+
+// reconcile merges the network checks into the agent config map and updates the status of the checks.
+func (r *networkCheckReconciler) reconcile(ctx context.Context, log logrus.FieldLogger) error {
+	checks, err := r.checks.List(ctx)
+	if err != nil {
+		return fmt.Errorf("listing network checks failed: %w", err)
+	}
+	statuses, err := r.updateAgentConfig(ctx, log, checks)
+	if err != nil {
+		for i := range checks {
+			statuses[checks[i].Namespace+"/"+checks[i].Name] = config.NetworkCheckStatus{
+				ObservedGeneration: checks[i].Generation,
+				Phase:              config.NetworkCheckInvalid,
+				Message:            err.Error(),
+				JobID:              checks[i].JobIDOrDefault(),
+			}
+		}
+	}
+	reportNetworkChecks(statuses)
+	for i := range checks {
+		check := &checks[i]
+		status := statuses[check.Namespace+"/"+check.Name]
+		if reflect.DeepEqual(check.Status, status) {
+			continue
+		}
+		check.Status = status
+		if status.Phase == config.NetworkCheckInvalid {
+			log.Warnf("network check %s/%s is invalid: %s", check.Namespace, check.Name, status.Message)
+		}
+		if err := r.checks.UpdateStatus(ctx, check); err != nil {
+			log.Warnf("updating status of network check %s/%s failed: %s", check.Namespace, check.Name, err)
+		}
+	}
+	return err
+}
+
+// updateAgentConfig merges the network checks into the agent config map and updates it if it has changed.
+// Agent config maps deployed with '--immutable-config' cannot be updated.
+func (r *networkCheckReconciler) updateAgentConfig(ctx context.Context, log logrus.FieldLogger, checks []config.NetworkCheck) (map[string]config.NetworkCheckStatus, error) {
+	statuses := map[string]config.NetworkCheckStatus{}
+	cm, err := r.configMaps.Get(ctx, common.NameAgentConfigMap, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return statuses, fmt.Errorf("agent config map %s/%s not found (immutable config maps are not supported)", common.NamespaceKubeSystem, common.NameAgentConfigMap)
+		}
+		return statuses, err
+	}
+	content := cm.Data[common.AgentConfigFilename]
+	cfg := &config.AgentConfig{}
+	if err := yaml.Unmarshal([]byte(content), cfg); err != nil {
+		return statuses, fmt.Errorf("unmarshalling %s failed: %w", common.AgentConfigFilename, err)
+	}
+	var ownedKeys []string
+	if annotation := cm.Annotations[common.AnnotationNetworkCheckJobs]; annotation != "" {
+		ownedKeys = strings.Split(annotation, ",")
+	}
+	statuses, keys := mergeNetworkChecks(cfg, ownedKeys, checks)
+	if len(keys) == 0 && len(ownedKeys) == 0 {
+		// leave the config map untouched if there are no jobs of network checks
+		return statuses, nil
+	}
+	if err := cfg.StampGeneration(); err != nil {
+		return statuses, err
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return statuses, err
+	}
+	annotation := strings.Join(keys, ",")
+	if string(data) == content && cm.Annotations[common.AnnotationNetworkCheckJobs] == annotation {
+		return statuses, nil
+	}
+	cm.Data[common.AgentConfigFilename] = string(data)
+	if annotation == "" {
+		delete(cm.Annotations, common.AnnotationNetworkCheckJobs)
+	} else {
+		if cm.Annotations == nil {
+			cm.Annotations = map[string]string{}
+		}
+		cm.Annotations[common.AnnotationNetworkCheckJobs] = annotation
+	}
+	if _, err := r.configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return statuses, fmt.Errorf("updating configmap %s/%s failed: %w", cm.Namespace, cm.Name, err)
+	}
+	log.Infof("updated configmap %s/%s with %d jobs of network checks", cm.Namespace, cm.Name, len(keys))
+	return statuses, nil
+}
+
+func (cc *controllerCommand) reconcileNetworkChecksLoop(log logrus.FieldLogger, client dynamic.Interface, stopCh <-chan struct{}) {
+	reconciler := newNetworkCheckReconciler(&dynamicNetworkCheckClient{client: client.Resource(networkCheckResource)},
+		cc.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem))
+	ticker := time.NewTicker(cc.networkChecksPeriod)
+	defer ticker.Stop()
+	for {
+		if err := reconciler.reconcile(context.Background(), log); err != nil {
+			log.Errorf("reconciling network checks failed: %s", err)
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+// fakeNetworkChecks stores the network checks and their status updates.
+type fakeNetworkChecks struct {
+	checks        []config.NetworkCheck
+	statusUpdates int
+}
+
+func (f *fakeNetworkChecks) List(_ context.Context) ([]config.NetworkCheck, error) {
+	return append([]config.NetworkCheck{}, f.checks...), nil
+}
+
+func (f *fakeNetworkChecks) UpdateStatus(_ context.Context, check *config.NetworkCheck) error {
+	f.statusUpdates++
+	for i := range f.checks {
+		if f.checks[i].Namespace == check.Namespace && f.checks[i].Name == check.Name {
+			f.checks[i].Status = check.Status
+		}
+	}
+	return nil
+}
+
+func (f *fakeNetworkChecks) status(namespace, name string) config.NetworkCheckStatus {
+	for _, check := range f.checks {
+		if check.Namespace == namespace && check.Name == name {
+			return check.Status
+		}
+	}
+	return config.NetworkCheckStatus{}
+}
+
+func networkCheck(namespace, name string, spec config.NetworkCheckSpec) config.NetworkCheck {
+	return config.NetworkCheck{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Generation: 1},
+		Spec:       spec,
+	}
+}
+
+func TestNetworkCheckReconciler(t *testing.T) {
+	configMaps := &fakeConfigMaps{cm: &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: common.NameAgentConfigMap, Namespace: common.NamespaceKubeSystem},
+		Data: map[string]string{common.AgentConfigFilename: `hostNetwork:
+  jobs:
+  - jobID: tcp-n2n
+    args: [checkTCPPort, --endpoints-of-pod-ds]
+podNetwork:
+  jobs:
+  - jobID: tcp-p2n
+    args: [checkTCPPort, --node-port, "1234"]
+`},
+	}}
+	checks := &fakeNetworkChecks{checks: []config.NetworkCheck{
+		networkCheck("team-a", "db", config.NetworkCheckSpec{
+			Networks: []config.CheckNetwork{config.CheckNetworkHost, config.CheckNetworkPod},
+			Args:     []string{"checkTCPPort", "--endpoints", "db:10.0.0.1:5432"},
+			Labels:   map[string]string{"team": "a"},
+		}),
+		networkCheck("team-a", "db-ping", config.NetworkCheckSpec{
+			Args:        []string{"pingHost", "--hosts", "db:10.0.0.1"},
+			TriggeredBy: &config.JobTrigger{JobID: "team-a.db", Condition: config.TriggerOnFailure},
+		}),
+		networkCheck("team-b", "conflict", config.NetworkCheckSpec{
+			JobID: "tcp-p2n",
+			Args:  []string{"checkTCPPort", "--endpoints", "db:10.0.0.1:5432"},
+		}),
+		networkCheck("team-b", "bad-args", config.NetworkCheckSpec{
+			Args: []string{"checkTCPPort", "--unknown"},
+		}),
+		networkCheck("team-b", "unknown-trigger", config.NetworkCheckSpec{
+			Args:        []string{"pingHost", "--hosts", "db:10.0.0.1"},
+			TriggeredBy: &config.JobTrigger{JobID: "team-b.bad-args", Condition: config.TriggerOnFailure},
+		}),
+	}}
+	reconciler := newNetworkCheckReconciler(checks, configMaps)
+	log := logrus.New()
+
+	assert.Nil(t, reconciler.reconcile(context.Background(), log))
+	assert.Equal(t, 1, configMaps.updates)
+	assert.Equal(t, "host/team-a.db,pod/team-a.db,pod/team-a.db-ping", configMaps.cm.Annotations[common.AnnotationNetworkCheckJobs])
+	cfg := &config.AgentConfig{}
+	assert.Nil(t, yaml.Unmarshal([]byte(configMaps.cm.Data[common.AgentConfigFilename]), cfg))
+	assert.NotEmpty(t, cfg.Generation)
+	jobIDs := func(jobs []config.Job) []string {
+		var ids []string
+		for _, job := range jobs {
+			ids = append(ids, job.JobID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"tcp-n2n", "team-a.db"}, jobIDs(cfg.HostNetwork.Jobs))
+	assert.Equal(t, []string{"tcp-p2n", "team-a.db", "team-a.db-ping"}, jobIDs(cfg.PodNetwork.Jobs))
+
+	assert.Equal(t, config.NetworkCheckStatus{ObservedGeneration: 1, Phase: config.NetworkCheckApplied, JobID: "team-a.db"}, checks.status("team-a", "db"))
+	assert.Equal(t, config.NetworkCheckApplied, checks.status("team-a", "db-ping").Phase)
+	assert.Equal(t, config.NetworkCheckInvalid, checks.status("team-b", "conflict").Phase)
+	assert.Equal(t, "job ID tcp-p2n already used on network pod", checks.status("team-b", "conflict").Message)
+	assert.Equal(t, config.NetworkCheckInvalid, checks.status("team-b", "bad-args").Phase)
+	assert.Contains(t, checks.status("team-b", "bad-args").Message, "invalid args")
+	assert.Equal(t, "unknown trigger job ID team-b.bad-args on network pod", checks.status("team-b", "unknown-trigger").Message)
+	assert.Equal(t, 5, checks.statusUpdates)
+
+	// unchanged checks neither update the config map nor the status
+	assert.Nil(t, reconciler.reconcile(context.Background(), log))
+	assert.Equal(t, 1, configMaps.updates)
+	assert.Equal(t, 5, checks.statusUpdates)
+
+	// removing the trigger job invalidates the triggered check, jobs edited in the config map are kept
+	cm := configMaps.cm
+	cfg.PodNetwork.Jobs = append(cfg.PodNetwork.Jobs, config.Job{JobID: "manual", Args: []string{"nslookup", "--names", "eu.gcr.io."}})
+	data, err := yaml.Marshal(cfg)
+	assert.Nil(t, err)
+	cm.Data[common.AgentConfigFilename] = string(data)
+	checks.checks = checks.checks[1:]
+	assert.Nil(t, reconciler.reconcile(context.Background(), log))
+	assert.Equal(t, 2, configMaps.updates)
+	assert.Empty(t, configMaps.cm.Annotations[common.AnnotationNetworkCheckJobs])
+	cfg = &config.AgentConfig{}
+	assert.Nil(t, yaml.Unmarshal([]byte(configMaps.cm.Data[common.AgentConfigFilename]), cfg))
+	assert.Equal(t, []string{"tcp-n2n"}, jobIDs(cfg.HostNetwork.Jobs))
+	assert.Equal(t, []string{"tcp-p2n", "manual"}, jobIDs(cfg.PodNetwork.Jobs))
+	assert.Equal(t, "unknown trigger job ID team-a.db on network pod", checks.status("team-a", "db-ping").Message)
+}
+
+func TestNetworkCheckReconcilerWithoutConfigMap(t *testing.T) {
+	checks := &fakeNetworkChecks{checks: []config.NetworkCheck{
+		networkCheck("team-a", "db", config.NetworkCheckSpec{Args: []string{"checkTCPPort", "--endpoints", "db:10.0.0.1:5432"}}),
+	}}
+	reconciler := newNetworkCheckReconciler(checks, &fakeConfigMaps{})
+
+	assert.NotNil(t, reconciler.reconcile(context.Background(), logrus.New()))
+	assert.Equal(t, config.NetworkCheckInvalid, checks.status("team-a", "db").Phase)
+	assert.Contains(t, checks.status("team-a", "db").Message, "not found")
+}
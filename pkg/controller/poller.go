@@ -0,0 +1,286 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// agentAddress is the GRPC endpoint of an agent pod.
+type agentAddress struct {
+	Podname   string
+	Address   string
+	Nodename  string
+	DaemonSet string
+}
+
+// agentAddresses returns the GRPC endpoints of the running agent pods of both daemon sets.
+func agentAddresses(pods []*corev1.Pod) []agentAddress {
+	var agents []agentAddress
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+			continue
+		}
+		var port int
+		daemonSet := pod.Labels[common.LabelKeyK8sApp]
+		switch daemonSet {
+		case common.NameDaemonSetAgentPodNet:
+			port = common.PodNetPodGRPCPort
+		case common.NameDaemonSetAgentHostNet:
+			port = common.HostNetPodGRPCPort
+		default:
+			continue
+		}
+		if p := grpcContainerPort(pod); p != 0 {
+			port = p
+		}
+		agents = append(agents, agentAddress{
+			Podname:   pod.Name,
+			Address:   net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(port)),
+			Nodename:  pod.Spec.NodeName,
+			DaemonSet: daemonSet,
+		})
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Podname < agents[j].Podname })
+	return agents
+}
+
+// grpcContainerPort returns the GRPC port from the pod spec, as the port of the host network agent is configurable.
+func grpcContainerPort(pod *corev1.Pod) int {
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == common.PortNameGRPC {
+				return int(p.ContainerPort)
+			}
+		}
+	}
+	return 0
+}
+
+// pollFunc fetches the aggregated observations from a single agent.
+type pollFunc func(ctx context.Context, agent agentAddress) (*nwpd.GetAggregatedObservationsResponse, error)
+
+// pollResult contains the results of a polling cycle over all agents.
+type pollResult struct {
+	Responses map[string]*nwpd.GetAggregatedObservationsResponse
+	Failed    map[string]error
+	// TimedOut contains the names of the agent pods which did not respond within the per-agent timeout.
+	TimedOut []string
+}
+
+// agentPoller polls the agents with a bounded number of concurrent requests.
+type agentPoller struct {
+	concurrency int
+	timeout     time.Duration
+	pollFunc    pollFunc
+}
+
+func newAgentPoller(concurrency int, timeout time.Duration, window time.Duration) *agentPoller {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &agentPoller{
+		concurrency: concurrency,
+		timeout:     timeout,
+		pollFunc:    grpcPollFunc(window),
+	}
+}
+
+// poll fetches the aggregated observations from all agents.
+// Each agent request is limited by the per-agent timeout, so that a slow agent does not stall the whole cycle.
+func (p *agentPoller) poll(ctx context.Context, agents []agentAddress) *pollResult {
+	result := &pollResult{
+		Responses: map[string]*nwpd.GetAggregatedObservationsResponse{},
+		Failed:    map[string]error{},
+	}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	queue := make(chan agentAddress)
+	for i := 0; i < p.concurrency && i < len(agents); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for agent := range queue {
+				resp, err := p.pollAgent(ctx, agent)
+				lock.Lock()
+				switch {
+				case err == nil:
+					result.Responses[agent.Podname] = resp
+				case isTimeout(err):
+					result.TimedOut = append(result.TimedOut, agent.Podname)
+				default:
+					result.Failed[agent.Podname] = err
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, agent := range agents {
+		queue <- agent
+	}
+	close(queue)
+	wg.Wait()
+	sort.Strings(result.TimedOut)
+	return result
+}
+
+func (p *agentPoller) pollAgent(ctx context.Context, agent agentAddress) (*nwpd.GetAggregatedObservationsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	return p.pollFunc(ctx, agent)
+}
+
+func isTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded
+}
+
+// grpcPollFunc returns a poll function requesting the aggregated observations of the given time window.
+func grpcPollFunc(window time.Duration) pollFunc {
+	return func(ctx context.Context, agent agentAddress) (*nwpd.GetAggregatedObservationsResponse, error) {
+		cc, err := grpc.DialContext(ctx, agent.Address, grpc.WithInsecure(), grpc.WithBlock())
+		if err != nil {
+			return nil, err
+		}
+		defer cc.Close()
+		client := nwpd.NewAgentServiceClient(cc)
+		now := time.Now()
+		return client.GetAggregatedObservations(ctx, &nwpd.GetObservationsRequest{
+			Start:             timestamppb.New(now.Add(-window)),
+			End:               timestamppb.New(now),
+			AggregationWindow: durationpb.New(window),
+		})
+	}
+}
+
+// agentGenerations returns the config generations of the polled agents by pod name.
+// Agents of older versions without generation are ignored.
+func agentGenerations(result *pollResult) map[string]string {
+	generations := map[string]string{}
+	for podname, resp := range result.Responses {
+		if resp.ConfigGeneration != "" {
+			generations[podname] = resp.ConfigGeneration
+		}
+	}
+	return generations
+}
+
+// pollLoop polls the agents periodically until the stop channel is closed.
+func (cc *controllerCommand) pollLoop(log logrus.FieldLogger, controller *nodePodController, stopCh <-chan struct{}) {
+	poller := newAgentPoller(cc.pollConcurrency, cc.pollTimeout, cc.pollPeriod)
+	ticker := time.NewTicker(cc.pollPeriod)
+	defer ticker.Stop()
+	var lastMTUFindings []string
+	generations := &generationTracker{threshold: cc.configDivergenceThreshold}
+	var relays *triangulator
+	if cc.triangulateRelays > 0 {
+		relays = newTriangulator(cc.triangulateRelays, cc.triangulateMaxEdges, cc.pollTimeout)
+	}
+	lastVerdicts := map[failingEdge]relayVerdict{}
+	var findingEvents *findingTracker
+	var recorder record.EventRecorder
+	if cc.findingEvents {
+		findingEvents = newFindingTracker(cc.findingEventsSustainedPolls, cc.findingEventsInterval)
+		recorder = newFindingEventRecorder(log, cc.Clientset)
+	}
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+		pods, err := controller.ListAllAgentPods()
+		if err != nil {
+			log.Errorf("listing agent pods failed: %s", err)
+			continue
+		}
+		agents := agentAddresses(pods)
+		start := time.Now()
+		result := poller.poll(context.Background(), agents)
+		reportPollResult(result, len(agents), time.Since(start))
+		for podname, err := range result.Failed {
+			log.Warnf("polling agent %s failed: %s", podname, err)
+		}
+		if len(result.TimedOut) > 0 {
+			log.Warnf("polling agents timed out after %s: %v", cc.pollTimeout, result.TimedOut)
+		}
+		log.Infof("polled %d of %d agents in %.1fs", len(result.Responses), len(agents), time.Since(start).Seconds())
+		cc.edgeMatrix.Store(buildEdgeMatrix(time.Now(), cc.pollPeriod, result.Responses))
+
+		mtus := collectNodeMTUs(pods, result.Responses)
+		findings := findMTUOutliers(mtus)
+		reportNodeMTUs(mtus, findings)
+		var mtuFindings []string
+		for _, f := range findings {
+			mtuFindings = append(mtuFindings, f.String())
+		}
+		if !reflect.DeepEqual(mtuFindings, lastMTUFindings) {
+			for _, f := range mtuFindings {
+				log.Warnf("inconsistent MTU: %s", f)
+			}
+			if len(mtuFindings) == 0 {
+				log.Infof("MTU consistent on all nodes")
+			}
+			lastMTUFindings = mtuFindings
+		}
+
+		report := generations.update(time.Now(), agentGenerations(result))
+		reportConfigGenerations(report)
+		if report.Exceeded {
+			log.Warnf("agents run different config generations for %s: %s", report.Divergence.Round(time.Second), report)
+		}
+		if report.Converged {
+			log.Infof("all polled agents run the same config generation again")
+		}
+
+		var edges []failingEdge
+		if relays != nil || findingEvents != nil {
+			edges = findFailingEdges(agents, result.Responses)
+		}
+		if findingEvents != nil {
+			if found := findingEvents.update(time.Now(), edges, findOkEdges(result.Responses)); len(found) > 0 {
+				nodes, err := controller.ListNodes()
+				if err != nil {
+					log.Errorf("listing nodes failed: %s", err)
+				}
+				count := emitFindings(recorder, found, nodes)
+				log.Infof("emitted %d events for %d findings", count, len(found))
+			}
+		}
+		if relays != nil {
+			if len(edges) > cc.triangulateMaxEdges {
+				log.Infof("triangulating %d of %d failing edges", cc.triangulateMaxEdges, len(edges))
+			}
+			triangulations := relays.triangulate(context.Background(), agents, edges)
+			reportTriangulations(triangulations)
+			verdicts := map[failingEdge]relayVerdict{}
+			for _, t := range triangulations {
+				verdicts[t.Edge] = t.Verdict
+				if lastVerdicts[t.Edge] != t.Verdict {
+					log.Warn(t.String())
+				}
+			}
+			lastVerdicts = verdicts
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestAgentAddresses(t *testing.T) {
+	pod := func(name, app, ip string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{common.LabelKeyK8sApp: app}},
+			Status:     corev1.PodStatus{Phase: phase, PodIP: ip},
+		}
+	}
+	agents := agentAddresses([]*corev1.Pod{
+		pod("pod-b", common.NameDaemonSetAgentPodNet, "100.96.0.2", corev1.PodRunning),
+		pod("host-a", common.NameDaemonSetAgentHostNet, "10.0.0.1", corev1.PodRunning),
+		pod("pod-c", common.NameDaemonSetAgentPodNet, "", corev1.PodPending),
+		pod("other", "other", "100.96.0.3", corev1.PodRunning),
+	})
+	exposed := pod("host-b", common.NameDaemonSetAgentHostNet, "10.0.0.2", corev1.PodRunning)
+	exposed.Spec.NodeName = "node-b"
+	exposed.Spec.Containers = []corev1.Container{{Ports: []corev1.ContainerPort{{Name: "grpc", ContainerPort: 4242, HostPort: 4242}}}}
+	agents = append(agents, agentAddresses([]*corev1.Pod{exposed})...)
+	assert.Equal(t, []agentAddress{
+		{Podname: "host-a", Address: fmt.Sprintf("10.0.0.1:%d", common.HostNetPodGRPCPort), DaemonSet: common.NameDaemonSetAgentHostNet},
+		{Podname: "pod-b", Address: fmt.Sprintf("100.96.0.2:%d", common.PodNetPodGRPCPort), DaemonSet: common.NameDaemonSetAgentPodNet},
+		{Podname: "host-b", Address: "10.0.0.2:4242", Nodename: "node-b", DaemonSet: common.NameDaemonSetAgentHostNet},
+	}, agents)
+}
+
+func TestPoll(t *testing.T) {
+	var agents []agentAddress
+	for i := 0; i < 20; i++ {
+		agents = append(agents, agentAddress{Podname: fmt.Sprintf("agent-%02d", i)})
+	}
+	var running, maxRunning atomic.Int32
+	poller := &agentPoller{
+		concurrency: 3,
+		timeout:     50 * time.Millisecond,
+		pollFunc: func(ctx context.Context, agent agentAddress) (*nwpd.GetAggregatedObservationsResponse, error) {
+			n := running.Inc()
+			defer running.Dec()
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CAS(m, n) {
+					break
+				}
+			}
+			switch agent.Podname {
+			case "agent-03", "agent-07":
+				<-ctx.Done()
+				return nil, ctx.Err()
+			case "agent-05":
+				return nil, fmt.Errorf("connection refused")
+			}
+			time.Sleep(time.Millisecond)
+			return &nwpd.GetAggregatedObservationsResponse{}, nil
+		},
+	}
+
+	start := time.Now()
+	result := poller.poll(context.Background(), agents)
+	assert.Less(t, time.Since(start), 1*time.Second)
+	assert.LessOrEqual(t, maxRunning.Load(), int32(3))
+	assert.Len(t, result.Responses, 17)
+	assert.Equal(t, []string{"agent-03", "agent-07"}, result.TimedOut)
+	if assert.Len(t, result.Failed, 1) {
+		assert.EqualError(t, result.Failed["agent-05"], "connection refused")
+	}
+}
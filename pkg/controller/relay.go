@@ -0,0 +1,349 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+// relayRequester identifies the controller as requester of relayed checks.
+const relayRequester = "controller"
+
+const (
+	// relayResultOK means the relayed check succeeded.
+	relayResultOK = "ok"
+	// relayResultFailed means the relayed check failed.
+	relayResultFailed = "failed"
+	// relayResultError means the relayed check could not be requested, e.g. because the agent is unreachable.
+	relayResultError = "error"
+)
+
+// relayVerdict is the result of the triangulation of a failing edge.
+type relayVerdict string
+
+const (
+	// verdictDestination means the destination is unreachable from the relay nodes, too.
+	verdictDestination relayVerdict = "destination"
+	// verdictSource means the destination is reachable from all relay nodes, so the source node or its path is broken.
+	verdictSource relayVerdict = "source"
+	// verdictInconclusive means the destination is reachable from some relay nodes only.
+	verdictInconclusive relayVerdict = "inconclusive"
+	// verdictUnknown means no relay node could check the destination.
+	verdictUnknown relayVerdict = "unknown"
+)
+
+// failingEdge is an edge without any successful check in the polled window.
+type failingEdge struct {
+	JobID    string
+	SrcHost  string
+	DestHost string
+	// DaemonSet is the daemon set of the reporting agent
+	DaemonSet string
+}
+
+func (e failingEdge) String() string {
+	return fmt.Sprintf("%s %s->%s", e.JobID, e.SrcHost, e.DestHost)
+}
+
+// triangulation is the result of the relayed checks of a failing edge.
+type triangulation struct {
+	Edge failingEdge
+	// Relays contains the result of the relayed check by relay node
+	Relays  map[string]bool
+	Verdict relayVerdict
+}
+
+func (t *triangulation) String() string {
+	var relays []string
+	for node, ok := range t.Relays {
+		result := "failed"
+		if ok {
+			result = "ok"
+		}
+		relays = append(relays, fmt.Sprintf("%s->%s %s", node, t.Edge.DestHost, result))
+	}
+	sort.Strings(relays)
+	return fmt.Sprintf("edge %s failing, relayed checks: [%s], verdict: %s", t.Edge, strings.Join(relays, ", "), t.Verdict)
+}
+
+// relayResult is the result of a relayed check, either the observation or the error if the agent could not run the check.
+type relayResult struct {
+	obs *nwpd.Observation
+	err error
+}
+
+// relayFunc requests relayed checks from an agent in a single call. The results are returned in the order of the requests.
+// An error is returned if the agent could not be called at all.
+type relayFunc func(ctx context.Context, agent agentAddress, requests []*nwpd.RelayCheckRequest) ([]relayResult, error)
+
+// triangulator pinpoints the broken node of failing edges by asking agents on other nodes to check the destination.
+type triangulator struct {
+	relaysPerEdge int
+	maxEdges      int
+	timeout       time.Duration
+	relayFunc     relayFunc
+}
+
+func newTriangulator(relaysPerEdge, maxEdges int, timeout time.Duration) *triangulator {
+	return &triangulator{
+		relaysPerEdge: relaysPerEdge,
+		maxEdges:      maxEdges,
+		timeout:       timeout,
+		relayFunc:     grpcRelayFunc,
+	}
+}
+
+// findFailingEdges returns the sorted edges reported by the polled agents with failed checks only.
+func findFailingEdges(agents []agentAddress, responses map[string]*nwpd.GetAggregatedObservationsResponse) []failingEdge {
+	daemonSets := map[string]string{}
+	for _, agent := range agents {
+		daemonSets[agent.Podname] = agent.DaemonSet
+	}
+	edges := map[failingEdge]struct{}{}
+	for podname, resp := range responses {
+		for _, aggr := range resp.AggregatedObservations {
+			for jobID, notOk := range aggr.JobsNotOkCount {
+				if notOk > 0 && aggr.JobsOkCount[jobID] == 0 {
+					edges[failingEdge{JobID: jobID, SrcHost: aggr.SrcHost, DestHost: aggr.DestHost, DaemonSet: daemonSets[podname]}] = struct{}{}
+				}
+			}
+		}
+	}
+	var result []failingEdge
+	for edge := range edges {
+		result = append(result, edge)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].String() < result[j].String() })
+	return result
+}
+
+// relayCandidates returns the agents which may check the destination of the edge, i.e. the agents not running on the source
+// or destination node. Agents of the daemon set of the reporting agent come first. The order is rotated per edge to spread the load.
+func relayCandidates(edge failingEdge, agents []agentAddress) []agentAddress {
+	var same, other []agentAddress
+	for _, agent := range agents {
+		if agent.Nodename == "" || agent.Nodename == edge.SrcHost || agent.Nodename == edge.DestHost {
+			continue
+		}
+		if agent.DaemonSet == edge.DaemonSet {
+			same = append(same, agent)
+		} else {
+			other = append(other, agent)
+		}
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(edge.String()))
+	offset := int(h.Sum32())
+	rotate := func(list []agentAddress) []agentAddress {
+		if len(list) == 0 {
+			return nil
+		}
+		n := offset % len(list)
+		return append(list[n:], list[:n]...)
+	}
+	return append(rotate(same), rotate(other)...)
+}
+
+// relayBatch contains the relayed checks requested from an agent in a single call.
+type relayBatch struct {
+	agent agentAddress
+	// edges are the indices of the edges of the requests
+	edges    []int
+	requests []*nwpd.RelayCheckRequest
+	results  []relayResult
+	err      error
+}
+
+// triangulate runs the relayed checks for the failing edges. At most maxEdges edges are triangulated.
+// The checks are requested in rounds with one batched call per agent and round. In each round, every edge still missing
+// answers gets its next candidates, so that candidates without the job or the destination are replaced.
+func (t *triangulator) triangulate(ctx context.Context, agents []agentAddress, edges []failingEdge) []*triangulation {
+	if len(edges) > t.maxEdges {
+		edges = edges[:t.maxEdges]
+	}
+	result := make([]*triangulation, len(edges))
+	candidates := make([][]agentAddress, len(edges))
+	for i, edge := range edges {
+		result[i] = &triangulation{Edge: edge, Relays: map[string]bool{}}
+		candidates[i] = relayCandidates(edge, agents)
+	}
+	for {
+		batches := t.nextRelayBatches(result, candidates)
+		if len(batches) == 0 {
+			break
+		}
+		t.runRelayBatches(ctx, batches)
+		for _, batch := range batches {
+			for j, edge := range batch.edges {
+				err := batch.err
+				var obs *nwpd.Observation
+				if err == nil {
+					obs, err = batch.results[j].obs, batch.results[j].err
+				}
+				if err != nil {
+					switch status.Code(err) {
+					case codes.NotFound, codes.InvalidArgument:
+					default:
+						reportRelayCheck(relayResultError)
+					}
+					continue
+				}
+				result[edge].Relays[batch.agent.Nodename] = obs.Ok
+				if obs.Ok {
+					reportRelayCheck(relayResultOK)
+				} else {
+					reportRelayCheck(relayResultFailed)
+				}
+			}
+		}
+	}
+	for _, tr := range result {
+		tr.Verdict = verdictOf(tr.Relays)
+	}
+	return result
+}
+
+// nextRelayBatches takes the next candidates on distinct nodes for each edge still missing answers of relayed checks
+// and groups the requests by agent.
+func (t *triangulator) nextRelayBatches(result []*triangulation, candidates [][]agentAddress) []*relayBatch {
+	var batches []*relayBatch
+	byAgent := map[string]*relayBatch{}
+	for i, tr := range result {
+		missing := t.relaysPerEdge - len(tr.Relays)
+		picked := map[string]bool{}
+		for missing > 0 && len(candidates[i]) > 0 {
+			agent := candidates[i][0]
+			candidates[i] = candidates[i][1:]
+			if _, ok := tr.Relays[agent.Nodename]; ok || picked[agent.Nodename] {
+				continue
+			}
+			picked[agent.Nodename] = true
+			missing--
+			batch := byAgent[agent.Podname]
+			if batch == nil || len(batch.requests) >= common.MaxRelayChecksPerBatch {
+				batch = &relayBatch{agent: agent}
+				byAgent[agent.Podname] = batch
+				batches = append(batches, batch)
+			}
+			batch.edges = append(batch.edges, i)
+			batch.requests = append(batch.requests, &nwpd.RelayCheckRequest{JobID: tr.Edge.JobID, DestHost: tr.Edge.DestHost, Requester: relayRequester})
+		}
+	}
+	return batches
+}
+
+// runRelayBatches calls the agents of the batches concurrently.
+func (t *triangulator) runRelayBatches(ctx context.Context, batches []*relayBatch) {
+	var wg sync.WaitGroup
+	for _, batch := range batches {
+		wg.Add(1)
+		go func(batch *relayBatch) {
+			defer wg.Done()
+			relayCtx, cancel := context.WithTimeout(ctx, t.timeout)
+			defer cancel()
+			batch.results, batch.err = t.relayFunc(relayCtx, batch.agent, batch.requests)
+			if batch.err == nil && len(batch.results) != len(batch.requests) {
+				batch.err = fmt.Errorf("relayed checks by %s: %d results for %d requests", batch.agent.Podname, len(batch.results), len(batch.requests))
+			}
+		}(batch)
+	}
+	wg.Wait()
+}
+
+func verdictOf(relays map[string]bool) relayVerdict {
+	if len(relays) == 0 {
+		return verdictUnknown
+	}
+	okCount := 0
+	for _, ok := range relays {
+		if ok {
+			okCount++
+		}
+	}
+	switch okCount {
+	case 0:
+		return verdictDestination
+	case len(relays):
+		return verdictSource
+	default:
+		return verdictInconclusive
+	}
+}
+
+// grpcRelayFunc requests the relayed checks with a single batched call. Agents of older versions without the batched
+// method are called once per check.
+func grpcRelayFunc(ctx context.Context, agent agentAddress, requests []*nwpd.RelayCheckRequest) ([]relayResult, error) {
+	cc, err := grpc.DialContext(ctx, agent.Address, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	client := nwpd.NewAgentServiceClient(cc)
+	results, err := relayChecksBatched(ctx, client, requests)
+	if status.Code(err) == codes.Unimplemented {
+		results, err = relayChecksPerCall(ctx, client, requests), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if results[i].obs == nil && results[i].err == nil {
+			results[i].err = fmt.Errorf("relayed check by %s without observation", agent.Podname)
+		}
+	}
+	return results, nil
+}
+
+func relayChecksBatched(ctx context.Context, client nwpd.AgentServiceClient, requests []*nwpd.RelayCheckRequest) ([]relayResult, error) {
+	stream, err := client.RelayChecks(ctx, &nwpd.RelayChecksRequest{Checks: requests})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]relayResult, len(requests))
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if resp.Index < 0 || int(resp.Index) >= len(results) {
+			return nil, fmt.Errorf("invalid index %d of relayed check", resp.Index)
+		}
+		if resp.Code != int32(codes.OK) {
+			results[resp.Index].err = status.Error(codes.Code(resp.Code), resp.Error)
+		} else {
+			results[resp.Index].obs = resp.Observation
+		}
+	}
+}
+
+func relayChecksPerCall(ctx context.Context, client nwpd.AgentServiceClient, requests []*nwpd.RelayCheckRequest) []relayResult {
+	results := make([]relayResult, len(requests))
+	for i, request := range requests {
+		resp, err := client.RelayCheck(ctx, request)
+		if err != nil {
+			results[i].err = err
+		} else {
+			results[i].obs = resp.Observation
+		}
+	}
+	return results
+}
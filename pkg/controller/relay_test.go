@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+)
+
+func TestFindFailingEdges(t *testing.T) {
+	agents := []agentAddress{
+		{Podname: "pod-a", Nodename: "node-a", DaemonSet: common.NameDaemonSetAgentPodNet},
+		{Podname: "host-a", Nodename: "node-a", DaemonSet: common.NameDaemonSetAgentHostNet},
+	}
+	responses := map[string]*nwpd.GetAggregatedObservationsResponse{
+		"pod-a": {AggregatedObservations: []*nwpd.AggregatedObservation{
+			{SrcHost: "node-a", DestHost: "node-b", JobsOkCount: map[string]int32{"tcp-p2p": 0}, JobsNotOkCount: map[string]int32{"tcp-p2p": 3}},
+			{SrcHost: "node-a", DestHost: "node-c", JobsOkCount: map[string]int32{"tcp-p2p": 1}, JobsNotOkCount: map[string]int32{"tcp-p2p": 2}},
+		}},
+		"host-a": {AggregatedObservations: []*nwpd.AggregatedObservation{
+			{SrcHost: "node-a", DestHost: "node-c", JobsNotOkCount: map[string]int32{"ping-n2n": 1}},
+		}},
+	}
+	assert.Equal(t, []failingEdge{
+		{JobID: "ping-n2n", SrcHost: "node-a", DestHost: "node-c", DaemonSet: common.NameDaemonSetAgentHostNet},
+		{JobID: "tcp-p2p", SrcHost: "node-a", DestHost: "node-b", DaemonSet: common.NameDaemonSetAgentPodNet},
+	}, findFailingEdges(agents, responses))
+}
+
+func TestRelayCandidates(t *testing.T) {
+	var agents []agentAddress
+	for _, node := range []string{"node-a", "node-b", "node-c", "node-d"} {
+		agents = append(agents,
+			agentAddress{Podname: "pod-" + node, Nodename: node, DaemonSet: common.NameDaemonSetAgentPodNet},
+			agentAddress{Podname: "host-" + node, Nodename: node, DaemonSet: common.NameDaemonSetAgentHostNet})
+	}
+	edge := failingEdge{JobID: "tcp-p2p", SrcHost: "node-a", DestHost: "node-b", DaemonSet: common.NameDaemonSetAgentPodNet}
+	candidates := relayCandidates(edge, agents)
+	assert.Len(t, candidates, 4)
+	for i, agent := range candidates {
+		assert.NotContains(t, []string{"node-a", "node-b"}, agent.Nodename)
+		assert.Equal(t, i < 2, agent.DaemonSet == common.NameDaemonSetAgentPodNet, "same daemon set first")
+	}
+	assert.Equal(t, candidates, relayCandidates(edge, agents), "stable order")
+}
+
+func TestTriangulate(t *testing.T) {
+	// node-b is broken, node-c is reachable from everywhere except node-a
+	reachable := func(src, dest string) bool {
+		return dest != "node-b" && src != "node-b"
+	}
+	var agents []agentAddress
+	for _, node := range []string{"node-a", "node-b", "node-c", "node-d", "node-e"} {
+		agents = append(agents, agentAddress{Podname: "pod-" + node, Nodename: node, DaemonSet: common.NameDaemonSetAgentPodNet})
+	}
+	agents = append(agents, agentAddress{Podname: "other", Nodename: "node-f", DaemonSet: common.NameDaemonSetAgentHostNet})
+	calls := map[string]int{}
+	tr := &triangulator{
+		relaysPerEdge: 2,
+		maxEdges:      2,
+		timeout:       time.Second,
+		relayFunc: func(ctx context.Context, agent agentAddress, requests []*nwpd.RelayCheckRequest) ([]relayResult, error) {
+			calls[agent.Podname]++
+			if agent.Nodename == "node-e" {
+				return nil, fmt.Errorf("connection refused")
+			}
+			var results []relayResult
+			for _, request := range requests {
+				if agent.DaemonSet != common.NameDaemonSetAgentPodNet {
+					results = append(results, relayResult{err: status.Errorf(codes.NotFound, "unknown job %s", request.JobID)})
+					continue
+				}
+				results = append(results, relayResult{obs: &nwpd.Observation{SrcHost: agent.Nodename, DestHost: request.DestHost, Ok: reachable(agent.Nodename, request.DestHost)}})
+			}
+			return results, nil
+		},
+	}
+	edges := []failingEdge{
+		{JobID: "tcp-p2p", SrcHost: "node-a", DestHost: "node-b", DaemonSet: common.NameDaemonSetAgentPodNet},
+		{JobID: "tcp-p2p", SrcHost: "node-a", DestHost: "node-c", DaemonSet: common.NameDaemonSetAgentPodNet},
+		{JobID: "tcp-p2p", SrcHost: "node-a", DestHost: "node-d", DaemonSet: common.NameDaemonSetAgentPodNet},
+	}
+	result := tr.triangulate(context.Background(), agents, edges)
+	if assert.Len(t, result, 2, "limited by max edges") {
+		assert.Equal(t, verdictDestination, result[0].Verdict)
+		assert.Len(t, result[0].Relays, 2)
+		assert.Equal(t, verdictInconclusive, result[1].Verdict, "node-b cannot reach node-c")
+		assert.Equal(t, map[string]bool{"node-b": false, "node-d": true}, result[1].Relays)
+		assert.Equal(t, "edge tcp-p2p node-a->node-c failing, relayed checks: [node-b->node-c failed, node-d->node-c ok], verdict: inconclusive",
+			result[1].String())
+	}
+	for podname, count := range calls {
+		assert.LessOrEqual(t, count, 2, "one batched call per agent and round: %s", podname)
+	}
+
+	assert.Equal(t, verdictSource, verdictOf(map[string]bool{"node-c": true, "node-d": true}))
+	assert.Equal(t, verdictUnknown, verdictOf(nil))
+}
+
+type fakeRelayAgent struct {
+	nwpd.UnimplementedAgentServiceServer
+}
+
+func (a *fakeRelayAgent) RelayCheck(_ context.Context, request *nwpd.RelayCheckRequest) (*nwpd.RelayCheckResponse, error) {
+	if request.JobID != "tcp-p2p" {
+		return nil, status.Errorf(codes.NotFound, "unknown job %s", request.JobID)
+	}
+	return &nwpd.RelayCheckResponse{Observation: &nwpd.Observation{JobID: request.JobID, DestHost: request.DestHost, Ok: true}}, nil
+}
+
+func TestGRPCRelayFuncFallback(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	s := grpc.NewServer()
+	nwpd.RegisterAgentServiceServer(s, &fakeRelayAgent{})
+	go func() { _ = s.Serve(listener) }()
+	defer s.Stop()
+
+	agent := agentAddress{Podname: "pod-a", Address: listener.Addr().String()}
+	results, err := grpcRelayFunc(context.Background(), agent, []*nwpd.RelayCheckRequest{
+		{JobID: "tcp-p2p", DestHost: "node-b"},
+		{JobID: "unknown", DestHost: "node-b"},
+	})
+	if assert.NoError(t, err, "falls back to single calls for agents without batched method") && assert.Len(t, results, 2) {
+		assert.True(t, results[0].obs.Ok)
+		assert.Equal(t, codes.NotFound, status.Code(results[1].err))
+	}
+}
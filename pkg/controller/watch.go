@@ -7,36 +7,60 @@
 package controller
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/gardener/network-problem-detector/pkg/common"
 	"github.com/gardener/network-problem-detector/pkg/common/config"
 	"github.com/gardener/network-problem-detector/pkg/deploy"
-	"k8s.io/apimachinery/pkg/api/errors"
 
 	"github.com/sirupsen/logrus"
 	"go.uber.org/atomic"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
+	informersappsv1 "k8s.io/client-go/informers/apps/v1"
 	informerscorev1 "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/yaml"
 )
 
+// shootInfoTimeout is the timeout for looking up the external kube-apiserver endpoint from the shoot info
+const shootInfoTimeout = 30 * time.Second
+
+// clusterConfigLoopPeriod is the period of checking for changes of nodes, agent pods and services.
+// It bounds the delay until e.g. a deleted node is removed from the cluster config.
+const clusterConfigLoopPeriod = 10 * time.Second
+
 type nodePodController struct {
 	hasUpdates                atomic.Bool
 	informerFactory           informers.SharedInformerFactory
 	informerFactoryKubeSystem informers.SharedInformerFactory
 	nodesInformer             informerscorev1.NodeInformer
 	podsInformer              informerscorev1.PodInformer
+	servicesInformer          informerscorev1.ServiceInformer
+	daemonSetsInformer        informersappsv1.DaemonSetInformer
+	nodeEvents                *nodeEventRecorder
+	// nodeSelector restricts the nodes of the cluster config, e.g. to the node pools managed by Gardener
+	nodeSelector         labels.Selector
+	loadBalancerSelector labels.Selector
+	serviceSelector      labels.Selector
 }
 
-func newNodePodController(clientset kubernetes.Interface, resyncPeriod time.Duration) *nodePodController {
+// newNodePodController creates the controller. If nodeSelector is nil, all nodes are selected.
+func newNodePodController(clientset kubernetes.Interface, resyncPeriod time.Duration, nodeSelector, loadBalancerSelector, serviceSelector labels.Selector) *nodePodController {
+	if nodeSelector == nil {
+		nodeSelector = labels.Everything()
+	}
 	informerFactory := informers.NewSharedInformerFactory(clientset, resyncPeriod)
 	informerFactoryKubeSystem := informers.NewSharedInformerFactoryWithOptions(clientset,
 		resyncPeriod, informers.WithNamespace(common.NamespaceKubeSystem))
@@ -45,51 +69,215 @@ func newNodePodController(clientset kubernetes.Interface, resyncPeriod time.Dura
 		informerFactoryKubeSystem: informerFactoryKubeSystem,
 		nodesInformer:             informerFactory.Core().V1().Nodes(),
 		podsInformer:              informerFactoryKubeSystem.Core().V1().Pods(),
+		servicesInformer:          informerFactory.Core().V1().Services(),
+		daemonSetsInformer:        informerFactoryKubeSystem.Apps().V1().DaemonSets(),
+		nodeEvents:                newNodeEventRecorder(),
+		nodeSelector:              nodeSelector,
+		loadBalancerSelector:      loadBalancerSelector,
+		serviceSelector:           serviceSelector,
 	}
 
+	// setting the transform functions only fails if the informers have already been started
+	_ = c.nodesInformer.Informer().SetTransform(c.transformNode)
+	_ = c.podsInformer.Informer().SetTransform(transformPod)
 	c.nodesInformer.Informer().AddEventHandler(c)
 	c.podsInformer.Informer().AddEventHandler(c)
+	c.servicesInformer.Informer().AddEventHandler(c)
+	c.daemonSetsInformer.Informer().AddEventHandler(c)
 
 	return c
 }
 
+// transformNode reduces a node to the fields used for the cluster config and the node events to keep the
+// memory usage of the informer cache low in large clusters. The labels of the node selector are kept.
+func (c *nodePodController) transformNode(obj interface{}) (interface{}, error) {
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		// e.g. tombstones of deleted nodes, which have already been transformed
+		return obj, nil
+	}
+	minimal := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              node.Name,
+			UID:               node.UID,
+			ResourceVersion:   node.ResourceVersion,
+			DeletionTimestamp: node.DeletionTimestamp,
+		},
+		Spec: corev1.NodeSpec{Taints: node.Spec.Taints},
+	}
+	keys := []string{corev1.LabelTopologyZone, common.LabelKeyWorkerPool}
+	requirements, _ := c.nodeSelector.Requirements()
+	for _, r := range requirements {
+		keys = append(keys, r.Key())
+	}
+	for _, key := range keys {
+		if value, ok := node.Labels[key]; ok {
+			if minimal.Labels == nil {
+				minimal.Labels = map[string]string{}
+			}
+			minimal.Labels[key] = value
+		}
+	}
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == corev1.NodeHostName || addr.Type == corev1.NodeInternalIP || addr.Type == corev1.NodeExternalIP {
+			minimal.Status.Addresses = append(minimal.Status.Addresses, addr)
+		}
+	}
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			minimal.Status.Conditions = []corev1.NodeCondition{{Type: cond.Type, Status: cond.Status}}
+		}
+	}
+	return minimal, nil
+}
+
+// transformPod reduces a pod to the fields used for the cluster config and for polling the agents.
+func transformPod(obj interface{}) (interface{}, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return obj, nil
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         pod.Namespace,
+			Name:              pod.Name,
+			UID:               pod.UID,
+			ResourceVersion:   pod.ResourceVersion,
+			Labels:            pod.Labels,
+			DeletionTimestamp: pod.DeletionTimestamp,
+		},
+		Spec: corev1.PodSpec{NodeName: pod.Spec.NodeName},
+		Status: corev1.PodStatus{
+			Phase:     pod.Status.Phase,
+			PodIP:     pod.Status.PodIP,
+			StartTime: pod.Status.StartTime,
+		},
+	}, nil
+}
+
 func (c *nodePodController) HasUpdates() bool {
 	return c.hasUpdates.Swap(false)
 }
 
+// ListNodes returns the nodes selected by the node selector.
 func (c *nodePodController) ListNodes() ([]*corev1.Node, error) {
-	return c.nodesInformer.Lister().List(labels.Everything())
+	return c.nodesInformer.Lister().List(c.nodeSelector)
+}
+
+// isSelectedNode returns true if the node is selected by the node selector.
+func (c *nodePodController) isSelectedNode(node *corev1.Node) bool {
+	return c.nodeSelector.Matches(labels.Set(node.Labels))
 }
 
 func (c *nodePodController) ListAgentPods() ([]*corev1.Pod, error) {
 	return c.podsInformer.Lister().List(labels.SelectorFromSet(map[string]string{common.LabelKeyK8sApp: common.NameDaemonSetAgentPodNet}))
 }
 
+// ListAllAgentPods returns the agent pods of both daemon sets.
+func (c *nodePodController) ListAllAgentPods() ([]*corev1.Pod, error) {
+	selector, err := labels.Parse(fmt.Sprintf("%s in (%s,%s)", common.LabelKeyK8sApp, common.NameDaemonSetAgentPodNet, common.NameDaemonSetAgentHostNet))
+	if err != nil {
+		return nil, err
+	}
+	return c.podsInformer.Lister().List(selector)
+}
+
+// KubeDNSEndpoint returns the endpoint of the kube-dns service.
+func (c *nodePodController) KubeDNSEndpoint() (*config.Endpoint, error) {
+	svc, err := c.servicesInformer.Lister().Services(common.NamespaceKubeSystem).Get(common.NameKubeDNSService)
+	if err != nil {
+		return nil, err
+	}
+	return deploy.KubeDNSEndpointFromService(svc)
+}
+
+// LoadBalancerEndpoints returns the external endpoints of the services selected for the load balancer hairpin check.
+func (c *nodePodController) LoadBalancerEndpoints() ([]config.Endpoint, []error) {
+	services, err := c.servicesInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, []error{err}
+	}
+	return deploy.LoadBalancerEndpoints(services, c.loadBalancerSelector)
+}
+
+// ServiceEndpoints returns the cluster IP endpoints of the services selected for the checks from the pod network.
+func (c *nodePodController) ServiceEndpoints() ([]config.Endpoint, []error) {
+	services, err := c.servicesInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, []error{err}
+	}
+	return deploy.ServiceEndpoints(services, c.serviceSelector)
+}
+
+// NodeLocalDNSEndpoint returns the endpoint of node-local-dns if its daemon set exists.
+func (c *nodePodController) NodeLocalDNSEndpoint() (*config.Endpoint, error) {
+	_, err := c.daemonSetsInformer.Lister().DaemonSets(common.NamespaceKubeSystem).Get(common.NameNodeLocalDNS)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: daemonset %s/%s does not exist", deploy.ErrNodeLocalDNSNotDeployed, common.NamespaceKubeSystem, common.NameNodeLocalDNS)
+		}
+		return nil, err
+	}
+	return deploy.NodeLocalDNSEndpoint(), nil
+}
+
 func (c *nodePodController) Start(stopCh chan struct{}) error {
 	c.informerFactory.Start(stopCh)
-	if !cache.WaitForCacheSync(stopCh, c.nodesInformer.Informer().HasSynced) {
+	if !cache.WaitForCacheSync(stopCh, c.nodesInformer.Informer().HasSynced, c.servicesInformer.Informer().HasSynced) {
 		return fmt.Errorf("Failed to sync")
 	}
 
 	c.informerFactoryKubeSystem.Start(stopCh)
-	if !cache.WaitForCacheSync(stopCh, c.podsInformer.Informer().HasSynced) {
+	if !cache.WaitForCacheSync(stopCh, c.podsInformer.Informer().HasSynced, c.daemonSetsInformer.Informer().HasSynced) {
 		return fmt.Errorf("Failed to sync")
 	}
+	c.nodeEvents.enable()
 
 	return nil
 }
 
 func (c *nodePodController) OnAdd(obj interface{}) {
+	if node, ok := obj.(*corev1.Node); ok && c.isSelectedNode(node) {
+		c.nodeEvents.onAdd(node)
+	}
 	if c.isRelevant(obj) {
 		c.hasUpdates.Store(true)
 	}
 }
 
 func (c *nodePodController) OnUpdate(oldObj, newObj interface{}) {
+	if oldNode, ok := oldObj.(*corev1.Node); ok {
+		if newNode, ok := newObj.(*corev1.Node); ok {
+			if c.isSelectedNode(newNode) {
+				c.nodeEvents.onUpdate(oldNode, newNode)
+			}
+			// nodes leaving or joining the node selector are pruned from or added to the cluster config
+			if c.isSelectedNode(oldNode) != c.isSelectedNode(newNode) ||
+				oldNode.Labels[corev1.LabelTopologyZone] != newNode.Labels[corev1.LabelTopologyZone] ||
+				oldNode.Labels[common.LabelKeyWorkerPool] != newNode.Labels[common.LabelKeyWorkerPool] ||
+				(oldNode.DeletionTimestamp == nil) != (newNode.DeletionTimestamp == nil) {
+				c.hasUpdates.Store(true)
+			}
+		}
+		return
+	}
+	if oldSvc, ok := oldObj.(*corev1.Service); ok {
+		if newSvc, ok := newObj.(*corev1.Service); ok && (c.isRelevant(oldObj) || c.isRelevant(newObj)) {
+			if oldSvc.Spec.ClusterIP != newSvc.Spec.ClusterIP ||
+				oldSvc.Annotations[common.AnnotationCheckLoadBalancer] != newSvc.Annotations[common.AnnotationCheckLoadBalancer] ||
+				c.isRelevant(oldObj) != c.isRelevant(newObj) ||
+				!reflect.DeepEqual(oldSvc.Spec.Ports, newSvc.Spec.Ports) ||
+				!reflect.DeepEqual(oldSvc.Status.LoadBalancer, newSvc.Status.LoadBalancer) {
+				c.hasUpdates.Store(true)
+			}
+		}
+		return
+	}
 	if oldPod, ok := oldObj.(*corev1.Pod); ok {
 		if c.isRelevant(newObj) {
 			if newPod, ok := newObj.(*corev1.Pod); ok {
-				if oldPod.Status.Phase != corev1.PodRunning && newPod.Status.Phase == corev1.PodRunning {
+				if oldPod.Status.Phase != corev1.PodRunning && newPod.Status.Phase == corev1.PodRunning ||
+					oldPod.DeletionTimestamp == nil && newPod.DeletionTimestamp != nil {
 					c.hasUpdates.Store(true)
 				}
 			}
@@ -98,19 +286,35 @@ func (c *nodePodController) OnUpdate(oldObj, newObj interface{}) {
 }
 
 func (c *nodePodController) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if node, ok := obj.(*corev1.Node); ok && c.isSelectedNode(node) {
+		c.nodeEvents.onDelete(node)
+	}
 	if c.isRelevant(obj) {
 		c.hasUpdates.Store(true)
 	}
 }
 
 func (c *nodePodController) isRelevant(obj interface{}) bool {
-	if _, ok := obj.(*corev1.Node); ok {
-		return ok
+	if node, ok := obj.(*corev1.Node); ok {
+		return c.isSelectedNode(node)
 	}
 	if pod, ok := obj.(*corev1.Pod); ok {
 		labels := pod.GetLabels()
 		return labels != nil && labels[common.LabelKeyK8sApp] == common.NameDaemonSetAgentPodNet
 	}
+	if svc, ok := obj.(*corev1.Service); ok {
+		if svc.Namespace == common.NamespaceKubeSystem && svc.Name == common.NameKubeDNSService {
+			return true
+		}
+		_, ok := svc.Annotations[common.AnnotationCheckLoadBalancer]
+		return ok || deploy.IsSelectedLoadBalancer(svc, c.loadBalancerSelector) || deploy.IsSelectedService(svc, c.serviceSelector)
+	}
+	if ds, ok := obj.(*appsv1.DaemonSet); ok {
+		return ds.Name == common.NameNodeLocalDNS
+	}
 	return false
 }
 
@@ -119,93 +323,208 @@ func (cc *controllerCommand) watch(log logrus.FieldLogger) error {
 		return err
 	}
 
-	controller := newNodePodController(cc.Clientset, 24*time.Hour)
+	var nodeSelector labels.Selector
+	if cc.nodeLabelSelector != "" {
+		var err error
+		nodeSelector, err = labels.Parse(cc.nodeLabelSelector)
+		if err != nil {
+			return fmt.Errorf("invalid node label selector %q: %w", cc.nodeLabelSelector, err)
+		}
+		log.Infof("restricting nodes to label selector %q", cc.nodeLabelSelector)
+	}
+	var loadBalancerSelector labels.Selector
+	if cc.loadBalancerSelector != "" {
+		var err error
+		loadBalancerSelector, err = labels.Parse(cc.loadBalancerSelector)
+		if err != nil {
+			return fmt.Errorf("invalid load balancer selector %q: %w", cc.loadBalancerSelector, err)
+		}
+	}
+	var serviceSelector labels.Selector
+	if cc.serviceSelector != "" {
+		var err error
+		serviceSelector, err = labels.Parse(cc.serviceSelector)
+		if err != nil {
+			return fmt.Errorf("invalid service selector %q: %w", cc.serviceSelector, err)
+		}
+	}
+	controller := newNodePodController(cc.Clientset, 24*time.Hour, nodeSelector, loadBalancerSelector, serviceSelector)
 	stopCh := make(chan struct{})
 	defer close(stopCh)
 	if err := controller.Start(stopCh); err != nil {
 		return err
 	}
+	if cc.pollPeriod > 0 {
+		go cc.pollLoop(log, controller, stopCh)
+	}
+	if cc.endpointStalenessPeriod > 0 {
+		go cc.checkEndpointStalenessLoop(log, stopCh)
+	}
+	if cc.agentConfigValidationPeriod > 0 {
+		go cc.validateAgentConfigLoop(log, stopCh)
+	}
+	if cc.networkChecksPeriod > 0 {
+		client, err := dynamic.NewForConfig(cc.RestConfig)
+		if err != nil {
+			return err
+		}
+		go cc.reconcileNetworkChecksLoop(log, client, stopCh)
+	}
+	var endpointList *endpointListFetcher
+	if cc.endpointsFromURL != "" {
+		endpointList = newEndpointListFetcher(cc.endpointsFromURL)
+		go cc.refreshEndpointListLoop(log, endpointList, controller, stopCh)
+	}
 
 	ctx := context.Background()
-	var last time.Time
+	var last, lastMaintenanceCheck time.Time
+	// pending is set until the cluster config map has been updated successfully after a change,
+	// so that failed updates are retried in the next loop
+	pending := false
 	for {
 		now := time.Now()
-		if delta := now.Sub(last); delta < 10*time.Second {
-			time.Sleep(delta)
+		if delta := now.Sub(last); delta < clusterConfigLoopPeriod {
+			time.Sleep(clusterConfigLoopPeriod - delta)
 			continue
 		}
 		last = now
-		if !controller.HasUpdates() {
-			cc.lastLoop.Store(last.UnixMilli())
-			continue
+		cc.storeNodeEvents(ctx, log, controller.nodeEvents)
+		maintenanceCheck := cc.maintenanceCheckPeriod > 0 && now.Sub(lastMaintenanceCheck) >= cc.maintenanceCheckPeriod
+		if controller.HasUpdates() {
+			pending = true
 		}
-		nodes, err := controller.ListNodes()
-		if err != nil {
-			log.Errorf("listing nodes failed: %s", err)
+		if !pending && !maintenanceCheck {
+			cc.lastLoop.Store(last.UnixMilli())
 			continue
 		}
-		pods, err := controller.ListAgentPods()
-		if err != nil {
-			log.Errorf("listing pods ins namespace %s failed: %s", common.NamespaceKubeSystem, err)
+		lastMaintenanceCheck = now
+		if err := cc.updateClusterConfig(ctx, log, cc.Clientset, controller, endpointList, now); err != nil {
+			log.Error(err)
 			continue
 		}
+		pending = false
+		cc.lastLoop.Store(last.UnixMilli())
+	}
+}
 
-		svc, err := cc.Clientset.CoreV1().Services(common.NamespaceDefault).Get(ctx, common.NameKubernetesService, metav1.GetOptions{})
-		if err != nil {
-			log.Errorf("loading service %s/%s failed: %s", common.NamespaceDefault, common.NameKubernetesService, err)
-			continue
-		}
-		internalApiServer := &config.Endpoint{
-			Hostname: common.DomainNameKubernetesService,
-			IP:       svc.Spec.ClusterIP,
-			Port:     int(svc.Spec.Ports[0].Port),
-		}
-		var apiServer *config.Endpoint
-		configmaps := cc.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem)
-		shootInfo, err := configmaps.Get(ctx, common.NameGardenerShootInfo, metav1.GetOptions{})
-		if err != nil {
-			if !errors.IsNotFound(err) {
-				log.Errorf("loading configmap %s/%s failed: %s", common.NamespaceKubeSystem, common.NameGardenerShootInfo, err)
-				continue
-			}
-		}
-		if err == nil {
-			apiServer, err = deploy.GetAPIServerEndpointFromShootInfo(shootInfo)
-			if err != nil {
-				log.Errorf("fetching kube-apiserver external endpoint failed: %s", err)
-				continue
-			}
-		}
+// updateClusterConfig builds the cluster config from the current state of the cluster and updates the cluster config map if it has changed.
+func (cc *controllerCommand) updateClusterConfig(ctx context.Context, log logrus.FieldLogger, clientset kubernetes.Interface, controller *nodePodController,
+	endpointList *endpointListFetcher, now time.Time) error {
+	nodes, err := controller.ListNodes()
+	if err != nil {
+		return fmt.Errorf("listing nodes failed: %s", err)
+	}
+	pods, err := controller.ListAgentPods()
+	if err != nil {
+		return fmt.Errorf("listing pods ins namespace %s failed: %s", common.NamespaceKubeSystem, err)
+	}
 
-		cm, err := configmaps.Get(ctx, common.NameClusterConfigMap, metav1.GetOptions{})
-		if err != nil {
-			log.Errorf("loading configmap %s/%s failed: %s", common.NamespaceKubeSystem, common.NameClusterConfigMap, err)
-			continue
-		}
-		content := cm.Data[common.ClusterConfigFilename]
-		cfg := &config.ClusterConfig{}
-		if err := yaml.Unmarshal([]byte(content), cfg); err != nil {
-			log.Errorf("unmarshal configmap %s/%s failed: %s", common.NamespaceKubeSystem, common.NameClusterConfigMap, err)
-			continue
-		}
-		cfg, err = deploy.BuildClusterConfig(nodes, pods, internalApiServer, apiServer)
-		cfgBytes, err := yaml.Marshal(cfg)
-		if err != nil {
-			log.Errorf("marshal configmap %s/%s failed: %s", common.NamespaceKubeSystem, common.NameClusterConfigMap, err)
-			continue
-		}
-		newContent := string(cfgBytes)
-		cm.Data[common.ClusterConfigFilename] = newContent
-		if newContent != content {
-			if _, err := configmaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
-				log.Errorf("updating configmap %s/%s failed: %s", common.NamespaceKubeSystem, common.NameClusterConfigMap, err)
-				continue
-			}
-			log.Infof("updated configmap %s/%s", common.NamespaceKubeSystem, common.NameClusterConfigMap)
-			cc.lastLoop.Store(last.UnixMilli())
-		} else {
-			log.Info("unchanged")
-			cc.lastLoop.Store(last.UnixMilli())
+	svc, err := clientset.CoreV1().Services(common.NamespaceDefault).Get(ctx, common.NameKubernetesService, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("loading service %s/%s failed: %s", common.NamespaceDefault, common.NameKubernetesService, err)
+	}
+	internalApiServer := &config.Endpoint{
+		Hostname: common.DomainNameKubernetesService,
+		IP:       svc.Spec.ClusterIP,
+		Port:     int(svc.Spec.Ports[0].Port),
+	}
+	configmaps := clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem)
+	apiServer, err := lookupAPIServerEndpoint(ctx, clientset)
+	if err != nil {
+		return fmt.Errorf("fetching kube-apiserver external endpoint failed: %s", err)
+	}
+
+	cm, err := configmaps.Get(ctx, common.NameClusterConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("loading configmap %s/%s failed: %s", common.NamespaceKubeSystem, common.NameClusterConfigMap, err)
+	}
+	content, err := deploy.ClusterConfigData(cm)
+	if err != nil {
+		return err
+	}
+	annotation := cm.Annotations[common.AnnotationMaintenanceWindows]
+	cfg := &config.ClusterConfig{}
+	if err := yaml.Unmarshal(content, cfg); err != nil {
+		return fmt.Errorf("unmarshal configmap %s/%s failed: %s", common.NamespaceKubeSystem, common.NameClusterConfigMap, err)
+	}
+	oldWindows := cfg.MaintenanceWindows
+	oldURLEndpoints := cfg.URLEndpoints
+	oldShards := cfg.Shards
+	cfg, err = deploy.BuildClusterConfig(nodes, pods, internalApiServer, apiServer)
+	if err != nil {
+		log.Warnf("building cluster config: %s", err)
+	}
+	cfg.NodeSelector = cc.nodeLabelSelector
+	if endpointList != nil {
+		cfg.URLEndpoints = endpointList.current(oldURLEndpoints)
+	}
+	cfg.MaintenanceWindows = cc.maintenanceWindows(log, cm, oldWindows, now)
+	cfg.KubeDNS, err = controller.KubeDNSEndpoint()
+	if err != nil {
+		log.Warnf("kube-dns discovery failed, DNS jobs using CoreDNS are omitted: %s", err)
+	}
+	cfg.NodeLocalDNS, err = controller.NodeLocalDNSEndpoint()
+	if err != nil {
+		log.Infof("DNS jobs using node-local-dns are omitted: %s", err)
+	}
+	var errs []error
+	cfg.LoadBalancers, errs = controller.LoadBalancerEndpoints()
+	for _, err := range errs {
+		log.Warnf("load balancer hairpin check skipped for %s", err)
+	}
+	cfg.Services, errs = controller.ServiceEndpoints()
+	for _, err := range errs {
+		log.Warnf("service check skipped for %s", err)
+	}
+	layout, err := deploy.LayoutClusterConfig(cfg, cc.clusterConfigCompressThreshold)
+	if err != nil {
+		return fmt.Errorf("configmap %s/%s not updated: %s", common.NamespaceKubeSystem, common.NameClusterConfigMap, err)
+	}
+	if bytes.Equal(layout.Data, content) && cm.Annotations[common.AnnotationMaintenanceWindows] == annotation {
+		reportClusterConfigLayout(layout)
+		log.Info("unchanged")
+		return nil
+	}
+	reportClusterConfigSize(ctx, log, clientset.CoreV1(), cm, layout, now)
+	if err := storeClusterConfigShards(ctx, configmaps, layout.Shards, cc.clusterConfigCompressThreshold); err != nil {
+		return err
+	}
+	if err := deploy.SetClusterConfigData(cm, layout.Data, cc.clusterConfigCompressThreshold); err != nil {
+		return err
+	}
+	if _, err := configmaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating configmap %s/%s failed: %s", common.NamespaceKubeSystem, common.NameClusterConfigMap, err)
+	}
+	log.Infof("updated configmap %s/%s", common.NamespaceKubeSystem, common.NameClusterConfigMap)
+	deleteObsoleteClusterConfigShards(ctx, log, configmaps, len(layout.Shards), oldShards)
+	return nil
+}
+
+// maintenanceWindows returns the maintenance windows of the annotation of the cluster config map and
+// removes the expired ones from the annotation. If the annotation is invalid, the old windows are kept.
+func (cc *controllerCommand) maintenanceWindows(log logrus.FieldLogger, cm *corev1.ConfigMap, oldWindows []config.MaintenanceWindow, now time.Time) []config.MaintenanceWindow {
+	windows, annotation, err := deploy.MaintenanceWindows(cm, now)
+	if err != nil {
+		log.Errorf("keeping previous maintenance windows: %s", err)
+		return config.PruneMaintenanceWindows(oldWindows, now)
+	}
+	if annotation != cm.Annotations[common.AnnotationMaintenanceWindows] {
+		log.Infof("pruned expired maintenance windows, %d remaining", len(windows))
+		deploy.SetMaintenanceWindowsAnnotation(cm, annotation)
+	}
+	return windows
+}
+
+// lookupAPIServerEndpoint returns the external kube-apiserver endpoint or nil if the cluster is not a Gardener shoot.
+func lookupAPIServerEndpoint(ctx context.Context, clientset kubernetes.Interface) (*config.Endpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, shootInfoTimeout)
+	defer cancel()
+	shootInfo, err := deploy.GetShootInfo(ctx, clientset)
+	if err != nil {
+		if errors.Is(err, deploy.ErrShootInfoNotFound) {
+			return nil, nil
 		}
+		return nil, err
 	}
+	return deploy.GetAPIServerEndpointFromShootInfo(ctx, shootInfo)
 }
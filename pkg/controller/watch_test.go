@@ -0,0 +1,415 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/deploy"
+)
+
+// fakeClientset provides the config maps and services of the core API.
+type fakeClientset struct {
+	kubernetes.Interface
+	core *fakeCoreV1
+}
+
+func (f *fakeClientset) CoreV1() typedcorev1.CoreV1Interface {
+	return f.core
+}
+
+type fakeCoreV1 struct {
+	typedcorev1.CoreV1Interface
+	configMaps *fakeConfigMaps
+	services   *fakeServices
+	nodes      *fakeNodes
+}
+
+func (f *fakeCoreV1) ConfigMaps(_ string) typedcorev1.ConfigMapInterface {
+	return f.configMaps
+}
+
+func (f *fakeCoreV1) Services(_ string) typedcorev1.ServiceInterface {
+	return f.services
+}
+
+func (f *fakeCoreV1) Nodes() typedcorev1.NodeInterface {
+	return f.nodes
+}
+
+// fakeServices implements Get for a single service.
+type fakeServices struct {
+	typedcorev1.ServiceInterface
+	svc *corev1.Service
+}
+
+func (f *fakeServices) Get(_ context.Context, _ string, _ metav1.GetOptions) (*corev1.Service, error) {
+	return f.svc.DeepCopy(), nil
+}
+
+func newClusterNode(name, ip string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+			{Type: corev1.NodeHostName, Address: name},
+			{Type: corev1.NodeInternalIP, Address: ip},
+		}},
+	}
+}
+
+func newAgentPod(name, nodename string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: common.NamespaceKubeSystem,
+			Name:      name,
+			Labels:    map[string]string{common.LabelKeyK8sApp: common.NameDaemonSetAgentPodNet},
+		},
+		Spec:   corev1.PodSpec{NodeName: nodename},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "100.64.0.1"},
+	}
+}
+
+func TestUpdateClusterConfigOnNodeDeletion(t *testing.T) {
+	configMaps := &fakeConfigMaps{cm: &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: common.NameClusterConfigMap, Namespace: common.NamespaceKubeSystem},
+		Data:       map[string]string{common.ClusterConfigFilename: "{}\n"},
+	}}
+	clientset := &fakeClientset{core: &fakeCoreV1{
+		configMaps: configMaps,
+		services: &fakeServices{svc: &corev1.Service{Spec: corev1.ServiceSpec{
+			ClusterIP: "100.104.0.1",
+			Ports:     []corev1.ServicePort{{Port: 443}},
+		}}},
+	}}
+	controller := newNodePodController(clientset, time.Hour, nil, nil, nil)
+	nodes := controller.nodesInformer.Informer().GetIndexer()
+	pods := controller.podsInformer.Informer().GetIndexer()
+	for i, name := range []string{"node1", "node2", "node3"} {
+		node := newClusterNode(name, fmt.Sprintf("10.0.0.%d", i+1))
+		assert.NoError(t, nodes.Add(node))
+		assert.NoError(t, pods.Add(newAgentPod("agent-"+name, name)))
+	}
+
+	cc := &controllerCommand{}
+	ctx := context.Background()
+	log := logrus.New()
+	clusterConfig := func() *config.ClusterConfig {
+		cfg := &config.ClusterConfig{}
+		assert.NoError(t, yaml.Unmarshal([]byte(configMaps.cm.Data[common.ClusterConfigFilename]), cfg))
+		return cfg
+	}
+	hostnames := func(cfg *config.ClusterConfig) []string {
+		var result []string
+		for _, n := range cfg.Nodes {
+			result = append(result, n.Hostname)
+		}
+		return result
+	}
+	podNodenames := func(cfg *config.ClusterConfig) []string {
+		var result []string
+		for _, p := range cfg.PodEndpoints {
+			result = append(result, p.Nodename)
+		}
+		return result
+	}
+
+	assert.NoError(t, cc.updateClusterConfig(ctx, log, clientset, controller, nil, time.Now()))
+	assert.Equal(t, []string{"node1", "node2", "node3"}, hostnames(clusterConfig()))
+	assert.Equal(t, 1, configMaps.updates)
+
+	// node being drained and deleted
+	node2, _, _ := nodes.GetByKey("node2")
+	deleting := node2.(*corev1.Node).DeepCopy()
+	deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	assert.NoError(t, nodes.Update(deleting))
+	controller.OnUpdate(node2, deleting)
+	assert.True(t, controller.HasUpdates())
+	assert.NoError(t, cc.updateClusterConfig(ctx, log, clientset, controller, nil, time.Now()))
+	cfg := clusterConfig()
+	assert.Equal(t, []string{"node1", "node3"}, hostnames(cfg))
+	assert.Equal(t, []string{"node1", "node3"}, podNodenames(cfg), "agent pod of deleted node must be dropped")
+
+	// node deleted, the agent pod is still listed until it is garbage collected
+	node3, _, _ := nodes.GetByKey("node3")
+	assert.NoError(t, nodes.Delete(node3))
+	controller.OnDelete(cache.DeletedFinalStateUnknown{Key: "node3", Obj: node3})
+	assert.True(t, controller.HasUpdates())
+	assert.NoError(t, cc.updateClusterConfig(ctx, log, clientset, controller, nil, time.Now()))
+	cfg = clusterConfig()
+	assert.Equal(t, []string{"node1"}, hostnames(cfg))
+	assert.Equal(t, []string{"node1"}, podNodenames(cfg))
+	assert.Equal(t, 3, configMaps.updates)
+
+	assert.False(t, controller.HasUpdates())
+	assert.NoError(t, cc.updateClusterConfig(ctx, log, clientset, controller, nil, time.Now()))
+	assert.Equal(t, 3, configMaps.updates, "unchanged config map must not be updated")
+}
+
+func TestUpdateClusterConfigWithNodeSelector(t *testing.T) {
+	configMaps := &fakeConfigMaps{cm: &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: common.NameClusterConfigMap, Namespace: common.NamespaceKubeSystem},
+		Data:       map[string]string{common.ClusterConfigFilename: "{}\n"},
+	}}
+	clientset := &fakeClientset{core: &fakeCoreV1{
+		configMaps: configMaps,
+		services: &fakeServices{svc: &corev1.Service{Spec: corev1.ServiceSpec{
+			ClusterIP: "100.104.0.1",
+			Ports:     []corev1.ServicePort{{Port: 443}},
+		}}},
+	}}
+	selector := "worker.gardener.cloud/pool in (pool-a,pool-b)"
+	nodeSelector, err := labels.Parse(selector)
+	assert.NoError(t, err)
+	controller := newNodePodController(clientset, time.Hour, nodeSelector, nil, nil)
+	nodes := controller.nodesInformer.Informer().GetIndexer()
+	for i, pool := range []string{"pool-a", "pool-b", "edge"} {
+		node := newClusterNode(fmt.Sprintf("node%d", i+1), fmt.Sprintf("10.0.0.%d", i+1))
+		node.Labels = map[string]string{common.LabelKeyWorkerPool: pool, "other": "x"}
+		transformed, err := controller.transformNode(node)
+		assert.NoError(t, err)
+		assert.NoError(t, nodes.Add(transformed))
+	}
+	cc := &controllerCommand{nodeLabelSelector: selector}
+	ctx := context.Background()
+	log := logrus.New()
+	clusterConfig := func() *config.ClusterConfig {
+		cfg := &config.ClusterConfig{}
+		assert.NoError(t, yaml.Unmarshal([]byte(configMaps.cm.Data[common.ClusterConfigFilename]), cfg))
+		return cfg
+	}
+
+	assert.NoError(t, cc.updateClusterConfig(ctx, log, clientset, controller, nil, time.Now()))
+	cfg := clusterConfig()
+	assert.Equal(t, selector, cfg.NodeSelector)
+	if assert.Len(t, cfg.Nodes, 2) {
+		assert.Equal(t, "node1", cfg.Nodes[0].Hostname)
+		assert.Equal(t, "node2", cfg.Nodes[1].Hostname)
+	}
+
+	// node leaving the selector
+	node2, _, _ := nodes.GetByKey("node2")
+	moved := node2.(*corev1.Node).DeepCopy()
+	moved.Labels[common.LabelKeyWorkerPool] = "edge"
+	assert.NoError(t, nodes.Update(moved))
+	controller.OnUpdate(node2, moved)
+	assert.True(t, controller.HasUpdates())
+	assert.NoError(t, cc.updateClusterConfig(ctx, log, clientset, controller, nil, time.Now()))
+	cfg = clusterConfig()
+	if assert.Len(t, cfg.Nodes, 1) {
+		assert.Equal(t, "node1", cfg.Nodes[0].Hostname)
+	}
+
+	// changes of unselected nodes are irrelevant
+	node3, _, _ := nodes.GetByKey("node3")
+	controller.OnDelete(node3)
+	assert.False(t, controller.HasUpdates())
+}
+
+func TestUpdateClusterConfigOnServiceDeletion(t *testing.T) {
+	configMaps := &fakeConfigMaps{cm: &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: common.NameClusterConfigMap, Namespace: common.NamespaceKubeSystem},
+		Data:       map[string]string{common.ClusterConfigFilename: "{}\n"},
+	}}
+	clientset := &fakeClientset{core: &fakeCoreV1{
+		configMaps: configMaps,
+		services: &fakeServices{svc: &corev1.Service{Spec: corev1.ServiceSpec{
+			ClusterIP: "100.104.0.1",
+			Ports:     []corev1.ServicePort{{Port: 443}},
+		}}},
+	}}
+	serviceSelector, err := labels.Parse(common.DefaultServiceSelector)
+	assert.NoError(t, err)
+	controller := newNodePodController(clientset, time.Hour, nil, nil, serviceSelector)
+	assert.NoError(t, controller.nodesInformer.Informer().GetIndexer().Add(newClusterNode("node1", "10.0.0.1")))
+	services := controller.servicesInformer.Informer().GetIndexer()
+	registry := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "infra", Name: "registry-cache", Labels: map[string]string{"nwpd.gardener.cloud/check": "true"}},
+		Spec:       corev1.ServiceSpec{ClusterIP: "100.104.0.10", Ports: []corev1.ServicePort{{Port: 5000}}},
+	}
+	other := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "infra", Name: "other"},
+		Spec:       corev1.ServiceSpec{ClusterIP: "100.104.0.11", Ports: []corev1.ServicePort{{Port: 80}}},
+	}
+	assert.NoError(t, services.Add(registry))
+	assert.NoError(t, services.Add(other))
+	controller.OnAdd(other)
+	assert.False(t, controller.HasUpdates(), "services not matching the selector are irrelevant")
+	controller.OnAdd(registry)
+	assert.True(t, controller.HasUpdates())
+
+	cc := &controllerCommand{}
+	ctx := context.Background()
+	log := logrus.New()
+	clusterConfig := func() *config.ClusterConfig {
+		cfg := &config.ClusterConfig{}
+		assert.NoError(t, yaml.Unmarshal([]byte(configMaps.cm.Data[common.ClusterConfigFilename]), cfg))
+		return cfg
+	}
+
+	assert.NoError(t, cc.updateClusterConfig(ctx, log, clientset, controller, nil, time.Now()))
+	assert.Equal(t, []config.Endpoint{{Hostname: "svc-infra-registry-cache", IP: "100.104.0.10", Port: 5000}}, clusterConfig().Services)
+
+	assert.NoError(t, services.Delete(registry))
+	controller.OnDelete(registry)
+	assert.True(t, controller.HasUpdates())
+	assert.NoError(t, cc.updateClusterConfig(ctx, log, clientset, controller, nil, time.Now()))
+	assert.Empty(t, clusterConfig().Services)
+}
+
+// fakeNodes serves a large node list to the node informer. The nodes are created on each list call,
+// so that only the informer cache keeps them in memory.
+type fakeNodes struct {
+	typedcorev1.NodeInterface
+	count int
+}
+
+func (f *fakeNodes) List(_ context.Context, _ metav1.ListOptions) (*corev1.NodeList, error) {
+	list := &corev1.NodeList{ListMeta: metav1.ListMeta{ResourceVersion: "1"}}
+	for i := 0; i < f.count; i++ {
+		list.Items = append(list.Items, *newLargeNode(i))
+	}
+	return list, nil
+}
+
+func (f *fakeNodes) Watch(_ context.Context, _ metav1.ListOptions) (watch.Interface, error) {
+	return watch.NewFake(), nil
+}
+
+// newLargeNode creates a node with the typical size of a node in a large cluster.
+func newLargeNode(i int) *corev1.Node {
+	name := fmt.Sprintf("shoot--foo--bar-worker-%d-z1-5b7c9-%05d", i%5, i)
+	node := newClusterNode(name, fmt.Sprintf("10.%d.%d.%d", i/65536, (i/256)%256, i%256))
+	node.UID = types.UID(fmt.Sprintf("uid-%d", i))
+	node.ResourceVersion = "1"
+	node.Labels = map[string]string{
+		corev1.LabelTopologyZone:  "europe-west1-b",
+		common.LabelKeyWorkerPool: fmt.Sprintf("worker-%d", i%5),
+		corev1.LabelHostname:      name,
+		corev1.LabelOSStable:      "linux",
+		corev1.LabelArchStable:    "amd64",
+	}
+	node.Annotations = map[string]string{
+		"node.alpha.kubernetes.io/ttl":                           "0",
+		"volumes.kubernetes.io/controller-managed-attach-detach": "true",
+		"csi.volume.kubernetes.io/nodeid":                        strings.Repeat(`{"pd.csi.storage.gke.io":"projects/foo/zones/europe-west1-b/instances/`+name+`"}`, 2),
+	}
+	node.ManagedFields = []metav1.ManagedFieldsEntry{{
+		Manager:    "kubelet",
+		Operation:  metav1.ManagedFieldsOperationUpdate,
+		APIVersion: "v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(strings.Repeat(`{"f:status":{"f:conditions":{"k:{\"type\":\"Ready\"}":{".":{},"f:lastHeartbeatTime":{}}}}}`, 40))},
+	}}
+	node.Spec.Taints = []corev1.Taint{{Key: "node.kubernetes.io/unschedulable", Effect: corev1.TaintEffectNoSchedule}}
+	node.Status.Addresses = append(node.Status.Addresses, corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "34.76.0.1"})
+	for _, condType := range []corev1.NodeConditionType{corev1.NodeReady, corev1.NodeMemoryPressure, corev1.NodeDiskPressure, corev1.NodePIDPressure, corev1.NodeNetworkUnavailable} {
+		node.Status.Conditions = append(node.Status.Conditions, corev1.NodeCondition{
+			Type:    condType,
+			Status:  corev1.ConditionTrue,
+			Reason:  "KubeletReady",
+			Message: "kubelet is posting ready status. AppArmor enabled",
+		})
+	}
+	for j := 0; j < 30; j++ {
+		node.Status.Images = append(node.Status.Images, corev1.ContainerImage{
+			Names:     []string{fmt.Sprintf("eu.gcr.io/gardener-project/gardener/image-%d@sha256:%064d", j, j)},
+			SizeBytes: 12345678,
+		})
+	}
+	return node
+}
+
+func TestUpdateClusterConfigLargeCluster(t *testing.T) {
+	const (
+		nodeCount = 3000
+		// memoryBudget is the maximum heap growth for the informer cache of all nodes
+		memoryBudget = 16 << 20
+	)
+	configMaps := &fakeConfigMaps{cm: &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: common.NameClusterConfigMap, Namespace: common.NamespaceKubeSystem},
+		Data:       map[string]string{common.ClusterConfigFilename: "{}\n"},
+	}}
+	clientset := &fakeClientset{core: &fakeCoreV1{
+		configMaps: configMaps,
+		services: &fakeServices{svc: &corev1.Service{Spec: corev1.ServiceSpec{
+			ClusterIP: "100.104.0.1",
+			Ports:     []corev1.ServicePort{{Port: 443}},
+		}}},
+		nodes: &fakeNodes{count: nodeCount},
+	}}
+	controller := newNodePodController(clientset, time.Hour, nil, nil, nil)
+	heapAlloc := func() uint64 {
+		var stats runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		return stats.HeapAlloc
+	}
+	before := heapAlloc()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	informer := controller.nodesInformer.Informer()
+	go informer.Run(stopCh)
+	if !assert.True(t, cache.WaitForCacheSync(stopCh, informer.HasSynced)) {
+		return
+	}
+	if after := heapAlloc(); after > before {
+		assert.Less(t, after-before, uint64(memoryBudget), "memory of the informer cache exceeds budget")
+	}
+
+	nodes, err := controller.ListNodes()
+	assert.NoError(t, err)
+	if assert.Len(t, nodes, nodeCount) {
+		assert.Empty(t, nodes[0].ManagedFields)
+		assert.Empty(t, nodes[0].Status.Images)
+		assert.Len(t, nodes[0].Labels, 2)
+		assert.Equal(t, "True", readyStatus(nodes[0]))
+		assert.NotEmpty(t, taintsString(nodes[0]))
+	}
+	pods := controller.podsInformer.Informer().GetIndexer()
+	for _, node := range nodes {
+		assert.NoError(t, pods.Add(newAgentPod("nwpd-agent-pod-net-"+node.Name[len(node.Name)-5:], node.Name)))
+	}
+
+	cc := &controllerCommand{clusterConfigCompressThreshold: deploy.DefaultClusterConfigCompressThreshold}
+	assert.NoError(t, cc.updateClusterConfig(context.Background(), logrus.New(), clientset, controller, nil, time.Now()))
+	cm := configMaps.cm
+	assert.NotContains(t, cm.Data, common.ClusterConfigFilename)
+	compressed := cm.BinaryData[common.ClusterConfigCompressedFilename]
+	assert.NotEmpty(t, compressed)
+	assert.Less(t, len(compressed), deploy.MaxClusterConfigMapDataSize)
+
+	// the agents read the compressed file of the mounted config map
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, common.ClusterConfigCompressedFilename), compressed, 0644))
+	cfg, err := config.LoadClusterConfig(filepath.Join(dir, common.ClusterConfigFilename))
+	if assert.NoError(t, err) {
+		assert.Len(t, cfg.Nodes, nodeCount)
+		assert.Len(t, cfg.PodEndpoints, nodeCount)
+	}
+
+	assert.NoError(t, cc.updateClusterConfig(context.Background(), logrus.New(), clientset, controller, nil, time.Now()))
+	assert.Equal(t, 1, configMaps.updates, "unchanged compressed config map must not be updated")
+}
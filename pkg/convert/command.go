@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package convert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/db"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type convertCommand struct {
+	from      string
+	to        string
+	node      string
+	outputDir string
+}
+
+func CreateConvertCmd() *cobra.Command {
+	cc := &convertCommand{}
+	cmd := &cobra.Command{
+		Use:   "convert <file|directory>...",
+		Short: "convert observation record files to another format",
+		Long: `converts observation record files of the previous release (format 'old' or 'v1' without file header)
+to the format 'v2'. Directories are searched for record files including their direct subdirectories
+(e.g. the output of the 'collect' command). Files already in the target format are skipped.
+The data file prefix and the start hour of the file header are taken from the file name.`,
+		RunE: cc.convert,
+	}
+	cmd.Flags().StringVar(&cc.from, "from", "old", "format of the record files to convert ('old' or 'v1').")
+	cmd.Flags().StringVar(&cc.to, "to", db.FormatV2, "target format ('v2').")
+	cmd.Flags().StringVar(&cc.node, "node", "", "node name stored in the file header (optional).")
+	cmd.Flags().StringVar(&cc.outputDir, "output", "", "directory for the converted files (default: convert in place).")
+	return cmd
+}
+
+func (cc *convertCommand) convert(_ *cobra.Command, args []string) error {
+	log := logrus.WithField("cmd", "convert")
+
+	from, err := db.ParseFormat(cc.from)
+	if err != nil {
+		return err
+	}
+	to, err := db.ParseFormat(cc.to)
+	if err != nil {
+		return err
+	}
+	if from != db.FormatLegacy || to != db.FormatV2 {
+		return fmt.Errorf("unsupported conversion from %s to %s (only from old to %s)", cc.from, cc.to, db.FormatV2)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("missing record files or directories")
+	}
+	if cc.outputDir != "" {
+		if err := os.MkdirAll(cc.outputDir, 0755); err != nil {
+			return err
+		}
+	}
+
+	files, err := cc.recordFiles(args)
+	if err != nil {
+		return err
+	}
+	converted := 0
+	for _, filename := range files {
+		target := ""
+		if cc.outputDir != "" {
+			target = filepath.Join(cc.outputDir, filepath.Base(filename))
+		}
+		ok, err := db.ConvertRecordFile(filename, target, db.RecordFileHeaderFromFilename(filename, cc.node))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log.Infof("skipped %s: already in format %s", filename, db.FormatV2)
+			continue
+		}
+		converted++
+	}
+	log.Infof("converted %d of %d record files", converted, len(files))
+	return nil
+}
+
+func (cc *convertCommand) recordFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		dirFiles, err := db.GetAnyRecordFiles(arg, true)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
@@ -5,12 +5,17 @@
 package deploy
 
 import (
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"path"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -18,6 +23,8 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/pointer"
 	"sigs.k8s.io/yaml"
@@ -30,24 +37,52 @@ import (
 //go:embed DEFAULT_REPOSITORY
 var defaultRepository string
 
+// defaultNetworkChecksPeriod is the period of the controller for reconciling the custom resources NetworkCheck
+const defaultNetworkChecksPeriod = 1 * time.Minute
+
+// defaultFindingEventsPollPeriod is the period of the controller for polling the agents if findings are emitted as events
+const defaultFindingEventsPollPeriod = 1 * time.Minute
+
+// defaultRetentionHours is the default retention of the observations in hours
+const defaultRetentionHours = 4
+
+const (
+	// DefaultClusterConfigCompressThreshold is the size of the marshalled cluster config above which it is stored compressed in the config map
+	DefaultClusterConfigCompressThreshold = 512 * 1024
+	// MaxClusterConfigMapDataSize is the maximum size of the cluster config stored in the config map, leaving room below the limit of 1MiB for the annotations
+	MaxClusterConfigMapDataSize = 900 * 1024
+)
+
 // AgentDeployConfig contains configuration for deploying the nwpd agent daemonset
 type AgentDeployConfig struct {
 	// Image is the image of the network problem detector agent to deploy
 	Image string
 	// DefaultPeriod is the default period for jobs
 	DefaultPeriod time.Duration
-	// PingEnabled if ping checks are enabled (needs NET_ADMIN capabilities)
+	// PingEnabled if ping checks are enabled (needs NET_ADMIN and NET_RAW capabilities for ICMPv4 and ICMPv6 raw sockets)
 	PingEnabled bool
 	// PodSecurityPolicyEnabled if psp should be deployed
 	PodSecurityPolicyEnabled bool
 	// IgnoreAPIServerEndpoint if the check of the API server endpoint should be ignored
 	IgnoreAPIServerEndpoint bool
+	// ShootInfoTimeout is the timeout for looking up the API server endpoint from the Gardener shoot info
+	ShootInfoTimeout time.Duration
 	// PriorityClassName is the priority class name used for the daemon sets
 	PriorityClassName string
 	// K8sExporterEnabled if node conditions and events should be updated/created
 	K8sExporterEnabled bool
 	// K8sExporterHeartbeat if K8sExporterEnabled sets the period of updating the node condition `ClusterNetworkProblems` or `HostNetworkProblems`
 	K8sExporterHeartbeat time.Duration
+	// EventExporterEnabled if the failed checks should be summarized as events per node, category and window
+	EventExporterEnabled bool
+	// EventExporterWindow is the window summarized by the events of the event exporter
+	EventExporterWindow time.Duration
+	// EventExporterCategories restricts the categories of the event exporter (default all)
+	EventExporterCategories []string
+	// EventExporterMaxEvents is the maximum number of events per window and agent of the event exporter
+	EventExporterMaxEvents int
+	// EventExporterMaxMessageSize is the maximum size of the event messages of the event exporter
+	EventExporterMaxMessageSize int
 	// AdditionalAnnotations adds annotations to the daemonset spec template
 	AdditionalAnnotations map[string]string
 	// AdditionalLabels adds labels to the daemonset spec template
@@ -55,6 +90,136 @@ type AgentDeployConfig struct {
 	// DisableAutomountServiceAccountTokenForAgents controls if automountServiceAccountToken should always be false for agents as it is provided
 	// by other means (e.g. https://github.com/gardener/gardener/blob/eb8400a2961400a8b984252a76eb546ea44432fd/docs/concepts/resource-manager.md#auto-mounting-projected-serviceaccount-tokens)
 	DisableAutomountServiceAccountTokenForAgents bool
+	// AgentResources overrides the resource requirements of the agent containers if set
+	AgentResources *corev1.ResourceRequirements
+	// ControllerResources overrides the resource requirements of the controller container if set
+	ControllerResources *corev1.ResourceRequirements
+	// AdditionalTolerations are added to the tolerations of the daemon sets, which tolerate all NoSchedule and NoExecute taints by default
+	AdditionalTolerations []corev1.Toleration
+	// EnabledJobs restricts the default jobs to the ones matching any of these job ID prefixes or glob patterns (if not empty)
+	EnabledJobs []string
+	// DisabledJobs removes the default jobs matching any of these job ID prefixes or glob patterns
+	DisabledJobs []string
+	// IMDSCheckEnabled if the reachability of the instance metadata service should be checked from the host network
+	IMDSCheckEnabled bool
+	// EchoCheckEnabled if the host network agents provide a TCP echo handler and the nodes are checked with 'checkTCPPort --echo'
+	// from both networks to detect paths silently dropping data of established connections
+	EchoCheckEnabled bool
+	// APIServerAuthCheckEnabled if the pod network agents check authenticated requests to the kube-apiserver with their
+	// service account token (job 'auth-p2api-int'). The token is mounted unless DisableAutomountServiceAccountTokenForAgents is set.
+	APIServerAuthCheckEnabled bool
+	// ExternalIPCheckEnabled if the nodes are checked by their external IPs in addition to their internal IPs from both
+	// networks (jobs 'tcp-n2n-ext' and 'tcp-p2n-ext', and 'ping-n2n-ext' and 'ping-p2n-ext' if ping is enabled)
+	ExternalIPCheckEnabled bool
+	// ProxyRulesCheckEnabled if the host network agents count the iptables rules and IPVS services programmed by kube-proxy
+	// (job 'proxy-rules-n', needs the NET_ADMIN capability)
+	ProxyRulesCheckEnabled bool
+	// IMDSEndpoint is the address of the instance metadata service in the format <ip>:<port>
+	IMDSEndpoint string
+	// CABundleConfigMap is the name of a config map in the namespace kube-system with the CA bundle used by the TLS-based checks
+	CABundleConfigMap string
+	// CABundleSecret is the name of a secret in the namespace kube-system with the CA bundle used by the TLS-based checks
+	CABundleSecret string
+	// CABundleKey is the key of the CA bundle in the config map or secret
+	CABundleKey string
+	// CABundleAppendToSystemPool if the CA bundle is added to the system pool instead of replacing it
+	CABundleAppendToSystemPool bool
+	// ExposedHostPort if != 0, the GRPC server of the host network agent uses this port and it is exposed as host port
+	// to allow reachability tests from outside the cluster
+	ExposedHostPort int
+	// RestartOnConfigChange if true, the pod templates of the daemon sets are annotated with a hash of the agent config,
+	// so that a changed config triggers a rolling restart of the agents
+	RestartOnConfigChange bool
+	// ImmutableConfig if true, the agent config map is immutable and its name is suffixed with the config generation.
+	// The daemon sets reference the current config map, so that a changed config triggers a rolling restart of the agents.
+	ImmutableConfig bool
+	// NodeLabelSelector is an optional label selector restricting the nodes running the agents and the known nodes of the
+	// cluster config, e.g. to the node pools managed by Gardener. Empty for all nodes.
+	NodeLabelSelector string
+	// LoadBalancerSelector is an optional label selector for services of type LoadBalancer to include in the hairpin check
+	// in addition to the services annotated with common.AnnotationCheckLoadBalancer
+	LoadBalancerSelector string
+	// ServiceSelector is the label selector for services to check from the pod network by their cluster IP. Empty to disable.
+	ServiceSelector string
+	// IngressEndpoints are external ingress endpoints in the format <name>=<host>:<port> checked from both networks
+	IngressEndpoints []string
+	// JobLabels are labels for the jobs in the format <job ID prefix or glob pattern>:<name>=<value>
+	JobLabels []string
+	// AgentCommand overrides the command of the agent containers (default: /nwpdcli run-agent), e.g. for custom images with a different entrypoint
+	AgentCommand []string
+	// AgentArgs are additional arguments of the agent containers
+	AgentArgs []string
+	// OmitAgentFlags if true, the computed flags (network and config file paths) are not appended to the command of the agent containers
+	OmitAgentFlags bool
+	// HeadlessServiceEnabled if a headless service should be deployed for each daemon set in addition to the
+	// load-balanced service, so that each agent gets a DNS record
+	HeadlessServiceEnabled bool
+	// EndpointsFromURL is an optional URL of an endpoint list loaded periodically by the controller and checked from both networks
+	EndpointsFromURL string
+	// EndpointsRefreshInterval is the refresh interval of the endpoint list loaded from EndpointsFromURL
+	EndpointsRefreshInterval time.Duration
+	// NetworkChecksEnabled if the controller reconciles the custom resources NetworkCheck into the agent config.
+	// The CRD must be installed separately.
+	NetworkChecksEnabled bool
+	// FindingEventsEnabled if the controller polls the agents and emits sustained failing edges and asymmetric links
+	// as Kubernetes events on the affected nodes.
+	FindingEventsEnabled bool
+	// PodHostPathDisabled if the daemon set in the pod network should not mount host paths. Its observations are forwarded
+	// to the agent in the host network on the same node, which stores them in its output directory.
+	PodHostPathDisabled bool
+	// NetNSChecksEnabled if the agent in the host network may run checks in other network namespaces (option --netns).
+	// The /proc directory of the host is mounted read-only and the capabilities SYS_ADMIN and SYS_PTRACE are added.
+	NetNSChecksEnabled bool
+	// ExistingServiceAccount is the name of a pre-provisioned service account in the kube-system namespace used by the agents
+	// and the controller. If set, no service accounts and RBAC objects (cluster roles, roles and their bindings) are created.
+	ExistingServiceAccount string
+	// RetentionHours is the retention of the observations in hours (default 4 if 0)
+	RetentionHours int
+	// HostNetworkRetentionHours overrides RetentionHours for the host network if != 0
+	HostNetworkRetentionHours int
+	// PodNetworkRetentionHours overrides RetentionHours for the pod network if != 0
+	PodNetworkRetentionHours int
+	// LogDroppingFactor is the fraction of observations dropped from the log if observations are logged
+	LogDroppingFactor float64
+	// HostNetworkLogDroppingFactor overrides LogDroppingFactor for the host network if >= 0
+	HostNetworkLogDroppingFactor float64
+	// PodNetworkLogDroppingFactor overrides LogDroppingFactor for the pod network if >= 0
+	PodNetworkLogDroppingFactor float64
+	// VPAEnabled if VerticalPodAutoscalers should be deployed for the daemon sets and the controller
+	VPAEnabled bool
+	// VPAUpdateMode is the update mode of the VerticalPodAutoscalers (default Off)
+	VPAUpdateMode string
+	// VPARequired fails the deployment if the VerticalPodAutoscaler CRD is missing instead of skipping the VPAs
+	VPARequired bool
+}
+
+// deniedHostPorts are well-known ports on the nodes which must not be used as exposed host port.
+var deniedHostPorts = []struct{ min, max int }{
+	{22, 22},       // SSH
+	{53, 53},       // DNS
+	{111, 111},     // rpcbind
+	{179, 179},     // BGP (Calico)
+	{2379, 2380},   // etcd
+	{4789, 4789},   // VXLAN
+	{6443, 6443},   // kube-apiserver
+	{9099, 9099},   // Calico felix
+	{10248, 10259}, // kubelet, kube-proxy, kube-controller-manager, kube-scheduler
+	{30000, 32767}, // default node port range
+}
+
+func validateExposedHostPort(port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("invalid exposed host port %d", port)
+	}
+	if port == common.HostNetPodHttpPort {
+		return fmt.Errorf("exposed host port %d collides with the metrics port of the host network agent", port)
+	}
+	for _, r := range deniedHostPorts {
+		if port >= r.min && port <= r.max {
+			return fmt.Errorf("exposed host port %d collides with well-known node ports %d-%d", port, r.min, r.max)
+		}
+	}
+	return nil
 }
 
 // DeployNetworkProblemDetectorAgent returns K8s resources to be created.
@@ -73,6 +238,13 @@ func DeployNetworkProblemDetectorAgent(config *AgentDeployConfig) ([]Object, err
 			return nil, err
 		}
 		objects = append(objects, svc)
+		if config.HeadlessServiceEnabled {
+			headless, err := config.buildHeadlessService(hostnetwork)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, headless)
+		}
 		ds, err := config.buildDaemonSet(serviceAccountName, hostnetwork)
 		if err != nil {
 			return nil, err
@@ -80,7 +252,7 @@ func DeployNetworkProblemDetectorAgent(config *AgentDeployConfig) ([]Object, err
 		objects = append(objects, ds)
 	}
 
-	return objects, nil
+	return config.appendVPAs(objects)
 }
 
 func (ac *AgentDeployConfig) AddImageFlag(imageTag string, flags *pflag.FlagSet) {
@@ -94,38 +266,76 @@ func (ac *AgentDeployConfig) AddOptionFlags(flags *pflag.FlagSet) {
 	flags.BoolVar(&ac.PodSecurityPolicyEnabled, "enable-psp", true, "if pod security policy should be deployed")
 	flags.BoolVar(&ac.K8sExporterEnabled, "enable-k8s-exporter", false, "if node conditions and events should be updated/created")
 	flags.DurationVar(&ac.K8sExporterHeartbeat, "k8s-exporter-heartbeat", 3*time.Minute, "period for updating the node conditions by the K8s exporter")
+	flags.BoolVar(&ac.EventExporterEnabled, "enable-event-exporter", false, "if the failed checks of each agent should be summarized as events of its node per category and window")
+	flags.DurationVar(&ac.EventExporterWindow, "event-exporter-window", config.DefaultEventExporterWindow, "window summarized by the events of the event exporter")
+	flags.StringSliceVar(&ac.EventExporterCategories, "event-exporter-categories", nil, "categories of the event exporter: 'nodes', 'ingress' or 'external' (default all)")
+	flags.IntVar(&ac.EventExporterMaxEvents, "event-exporter-max-events", config.DefaultEventExporterMaxEvents, "maximum number of events created or updated per window by each agent")
+	flags.IntVar(&ac.EventExporterMaxMessageSize, "event-exporter-max-message-size", config.DefaultEventExporterMaxMessageSize, "maximum size of the event messages in bytes")
 	flags.BoolVar(&ac.IgnoreAPIServerEndpoint, "ignore-gardener-kube-api-server", false, "if true, does not try to lookup kube api-server of Gardener control plane")
+	flags.DurationVar(&ac.ShootInfoTimeout, "shoot-info-timeout", 1*time.Minute, "timeout for looking up the kube api-server of Gardener control plane (incl. DNS lookup retries)")
 	flags.StringVar(&ac.PriorityClassName, "priority-class", "", "priority class name")
+	flags.StringSliceVar(&ac.EnabledJobs, "enable-jobs", nil, "if specified, only default jobs with job ID matching any of the given prefixes or glob patterns are deployed")
+	flags.StringSliceVar(&ac.DisabledJobs, "disable-jobs", nil, "default jobs with job ID matching any of the given prefixes or glob patterns are not deployed (e.g. 'ping-*')")
+	flags.BoolVar(&ac.IMDSCheckEnabled, "enable-imds-check", false, "if the TCP connection to the instance metadata service should be checked from the host network")
+	flags.BoolVar(&ac.EchoCheckEnabled, "enable-echo-check", false, fmt.Sprintf("if the host network agents should provide a TCP echo handler on port %d and the nodes should be checked for blackholing paths with 'checkTCPPort --echo' from both networks", common.HostNetPodEchoPort))
+	flags.BoolVar(&ac.APIServerAuthCheckEnabled, "enable-api-server-auth-check", false, "if the pod network agents should check authenticated requests to the kube-apiserver with their service account token (mounts the token)")
+	flags.BoolVar(&ac.ExternalIPCheckEnabled, "enable-external-ip-check", false, "if the nodes should be checked by their external IPs in addition to their internal IPs from both networks (nodes without external IP are skipped)")
+	flags.BoolVar(&ac.ProxyRulesCheckEnabled, "enable-proxy-rules-check", false, "if the host network agents should count the iptables rules and IPVS services programmed by kube-proxy and check them for anomalies (adds the capability NET_ADMIN)")
+	flags.IntVar(&ac.ExposedHostPort, "expose-host-port", 0, "if != 0, the GRPC server of the host network agent uses this port and it is exposed as host port for reachability tests from outside the cluster. Firewall rules must allow ingress traffic to this port on the nodes.")
+	flags.BoolVar(&ac.ImmutableConfig, "immutable-config", false, "if true, the agent config is deployed as immutable config map versioned by the config generation, outdated versions are deleted (implies restart on config change)")
+	flags.BoolVar(&ac.RestartOnConfigChange, "restart-on-config-change", false, "if true, the agents are restarted on changes of the agent config (by default, the agents reload it without restart)")
+	flags.StringVar(&ac.NodeLabelSelector, "node-label-selector", "", "if set, the agents only run on nodes matching this label selector and only these nodes are checked (e.g. 'worker.gardener.cloud/pool in (a,b)')")
+	flags.StringVar(&ac.LoadBalancerSelector, "load-balancer-selector", "", "label selector for services of type LoadBalancer to check in addition to the annotated ones (e.g. 'app=ingress')")
+	flags.StringVar(&ac.ServiceSelector, "service-selector", common.DefaultServiceSelector, "label selector for services to check from the pod network by their cluster IP (empty to disable)")
+	flags.StringSliceVar(&ac.JobLabels, "job-labels", nil, "labels for the jobs in the format <job ID prefix or glob pattern>:<name>=<value> (e.g. 'tcp-*2api-ext:tier=external')")
+	flags.StringSliceVar(&ac.IngressEndpoints, "ingress-endpoints", nil, "ingress endpoints to check from both networks in the format <name>=<host>:<port> (HTTPS is checked additionally for port 443)")
+	flags.StringSliceVar(&ac.AgentCommand, "agent-command", nil, "overrides the command of the agent containers for custom images (default '/nwpdcli,run-agent'). The flags for network and config files are appended unless --omit-agent-flags is set.")
+	flags.StringSliceVar(&ac.AgentArgs, "agent-args", nil, "additional arguments of the agent containers")
+	flags.BoolVar(&ac.OmitAgentFlags, "omit-agent-flags", false, "if true, the flags for network and config files are not appended to the command of the agent containers")
+	flags.BoolVar(&ac.HeadlessServiceEnabled, "enable-headless-service", false, "if a headless service should be deployed for each daemon set, so that each agent is addressable by DNS")
+	flags.StringVar(&ac.EndpointsFromURL, "endpoints-from-url", "", "if set, the controller loads an endpoint list from this URL periodically and the endpoints are checked from both networks")
+	flags.DurationVar(&ac.EndpointsRefreshInterval, "endpoints-refresh-interval", 5*time.Minute, "refresh interval of the endpoint list loaded from the URL given by --endpoints-from-url")
+	flags.BoolVar(&ac.NetworkChecksEnabled, "enable-network-checks", false, "if the controller should add the jobs declared by the custom resources NetworkCheck to the agent config (the CRD must be installed separately)")
+	flags.BoolVar(&ac.FindingEventsEnabled, "enable-finding-events", false, "if the controller should poll the agents and emit sustained failing edges and asymmetric links as Kubernetes events on the affected nodes")
+	flags.BoolVar(&ac.PodHostPathDisabled, "disable-pod-host-path", false, "if true, the daemon set in the pod network mounts no host paths and forwards its observations to the host network agent on the same node")
+	flags.BoolVar(&ac.NetNSChecksEnabled, "enable-netns-checks", false, "if true, the daemon set in the host network mounts the host /proc directory and gets the capabilities to run checks with option --netns in other network namespaces")
+	flags.StringVar(&ac.ExistingServiceAccount, "existing-service-account", "", "if set, the agents and the controller use this pre-provisioned service account in the namespace kube-system and no service accounts and RBAC objects are created")
+	flags.IntVar(&ac.RetentionHours, "retention-hours", defaultRetentionHours, fmt.Sprintf("retention of the observations in hours (%d-%d)", config.MinRetentionHours, config.MaxRetentionHours))
+	flags.IntVar(&ac.HostNetworkRetentionHours, "host-network-retention-hours", 0, "if != 0, overrides the retention of the observations in hours for the host network")
+	flags.IntVar(&ac.PodNetworkRetentionHours, "pod-network-retention-hours", 0, "if != 0, overrides the retention of the observations in hours for the pod network")
+	flags.Float64Var(&ac.LogDroppingFactor, "log-dropping-factor", 0, "fraction of observations randomly dropped from the log if observations are logged (0-1)")
+	flags.Float64Var(&ac.HostNetworkLogDroppingFactor, "host-network-log-dropping-factor", -1, "if >= 0, overrides the log dropping factor for the host network")
+	flags.Float64Var(&ac.PodNetworkLogDroppingFactor, "pod-network-log-dropping-factor", -1, "if >= 0, overrides the log dropping factor for the pod network")
+	flags.BoolVar(&ac.VPAEnabled, "enable-vpa", false, "if VerticalPodAutoscalers (autoscaling.k8s.io/v1) should be deployed for the daemon sets and the controller. The requests are bounded by the configured requests and limits.")
+	flags.StringVar(&ac.VPAUpdateMode, "vpa-update-mode", VPAUpdateModeOff, "update mode of the VerticalPodAutoscalers: 'Off' (recommendations only), 'Initial', 'Recreate' or 'Auto'")
+	flags.BoolVar(&ac.VPARequired, "vpa-required", false, "if true, the deployment fails if the VerticalPodAutoscaler CRD is not installed (by default, the VPAs are skipped with a warning)")
+	flags.StringVar(&ac.CABundleConfigMap, "ca-bundle-config-map", "", "if set, the CA bundle for verifying the server certificates of the TLS-based checks is mounted from this config map in the namespace kube-system")
+	flags.StringVar(&ac.CABundleSecret, "ca-bundle-secret", "", "if set, the CA bundle for verifying the server certificates of the TLS-based checks is mounted from this secret in the namespace kube-system")
+	flags.StringVar(&ac.CABundleKey, "ca-bundle-key", common.CABundleFilename, "key of the PEM encoded CA certificates in the config map or secret of the CA bundle")
+	flags.BoolVar(&ac.CABundleAppendToSystemPool, "ca-bundle-append-to-system-pool", false, "if true, the CA bundle is added to the system CA certificates instead of replacing them")
+	flags.StringVar(&ac.IMDSEndpoint, "imds-endpoint", common.DefaultIMDSEndpoint, "IPv4 address of the instance metadata service in the format <ip>:<port> (depends on cloud provider, e.g. '100.100.100.200:80' on Alibaba Cloud)")
 }
 
 func (ac *AgentDeployConfig) buildService(hostnetwork bool) (*corev1.Service, error) {
-	name, _, _ := ac.getNetworkConfig(hostnetwork)
+	name := ac.getDaemonSetName(hostnetwork)
+	var ports []corev1.ServicePort
+	for _, p := range ac.agentPorts(hostnetwork) {
+		if p.InService() {
+			ports = append(ports, corev1.ServicePort{
+				Name:       p.Name,
+				Protocol:   corev1.Protocol(p.Protocol),
+				Port:       int32(p.ServicePort),
+				TargetPort: intstr.FromString(p.Name),
+			})
+		}
+	}
 	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
 			Namespace: common.NamespaceKubeSystem,
 		},
 		Spec: corev1.ServiceSpec{
-			Ports: []corev1.ServicePort{
-				{
-					Name:     "grpc",
-					Protocol: corev1.ProtocolTCP,
-					Port:     80,
-					TargetPort: intstr.IntOrString{
-						Type:   intstr.String,
-						StrVal: "grpc",
-					},
-				},
-				{
-					Name:     "metrics",
-					Protocol: corev1.ProtocolTCP,
-					Port:     8080,
-					TargetPort: intstr.IntOrString{
-						Type:   intstr.String,
-						StrVal: "metrics",
-					},
-				},
-			},
+			Ports:    ports,
 			Selector: ac.getLabels(name),
 			Type:     corev1.ServiceTypeClusterIP,
 		},
@@ -133,6 +343,41 @@ func (ac *AgentDeployConfig) buildService(hostnetwork bool) (*corev1.Service, er
 	return svc, nil
 }
 
+// buildHeadlessService builds a service without cluster IP selecting the pods of the daemon set.
+// The cluster DNS provides a record for each agent in the format <pod-ip-with-dashes>.<service>.kube-system.svc.cluster.local.
+func (ac *AgentDeployConfig) buildHeadlessService(hostnetwork bool) (*corev1.Service, error) {
+	name := ac.getDaemonSetName(hostnetwork)
+	// the ports are used for the SRV records, as there is no port mapping without cluster IP
+	var ports []corev1.ServicePort
+	for _, p := range ac.agentPorts(hostnetwork) {
+		if p.InService() {
+			ports = append(ports, corev1.ServicePort{
+				Name:       p.Name,
+				Protocol:   corev1.Protocol(p.Protocol),
+				Port:       int32(p.Number),
+				TargetPort: intstr.FromInt(p.Number),
+			})
+		}
+	}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      headlessServiceName(name),
+			Namespace: common.NamespaceKubeSystem,
+		},
+		Spec: corev1.ServiceSpec{
+			Ports:     ports,
+			Selector:  ac.getLabels(name),
+			Type:      corev1.ServiceTypeClusterIP,
+			ClusterIP: corev1.ClusterIPNone,
+		},
+	}
+	return svc, nil
+}
+
+func headlessServiceName(name string) string {
+	return name + "-headless"
+}
+
 func (ac *AgentDeployConfig) getLabels(name string) map[string]string {
 	return map[string]string{
 		common.LabelKeyK8sApp: name,
@@ -140,17 +385,75 @@ func (ac *AgentDeployConfig) getLabels(name string) map[string]string {
 	}
 }
 
-func (ac *AgentDeployConfig) getNetworkConfig(hostnetwork bool) (name string, portGRPC, portMetrics int32) {
+func (ac *AgentDeployConfig) getDaemonSetName(hostnetwork bool) string {
 	if hostnetwork {
-		name = common.NameDaemonSetAgentHostNet
-		portGRPC = common.HostNetPodGRPCPort
-		portMetrics = common.HostNetPodHttpPort
-	} else {
-		name = common.NameDaemonSetAgentPodNet
-		portGRPC = common.PodNetPodGRPCPort
-		portMetrics = common.PodNetPodHttpPort
+		return common.NameDaemonSetAgentHostNet
+	}
+	return common.NameDaemonSetAgentPodNet
+}
+
+// agentPorts returns the enabled ports of the agent container of the daemon set with the configured port numbers.
+func (ac *AgentDeployConfig) agentPorts(hostnetwork bool) []common.PortSpec {
+	var ports []common.PortSpec
+	for _, p := range common.AgentPortsOf(hostnetwork) {
+		if p.Optional && !ac.optionalPortEnabled(p.Name) {
+			continue
+		}
+		if hostnetwork && p.Name == common.PortNameGRPC {
+			p.Number = ac.hostNetGRPCPort()
+		}
+		ports = append(ports, p)
+	}
+	return ports
+}
+
+// optionalPortEnabled returns true if the optional port is enabled by the deploy config.
+func (ac *AgentDeployConfig) optionalPortEnabled(name string) bool {
+	switch name {
+	case common.PortNameEcho:
+		return ac.EchoCheckEnabled
+	default:
+		return false
 	}
-	return
+}
+
+// containerPorts returns the container ports of the agent. Only the GRPC port of the host network agent may be exposed as host port.
+func (ac *AgentDeployConfig) containerPorts(hostnetwork bool) []corev1.ContainerPort {
+	var ports []corev1.ContainerPort
+	for _, p := range ac.agentPorts(hostnetwork) {
+		port := corev1.ContainerPort{
+			Name:          p.Name,
+			ContainerPort: int32(p.Number),
+			Protocol:      corev1.Protocol(p.Protocol),
+		}
+		if hostnetwork && p.Name == common.PortNameGRPC && ac.ExposedHostPort != 0 {
+			port.HostPort = port.ContainerPort
+		}
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// applyPorts sets the ports of the network config of the agent config.
+func applyPorts(nc *config.NetworkConfig, ports []common.PortSpec) {
+	for _, p := range ports {
+		switch p.Name {
+		case common.PortNameGRPC:
+			nc.GRPCPort = p.Number
+		case common.PortNameMetrics:
+			nc.HttpPort = p.Number
+		case common.PortNameEcho:
+			nc.EchoPort = p.Number
+		}
+	}
+}
+
+// hostNetGRPCPort returns the port of the GRPC server of the host network agent.
+func (ac *AgentDeployConfig) hostNetGRPCPort() int {
+	if ac.ExposedHostPort != 0 {
+		return ac.ExposedHostPort
+	}
+	return common.HostNetPodGRPCPort
 }
 
 func (ac *AgentDeployConfig) buildDaemonSet(serviceAccountName string, hostNetwork bool) (*appsv1.DaemonSet, error) {
@@ -161,24 +464,68 @@ func (ac *AgentDeployConfig) buildDaemonSet(serviceAccountName string, hostNetwo
 		limitMemory, _         = resource.ParseQuantity("64Mi")
 		defaultMode      int32 = 0444
 	)
-	name, portGRPC, portMetrics := ac.getNetworkConfig(hostNetwork)
+	name := ac.getDaemonSetName(hostNetwork)
+	if hostNetwork && ac.ExposedHostPort != 0 {
+		if err := validateExposedHostPort(ac.ExposedHostPort); err != nil {
+			return nil, err
+		}
+	}
+	affinity, err := ac.nodeAffinity()
+	if err != nil {
+		return nil, err
+	}
+	caBundleVolumeSource, err := ac.caBundleVolumeSource()
+	if err != nil {
+		return nil, err
+	}
 
 	labels := ac.getLabels(name)
 	labelsPlusAdditionalLabels := common.MergeMaps(ac.AdditionalLabels, labels)
 	annotations := common.MergeMaps(ac.AdditionalAnnotations, map[string]string{"check-sum/k8s-exporter": strconv.FormatBool(ac.K8sExporterEnabled)})
+	if ac.EventExporterEnabled {
+		annotations["check-sum/event-exporter"] = "true"
+	}
+	if ac.RestartOnConfigChange {
+		hash, err := ac.agentConfigHash()
+		if err != nil {
+			return nil, err
+		}
+		annotations["check-sum/config"] = hash
+	}
+
+	configMapName, err := ac.agentConfigMapName()
+	if err != nil {
+		return nil, err
+	}
 
 	var capabilities *corev1.Capabilities
-	if ac.PingEnabled {
+	if added := ac.agentCapabilities(hostNetwork); len(added) > 0 {
 		capabilities = &corev1.Capabilities{
-			Add: []corev1.Capability{"NET_ADMIN"},
+			Add: added,
 		}
 	}
 	var automountServiceAccountToken *bool
 	if !ac.DisableAutomountServiceAccountTokenForAgents {
-		automountServiceAccountToken = pointer.Bool(ac.K8sExporterEnabled)
+		automountServiceAccountToken = pointer.Bool(ac.K8sExporterEnabled || ac.EventExporterEnabled || (!hostNetwork && ac.APIServerAuthCheckEnabled))
 	}
 
 	typ := corev1.HostPathDirectoryOrCreate
+	outputVolumeSource := corev1.VolumeSource{
+		HostPath: &corev1.HostPathVolumeSource{
+			Path: common.PathOutputDir,
+			Type: &typ,
+		},
+	}
+	logVolumeSource := corev1.VolumeSource{
+		HostPath: &corev1.HostPathVolumeSource{
+			Path: common.PathLogDir,
+			Type: &typ,
+		},
+	}
+	if !hostNetwork && ac.PodHostPathDisabled {
+		outputVolumeSource = corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+		logVolumeSource = corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+	}
 	ds := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
@@ -200,9 +547,10 @@ func (ac *AgentDeployConfig) buildDaemonSet(serviceAccountName string, hostNetwo
 				},
 				Spec: corev1.PodSpec{
 					HostNetwork:                   hostNetwork,
+					Affinity:                      affinity,
 					PriorityClassName:             ac.PriorityClassName,
 					TerminationGracePeriodSeconds: pointer.Int64(0),
-					Tolerations: []corev1.Toleration{
+					Tolerations: append([]corev1.Toleration{
 						{
 							Effect:   corev1.TaintEffectNoSchedule,
 							Operator: corev1.TolerationOpExists,
@@ -217,20 +565,15 @@ func (ac *AgentDeployConfig) buildDaemonSet(serviceAccountName string, hostNetwo
 							Effect:   corev1.TaintEffectNoExecute,
 							Operator: corev1.TolerationOpExists,
 						},
-					},
+					}, ac.AdditionalTolerations...),
 					AutomountServiceAccountToken: automountServiceAccountToken,
 					ServiceAccountName:           serviceAccountName,
 					Containers: []corev1.Container{{
 						Name:            name,
 						Image:           ac.Image,
 						ImagePullPolicy: imagePullPolicyByImage(ac.Image),
-						Command: []string{
-							"/nwpdcli",
-							"run-agent",
-							fmt.Sprintf("--hostNetwork=%t", hostNetwork),
-							"--config=/config/agent/" + common.AgentConfigFilename,
-							"--cluster-config=/config/cluster/" + common.ClusterConfigFilename,
-						},
+						Command:         ac.agentCommand(hostNetwork),
+						Args:            ac.AgentArgs,
 						Env: []corev1.EnvVar{
 							{
 								Name: common.EnvNodeName,
@@ -257,28 +600,18 @@ func (ac *AgentDeployConfig) buildDaemonSet(serviceAccountName string, hostNetwo
 								},
 							},
 						},
-						Ports: []corev1.ContainerPort{
-							{
-								Name:          "grpc",
-								ContainerPort: portGRPC,
-								Protocol:      "TCP",
-							},
-							{
-								Name:          "metrics",
-								ContainerPort: portMetrics,
-								Protocol:      "TCP",
-							},
-						},
-						Resources: corev1.ResourceRequirements{
-							Requests: corev1.ResourceList{
-								corev1.ResourceCPU:    requestCPU,
-								corev1.ResourceMemory: requestMemory,
-							},
-							Limits: corev1.ResourceList{
-								corev1.ResourceCPU:    limitCPU,
-								corev1.ResourceMemory: limitMemory,
+						Ports: ac.containerPorts(hostNetwork),
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								HTTPGet: &corev1.HTTPGetAction{
+									Path: "/readyz",
+									Port: intstr.FromString(common.PortNameMetrics),
+								},
 							},
+							PeriodSeconds:    10,
+							FailureThreshold: 3,
 						},
+						Resources: resourcesOrDefault(ac.AgentResources, requestCPU, requestMemory, limitCPU, limitMemory),
 						SecurityContext: &corev1.SecurityContext{
 							Capabilities: capabilities,
 						},
@@ -307,33 +640,27 @@ func (ac *AgentDeployConfig) buildDaemonSet(serviceAccountName string, hostNetwo
 					}},
 					Volumes: []corev1.Volume{
 						{
-							Name: "output",
-							VolumeSource: corev1.VolumeSource{
-								HostPath: &corev1.HostPathVolumeSource{
-									Path: common.PathOutputDir,
-									Type: &typ,
-								},
-							},
+							Name:         "output",
+							VolumeSource: outputVolumeSource,
 						},
 						{
-							Name: "log",
-							VolumeSource: corev1.VolumeSource{
-								HostPath: &corev1.HostPathVolumeSource{
-									Path: common.PathLogDir,
-									Type: &typ,
-								},
-							},
+							Name:         "log",
+							VolumeSource: logVolumeSource,
 						},
 						{
 							Name: "agent-config",
 							VolumeSource: corev1.VolumeSource{
 								ConfigMap: &corev1.ConfigMapVolumeSource{
-									LocalObjectReference: corev1.LocalObjectReference{Name: common.NameAgentConfigMap},
+									LocalObjectReference: corev1.LocalObjectReference{Name: configMapName},
 									Items: []corev1.KeyToPath{
 										{
 											Key:  common.AgentConfigFilename,
 											Path: common.AgentConfigFilename,
 										},
+										{
+											Key:  common.AgentConfigLastKnownGoodFilename,
+											Path: common.AgentConfigLastKnownGoodFilename,
+										},
 									},
 									DefaultMode: &defaultMode,
 								},
@@ -342,14 +669,8 @@ func (ac *AgentDeployConfig) buildDaemonSet(serviceAccountName string, hostNetwo
 						{
 							Name: "cluster-config",
 							VolumeSource: corev1.VolumeSource{
-								ConfigMap: &corev1.ConfigMapVolumeSource{
-									LocalObjectReference: corev1.LocalObjectReference{Name: common.NameClusterConfigMap},
-									Items: []corev1.KeyToPath{
-										{
-											Key:  common.ClusterConfigFilename,
-											Path: common.ClusterConfigFilename,
-										},
-									},
+								Projected: &corev1.ProjectedVolumeSource{
+									Sources:     clusterConfigVolumeProjections(),
 									DefaultMode: &defaultMode,
 								},
 							},
@@ -360,9 +681,159 @@ func (ac *AgentDeployConfig) buildDaemonSet(serviceAccountName string, hostNetwo
 		},
 	}
 
+	if hostNetwork && ac.NetNSChecksEnabled {
+		spec := &ds.Spec.Template.Spec
+		spec.Containers[0].VolumeMounts = append(spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "host-proc",
+			ReadOnly:  true,
+			MountPath: common.PathHostProc,
+		})
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name: "host-proc",
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: "/proc",
+				},
+			},
+		})
+	}
+
+	if caBundleVolumeSource != nil {
+		spec := &ds.Spec.Template.Spec
+		spec.Containers[0].VolumeMounts = append(spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "ca-bundle",
+			ReadOnly:  true,
+			MountPath: common.PathCABundleDir,
+		})
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name:         "ca-bundle",
+			VolumeSource: *caBundleVolumeSource,
+		})
+	}
+
 	return ds, nil
 }
 
+// caBundleEnabled returns true if a CA bundle is mounted from a config map or secret.
+func (ac *AgentDeployConfig) caBundleEnabled() bool {
+	return ac.CABundleConfigMap != "" || ac.CABundleSecret != ""
+}
+
+// caBundleVolumeSource returns the volume source of the CA bundle mounted as common.CABundleFilename, nil if not enabled.
+func (ac *AgentDeployConfig) caBundleVolumeSource() (*corev1.VolumeSource, error) {
+	if !ac.caBundleEnabled() {
+		return nil, nil
+	}
+	if ac.CABundleConfigMap != "" && ac.CABundleSecret != "" {
+		return nil, fmt.Errorf("the CA bundle can either be mounted from a config map or a secret")
+	}
+	if ac.CABundleKey == "" {
+		return nil, fmt.Errorf("missing key of the CA bundle")
+	}
+	var defaultMode int32 = 0444
+	items := []corev1.KeyToPath{{Key: ac.CABundleKey, Path: common.CABundleFilename}}
+	if ac.CABundleSecret != "" {
+		return &corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: ac.CABundleSecret, Items: items, DefaultMode: &defaultMode},
+		}, nil
+	}
+	return &corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: ac.CABundleConfigMap},
+			Items:                items,
+			DefaultMode:          &defaultMode,
+		},
+	}, nil
+}
+
+// clusterConfigVolumeProjections returns the projections of the cluster config map and its optional shard config maps.
+// All keys are mounted, as the cluster config and the shards are either stored plain or compressed.
+func clusterConfigVolumeProjections() []corev1.VolumeProjection {
+	projections := []corev1.VolumeProjection{{
+		ConfigMap: &corev1.ConfigMapProjection{
+			LocalObjectReference: corev1.LocalObjectReference{Name: common.NameClusterConfigMap},
+		},
+	}}
+	for i := 0; i < MaxClusterConfigShards; i++ {
+		projections = append(projections, corev1.VolumeProjection{
+			ConfigMap: &corev1.ConfigMapProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: ClusterConfigShardMapName(i)},
+				Optional:             pointer.Bool(true),
+			},
+		})
+	}
+	return projections
+}
+
+// agentCapabilities returns the capabilities to add to the agent container.
+// resourcesOrDefault returns the given resource requirements or the default requests and limits if not set.
+func resourcesOrDefault(resources *corev1.ResourceRequirements, requestCPU, requestMemory, limitCPU, limitMemory resource.Quantity) corev1.ResourceRequirements {
+	if resources != nil {
+		return *resources
+	}
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    requestCPU,
+			corev1.ResourceMemory: requestMemory,
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    limitCPU,
+			corev1.ResourceMemory: limitMemory,
+		},
+	}
+}
+
+func (ac *AgentDeployConfig) agentCapabilities(hostNetwork bool) []corev1.Capability {
+	var capabilities []corev1.Capability
+	if ac.PingEnabled {
+		capabilities = append(capabilities, "NET_ADMIN", "NET_RAW")
+	} else if hostNetwork && ac.ProxyRulesCheckEnabled {
+		capabilities = append(capabilities, "NET_ADMIN")
+	}
+	if hostNetwork && ac.NetNSChecksEnabled {
+		capabilities = append(capabilities, "SYS_ADMIN", "SYS_PTRACE")
+	}
+	return capabilities
+}
+
+// agentCommand returns the command of the agent container with the computed flags appended unless they are omitted.
+func (ac *AgentDeployConfig) agentCommand(hostNetwork bool) []string {
+	command := []string{"/nwpdcli", "run-agent"}
+	if len(ac.AgentCommand) > 0 {
+		command = append([]string{}, ac.AgentCommand...)
+	}
+	if ac.OmitAgentFlags {
+		return command
+	}
+	return append(command,
+		fmt.Sprintf("--hostNetwork=%t", hostNetwork),
+		"--config=/config/agent/"+common.AgentConfigFilename,
+		"--cluster-config=/config/cluster/"+common.ClusterConfigFilename,
+	)
+}
+
+func (ac *AgentDeployConfig) controllerCommand() []string {
+	command := []string{"/nwpdcli", "run-controller", "--in-cluster"}
+	if ac.NodeLabelSelector != "" {
+		command = append(command, "--node-label-selector="+ac.NodeLabelSelector)
+	}
+	if ac.LoadBalancerSelector != "" {
+		command = append(command, "--load-balancer-selector="+ac.LoadBalancerSelector)
+	}
+	command = append(command, "--service-selector="+ac.ServiceSelector)
+	if ac.EndpointsFromURL != "" {
+		command = append(command, "--endpoints-from-url="+ac.EndpointsFromURL,
+			"--endpoints-refresh-interval="+ac.EndpointsRefreshInterval.String())
+	}
+	if ac.NetworkChecksEnabled {
+		command = append(command, "--network-checks-period="+defaultNetworkChecksPeriod.String())
+	}
+	if ac.FindingEventsEnabled {
+		command = append(command, "--poll-period="+defaultFindingEventsPollPeriod.String(), "--finding-events")
+	}
+	return command
+}
+
 func (ac *AgentDeployConfig) buildControllerDeployment() (*appsv1.Deployment, *rbacv1.ClusterRole, *rbacv1.ClusterRoleBinding,
 	*rbacv1.Role, *rbacv1.RoleBinding, *corev1.ServiceAccount, error) {
 	var (
@@ -375,6 +846,9 @@ func (ac *AgentDeployConfig) buildControllerDeployment() (*appsv1.Deployment, *r
 	name := common.NameDeploymentAgentController
 	labels := ac.getLabels(name)
 	serviceAccountName := name
+	if ac.ExistingServiceAccount != "" {
+		serviceAccountName = ac.ExistingServiceAccount
+	}
 
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -401,17 +875,8 @@ func (ac *AgentDeployConfig) buildControllerDeployment() (*appsv1.Deployment, *r
 						Name:            name,
 						Image:           ac.Image,
 						ImagePullPolicy: imagePullPolicyByImage(ac.Image),
-						Command:         []string{"/nwpdcli", "run-controller", "--in-cluster"},
-						Resources: corev1.ResourceRequirements{
-							Requests: corev1.ResourceList{
-								corev1.ResourceCPU:    requestCPU,
-								corev1.ResourceMemory: requestMemory,
-							},
-							Limits: corev1.ResourceList{
-								corev1.ResourceCPU:    limitCPU,
-								corev1.ResourceMemory: limitMemory,
-							},
-						},
+						Command:         ac.controllerCommand(),
+						Resources: resourcesOrDefault(ac.ControllerResources, requestCPU, requestMemory, limitCPU, limitMemory),
 						SecurityContext: &corev1.SecurityContext{
 							RunAsUser:  pointer.Int64(65534),
 							RunAsGroup: pointer.Int64(65534),
@@ -434,13 +899,44 @@ func (ac *AgentDeployConfig) buildControllerDeployment() (*appsv1.Deployment, *r
 				Resources: []string{"nodes"},
 			},
 			{
-				APIGroups:     []string{""},
-				Verbs:         []string{"get"},
-				Resources:     []string{"services"},
-				ResourceNames: []string{common.NameKubernetesService},
+				APIGroups: []string{""},
+				Verbs:     []string{"get", "list", "watch"},
+				Resources: []string{"services"},
+			},
+			{
+				APIGroups: []string{"discovery.k8s.io"},
+				Verbs:     []string{"get", "list"},
+				Resources: []string{"endpointslices"},
+			},
+			{
+				APIGroups: []string{""},
+				Verbs:     []string{"list"},
+				Resources: []string{"pods"},
 			},
 		},
 	}
+	if ac.NetworkChecksEnabled {
+		clusterRole.Rules = append(clusterRole.Rules,
+			rbacv1.PolicyRule{
+				APIGroups: []string{config.NetworkCheckGroup},
+				Verbs:     []string{"get", "list"},
+				Resources: []string{config.NetworkCheckResource},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{config.NetworkCheckGroup},
+				Verbs:     []string{"update"},
+				Resources: []string{config.NetworkCheckResource + "/status"},
+			},
+		)
+	}
+	if ac.FindingEventsEnabled {
+		// events of nodes are created in the namespace default
+		clusterRole.Rules = append(clusterRole.Rules, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Verbs:     []string{"create", "patch"},
+			Resources: []string{"events"},
+		})
+	}
 	clusterRoleBinding := &rbacv1.ClusterRoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: roleName,
@@ -473,7 +969,13 @@ func (ac *AgentDeployConfig) buildControllerDeployment() (*appsv1.Deployment, *r
 				APIGroups:     []string{""},
 				Verbs:         []string{"get", "update", "patch"},
 				Resources:     []string{"configmaps"},
-				ResourceNames: []string{common.NameAgentConfigMap, common.NameClusterConfigMap},
+				ResourceNames: []string{common.NameAgentConfigMap, common.NameClusterConfigMap, common.NameClusterEventsConfigMap},
+			},
+			{
+				APIGroups:     []string{""},
+				Verbs:         []string{"get", "update", "patch", "delete"},
+				Resources:     []string{"configmaps"},
+				ResourceNames: clusterConfigShardMapNames(),
 			},
 			{
 				APIGroups: []string{""},
@@ -486,6 +988,16 @@ func (ac *AgentDeployConfig) buildControllerDeployment() (*appsv1.Deployment, *r
 				Resources:     []string{"configmaps"},
 				ResourceNames: []string{common.NameGardenerShootInfo},
 			},
+			{
+				APIGroups: []string{""},
+				Verbs:     []string{"create"},
+				Resources: []string{"events"},
+			},
+			{
+				APIGroups: []string{"apps"},
+				Verbs:     []string{"get", "list", "watch"},
+				Resources: []string{"daemonsets"},
+			},
 		},
 	}
 	roleBinding := &rbacv1.RoleBinding{
@@ -537,13 +1049,46 @@ func (ac *AgentDeployConfig) buildK8sExporterClusterRoleRules() []rbacv1.PolicyR
 	}
 }
 
+// buildEventExporterClusterRoleRules returns the rules for updating the events created by the event exporter.
+func (ac *AgentDeployConfig) buildEventExporterClusterRoleRules() []rbacv1.PolicyRule {
+	return []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{""},
+			Resources: []string{"events"},
+			Verbs:     []string{"get", "create", "update"},
+		},
+	}
+}
+
+// buildAgentClusterRoleRules returns the rules needed by the enabled exporters of the agents.
+func (ac *AgentDeployConfig) buildAgentClusterRoleRules() []rbacv1.PolicyRule {
+	var rules []rbacv1.PolicyRule
+	if ac.K8sExporterEnabled {
+		rules = append(rules, ac.buildK8sExporterClusterRoleRules()...)
+	}
+	if ac.EventExporterEnabled {
+		rules = append(rules, ac.buildEventExporterClusterRoleRules()...)
+	}
+	return rules
+}
+
 func (ac *AgentDeployConfig) buildSecurityObjects() (serviceAccountName string, objects []Object, retErr error) {
+	if ac.ExistingServiceAccount != "" {
+		// the RBAC objects are managed externally, including the permission to use the pod security policy
+		serviceAccountName = ac.ExistingServiceAccount
+		if ac.PodSecurityPolicyEnabled {
+			_, _, _, psp, err := ac.buildPodSecurityPolicy(serviceAccountName)
+			retErr = err
+			objects = append(objects, psp)
+		}
+		return
+	}
 	if ac.PodSecurityPolicyEnabled {
 		serviceAccountName = common.ApplicationName
 		cr, crb, sa, psp, err := ac.buildPodSecurityPolicy(serviceAccountName)
 		retErr = err
 		objects = append(objects, cr, crb, sa, psp)
-	} else if ac.K8sExporterEnabled {
+	} else if ac.K8sExporterEnabled || ac.EventExporterEnabled {
 		serviceAccountName = common.ApplicationName
 		cr, crb, sa, err := ac.buildK8sExporterClusterRole(serviceAccountName)
 		retErr = err
@@ -554,7 +1099,7 @@ func (ac *AgentDeployConfig) buildSecurityObjects() (serviceAccountName string,
 
 func (ac *AgentDeployConfig) buildK8sExporterClusterRole(serviceAccountName string) (*rbacv1.ClusterRole, *rbacv1.ClusterRoleBinding, *corev1.ServiceAccount, error) {
 	roleName := "gardener.cloud:kube-system:" + common.ApplicationName
-	rules := ac.buildK8sExporterClusterRoleRules()
+	rules := ac.buildAgentClusterRoleRules()
 	return ac.createClusterRuleAndServiceAccount(serviceAccountName, roleName, rules)
 }
 
@@ -605,17 +1150,22 @@ func (ac *AgentDeployConfig) buildPodSecurityPolicy(serviceAccountName string) (
 			NonResourceURLs: nil,
 		},
 	}
-	if ac.K8sExporterEnabled {
-		rules = append(rules, ac.buildK8sExporterClusterRoleRules()...)
-	}
+	rules = append(rules, ac.buildAgentClusterRoleRules()...)
 	cr, crb, sa, err := ac.createClusterRuleAndServiceAccount(serviceAccountName, roleName, rules)
 	if err != nil {
 		return cr, crb, sa, nil, err
 	}
 
-	var allowedCapabilities []corev1.Capability
-	if ac.PingEnabled {
-		allowedCapabilities = []corev1.Capability{"NET_ADMIN"}
+	allowedCapabilities := ac.agentCapabilities(true)
+	allowedHostPaths := []policyv1beta1.AllowedHostPath{
+		{PathPrefix: common.PathLogDir, ReadOnly: false},
+	}
+	if ac.NetNSChecksEnabled {
+		allowedHostPaths = append(allowedHostPaths, policyv1beta1.AllowedHostPath{PathPrefix: "/proc", ReadOnly: true})
+	}
+	var hostPorts []policyv1beta1.HostPortRange
+	for _, p := range ac.agentPorts(true) {
+		hostPorts = append(hostPorts, policyv1beta1.HostPortRange{Min: int32(p.Number), Max: int32(p.Number)})
 	}
 	psp := &policyv1beta1.PodSecurityPolicy{
 		ObjectMeta: metav1.ObjectMeta{
@@ -626,14 +1176,11 @@ func (ac *AgentDeployConfig) buildPodSecurityPolicy(serviceAccountName string) (
 			DefaultAddCapabilities:   nil,
 			RequiredDropCapabilities: nil,
 			AllowedCapabilities:      allowedCapabilities,
-			Volumes:                  []policyv1beta1.FSType{policyv1beta1.Secret, policyv1beta1.ConfigMap, policyv1beta1.HostPath},
+			Volumes:                  []policyv1beta1.FSType{policyv1beta1.Secret, policyv1beta1.ConfigMap, policyv1beta1.HostPath, policyv1beta1.EmptyDir},
 			HostNetwork:              true,
-			HostPorts: []policyv1beta1.HostPortRange{
-				{Min: common.HostNetPodGRPCPort, Max: common.HostNetPodGRPCPort},
-				{Min: common.HostNetPodHttpPort, Max: common.HostNetPodHttpPort},
-			},
-			HostPID: false,
-			HostIPC: false,
+			HostPorts:                hostPorts,
+			HostPID:                  false,
+			HostIPC:                  false,
 			SELinux: policyv1beta1.SELinuxStrategyOptions{
 				Rule: policyv1beta1.SELinuxStrategyRunAsAny,
 			},
@@ -650,24 +1197,33 @@ func (ac *AgentDeployConfig) buildPodSecurityPolicy(serviceAccountName string) (
 			ReadOnlyRootFilesystem:          false,
 			DefaultAllowPrivilegeEscalation: nil,
 			AllowPrivilegeEscalation:        pointer.Bool(true),
-			AllowedHostPaths: []policyv1beta1.AllowedHostPath{
-				{PathPrefix: common.PathLogDir, ReadOnly: false},
-			},
+			AllowedHostPaths:                allowedHostPaths,
 		},
 	}
 
 	return cr, crb, sa, psp, nil
 }
 
+// BuildAgentConfig builds the agent config of the deploy config with NewDefaultAgentConfig.
 func (ac *AgentDeployConfig) BuildAgentConfig() (*config.AgentConfig, error) {
+	return NewDefaultAgentConfig(ac.agentConfigOptions()...)
+}
+
+// buildAgentConfig builds the default jobs and settings of the agent config enabled by the deploy config.
+func (ac *AgentDeployConfig) buildAgentConfig() (*config.AgentConfig, error) {
+	if ac.ExposedHostPort != 0 {
+		if err := validateExposedHostPort(ac.ExposedHostPort); err != nil {
+			return nil, err
+		}
+	}
 	cfg := config.AgentConfig{
 		OutputDir:       common.PathOutputDir,
-		RetentionHours:  4,
-		LogObservations: false,
+		RetentionHours:    ac.RetentionHours,
+		LogObservations:   false,
+		LogDroppingFactor: ac.LogDroppingFactor,
 		HostNetwork: &config.NetworkConfig{
 			DataFilePrefix: common.NameDaemonSetAgentHostNet,
-			GRPCPort:       common.HostNetPodGRPCPort,
-			HttpPort:       common.HostNetPodHttpPort,
+			ExposedPort:    ac.ExposedHostPort,
 			DefaultPeriod:  metav1.Duration{Duration: ac.DefaultPeriod},
 			Jobs: []config.Job{
 				{
@@ -676,7 +1232,7 @@ func (ac *AgentDeployConfig) BuildAgentConfig() (*config.AgentConfig, error) {
 				},
 				{
 					JobID: "tcp-n2n",
-					Args:  []string{"checkTCPPort", "--node-port", fmt.Sprintf("%d", common.HostNetPodGRPCPort)},
+					Args:  []string{"checkTCPPort", "--node-port", fmt.Sprintf("%d", ac.hostNetGRPCPort())},
 				},
 				{
 					JobID: "tcp-n2p",
@@ -686,13 +1242,24 @@ func (ac *AgentDeployConfig) BuildAgentConfig() (*config.AgentConfig, error) {
 					JobID: "nslookup-n",
 					Args:  []string{"nslookup", "--names", "eu.gcr.io.", "--period", "1m"},
 				},
+				{
+					JobID: "tcp-n2lb",
+					Args:  []string{"checkTCPPort", "--endpoints-of-load-balancers"},
+				},
+				{
+					JobID: "dns-n2nodelocaldns",
+					Args:  []string{"nslookup", "--name-internal-kube-apiserver", "--dns-server", "node-local-dns", "--period", "1m"},
+				},
+				{
+					JobID: "kubelet-n2n",
+					Args:  []string{"checkKubelet"},
+				},
 			},
 		},
 		PodNetwork: &config.NetworkConfig{
-			DataFilePrefix: common.NameDaemonSetAgentPodNet,
-			DefaultPeriod:  metav1.Duration{Duration: ac.DefaultPeriod},
-			GRPCPort:       common.PodNetPodGRPCPort,
-			HttpPort:       common.PodNetPodHttpPort,
+			DataFilePrefix:      common.NameDaemonSetAgentPodNet,
+			DefaultPeriod:       metav1.Duration{Duration: ac.DefaultPeriod},
+			ForwardObservations: ac.PodHostPathDisabled,
 			Jobs: []config.Job{
 				{
 					JobID: "tcp-p2api-int",
@@ -704,7 +1271,7 @@ func (ac *AgentDeployConfig) BuildAgentConfig() (*config.AgentConfig, error) {
 				},
 				{
 					JobID: "tcp-p2n",
-					Args:  []string{"checkTCPPort", "--node-port", fmt.Sprintf("%d", common.HostNetPodGRPCPort)},
+					Args:  []string{"checkTCPPort", "--node-port", fmt.Sprintf("%d", ac.hostNetGRPCPort())},
 				},
 				{
 					JobID: "tcp-p2p",
@@ -714,10 +1281,29 @@ func (ac *AgentDeployConfig) BuildAgentConfig() (*config.AgentConfig, error) {
 					JobID: "nslookup-p",
 					Args:  []string{"nslookup", "--names", "eu.gcr.io.", "--name-internal-kube-apiserver", "--period", "1m"},
 				},
+				{
+					JobID: "tcp-p2lb",
+					Args:  []string{"checkTCPPort", "--endpoints-of-load-balancers"},
+				},
+				{
+					JobID: "tcp-p2svc",
+					Args:  []string{"checkTCPPort", "--endpoints-of-services"},
+				},
+				{
+					JobID: "dns-p2coredns",
+					Args:  []string{"nslookup", "--name-internal-kube-apiserver", "--dns-server", "kube-dns", "--period", "1m"},
+				},
+				{
+					JobID: "route-p2gw",
+					Args:  []string{"checkDefaultRoute", "--period", "1m"},
+				},
 			},
 		},
 	}
 
+	applyPorts(cfg.HostNetwork, ac.agentPorts(true))
+	applyPorts(cfg.PodNetwork, ac.agentPorts(false))
+
 	if ac.K8sExporterEnabled {
 		cfg.K8sExporter = &config.K8sExporterConfig{
 			Enabled:         true,
@@ -725,6 +1311,21 @@ func (ac *AgentDeployConfig) BuildAgentConfig() (*config.AgentConfig, error) {
 		}
 	}
 
+	if ac.EventExporterEnabled {
+		cfg.EventExporter = &config.EventExporterConfig{
+			Enabled:            true,
+			Window:             &metav1.Duration{Duration: ac.EventExporterWindow},
+			MaxEventsPerWindow: ac.EventExporterMaxEvents,
+			MaxMessageSize:     ac.EventExporterMaxMessageSize,
+		}
+		for _, category := range ac.EventExporterCategories {
+			cfg.EventExporter.Categories = append(cfg.EventExporter.Categories, config.EventCategory(category))
+		}
+		if err := cfg.EventExporter.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	if !ac.IgnoreAPIServerEndpoint {
 		for i := range cfg.HostNetwork.Jobs {
 			job := &cfg.HostNetwork.Jobs[i]
@@ -772,10 +1373,405 @@ func (ac *AgentDeployConfig) BuildAgentConfig() (*config.AgentConfig, error) {
 			})
 	}
 
+	if ac.ExternalIPCheckEnabled {
+		nodePortArgs := []string{"checkTCPPort", "--node-port", fmt.Sprintf("%d", ac.hostNetGRPCPort()), "--node-address-type", config.NodeAddressTypeExternal}
+		cfg.HostNetwork.Jobs = append(cfg.HostNetwork.Jobs,
+			config.Job{
+				JobID: "tcp-n2n-ext",
+				Args:  nodePortArgs,
+			})
+		cfg.PodNetwork.Jobs = append(cfg.PodNetwork.Jobs,
+			config.Job{
+				JobID: "tcp-p2n-ext",
+				Args:  append([]string{}, nodePortArgs...),
+			})
+		if ac.PingEnabled {
+			cfg.HostNetwork.Jobs = append(cfg.HostNetwork.Jobs,
+				config.Job{
+					JobID: "ping-n2n-ext",
+					Args:  []string{"pingHost", "--node-address-type", config.NodeAddressTypeExternal},
+				})
+			cfg.PodNetwork.Jobs = append(cfg.PodNetwork.Jobs,
+				config.Job{
+					JobID: "ping-p2n-ext",
+					Args:  []string{"pingHost", "--node-address-type", config.NodeAddressTypeExternal},
+				})
+		}
+	}
+
+	if ac.ProxyRulesCheckEnabled {
+		cfg.HostNetwork.Jobs = append(cfg.HostNetwork.Jobs,
+			config.Job{
+				JobID: "proxy-rules-n",
+				Args:  []string{"checkProxyRules", "--period", "1m"},
+			})
+	}
+
+	if ac.IMDSCheckEnabled {
+		endpoint := ac.IMDSEndpoint
+		if endpoint == "" {
+			endpoint = common.DefaultIMDSEndpoint
+		}
+		ip, port, err := net.SplitHostPort(endpoint)
+		if err != nil || net.ParseIP(ip).To4() == nil {
+			return nil, fmt.Errorf("invalid IMDS endpoint %q: expected format <ipv4>:<port>", endpoint)
+		}
+		cfg.HostNetwork.Jobs = append(cfg.HostNetwork.Jobs,
+			config.Job{
+				JobID: "tcp-n2imds",
+				Args:  []string{"checkTCPPort", "--endpoints", common.DestHostIMDS + ":" + net.JoinHostPort(ip, port)},
+			})
+	}
+
+	if ac.EchoCheckEnabled {
+		cfg.HostNetwork.Jobs = append(cfg.HostNetwork.Jobs,
+			config.Job{
+				JobID: "tcp-n2n-echo",
+				Args:  []string{"checkTCPPort", "--node-port", fmt.Sprintf("%d", common.HostNetPodEchoPort), "--echo"},
+			})
+		cfg.PodNetwork.Jobs = append(cfg.PodNetwork.Jobs,
+			config.Job{
+				JobID: "tcp-p2n-echo",
+				Args:  []string{"checkTCPPort", "--node-port", fmt.Sprintf("%d", common.HostNetPodEchoPort), "--echo"},
+			})
+	}
+
+	if ac.APIServerAuthCheckEnabled {
+		cfg.PodNetwork.Jobs = append(cfg.PodNetwork.Jobs,
+			config.Job{
+				JobID: "auth-p2api-int",
+				Args:  []string{"checkAPIServerAuth", "--period", "1m", "--scale-period"},
+			})
+	}
+
+	if len(ac.IngressEndpoints) > 0 {
+		if err := ac.addIngressJobs(&cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if ac.EndpointsFromURL != "" {
+		cfg.HostNetwork.Jobs = append(cfg.HostNetwork.Jobs,
+			config.Job{
+				JobID: "tcp-n2url",
+				Args:  []string{"checkTCPPort", "--endpoints-of-url-list"},
+			})
+		cfg.PodNetwork.Jobs = append(cfg.PodNetwork.Jobs,
+			config.Job{
+				JobID: "tcp-p2url",
+				Args:  []string{"checkTCPPort", "--endpoints-of-url-list"},
+			})
+	}
+
+	if err := ac.applyRetentionAndLogDropping(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err := ac.filterJobs(&cfg); err != nil {
+		return nil, err
+	}
+	if err := ac.addJobLabels(&cfg); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
+// applyRetentionAndLogDropping sets the global retention and the overrides of the retention and the log dropping factor
+// per network. A log dropping factor equal to the global one is not set as override.
+func (ac *AgentDeployConfig) applyRetentionAndLogDropping(cfg *config.AgentConfig) error {
+	if cfg.RetentionHours == 0 {
+		cfg.RetentionHours = defaultRetentionHours
+	}
+	cfg.HostNetwork.RetentionHours = ac.HostNetworkRetentionHours
+	cfg.PodNetwork.RetentionHours = ac.PodNetworkRetentionHours
+	if ac.HostNetworkLogDroppingFactor >= 0 && ac.HostNetworkLogDroppingFactor != ac.LogDroppingFactor {
+		cfg.HostNetwork.LogDroppingFactor = pointer.Float64(ac.HostNetworkLogDroppingFactor)
+	}
+	if ac.PodNetworkLogDroppingFactor >= 0 && ac.PodNetworkLogDroppingFactor != ac.LogDroppingFactor {
+		cfg.PodNetwork.LogDroppingFactor = pointer.Float64(ac.PodNetworkLogDroppingFactor)
+	}
+	return cfg.ValidateRetentionAndLogDropping()
+}
+
+// ParseNodeLabelSelector parses the label selector restricting the nodes. It returns nil if it is not set.
+func (ac *AgentDeployConfig) ParseNodeLabelSelector() (labels.Selector, error) {
+	if ac.NodeLabelSelector == "" {
+		return nil, nil
+	}
+	selector, err := labels.Parse(ac.NodeLabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node label selector %q: %w", ac.NodeLabelSelector, err)
+	}
+	return selector, nil
+}
+
+// nodeAffinity returns the node affinity restricting the agents to the nodes of the node label selector, nil if it is not set.
+// A required node affinity is used instead of a plain node selector to support set based requirements like 'in' and 'notin'.
+func (ac *AgentDeployConfig) nodeAffinity() (*corev1.Affinity, error) {
+	selector, err := ac.ParseNodeLabelSelector()
+	if err != nil || selector == nil {
+		return nil, err
+	}
+	requirements, _ := selector.Requirements()
+	var expressions []corev1.NodeSelectorRequirement
+	for _, r := range requirements {
+		var op corev1.NodeSelectorOperator
+		switch r.Operator() {
+		case selection.Equals, selection.DoubleEquals, selection.In:
+			op = corev1.NodeSelectorOpIn
+		case selection.NotEquals, selection.NotIn:
+			op = corev1.NodeSelectorOpNotIn
+		case selection.Exists:
+			op = corev1.NodeSelectorOpExists
+		case selection.DoesNotExist:
+			op = corev1.NodeSelectorOpDoesNotExist
+		case selection.GreaterThan:
+			op = corev1.NodeSelectorOpGt
+		case selection.LessThan:
+			op = corev1.NodeSelectorOpLt
+		default:
+			return nil, fmt.Errorf("invalid node label selector %q: unsupported operator %s", ac.NodeLabelSelector, r.Operator())
+		}
+		expressions = append(expressions, corev1.NodeSelectorRequirement{Key: r.Key(), Operator: op, Values: r.Values().List()})
+	}
+	return &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: expressions}},
+			},
+		},
+	}, nil
+}
+
+// ParseLoadBalancerSelector parses the label selector for services of type LoadBalancer. It returns nil if it is not set.
+func (ac *AgentDeployConfig) ParseLoadBalancerSelector() (labels.Selector, error) {
+	if ac.LoadBalancerSelector == "" {
+		return nil, nil
+	}
+	selector, err := labels.Parse(ac.LoadBalancerSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid load balancer selector %q: %w", ac.LoadBalancerSelector, err)
+	}
+	return selector, nil
+}
+
+// ParseServiceSelector parses the label selector for services checked from the pod network. It returns nil if it is not set.
+func (ac *AgentDeployConfig) ParseServiceSelector() (labels.Selector, error) {
+	if ac.ServiceSelector == "" {
+		return nil, nil
+	}
+	selector, err := labels.Parse(ac.ServiceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service selector %q: %w", ac.ServiceSelector, err)
+	}
+	return selector, nil
+}
+
+// addIngressJobs adds TCP jobs for the ingress endpoints and HTTPS jobs for the ones on port 443 to both networks.
+func (ac *AgentDeployConfig) addIngressJobs(cfg *config.AgentConfig) error {
+	var tcpEndpoints, httpsEndpoints []string
+	for _, value := range ac.IngressEndpoints {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid ingress endpoint %q: expected format <name>=<host>:<port>", value)
+		}
+		name := parts[0]
+		host, port, err := net.SplitHostPort(parts[1])
+		if err != nil || host == "" {
+			return fmt.Errorf("invalid ingress endpoint %q: expected format <name>=<host>:<port>", value)
+		}
+		endpoint, err := config.ParseEndpoint(common.DestHostPrefixIngress + name + ":" + parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid ingress endpoint %q: %w", value, err)
+		}
+		tcpEndpoints = append(tcpEndpoints, endpoint.String())
+		if endpoint.Port == 443 {
+			httpsEndpoints = append(httpsEndpoints, net.JoinHostPort(host, port))
+		}
+	}
+	cfg.HostNetwork.Jobs = append(cfg.HostNetwork.Jobs, config.Job{
+		JobID: "tcp-n2ingress",
+		Args:  []string{"checkTCPPort", "--endpoints", strings.Join(tcpEndpoints, ",")},
+	})
+	cfg.PodNetwork.Jobs = append(cfg.PodNetwork.Jobs, config.Job{
+		JobID: "tcp-p2ingress",
+		Args:  []string{"checkTCPPort", "--endpoints", strings.Join(tcpEndpoints, ",")},
+	})
+	if len(httpsEndpoints) > 0 {
+		cfg.HostNetwork.Jobs = append(cfg.HostNetwork.Jobs, config.Job{
+			JobID: "https-n2ingress",
+			Args:  []string{"checkHTTPSGet", "--endpoints", strings.Join(httpsEndpoints, ","), "--period", "1m"},
+		})
+		cfg.PodNetwork.Jobs = append(cfg.PodNetwork.Jobs, config.Job{
+			JobID: "https-p2ingress",
+			Args:  []string{"checkHTTPSGet", "--endpoints", strings.Join(httpsEndpoints, ","), "--period", "1m"},
+		})
+	}
+	return nil
+}
+
+func (ac *AgentDeployConfig) filterJobs(cfg *config.AgentConfig) error {
+	if len(ac.EnabledJobs) == 0 && len(ac.DisabledJobs) == 0 {
+		return nil
+	}
+
+	matched := map[string]bool{}
+	matchesAny := func(patterns []string, jobID string) (bool, error) {
+		found := false
+		for _, pattern := range patterns {
+			ok, err := MatchJobID(pattern, jobID)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				matched[pattern] = true
+				found = true
+			}
+		}
+		return found, nil
+	}
+
+	count := 0
+	for _, networkCfg := range []*config.NetworkConfig{cfg.HostNetwork, cfg.PodNetwork} {
+		var jobs []config.Job
+		for _, job := range networkCfg.Jobs {
+			if len(ac.EnabledJobs) > 0 {
+				enabled, err := matchesAny(ac.EnabledJobs, job.JobID)
+				if err != nil {
+					return err
+				}
+				if !enabled {
+					continue
+				}
+			}
+			disabled, err := matchesAny(ac.DisabledJobs, job.JobID)
+			if err != nil {
+				return err
+			}
+			if disabled {
+				continue
+			}
+			jobs = append(jobs, job)
+		}
+		networkCfg.Jobs = jobs
+		count += len(jobs)
+	}
+
+	for _, pattern := range append(append([]string{}, ac.EnabledJobs...), ac.DisabledJobs...) {
+		if !matched[pattern] {
+			logrus.Warnf("job filter %q does not match any default job", pattern)
+		}
+	}
+	if count == 0 {
+		return fmt.Errorf("no jobs left after applying job filters")
+	}
+	return nil
+}
+
+// addJobLabels sets the job labels on all jobs matching the pattern.
+func (ac *AgentDeployConfig) addJobLabels(cfg *config.AgentConfig) error {
+	for _, value := range ac.JobLabels {
+		parts := strings.SplitN(value, ":", 2)
+		var nameValue []string
+		if len(parts) == 2 {
+			nameValue = strings.SplitN(parts[1], "=", 2)
+		}
+		if len(nameValue) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid job label %q: expected format <job ID prefix or glob pattern>:<name>=<value>", value)
+		}
+		found := false
+		for _, networkCfg := range []*config.NetworkConfig{cfg.HostNetwork, cfg.PodNetwork} {
+			for i := range networkCfg.Jobs {
+				job := &networkCfg.Jobs[i]
+				ok, err := MatchJobID(parts[0], job.JobID)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
+				found = true
+				if job.Labels == nil {
+					job.Labels = map[string]string{}
+				}
+				job.Labels[nameValue[0]] = nameValue[1]
+				if err := config.ValidateJobLabels(job.Labels); err != nil {
+					return fmt.Errorf("invalid job label %q for job %s: %w", value, job.JobID, err)
+				}
+			}
+		}
+		if !found {
+			logrus.Warnf("job label %q does not match any job", value)
+		}
+	}
+	return nil
+}
+
+// MatchJobID matches a job ID either by glob pattern (if the pattern contains any of '*?[') or by prefix.
+func MatchJobID(pattern, jobID string) (bool, error) {
+	if strings.ContainsAny(pattern, "*?[") {
+		ok, err := path.Match(pattern, jobID)
+		if err != nil {
+			return false, fmt.Errorf("invalid job filter %q: %w", pattern, err)
+		}
+		return ok, nil
+	}
+	return strings.HasPrefix(jobID, pattern), nil
+}
+
+// agentConfigHash returns the SHA256 hash of the agent config map content.
+func (ac *AgentDeployConfig) agentConfigHash() (string, error) {
+	agentConfig, err := ac.BuildAgentConfig()
+	if err != nil {
+		return "", err
+	}
+	cm, err := BuildAgentConfigMap(agentConfig)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(cm.Data[common.AgentConfigFilename]))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// agentConfigMapName returns the name of the agent config map referenced by the daemon sets.
+func (ac *AgentDeployConfig) agentConfigMapName() (string, error) {
+	if !ac.ImmutableConfig {
+		return common.NameAgentConfigMap, nil
+	}
+	cm, err := ac.buildAgentConfigMap()
+	if err != nil {
+		return "", err
+	}
+	return cm.Name, nil
+}
+
+// buildAgentConfigMap builds the agent config map. With ImmutableConfig, the config map is immutable and
+// named and labeled by the config generation.
+func (ac *AgentDeployConfig) buildAgentConfigMap() (*corev1.ConfigMap, error) {
+	agentConfig, err := ac.BuildAgentConfig()
+	if err != nil {
+		return nil, err
+	}
+	cm, err := BuildAgentConfigMap(agentConfig)
+	if err != nil {
+		return nil, err
+	}
+	if ac.ImmutableConfig {
+		cm.Name = common.VersionedAgentConfigMapName(agentConfig.Generation)
+		cm.Labels = map[string]string{
+			common.LabelKeyK8sApp:                common.ApplicationName,
+			common.LabelKeyAgentConfigGeneration: agentConfig.Generation,
+		}
+		cm.Immutable = pointer.Bool(true)
+	}
+	return cm, nil
+}
+
 func BuildAgentConfigMap(agentConfig *config.AgentConfig) (*corev1.ConfigMap, error) {
+	if err := agentConfig.StampGeneration(); err != nil {
+		return nil, err
+	}
 	cfgBytes, err := yaml.Marshal(agentConfig)
 	if err != nil {
 		return nil, err
@@ -786,29 +1782,91 @@ func BuildAgentConfigMap(agentConfig *config.AgentConfig) (*corev1.ConfigMap, er
 			Namespace: common.NamespaceKubeSystem,
 		},
 		Data: map[string]string{
-			common.AgentConfigFilename: string(cfgBytes),
+			common.AgentConfigFilename:              string(cfgBytes),
+			common.AgentConfigLastKnownGoodFilename: string(cfgBytes),
 		},
 	}
 	return cm, nil
 }
 
+// BuildClusterConfigMap builds the cluster config map. The optional metadata of the nodes is dropped if the cluster config
+// is too large otherwise. Sharding is left to the controller, so an error is returned if trimming is not sufficient.
 func BuildClusterConfigMap(clusterConfig *config.ClusterConfig) (*corev1.ConfigMap, error) {
-	cfgBytes, err := yaml.Marshal(clusterConfig)
+	layout, err := LayoutClusterConfig(clusterConfig, DefaultClusterConfigCompressThreshold)
 	if err != nil {
 		return nil, err
 	}
+	if len(layout.Shards) > 0 {
+		return nil, fmt.Errorf("cluster config too large: %d bytes exceed %d bytes (it is sharded by the controller)", layout.FullSize, MaxClusterConfigMapDataSize)
+	}
+	cfgBytes := layout.Data
 	cm := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      common.NameClusterConfigMap,
 			Namespace: common.NamespaceKubeSystem,
 		},
-		Data: map[string]string{
-			common.ClusterConfigFilename: string(cfgBytes),
-		},
+	}
+	if err := SetClusterConfigData(cm, cfgBytes, DefaultClusterConfigCompressThreshold); err != nil {
+		return nil, err
 	}
 	return cm, nil
 }
 
+// SetClusterConfigData stores the marshalled cluster config in the config map. If it exceeds the compress threshold
+// (DefaultClusterConfigCompressThreshold if <= 0), it is stored gzip compressed with the key common.ClusterConfigCompressedFilename
+// instead of common.ClusterConfigFilename. An error is returned if the stored data exceeds MaxClusterConfigMapDataSize.
+func SetClusterConfigData(cm *corev1.ConfigMap, data []byte, compressThreshold int) error {
+	return setClusterConfigData(cm, common.ClusterConfigFilename, data, compressThreshold)
+}
+
+// setClusterConfigData stores the data in the config map with the key, or gzip compressed with the key and the suffix
+// config.CompressedFileSuffix if it exceeds the compress threshold.
+func setClusterConfigData(cm *corev1.ConfigMap, key string, data []byte, compressThreshold int) error {
+	if compressThreshold <= 0 {
+		compressThreshold = DefaultClusterConfigCompressThreshold
+	}
+	compressedKey := key + config.CompressedFileSuffix
+	size := len(data)
+	if size > compressThreshold {
+		compressed, err := config.Gzip(data)
+		if err != nil {
+			return err
+		}
+		size = len(compressed)
+		delete(cm.Data, key)
+		if cm.BinaryData == nil {
+			cm.BinaryData = map[string][]byte{}
+		}
+		cm.BinaryData[compressedKey] = compressed
+	} else {
+		delete(cm.BinaryData, compressedKey)
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[key] = string(data)
+	}
+	if size > MaxClusterConfigMapDataSize {
+		return fmt.Errorf("cluster config of configmap %s/%s too large: %d bytes (maximum %d)", cm.Namespace, cm.Name, size, MaxClusterConfigMapDataSize)
+	}
+	return nil
+}
+
+// ClusterConfigData returns the marshalled cluster config stored in the config map with SetClusterConfigData.
+func ClusterConfigData(cm *corev1.ConfigMap) ([]byte, error) {
+	return clusterConfigData(cm, common.ClusterConfigFilename)
+}
+
+func clusterConfigData(cm *corev1.ConfigMap, key string) ([]byte, error) {
+	if compressed, ok := cm.BinaryData[key+config.CompressedFileSuffix]; ok {
+		data, err := config.Gunzip(compressed)
+		if err != nil {
+			return nil, fmt.Errorf("configmap %s/%s: %w", cm.Namespace, cm.Name, err)
+		}
+		return data, nil
+	}
+	return []byte(cm.Data[key]), nil
+}
+
 func imagePullPolicyByImage(image string) corev1.PullPolicy {
 	if strings.HasSuffix(image, "-dev") || strings.HasSuffix(image, ":latest") {
 		return corev1.PullAlways
@@ -0,0 +1,635 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package deploy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/utils/pointer"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+func TestValidateExposedHostPort(t *testing.T) {
+	for _, testCase := range []struct {
+		port    int
+		invalid bool
+	}{
+		{port: 1011},
+		{port: 4242},
+		{port: 0, invalid: true},
+		{port: 70000, invalid: true},
+		{port: 22, invalid: true},
+		{port: common.HostNetPodHttpPort, invalid: true},
+		{port: 10250, invalid: true},
+		{port: 30080, invalid: true},
+	} {
+		err := validateExposedHostPort(testCase.port)
+		assert.Equal(t, testCase.invalid, err != nil, "port %d: %v", testCase.port, err)
+	}
+}
+
+func TestExposedHostPort(t *testing.T) {
+	ac := &AgentDeployConfig{ExposedHostPort: 4242, IgnoreAPIServerEndpoint: true}
+
+	cfg, err := ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 4242, cfg.HostNetwork.GRPCPort)
+	assert.Equal(t, 4242, cfg.HostNetwork.ExposedPort)
+	assert.Equal(t, 0, cfg.PodNetwork.ExposedPort)
+	for _, job := range append(cfg.HostNetwork.Jobs, cfg.PodNetwork.Jobs...) {
+		if job.JobID == "tcp-n2n" || job.JobID == "tcp-p2n" {
+			assert.Equal(t, []string{"checkTCPPort", "--node-port", "4242"}, job.Args)
+		}
+	}
+
+	ds, err := ac.buildDaemonSet("sa", true)
+	assert.NoError(t, err)
+	port := ds.Spec.Template.Spec.Containers[0].Ports[0]
+	assert.Equal(t, int32(4242), port.ContainerPort)
+	assert.Equal(t, int32(4242), port.HostPort)
+
+	ds, err = ac.buildDaemonSet("sa", false)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), ds.Spec.Template.Spec.Containers[0].Ports[0].HostPort)
+
+	_, _, _, psp, err := ac.buildPodSecurityPolicy("sa")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4242), psp.Spec.HostPorts[0].Min)
+
+	ac.ExposedHostPort = 10250
+	_, err = ac.BuildAgentConfig()
+	assert.Error(t, err)
+	_, err = ac.buildDaemonSet("sa", true)
+	assert.Error(t, err)
+}
+
+func TestRestartOnConfigChange(t *testing.T) {
+	ac := &AgentDeployConfig{IgnoreAPIServerEndpoint: true}
+	ds, err := ac.buildDaemonSet("sa", true)
+	assert.NoError(t, err)
+	assert.NotContains(t, ds.Spec.Template.Annotations, "check-sum/config")
+
+	ac.RestartOnConfigChange = true
+	ds, err = ac.buildDaemonSet("sa", true)
+	assert.NoError(t, err)
+	hash := ds.Spec.Template.Annotations["check-sum/config"]
+	assert.Len(t, hash, 64)
+	ds, err = ac.buildDaemonSet("sa", false)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, ds.Spec.Template.Annotations["check-sum/config"])
+
+	ac.PingEnabled = true
+	ds, err = ac.buildDaemonSet("sa", true)
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, ds.Spec.Template.Annotations["check-sum/config"])
+}
+
+func TestImmutableConfig(t *testing.T) {
+	configVolume := func(ds *appsv1.DaemonSet) string {
+		for _, v := range ds.Spec.Template.Spec.Volumes {
+			if v.ConfigMap != nil && strings.HasPrefix(v.ConfigMap.Name, common.NameAgentConfigMap) {
+				return v.ConfigMap.Name
+			}
+		}
+		return ""
+	}
+
+	ac := &AgentDeployConfig{IgnoreAPIServerEndpoint: true}
+	cm, err := ac.buildAgentConfigMap()
+	assert.NoError(t, err)
+	assert.Equal(t, common.NameAgentConfigMap, cm.Name)
+	assert.Nil(t, cm.Immutable)
+	ds, err := ac.buildDaemonSet("sa", true)
+	assert.NoError(t, err)
+	assert.Equal(t, common.NameAgentConfigMap, configVolume(ds))
+
+	ac.ImmutableConfig = true
+	cm, err = ac.buildAgentConfigMap()
+	assert.NoError(t, err)
+	generation := cm.Labels[common.LabelKeyAgentConfigGeneration]
+	assert.Len(t, generation, 12)
+	assert.Equal(t, common.VersionedAgentConfigMapName(generation), cm.Name)
+	assert.Equal(t, pointer.Bool(true), cm.Immutable)
+	ds, err = ac.buildDaemonSet("sa", true)
+	assert.NoError(t, err)
+	assert.Equal(t, cm.Name, configVolume(ds))
+
+	ac.PingEnabled = true
+	cm2, err := ac.buildAgentConfigMap()
+	assert.NoError(t, err)
+	assert.NotEqual(t, cm.Name, cm2.Name)
+	ds, err = ac.buildDaemonSet("sa", false)
+	assert.NoError(t, err)
+	assert.Equal(t, cm2.Name, configVolume(ds))
+}
+
+func TestIngressEndpoints(t *testing.T) {
+	ac := &AgentDeployConfig{
+		IgnoreAPIServerEndpoint: true,
+		IngressEndpoints:        []string{"web=web.example.com:443", "db=10.0.0.1:5432"},
+	}
+
+	cfg, err := ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	jobs := map[string][]string{}
+	for _, job := range append(cfg.HostNetwork.Jobs, cfg.PodNetwork.Jobs...) {
+		jobs[job.JobID] = job.Args
+	}
+	tcpArgs := []string{"checkTCPPort", "--endpoints", "ingress-web:web.example.com:443,ingress-db:10.0.0.1:5432"}
+	httpsArgs := []string{"checkHTTPSGet", "--endpoints", "web.example.com:443", "--period", "1m"}
+	assert.Equal(t, tcpArgs, jobs["tcp-n2ingress"])
+	assert.Equal(t, tcpArgs, jobs["tcp-p2ingress"])
+	assert.Equal(t, httpsArgs, jobs["https-n2ingress"])
+	assert.Equal(t, httpsArgs, jobs["https-p2ingress"])
+
+	for _, invalid := range []string{"web.example.com:443", "=web.example.com:443", "web=web.example.com", "web=:443", "web=web.example.com:0", "web=fd00::1:443", "web=[10.0.0.1]:443"} {
+		ac.IngressEndpoints = []string{invalid}
+		_, err = ac.BuildAgentConfig()
+		assert.Error(t, err, invalid)
+	}
+
+	ac.IngressEndpoints = []string{"web=[fd00::1]:443"}
+	cfg, err = ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	for _, job := range cfg.HostNetwork.Jobs {
+		switch job.JobID {
+		case "tcp-n2ingress":
+			assert.Equal(t, []string{"checkTCPPort", "--endpoints", "ingress-web:[fd00::1]:443"}, job.Args)
+		case "https-n2ingress":
+			assert.Equal(t, []string{"checkHTTPSGet", "--endpoints", "[fd00::1]:443", "--period", "1m"}, job.Args)
+		}
+	}
+}
+
+func TestJobLabels(t *testing.T) {
+	ac := &AgentDeployConfig{
+		IgnoreAPIServerEndpoint: true,
+		JobLabels:               []string{"tcp-n2*:tier=host", "tcp-:team=network"},
+	}
+
+	cfg, err := ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	count := 0
+	for _, job := range append(cfg.HostNetwork.Jobs, cfg.PodNetwork.Jobs...) {
+		switch {
+		case strings.HasPrefix(job.JobID, "tcp-n2"):
+			count++
+			assert.Equal(t, map[string]string{"tier": "host", "team": "network"}, job.Labels, job.JobID)
+		case strings.HasPrefix(job.JobID, "tcp-"):
+			assert.Equal(t, map[string]string{"team": "network"}, job.Labels, job.JobID)
+		default:
+			assert.Nil(t, job.Labels, job.JobID)
+		}
+	}
+	assert.NotZero(t, count)
+
+	for _, invalid := range []string{"tier=external", "tcp-n2n:tier", ":tier=external", "tcp-n2n:jobid=x", "tcp-n2n:dependency-name=x"} {
+		ac.JobLabels = []string{invalid}
+		_, err = ac.BuildAgentConfig()
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestServicePortsMatchContainerPorts(t *testing.T) {
+	for _, ac := range []*AgentDeployConfig{
+		{IgnoreAPIServerEndpoint: true},
+		{IgnoreAPIServerEndpoint: true, EchoCheckEnabled: true, ExposedHostPort: 4242},
+	} {
+		cfg, err := ac.BuildAgentConfig()
+		assert.NoError(t, err)
+		for _, hostNetwork := range []bool{true, false} {
+			ds, err := ac.buildDaemonSet("sa", hostNetwork)
+			assert.NoError(t, err)
+			containerPorts := map[string]int32{}
+			for _, p := range ds.Spec.Template.Spec.Containers[0].Ports {
+				containerPorts[p.Name] = p.ContainerPort
+			}
+
+			svc, err := ac.buildService(hostNetwork)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, svc.Spec.Ports)
+			for _, p := range svc.Spec.Ports {
+				assert.Contains(t, containerPorts, p.TargetPort.StrVal, "service %s", svc.Name)
+			}
+			headless, err := ac.buildHeadlessService(hostNetwork)
+			assert.NoError(t, err)
+			assert.Len(t, headless.Spec.Ports, len(svc.Spec.Ports))
+			for _, p := range headless.Spec.Ports {
+				assert.Equal(t, containerPorts[p.Name], p.TargetPort.IntVal, "service %s", headless.Name)
+			}
+
+			nc := cfg.PodNetwork
+			if hostNetwork {
+				nc = cfg.HostNetwork
+			}
+			assert.Equal(t, containerPorts[common.PortNameGRPC], int32(nc.GRPCPort))
+			assert.Equal(t, containerPorts[common.PortNameMetrics], int32(nc.HttpPort))
+			assert.Equal(t, containerPorts[common.PortNameEcho], int32(nc.EchoPort))
+		}
+	}
+}
+
+func TestHeadlessService(t *testing.T) {
+	ac := &AgentDeployConfig{IgnoreAPIServerEndpoint: true, ExposedHostPort: 4242}
+
+	svc, err := ac.buildHeadlessService(true)
+	assert.NoError(t, err)
+	assert.Equal(t, common.NameDaemonSetAgentHostNet+"-headless", svc.Name)
+	assert.Equal(t, corev1.ClusterIPNone, svc.Spec.ClusterIP)
+	assert.Equal(t, common.NameDaemonSetAgentHostNet, svc.Spec.Selector[common.LabelKeyK8sApp])
+	assert.Equal(t, int32(4242), svc.Spec.Ports[0].Port)
+	assert.Equal(t, int32(4242), svc.Spec.Ports[0].TargetPort.IntVal)
+
+	countServices := func() int {
+		objects, err := DeployNetworkProblemDetectorAgent(ac)
+		assert.NoError(t, err)
+		count := 0
+		for _, obj := range objects {
+			if _, ok := obj.(*corev1.Service); ok {
+				count++
+			}
+		}
+		return count
+	}
+	assert.Equal(t, 2, countServices())
+	ac.HeadlessServiceEnabled = true
+	assert.Equal(t, 4, countServices())
+}
+
+func TestAgentCommand(t *testing.T) {
+	ac := &AgentDeployConfig{IgnoreAPIServerEndpoint: true}
+	ds, err := ac.buildDaemonSet("sa", true)
+	assert.NoError(t, err)
+	container := ds.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, []string{"/nwpdcli", "run-agent", "--hostNetwork=true", "--config=/config/agent/agent-config.yaml",
+		"--cluster-config=/config/cluster/cluster-config.yaml"}, container.Command)
+	assert.Nil(t, container.Args)
+
+	ac.AgentCommand = []string{"/usr/local/bin/agent"}
+	ac.AgentArgs = []string{"--debug"}
+	ds, err = ac.buildDaemonSet("sa", false)
+	assert.NoError(t, err)
+	container = ds.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, []string{"/usr/local/bin/agent", "--hostNetwork=false", "--config=/config/agent/agent-config.yaml",
+		"--cluster-config=/config/cluster/cluster-config.yaml"}, container.Command)
+	assert.Equal(t, []string{"--debug"}, container.Args)
+
+	ac.OmitAgentFlags = true
+	ds, err = ac.buildDaemonSet("sa", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/usr/local/bin/agent"}, ds.Spec.Template.Spec.Containers[0].Command)
+}
+
+func TestPodHostPathDisabled(t *testing.T) {
+	ac := &AgentDeployConfig{PodHostPathDisabled: true, IgnoreAPIServerEndpoint: true}
+
+	cfg, err := ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	assert.True(t, cfg.PodNetwork.ForwardObservations)
+	assert.False(t, cfg.HostNetwork.ForwardObservations)
+
+	for _, hostNetwork := range []bool{false, true} {
+		ds, err := ac.buildDaemonSet("sa", hostNetwork)
+		assert.NoError(t, err)
+		for _, volume := range ds.Spec.Template.Spec.Volumes {
+			if volume.Name == "output" || volume.Name == "log" {
+				assert.Equal(t, hostNetwork, volume.HostPath != nil, "volume %s of host network %t", volume.Name, hostNetwork)
+				assert.Equal(t, !hostNetwork, volume.EmptyDir != nil, "volume %s of host network %t", volume.Name, hostNetwork)
+			}
+		}
+	}
+}
+
+func TestNetNSChecksEnabled(t *testing.T) {
+	ac := &AgentDeployConfig{NetNSChecksEnabled: true, PingEnabled: true, IgnoreAPIServerEndpoint: true}
+
+	for _, hostNetwork := range []bool{false, true} {
+		ds, err := ac.buildDaemonSet("sa", hostNetwork)
+		assert.NoError(t, err)
+		spec := ds.Spec.Template.Spec
+		capabilities := []corev1.Capability{"NET_ADMIN", "NET_RAW"}
+		if hostNetwork {
+			capabilities = append(capabilities, "SYS_ADMIN", "SYS_PTRACE")
+		}
+		assert.Equal(t, capabilities, spec.Containers[0].SecurityContext.Capabilities.Add)
+		mounted := false
+		for _, mount := range spec.Containers[0].VolumeMounts {
+			if mount.Name == "host-proc" {
+				mounted = true
+				assert.True(t, mount.ReadOnly)
+				assert.Equal(t, common.PathHostProc, mount.MountPath)
+			}
+		}
+		assert.Equal(t, hostNetwork, mounted, "host network %t", hostNetwork)
+	}
+
+	_, _, _, psp, err := ac.buildPodSecurityPolicy("sa")
+	assert.NoError(t, err)
+	assert.Contains(t, psp.Spec.AllowedHostPaths, policyv1beta1.AllowedHostPath{PathPrefix: "/proc", ReadOnly: true})
+	assert.Contains(t, psp.Spec.AllowedCapabilities, corev1.Capability("SYS_ADMIN"))
+}
+
+func TestReadinessProbe(t *testing.T) {
+	ac := &AgentDeployConfig{IgnoreAPIServerEndpoint: true}
+	ds, err := ac.buildDaemonSet("sa", false)
+	assert.NoError(t, err)
+	probe := ds.Spec.Template.Spec.Containers[0].ReadinessProbe
+	if assert.NotNil(t, probe) {
+		assert.Equal(t, "/readyz", probe.HTTPGet.Path)
+		assert.Equal(t, "metrics", probe.HTTPGet.Port.StrVal)
+	}
+}
+
+func TestExistingServiceAccount(t *testing.T) {
+	ac := AgentDeployConfig{ExistingServiceAccount: "nwpd-managed", K8sExporterEnabled: true, PodSecurityPolicyEnabled: true, IgnoreAPIServerEndpoint: true}
+
+	objects, err := DeployNetworkProblemDetectorAgent(&ac)
+	assert.NoError(t, err)
+	for _, obj := range objects {
+		switch v := obj.(type) {
+		case *rbacv1.ClusterRole, *rbacv1.ClusterRoleBinding, *rbacv1.Role, *rbacv1.RoleBinding, *corev1.ServiceAccount:
+			t.Errorf("unexpected %T %s", obj, obj.GetName())
+		case *appsv1.DaemonSet:
+			assert.Equal(t, "nwpd-managed", v.Spec.Template.Spec.ServiceAccountName)
+		}
+	}
+
+	dc := &deployCommand{agentDeployConfig: ac}
+	objects, err = dc.buildControllerObjects()
+	assert.NoError(t, err)
+	if assert.Len(t, objects, 1) {
+		assert.Equal(t, "nwpd-managed", objects[0].(*appsv1.Deployment).Spec.Template.Spec.ServiceAccountName)
+	}
+}
+
+func TestEventExporter(t *testing.T) {
+	hasEventRules := func(objects []Object) bool {
+		for _, obj := range objects {
+			if cr, ok := obj.(*rbacv1.ClusterRole); ok {
+				for _, rule := range cr.Rules {
+					if len(rule.Resources) == 1 && rule.Resources[0] == "events" {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}
+
+	ac := &AgentDeployConfig{IgnoreAPIServerEndpoint: true}
+	_, objects, err := ac.buildSecurityObjects()
+	assert.NoError(t, err)
+	assert.False(t, hasEventRules(objects))
+	cfg, err := ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	assert.Nil(t, cfg.EventExporter)
+
+	ac = &AgentDeployConfig{IgnoreAPIServerEndpoint: true, EventExporterEnabled: true, EventExporterWindow: 10 * time.Minute,
+		EventExporterCategories: []string{"nodes"}, EventExporterMaxEvents: 2, EventExporterMaxMessageSize: 512}
+	for _, psp := range []bool{false, true} {
+		ac.PodSecurityPolicyEnabled = psp
+		serviceAccountName, objects, err := ac.buildSecurityObjects()
+		assert.NoError(t, err)
+		assert.Equal(t, common.ApplicationName, serviceAccountName)
+		assert.True(t, hasEventRules(objects), "psp %t", psp)
+	}
+	ds, err := ac.buildDaemonSet("sa", false)
+	assert.NoError(t, err)
+	assert.Equal(t, pointer.Bool(true), ds.Spec.Template.Spec.AutomountServiceAccountToken)
+	cfg, err = ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg.EventExporter) {
+		assert.Equal(t, 10*time.Minute, cfg.EventExporter.WindowOrDefault())
+		assert.Equal(t, []config.EventCategory{config.EventCategoryNodes}, cfg.EventExporter.Categories)
+		assert.Equal(t, 2, cfg.EventExporter.MaxEventsPerWindow)
+	}
+
+	ac.EventExporterCategories = []string{"dns"}
+	_, err = ac.BuildAgentConfig()
+	assert.Error(t, err)
+}
+
+func TestEchoCheck(t *testing.T) {
+	ac := &AgentDeployConfig{EchoCheckEnabled: true, IgnoreAPIServerEndpoint: true}
+	cfg, err := ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, common.HostNetPodEchoPort, cfg.HostNetwork.EchoPort)
+	assert.Zero(t, cfg.PodNetwork.EchoPort)
+	echoArgs := []string{"checkTCPPort", "--node-port", "1013", "--echo"}
+	assert.Contains(t, cfg.HostNetwork.Jobs, config.Job{JobID: "tcp-n2n-echo", Args: echoArgs})
+	assert.Contains(t, cfg.PodNetwork.Jobs, config.Job{JobID: "tcp-p2n-echo", Args: echoArgs})
+
+	ds, err := ac.buildDaemonSet("sa", true)
+	assert.NoError(t, err)
+	assert.Contains(t, ds.Spec.Template.Spec.Containers[0].Ports, corev1.ContainerPort{Name: "echo", ContainerPort: common.HostNetPodEchoPort, Protocol: "TCP"})
+	ds, err = ac.buildDaemonSet("sa", false)
+	assert.NoError(t, err)
+	assert.Len(t, ds.Spec.Template.Spec.Containers[0].Ports, 2)
+
+	_, _, _, psp, err := ac.buildPodSecurityPolicy("sa")
+	assert.NoError(t, err)
+	assert.Contains(t, psp.Spec.HostPorts, policyv1beta1.HostPortRange{Min: common.HostNetPodEchoPort, Max: common.HostNetPodEchoPort})
+}
+
+func TestExternalIPCheck(t *testing.T) {
+	ac := &AgentDeployConfig{ExternalIPCheckEnabled: true, IgnoreAPIServerEndpoint: true}
+	cfg, err := ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	tcpArgs := []string{"checkTCPPort", "--node-port", "1011", "--node-address-type", "external"}
+	assert.Contains(t, cfg.HostNetwork.Jobs, config.Job{JobID: "tcp-n2n-ext", Args: tcpArgs})
+	assert.Contains(t, cfg.PodNetwork.Jobs, config.Job{JobID: "tcp-p2n-ext", Args: tcpArgs})
+	assert.NotContains(t, cfg.HostNetwork.Jobs, config.Job{JobID: "ping-n2n-ext", Args: []string{"pingHost", "--node-address-type", "external"}})
+
+	ac.PingEnabled = true
+	cfg, err = ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	assert.Contains(t, cfg.HostNetwork.Jobs, config.Job{JobID: "ping-n2n-ext", Args: []string{"pingHost", "--node-address-type", "external"}})
+	assert.Contains(t, cfg.PodNetwork.Jobs, config.Job{JobID: "ping-p2n-ext", Args: []string{"pingHost", "--node-address-type", "external"}})
+}
+
+func TestProxyRulesCheck(t *testing.T) {
+	ac := &AgentDeployConfig{ProxyRulesCheckEnabled: true, IgnoreAPIServerEndpoint: true}
+	cfg, err := ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	assert.Contains(t, cfg.HostNetwork.Jobs, config.Job{JobID: "proxy-rules-n", Args: []string{"checkProxyRules", "--period", "1m"}})
+	assert.Equal(t, []corev1.Capability{"NET_ADMIN"}, ac.agentCapabilities(true))
+	assert.Empty(t, ac.agentCapabilities(false))
+
+	ac.PingEnabled = true
+	assert.Equal(t, []corev1.Capability{"NET_ADMIN", "NET_RAW"}, ac.agentCapabilities(true))
+}
+
+func TestCABundle(t *testing.T) {
+	ac := &AgentDeployConfig{CABundleSecret: "internal-ca", CABundleKey: "bundle.pem", CABundleAppendToSystemPool: true, IgnoreAPIServerEndpoint: true}
+	cfg, err := ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, &config.CABundleConfig{File: "/config/ca-bundle/ca.crt", AppendToSystemPool: true}, cfg.CABundle)
+	for _, hostNetwork := range []bool{true, false} {
+		ds, err := ac.buildDaemonSet("sa", hostNetwork)
+		assert.NoError(t, err)
+		spec := ds.Spec.Template.Spec
+		assert.Contains(t, spec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: "ca-bundle", ReadOnly: true, MountPath: common.PathCABundleDir})
+		volume := spec.Volumes[len(spec.Volumes)-1]
+		assert.Equal(t, "ca-bundle", volume.Name)
+		assert.Equal(t, "internal-ca", volume.Secret.SecretName)
+		assert.Equal(t, []corev1.KeyToPath{{Key: "bundle.pem", Path: common.CABundleFilename}}, volume.Secret.Items)
+	}
+
+	ac = &AgentDeployConfig{CABundleConfigMap: "internal-ca", CABundleKey: common.CABundleFilename, IgnoreAPIServerEndpoint: true}
+	ds, err := ac.buildDaemonSet("sa", false)
+	assert.NoError(t, err)
+	volume := ds.Spec.Template.Spec.Volumes[len(ds.Spec.Template.Spec.Volumes)-1]
+	assert.Equal(t, "internal-ca", volume.ConfigMap.Name)
+
+	ac.CABundleSecret = "internal-ca"
+	_, err = ac.buildDaemonSet("sa", false)
+	assert.Error(t, err)
+
+	cfg, err = (&AgentDeployConfig{IgnoreAPIServerEndpoint: true}).BuildAgentConfig()
+	assert.NoError(t, err)
+	assert.Nil(t, cfg.CABundle)
+}
+
+func TestAPIServerAuthCheck(t *testing.T) {
+	ac := &AgentDeployConfig{APIServerAuthCheckEnabled: true, IgnoreAPIServerEndpoint: true}
+	cfg, err := ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	assert.Contains(t, cfg.PodNetwork.Jobs, config.Job{JobID: "auth-p2api-int", Args: []string{"checkAPIServerAuth", "--period", "1m", "--scale-period"}})
+
+	ds, err := ac.buildDaemonSet("sa", false)
+	assert.NoError(t, err)
+	assert.Equal(t, pointer.Bool(true), ds.Spec.Template.Spec.AutomountServiceAccountToken)
+	ds, err = ac.buildDaemonSet("sa", true)
+	assert.NoError(t, err)
+	assert.Equal(t, pointer.Bool(false), ds.Spec.Template.Spec.AutomountServiceAccountToken)
+
+	ac.DisableAutomountServiceAccountTokenForAgents = true
+	ds, err = ac.buildDaemonSet("sa", false)
+	assert.NoError(t, err)
+	assert.Nil(t, ds.Spec.Template.Spec.AutomountServiceAccountToken)
+}
+
+func TestNetworkChecks(t *testing.T) {
+	ac := &AgentDeployConfig{}
+	assert.NotContains(t, ac.controllerCommand(), "--network-checks-period=1m0s")
+	_, cr, _, _, _, _, err := ac.buildControllerDeployment()
+	assert.NoError(t, err)
+	rules := len(cr.Rules)
+
+	ac.NetworkChecksEnabled = true
+	assert.Contains(t, ac.controllerCommand(), "--network-checks-period=1m0s")
+	_, cr, _, _, _, _, err = ac.buildControllerDeployment()
+	assert.NoError(t, err)
+	assert.Len(t, cr.Rules, rules+2)
+	assert.Contains(t, cr.Rules, rbacv1.PolicyRule{
+		APIGroups: []string{config.NetworkCheckGroup},
+		Verbs:     []string{"update"},
+		Resources: []string{"networkchecks/status"},
+	})
+}
+
+func TestFindingEvents(t *testing.T) {
+	ac := &AgentDeployConfig{}
+	assert.NotContains(t, ac.controllerCommand(), "--finding-events")
+	_, cr, _, _, _, _, err := ac.buildControllerDeployment()
+	assert.NoError(t, err)
+	rules := len(cr.Rules)
+
+	ac.FindingEventsEnabled = true
+	assert.Subset(t, ac.controllerCommand(), []string{"--poll-period=1m0s", "--finding-events"})
+	_, cr, _, _, _, _, err = ac.buildControllerDeployment()
+	assert.NoError(t, err)
+	assert.Len(t, cr.Rules, rules+1)
+	assert.Contains(t, cr.Rules, rbacv1.PolicyRule{
+		APIGroups: []string{""},
+		Verbs:     []string{"create", "patch"},
+		Resources: []string{"events"},
+	})
+}
+
+func TestNodeLabelSelector(t *testing.T) {
+	ac := &AgentDeployConfig{}
+	ds, err := ac.buildDaemonSet("sa", true)
+	assert.NoError(t, err)
+	assert.Nil(t, ds.Spec.Template.Spec.Affinity)
+	assert.NotContains(t, strings.Join(ac.controllerCommand(), " "), "--node-label-selector")
+
+	ac.NodeLabelSelector = "worker.gardener.cloud/pool in (pool-a,pool-b),edge!=true"
+	for _, hostNetwork := range []bool{true, false} {
+		ds, err = ac.buildDaemonSet("sa", hostNetwork)
+		assert.NoError(t, err)
+		if assert.NotNil(t, ds.Spec.Template.Spec.Affinity) {
+			assert.Equal(t, &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{
+				{Key: "edge", Operator: corev1.NodeSelectorOpNotIn, Values: []string{"true"}},
+				{Key: "worker.gardener.cloud/pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"pool-a", "pool-b"}},
+			}}}}, ds.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution)
+		}
+	}
+	assert.Contains(t, ac.controllerCommand(), "--node-label-selector="+ac.NodeLabelSelector)
+
+	ac.NodeLabelSelector = "a in ("
+	_, err = ac.buildDaemonSet("sa", true)
+	assert.Error(t, err)
+}
+
+func TestRetentionAndLogDropping(t *testing.T) {
+	ac := &AgentDeployConfig{IgnoreAPIServerEndpoint: true}
+	cfg, err := ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, defaultRetentionHours, cfg.RetentionHoursFor(cfg.HostNetwork))
+	assert.Equal(t, defaultRetentionHours, cfg.RetentionHoursFor(cfg.PodNetwork))
+	assert.Nil(t, cfg.HostNetwork.LogDroppingFactor)
+	assert.Nil(t, cfg.PodNetwork.LogDroppingFactor)
+
+	ac = &AgentDeployConfig{
+		IgnoreAPIServerEndpoint:      true,
+		RetentionHours:               8,
+		HostNetworkRetentionHours:    24,
+		LogDroppingFactor:            0.5,
+		HostNetworkLogDroppingFactor: -1,
+		PodNetworkLogDroppingFactor:  0.9,
+	}
+	cfg, err = ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 24, cfg.RetentionHoursFor(cfg.HostNetwork))
+	assert.Equal(t, 8, cfg.RetentionHoursFor(cfg.PodNetwork))
+	assert.Equal(t, 0.5, cfg.LogDroppingFactorFor(cfg.HostNetwork))
+	assert.Equal(t, 0.9, cfg.LogDroppingFactorFor(cfg.PodNetwork))
+
+	ac = &AgentDeployConfig{IgnoreAPIServerEndpoint: true, PodNetworkRetentionHours: 500}
+	_, err = ac.BuildAgentConfig()
+	assert.Error(t, err)
+}
+
+func TestClusterConfigData(t *testing.T) {
+	cm := &corev1.ConfigMap{}
+	small := []byte("nodes: []\n")
+	large := []byte(strings.Repeat("- hostname: node\n", 100))
+
+	assert.NoError(t, SetClusterConfigData(cm, large, 1000))
+	assert.NotContains(t, cm.Data, common.ClusterConfigFilename)
+	assert.Less(t, len(cm.BinaryData[common.ClusterConfigCompressedFilename]), len(large))
+	data, err := ClusterConfigData(cm)
+	assert.NoError(t, err)
+	assert.Equal(t, large, data)
+
+	// switching back to the plain config removes the compressed one
+	assert.NoError(t, SetClusterConfigData(cm, small, 1000))
+	assert.NotContains(t, cm.BinaryData, common.ClusterConfigCompressedFilename)
+	data, err = ClusterConfigData(cm)
+	assert.NoError(t, err)
+	assert.Equal(t, small, data)
+
+	assert.Error(t, SetClusterConfigData(cm, make([]byte, MaxClusterConfigMapDataSize+1), MaxClusterConfigMapDataSize+1))
+}
@@ -0,0 +1,305 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package deploy
+
+import (
+	"path"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+// AgentConfigOption is an option of NewDefaultAgentConfig.
+// The options are a stable API for building the agent config programmatically, e.g. by a Gardener extension,
+// independent of the fields of AgentDeployConfig.
+type AgentConfigOption func(o *agentConfigOptions)
+
+type agentConfigOptions struct {
+	deploy          AgentDeployConfig
+	hostNetworkJobs []config.Job
+	podNetworkJobs  []config.Job
+	withoutJobs     []string
+	caBundle        *config.CABundleConfig
+}
+
+// NewDefaultAgentConfig builds the agent config with the default jobs of both networks, modified by the options.
+// Without options, the defaults are the same as for the command 'deploy' without flags.
+// The returned config is validated, including the arguments of all jobs.
+func NewDefaultAgentConfig(opts ...AgentConfigOption) (*config.AgentConfig, error) {
+	o := &agentConfigOptions{}
+	o.deploy.AddOptionFlags(pflag.NewFlagSet("defaults", pflag.ContinueOnError))
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	cfg, err := o.deploy.buildAgentConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.CABundle = o.caBundle
+	cfg.HostNetwork.Jobs = append(cfg.HostNetwork.Jobs, o.hostNetworkJobs...)
+	cfg.PodNetwork.Jobs = append(cfg.PodNetwork.Jobs, o.podNetworkJobs...)
+	for _, jobID := range o.withoutJobs {
+		if !removeJob(cfg, jobID) {
+			logrus.Warnf("job %q to remove does not exist", jobID)
+		}
+	}
+	if err := runners.ValidateAgentConfig(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// removeJob removes the job with the given ID from both networks and returns true if it has been found.
+func removeJob(cfg *config.AgentConfig, jobID string) bool {
+	found := false
+	for _, networkCfg := range []*config.NetworkConfig{cfg.HostNetwork, cfg.PodNetwork} {
+		var jobs []config.Job
+		for _, job := range networkCfg.Jobs {
+			if job.JobID == jobID {
+				found = true
+				continue
+			}
+			jobs = append(jobs, job)
+		}
+		networkCfg.Jobs = jobs
+	}
+	return found
+}
+
+// agentConfigOptions returns the options of NewDefaultAgentConfig for the deploy config.
+func (ac *AgentDeployConfig) agentConfigOptions() []AgentConfigOption {
+	opts := []AgentConfigOption{
+		WithDefaultPeriod(ac.DefaultPeriod),
+		WithExposedHostPort(ac.ExposedHostPort),
+		WithRetentionHours(ac.RetentionHours),
+		WithNetworkRetentionHours(ac.HostNetworkRetentionHours, ac.PodNetworkRetentionHours),
+		WithLogDroppingFactor(ac.LogDroppingFactor),
+		WithNetworkLogDroppingFactors(ac.HostNetworkLogDroppingFactor, ac.PodNetworkLogDroppingFactor),
+		WithIngressEndpoints(ac.IngressEndpoints...),
+		WithEndpointsFromURL(ac.EndpointsFromURL),
+		WithEnabledJobs(ac.EnabledJobs...),
+		WithDisabledJobs(ac.DisabledJobs...),
+		WithJobLabels(ac.JobLabels...),
+	}
+	if ac.IgnoreAPIServerEndpoint {
+		opts = append(opts, WithoutExternalAPIServer())
+	}
+	if ac.PingEnabled {
+		opts = append(opts, WithPing())
+	}
+	if ac.K8sExporterEnabled {
+		opts = append(opts, WithK8sExporter(ac.K8sExporterHeartbeat))
+	}
+	if ac.EventExporterEnabled {
+		opts = append(opts, WithEventExporter(ac.EventExporterWindow, ac.EventExporterMaxEvents, ac.EventExporterMaxMessageSize, ac.EventExporterCategories...))
+	}
+	if ac.PodHostPathDisabled {
+		opts = append(opts, WithForwardedPodObservations())
+	}
+	if ac.IMDSCheckEnabled {
+		opts = append(opts, WithIMDSCheck(ac.IMDSEndpoint))
+	}
+	if ac.EchoCheckEnabled {
+		opts = append(opts, WithEchoCheck())
+	}
+	if ac.APIServerAuthCheckEnabled {
+		opts = append(opts, WithAPIServerAuthCheck())
+	}
+	if ac.ExternalIPCheckEnabled {
+		opts = append(opts, WithExternalIPCheck())
+	}
+	if ac.ProxyRulesCheckEnabled {
+		opts = append(opts, WithProxyRulesCheck())
+	}
+	if ac.caBundleEnabled() {
+		opts = append(opts, WithCABundle(path.Join(common.PathCABundleDir, common.CABundleFilename), ac.CABundleAppendToSystemPool))
+	}
+	return opts
+}
+
+// WithDefaultPeriod sets the default period of the jobs (default 10s).
+func WithDefaultPeriod(period time.Duration) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.DefaultPeriod = period
+	}
+}
+
+// WithExposedHostPort sets the port of the GRPC server of the host network agent, which is checked by the node port jobs.
+func WithExposedHostPort(port int) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.ExposedHostPort = port
+	}
+}
+
+// WithoutExternalAPIServer omits the checks of the external address of the Kube API server.
+func WithoutExternalAPIServer() AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.IgnoreAPIServerEndpoint = true
+	}
+}
+
+// WithPing adds the ping jobs to both networks.
+func WithPing() AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.PingEnabled = true
+	}
+}
+
+// WithK8sExporter enables the update of node conditions and events with the given heartbeat period.
+func WithK8sExporter(heartbeat time.Duration) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.K8sExporterEnabled = true
+		o.deploy.K8sExporterHeartbeat = heartbeat
+	}
+}
+
+// WithEventExporter enables the event exporter, restricted to the given categories (default all).
+func WithEventExporter(window time.Duration, maxEvents, maxMessageSize int, categories ...string) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.EventExporterEnabled = true
+		o.deploy.EventExporterWindow = window
+		o.deploy.EventExporterMaxEvents = maxEvents
+		o.deploy.EventExporterMaxMessageSize = maxMessageSize
+		o.deploy.EventExporterCategories = categories
+	}
+}
+
+// WithForwardedPodObservations lets the pod network agent forward its observations to the host network agent.
+func WithForwardedPodObservations() AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.PodHostPathDisabled = true
+	}
+}
+
+// WithIMDSCheck adds the check of the instance metadata service to the host network.
+// The endpoint has the format <ip>:<port>, empty for the default endpoint.
+func WithIMDSCheck(endpoint string) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.IMDSCheckEnabled = true
+		o.deploy.IMDSEndpoint = endpoint
+	}
+}
+
+// WithEchoCheck adds the echo checks of the nodes to both networks and enables the echo port of the host network agent.
+func WithEchoCheck() AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.EchoCheckEnabled = true
+	}
+}
+
+// WithAPIServerAuthCheck adds the check of authenticated requests to the kube-apiserver to the pod network.
+func WithAPIServerAuthCheck() AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.APIServerAuthCheckEnabled = true
+	}
+}
+
+// WithExternalIPCheck adds the checks of the external IPs of the nodes to both networks.
+func WithExternalIPCheck() AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.ExternalIPCheckEnabled = true
+	}
+}
+
+// WithProxyRulesCheck adds the check of the kube-proxy rules to the host network.
+func WithProxyRulesCheck() AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.ProxyRulesCheckEnabled = true
+	}
+}
+
+// WithCABundle sets the file of the CA certificates used by the TLS-based checks, which are added to the system pool
+// if appendToSystemPool is set, or replace it otherwise.
+func WithCABundle(file string, appendToSystemPool bool) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.caBundle = &config.CABundleConfig{File: file, AppendToSystemPool: appendToSystemPool}
+	}
+}
+
+// WithIngressEndpoints adds the checks of the ingress endpoints in the format <name>=<host>:<port> to both networks.
+func WithIngressEndpoints(endpoints ...string) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.IngressEndpoints = append(o.deploy.IngressEndpoints, endpoints...)
+	}
+}
+
+// WithEndpointsFromURL adds the checks of the endpoint list loaded by the controller from the URL to both networks.
+func WithEndpointsFromURL(url string) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.EndpointsFromURL = url
+	}
+}
+
+// WithRetentionHours sets the retention of the observations in hours (default 4).
+func WithRetentionHours(hours int) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.RetentionHours = hours
+	}
+}
+
+// WithNetworkRetentionHours overrides the retention of the observations per network if != 0.
+func WithNetworkRetentionHours(hostNetwork, podNetwork int) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.HostNetworkRetentionHours = hostNetwork
+		o.deploy.PodNetworkRetentionHours = podNetwork
+	}
+}
+
+// WithLogDroppingFactor sets the fraction of observations dropped from the log if observations are logged.
+func WithLogDroppingFactor(factor float64) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.LogDroppingFactor = factor
+	}
+}
+
+// WithNetworkLogDroppingFactors overrides the log dropping factor per network if >= 0.
+func WithNetworkLogDroppingFactors(hostNetwork, podNetwork float64) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.HostNetworkLogDroppingFactor = hostNetwork
+		o.deploy.PodNetworkLogDroppingFactor = podNetwork
+	}
+}
+
+// WithEnabledJobs restricts the default jobs to the ones matching any of the job ID prefixes or glob patterns.
+func WithEnabledJobs(patterns ...string) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.EnabledJobs = append(o.deploy.EnabledJobs, patterns...)
+	}
+}
+
+// WithDisabledJobs removes the default jobs matching any of the job ID prefixes or glob patterns.
+func WithDisabledJobs(patterns ...string) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.DisabledJobs = append(o.deploy.DisabledJobs, patterns...)
+	}
+}
+
+// WithJobLabels sets labels on the default jobs in the format <job ID prefix or glob pattern>:<name>=<value>.
+func WithJobLabels(labels ...string) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.deploy.JobLabels = append(o.deploy.JobLabels, labels...)
+	}
+}
+
+// WithAdditionalJobs adds jobs to the host network and the pod network after the default jobs.
+func WithAdditionalJobs(hostNetwork, podNetwork []config.Job) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.hostNetworkJobs = append(o.hostNetworkJobs, hostNetwork...)
+		o.podNetworkJobs = append(o.podNetworkJobs, podNetwork...)
+	}
+}
+
+// WithoutJob removes the job with the given ID from both networks, including additional jobs.
+func WithoutJob(jobID string) AgentConfigOption {
+	return func(o *agentConfigOptions) {
+		o.withoutJobs = append(o.withoutJobs, jobID)
+	}
+}
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package deploy
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of the agent configs")
+
+// TestNewDefaultAgentConfigGolden guards the agent configs built for fixed option sets against accidental changes of the defaults.
+// Intended changes are recorded with 'go test ./pkg/deploy -run TestNewDefaultAgentConfigGolden -update'.
+func TestNewDefaultAgentConfigGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []AgentConfigOption
+	}{
+		{name: "default"},
+		{
+			name: "options",
+			opts: []AgentConfigOption{
+				WithDefaultPeriod(30 * time.Second),
+				WithPing(),
+				WithK8sExporter(5 * time.Minute),
+				WithEventExporter(10*time.Minute, 5, 512, "nodes"),
+				WithForwardedPodObservations(),
+				WithIMDSCheck(""),
+				WithEchoCheck(),
+				WithAPIServerAuthCheck(),
+				WithExternalIPCheck(),
+				WithProxyRulesCheck(),
+				WithCABundle("/config/ca-bundle/ca.crt", true),
+				WithIngressEndpoints("web=ingress.example.com:443"),
+				WithEndpointsFromURL("https://example.com/endpoints"),
+				WithRetentionHours(8),
+				WithNetworkRetentionHours(12, 0),
+				WithLogDroppingFactor(0.5),
+				WithNetworkLogDroppingFactors(-1, 0.9),
+				WithDisabledJobs("https-*2api-ext"),
+				WithJobLabels("tcp-*2api-ext:tier=external"),
+				WithAdditionalJobs(
+					[]config.Job{{JobID: "tcp-n2custom", Args: []string{"checkTCPPort", "--endpoints", "custom:10.0.0.1:443"}}},
+					nil),
+				WithoutJob("kubelet-n2n"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := NewDefaultAgentConfig(tt.opts...)
+			if !assert.NoError(t, err) {
+				return
+			}
+			actual, err := yaml.Marshal(cfg)
+			assert.NoError(t, err)
+			golden := filepath.Join("testdata", "agent-config-"+tt.name+".yaml")
+			if *updateGolden {
+				assert.NoError(t, os.WriteFile(golden, actual, 0644))
+			}
+			expected, err := os.ReadFile(golden)
+			assert.NoError(t, err)
+			assert.Equal(t, string(expected), string(actual))
+		})
+	}
+}
+
+func TestNewDefaultAgentConfig(t *testing.T) {
+	additional := config.Job{JobID: "tcp-p2custom", Args: []string{"checkTCPPort", "--endpoints", "custom:10.0.0.1:443"}}
+	cfg, err := NewDefaultAgentConfig(WithoutExternalAPIServer(), WithAdditionalJobs(nil, []config.Job{additional}), WithoutJob("tcp-n2n"))
+	assert.NoError(t, err)
+	assert.Contains(t, cfg.PodNetwork.Jobs, additional)
+	for _, job := range append(cfg.HostNetwork.Jobs, cfg.PodNetwork.Jobs...) {
+		assert.NotEqual(t, "tcp-n2n", job.JobID)
+		assert.NotEqual(t, "tcp-n2api-ext", job.JobID)
+	}
+
+	// the options of the deploy config build the same config
+	ac := &AgentDeployConfig{}
+	ac.AddOptionFlags(pflag.NewFlagSet("deploy", pflag.ContinueOnError))
+	expected, err := NewDefaultAgentConfig()
+	assert.NoError(t, err)
+	actual, err := ac.BuildAgentConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	_, err = NewDefaultAgentConfig(WithAdditionalJobs(nil, []config.Job{{JobID: "invalid-p", Args: []string{"unknownCommand"}}}))
+	assert.Error(t, err)
+	_, err = NewDefaultAgentConfig(WithAdditionalJobs([]config.Job{{JobID: "tcp-n2n", Args: []string{"pingHost"}}}, nil))
+	assert.Error(t, err, "duplicate job ID")
+}
@@ -0,0 +1,247 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+)
+
+const (
+	// maxParallelApply is the maximum number of objects created or updated simultaneously
+	maxParallelApply = 5
+	// waitPollInterval is the interval for checking the status of the workloads with option --wait
+	waitPollInterval = 2 * time.Second
+)
+
+type applyFunc func(ctx context.Context, obj Object) error
+
+// applyStages splits the objects into stages which must be applied one after the other.
+// Service accounts, RBAC objects, config maps and services are needed before the workloads can start.
+// Duplicate objects (same type, namespace and name) are only applied once, the last one wins.
+func applyStages(objects []Object) [][]Object {
+	var prerequisites, workloads []Object
+	index := map[string]int{}
+	for _, obj := range objects {
+		key := objectKey(obj)
+		if i, ok := index[key]; ok {
+			if i < 0 {
+				workloads[-i-1] = obj
+			} else {
+				prerequisites[i] = obj
+			}
+			continue
+		}
+		switch obj.(type) {
+		case *appsv1.DaemonSet, *appsv1.Deployment:
+			workloads = append(workloads, obj)
+			index[key] = -len(workloads)
+		default:
+			prerequisites = append(prerequisites, obj)
+			index[key] = len(prerequisites) - 1
+		}
+	}
+	var stages [][]Object
+	for _, stage := range [][]Object{prerequisites, workloads} {
+		if len(stage) > 0 {
+			stages = append(stages, stage)
+		}
+	}
+	return stages
+}
+
+// applyObjects applies the objects stage by stage. Within a stage, the objects are applied in parallel.
+// If a stage fails, the following stages are skipped. All errors of the failed stage are aggregated.
+func applyObjects(ctx context.Context, objects []Object, parallel int, apply applyFunc) error {
+	for _, stage := range applyStages(objects) {
+		if err := applyParallel(ctx, stage, parallel, apply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyParallel(ctx context.Context, objects []Object, parallel int, apply applyFunc) error {
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+		errs []error
+	)
+	sem := make(chan struct{}, parallel)
+	for _, obj := range objects {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(obj Object) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := apply(ctx, obj); err != nil {
+				lock.Lock()
+				errs = append(errs, err)
+				lock.Unlock()
+			}
+		}(obj)
+	}
+	wg.Wait()
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	return utilerrors.NewAggregate(errs)
+}
+
+// validateExistingServiceAccount checks that the pre-provisioned service account given by option --existing-service-account exists.
+func validateExistingServiceAccount(ctx context.Context, serviceAccounts typedcorev1.ServiceAccountsGetter, name string) error {
+	if name == "" {
+		return nil
+	}
+	_, err := serviceAccounts.ServiceAccounts(common.NamespaceKubeSystem).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("existing service account %s/%s not found", common.NamespaceKubeSystem, name)
+	}
+	if err != nil {
+		return fmt.Errorf("error getting existing service account %s/%s: %w", common.NamespaceKubeSystem, name, err)
+	}
+	return nil
+}
+
+// deleteOutdatedAgentConfigMaps deletes the agent config maps not referenced by the current daemon sets, i.e. the immutable
+// config maps of other generations and the mutable config map if the current one is immutable.
+// If keepPrevious is true, the newest outdated config map is kept for agent pods not rolled out yet.
+func deleteOutdatedAgentConfigMaps(ctx context.Context, log logrus.FieldLogger, configMaps typedcorev1.ConfigMapInterface, current string, keepPrevious bool) error {
+	list, err := configMaps.List(ctx, metav1.ListOptions{LabelSelector: common.LabelKeyAgentConfigGeneration})
+	if err != nil {
+		return fmt.Errorf("error listing agent config maps: %w", err)
+	}
+	var outdated []corev1.ConfigMap
+	for _, item := range list.Items {
+		if item.Name != current {
+			outdated = append(outdated, item)
+		}
+	}
+	if current != common.NameAgentConfigMap {
+		cm, err := configMaps.Get(ctx, common.NameAgentConfigMap, metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("error getting config map %s/%s: %w", common.NamespaceKubeSystem, common.NameAgentConfigMap, err)
+		}
+		if err == nil {
+			outdated = append(outdated, *cm)
+		}
+	}
+	sort.Slice(outdated, func(i, j int) bool {
+		return outdated[j].CreationTimestamp.Before(&outdated[i].CreationTimestamp)
+	})
+	if keepPrevious && len(outdated) > 0 {
+		outdated = outdated[1:]
+	}
+	var errs []error
+	for _, cm := range outdated {
+		if err := configMaps.Delete(ctx, cm.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("error deleting config map %s/%s: %w", common.NamespaceKubeSystem, cm.Name, err))
+			continue
+		}
+		log.Infof("deleted outdated configmap %s/%s", common.NamespaceKubeSystem, cm.Name)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func objectKey(obj Object) string {
+	name, _ := typename(obj)
+	return fmt.Sprintf("%s/%s/%s", name, obj.GetNamespace(), obj.GetName())
+}
+
+// waitForWorkloads waits until all daemon sets and deployments of the objects are ready.
+// The status is logged whenever it changes.
+func waitForWorkloads(log logrus.FieldLogger, clientset *kubernetes.Clientset, objects []Object, timeout time.Duration) error {
+	ctx := context.Background()
+	progress := map[string]string{}
+	var pending []string
+	err := wait.PollImmediate(waitPollInterval, timeout, func() (bool, error) {
+		pending = nil
+		for _, obj := range objects {
+			var (
+				ready  bool
+				status string
+			)
+			switch v := obj.(type) {
+			case *appsv1.DaemonSet:
+				ds, err := clientset.AppsV1().DaemonSets(v.Namespace).Get(ctx, v.Name, metav1.GetOptions{})
+				if err != nil {
+					return false, fmt.Errorf("error getting daemonset %s/%s: %w", v.Namespace, v.Name, err)
+				}
+				ready, status = daemonSetStatus(ds)
+			case *appsv1.Deployment:
+				deployment, err := clientset.AppsV1().Deployments(v.Namespace).Get(ctx, v.Name, metav1.GetOptions{})
+				if err != nil {
+					return false, fmt.Errorf("error getting deployment %s/%s: %w", v.Namespace, v.Name, err)
+				}
+				ready, status = deploymentStatus(deployment)
+			default:
+				continue
+			}
+			kind, _ := typename(obj)
+			name := fmt.Sprintf("%s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+			if progress[name] != status {
+				progress[name] = status
+				log.Infof("%s: %s", name, status)
+			}
+			if !ready {
+				pending = append(pending, fmt.Sprintf("%s (%s)", name, status))
+			}
+		}
+		return len(pending) == 0, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("timeout after %s waiting for %s", timeout, strings.Join(pending, ", "))
+	}
+	return err
+}
+
+// daemonSetStatus returns true if the current generation of the daemon set has been rolled out
+// and all desired pods are ready.
+func daemonSetStatus(ds *appsv1.DaemonSet) (bool, string) {
+	s := ds.Status
+	if s.ObservedGeneration < ds.Generation {
+		return false, "waiting for rollout to be observed"
+	}
+	status := fmt.Sprintf("%d/%d ready, %d updated", s.NumberReady, s.DesiredNumberScheduled, s.UpdatedNumberScheduled)
+	return s.NumberReady == s.DesiredNumberScheduled && s.UpdatedNumberScheduled == s.DesiredNumberScheduled, status
+}
+
+// deploymentStatus returns true if the current generation of the deployment has been rolled out and it is available.
+func deploymentStatus(d *appsv1.Deployment) (bool, string) {
+	s := d.Status
+	if s.ObservedGeneration < d.Generation {
+		return false, "waiting for rollout to be observed"
+	}
+	available := false
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentAvailable && c.Status == corev1.ConditionTrue {
+			available = true
+		}
+	}
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	status := fmt.Sprintf("%d/%d available, %d updated", s.AvailableReplicas, replicas, s.UpdatedReplicas)
+	return available && s.UpdatedReplicas == replicas && s.AvailableReplicas >= replicas, status
+}
@@ -0,0 +1,227 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+)
+
+func meta(name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: name, Namespace: "kube-system"}
+}
+
+func TestApplyStages(t *testing.T) {
+	ds := &appsv1.DaemonSet{ObjectMeta: meta("ds")}
+	deployment := &appsv1.Deployment{ObjectMeta: meta("deployment")}
+	sa := &corev1.ServiceAccount{ObjectMeta: meta("sa")}
+	cr := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cr"}}
+	cm1 := &corev1.ConfigMap{ObjectMeta: meta("cm"), Data: map[string]string{"v": "1"}}
+	cm2 := &corev1.ConfigMap{ObjectMeta: meta("cm"), Data: map[string]string{"v": "2"}}
+	svc := &corev1.Service{ObjectMeta: meta("cm")}
+
+	stages := applyStages([]Object{ds, sa, cm1, deployment, cr, svc, cm2})
+	assert.Equal(t, [][]Object{{sa, cm2, cr, svc}, {ds, deployment}}, stages)
+
+	stages = applyStages([]Object{cm1})
+	assert.Equal(t, [][]Object{{cm1}}, stages)
+}
+
+func TestApplyObjects(t *testing.T) {
+	var objects []Object
+	for i := 0; i < 10; i++ {
+		objects = append(objects, &corev1.ConfigMap{ObjectMeta: meta(fmt.Sprintf("cm%d", i))})
+	}
+	objects = append(objects, &appsv1.DaemonSet{ObjectMeta: meta("ds")})
+
+	var (
+		lock      sync.Mutex
+		applied   []string
+		active    int32
+		maxActive int32
+	)
+	apply := func(failing ...string) applyFunc {
+		return func(ctx context.Context, obj Object) error {
+			n := atomic.AddInt32(&active, 1)
+			defer atomic.AddInt32(&active, -1)
+			lock.Lock()
+			if n > maxActive {
+				maxActive = n
+			}
+			applied = append(applied, obj.GetName())
+			lock.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			for _, name := range failing {
+				if obj.GetName() == name {
+					return fmt.Errorf("error creating configmap %s/%s: failed", obj.GetNamespace(), name)
+				}
+			}
+			return nil
+		}
+	}
+
+	err := applyObjects(context.Background(), objects, 3, apply())
+	assert.NoError(t, err)
+	assert.Len(t, applied, 11)
+	assert.Equal(t, "ds", applied[10], "workloads are applied last")
+	assert.LessOrEqual(t, maxActive, int32(3))
+
+	applied = nil
+	err = applyObjects(context.Background(), objects, 3, apply("cm7", "cm2"))
+	assert.EqualError(t, err, "[error creating configmap kube-system/cm2: failed, error creating configmap kube-system/cm7: failed]")
+	assert.Len(t, applied, 10, "workloads are skipped")
+}
+
+func TestDaemonSetStatus(t *testing.T) {
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Generation: 2},
+		Status: appsv1.DaemonSetStatus{
+			ObservedGeneration:     1,
+			DesiredNumberScheduled: 3,
+			NumberReady:            3,
+			UpdatedNumberScheduled: 3,
+		},
+	}
+	ready, status := daemonSetStatus(ds)
+	assert.False(t, ready)
+	assert.Equal(t, "waiting for rollout to be observed", status)
+
+	ds.Status.ObservedGeneration = 2
+	ds.Status.UpdatedNumberScheduled = 1
+	ready, status = daemonSetStatus(ds)
+	assert.False(t, ready)
+	assert.Equal(t, "3/3 ready, 1 updated", status)
+
+	ds.Status.UpdatedNumberScheduled = 3
+	ready, _ = daemonSetStatus(ds)
+	assert.True(t, ready)
+}
+
+func TestDeploymentStatus(t *testing.T) {
+	replicas := int32(1)
+	d := &appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{
+			UpdatedReplicas:   1,
+			AvailableReplicas: 1,
+		},
+	}
+	ready, status := deploymentStatus(d)
+	assert.False(t, ready)
+	assert.Equal(t, "1/1 available, 1 updated", status)
+
+	d.Status.Conditions = []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue}}
+	ready, _ = deploymentStatus(d)
+	assert.True(t, ready)
+}
+
+// fakeServiceAccounts only implements Get for the given service accounts.
+type fakeServiceAccounts struct {
+	typedcorev1.ServiceAccountInterface
+	names []string
+}
+
+func (f *fakeServiceAccounts) ServiceAccounts(_ string) typedcorev1.ServiceAccountInterface {
+	return f
+}
+
+func (f *fakeServiceAccounts) Get(_ context.Context, name string, _ metav1.GetOptions) (*corev1.ServiceAccount, error) {
+	for _, n := range f.names {
+		if n == name {
+			return &corev1.ServiceAccount{ObjectMeta: meta(name)}, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "serviceaccounts"}, name)
+}
+
+func TestValidateExistingServiceAccount(t *testing.T) {
+	serviceAccounts := &fakeServiceAccounts{names: []string{"nwpd-managed"}}
+	ctx := context.Background()
+	assert.NoError(t, validateExistingServiceAccount(ctx, serviceAccounts, ""))
+	assert.NoError(t, validateExistingServiceAccount(ctx, serviceAccounts, "nwpd-managed"))
+	assert.EqualError(t, validateExistingServiceAccount(ctx, serviceAccounts, "missing"), "existing service account kube-system/missing not found")
+}
+
+// fakeConfigMaps implements List, Get and Delete for the given config maps.
+type fakeConfigMaps struct {
+	typedcorev1.ConfigMapInterface
+	items   []corev1.ConfigMap
+	deleted []string
+}
+
+func (f *fakeConfigMaps) List(_ context.Context, opts metav1.ListOptions) (*corev1.ConfigMapList, error) {
+	list := &corev1.ConfigMapList{}
+	for _, item := range f.items {
+		if _, ok := item.Labels[opts.LabelSelector]; ok {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeConfigMaps) Get(_ context.Context, name string, _ metav1.GetOptions) (*corev1.ConfigMap, error) {
+	for _, item := range f.items {
+		if item.Name == name {
+			return item.DeepCopy(), nil
+		}
+	}
+	return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+}
+
+func (f *fakeConfigMaps) Delete(_ context.Context, name string, _ metav1.DeleteOptions) error {
+	f.deleted = append(f.deleted, name)
+	return nil
+}
+
+func TestDeleteOutdatedAgentConfigMaps(t *testing.T) {
+	now := time.Now()
+	configMap := func(name string, age time.Duration, versioned bool) corev1.ConfigMap {
+		cm := corev1.ConfigMap{ObjectMeta: meta(name)}
+		cm.CreationTimestamp = metav1.NewTime(now.Add(-age))
+		if versioned {
+			cm.Labels = map[string]string{common.LabelKeyAgentConfigGeneration: name}
+		}
+		return cm
+	}
+	items := []corev1.ConfigMap{
+		configMap(common.NameAgentConfigMap, 3*time.Hour, false),
+		configMap("gen1", 2*time.Hour, true),
+		configMap("gen2", 1*time.Hour, true),
+		configMap("gen3", 0, true),
+	}
+	ctx := context.Background()
+	log := logrus.New()
+
+	for _, tc := range []struct {
+		current      string
+		keepPrevious bool
+		deleted      []string
+	}{
+		{current: "gen3", deleted: []string{"gen2", "gen1", common.NameAgentConfigMap}},
+		{current: "gen3", keepPrevious: true, deleted: []string{"gen1", common.NameAgentConfigMap}},
+		{current: common.NameAgentConfigMap, deleted: []string{"gen3", "gen2", "gen1"}},
+		{current: common.NameAgentConfigMap, keepPrevious: true, deleted: []string{"gen2", "gen1"}},
+	} {
+		configMaps := &fakeConfigMaps{items: items}
+		assert.NoError(t, deleteOutdatedAgentConfigMaps(ctx, log, configMaps, tc.current, tc.keepPrevious))
+		assert.Equal(t, tc.deleted, configMaps.deleted, "%+v", tc)
+	}
+}
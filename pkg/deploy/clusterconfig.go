@@ -5,16 +5,44 @@
 package deploy
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gardener/network-problem-detector/pkg/common"
 	"github.com/gardener/network-problem-detector/pkg/common/config"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
 )
 
+var (
+	// ErrShootInfoNotFound is returned if the shoot info config map does not exist, i.e. the cluster is not a Gardener shoot.
+	ErrShootInfoNotFound = errors.New("shoot info not found")
+	// ErrShootInfoDomainMissing is returned if the shoot info config map has no 'domain' key.
+	ErrShootInfoDomainMissing = errors.New("shoot info domain missing")
+	// ErrAPIServerLookupFailed is returned if the DNS lookup of the shoot apiserver failed.
+	ErrAPIServerLookupFailed = errors.New("DNS lookup of shoot apiserver failed")
+	// ErrNodeLocalDNSNotDeployed is returned if the node-local-dns daemon set does not exist.
+	ErrNodeLocalDNSNotDeployed = errors.New("node-local-dns not deployed")
+)
+
+const (
+	lookupInitialBackoff = 1 * time.Second
+	lookupMaxBackoff     = 16 * time.Second
+)
+
+// BuildClusterConfig builds the cluster config from the nodes and the agent pods.
+// Nodes being deleted are omitted, as are agent pods being deleted or running on omitted nodes,
+// so that the agents stop checking them before they are gone.
 func BuildClusterConfig(nodes []*corev1.Node, agentPods []*corev1.Pod,
 	internalKubeAPIServer, kubeAPIServer *config.Endpoint) (*config.ClusterConfig, error) {
 	clusterConfig := &config.ClusterConfig{
@@ -22,15 +50,25 @@ func BuildClusterConfig(nodes []*corev1.Node, agentPods []*corev1.Pod,
 		KubeAPIServer:         kubeAPIServer,
 	}
 
+	nodeNames := map[string]bool{}
 	for _, n := range nodes {
+		if n.DeletionTimestamp != nil {
+			continue
+		}
+		nodeNames[n.Name] = true
 		hostname := ""
 		ip := ""
+		externalIP := ""
 		for _, addr := range n.Status.Addresses {
 			switch addr.Type {
 			case "Hostname":
 				hostname = addr.Address
 			case "InternalIP":
 				ip = addr.Address
+			case "ExternalIP":
+				if externalIP == "" {
+					externalIP = addr.Address
+				}
 			}
 		}
 		if hostname == "" || ip == "" {
@@ -39,15 +77,22 @@ func BuildClusterConfig(nodes []*corev1.Node, agentPods []*corev1.Pod,
 		clusterConfig.Nodes = append(clusterConfig.Nodes, config.Node{
 			Hostname:   hostname,
 			InternalIP: ip,
+			ExternalIP: externalIP,
+			Zone:       n.Labels[corev1.LabelTopologyZone],
+			Pool:       n.Labels[common.LabelKeyWorkerPool],
 		})
 	}
 
 	for _, p := range agentPods {
+		if p.DeletionTimestamp != nil || !nodeNames[p.Spec.NodeName] {
+			continue
+		}
 		clusterConfig.PodEndpoints = append(clusterConfig.PodEndpoints, config.PodEndpoint{
-			Nodename: p.Spec.NodeName,
-			Podname:  p.Name,
-			PodIP:    p.Status.PodIP,
-			Port:     common.PodNetPodGRPCPort,
+			Nodename:  p.Spec.NodeName,
+			Podname:   p.Name,
+			PodIP:     p.Status.PodIP,
+			Port:      common.PodNetPodGRPCPort,
+			StartTime: p.Status.StartTime,
 		})
 	}
 
@@ -65,19 +110,262 @@ func BuildClusterConfig(nodes []*corev1.Node, agentPods []*corev1.Pod,
 	return clusterConfig, nil
 }
 
-func GetAPIServerEndpointFromShootInfo(shootInfo *corev1.ConfigMap) (*config.Endpoint, error) {
+// GetShootInfo gets the Gardener shoot info config map. If it does not exist, an error wrapping ErrShootInfoNotFound is returned.
+func GetShootInfo(ctx context.Context, clientset kubernetes.Interface) (*corev1.ConfigMap, error) {
+	shootInfo, err := clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem).Get(ctx, common.NameGardenerShootInfo, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: configmap %s/%s does not exist, the cluster is probably not a Gardener shoot (use --ignore-gardener-kube-api-server to skip the lookup)",
+				ErrShootInfoNotFound, common.NamespaceKubeSystem, common.NameGardenerShootInfo)
+		}
+		return nil, fmt.Errorf("error getting configmap %s/%s: %w", common.NamespaceKubeSystem, common.NameGardenerShootInfo, err)
+	}
+	return shootInfo, nil
+}
+
+// GetAPIServerEndpointFromShootInfo determines the external endpoint of the kube-apiserver from the 'domain' key of the shoot info.
+// The DNS lookup is retried with exponential backoff until it succeeds or the context is done.
+func GetAPIServerEndpointFromShootInfo(ctx context.Context, shootInfo *corev1.ConfigMap) (*config.Endpoint, error) {
 	domain, ok := shootInfo.Data["domain"]
-	if !ok {
-		return nil, fmt.Errorf("missing 'domain' key in configmap %s/%s", common.NamespaceKubeSystem, common.NameGardenerShootInfo)
+	if !ok || domain == "" {
+		return nil, fmt.Errorf("%w: missing 'domain' key in configmap %s/%s", ErrShootInfoDomainMissing, common.NamespaceKubeSystem, common.NameGardenerShootInfo)
 	}
 	apiServer := "api." + domain
-	ips, err := net.LookupIP(apiServer)
+	backoff := lookupInitialBackoff
+	for {
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip", apiServer)
+		if err == nil && len(ips) > 0 {
+			return &config.Endpoint{
+				Hostname: apiServer,
+				IP:       ips[0].String(),
+				Port:     443,
+			}, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("no IP addresses")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w for %s: %s", ErrAPIServerLookupFailed, apiServer, err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > lookupMaxBackoff {
+			backoff = lookupMaxBackoff
+		}
+	}
+}
+
+// GetKubeDNSEndpoint gets the kube-dns service and determines the endpoint of the cluster DNS from it.
+func GetKubeDNSEndpoint(ctx context.Context, clientset kubernetes.Interface) (*config.Endpoint, error) {
+	svc, err := clientset.CoreV1().Services(common.NamespaceKubeSystem).Get(ctx, common.NameKubeDNSService, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting service %s/%s: %w", common.NamespaceKubeSystem, common.NameKubeDNSService, err)
+	}
+	return KubeDNSEndpointFromService(svc)
+}
+
+// KubeDNSEndpointFromService determines the endpoint of the cluster DNS from the cluster IP and UDP port of the kube-dns service.
+func KubeDNSEndpointFromService(svc *corev1.Service) (*config.Endpoint, error) {
+	ip := svc.Spec.ClusterIP
+	if ip == "" || ip == corev1.ClusterIPNone || net.ParseIP(ip) == nil {
+		return nil, fmt.Errorf("service %s/%s has no valid cluster IP: %q", svc.Namespace, svc.Name, ip)
+	}
+	for _, port := range svc.Spec.Ports {
+		if port.Protocol == corev1.ProtocolUDP {
+			return &config.Endpoint{
+				Hostname: common.DomainNameKubeDNSService,
+				IP:       ip,
+				Port:     int(port.Port),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("service %s/%s has no UDP port", svc.Namespace, svc.Name)
+}
+
+// GetNodeLocalDNSEndpoint returns the endpoint of node-local-dns if its daemon set is deployed.
+// If it does not exist, an error wrapping ErrNodeLocalDNSNotDeployed is returned.
+func GetNodeLocalDNSEndpoint(ctx context.Context, clientset kubernetes.Interface) (*config.Endpoint, error) {
+	_, err := clientset.AppsV1().DaemonSets(common.NamespaceKubeSystem).Get(ctx, common.NameNodeLocalDNS, metav1.GetOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("error looking up shoot apiserver %s: %s", apiServer, err)
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: daemonset %s/%s does not exist", ErrNodeLocalDNSNotDeployed, common.NamespaceKubeSystem, common.NameNodeLocalDNS)
+		}
+		return nil, fmt.Errorf("error getting daemonset %s/%s: %w", common.NamespaceKubeSystem, common.NameNodeLocalDNS, err)
 	}
+	return NodeLocalDNSEndpoint(), nil
+}
+
+// NodeLocalDNSEndpoint returns the endpoint of node-local-dns, which listens on the same link-local address on each node.
+func NodeLocalDNSEndpoint() *config.Endpoint {
 	return &config.Endpoint{
-		Hostname: apiServer,
-		IP:       ips[0].String(),
-		Port:     443,
-	}, nil
+		Hostname: common.NameNodeLocalDNS,
+		IP:       common.NodeLocalDNSIP,
+		Port:     53,
+	}
+}
+
+// LoadBalancerEndpoints returns the external endpoints of the services of type LoadBalancer which are selected
+// for the hairpin check by the annotation common.AnnotationCheckLoadBalancer or by the optional label selector.
+// Services without ingress or with an invalid annotation are skipped and reported as errors.
+func LoadBalancerEndpoints(services []*corev1.Service, selector labels.Selector) ([]config.Endpoint, []error) {
+	var (
+		endpoints []config.Endpoint
+		errs      []error
+	)
+	for _, svc := range services {
+		value, ok := svc.Annotations[common.AnnotationCheckLoadBalancer]
+		if !ok && IsSelectedLoadBalancer(svc, selector) {
+			value, ok = "true", true
+		}
+		if !ok || value == "false" {
+			continue
+		}
+		if svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+			errs = append(errs, fmt.Errorf("service %s/%s: not of type LoadBalancer", svc.Namespace, svc.Name))
+			continue
+		}
+		port, err := loadBalancerPort(svc, value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("service %s/%s: %w", svc.Namespace, svc.Name, err))
+			continue
+		}
+		if len(svc.Status.LoadBalancer.Ingress) == 0 {
+			errs = append(errs, fmt.Errorf("service %s/%s: no load balancer ingress", svc.Namespace, svc.Name))
+			continue
+		}
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			address := ingress.IP
+			if address == "" {
+				// some cloud providers only provide a DNS name, it is resolved on dialing
+				address = ingress.Hostname
+			}
+			if address == "" {
+				continue
+			}
+			endpoints = append(endpoints, config.Endpoint{
+				Hostname: common.DestHostPrefixLoadBalancer + svc.Namespace + "-" + svc.Name,
+				IP:       address,
+				Port:     port,
+			})
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		cmp := strings.Compare(endpoints[i].Hostname, endpoints[j].Hostname)
+		if cmp == 0 {
+			cmp = strings.Compare(endpoints[i].IP, endpoints[j].IP)
+		}
+		return cmp < 0
+	})
+	return endpoints, errs
+}
+
+// IsSelectedLoadBalancer returns true if the service is of type LoadBalancer and matches the label selector.
+func IsSelectedLoadBalancer(svc *corev1.Service, selector labels.Selector) bool {
+	return selector != nil && !selector.Empty() && svc.Spec.Type == corev1.ServiceTypeLoadBalancer && selector.Matches(labels.Set(svc.Labels))
+}
+
+// ServiceEndpoints returns the cluster IP endpoints of the services matching the label selector, using the first TCP port
+// of each service. Services without cluster IP (headless or of type ExternalName) or without TCP port are skipped and reported as errors.
+func ServiceEndpoints(services []*corev1.Service, selector labels.Selector) ([]config.Endpoint, []error) {
+	var (
+		endpoints []config.Endpoint
+		errs      []error
+	)
+	for _, svc := range services {
+		if !IsSelectedService(svc, selector) {
+			continue
+		}
+		ip := svc.Spec.ClusterIP
+		if ip == "" || ip == corev1.ClusterIPNone || net.ParseIP(ip) == nil {
+			errs = append(errs, fmt.Errorf("service %s/%s: no valid cluster IP %q", svc.Namespace, svc.Name, ip))
+			continue
+		}
+		port := 0
+		for _, p := range svc.Spec.Ports {
+			if isTCP(p) {
+				port = int(p.Port)
+				break
+			}
+		}
+		if port == 0 {
+			errs = append(errs, fmt.Errorf("service %s/%s: no TCP port", svc.Namespace, svc.Name))
+			continue
+		}
+		endpoints = append(endpoints, config.Endpoint{
+			Hostname: common.DestHostPrefixService + svc.Namespace + "-" + svc.Name,
+			IP:       ip,
+			Port:     port,
+		})
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return strings.Compare(endpoints[i].Hostname, endpoints[j].Hostname) < 0
+	})
+	return endpoints, errs
+}
+
+// IsSelectedService returns true if the service matches the label selector.
+func IsSelectedService(svc *corev1.Service, selector labels.Selector) bool {
+	return selector != nil && !selector.Empty() && selector.Matches(labels.Set(svc.Labels))
+}
+
+func loadBalancerPort(svc *corev1.Service, value string) (int, error) {
+	if value == "true" {
+		for _, port := range svc.Spec.Ports {
+			if isTCP(port) {
+				return int(port.Port), nil
+			}
+		}
+		return 0, fmt.Errorf("no TCP port")
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value of annotation %s: %q (expected 'true' or port number)", common.AnnotationCheckLoadBalancer, value)
+	}
+	for _, p := range svc.Spec.Ports {
+		if int(p.Port) == port && isTCP(p) {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no TCP port %d", port)
+}
+
+func isTCP(port corev1.ServicePort) bool {
+	// protocol defaults to TCP
+	return port.Protocol == corev1.ProtocolTCP || port.Protocol == ""
+}
+
+// MaintenanceWindows reads the maintenance windows from the annotation common.AnnotationMaintenanceWindows of the
+// cluster config map and prunes the expired ones. It returns the remaining windows and the new annotation value,
+// which is empty if no window remains. The annotation value is unchanged if no window has expired.
+func MaintenanceWindows(cm *corev1.ConfigMap, now time.Time) ([]config.MaintenanceWindow, string, error) {
+	value := cm.Annotations[common.AnnotationMaintenanceWindows]
+	windows, err := config.ParseMaintenanceWindows(value)
+	if err != nil {
+		return nil, value, fmt.Errorf("invalid value of annotation %s: %w", common.AnnotationMaintenanceWindows, err)
+	}
+	pruned := config.PruneMaintenanceWindows(windows, now)
+	if len(pruned) == len(windows) {
+		return pruned, value, nil
+	}
+	if len(pruned) == 0 {
+		return nil, "", nil
+	}
+	data, err := json.Marshal(pruned)
+	if err != nil {
+		return nil, value, err
+	}
+	return pruned, string(data), nil
+}
+
+// SetMaintenanceWindowsAnnotation sets the annotation common.AnnotationMaintenanceWindows or removes it if the value is empty.
+func SetMaintenanceWindowsAnnotation(cm *corev1.ConfigMap, value string) {
+	if value == "" {
+		delete(cm.Annotations, common.AnnotationMaintenanceWindows)
+		return
+	}
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[common.AnnotationMaintenanceWindows] = value
 }
@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package deploy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+func newLoadBalancerService(name, annotation string, ingress ...corev1.LoadBalancerIngress) *corev1.Service {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+		},
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{
+				{Name: "dns", Protocol: corev1.ProtocolUDP, Port: 53},
+				{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80},
+				{Name: "https", Protocol: corev1.ProtocolTCP, Port: 443},
+			},
+		},
+		Status: corev1.ServiceStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{Ingress: ingress},
+		},
+	}
+	if annotation != "" {
+		svc.Annotations = map[string]string{common.AnnotationCheckLoadBalancer: annotation}
+	}
+	return svc
+}
+
+func TestLoadBalancerEndpoints(t *testing.T) {
+	clusterIPService := newLoadBalancerService("cluster-ip", "true")
+	clusterIPService.Spec.Type = corev1.ServiceTypeClusterIP
+
+	endpoints, errs := LoadBalancerEndpoints([]*corev1.Service{
+		newLoadBalancerService("web", "true", corev1.LoadBalancerIngress{IP: "1.2.3.4"}),
+		newLoadBalancerService("secure", "443", corev1.LoadBalancerIngress{Hostname: "lb.example.com"}),
+		newLoadBalancerService("not-selected", "", corev1.LoadBalancerIngress{IP: "1.2.3.5"}),
+		newLoadBalancerService("disabled", "false", corev1.LoadBalancerIngress{IP: "1.2.3.6"}),
+		newLoadBalancerService("pending", "true"),
+		newLoadBalancerService("wrong-port", "8080", corev1.LoadBalancerIngress{IP: "1.2.3.7"}),
+		newLoadBalancerService("udp-port", "53", corev1.LoadBalancerIngress{IP: "1.2.3.8"}),
+		clusterIPService,
+	}, nil)
+	assert.Equal(t, []config.Endpoint{
+		{Hostname: "lb-default-secure", IP: "lb.example.com", Port: 443},
+		{Hostname: "lb-default-web", IP: "1.2.3.4", Port: 80},
+	}, endpoints)
+	if assert.Len(t, errs, 4) {
+		assert.Contains(t, errs[0].Error(), "default/pending: no load balancer ingress")
+		assert.Contains(t, errs[1].Error(), "default/wrong-port: no TCP port 8080")
+		assert.Contains(t, errs[2].Error(), "default/udp-port: no TCP port 53")
+		assert.Contains(t, errs[3].Error(), "default/cluster-ip: not of type LoadBalancer")
+	}
+}
+
+func TestLoadBalancerEndpointsWithSelector(t *testing.T) {
+	selector, err := labels.Parse("ingress=true")
+	assert.NoError(t, err)
+
+	withLabels := func(svc *corev1.Service) *corev1.Service {
+		svc.Labels = map[string]string{"ingress": "true"}
+		return svc
+	}
+	clusterIPService := withLabels(newLoadBalancerService("cluster-ip", ""))
+	clusterIPService.Spec.Type = corev1.ServiceTypeClusterIP
+
+	endpoints, errs := LoadBalancerEndpoints([]*corev1.Service{
+		withLabels(newLoadBalancerService("selected", "", corev1.LoadBalancerIngress{IP: "1.2.3.4"})),
+		withLabels(newLoadBalancerService("annotated", "443", corev1.LoadBalancerIngress{IP: "1.2.3.5"})),
+		withLabels(newLoadBalancerService("disabled", "false", corev1.LoadBalancerIngress{IP: "1.2.3.6"})),
+		newLoadBalancerService("not-selected", "", corev1.LoadBalancerIngress{IP: "1.2.3.7"}),
+		clusterIPService,
+	}, selector)
+	assert.Empty(t, errs)
+	assert.Equal(t, []config.Endpoint{
+		{Hostname: "lb-default-annotated", IP: "1.2.3.5", Port: 443},
+		{Hostname: "lb-default-selected", IP: "1.2.3.4", Port: 80},
+	}, endpoints)
+}
+
+func TestServiceEndpoints(t *testing.T) {
+	selector, err := labels.Parse(common.DefaultServiceSelector)
+	assert.NoError(t, err)
+
+	newService := func(name, clusterIP string, selected bool, ports ...corev1.ServicePort) *corev1.Service {
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "infra", Name: name},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, ClusterIP: clusterIP, Ports: ports},
+		}
+		if selected {
+			svc.Labels = map[string]string{"nwpd.gardener.cloud/check": "true"}
+		}
+		return svc
+	}
+	endpoints, errs := ServiceEndpoints([]*corev1.Service{
+		newService("registry-cache", "10.0.0.10", true, corev1.ServicePort{Port: 53, Protocol: corev1.ProtocolUDP}, corev1.ServicePort{Port: 5000}),
+		newService("metrics-gateway", "10.0.0.11", true, corev1.ServicePort{Port: 9090, Protocol: corev1.ProtocolTCP}),
+		newService("not-selected", "10.0.0.12", false, corev1.ServicePort{Port: 80}),
+		newService("headless", corev1.ClusterIPNone, true, corev1.ServicePort{Port: 80}),
+		newService("udp-only", "10.0.0.13", true, corev1.ServicePort{Port: 53, Protocol: corev1.ProtocolUDP}),
+	}, selector)
+	assert.Equal(t, []config.Endpoint{
+		{Hostname: "svc-infra-metrics-gateway", IP: "10.0.0.11", Port: 9090},
+		{Hostname: "svc-infra-registry-cache", IP: "10.0.0.10", Port: 5000},
+	}, endpoints)
+	if assert.Len(t, errs, 2) {
+		assert.Contains(t, errs[0].Error(), "infra/headless: no valid cluster IP")
+		assert.Contains(t, errs[1].Error(), "infra/udp-only: no TCP port")
+	}
+
+	endpoints, errs = ServiceEndpoints([]*corev1.Service{newService("registry-cache", "10.0.0.10", true, corev1.ServicePort{Port: 5000})}, nil)
+	assert.Empty(t, endpoints, "no selector")
+	assert.Empty(t, errs)
+}
+
+func TestMaintenanceWindows(t *testing.T) {
+	cm := &corev1.ConfigMap{}
+	windows, annotation, err := MaintenanceWindows(cm, time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, windows)
+	assert.Empty(t, annotation)
+
+	value := `[{"name":"old","start":"2022-08-01T09:00:00Z","end":"2022-08-01T10:00:00Z"},` +
+		`{"name":"new","start":"2022-08-01T11:00:00Z","end":"2022-08-01T12:00:00Z","nodes":["node1"]}]`
+	cm.Annotations = map[string]string{common.AnnotationMaintenanceWindows: value}
+	windows, annotation, err = MaintenanceWindows(cm, time.Date(2022, 8, 1, 9, 30, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Len(t, windows, 2)
+	assert.Equal(t, value, annotation)
+
+	windows, annotation, err = MaintenanceWindows(cm, time.Date(2022, 8, 1, 10, 30, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	if assert.Len(t, windows, 1) {
+		assert.Equal(t, "new", windows[0].Name)
+	}
+	assert.Equal(t, `[{"name":"new","start":"2022-08-01T11:00:00Z","end":"2022-08-01T12:00:00Z","nodes":["node1"]}]`, annotation)
+	SetMaintenanceWindowsAnnotation(cm, annotation)
+	assert.Equal(t, annotation, cm.Annotations[common.AnnotationMaintenanceWindows])
+
+	windows, annotation, err = MaintenanceWindows(cm, time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Empty(t, windows)
+	assert.Empty(t, annotation)
+	SetMaintenanceWindowsAnnotation(cm, annotation)
+	assert.NotContains(t, cm.Annotations, common.AnnotationMaintenanceWindows)
+
+	cm.Annotations[common.AnnotationMaintenanceWindows] = "{"
+	_, annotation, err = MaintenanceWindows(cm, time.Now())
+	assert.Error(t, err)
+	assert.Equal(t, "{", annotation)
+}
+
+func TestBuildClusterConfigOmitsRemovedNodes(t *testing.T) {
+	newNode := func(name, ip string, deleting bool) *corev1.Node {
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeHostName, Address: name},
+				{Type: corev1.NodeInternalIP, Address: ip},
+			}},
+		}
+		if deleting {
+			node.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		}
+		return node
+	}
+	newPod := func(name, nodename string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.PodSpec{NodeName: nodename},
+			Status:     corev1.PodStatus{PodIP: "100.64.0.1"},
+		}
+	}
+	nodes := []*corev1.Node{newNode("node2", "10.0.0.2", false), newNode("node1", "10.0.0.1", false), newNode("node3", "10.0.0.3", true)}
+	pods := []*corev1.Pod{newPod("pod1", "node1"), newPod("pod2", "node2"), newPod("pod3", "node3"), newPod("pod4", "node4")}
+
+	cfg, err := BuildClusterConfig(nodes, pods, nil, nil)
+	assert.NoError(t, err)
+	var hostnames, podnames []string
+	for _, n := range cfg.Nodes {
+		hostnames = append(hostnames, n.Hostname)
+	}
+	for _, p := range cfg.PodEndpoints {
+		podnames = append(podnames, p.Podname)
+	}
+	assert.Equal(t, []string{"node1", "node2"}, hostnames)
+	assert.Equal(t, []string{"pod1", "pod2"}, podnames)
+}
+
+func TestBuildClusterConfigExternalIP(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+			{Type: corev1.NodeHostName, Address: "node1"},
+			{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+			{Type: corev1.NodeExternalIP, Address: "192.0.2.1"},
+			{Type: corev1.NodeExternalIP, Address: "2001:db8::1"},
+		}},
+	}
+	cfg, err := BuildClusterConfig([]*corev1.Node{node}, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []config.Node{{Hostname: "node1", InternalIP: "10.0.0.1", ExternalIP: "192.0.2.1"}}, cfg.Nodes)
+}
+
+func TestBuildClusterConfigPodStartTime(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+			{Type: corev1.NodeHostName, Address: "node1"},
+			{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+		}},
+	}
+	started := metav1.NewTime(time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC))
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1"},
+		Spec:       corev1.PodSpec{NodeName: "node1"},
+		Status:     corev1.PodStatus{PodIP: "100.64.0.1", StartTime: &started},
+	}
+	cfg, err := BuildClusterConfig([]*corev1.Node{node}, []*corev1.Pod{pod}, nil, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, cfg.PodEndpoints, 1) {
+		assert.Equal(t, &started, cfg.PodEndpoints[0].StartTime)
+	}
+}
@@ -0,0 +1,219 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+const (
+	// ClusterConfigSizeWarningRatio is the ratio of MaxClusterConfigMapDataSize above which the size of the cluster config is reported as warning
+	ClusterConfigSizeWarningRatio = 0.8
+	// MaxClusterConfigShards is the maximum number of shards of the cluster config. The shard config maps are mounted optionally by the agents.
+	MaxClusterConfigShards = 8
+)
+
+// ClusterConfigShardMapName returns the name of the config map of a shard of the cluster config.
+func ClusterConfigShardMapName(shard int) string {
+	return fmt.Sprintf("%s-shard-%d", common.NameClusterConfigMap, shard)
+}
+
+// clusterConfigShardMapNames returns the names of the config maps of all possible shards of the cluster config.
+func clusterConfigShardMapNames() []string {
+	var names []string
+	for i := 0; i < MaxClusterConfigShards; i++ {
+		names = append(names, ClusterConfigShardMapName(i))
+	}
+	return names
+}
+
+// clusterConfigShardKey returns the key of a shard of the cluster config in its config map. The keys of the shards differ,
+// as all shards are mounted into the directory of the cluster config.
+func clusterConfigShardKey(shard int) string {
+	return config.ClusterConfigShardFilename(common.ClusterConfigFilename, shard)
+}
+
+// ClusterConfigLayout is the cluster config prepared for storing in the config maps. If the marshalled cluster config exceeds
+// MaxClusterConfigMapDataSize, the optional metadata of the nodes is dropped first (trimmed). If it is still too large,
+// the nodes and pod endpoints are split into shards stored in separate config maps.
+type ClusterConfigLayout struct {
+	// Data is the marshalled cluster config of the cluster config map.
+	Data []byte
+	// Shards are the marshalled shards if the cluster config is sharded.
+	Shards [][]byte
+	// FullSize is the stored size of the complete cluster config in bytes (compressed if above the compress threshold).
+	FullSize int
+	// Size is the stored size of the largest config map data in bytes.
+	Size int
+	// Trimmed is set if the optional metadata of the nodes has been dropped.
+	Trimmed bool
+}
+
+// LayoutClusterConfig marshals the cluster config and trims or shards it if needed to stay below MaxClusterConfigMapDataSize.
+// An error is returned if it is too large even with MaxClusterConfigShards shards.
+func LayoutClusterConfig(cfg *config.ClusterConfig, compressThreshold int) (*ClusterConfigLayout, error) {
+	data, size, err := marshalClusterConfig(cfg, compressThreshold)
+	if err != nil {
+		return nil, err
+	}
+	layout := &ClusterConfigLayout{Data: data, FullSize: size, Size: size}
+	if size <= MaxClusterConfigMapDataSize {
+		return layout, nil
+	}
+
+	trimmed := *cfg
+	trimmed.Trimmed = true
+	trimmed.Nodes = make([]config.Node, len(cfg.Nodes))
+	for i, node := range cfg.Nodes {
+		trimmed.Nodes[i] = config.Node{Hostname: node.Hostname, InternalIP: node.InternalIP, ExternalIP: node.ExternalIP}
+	}
+	trimmedData, size, err := marshalClusterConfig(&trimmed, compressThreshold)
+	if err != nil {
+		return nil, err
+	}
+	if size <= MaxClusterConfigMapDataSize {
+		layout.Data, layout.Size, layout.Trimmed = trimmedData, size, true
+		return layout, nil
+	}
+
+	// the shards keep the metadata of the nodes, so the complete size is a lower bound for the number of shards
+	n := (layout.FullSize + MaxClusterConfigMapDataSize - 1) / MaxClusterConfigMapDataSize
+	if n < 2 {
+		n = 2
+	}
+	// the generation identifies the shards of the same cluster config
+	sum := sha256.Sum256(layout.Data)
+	generation := hex.EncodeToString(sum[:6])
+	for ; n <= MaxClusterConfigShards; n++ {
+		if data, shards, size, err := shardClusterConfig(cfg, n, generation, compressThreshold); err != nil {
+			return nil, err
+		} else if size <= MaxClusterConfigMapDataSize {
+			layout.Data, layout.Shards, layout.Size = data, shards, size
+			return layout, nil
+		}
+	}
+	return nil, fmt.Errorf("cluster config too large: %d bytes exceed %d bytes even with %d shards", layout.FullSize, MaxClusterConfigMapDataSize, MaxClusterConfigShards)
+}
+
+// shardClusterConfig splits the nodes and pod endpoints into n shards. It returns the marshalled cluster config without them,
+// the marshalled shards and the largest stored size.
+func shardClusterConfig(cfg *config.ClusterConfig, n int, generation string, compressThreshold int) ([]byte, [][]byte, int, error) {
+	largest := 0
+	var shardsData [][]byte
+	for i := 0; i < n; i++ {
+		shard := &config.ClusterConfig{
+			Nodes:            chunk(cfg.Nodes, i, n),
+			PodEndpoints:     chunk(cfg.PodEndpoints, i, n),
+			ShardsGeneration: generation,
+		}
+		data, size, err := marshalClusterConfig(shard, compressThreshold)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		shardsData = append(shardsData, data)
+		if size > largest {
+			largest = size
+		}
+	}
+	main := *cfg
+	main.Nodes = nil
+	main.PodEndpoints = nil
+	main.Shards = n
+	main.ShardsGeneration = generation
+	data, size, err := marshalClusterConfig(&main, compressThreshold)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if size > largest {
+		largest = size
+	}
+	return data, shardsData, largest, nil
+}
+
+// chunk returns the i-th of n contiguous parts of the items.
+func chunk[T any](items []T, i, n int) []T {
+	return items[i*len(items)/n : (i+1)*len(items)/n]
+}
+
+// marshalClusterConfig marshals the cluster config and returns the size stored in the config map.
+func marshalClusterConfig(cfg *config.ClusterConfig, compressThreshold int) ([]byte, int, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	if compressThreshold <= 0 {
+		compressThreshold = DefaultClusterConfigCompressThreshold
+	}
+	if len(data) <= compressThreshold {
+		return data, len(data), nil
+	}
+	compressed, err := config.Gzip(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, len(compressed), nil
+}
+
+// SetClusterConfigShardData stores the shard of the cluster config in its config map (see ClusterConfigShardMapName).
+// It returns false if the config map already contains the data.
+func SetClusterConfigShardData(cm *corev1.ConfigMap, shard int, data []byte, compressThreshold int) (bool, error) {
+	key := clusterConfigShardKey(shard)
+	if old, err := clusterConfigData(cm, key); err == nil && bytes.Equal(old, data) {
+		return false, nil
+	}
+	return true, setClusterConfigData(cm, key, data, compressThreshold)
+}
+
+// LoadClusterConfigFromConfigMaps loads the cluster config from the cluster config map including its shards.
+func LoadClusterConfigFromConfigMaps(ctx context.Context, configMaps corev1client.ConfigMapInterface) (*config.ClusterConfig, error) {
+	cm, err := configMaps.Get(ctx, common.NameClusterConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("loading configmap %s/%s failed: %w", common.NamespaceKubeSystem, common.NameClusterConfigMap, err)
+	}
+	cfg, err := unmarshalClusterConfig(cm, common.ClusterConfigFilename)
+	if err != nil {
+		return nil, err
+	}
+	var shards []*config.ClusterConfig
+	for i := 0; i < cfg.Shards; i++ {
+		shardCM, err := configMaps.Get(ctx, ClusterConfigShardMapName(i), metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("loading shard %d of cluster config failed: %w", i, err)
+		}
+		shard, err := unmarshalClusterConfig(shardCM, clusterConfigShardKey(i))
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, shard)
+	}
+	if err := cfg.MergeShards(shards); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func unmarshalClusterConfig(cm *corev1.ConfigMap, key string) (*config.ClusterConfig, error) {
+	data, err := clusterConfigData(cm, key)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config.ClusterConfig{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal configmap %s/%s failed: %w", cm.Namespace, cm.Name, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package deploy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+// newLargeClusterConfig creates a cluster config with hardly compressible hostnames and metadata of the nodes.
+func newLargeClusterConfig(nodeCount int) *config.ClusterConfig {
+	random := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	cfg := &config.ClusterConfig{}
+	for i := 0; i < nodeCount; i++ {
+		hostname := random(fmt.Sprintf("node%d", i))
+		cfg.Nodes = append(cfg.Nodes, config.Node{
+			Hostname:   hostname,
+			InternalIP: fmt.Sprintf("10.%d.%d.%d", i>>16, (i>>8)&0xff, i&0xff),
+			Zone:       random(fmt.Sprintf("zone%d", i)),
+			Pool:       random(fmt.Sprintf("pool%d", i)),
+		})
+		cfg.PodEndpoints = append(cfg.PodEndpoints, config.PodEndpoint{
+			Nodename: hostname,
+			Podname:  "nwpd-agent-pod-net-" + hostname[:5],
+			PodIP:    fmt.Sprintf("100.%d.%d.%d", 64+i>>16, (i>>8)&0xff, i&0xff),
+			Port:     common.PodNetPodGRPCPort,
+		})
+	}
+	return cfg
+}
+
+func TestLayoutClusterConfig(t *testing.T) {
+	small := newLargeClusterConfig(10)
+	layout, err := LayoutClusterConfig(small, 0)
+	if assert.NoError(t, err) {
+		assert.False(t, layout.Trimmed)
+		assert.Empty(t, layout.Shards)
+		assert.Equal(t, layout.FullSize, layout.Size)
+		data, _ := yaml.Marshal(small)
+		assert.Equal(t, data, layout.Data)
+	}
+
+	if testing.Short() {
+		t.Skip("skipping large cluster configs in short mode")
+	}
+
+	// dropping zones and pools is sufficient
+	trimmed := newLargeClusterConfig(8000)
+	layout, err = LayoutClusterConfig(trimmed, 0)
+	if assert.NoError(t, err) {
+		assert.True(t, layout.Trimmed)
+		assert.Empty(t, layout.Shards)
+		assert.Greater(t, layout.FullSize, MaxClusterConfigMapDataSize)
+		assert.LessOrEqual(t, layout.Size, MaxClusterConfigMapDataSize)
+		cfg := &config.ClusterConfig{}
+		assert.NoError(t, yaml.Unmarshal(layout.Data, cfg))
+		assert.True(t, cfg.Trimmed)
+		assert.Len(t, cfg.Nodes, len(trimmed.Nodes))
+		assert.Equal(t, config.Node{Hostname: trimmed.Nodes[0].Hostname, InternalIP: trimmed.Nodes[0].InternalIP}, cfg.Nodes[0])
+		assert.NotEmpty(t, trimmed.Nodes[0].Zone, "input must not be modified")
+	}
+
+	sharded := newLargeClusterConfig(12000)
+	layout, err = LayoutClusterConfig(sharded, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, layout.Trimmed)
+	assert.Greater(t, len(layout.Shards), 1)
+	assert.LessOrEqual(t, layout.Size, MaxClusterConfigMapDataSize)
+
+	// the agents and commands merge the shards with full metadata of the nodes
+	configMaps := &fakeConfigMaps{}
+	cm := corev1.ConfigMap{ObjectMeta: meta(common.NameClusterConfigMap)}
+	assert.NoError(t, SetClusterConfigData(&cm, layout.Data, 0))
+	configMaps.items = append(configMaps.items, cm)
+	for i, data := range layout.Shards {
+		shardCM := corev1.ConfigMap{ObjectMeta: meta(ClusterConfigShardMapName(i))}
+		changed, err := SetClusterConfigShardData(&shardCM, i, data, 0)
+		assert.NoError(t, err)
+		assert.True(t, changed)
+		changed, err = SetClusterConfigShardData(&shardCM, i, data, 0)
+		assert.NoError(t, err)
+		assert.False(t, changed, "unchanged shard")
+		configMaps.items = append(configMaps.items, shardCM)
+	}
+	cfg, err := LoadClusterConfigFromConfigMaps(context.Background(), configMaps)
+	if assert.NoError(t, err) {
+		assert.Equal(t, sharded.Nodes, cfg.Nodes)
+		assert.Equal(t, sharded.PodEndpoints, cfg.PodEndpoints)
+		assert.Zero(t, cfg.Shards)
+	}
+
+	// a shard of another generation is rejected
+	configMaps.items = configMaps.items[:len(configMaps.items)-1]
+	other := corev1.ConfigMap{ObjectMeta: meta(ClusterConfigShardMapName(len(layout.Shards) - 1))}
+	_, err = SetClusterConfigShardData(&other, len(layout.Shards)-1, []byte("shardsGeneration: other\n"), 0)
+	assert.NoError(t, err)
+	configMaps.items = append(configMaps.items, other)
+	_, err = LoadClusterConfigFromConfigMaps(context.Background(), configMaps)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "generation")
+	}
+
+	_, err = BuildClusterConfigMap(sharded)
+	assert.Error(t, err, "sharding is left to the controller")
+}
+
+func TestClusterConfigShardVolume(t *testing.T) {
+	ac := &AgentDeployConfig{}
+	ds, err := ac.buildDaemonSet("sa", true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, volume := range ds.Spec.Template.Spec.Volumes {
+		if volume.Name != "cluster-config" {
+			continue
+		}
+		if assert.NotNil(t, volume.Projected) && assert.Len(t, volume.Projected.Sources, MaxClusterConfigShards+1) {
+			assert.Equal(t, common.NameClusterConfigMap, volume.Projected.Sources[0].ConfigMap.Name)
+			assert.Nil(t, volume.Projected.Sources[0].ConfigMap.Optional)
+			last := volume.Projected.Sources[MaxClusterConfigShards].ConfigMap
+			assert.Equal(t, ClusterConfigShardMapName(MaxClusterConfigShards-1), last.Name)
+			assert.True(t, *last.Optional)
+		}
+		return
+	}
+	t.Fatal("cluster-config volume not found")
+}
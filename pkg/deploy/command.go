@@ -8,12 +8,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
 
 	"github.com/gardener/network-problem-detector/pkg/common"
 	"github.com/gardener/network-problem-detector/pkg/common/config"
@@ -22,7 +27,11 @@ import (
 type deployCommand struct {
 	common.ClientsetBase
 	delete            bool
+	wait              bool
+	timeout           time.Duration
+	valuesFile        string
 	agentDeployConfig AgentDeployConfig
+	dynamicClient     dynamic.Interface
 }
 
 func CreateDeployCmd(imageTag string) *cobra.Command {
@@ -31,10 +40,15 @@ func CreateDeployCmd(imageTag string) *cobra.Command {
 		Use:   "deploy",
 		Short: "deploy nwpd daemonsets and deployments",
 		Long:  `deploy agent daemon sets and controller deployment`,
+		// the values file is loaded before running any sub command, flags given on the command line take precedence
+		PersistentPreRunE: dc.loadValues,
 	}
 	dc.AddKubeConfigFlag(cmd.PersistentFlags())
 	dc.agentDeployConfig.AddImageFlag(imageTag, cmd.PersistentFlags())
 	dc.agentDeployConfig.AddOptionFlags(cmd.PersistentFlags())
+	cmd.PersistentFlags().BoolVar(&dc.wait, "wait", false, "if true, waits until the daemonsets are ready and the controller deployment is available.")
+	cmd.PersistentFlags().DurationVar(&dc.timeout, "timeout", 5*time.Minute, "timeout for waiting with option --wait.")
+	cmd.PersistentFlags().StringVar(&dc.valuesFile, "values", "", "values file in YAML or JSON format with the deploy configuration. Flags given on the command line take precedence.")
 
 	agentCmd := &cobra.Command{
 		Use:     "agent",
@@ -53,6 +67,14 @@ func CreateDeployCmd(imageTag string) *cobra.Command {
 	controllerCmd.Flags().BoolVar(&dc.delete, "delete", false, "if true, the deployment is deleted.")
 	controllerCmd.Flags().StringVar(&dc.agentDeployConfig.PriorityClassName, "priority-class", "", "priority class name")
 
+	allCmd := &cobra.Command{
+		Use:   "all",
+		Short: "deploy agent daemonsets and controller deployment",
+		RunE:  dc.deployAll,
+	}
+	allCmd.Flags().BoolVar(&dc.delete, "delete", false, "if true, the daemonsets and the deployment are deleted.")
+	allCmd.Flags().StringVar(&dc.agentDeployConfig.PriorityClassName, "priority-class", "", "priority class name of the controller")
+
 	printConfigCmd := &cobra.Command{
 		Use:     "print-default-config",
 		Aliases: []string{"print"},
@@ -62,7 +84,15 @@ func CreateDeployCmd(imageTag string) *cobra.Command {
 
 	cmd.AddCommand(agentCmd)
 	cmd.AddCommand(controllerCmd)
+	cmd.AddCommand(allCmd)
+	printValuesCmd := &cobra.Command{
+		Use:   "print-values",
+		Short: "prints the effective deploy configuration merged from values file and flags.",
+		RunE:  dc.printValues,
+	}
+
 	cmd.AddCommand(printConfigCmd)
+	cmd.AddCommand(printValuesCmd)
 	return cmd
 }
 
@@ -70,6 +100,32 @@ func (dc *deployCommand) setup() error {
 	if err := dc.SetupClientSet(); err != nil {
 		return err
 	}
+	var err error
+	dc.dynamicClient, err = dynamic.NewForConfig(dc.RestConfig)
+	if err != nil {
+		return fmt.Errorf("error creating dynamic client: %s", err)
+	}
+	return nil
+}
+
+func (dc *deployCommand) loadValues(cmd *cobra.Command, args []string) error {
+	if dc.valuesFile == "" {
+		return nil
+	}
+	values, err := LoadDeployValues(dc.valuesFile)
+	if err != nil {
+		return err
+	}
+	values.ApplyTo(&dc.agentDeployConfig, cmd.Flags())
+	return nil
+}
+
+func (dc *deployCommand) printValues(cmd *cobra.Command, args []string) error {
+	data, err := yaml.Marshal(NewDeployValues(&dc.agentDeployConfig))
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(data))
 	return nil
 }
 
@@ -94,39 +150,153 @@ func (dc *deployCommand) printDefaultConfig(cmd *cobra.Command, args []string) e
 
 func (dc *deployCommand) deployAgentAllDaemonsets(cmd *cobra.Command, args []string) error {
 	log := logrus.WithField("cmd", "deploy-agent")
-	err := dc.deployAgent(log, false, dc.buildAgentConfigMap, dc.buildClusterConfigMap)
+
+	if err := dc.setup(); err != nil {
+		return err
+	}
+	if dc.delete {
+		return dc.deleteAgent(log)
+	}
+	objects, err := dc.buildAgentObjects(dc.buildAgentConfigMap, dc.buildClusterConfigMap)
 	if err != nil {
 		return err
 	}
-	return dc.deployAgent(log, true, dc.buildAgentConfigMap, dc.buildClusterConfigMap)
+	if err := dc.apply(log, objects); err != nil {
+		return err
+	}
+	return dc.deleteOutdatedAgentConfigMaps(log)
 }
 
 func (dc *deployCommand) deployAgentControllerDeployment(cmd *cobra.Command, args []string) error {
 	log := logrus.WithField("cmd", "deploy-controller")
 
-	err := dc.setup()
+	if err := dc.setup(); err != nil {
+		return err
+	}
+	if dc.delete {
+		return dc.deleteController(log)
+	}
+	objects, err := dc.buildControllerObjects()
 	if err != nil {
 		return err
 	}
+	return dc.apply(log, objects)
+}
 
-	ac := dc.agentDeployConfig
+func (dc *deployCommand) deployAll(cmd *cobra.Command, args []string) error {
+	log := logrus.WithField("cmd", "deploy-all")
+
+	if err := dc.setup(); err != nil {
+		return err
+	}
+	if dc.delete {
+		if err := dc.deleteController(log); err != nil {
+			return err
+		}
+		return dc.deleteAgent(log)
+	}
+	objects, err := dc.buildAgentObjects(dc.buildAgentConfigMap, dc.buildClusterConfigMap)
+	if err != nil {
+		return err
+	}
+	controllerObjects, err := dc.buildControllerObjects()
+	if err != nil {
+		return err
+	}
+	if err := dc.apply(log, append(objects, controllerObjects...)); err != nil {
+		return err
+	}
+	return dc.deleteOutdatedAgentConfigMaps(log)
+}
+
+// deleteOutdatedAgentConfigMaps deletes the agent config maps not referenced by the daemon sets anymore.
+// Without option --wait, the previous config map is kept as the rollout of the daemon sets may still be in progress.
+func (dc *deployCommand) deleteOutdatedAgentConfigMaps(log logrus.FieldLogger) error {
+	current, err := dc.agentDeployConfig.agentConfigMapName()
+	if err != nil {
+		return err
+	}
+	return deleteOutdatedAgentConfigMaps(context.Background(), log, dc.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem), current, !dc.wait)
+}
+
+// apply creates or updates the objects with bounded concurrency. Service accounts, RBAC objects and config maps
+// are applied before the workloads. With option --wait, it blocks until the workloads are ready.
+func (dc *deployCommand) apply(log logrus.FieldLogger, objects []Object) error {
 	ctx := context.Background()
-	deployment, cr, crb, role, rolebinding, sa, err := ac.buildControllerDeployment()
+	if err := validateExistingServiceAccount(ctx, dc.Clientset.CoreV1(), dc.agentDeployConfig.ExistingServiceAccount); err != nil {
+		return err
+	}
+	objects, err := dc.agentDeployConfig.appendVPAs(objects)
 	if err != nil {
 		return err
 	}
-	for _, obj := range []Object{deployment, cr, crb, role, rolebinding, sa} {
-		if !dc.delete {
-			_, err = genericCreateOrUpdate(ctx, dc.Clientset, obj)
-		} else {
-			err = genericDeleteWithLog(ctx, log, dc.Clientset, obj)
+	objects, err = filterVPAs(log, dc.Clientset.Discovery(), objects, dc.agentDeployConfig.VPARequired)
+	if err != nil {
+		return err
+	}
+	err = applyObjects(ctx, objects, maxParallelApply, func(ctx context.Context, obj Object) error {
+		if vpa, ok := obj.(*unstructured.Unstructured); ok && isVPA(obj) {
+			_, err := createOrUpdate(ctx, "verticalpodautoscaler", dynamicObjects{client: dc.dynamicClient.Resource(vpaResource).Namespace(vpa.GetNamespace())}, vpa)
+			return err
 		}
-		if err != nil {
+		_, err := genericCreateOrUpdate(ctx, dc.Clientset, obj)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		switch obj.(type) {
+		case *appsv1.DaemonSet, *appsv1.Deployment:
+			kind, _ := typename(obj)
+			log.Infof("deployed %s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+		}
+	}
+	if !dc.wait {
+		return nil
+	}
+	return waitForWorkloads(log, dc.Clientset, objects, dc.timeout)
+}
+
+func (dc *deployCommand) buildControllerObjects() ([]Object, error) {
+	deployment, cr, crb, role, rolebinding, sa, err := dc.agentDeployConfig.buildControllerDeployment()
+	if err != nil {
+		return nil, err
+	}
+	if dc.agentDeployConfig.ExistingServiceAccount != "" {
+		return []Object{deployment}, nil
+	}
+	return []Object{deployment, cr, crb, role, rolebinding, sa}, nil
+}
+
+func (dc *deployCommand) deleteController(log logrus.FieldLogger) error {
+	ctx := context.Background()
+	objects, err := dc.buildControllerObjects()
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := genericDeleteWithLog(ctx, log, dc.Clientset, obj); err != nil {
 			return err
 		}
 	}
-	if !dc.delete {
-		log.Infof("deployed deployment %s/%s", deployment.Namespace, deployment.Name)
+	if err := dc.deleteVPA(log, common.NameDeploymentAgentController); err != nil {
+		return err
+	}
+	err = dc.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem).Delete(ctx, common.NameClusterEventsConfigMap, metav1.DeleteOptions{})
+	if err == nil {
+		log.Infof("configmap %s/%s deleted", common.NamespaceKubeSystem, common.NameClusterEventsConfigMap)
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+	// shards of the cluster config created by the controller
+	for _, name := range clusterConfigShardMapNames() {
+		err = dc.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem).Delete(ctx, name, metav1.DeleteOptions{})
+		if err == nil {
+			log.Infof("configmap %s/%s deleted", common.NamespaceKubeSystem, name)
+		} else if !errors.IsNotFound(err) {
+			return err
+		}
 	}
 	return nil
 }
@@ -141,54 +311,53 @@ func (dc *deployCommand) deleteAgentControllerDeployment(log logrus.FieldLogger)
 	return nil
 }
 
-func (dc *deployCommand) deployAgent(log logrus.FieldLogger, hostnetwork bool,
-	buildAgentConfigMap, buildClusterConfigMap buildObject[*corev1.ConfigMap]) error {
+// buildAgentObjects builds the objects of the daemon sets for both the host and the pod network.
+func (dc *deployCommand) buildAgentObjects(buildAgentConfigMap, buildClusterConfigMap buildObject[*corev1.ConfigMap]) ([]Object, error) {
 	ac := dc.agentDeployConfig
-	name, _, _ := ac.getNetworkConfig(hostnetwork)
 
-	err := dc.setup()
-	if err != nil {
-		return err
-	}
-
-	if dc.delete {
-		return dc.deleteDaemonSet(log, name)
-	}
-
-	svc, err := ac.buildService(hostnetwork)
-	if err != nil {
-		return fmt.Errorf("error building service[%t]: %s", hostnetwork, err)
-	}
 	acm, err := buildAgentConfigMap()
 	if err != nil {
-		return fmt.Errorf("error building config map: %s", err)
+		return nil, fmt.Errorf("error building config map: %s", err)
 	}
 	ccm, err := buildClusterConfigMap()
 	if err != nil {
-		return fmt.Errorf("error building config map: %s", err)
+		return nil, fmt.Errorf("error building config map: %s", err)
 	}
-
-	serviceAccountName := ""
-	var objects []Object
-	serviceAccountName, objects, err = dc.agentDeployConfig.buildSecurityObjects()
+	serviceAccountName, objects, err := ac.buildSecurityObjects()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	objects = append(objects, acm, ccm)
 
-	ds, err := ac.buildDaemonSet(serviceAccountName, hostnetwork)
-	if err != nil {
-		return fmt.Errorf("error building daemon set: %s", err)
-	}
-	objects = append(objects, svc, acm, ccm, ds)
-	ctx := context.Background()
-	for _, obj := range objects {
-		_, err = genericCreateOrUpdate(ctx, dc.Clientset, obj)
+	for _, hostnetwork := range []bool{false, true} {
+		svc, err := ac.buildService(hostnetwork)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("error building service[%t]: %s", hostnetwork, err)
+		}
+		objects = append(objects, svc)
+		if ac.HeadlessServiceEnabled {
+			headless, err := ac.buildHeadlessService(hostnetwork)
+			if err != nil {
+				return nil, fmt.Errorf("error building headless service[%t]: %s", hostnetwork, err)
+			}
+			objects = append(objects, headless)
 		}
+		ds, err := ac.buildDaemonSet(serviceAccountName, hostnetwork)
+		if err != nil {
+			return nil, fmt.Errorf("error building daemon set: %s", err)
+		}
+		objects = append(objects, ds)
 	}
+	return objects, nil
+}
 
-	log.Infof("deployed daemonset %s/%s", ds.Namespace, ds.Name)
+func (dc *deployCommand) deleteAgent(log logrus.FieldLogger) error {
+	for _, hostnetwork := range []bool{false, true} {
+		name := dc.agentDeployConfig.getDaemonSetName(hostnetwork)
+		if err := dc.deleteDaemonSet(log, name); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -210,6 +379,12 @@ func (dc *deployCommand) deleteDaemonSet(log logrus.FieldLogger, name string) er
 	if err4 == nil {
 		log.Infof("service %s/%s deleted", common.NamespaceKubeSystem, name)
 	}
+	err5 := dc.Clientset.CoreV1().Services(common.NamespaceKubeSystem).Delete(ctx, headlessServiceName(name), metav1.DeleteOptions{})
+	if err5 == nil {
+		log.Infof("service %s/%s deleted", common.NamespaceKubeSystem, headlessServiceName(name))
+	}
+	// immutable agent config maps
+	err6 := deleteOutdatedAgentConfigMaps(ctx, log, dc.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem), common.NameAgentConfigMap, false)
 	if err1 != nil && !errors.IsNotFound(err1) {
 		return err1
 	}
@@ -222,10 +397,31 @@ func (dc *deployCommand) deleteDaemonSet(log logrus.FieldLogger, name string) er
 	if err4 != nil && !errors.IsNotFound(err4) {
 		return err4
 	}
+	if err5 != nil && !errors.IsNotFound(err5) {
+		return err5
+	}
+	if err6 != nil {
+		return err6
+	}
+	if err := dc.deleteVPA(log, name); err != nil {
+		return err
+	}
 
 	return dc.deletePodSecurityPolicy(log)
 }
 
+// deleteVPA deletes the VerticalPodAutoscaler of a workload. It is deleted even if VPAs are not enabled anymore,
+// a missing VPA or VPA CRD is ignored.
+func (dc *deployCommand) deleteVPA(log logrus.FieldLogger, name string) error {
+	err := dc.dynamicClient.Resource(vpaResource).Namespace(common.NamespaceKubeSystem).Delete(context.Background(), name, metav1.DeleteOptions{})
+	if err == nil {
+		log.Infof("verticalpodautoscaler %s/%s deleted", common.NamespaceKubeSystem, name)
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
 func (dc *deployCommand) deletePodSecurityPolicy(log logrus.FieldLogger) error {
 	ctx := context.Background()
 	_, objects, err := dc.agentDeployConfig.buildSecurityObjects()
@@ -241,11 +437,7 @@ func (dc *deployCommand) deletePodSecurityPolicy(log logrus.FieldLogger) error {
 }
 
 func (dc *deployCommand) buildAgentConfigMap() (*corev1.ConfigMap, error) {
-	agentConfig, err := dc.agentDeployConfig.BuildAgentConfig()
-	if err != nil {
-		return nil, err
-	}
-	return BuildAgentConfigMap(agentConfig)
+	return dc.agentDeployConfig.buildAgentConfigMap()
 }
 
 func (dc *deployCommand) buildClusterConfigMap() (*corev1.ConfigMap, error) {
@@ -261,11 +453,13 @@ func (dc *deployCommand) buildClusterConfigMap() (*corev1.ConfigMap, error) {
 	}
 	var apiServer *config.Endpoint
 	if !dc.agentDeployConfig.IgnoreAPIServerEndpoint {
-		shootInfo, err := dc.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem).Get(ctx, common.NameGardenerShootInfo, metav1.GetOptions{})
+		lookupCtx, cancel := context.WithTimeout(ctx, dc.agentDeployConfig.ShootInfoTimeout)
+		defer cancel()
+		shootInfo, err := GetShootInfo(lookupCtx, dc.Clientset)
 		if err != nil {
-			return nil, fmt.Errorf("error getting configmap %s/%s", common.NamespaceKubeSystem, common.NameGardenerShootInfo)
+			return nil, err
 		}
-		apiServer, err = GetAPIServerEndpointFromShootInfo(shootInfo)
+		apiServer, err = GetAPIServerEndpointFromShootInfo(lookupCtx, shootInfo)
 		if err != nil {
 			return nil, err
 		}
@@ -283,12 +477,75 @@ func (dc *deployCommand) buildClusterConfigMap() (*corev1.ConfigMap, error) {
 	if err != nil {
 		return nil, err
 	}
-	return BuildClusterConfigMap(clusterConfig)
+	clusterConfig.NodeSelector = dc.agentDeployConfig.NodeLabelSelector
+	clusterConfig.KubeDNS, err = GetKubeDNSEndpoint(ctx, dc.Clientset)
+	if err != nil {
+		logrus.Warnf("kube-dns discovery failed, DNS jobs using CoreDNS are omitted: %s", err)
+	}
+	clusterConfig.NodeLocalDNS, err = GetNodeLocalDNSEndpoint(ctx, dc.Clientset)
+	if err != nil {
+		logrus.Infof("DNS jobs using node-local-dns are omitted: %s", err)
+	}
+	services, err := dc.services()
+	if err != nil {
+		return nil, err
+	}
+	selector, err := dc.agentDeployConfig.ParseLoadBalancerSelector()
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	clusterConfig.LoadBalancers, errs = LoadBalancerEndpoints(services, selector)
+	for _, err := range errs {
+		logrus.Warnf("load balancer hairpin check skipped for %s", err)
+	}
+	serviceSelector, err := dc.agentDeployConfig.ParseServiceSelector()
+	if err != nil {
+		return nil, err
+	}
+	clusterConfig.Services, errs = ServiceEndpoints(services, serviceSelector)
+	for _, err := range errs {
+		logrus.Warnf("service check skipped for %s", err)
+	}
+	// keep the maintenance windows of an existing config map
+	annotation := ""
+	old, err := dc.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem).Get(ctx, common.NameClusterConfigMap, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		clusterConfig.MaintenanceWindows, annotation, err = MaintenanceWindows(old, time.Now())
+		if err != nil {
+			logrus.Warnf("maintenance windows ignored: %s", err)
+		}
+		// keep the endpoint list until the controller has loaded it again
+		oldConfig := &config.ClusterConfig{}
+		if data, err := ClusterConfigData(old); err != nil {
+			logrus.Warnf("previous cluster config ignored: %s", err)
+		} else if err := yaml.Unmarshal(data, oldConfig); err == nil &&
+			oldConfig.URLEndpoints != nil && oldConfig.URLEndpoints.Source == dc.agentDeployConfig.EndpointsFromURL {
+			clusterConfig.URLEndpoints = oldConfig.URLEndpoints
+		}
+	}
+	cm, err := BuildClusterConfigMap(clusterConfig)
+	if err != nil {
+		return nil, err
+	}
+	SetMaintenanceWindowsAnnotation(cm, annotation)
+	return cm, nil
 }
 
 func (dc *deployCommand) nodes() ([]*corev1.Node, error) {
 	ctx := context.Background()
-	nodeList, err := dc.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	selector, err := dc.agentDeployConfig.ParseNodeLabelSelector()
+	if err != nil {
+		return nil, err
+	}
+	opts := metav1.ListOptions{}
+	if selector != nil {
+		opts.LabelSelector = selector.String()
+	}
+	nodeList, err := dc.Clientset.CoreV1().Nodes().List(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("error listing nodes: %w", err)
 	}
@@ -322,3 +579,17 @@ func (dc *deployCommand) agentPods() ([]*corev1.Pod, error) {
 	}
 	return pods, nil
 }
+
+func (dc *deployCommand) services() ([]*corev1.Service, error) {
+	ctx := context.Background()
+	serviceList, err := dc.Clientset.CoreV1().Services("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing services: %w", err)
+	}
+	var services []*corev1.Service
+	for _, svc := range serviceList.Items {
+		item := svc
+		services = append(services, &item)
+	}
+	return services, nil
+}
@@ -7,6 +7,7 @@ package deploy
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
@@ -15,6 +16,7 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 )
@@ -49,7 +51,7 @@ func genericCreateOrUpdate(ctx context.Context, clientset *kubernetes.Clientset,
 	case *appsv1.Deployment:
 		return createOrUpdate(ctx, "deployment", clientset.AppsV1().Deployments(object.GetNamespace()), v)
 	case *appsv1.DaemonSet:
-		return createOrUpdate(ctx, "deployment", clientset.AppsV1().DaemonSets(object.GetNamespace()), v)
+		return createOrUpdate(ctx, "daemonset", clientset.AppsV1().DaemonSets(object.GetNamespace()), v)
 	case *rbacv1.ClusterRole:
 		return createOrUpdate(ctx, "clusterrole", clientset.RbacV1().ClusterRoles(), v)
 	case *rbacv1.ClusterRoleBinding:
@@ -134,9 +136,11 @@ func typename(object Object) (string, bool) {
 	case *rbacv1.Role:
 		return "role", true
 	case *rbacv1.RoleBinding:
-		return "role", true
+		return "rolebinding", true
 	case *policyv1beta1.PodSecurityPolicy:
 		return "podsecuritypolicy", false
+	case *unstructured.Unstructured:
+		return strings.ToLower(v.GetKind()), v.GetNamespace() != ""
 	default:
 		return fmt.Sprintf("unsupported type: %T", v), false
 	}
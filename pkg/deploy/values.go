@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// DeployValues is the content of a values file of the deploy command (option --values) in YAML or JSON format.
+// Its fields have the names of the fields of AgentDeployConfig. Fields with a `flag` tag correspond to the flag of
+// the deploy command with this name, which takes precedence if given on the command line. The other fields are
+// only available in the values file. Fields not set keep the defaults of the flags.
+type DeployValues struct {
+	Image                        *string          `json:"image,omitempty" flag:"image"`
+	DefaultPeriod                *metav1.Duration `json:"defaultPeriod,omitempty" flag:"default-period"`
+	PingEnabled                  *bool            `json:"pingEnabled,omitempty" flag:"enable-ping"`
+	PodSecurityPolicyEnabled     *bool            `json:"podSecurityPolicyEnabled,omitempty" flag:"enable-psp"`
+	IgnoreAPIServerEndpoint      *bool            `json:"ignoreAPIServerEndpoint,omitempty" flag:"ignore-gardener-kube-api-server"`
+	ShootInfoTimeout             *metav1.Duration `json:"shootInfoTimeout,omitempty" flag:"shoot-info-timeout"`
+	PriorityClassName            *string          `json:"priorityClassName,omitempty" flag:"priority-class"`
+	K8sExporterEnabled           *bool            `json:"k8sExporterEnabled,omitempty" flag:"enable-k8s-exporter"`
+	K8sExporterHeartbeat         *metav1.Duration `json:"k8sExporterHeartbeat,omitempty" flag:"k8s-exporter-heartbeat"`
+	EventExporterEnabled         *bool            `json:"eventExporterEnabled,omitempty" flag:"enable-event-exporter"`
+	EventExporterWindow          *metav1.Duration `json:"eventExporterWindow,omitempty" flag:"event-exporter-window"`
+	EventExporterCategories      []string         `json:"eventExporterCategories,omitempty" flag:"event-exporter-categories"`
+	EventExporterMaxEvents       *int             `json:"eventExporterMaxEvents,omitempty" flag:"event-exporter-max-events"`
+	EventExporterMaxMessageSize  *int             `json:"eventExporterMaxMessageSize,omitempty" flag:"event-exporter-max-message-size"`
+	EnabledJobs                  []string         `json:"enabledJobs,omitempty" flag:"enable-jobs"`
+	DisabledJobs                 []string         `json:"disabledJobs,omitempty" flag:"disable-jobs"`
+	IMDSCheckEnabled             *bool            `json:"imdsCheckEnabled,omitempty" flag:"enable-imds-check"`
+	IMDSEndpoint                 *string          `json:"imdsEndpoint,omitempty" flag:"imds-endpoint"`
+	CABundleConfigMap            *string          `json:"caBundleConfigMap,omitempty" flag:"ca-bundle-config-map"`
+	CABundleSecret               *string          `json:"caBundleSecret,omitempty" flag:"ca-bundle-secret"`
+	CABundleKey                  *string          `json:"caBundleKey,omitempty" flag:"ca-bundle-key"`
+	CABundleAppendToSystemPool   *bool            `json:"caBundleAppendToSystemPool,omitempty" flag:"ca-bundle-append-to-system-pool"`
+	EchoCheckEnabled             *bool            `json:"echoCheckEnabled,omitempty" flag:"enable-echo-check"`
+	APIServerAuthCheckEnabled    *bool            `json:"apiServerAuthCheckEnabled,omitempty" flag:"enable-api-server-auth-check"`
+	ExternalIPCheckEnabled       *bool            `json:"externalIPCheckEnabled,omitempty" flag:"enable-external-ip-check"`
+	ProxyRulesCheckEnabled       *bool            `json:"proxyRulesCheckEnabled,omitempty" flag:"enable-proxy-rules-check"`
+	ExposedHostPort              *int             `json:"exposedHostPort,omitempty" flag:"expose-host-port"`
+	RestartOnConfigChange        *bool            `json:"restartOnConfigChange,omitempty" flag:"restart-on-config-change"`
+	ImmutableConfig              *bool            `json:"immutableConfig,omitempty" flag:"immutable-config"`
+	NodeLabelSelector            *string          `json:"nodeLabelSelector,omitempty" flag:"node-label-selector"`
+	LoadBalancerSelector         *string          `json:"loadBalancerSelector,omitempty" flag:"load-balancer-selector"`
+	ServiceSelector              *string          `json:"serviceSelector,omitempty" flag:"service-selector"`
+	IngressEndpoints             []string         `json:"ingressEndpoints,omitempty" flag:"ingress-endpoints"`
+	JobLabels                    []string         `json:"jobLabels,omitempty" flag:"job-labels"`
+	AgentCommand                 []string         `json:"agentCommand,omitempty" flag:"agent-command"`
+	AgentArgs                    []string         `json:"agentArgs,omitempty" flag:"agent-args"`
+	OmitAgentFlags               *bool            `json:"omitAgentFlags,omitempty" flag:"omit-agent-flags"`
+	HeadlessServiceEnabled       *bool            `json:"headlessServiceEnabled,omitempty" flag:"enable-headless-service"`
+	EndpointsFromURL             *string          `json:"endpointsFromURL,omitempty" flag:"endpoints-from-url"`
+	EndpointsRefreshInterval     *metav1.Duration `json:"endpointsRefreshInterval,omitempty" flag:"endpoints-refresh-interval"`
+	NetworkChecksEnabled         *bool            `json:"networkChecksEnabled,omitempty" flag:"enable-network-checks"`
+	FindingEventsEnabled         *bool            `json:"findingEventsEnabled,omitempty" flag:"enable-finding-events"`
+	PodHostPathDisabled          *bool            `json:"podHostPathDisabled,omitempty" flag:"disable-pod-host-path"`
+	NetNSChecksEnabled           *bool            `json:"netNSChecksEnabled,omitempty" flag:"enable-netns-checks"`
+	ExistingServiceAccount       *string          `json:"existingServiceAccount,omitempty" flag:"existing-service-account"`
+	RetentionHours               *int             `json:"retentionHours,omitempty" flag:"retention-hours"`
+	HostNetworkRetentionHours    *int             `json:"hostNetworkRetentionHours,omitempty" flag:"host-network-retention-hours"`
+	PodNetworkRetentionHours     *int             `json:"podNetworkRetentionHours,omitempty" flag:"pod-network-retention-hours"`
+	LogDroppingFactor            *float64         `json:"logDroppingFactor,omitempty" flag:"log-dropping-factor"`
+	HostNetworkLogDroppingFactor *float64         `json:"hostNetworkLogDroppingFactor,omitempty" flag:"host-network-log-dropping-factor"`
+	PodNetworkLogDroppingFactor  *float64         `json:"podNetworkLogDroppingFactor,omitempty" flag:"pod-network-log-dropping-factor"`
+	VPAEnabled                   *bool            `json:"vpaEnabled,omitempty" flag:"enable-vpa"`
+	VPAUpdateMode                *string          `json:"vpaUpdateMode,omitempty" flag:"vpa-update-mode"`
+	VPARequired                  *bool            `json:"vpaRequired,omitempty" flag:"vpa-required"`
+
+	// fields only available in the values file
+	AdditionalAnnotations                        map[string]string            `json:"additionalAnnotations,omitempty"`
+	AdditionalLabels                             map[string]string            `json:"additionalLabels,omitempty"`
+	DisableAutomountServiceAccountTokenForAgents *bool                        `json:"disableAutomountServiceAccountTokenForAgents,omitempty"`
+	AgentResources                               *corev1.ResourceRequirements `json:"agentResources,omitempty"`
+	ControllerResources                          *corev1.ResourceRequirements `json:"controllerResources,omitempty"`
+	AdditionalTolerations                        []corev1.Toleration          `json:"additionalTolerations,omitempty"`
+}
+
+var durationType = reflect.TypeOf(metav1.Duration{})
+
+// LoadDeployValues reads a values file of the deploy command.
+func LoadDeployValues(filename string) (*DeployValues, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	values, err := ParseDeployValues(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid values file %s: %s", filename, err)
+	}
+	return values, nil
+}
+
+// ParseDeployValues unmarshals values in YAML or JSON format. Unknown fields are rejected.
+func ParseDeployValues(data []byte) (*DeployValues, error) {
+	values := &DeployValues{}
+	if err := yaml.UnmarshalStrict(data, values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// NewDeployValues returns the values of all fields of the deploy config.
+func NewDeployValues(ac *AgentDeployConfig) *DeployValues {
+	values := &DeployValues{}
+	v := reflect.ValueOf(values).Elem()
+	src := reflect.ValueOf(ac).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		value := src.FieldByName(v.Type().Field(i).Name)
+		switch {
+		case field.Type() == value.Type():
+			field.Set(value)
+		case field.Type().Elem() == durationType:
+			field.Set(reflect.ValueOf(&metav1.Duration{Duration: value.Interface().(time.Duration)}))
+		default:
+			ptr := reflect.New(value.Type())
+			ptr.Elem().Set(value)
+			field.Set(ptr)
+		}
+	}
+	return values
+}
+
+// ApplyTo sets the fields of the deploy config given by the values. Fields with a flag changed on the command line are skipped,
+// so that the flags take precedence.
+func (values *DeployValues) ApplyTo(ac *AgentDeployConfig, flags *pflag.FlagSet) {
+	v := reflect.ValueOf(values).Elem()
+	dst := reflect.ValueOf(ac).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.IsNil() {
+			continue
+		}
+		structField := v.Type().Field(i)
+		if name := structField.Tag.Get("flag"); name != "" && flags != nil && flags.Changed(name) {
+			continue
+		}
+		target := dst.FieldByName(structField.Name)
+		switch {
+		case field.Type() == target.Type():
+			target.Set(field)
+		case field.Type().Elem() == durationType:
+			target.Set(reflect.ValueOf(field.Interface().(*metav1.Duration).Duration))
+		default:
+			target.Set(field.Elem())
+		}
+	}
+}
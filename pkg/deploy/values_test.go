@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func newDeployFlags(ac *AgentDeployConfig) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("deploy", pflag.ContinueOnError)
+	ac.AddImageFlag("v1", flags)
+	ac.AddOptionFlags(flags)
+	return flags
+}
+
+func TestDeployValuesFlags(t *testing.T) {
+	flags := newDeployFlags(&AgentDeployConfig{})
+	typ := reflect.TypeOf(DeployValues{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if name := field.Tag.Get("flag"); name != "" {
+			assert.NotNil(t, flags.Lookup(name), "flag %s of field %s", name, field.Name)
+		}
+		_, found := reflect.TypeOf(AgentDeployConfig{}).FieldByName(field.Name)
+		assert.True(t, found, "field %s", field.Name)
+	}
+}
+
+func TestDeployValuesPrecedence(t *testing.T) {
+	values, err := ParseDeployValues([]byte(`
+image: registry.example.com/nwpd:v1.2.3
+defaultPeriod: 30s
+pingEnabled: true
+retentionHours: 8
+disabledJobs: [ping-*]
+agentResources:
+  requests:
+    cpu: 20m
+additionalTolerations:
+- key: dedicated
+  operator: Exists
+`))
+	assert.NoError(t, err)
+
+	ac := &AgentDeployConfig{}
+	flags := newDeployFlags(ac)
+	assert.NoError(t, flags.Parse([]string{"--retention-hours", "2", "--disable-jobs", "tcp-*"}))
+	values.ApplyTo(ac, flags)
+
+	assert.Equal(t, "registry.example.com/nwpd:v1.2.3", ac.Image)
+	assert.Equal(t, 30*time.Second, ac.DefaultPeriod)
+	assert.True(t, ac.PingEnabled)
+	assert.Equal(t, 2, ac.RetentionHours, "flag takes precedence")
+	assert.Equal(t, []string{"tcp-*"}, ac.DisabledJobs, "flag takes precedence")
+	assert.True(t, ac.PodSecurityPolicyEnabled, "default of flag")
+	assert.Equal(t, 3*time.Minute, ac.K8sExporterHeartbeat, "default of flag")
+	assert.Equal(t, resource.MustParse("20m"), ac.AgentResources.Requests[corev1.ResourceCPU])
+	assert.Equal(t, []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}}, ac.AdditionalTolerations)
+
+	ds, err := ac.buildDaemonSet("sa", false)
+	assert.NoError(t, err)
+	assert.Equal(t, *ac.AgentResources, ds.Spec.Template.Spec.Containers[0].Resources)
+	assert.Len(t, ds.Spec.Template.Spec.Tolerations, 3)
+}
+
+func TestDeployValuesRoundTrip(t *testing.T) {
+	ac := &AgentDeployConfig{}
+	assert.NoError(t, newDeployFlags(ac).Parse([]string{"--enable-ping", "--default-period", "1m", "--job-labels", "tcp-*:tier=a"}))
+	ac.AdditionalLabels = map[string]string{"foo": "bar"}
+
+	copied := &AgentDeployConfig{}
+	NewDeployValues(ac).ApplyTo(copied, nil)
+	assert.Equal(t, ac, copied)
+}
+
+func TestLoadDeployValues(t *testing.T) {
+	dir := t.TempDir()
+	for _, testCase := range []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{name: "values.yaml", content: "pingEnabled: true\nretentionHours: 6\n"},
+		{name: "values.json", content: `{"pingEnabled": true, "retentionHours": 6}`},
+		{name: "unknown.yaml", content: "pingEnabled: true\nretentionHour: 6\n", err: `unknown field "retentionHour"`},
+		{name: "type.yaml", content: "retentionHours: six\n", err: "retentionHours of type int"},
+		{name: "duration.yaml", content: "defaultPeriod: 10 seconds\n", err: "time: unknown unit"},
+		{name: "malformed.yaml", content: "pingEnabled: [true\n", err: "error converting YAML to JSON"},
+	} {
+		filename := filepath.Join(dir, testCase.name)
+		assert.NoError(t, os.WriteFile(filename, []byte(testCase.content), 0644))
+		values, err := LoadDeployValues(filename)
+		if testCase.err != "" {
+			if assert.Error(t, err, testCase.name) {
+				assert.Contains(t, err.Error(), "invalid values file "+filename)
+				assert.Contains(t, err.Error(), testCase.err)
+			}
+			continue
+		}
+		if assert.NoError(t, err, testCase.name) {
+			assert.True(t, *values.PingEnabled)
+			assert.Equal(t, 6, *values.RetentionHours)
+		}
+	}
+
+	_, err := LoadDeployValues(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,206 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// VPAUpdateModeOff only computes recommendations without changing the pods.
+	VPAUpdateModeOff = "Off"
+	// VPAUpdateModeInitial applies the recommendations on pod creation only.
+	VPAUpdateModeInitial = "Initial"
+	// VPAUpdateModeRecreate applies the recommendations by evicting pods.
+	VPAUpdateModeRecreate = "Recreate"
+	// VPAUpdateModeAuto applies the recommendations with the best available method (currently Recreate).
+	VPAUpdateModeAuto = "Auto"
+
+	vpaKind = "VerticalPodAutoscaler"
+)
+
+// vpaResource is the resource of the VerticalPodAutoscaler objects of the vertical pod autoscaler.
+var vpaResource = schema.GroupVersionResource{
+	Group:    "autoscaling.k8s.io",
+	Version:  "v1",
+	Resource: "verticalpodautoscalers",
+}
+
+func validateVPAUpdateMode(mode string) error {
+	switch mode {
+	case VPAUpdateModeOff, VPAUpdateModeInitial, VPAUpdateModeRecreate, VPAUpdateModeAuto:
+		return nil
+	}
+	return fmt.Errorf("invalid VPA update mode %q: must be one of %s, %s, %s or %s", mode,
+		VPAUpdateModeOff, VPAUpdateModeInitial, VPAUpdateModeRecreate, VPAUpdateModeAuto)
+}
+
+// buildVPA builds the VerticalPodAutoscaler of a daemon set or deployment. The requests of the pod are bounded by the configured
+// requests (minAllowed) and limits (maxAllowed) of its first container, the limits are not changed.
+func (ac *AgentDeployConfig) buildVPA(workload Object) (*unstructured.Unstructured, error) {
+	var (
+		kind      string
+		resources corev1.ResourceRequirements
+	)
+	switch v := workload.(type) {
+	case *appsv1.DaemonSet:
+		kind = "DaemonSet"
+		resources = v.Spec.Template.Spec.Containers[0].Resources
+	case *appsv1.Deployment:
+		kind = "Deployment"
+		resources = v.Spec.Template.Spec.Containers[0].Resources
+	default:
+		return nil, fmt.Errorf("unsupported VPA target type: %T", v)
+	}
+	updateMode := ac.VPAUpdateMode
+	if updateMode == "" {
+		updateMode = VPAUpdateModeOff
+	}
+	if err := validateVPAUpdateMode(updateMode); err != nil {
+		return nil, err
+	}
+
+	policy := map[string]interface{}{
+		"containerName":    "*",
+		"controlledValues": "RequestsOnly",
+	}
+	if len(resources.Requests) > 0 {
+		policy["minAllowed"] = resourceListValues(resources.Requests)
+	}
+	if len(resources.Limits) > 0 {
+		policy["maxAllowed"] = resourceListValues(resources.Limits)
+	}
+	vpa := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"targetRef": map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       kind,
+				"name":       workload.GetName(),
+			},
+			"updatePolicy": map[string]interface{}{
+				"updateMode": updateMode,
+			},
+			"resourcePolicy": map[string]interface{}{
+				"containerPolicies": []interface{}{policy},
+			},
+		},
+	}}
+	vpa.SetAPIVersion(vpaResource.GroupVersion().String())
+	vpa.SetKind(vpaKind)
+	vpa.SetName(workload.GetName())
+	vpa.SetNamespace(workload.GetNamespace())
+	vpa.SetLabels(ac.getLabels(workload.GetName()))
+	return vpa, nil
+}
+
+func resourceListValues(list corev1.ResourceList) map[string]interface{} {
+	values := map[string]interface{}{}
+	for name, quantity := range list {
+		values[string(name)] = quantity.String()
+	}
+	return values
+}
+
+// appendVPAs appends a VerticalPodAutoscaler for each daemon set and deployment of the objects if enabled.
+func (ac *AgentDeployConfig) appendVPAs(objects []Object) ([]Object, error) {
+	if !ac.VPAEnabled {
+		return objects, nil
+	}
+	for _, obj := range objects {
+		switch obj.(type) {
+		case *appsv1.DaemonSet, *appsv1.Deployment:
+			vpa, err := ac.buildVPA(obj)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, vpa)
+		}
+	}
+	return objects, nil
+}
+
+// vpaSupported checks if the custom resource VerticalPodAutoscaler is served by the API server.
+func vpaSupported(client discovery.DiscoveryInterface) (bool, error) {
+	resources, err := client.ServerResourcesForGroupVersion(vpaResource.GroupVersion().String())
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error discovering %s: %w", vpaResource.GroupVersion(), err)
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == vpaResource.Resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// filterVPAs removes the VerticalPodAutoscalers from the objects if the cluster does not support them.
+// It fails instead if they are required.
+func filterVPAs(log logrus.FieldLogger, client discovery.DiscoveryInterface, objects []Object, required bool) ([]Object, error) {
+	var (
+		result []Object
+		vpas   int
+	)
+	for _, obj := range objects {
+		if isVPA(obj) {
+			vpas++
+		} else {
+			result = append(result, obj)
+		}
+	}
+	if vpas == 0 {
+		return objects, nil
+	}
+	supported, err := vpaSupported(client)
+	if err != nil {
+		return nil, err
+	}
+	if supported {
+		return objects, nil
+	}
+	crd := vpaResource.GroupResource().String()
+	if required {
+		return nil, fmt.Errorf("custom resource definition %s not found: install the vertical pod autoscaler or deploy without --enable-vpa", crd)
+	}
+	log.Warnf("custom resource definition %s not found, skipping %d vertical pod autoscalers", crd, vpas)
+	return result, nil
+}
+
+func isVPA(obj Object) bool {
+	u, ok := obj.(*unstructured.Unstructured)
+	return ok && u.GetKind() == vpaKind
+}
+
+// dynamicObjects adapts a dynamic resource client to the ObjectInterface used by createOrUpdate.
+type dynamicObjects struct {
+	client dynamic.ResourceInterface
+}
+
+var _ ObjectInterface[*unstructured.Unstructured] = dynamicObjects{}
+
+func (d dynamicObjects) Create(ctx context.Context, obj *unstructured.Unstructured, opts metav1.CreateOptions) (*unstructured.Unstructured, error) {
+	return d.client.Create(ctx, obj, opts)
+}
+
+func (d dynamicObjects) Update(ctx context.Context, obj *unstructured.Unstructured, opts metav1.UpdateOptions) (*unstructured.Unstructured, error) {
+	return d.client.Update(ctx, obj, opts)
+}
+
+func (d dynamicObjects) Get(ctx context.Context, name string, opts metav1.GetOptions) (*unstructured.Unstructured, error) {
+	return d.client.Get(ctx, name, opts)
+}
@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package deploy
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+)
+
+func vpas(objects []Object) map[string]*unstructured.Unstructured {
+	result := map[string]*unstructured.Unstructured{}
+	for _, obj := range objects {
+		if isVPA(obj) {
+			result[obj.GetName()] = obj.(*unstructured.Unstructured)
+		}
+	}
+	return result
+}
+
+type fakeDiscovery struct {
+	discovery.DiscoveryInterface
+	resources []*metav1.APIResourceList
+}
+
+func (d *fakeDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	for _, list := range d.resources {
+		if list.GroupVersion == groupVersion {
+			return list, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(schema.GroupResource{}, groupVersion)
+}
+
+func TestVPA(t *testing.T) {
+	ac := &AgentDeployConfig{VPAEnabled: true}
+	objects, err := DeployNetworkProblemDetectorAgent(ac)
+	assert.NoError(t, err)
+	deployment, _, _, _, _, _, err := ac.buildControllerDeployment()
+	assert.NoError(t, err)
+	objects, err = ac.appendVPAs(append(objects, deployment))
+	assert.NoError(t, err)
+
+	result := vpas(objects)
+	assert.Len(t, result, 3)
+	for name, kind := range map[string]string{
+		common.NameDaemonSetAgentHostNet:     "DaemonSet",
+		common.NameDaemonSetAgentPodNet:      "DaemonSet",
+		common.NameDeploymentAgentController: "Deployment",
+	} {
+		vpa := result[name]
+		if !assert.NotNil(t, vpa, name) {
+			continue
+		}
+		assert.Equal(t, "autoscaling.k8s.io/v1", vpa.GetAPIVersion())
+		assert.Equal(t, common.NamespaceKubeSystem, vpa.GetNamespace())
+		targetRef, _, _ := unstructured.NestedStringMap(vpa.Object, "spec", "targetRef")
+		assert.Equal(t, map[string]string{"apiVersion": "apps/v1", "kind": kind, "name": name}, targetRef)
+		mode, _, _ := unstructured.NestedString(vpa.Object, "spec", "updatePolicy", "updateMode")
+		assert.Equal(t, VPAUpdateModeOff, mode)
+	}
+
+	policies, _, _ := unstructured.NestedSlice(result[common.NameDaemonSetAgentHostNet].Object, "spec", "resourcePolicy", "containerPolicies")
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"containerName":    "*",
+		"controlledValues": "RequestsOnly",
+		"minAllowed":       map[string]interface{}{"cpu": "10m", "memory": "32Mi"},
+		"maxAllowed":       map[string]interface{}{"cpu": "50m", "memory": "64Mi"},
+	}}, policies)
+
+	ac.VPAUpdateMode = "Always"
+	_, err = ac.buildVPA(deployment)
+	assert.Error(t, err)
+	ac.VPAUpdateMode = VPAUpdateModeAuto
+	vpa, err := ac.buildVPA(deployment)
+	assert.NoError(t, err)
+	mode, _, _ := unstructured.NestedString(vpa.Object, "spec", "updatePolicy", "updateMode")
+	assert.Equal(t, VPAUpdateModeAuto, mode)
+
+	ac.VPAEnabled = false
+	objects, err = DeployNetworkProblemDetectorAgent(ac)
+	assert.NoError(t, err)
+	assert.Empty(t, vpas(objects))
+}
+
+func TestFilterVPAs(t *testing.T) {
+	ac := &AgentDeployConfig{VPAEnabled: true}
+	ds, err := ac.buildDaemonSet("sa", true)
+	assert.NoError(t, err)
+	objects, err := ac.appendVPAs([]Object{ds})
+	assert.NoError(t, err)
+	assert.Len(t, objects, 2)
+
+	discovery := &fakeDiscovery{}
+	filtered, err := filterVPAs(logrus.New(), discovery, objects, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []Object{ds}, filtered, "skipped without CRD")
+	_, err = filterVPAs(logrus.New(), discovery, objects, true)
+	assert.Error(t, err, "required without CRD")
+
+	discovery.resources = []*metav1.APIResourceList{{
+		GroupVersion: "autoscaling.k8s.io/v1",
+		APIResources: []metav1.APIResource{{Name: "verticalpodautoscalers", Kind: "VerticalPodAutoscaler"}},
+	}}
+	filtered, err = filterVPAs(logrus.New(), discovery, objects, true)
+	assert.NoError(t, err)
+	assert.Equal(t, objects, filtered)
+
+	stages := applyStages(objects)
+	assert.Len(t, stages, 2)
+	assert.IsType(t, &appsv1.DaemonSet{}, stages[1][0], "VPA applied before workload")
+}
@@ -0,0 +1,344 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package external
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/agent"
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/analysis"
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	"github.com/gardener/network-problem-detector/pkg/deploy"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+type externalCommand struct {
+	common.ClientsetBase
+	jobs           []string
+	runFor         time.Duration
+	srcName        string
+	summaryFile    string
+	thresholds     agent.SummaryThresholds
+	compareInput   string
+	compareMinutes int
+}
+
+// externalJob is a job of the agent config rewritten for the execution outside of the cluster.
+type externalJob struct {
+	job    config.Job
+	runner runners.Runner
+}
+
+func CreateRunExternalCmd() *cobra.Command {
+	ec := &externalCommand{}
+	cmd := &cobra.Command{
+		Use:   "run-external",
+		Short: "run the checks of the agents from outside of the cluster",
+		Long: `run the checks of the agents from outside of the cluster, e.g. from a workstation or a bastion host.
+The jobs and the cluster config are read from the config maps of the agents. Nodes are checked on their external IPs where available.
+Jobs with targets only reachable from inside the cluster (pod network, cluster IPs, internal kube-apiserver, cluster DNS) are skipped.
+The summary is written in the same format as the summary of 'run-agent --run-for'.
+With '--compare-input', the results are compared with the observations collected by the agents.`,
+		RunE: ec.run,
+	}
+	ec.AddKubeConfigFlag(cmd.Flags())
+	cmd.Flags().StringSliceVar(&ec.jobs, "jobs", nil, "job IDs to run (prefix or pattern with '*', default: all jobs which can run externally)")
+	cmd.Flags().DurationVar(&ec.runFor, "run-for", 0, "duration to run the jobs with their periods (0: check each destination once)")
+	cmd.Flags().StringVar(&ec.srcName, "src-name", "external", "source host name of the observations")
+	cmd.Flags().StringVar(&ec.summaryFile, "summary-file", "", "file for the JSON summary (default: stdout)")
+	cmd.Flags().Float64Var(&ec.thresholds.DegradedRatio, "summary-degraded-ratio", 0.01, "failure ratio of a job above which the verdict is 'degraded'")
+	cmd.Flags().Float64Var(&ec.thresholds.FailedRatio, "summary-failed-ratio", 0.05, "failure ratio of a job above which the verdict is 'failed'")
+	cmd.Flags().StringVar(&ec.compareInput, "compare-input", "", "optional directory with the observations collected from the agents (see 'collect') to compare the results with")
+	cmd.Flags().IntVar(&ec.compareMinutes, "compare-minutes", 60, "time window of the collected observations to compare with in minutes (ending now)")
+	return cmd
+}
+
+func (ec *externalCommand) run(_ *cobra.Command, _ []string) error {
+	log := logrus.WithField("cmd", "run-external")
+
+	if ec.runFor < 0 {
+		return fmt.Errorf("invalid --run-for option: must not be negative")
+	}
+	if ec.thresholds.DegradedRatio > ec.thresholds.FailedRatio {
+		return fmt.Errorf("invalid thresholds: --summary-degraded-ratio must not be greater than --summary-failed-ratio")
+	}
+	if ec.compareInput != "" && ec.compareMinutes <= 0 {
+		return fmt.Errorf("invalid --compare-minutes option: must be positive")
+	}
+	if err := ec.SetupClientSet(); err != nil {
+		return err
+	}
+	jobs, err := ec.loadJobs(log)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("no jobs to run externally")
+	}
+
+	start := time.Now()
+	observations := ec.runJobs(log, jobs)
+	end := time.Now()
+	if !end.After(start) {
+		end = start.Add(time.Millisecond)
+	}
+
+	summary, err := agent.SummarizeObservations(observations, start, end, false, ec.thresholds)
+	if err != nil {
+		return err
+	}
+	if err := agent.WriteSummary(summary, ec.summaryFile); err != nil {
+		return err
+	}
+	log.Infof("verdict: %s", summary.Verdict)
+
+	if ec.compareInput != "" {
+		if err := ec.compare(log, observations, start, end); err != nil {
+			return err
+		}
+	}
+	if summary.ExitCode != 0 {
+		os.Exit(summary.ExitCode)
+	}
+	return nil
+}
+
+// loadJobs reads the agent and cluster config maps and returns the selected jobs rewritten for the external execution.
+func (ec *externalCommand) loadJobs(log logrus.FieldLogger) ([]externalJob, error) {
+	ctx := context.Background()
+	configMaps := ec.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem)
+	cm, err := common.LoadAgentConfigMap(ctx, configMaps)
+	if err != nil {
+		return nil, fmt.Errorf("loading agent config map failed: %w", err)
+	}
+	agentConfig := &config.AgentConfig{}
+	if err := yaml.Unmarshal([]byte(cm.Data[common.AgentConfigFilename]), agentConfig); err != nil {
+		return nil, fmt.Errorf("parsing agent config failed: %w", err)
+	}
+	clusterConfig, err := deploy.LoadClusterConfigFromConfigMaps(ctx, configMaps)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := ec.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes failed: %w", err)
+	}
+	externalIPs := nodeExternalIPs(nodes.Items)
+	log.Infof("%d of %d nodes have an external IP", len(externalIPs), len(clusterConfig.Nodes))
+	clusterCfg := externalClusterConfig(clusterConfig, externalIPs)
+
+	var result []externalJob
+	for _, nc := range []*config.NetworkConfig{agentConfig.HostNetwork, agentConfig.PodNetwork} {
+		if nc == nil {
+			continue
+		}
+		period := nc.DefaultPeriod.Duration
+		if period == 0 {
+			period = time.Second
+		}
+		for _, job := range nc.Jobs {
+			selected, err := ec.selected(job.JobID)
+			if err != nil {
+				return nil, err
+			}
+			if !selected {
+				continue
+			}
+			rewritten, reason := rewriteJob(job)
+			if reason != "" {
+				log.Infof("skipping job %s: %s", job.JobID, reason)
+				continue
+			}
+			// the resolver cache of the agent is not available
+			rewritten.BypassResolverCache = true
+			runner, err := runners.Parse(clusterCfg, runners.RunnerConfig{Job: rewritten, Period: period}, rewritten.Args, false)
+			if err != nil {
+				return nil, fmt.Errorf("invalid job %s: %w", job.JobID, err)
+			}
+			if runner == nil {
+				log.Infof("skipping job %s: no destinations reachable externally", job.JobID)
+				continue
+			}
+			result = append(result, externalJob{job: rewritten, runner: runner})
+		}
+	}
+	return result, nil
+}
+
+func (ec *externalCommand) selected(jobID string) (bool, error) {
+	if len(ec.jobs) == 0 {
+		return true, nil
+	}
+	for _, pattern := range ec.jobs {
+		ok, err := deploy.MatchJobID(pattern, jobID)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// runJobs runs all jobs concurrently and returns the observations. Without '--run-for' each destination is checked once.
+func (ec *externalCommand) runJobs(log logrus.FieldLogger, jobs []externalJob) []*nwpd.Observation {
+	ch := make(chan *nwpd.Observation, 100)
+	var observations []*nwpd.Observation
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for obs := range ch {
+			obs.SrcHost = ec.srcName
+			observations = append(observations, obs)
+		}
+	}()
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j externalJob) {
+			defer wg.Done()
+			log.Infof("running job %s: %s", j.job.JobID, j.runner.Description())
+			if ec.runFor == 0 {
+				for i := 0; i < len(j.runner.DestHosts()) || i == 0; i++ {
+					j.runner.Run(ctx, ch)
+				}
+				return
+			}
+			deadline := time.Now().Add(ec.runFor)
+			ticker := time.NewTicker(j.runner.Config().Period)
+			defer ticker.Stop()
+			for {
+				j.runner.Run(ctx, ch)
+				if !time.Now().Add(j.runner.Config().Period).Before(deadline) {
+					return
+				}
+				<-ticker.C
+			}
+		}(j)
+	}
+	wg.Wait()
+	close(ch)
+	<-done
+	return observations
+}
+
+// difference is a destination of a job with different results inside and outside of the cluster.
+type difference struct {
+	jobID          string
+	dest           string
+	internalRatio  float64
+	externalRatio  float64
+	internalFailed bool
+}
+
+func (d difference) String() string {
+	where, other := "inside", "outside"
+	if !d.internalFailed {
+		where, other = other, where
+	}
+	return fmt.Sprintf("job %s to %s fails only %s of the cluster (failure ratio internal %.2f, external %.2f, ok %s)",
+		d.jobID, d.dest, where, d.internalRatio, d.externalRatio, other)
+}
+
+// compare compares the external results with the observations collected from the agents and logs the differences.
+func (ec *externalCommand) compare(log logrus.FieldLogger, observations []*nwpd.Observation, start, end time.Time) error {
+	source, err := analysis.OpenDir(ec.compareInput)
+	if err != nil {
+		return err
+	}
+	internalEnd := time.Now()
+	internal, err := analysis.Aggregate(source, analysis.Options{
+		Filter:  analysis.Filter{Start: internalEnd.Add(-time.Duration(ec.compareMinutes) * time.Minute), End: internalEnd},
+		Buckets: ec.compareMinutes,
+	})
+	if err != nil {
+		return err
+	}
+	aggregator, err := analysis.NewAggregator(analysis.Options{
+		Filter:  analysis.Filter{Start: start, End: end},
+		Buckets: int(math.Ceil(end.Sub(start).Minutes())),
+	})
+	if err != nil {
+		return err
+	}
+	for _, obs := range observations {
+		aggregator.Add(obs)
+	}
+	diffs := compareResults(internal, aggregator.Report(), ec.thresholds.DegradedRatio)
+	for _, d := range diffs {
+		log.Warn(d.String())
+	}
+	log.Infof("%d destinations with different results inside and outside of the cluster", len(diffs))
+	return nil
+}
+
+// destResults are the counts of the results of a job to a destination over all sources.
+type destResults struct {
+	ok, failed int
+}
+
+func (r destResults) failureRatio() float64 {
+	if r.ok+r.failed == 0 {
+		return 0
+	}
+	return float64(r.failed) / float64(r.ok+r.failed)
+}
+
+func resultsByDest(report *analysis.Report) map[[2]string]destResults {
+	result := map[[2]string]destResults{}
+	for edge, ed := range report.Edges {
+		for jobID, jr := range ed.JobResults {
+			key := [2]string{jobID, edge.Dest}
+			r := result[key]
+			r.ok += jr.OkCount()
+			r.failed += jr.FailedCount()
+			result[key] = r
+		}
+	}
+	return result
+}
+
+// compareResults returns the destinations of jobs checked inside and outside of the cluster, which are failing on one side only.
+// A destination is failing if its failure ratio is above the threshold.
+func compareResults(internal, external *analysis.Report, threshold float64) []difference {
+	internalResults := resultsByDest(internal)
+	var diffs []difference
+	for key, ext := range resultsByDest(external) {
+		in, ok := internalResults[key]
+		if !ok || in.ok+in.failed == 0 || ext.ok+ext.failed == 0 {
+			continue
+		}
+		inFailed := in.failureRatio() > threshold
+		if inFailed != (ext.failureRatio() > threshold) {
+			diffs = append(diffs, difference{
+				jobID:          key[0],
+				dest:           key[1],
+				internalRatio:  in.failureRatio(),
+				externalRatio:  ext.failureRatio(),
+				internalFailed: inFailed,
+			})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].jobID != diffs[j].jobID {
+			return diffs[i].jobID < diffs[j].jobID
+		}
+		return diffs[i].dest < diffs[j].dest
+	})
+	return diffs
+}
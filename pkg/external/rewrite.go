@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package external
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gardener/network-problem-detector/pkg/agent/runners"
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// inClusterCommands are the runner commands which only work inside the cluster.
+var inClusterCommands = map[string]string{
+	runners.CollectNetStatsCmd:    "reads the network counters of the node",
+	runners.CheckDefaultRouteCmd:  "checks the default route of the agent",
+	runners.CheckAPIServerAuthCmd: "needs the service account token of the agent",
+	runners.CheckProxyRulesCmd:    "reads the kube-proxy rules of the node",
+}
+
+// inClusterOptions are the runner options selecting targets which are only reachable from inside the cluster.
+var inClusterOptions = map[string]string{
+	"--endpoints-of-pod-ds":              "pod network target",
+	"--endpoints-of-services":            "cluster IP target",
+	"--endpoint-internal-kube-apiserver": "internal kube-apiserver target",
+	"--name-internal-kube-apiserver":     "internal kube-apiserver name",
+	"--dns-server":                       "cluster DNS server",
+	"--netns":                            "network namespace of the node",
+	"--interface":                        "network interface of the node",
+}
+
+// rewriteJob returns the job rewritten for the execution outside of the cluster or the reason why it cannot be run externally.
+func rewriteJob(job config.Job) (config.Job, string) {
+	if len(job.Args) == 0 {
+		return job, "no job args"
+	}
+	if reason, ok := inClusterCommands[job.Args[0]]; ok {
+		return job, fmt.Sprintf("%s %s", job.Args[0], reason)
+	}
+	for _, arg := range job.Args[1:] {
+		name := strings.SplitN(arg, "=", 2)[0]
+		if reason, ok := inClusterOptions[name]; ok {
+			return job, fmt.Sprintf("option %s selects %s", name, reason)
+		}
+		if strings.HasPrefix(arg, common.DestHostIMDS+":") {
+			return job, "instance metadata service is only reachable from the nodes"
+		}
+	}
+	rewritten := job
+	rewritten.Args = append([]string{}, job.Args...)
+	if job.Args[0] == "checkKubelet" {
+		// the healthz endpoint of the kubelet is only served on localhost of the node
+		rewritten.Args = append(rewritten.Args, "--healthz-port", "0")
+	}
+	return rewritten, ""
+}
+
+// externalClusterConfig returns the cluster config for the checks from outside of the cluster. The nodes use their external IPs
+// where available, and the targets only reachable from inside the cluster are removed.
+func externalClusterConfig(clusterCfg *config.ClusterConfig, externalIPs map[string]string) config.ClusterConfig {
+	cfg := config.ClusterConfig{
+		KubeAPIServer:      clusterCfg.KubeAPIServer,
+		LoadBalancers:      clusterCfg.LoadBalancers,
+		URLEndpoints:       clusterCfg.URLEndpoints,
+		MaintenanceWindows: clusterCfg.MaintenanceWindows,
+		NodeSelector:       clusterCfg.NodeSelector,
+	}
+	for _, node := range clusterCfg.Nodes {
+		if ip := externalIPs[node.Hostname]; ip != "" {
+			node.InternalIP = ip
+		}
+		cfg.Nodes = append(cfg.Nodes, node)
+	}
+	return cfg
+}
+
+// nodeExternalIPs returns the external IPs of the nodes by host name.
+func nodeExternalIPs(nodes []corev1.Node) map[string]string {
+	result := map[string]string{}
+	for _, n := range nodes {
+		hostname, ip := "", ""
+		for _, addr := range n.Status.Addresses {
+			switch addr.Type {
+			case corev1.NodeHostName:
+				hostname = addr.Address
+			case corev1.NodeExternalIP:
+				if ip == "" {
+					ip = addr.Address
+				}
+			}
+		}
+		if hostname != "" && ip != "" {
+			result[hostname] = ip
+		}
+	}
+	return result
+}
@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package external
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/analysis"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRewriteJob(t *testing.T) {
+	for _, tc := range []struct {
+		args     []string
+		expected []string
+		skipped  bool
+	}{
+		{args: []string{"checkTCPPort", "--node-port", "10250"}, expected: []string{"checkTCPPort", "--node-port", "10250"}},
+		{args: []string{"checkHTTPSGet", "--endpoint-external-kube-apiserver", "--period", "1m"}, expected: []string{"checkHTTPSGet", "--endpoint-external-kube-apiserver", "--period", "1m"}},
+		{args: []string{"checkKubelet"}, expected: []string{"checkKubelet", "--healthz-port", "0"}},
+		{args: []string{"checkTCPPort", "--endpoints-of-pod-ds"}, skipped: true},
+		{args: []string{"checkTCPPort", "--endpoints-of-services"}, skipped: true},
+		{args: []string{"checkTCPPort", "--endpoint-internal-kube-apiserver", "--scale-period"}, skipped: true},
+		{args: []string{"nslookup", "--names", "eu.gcr.io.", "--dns-server=kube-dns"}, skipped: true},
+		{args: []string{"checkTCPPort", "--endpoints", "imds:169.254.169.254:80"}, skipped: true},
+		{args: []string{"checkDefaultRoute", "--period", "1m"}, skipped: true},
+		{args: []string{"checkAPIServerAuth"}, skipped: true},
+		{args: nil, skipped: true},
+	} {
+		job := config.Job{JobID: "job", Args: tc.args}
+		actual, reason := rewriteJob(job)
+		if tc.skipped {
+			assert.NotEmpty(t, reason, "%v", tc.args)
+			continue
+		}
+		assert.Empty(t, reason, "%v", tc.args)
+		assert.Equal(t, tc.expected, actual.Args)
+		assert.Equal(t, tc.args, job.Args, "original job must not be modified")
+	}
+}
+
+func TestExternalClusterConfig(t *testing.T) {
+	nodes := []corev1.Node{
+		{Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+			{Type: corev1.NodeHostName, Address: "node1"},
+			{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+			{Type: corev1.NodeExternalIP, Address: "1.2.3.4"},
+		}}},
+		{Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+			{Type: corev1.NodeHostName, Address: "node2"},
+			{Type: corev1.NodeInternalIP, Address: "10.0.0.2"},
+		}}},
+	}
+	externalIPs := nodeExternalIPs(nodes)
+	assert.Equal(t, map[string]string{"node1": "1.2.3.4"}, externalIPs)
+
+	kapi := &config.Endpoint{Hostname: "api.example.com", IP: "5.6.7.8", Port: 443}
+	clusterCfg := &config.ClusterConfig{
+		Nodes: []config.Node{
+			{Hostname: "node1", InternalIP: "10.0.0.1", Zone: "a"},
+			{Hostname: "node2", InternalIP: "10.0.0.2", Zone: "b"},
+		},
+		KubeAPIServer:         kapi,
+		InternalKubeAPIServer: &config.Endpoint{Hostname: "kubernetes", IP: "100.64.0.1", Port: 443},
+		PodEndpoints:          []config.PodEndpoint{{Nodename: "node1", Podname: "pod1", PodIP: "100.96.0.1", Port: 1011}},
+	}
+	actual := externalClusterConfig(clusterCfg, externalIPs)
+	assert.Equal(t, config.ClusterConfig{
+		Nodes: []config.Node{
+			{Hostname: "node1", InternalIP: "1.2.3.4", Zone: "a"},
+			{Hostname: "node2", InternalIP: "10.0.0.2", Zone: "b"},
+		},
+		KubeAPIServer: kapi,
+	}, actual)
+	assert.Equal(t, "10.0.0.1", clusterCfg.Nodes[0].InternalIP)
+}
+
+func TestCompareResults(t *testing.T) {
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	aggregate := func(results map[string][]bool) *analysis.Report {
+		aggregator, err := analysis.NewAggregator(analysis.Options{
+			Filter:  analysis.Filter{Start: start, End: start.Add(time.Minute)},
+			Buckets: 1,
+		})
+		assert.NoError(t, err)
+		for dest, oks := range results {
+			for i, ok := range oks {
+				aggregator.Add(&nwpd.Observation{
+					SrcHost:   "src",
+					DestHost:  dest,
+					JobID:     "tcp-n2kubeproxy",
+					Ok:        ok,
+					Timestamp: timestamppb.New(start.Add(time.Duration(i) * time.Second)),
+				})
+			}
+		}
+		return aggregator.Report()
+	}
+
+	internal := aggregate(map[string][]bool{
+		"node1": {true, true, true, true},
+		"node2": {true, true, true, true},
+		"node3": {false, false, true, true},
+		"node4": {true},
+	})
+	external := aggregate(map[string][]bool{
+		"node1": {true},
+		"node2": {false},
+		"node3": {true},
+		"node5": {false},
+	})
+	assert.Equal(t, []difference{
+		{jobID: "tcp-n2kubeproxy", dest: "node2", internalRatio: 0, externalRatio: 1, internalFailed: false},
+		{jobID: "tcp-n2kubeproxy", dest: "node3", internalRatio: 0.5, externalRatio: 0, internalFailed: true},
+	}, compareResults(internal, external, 0.01))
+}
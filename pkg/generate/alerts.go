@@ -0,0 +1,183 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package generate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+)
+
+const (
+	// recordZonePairFailureRatio is the name prefix of the recording rules for the failure ratio per job and zone pair.
+	// The window is appended, e.g. 'nwpd:zone_pair_failure_ratio:rate1h'.
+	recordZonePairFailureRatio = "nwpd:zone_pair_failure_ratio:rate"
+	// externalZone is the destination zone of observations whose destination is not a node of the cluster
+	// (e.g. the kube-apiserver or load balancers)
+	externalZone = "external"
+)
+
+// burnRateAlert is a multi-window burn rate alert. It fires if the error budget is consumed with at least
+// the given burn rate over both the long and the short window.
+type burnRateAlert struct {
+	name        string
+	severity    string
+	burnRate    float64
+	longWindow  time.Duration
+	shortWindow time.Duration
+	forDuration time.Duration
+}
+
+// alertsConfig contains the settings of the generated alerts.
+type alertsConfig struct {
+	// objective is the target ratio of successful observations, e.g. 0.99
+	objective float64
+	// alerts are the burn rate alerts, typically a fast burning one for paging and a slow burning one for tickets
+	alerts []burnRateAlert
+	// jobRegex restricts the jobs
+	jobRegex string
+	// labels are additional labels of all alerts
+	labels map[string]string
+	// runbookURL is an optional runbook URL added as annotation
+	runbookURL string
+}
+
+type ruleGroup struct {
+	Name     string `json:"name"`
+	Interval string `json:"interval,omitempty"`
+	Rules    []rule `json:"rules"`
+}
+
+type rule struct {
+	Record      string            `json:"record,omitempty"`
+	Alert       string            `json:"alert,omitempty"`
+	Expr        string            `json:"expr"`
+	For         string            `json:"for,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func (c *alertsConfig) validate() error {
+	if c.objective <= 0 || c.objective >= 1 {
+		return fmt.Errorf("invalid objective %g: must be in range (0,1)", c.objective)
+	}
+	if len(c.alerts) == 0 {
+		return fmt.Errorf("no alerts")
+	}
+	for _, a := range c.alerts {
+		if a.burnRate <= 0 {
+			return fmt.Errorf("invalid burn rate %g of alert %s: must be positive", a.burnRate, a.name)
+		}
+		if a.shortWindow < time.Minute || a.longWindow <= a.shortWindow {
+			return fmt.Errorf("invalid windows %s/%s of alert %s: short window must be at least 1m and shorter than long window",
+				a.shortWindow, a.longWindow, a.name)
+		}
+		if a.burnRate*(1-c.objective) > 1 {
+			return fmt.Errorf("burn rate %g of alert %s can never be reached with objective %g", a.burnRate, a.name, c.objective)
+		}
+	}
+	return nil
+}
+
+// buildRuleGroups creates a group with the recording rules of the failure ratio for all needed windows
+// and a group with the burn rate alerts.
+func (c *alertsConfig) buildRuleGroups() ([]ruleGroup, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	windowSet := map[time.Duration]struct{}{}
+	for _, a := range c.alerts {
+		windowSet[a.longWindow] = struct{}{}
+		windowSet[a.shortWindow] = struct{}{}
+	}
+	var windows []time.Duration
+	for w := range windowSet {
+		windows = append(windows, w)
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i] < windows[j] })
+
+	recording := ruleGroup{Name: "nwpd-failure-ratio.rules"}
+	for _, w := range windows {
+		recording.Rules = append(recording.Rules, rule{
+			Record: recordZonePairFailureRatio + formatDuration(w),
+			Expr:   c.failureRatioExpr(w),
+		})
+	}
+
+	alerting := ruleGroup{Name: "nwpd-burn-rate.alerts"}
+	errorBudget := 1 - c.objective
+	for _, a := range c.alerts {
+		threshold := a.burnRate * errorBudget
+		labels := map[string]string{"severity": a.severity}
+		for k, v := range c.labels {
+			labels[k] = v
+		}
+		annotations := map[string]string{
+			"summary": fmt.Sprintf("Job {{ $labels.jobid }} fails from zone {{ $labels.src_zone }} to zone {{ $labels.dest_zone }} (burn rate %g)", a.burnRate),
+			"description": fmt.Sprintf("The failure ratio of job {{ $labels.jobid }} from zone {{ $labels.src_zone }} to zone {{ $labels.dest_zone }} is "+
+				"{{ $value | humanizePercentage }} over the last %s and above %s over the last %s. "+
+				"At this rate, the error budget of the objective %s is consumed %g times faster than allowed.",
+				formatDuration(a.longWindow), formatRatio(threshold), formatDuration(a.shortWindow), formatRatio(c.objective), a.burnRate),
+		}
+		if c.runbookURL != "" {
+			annotations["runbook_url"] = c.runbookURL
+		}
+		alerting.Rules = append(alerting.Rules, rule{
+			Alert: a.name,
+			Expr: fmt.Sprintf("%s%s > %s\nand\n%s%s > %s",
+				recordZonePairFailureRatio, formatDuration(a.longWindow), formatFloat(threshold),
+				recordZonePairFailureRatio, formatDuration(a.shortWindow), formatFloat(threshold)),
+			For:         formatDuration(a.forDuration),
+			Labels:      labels,
+			Annotations: annotations,
+		})
+	}
+	return []ruleGroup{recording, alerting}, nil
+}
+
+// failureRatioExpr returns the ratio of failed observations per job and zone pair for the given window.
+// The zones of source and destination are joined from the metric of the node zones exported by the agents.
+// Destinations which are no nodes get the zone 'external'. Observations during maintenance windows are
+// counted with a separate status and are ignored.
+func (c *alertsConfig) failureRatioExpr(window time.Duration) string {
+	return fmt.Sprintf("sum by (jobid, src_zone, dest_zone) (%s)\n/\nsum by (jobid, src_zone, dest_zone) (%s)",
+		c.zonePairRateExpr(window, "failed"), c.zonePairRateExpr(window, "ok|failed"))
+}
+
+// zonePairRateExpr returns the rate of the observations matching the status regex with the labels src_zone and dest_zone.
+func (c *alertsConfig) zonePairRateExpr(window time.Duration, statusRegex string) string {
+	selector := fmt.Sprintf(`status=~"%s"`, statusRegex)
+	if c.jobRegex != "" {
+		selector += fmt.Sprintf(`,jobid=~"%s"`, c.jobRegex)
+	}
+	rate := fmt.Sprintf("rate(%s{%s}[%s])", common.MetricAggregatedObservations, selector, formatDuration(window))
+	withSrcZone := fmt.Sprintf(`label_replace(%s, "node", "$1", "src", "(.*)") * on (node) group_left (src_zone) %s`,
+		rate, zoneInfoExpr("src_zone"))
+	return fmt.Sprintf(`label_replace(%s, "node", "$1", "dest", "(.*)") * on (node) group_left (dest_zone) %s`+
+		` or on (src, dest, jobid, status) label_replace(%s, "dest_zone", "%s", "", "")`,
+		withSrcZone, zoneInfoExpr("dest_zone"), withSrcZone, externalZone)
+}
+
+func zoneInfoExpr(label string) string {
+	return fmt.Sprintf(`label_replace(max by (node, zone) (%s), "%s", "$1", "zone", "(.*)")`, common.MetricNodeZoneInfo, label)
+}
+
+func formatDuration(d time.Duration) string {
+	return model.Duration(d).String()
+}
+
+func formatFloat(f float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.6f", f), "0"), ".")
+}
+
+func formatRatio(f float64) string {
+	return formatFloat(f*100) + "%"
+}
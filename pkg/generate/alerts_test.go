@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package generate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gardener/network-problem-detector/pkg/agent"
+	"github.com/gardener/network-problem-detector/pkg/common"
+)
+
+func defaultAlertsConfig(t *testing.T) *alertsConfig {
+	ac := &alertsCommand{}
+	cmd := ac.command()
+	assert.NoError(t, cmd.ParseFlags([]string{"--label", "team=network"}))
+	cfg, err := ac.alertsConfig()
+	assert.NoError(t, err)
+	return cfg
+}
+
+// TestMetricNames ensures the generated rules use the names and labels of the metrics exported by the agent.
+func TestMetricNames(t *testing.T) {
+	for _, testCase := range []struct {
+		collector prometheus.Collector
+		name      string
+		labels    []string
+	}{
+		{agent.AggregatedObservations, common.MetricAggregatedObservations, []string{"src", "dest", "jobid", "status"}},
+		{agent.NodeZoneInfo, common.MetricNodeZoneInfo, []string{"node", "zone"}},
+	} {
+		ch := make(chan *prometheus.Desc, 1)
+		testCase.collector.Describe(ch)
+		desc := (<-ch).String()
+		assert.Contains(t, desc, fmt.Sprintf("fqName: %q", testCase.name))
+		assert.Contains(t, desc, fmt.Sprintf("variableLabels: %v", testCase.labels))
+	}
+}
+
+func TestBuildRuleGroups(t *testing.T) {
+	cfg := defaultAlertsConfig(t)
+	groups, err := cfg.buildRuleGroups()
+	assert.NoError(t, err)
+	assert.Len(t, groups, 2)
+
+	var records []string
+	for _, r := range groups[0].Rules {
+		records = append(records, r.Record)
+		assert.Contains(t, r.Expr, common.MetricAggregatedObservations+`{status=~"failed"}`)
+		assert.Contains(t, r.Expr, common.MetricAggregatedObservations+`{status=~"ok|failed"}`)
+		assert.Contains(t, r.Expr, common.MetricNodeZoneInfo)
+	}
+	assert.Equal(t, []string{
+		"nwpd:zone_pair_failure_ratio:rate5m",
+		"nwpd:zone_pair_failure_ratio:rate30m",
+		"nwpd:zone_pair_failure_ratio:rate1h",
+		"nwpd:zone_pair_failure_ratio:rate6h",
+	}, records)
+
+	fast := groups[1].Rules[0]
+	assert.Equal(t, "NetworkProblemDetectorFastBurn", fast.Alert)
+	assert.Equal(t, "nwpd:zone_pair_failure_ratio:rate1h > 0.144\nand\nnwpd:zone_pair_failure_ratio:rate5m > 0.144", fast.Expr)
+	assert.Equal(t, "2m", fast.For)
+	assert.Equal(t, map[string]string{"severity": "critical", "team": "network"}, fast.Labels)
+	for _, placeholder := range []string{"{{ $labels.jobid }}", "{{ $labels.src_zone }}", "{{ $labels.dest_zone }}"} {
+		assert.Contains(t, fast.Annotations["summary"], placeholder)
+		assert.Contains(t, fast.Annotations["description"], placeholder)
+	}
+	assert.NotContains(t, fast.Annotations, "runbook_url")
+
+	slow := groups[1].Rules[1]
+	assert.Equal(t, "nwpd:zone_pair_failure_ratio:rate6h > 0.06\nand\nnwpd:zone_pair_failure_ratio:rate30m > 0.06", slow.Expr)
+	assert.Equal(t, "warning", slow.Labels["severity"])
+}
+
+func TestJobRegex(t *testing.T) {
+	cfg := defaultAlertsConfig(t)
+	cfg.jobRegex = "tcp-.*"
+	groups, err := cfg.buildRuleGroups()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(groups[0].Rules[0].Expr, `jobid=~"tcp-.*"`))
+}
+
+func TestValidate(t *testing.T) {
+	for _, testCase := range []struct {
+		modify func(cfg *alertsConfig)
+		err    string
+	}{
+		{func(cfg *alertsConfig) { cfg.objective = 1 }, "invalid objective 1"},
+		{func(cfg *alertsConfig) { cfg.alerts[0].burnRate = 0 }, "invalid burn rate 0"},
+		{func(cfg *alertsConfig) { cfg.alerts[0].shortWindow = 2 * time.Hour }, "invalid windows 2h0m0s/1h0m0s"},
+		{func(cfg *alertsConfig) { cfg.objective = 0.9 }, "burn rate 14.4 of alert NetworkProblemDetectorFastBurn can never be reached"},
+	} {
+		cfg := defaultAlertsConfig(t)
+		testCase.modify(cfg)
+		_, err := cfg.buildRuleGroups()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), testCase.err)
+		}
+	}
+
+	ac := &alertsCommand{labels: []string{"team"}}
+	_, err := ac.alertsConfig()
+	assert.EqualError(t, err, `invalid label "team": expected format <key>=<value>`)
+}
+
+func TestMarshal(t *testing.T) {
+	groups := []ruleGroup{{Name: "test", Rules: []rule{{Record: "r", Expr: "1"}}}}
+	ac := &alertsCommand{format: formatPrometheusRule, name: "nwpd", namespace: "monitoring"}
+	data, err := ac.marshal(groups)
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: monitoring.coreos.com/v1
+kind: PrometheusRule
+metadata:
+  labels:
+    k8s-app: network-problem-detector
+  name: nwpd
+  namespace: monitoring
+spec:
+  groups:
+  - name: test
+    rules:
+    - expr: "1"
+      record: r
+`, string(data))
+
+	ac.format = formatPlain
+	data, err = ac.marshal(groups)
+	assert.NoError(t, err)
+	assert.Equal(t, `groups:
+- name: test
+  rules:
+  - expr: "1"
+    record: r
+`, string(data))
+
+	ac.format = "json"
+	_, err = ac.marshal(groups)
+	assert.Error(t, err)
+}
@@ -0,0 +1,164 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package generate
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+)
+
+const (
+	// formatPrometheusRule is the output format for the custom resource PrometheusRule of the Prometheus operator
+	formatPrometheusRule = "prometheusrule"
+	// formatPlain is the output format for a plain Prometheus rules file
+	formatPlain = "plain"
+)
+
+type alertsCommand struct {
+	output     string
+	format     string
+	name       string
+	namespace  string
+	objective  float64
+	jobRegex   string
+	labels     []string
+	runbookURL string
+
+	fastBurnRate    float64
+	fastLongWindow  time.Duration
+	fastShortWindow time.Duration
+	fastFor         time.Duration
+	fastSeverity    string
+	slowBurnRate    float64
+	slowLongWindow  time.Duration
+	slowShortWindow time.Duration
+	slowFor         time.Duration
+	slowSeverity    string
+}
+
+func CreateGenerateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "generate configuration for monitoring the network problem detector",
+	}
+	cmd.AddCommand((&alertsCommand{}).command())
+	return cmd
+}
+
+func (ac *alertsCommand) command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "alerts",
+		Short: "generate multi-window burn rate alerts on the failure ratio per job and zone pair",
+		Long: `generates recording rules for the ratio of failed observations per job and zone pair and multi-window burn rate alerts on them.
+The zones are joined from the metric ` + common.MetricNodeZoneInfo + ` exported by the agents.
+The output is a PrometheusRule for the Prometheus operator or a plain Prometheus rules file.`,
+		RunE: ac.generate,
+	}
+	cmd.Flags().StringVarP(&ac.output, "output", "o", "", "output file (default stdout)")
+	cmd.Flags().StringVar(&ac.format, "format", formatPrometheusRule, "output format: 'prometheusrule' or 'plain'")
+	cmd.Flags().StringVar(&ac.name, "name", common.ApplicationName, "name of the PrometheusRule")
+	cmd.Flags().StringVar(&ac.namespace, "namespace", "", "optional namespace of the PrometheusRule")
+	cmd.Flags().Float64Var(&ac.objective, "objective", 0.99, "target ratio of successful observations, the error budget is 1 - objective")
+	cmd.Flags().StringVar(&ac.jobRegex, "jobs", "", "optional regex restricting the job IDs")
+	cmd.Flags().StringSliceVar(&ac.labels, "label", nil, "additional labels of the alerts in format <key>=<value>")
+	cmd.Flags().StringVar(&ac.runbookURL, "runbook-url", "", "optional runbook URL added as annotation to the alerts")
+	cmd.Flags().Float64Var(&ac.fastBurnRate, "fast-burn-rate", 14.4, "burn rate of the fast burn alert (14.4 consumes 2% of a 30 day budget in 1h)")
+	cmd.Flags().DurationVar(&ac.fastLongWindow, "fast-long-window", 1*time.Hour, "long window of the fast burn alert")
+	cmd.Flags().DurationVar(&ac.fastShortWindow, "fast-short-window", 5*time.Minute, "short window of the fast burn alert")
+	cmd.Flags().DurationVar(&ac.fastFor, "fast-for", 2*time.Minute, "pending duration of the fast burn alert")
+	cmd.Flags().StringVar(&ac.fastSeverity, "fast-severity", "critical", "severity label of the fast burn alert")
+	cmd.Flags().Float64Var(&ac.slowBurnRate, "slow-burn-rate", 6, "burn rate of the slow burn alert (6 consumes 5% of a 30 day budget in 6h)")
+	cmd.Flags().DurationVar(&ac.slowLongWindow, "slow-long-window", 6*time.Hour, "long window of the slow burn alert")
+	cmd.Flags().DurationVar(&ac.slowShortWindow, "slow-short-window", 30*time.Minute, "short window of the slow burn alert")
+	cmd.Flags().DurationVar(&ac.slowFor, "slow-for", 15*time.Minute, "pending duration of the slow burn alert")
+	cmd.Flags().StringVar(&ac.slowSeverity, "slow-severity", "warning", "severity label of the slow burn alert")
+	return cmd
+}
+
+func (ac *alertsCommand) alertsConfig() (*alertsConfig, error) {
+	labels := map[string]string{}
+	for _, label := range ac.labels {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid label %q: expected format <key>=<value>", label)
+		}
+		labels[parts[0]] = parts[1]
+	}
+	return &alertsConfig{
+		objective: ac.objective,
+		alerts: []burnRateAlert{
+			{
+				name:        "NetworkProblemDetectorFastBurn",
+				severity:    ac.fastSeverity,
+				burnRate:    ac.fastBurnRate,
+				longWindow:  ac.fastLongWindow,
+				shortWindow: ac.fastShortWindow,
+				forDuration: ac.fastFor,
+			},
+			{
+				name:        "NetworkProblemDetectorSlowBurn",
+				severity:    ac.slowSeverity,
+				burnRate:    ac.slowBurnRate,
+				longWindow:  ac.slowLongWindow,
+				shortWindow: ac.slowShortWindow,
+				forDuration: ac.slowFor,
+			},
+		},
+		jobRegex:   ac.jobRegex,
+		labels:     labels,
+		runbookURL: ac.runbookURL,
+	}, nil
+}
+
+func (ac *alertsCommand) generate(_ *cobra.Command, _ []string) error {
+	cfg, err := ac.alertsConfig()
+	if err != nil {
+		return err
+	}
+	groups, err := cfg.buildRuleGroups()
+	if err != nil {
+		return err
+	}
+	data, err := ac.marshal(groups)
+	if err != nil {
+		return err
+	}
+	if ac.output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(ac.output, data, 0644)
+}
+
+func (ac *alertsCommand) marshal(groups []ruleGroup) ([]byte, error) {
+	spec := map[string]interface{}{"groups": groups}
+	switch ac.format {
+	case formatPlain:
+		return yaml.Marshal(spec)
+	case formatPrometheusRule:
+		metadata := map[string]interface{}{
+			"name":   ac.name,
+			"labels": map[string]string{common.LabelKeyK8sApp: common.ApplicationName},
+		}
+		if ac.namespace != "" {
+			metadata["namespace"] = ac.namespace
+		}
+		return yaml.Marshal(map[string]interface{}{
+			"apiVersion": "monitoring.coreos.com/v1",
+			"kind":       "PrometheusRule",
+			"metadata":   metadata,
+			"spec":       spec,
+		})
+	default:
+		return nil, fmt.Errorf("invalid format %q: must be %q or %q", ac.format, formatPrometheusRule, formatPlain)
+	}
+}
@@ -11,11 +11,13 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
 	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
 
 	"github.com/sirupsen/logrus"
@@ -23,12 +25,13 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
-	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
 )
 
 type listCommand struct {
-	kubeconfig string
+	common.ClientsetBase
 	targetPort int
+	socket     string
 	since      time.Duration
 	limit      int
 	jobIDs     []string
@@ -36,8 +39,14 @@ type listCommand struct {
 	destHosts  []string
 	failedOnly bool
 	window     time.Duration
+}
 
-	clientset *kubernetes.Clientset
+// agentTarget defines how to reach the GRPC server of an agent pod.
+type agentTarget struct {
+	// port is the pod port used with 'kubectl port-forward'
+	port int
+	// socket is the admin socket in the pod used with 'kubectl exec' if the admin listener has no TCP port
+	socket string
 }
 
 func CreateListCmd() *cobra.Command {
@@ -45,11 +54,14 @@ func CreateListCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "list (observation|obs|aggregated|aggr) <podname>",
 		Short: "collect observations or aggregations from an agent",
-		Long:  `collect observations from an agent using 'kubectl port-forward' and GRPC'`,
-		RunE:  lc.list,
+		Long: `collect observations from an agent using 'kubectl port-forward' and GRPC.
+If the agent serves the GRPC API on an admin listener, the admin port is used. If the admin listener is only a unix socket,
+the command is run in the agent pod with 'kubectl exec'.`,
+		RunE: lc.list,
 	}
-	cmd.Flags().StringVar(&lc.kubeconfig, "kubeconfig", "", "kubeconfig for shoot cluster, uses KUBECONFIG if not specified.")
-	cmd.Flags().IntVar(&lc.targetPort, "targetPort", 0, "target pod port")
+	lc.AddKubeConfigFlag(cmd.Flags())
+	cmd.Flags().IntVar(&lc.targetPort, "targetPort", 0, "target pod port (default from the agent configuration)")
+	cmd.Flags().StringVar(&lc.socket, "socket", "", "unix socket of the agent GRPC server to query directly, e.g. inside the agent pod")
 	cmd.Flags().DurationVar(&lc.since, "since", 10*time.Minute, "list observations since given time period.")
 	cmd.Flags().IntVar(&lc.limit, "limit", 10000, "maximum number of observations to retrieve.")
 	cmd.Flags().StringArrayVar(&lc.jobIDs, "job", nil, "jobID(s) to filter")
@@ -63,7 +75,7 @@ func CreateListCmd() *cobra.Command {
 func (lc *listCommand) list(ccmd *cobra.Command, args []string) error {
 	log := logrus.WithField("cmd", "list")
 
-	if len(args) != 2 {
+	if len(args) != 2 && (lc.socket == "" || len(args) != 1) {
 		return fmt.Errorf("Missing kind or pod name: %s", strings.Join(args, " "))
 	}
 
@@ -81,27 +93,50 @@ func (lc *listCommand) list(ccmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Invalid kind: %s (allowed 'observation', 'obs', 'aggregated', 'aggr')", args[0])
 	}
 
-	podname := args[1]
-	port := 18007
-	for !lc.checkPortAvailable(port) {
-		port++
+	if lc.socket != "" {
+		cc, err := grpc.Dial("unix://"+lc.socket, grpc.WithInsecure())
+		if err != nil {
+			return err
+		}
+		defer cc.Close()
+		return lc.query(log, nwpd.NewAgentServiceClient(cc), aggr)
 	}
-	targetPort := lc.targetPort
-	if targetPort == 0 {
-		if strings.HasPrefix(podname, common.NameDaemonSetAgentHostNet) {
-			targetPort = common.HostNetPodGRPCPort
-		} else {
-			targetPort = common.PodNetPodGRPCPort
+
+	podname := args[1]
+	target := agentTarget{port: lc.targetPort}
+	if target.port == 0 {
+		agentConfig, err := lc.loadAgentConfig()
+		if err != nil {
+			log.Warnf("loading agent config failed, using default port: %s", err)
 		}
+		target = resolveTarget(podname, agentConfig)
 	}
 
 	kubeconfigOpt := ""
-	if lc.kubeconfig != "" {
-		kubeconfigOpt = " --kubeconfig=" + lc.kubeconfig
+	if lc.Kubeconfig != "" {
+		kubeconfigOpt = " --kubeconfig=" + lc.Kubeconfig
+	}
+
+	if target.socket != "" {
+		log.Infof("Loading observations from pod %s via admin socket %s", podname, target.socket)
+		kubectlArgs := []string{"-n", common.NamespaceKubeSystem, "exec", podname, "--", "/nwpdcli"}
+		if lc.Kubeconfig != "" {
+			kubectlArgs = append([]string{"--kubeconfig=" + lc.Kubeconfig}, kubectlArgs...)
+		}
+		cmd := exec.Command("kubectl", append(kubectlArgs, lc.execArgs(args[0], target.socket)...)...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Env = os.Environ()
+		return cmd.Run()
+	}
+
+	port := 18007
+	for !lc.checkPortAvailable(port) {
+		port++
 	}
 
 	log.Infof("Loading observations from pod %s", podname)
-	cmdline := fmt.Sprintf("kubectl %s -n kube-system  port-forward %s %d:%d", kubeconfigOpt, podname, port, targetPort)
+	cmdline := fmt.Sprintf("kubectl %s -n kube-system  port-forward %s %d:%d", kubeconfigOpt, podname, port, target.port)
 	var stderr bytes.Buffer
 	cmd := exec.Command("sh", "-c", cmdline)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} //create process group for child processes
@@ -119,8 +154,83 @@ func (lc *listCommand) list(ccmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	defer cc.Close()
 
-	client := nwpd.NewAgentServiceClient(cc)
+	for i := 0; i < 20; i++ {
+		if !lc.checkPortAvailable(port) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return lc.query(log, nwpd.NewAgentServiceClient(cc), aggr)
+}
+
+// loadAgentConfig loads the agent configuration from the config map.
+func (lc *listCommand) loadAgentConfig() (*config.AgentConfig, error) {
+	if err := lc.SetupClientSet(); err != nil {
+		return nil, err
+	}
+	cm, err := common.LoadAgentConfigMap(context.Background(), lc.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem))
+	if err != nil {
+		return nil, err
+	}
+	agentConfig := &config.AgentConfig{}
+	if err := yaml.Unmarshal([]byte(cm.Data[common.AgentConfigFilename]), agentConfig); err != nil {
+		return nil, err
+	}
+	return agentConfig, nil
+}
+
+// resolveTarget determines how to reach the GRPC server of the agent pod from the agent configuration.
+// The admin listener is preferred as the cluster listener only serves the aggregated observations.
+func resolveTarget(podname string, agentConfig *config.AgentConfig) agentTarget {
+	hostNetwork := strings.HasPrefix(podname, common.NameDaemonSetAgentHostNet)
+	target := agentTarget{port: common.PodNetPodGRPCPort}
+	if hostNetwork {
+		target.port = common.HostNetPodGRPCPort
+	}
+	if agentConfig == nil {
+		return target
+	}
+	networkCfg := agentConfig.PodNetwork
+	if hostNetwork {
+		networkCfg = agentConfig.HostNetwork
+	}
+	if networkCfg == nil {
+		return target
+	}
+	switch {
+	case networkCfg.AdminGRPCPort != 0:
+		target.port = networkCfg.AdminGRPCPort
+	case networkCfg.AdminSocket:
+		target.socket = networkCfg.AdminSocketPath(agentConfig.OutputDir)
+	case networkCfg.GRPCPort != 0:
+		target.port = networkCfg.GRPCPort
+	}
+	return target
+}
+
+// execArgs returns the arguments of the list command to run in the agent pod on the admin socket.
+func (lc *listCommand) execArgs(kind, socket string) []string {
+	args := []string{"list", kind, "--socket", socket, "--since", lc.since.String(), "--limit", strconv.Itoa(lc.limit),
+		"--window", lc.window.String()}
+	for _, jobID := range lc.jobIDs {
+		args = append(args, "--job", jobID)
+	}
+	for _, src := range lc.srcHosts {
+		args = append(args, "--src", src)
+	}
+	for _, dest := range lc.destHosts {
+		args = append(args, "--dest", dest)
+	}
+	if lc.failedOnly {
+		args = append(args, "--failed-only")
+	}
+	return args
+}
+
+func (lc *listCommand) query(log logrus.FieldLogger, client nwpd.AgentServiceClient, aggr bool) error {
 	request := &nwpd.GetObservationsRequest{
 		Start:               timestamppb.New(time.Now().Add(-lc.since)),
 		Limit:               int32(lc.limit),
@@ -131,13 +241,6 @@ func (lc *listCommand) list(ccmd *cobra.Command, args []string) error {
 		AggregationWindow:   durationpb.New(lc.window),
 	}
 
-	for i := 0; i < 20; i++ {
-		if !lc.checkPortAvailable(port) {
-			break
-		}
-		time.Sleep(100 * time.Millisecond)
-	}
-
 	if aggr {
 		return lc.listAggregatedObservations(log, client, request)
 	} else {
@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package list
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+)
+
+func TestResolveTarget(t *testing.T) {
+	hostPod := common.NameDaemonSetAgentHostNet + "-abcde"
+	podPod := common.NameDaemonSetAgentPodNet + "-abcde"
+
+	assert.Equal(t, agentTarget{port: common.HostNetPodGRPCPort}, resolveTarget(hostPod, nil))
+	assert.Equal(t, agentTarget{port: common.PodNetPodGRPCPort}, resolveTarget(podPod, nil))
+
+	agentConfig := &config.AgentConfig{
+		OutputDir:   "/var/log/nwpd/records",
+		HostNetwork: &config.NetworkConfig{GRPCPort: 1234, AdminGRPCPort: 1235, AdminSocket: true},
+		PodNetwork:  &config.NetworkConfig{DataFilePrefix: "nwpd-agent-pod", AdminSocket: true},
+	}
+	assert.Equal(t, agentTarget{port: 1235}, resolveTarget(hostPod, agentConfig))
+	assert.Equal(t, agentTarget{port: common.PodNetPodGRPCPort, socket: "/var/log/nwpd/records/admin-nwpd-agent-pod.sock"},
+		resolveTarget(podPod, agentConfig))
+
+	agentConfig.HostNetwork = &config.NetworkConfig{GRPCPort: 1234}
+	assert.Equal(t, agentTarget{port: 1234}, resolveTarget(hostPod, agentConfig))
+}
+
+func TestExecArgs(t *testing.T) {
+	lc := &listCommand{since: 5 * time.Minute, limit: 100, window: time.Minute, jobIDs: []string{"tcp-n2n"}, failedOnly: true}
+	assert.Equal(t, []string{"list", "aggr", "--socket", "/tmp/admin.sock", "--since", "5m0s", "--limit", "100", "--window", "1m0s",
+		"--job", "tcp-n2n", "--failed-only"}, lc.execArgs("aggr", "/tmp/admin.sock"))
+}
@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/analysis"
+	"github.com/gardener/network-problem-detector/pkg/common"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	outputASCII = "ascii"
+	outputDot   = "dot"
+)
+
+type matrixCommand struct {
+	common.ClientsetBase
+	port      int
+	url       string
+	file      string
+	output    string
+	jobFilter string
+	all       bool
+	timeout   time.Duration
+}
+
+func CreateMatrixCmd() *cobra.Command {
+	mc := &matrixCommand{}
+	cmd := &cobra.Command{
+		Use:   "matrix",
+		Short: "show the node x node health of the edges polled by the controller",
+		Long: `fetches the edge health of the last polling cycle from the controller (requires 'run-controller --poll-period' and '--http-port')
+and renders it as ASCII grid with a row per source and a column per destination, or as graphviz digraph ('--output dot').
+The controller pod is reached via the API server proxy, or directly with '--url', e.g. after 'kubectl port-forward'.`,
+		RunE: mc.matrix,
+	}
+	mc.AddKubeConfigFlag(cmd.Flags())
+	cmd.Flags().IntVar(&mc.port, "port", 0, "http port of the controller (value of its option --http-port).")
+	cmd.Flags().StringVar(&mc.url, "url", "", "base URL of the controller http server, e.g. 'http://localhost:8080' (instead of the API server proxy).")
+	cmd.Flags().StringVar(&mc.file, "file", "", "file with the JSON edge matrix of the controller to render instead of fetching it ('-' for stdin).")
+	cmd.Flags().StringVar(&mc.output, "output", outputASCII, "output format ('ascii' or 'dot').")
+	cmd.Flags().StringVar(&mc.jobFilter, "job", "", "filter edges by job id (use '*' for globbing)")
+	cmd.Flags().BoolVar(&mc.all, "all", false, "if true, ok edges are included in the dot output, too.")
+	cmd.Flags().DurationVar(&mc.timeout, "timeout", 10*time.Second, "timeout for fetching the edge matrix.")
+	return cmd
+}
+
+func (mc *matrixCommand) matrix(_ *cobra.Command, _ []string) error {
+	if mc.output != outputASCII && mc.output != outputDot {
+		return fmt.Errorf("invalid output format %q: must be '%s' or '%s'", mc.output, outputASCII, outputDot)
+	}
+	jobFilter, err := analysis.GlobMatcher(mc.jobFilter)
+	if err != nil {
+		return err
+	}
+	data, err := mc.fetch()
+	if err != nil {
+		return err
+	}
+	matrix := &common.EdgeMatrix{}
+	if err := json.Unmarshal(data, matrix); err != nil {
+		return fmt.Errorf("parsing edge matrix failed: %w", err)
+	}
+	g := newGrid(matrix, jobFilter)
+	if mc.output == outputDot {
+		g.printDot(os.Stdout, mc.all)
+	} else {
+		g.printASCII(os.Stdout, matrix)
+	}
+	return nil
+}
+
+// fetch reads the edge matrix from the file, the URL or the controller pod.
+func (mc *matrixCommand) fetch() ([]byte, error) {
+	switch {
+	case mc.file == "-":
+		return io.ReadAll(os.Stdin)
+	case mc.file != "":
+		return os.ReadFile(mc.file)
+	case mc.url != "":
+		client := &http.Client{Timeout: mc.timeout}
+		resp, err := client.Get(strings.TrimSuffix(mc.url, "/") + "/edges.json")
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching edge matrix failed with status %s: %s", resp.Status, strings.TrimSpace(string(data)))
+		}
+		return data, nil
+	}
+	if mc.port == 0 {
+		return nil, fmt.Errorf("missing option --port, --url or --file")
+	}
+	if err := mc.SetupClientSet(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mc.timeout)
+	defer cancel()
+	pods, err := mc.Clientset.CoreV1().Pods(common.NamespaceKubeSystem).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", common.LabelKeyK8sApp, common.NameDeploymentAgentController),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		return mc.Clientset.CoreV1().Pods(pod.Namespace).ProxyGet("http", pod.Name, strconv.Itoa(mc.port), "/edges.json", nil).DoRaw(ctx)
+	}
+	return nil, fmt.Errorf("no running controller pod found")
+}
@@ -0,0 +1,246 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package matrix
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/analysis"
+	"github.com/gardener/network-problem-detector/pkg/common"
+)
+
+// cellState is the health of all checks from a source to a destination.
+type cellState int
+
+const (
+	stateNone cellState = iota
+	stateOk
+	stateDegraded
+	stateFailed
+)
+
+// symbols are the characters of the cell states in the ASCII grid.
+var symbols = map[cellState]string{
+	stateNone:     " ",
+	stateOk:       ".",
+	stateDegraded: "~",
+	stateFailed:   "X",
+}
+
+// colors are the graphviz colors of the cell states.
+var colors = map[cellState]string{
+	stateOk:       "gray",
+	stateDegraded: "orange",
+	stateFailed:   "red",
+}
+
+type hostPair struct {
+	src  string
+	dest string
+}
+
+// cell combines the checks of all jobs of a source and a destination.
+type cell struct {
+	state  cellState
+	ok     int
+	failed int
+	jobs   []string
+}
+
+// grid is the node x node health matrix of the edges.
+type grid struct {
+	sources      []string
+	destinations []string
+	cells        map[hostPair]*cell
+}
+
+// newGrid builds the grid from the edges of the jobs matching the filter (nil for all jobs).
+// The cell of a source and a destination is failed if all checks of all jobs failed, degraded if any check failed
+// and ok if no check failed.
+func newGrid(matrix *common.EdgeMatrix, jobFilter analysis.Matcher) *grid {
+	g := &grid{cells: map[hostPair]*cell{}}
+	sources, destinations := map[string]bool{}, map[string]bool{}
+	for _, edge := range matrix.Edges {
+		if jobFilter != nil && !jobFilter(edge.JobID) {
+			continue
+		}
+		if edge.OkCount+edge.FailedCount == 0 {
+			continue
+		}
+		sources[edge.SrcHost] = true
+		destinations[edge.DestHost] = true
+		key := hostPair{src: edge.SrcHost, dest: edge.DestHost}
+		c := g.cells[key]
+		if c == nil {
+			c = &cell{}
+			g.cells[key] = c
+		}
+		c.ok += edge.OkCount
+		c.failed += edge.FailedCount
+		if edge.FailedCount > 0 {
+			c.jobs = append(c.jobs, edge.JobID)
+		}
+	}
+	for _, c := range g.cells {
+		switch {
+		case c.failed == 0:
+			c.state = stateOk
+		case c.ok == 0:
+			c.state = stateFailed
+		default:
+			c.state = stateDegraded
+		}
+		sort.Strings(c.jobs)
+	}
+	g.sources = sortedKeys(sources)
+	g.destinations = sortedKeys(destinations)
+	return g
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (g *grid) state(src, dest string) cellState {
+	if c := g.cells[hostPair{src: src, dest: dest}]; c != nil {
+		return c.state
+	}
+	return stateNone
+}
+
+// count returns the number of cells with the state.
+func (g *grid) count(state cellState) int {
+	n := 0
+	for _, c := range g.cells {
+		if c.state == state {
+			n++
+		}
+	}
+	return n
+}
+
+// patterns describes the hosts failing as source or destination of all their edges, so that a single bad node
+// can be distinguished from a total partition.
+func (g *grid) patterns() []string {
+	failed := g.count(stateFailed)
+	if failed == 0 {
+		return nil
+	}
+	if failed == len(g.cells) {
+		return []string{fmt.Sprintf("all %d edges failing", failed)}
+	}
+	var result []string
+	for _, dest := range g.destinations {
+		total, failing := 0, 0
+		for _, src := range g.sources {
+			if s := g.state(src, dest); s != stateNone {
+				total++
+				if s == stateFailed {
+					failing++
+				}
+			}
+		}
+		if total > 1 && failing == total {
+			result = append(result, fmt.Sprintf("destination %s unreachable from all %d sources", dest, total))
+		}
+	}
+	for _, src := range g.sources {
+		total, failing := 0, 0
+		for _, dest := range g.destinations {
+			if s := g.state(src, dest); s != stateNone {
+				total++
+				if s == stateFailed {
+					failing++
+				}
+			}
+		}
+		if total > 1 && failing == total {
+			result = append(result, fmt.Sprintf("source %s cannot reach any of %d destinations", src, total))
+		}
+	}
+	return result
+}
+
+// printASCII prints the grid with a row per source and a numbered column per destination.
+func (g *grid) printASCII(out io.Writer, matrix *common.EdgeMatrix) {
+	fmt.Fprintf(out, "edge health of %d polled agents at %s (window %s)\n", matrix.PolledAgents, matrix.Timestamp.Format(time.RFC3339), matrix.Window)
+	if len(g.cells) == 0 {
+		fmt.Fprintln(out, "no edges")
+		return
+	}
+	width := len(strconv.Itoa(len(g.destinations)))
+	nameWidth := 0
+	for _, src := range g.sources {
+		if len(src) > nameWidth {
+			nameWidth = len(src)
+		}
+	}
+	// column numbers printed vertically, one line per digit
+	for digit := 0; digit < width; digit++ {
+		line := strings.Repeat(" ", nameWidth+1)
+		for i := range g.destinations {
+			number := fmt.Sprintf("%*d", width, i+1)
+			line += number[digit:digit+1] + " "
+		}
+		fmt.Fprintln(out, strings.TrimRight(line, " "))
+	}
+	for _, src := range g.sources {
+		line := fmt.Sprintf("%-*s ", nameWidth, src)
+		for _, dest := range g.destinations {
+			line += symbols[g.state(src, dest)] + " "
+		}
+		fmt.Fprintln(out, strings.TrimRight(line, " "))
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "destinations:")
+	for i, dest := range g.destinations {
+		fmt.Fprintf(out, "  %*d %s\n", width, i+1, dest)
+	}
+	fmt.Fprintf(out, "\nlegend: %s ok, %s degraded, %s failed, blank no checks\n", symbols[stateOk], symbols[stateDegraded], symbols[stateFailed])
+	fmt.Fprintf(out, "%d edges: %d ok, %d degraded, %d failed\n", len(g.cells), g.count(stateOk), g.count(stateDegraded), g.count(stateFailed))
+	for _, p := range g.patterns() {
+		fmt.Fprintln(out, p)
+	}
+}
+
+// printDot prints the grid as graphviz digraph. Ok edges are only included if all is set.
+func (g *grid) printDot(out io.Writer, all bool) {
+	fmt.Fprintln(out, "digraph nwpd {")
+	fmt.Fprintln(out, "  node [shape=box];")
+	hosts := map[string]bool{}
+	for _, src := range g.sources {
+		hosts[src] = true
+	}
+	for _, dest := range g.destinations {
+		hosts[dest] = true
+	}
+	for _, host := range sortedKeys(hosts) {
+		fmt.Fprintf(out, "  %s;\n", strconv.Quote(host))
+	}
+	for _, src := range g.sources {
+		for _, dest := range g.destinations {
+			c := g.cells[hostPair{src: src, dest: dest}]
+			if c == nil || (c.state == stateOk && !all) {
+				continue
+			}
+			label := fmt.Sprintf("%d/%d failed", c.failed, c.ok+c.failed)
+			if len(c.jobs) > 0 {
+				label += " (" + strings.Join(c.jobs, ",") + ")"
+			}
+			fmt.Fprintf(out, "  %s -> %s [color=%s, label=%s];\n", strconv.Quote(src), strconv.Quote(dest), colors[c.state], strconv.Quote(label))
+		}
+	}
+	fmt.Fprintln(out, "}")
+}
@@ -0,0 +1,98 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package matrix
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gardener/network-problem-detector/pkg/analysis"
+	"github.com/gardener/network-problem-detector/pkg/common"
+)
+
+func testMatrix() *common.EdgeMatrix {
+	return &common.EdgeMatrix{
+		Timestamp:    time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC),
+		Window:       "1m0s",
+		PolledAgents: 3,
+		Edges: []common.EdgeHealth{
+			{JobID: "ping-n2n", SrcHost: "node-a", DestHost: "node-b", OkCount: 2},
+			{JobID: "ping-n2n", SrcHost: "node-a", DestHost: "node-c", FailedCount: 2},
+			{JobID: "ping-n2n", SrcHost: "node-b", DestHost: "node-a", OkCount: 2},
+			{JobID: "ping-n2n", SrcHost: "node-b", DestHost: "node-c", FailedCount: 2},
+			{JobID: "tcp-n2n", SrcHost: "node-a", DestHost: "node-b", OkCount: 1, FailedCount: 1},
+			{JobID: "tcp-n2n", SrcHost: "node-b", DestHost: "node-a", OkCount: 2},
+			{JobID: "tcp-n2n", SrcHost: "node-c", DestHost: "node-a", OkCount: 2},
+		},
+	}
+}
+
+func TestGrid(t *testing.T) {
+	g := newGrid(testMatrix(), nil)
+	assert.Equal(t, []string{"node-a", "node-b", "node-c"}, g.sources)
+	assert.Equal(t, []string{"node-a", "node-b", "node-c"}, g.destinations)
+	assert.Equal(t, stateDegraded, g.state("node-a", "node-b"))
+	assert.Equal(t, stateFailed, g.state("node-a", "node-c"))
+	assert.Equal(t, stateOk, g.state("node-c", "node-a"))
+	assert.Equal(t, stateNone, g.state("node-c", "node-b"))
+	assert.Equal(t, []string{"destination node-c unreachable from all 2 sources"}, g.patterns())
+
+	filter, err := analysis.GlobMatcher("tcp-*")
+	assert.NoError(t, err)
+	g = newGrid(testMatrix(), filter)
+	assert.Equal(t, stateDegraded, g.state("node-a", "node-b"))
+	assert.Equal(t, []string{"node-a", "node-b"}, g.destinations)
+	assert.Empty(t, g.patterns())
+}
+
+func TestPrintASCII(t *testing.T) {
+	buf := &bytes.Buffer{}
+	newGrid(testMatrix(), nil).printASCII(buf, testMatrix())
+	assert.Equal(t, `edge health of 3 polled agents at 2022-08-01T10:00:00Z (window 1m0s)
+       1 2 3
+node-a   ~ X
+node-b .   X
+node-c .
+
+destinations:
+  1 node-a
+  2 node-b
+  3 node-c
+
+legend: . ok, ~ degraded, X failed, blank no checks
+5 edges: 2 ok, 1 degraded, 2 failed
+destination node-c unreachable from all 2 sources
+`, buf.String())
+}
+
+func TestPrintDot(t *testing.T) {
+	buf := &bytes.Buffer{}
+	newGrid(testMatrix(), nil).printDot(buf, false)
+	assert.Equal(t, `digraph nwpd {
+  node [shape=box];
+  "node-a";
+  "node-b";
+  "node-c";
+  "node-a" -> "node-b" [color=orange, label="1/4 failed (tcp-n2n)"];
+  "node-a" -> "node-c" [color=red, label="2/2 failed (ping-n2n)"];
+  "node-b" -> "node-c" [color=red, label="2/2 failed (ping-n2n)"];
+}
+`, buf.String())
+
+	buf.Reset()
+	newGrid(testMatrix(), nil).printDot(buf, true)
+	assert.Contains(t, buf.String(), `"node-c" -> "node-a" [color=gray, label="0/2 failed"];`)
+}
+
+func TestPatternsPartition(t *testing.T) {
+	matrix := &common.EdgeMatrix{Edges: []common.EdgeHealth{
+		{JobID: "tcp-n2n", SrcHost: "node-a", DestHost: "node-b", FailedCount: 1},
+		{JobID: "tcp-n2n", SrcHost: "node-b", DestHost: "node-a", FailedCount: 1},
+	}}
+	assert.Equal(t, []string{"all 2 edges failing"}, newGrid(matrix, nil).patterns())
+}
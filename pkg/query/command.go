@@ -6,10 +6,14 @@ package query
 
 import (
 	"fmt"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/gardener/network-problem-detector/pkg/agent/db"
+	"github.com/gardener/network-problem-detector/pkg/analysis"
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
 	"github.com/gardener/network-problem-detector/pkg/common/nwpd"
 
 	"github.com/spf13/cobra"
@@ -20,9 +24,19 @@ type queryCommand struct {
 	src        string
 	dest       string
 	jobID      string
+	protocol   string
+	port       int
 	minutes    int
 	failedOnly bool
 	exactMatch bool
+
+	withClusterEvents bool
+	withNetStats      bool
+
+	coverage            bool
+	clusterConfigFile   string
+	staleAfter          time.Duration
+	requireFullCoverage bool
 }
 
 func CreateQueryCmd() *cobra.Command {
@@ -37,68 +51,289 @@ func CreateQueryCmd() *cobra.Command {
 	cmd.Flags().StringVar(&qc.src, "src", "", "filter by source.")
 	cmd.Flags().StringVar(&qc.dest, "dest", "", "filter by dest.")
 	cmd.Flags().StringVar(&qc.jobID, "job", "", "filter by job ID.")
+	cmd.Flags().StringVar(&qc.protocol, "protocol", "", "filter by protocol (tcp, udp, icmp or https).")
+	cmd.Flags().IntVar(&qc.port, "port", 0, "filter by destination port.")
 	cmd.Flags().BoolVar(&qc.failedOnly, "failed-only", false, "if only failed checks should be printed.")
 	cmd.Flags().BoolVar(&qc.exactMatch, "match-exact", false, "if filter expressions must match full names.")
 	cmd.Flags().IntVar(&qc.minutes, "minutes", 0, "restrict to given last minutes.")
+	cmd.Flags().BoolVar(&qc.withClusterEvents, "with-cluster-events", false, "if node lifecycle events collected from the controller should be merged into the output ordered by time.")
+	cmd.Flags().BoolVar(&qc.withNetStats, "with-net-stats", false, "if the samples of the kernel network counters of the source node (jobs with 'collectNetStats') covering the time of a failed check should be added to it.")
+	cmd.Flags().BoolVar(&qc.coverage, "coverage", false, "if a coverage summary should be printed instead of the observations, showing for each known node if there are observations from the agents of both networks.")
+	cmd.Flags().StringVar(&qc.clusterConfigFile, "cluster-config", "", "cluster config file with the known nodes for the coverage summary (defaults to the file collected with 'nwpdcli collect' in the input directory).")
+	cmd.Flags().DurationVar(&qc.staleAfter, "stale-after", 5*time.Minute, "an agent is stale in the coverage summary if its last observation is older than this duration compared to the newest observation.")
+	cmd.Flags().BoolVar(&qc.requireFullCoverage, "require-full-coverage", false, "if the coverage summary should fail if any node has missing or stale observations.")
 
 	return cmd
 }
 
 func (qc *queryCommand) query(cmd *cobra.Command, args []string) error {
-	filenames, err := db.GetAnyRecordFiles(qc.directory, true)
+	source, err := analysis.OpenDir(qc.directory)
 	if err != nil {
 		return err
 	}
 
-	var endMillis int64 = time.Now().UnixMilli()
-	var startMillis int64 = 0
+	match := analysis.SubstringMatcher
+	if qc.exactMatch {
+		match = analysis.ExactMatcher
+	}
+	filter := analysis.Filter{
+		End:          time.Now(),
+		JobID:        match(qc.jobID),
+		SrcHost:      match(qc.src),
+		DestHost:     match(qc.dest),
+		Protocol:     analysis.ExactMatcher(qc.protocol),
+		DestPort:     int32(qc.port),
+		FailuresOnly: qc.failedOnly,
+	}
 	if qc.minutes > 0 {
-		startMillis = endMillis - int64(qc.minutes*60000)
+		filter.Start = filter.End.Add(-time.Duration(qc.minutes) * time.Minute)
 	}
-	count := 0
-	for _, filename := range filenames {
-		db.IterateRecordFile(filename, func(obs *nwpd.Observation) error {
-			timeMillis := obs.Timestamp.AsTime().UnixMilli()
-
-			if timeMillis < startMillis || timeMillis > endMillis {
-				return nil
+	if qc.coverage {
+		return qc.printCoverage(cmd, source, filter)
+	}
+	var events []config.NodeEvent
+	if qc.withClusterEvents {
+		events, err = config.LoadNodeEvents(filepath.Join(qc.directory, common.ClusterEventsFilename))
+		if err != nil {
+			return fmt.Errorf("loading cluster events failed (collected with 'nwpdcli collect'): %w", err)
+		}
+	}
+	format := formatObservation
+	if qc.withNetStats {
+		netStats, err := analysis.LoadNetStats(source, filter)
+		if err != nil {
+			return err
+		}
+		format = func(obs *nwpd.Observation) string {
+			if obs.Ok || obs.Protocol == nwpd.ProtocolNetStats {
+				return formatObservation(obs)
 			}
+			return formatObservationWithNetStats(obs, netStats.Covering(obs))
+		}
+	}
+	out := &jsonListPrinter{}
+	defer out.close()
+	if !qc.withClusterEvents {
+		return source.Iterate(filter, func(obs *nwpd.Observation) error {
+			out.print(format(obs))
+			return nil
+		})
+	}
 
-			if qc.failedOnly && obs.Ok {
-				return nil
-			}
-			match := strings.Contains
-			if qc.exactMatch {
-				match = func(s, t string) bool { return s == t }
-			}
-			if qc.src != "" && !match(obs.SrcHost, qc.src) {
-				return nil
-			}
-			if qc.dest != "" && !match(obs.DestHost, qc.dest) {
-				return nil
-			}
-			if qc.jobID != "" && !match(obs.JobID, qc.jobID) {
-				return nil
-			}
-			if count == 0 {
-				fmt.Printf("[")
-			} else {
-				fmt.Printf(",\n")
+	var observations []*nwpd.Observation
+	if err := source.Iterate(filter, func(obs *nwpd.Observation) error {
+		observations = append(observations, obs)
+		return nil
+	}); err != nil {
+		return err
+	}
+	sort.SliceStable(observations, func(i, j int) bool {
+		return observations[i].Timestamp.AsTime().Before(observations[j].Timestamp.AsTime())
+	})
+	i := 0
+	for _, event := range events {
+		if !qc.matchesEvent(filter, event) {
+			continue
+		}
+		for ; i < len(observations) && !observations[i].Timestamp.AsTime().After(event.Time); i++ {
+			out.print(format(observations[i]))
+		}
+		out.print(formatNodeEvent(event))
+	}
+	for ; i < len(observations); i++ {
+		out.print(format(observations[i]))
+	}
+	return nil
+}
+
+// matchesEvent checks if the node event is in the time range and the node matches the source or destination filter.
+func (qc *queryCommand) matchesEvent(filter analysis.Filter, event config.NodeEvent) bool {
+	if !filter.Start.IsZero() && event.Time.Before(filter.Start) {
+		return false
+	}
+	if !filter.End.IsZero() && event.Time.After(filter.End) {
+		return false
+	}
+	if qc.src == "" && qc.dest == "" {
+		return true
+	}
+	return (qc.src != "" && filter.SrcHost(event.Node)) || (qc.dest != "" && filter.DestHost(event.Node))
+}
+
+func (qc *queryCommand) printCoverage(cmd *cobra.Command, source analysis.Source, filter analysis.Filter) error {
+	filename := qc.clusterConfigFile
+	if filename == "" {
+		filename = filepath.Join(qc.directory, common.ClusterConfigFilename)
+	}
+	clusterConfig, err := config.LoadClusterConfig(filename)
+	if err != nil {
+		return fmt.Errorf("loading cluster config failed (collected with 'nwpdcli collect'): %w", err)
+	}
+	var nodes []string
+	for _, n := range clusterConfig.Nodes {
+		if filter.SrcHost == nil || filter.SrcHost(n.Hostname) {
+			nodes = append(nodes, n.Hostname)
+		}
+	}
+	sort.Strings(nodes)
+
+	coverage, err := analysis.Coverage(source, filter, nodes, qc.staleAfter)
+	if err != nil {
+		return err
+	}
+	incomplete := 0
+	if clusterConfig.NodeSelector != "" {
+		// nodes not matching the selector are not checked and therefore not listed
+		fmt.Printf("nodes restricted to label selector %q\n", clusterConfig.NodeSelector)
+	}
+	fmt.Printf("%-40s %-8s %10s %-24s %10s %-24s\n", "NODE", "STATUS", "HOST", "HOST LAST", "POD", "POD LAST")
+	for _, nc := range coverage {
+		marker := ""
+		if nc.Status != analysis.CoverageOK {
+			incomplete++
+			marker = " <=="
+		}
+		fmt.Printf("%-40s %-8s %10d %-24s %10d %-24s%s\n", nc.Node, nc.Status,
+			nc.HostNetwork.Samples, formatLast(nc.HostNetwork.Last), nc.PodNetwork.Samples, formatLast(nc.PodNetwork.Last), marker)
+	}
+	fmt.Printf("%d of %d nodes with full coverage\n", len(coverage)-incomplete, len(coverage))
+	printNetworkInfo(coverage)
+	if qc.requireFullCoverage && incomplete > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d nodes with missing or stale observations", incomplete)
+	}
+	return nil
+}
+
+// printNetworkInfo prints the default routes of the agents recorded in the headers of their newest record files.
+func printNetworkInfo(coverage []analysis.NodeCoverage) {
+	header := false
+	for _, nc := range coverage {
+		for _, network := range []struct {
+			name string
+			info []*nwpd.NetworkInfo
+		}{{"host", nc.HostNetwork.NetworkInfo}, {"pod", nc.PodNetwork.NetworkInfo}} {
+			if len(network.info) == 0 {
+				continue
 			}
-			count++
-			t := obs.Timestamp.AsTime().UTC().Format("2006-01-02T15:04:05.000Z")
-			dur := ""
-			if obs.Duration != nil {
-				dur = fmt.Sprintf(`,"duration": "%dms"`, obs.Duration.AsDuration().Milliseconds())
+			if !header {
+				fmt.Printf("\n%-40s %-8s %s\n", "NODE", "NETWORK", "DEFAULT ROUTES")
+				header = true
 			}
-			fmt.Printf("{%q: %q, %q: %q, %q: %q, %q: %q%s, %q: %t}", "time", t, "src", obs.SrcHost, "dest", obs.DestHost, "jobID", obs.JobID, dur, "ok", obs.Ok)
-			return nil
-		})
+			fmt.Printf("%-40s %-8s %s\n", nc.Node, network.name, nwpd.FormatNetworkInfo(network.info))
+		}
+	}
+}
+
+func formatLast(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return formatTime(t)
+}
+
+type jsonListPrinter struct {
+	count int
+}
+
+func (p *jsonListPrinter) print(item string) {
+	if p.count == 0 {
+		fmt.Printf("[")
+	} else {
+		fmt.Printf(",\n")
 	}
-	if count > 0 {
+	p.count++
+	fmt.Print(item)
+}
+
+func (p *jsonListPrinter) close() {
+	if p.count > 0 {
 		fmt.Printf("]\n")
 	} else {
 		fmt.Printf("[]\n")
 	}
-	return nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func formatObservation(obs *nwpd.Observation) string {
+	t := formatTime(obs.Timestamp.AsTime())
+	dur := ""
+	if obs.Duration != nil {
+		dur = fmt.Sprintf(`,"duration": "%dms"`, obs.Duration.AsDuration().Milliseconds())
+	}
+	if obs.UdpDuration != nil {
+		dur += fmt.Sprintf(`, "udpDuration": "%dms"`, obs.UdpDuration.AsDuration().Milliseconds())
+	}
+	if obs.TcpDuration != nil {
+		dur += fmt.Sprintf(`, "tcpDuration": "%dms"`, obs.TcpDuration.AsDuration().Milliseconds())
+	}
+	destination := ""
+	if obs.DestAddress != "" {
+		destination = fmt.Sprintf(`, "destAddress": %q`, obs.DestAddress)
+	}
+	if obs.Protocol != "" {
+		destination += fmt.Sprintf(`, "protocol": %q`, obs.Protocol)
+	}
+	if obs.DestIP != "" {
+		destination += fmt.Sprintf(`, "destIP": %q`, obs.DestIP)
+	}
+	if obs.DestPort != 0 {
+		destination += fmt.Sprintf(`, "destPort": %d`, obs.DestPort)
+	}
+	if obs.SrcPort != 0 {
+		destination += fmt.Sprintf(`, "srcPort": %d`, obs.SrcPort)
+	}
+	if obs.ListSource != "" {
+		destination += fmt.Sprintf(`, "listSource": %q, "listVersion": %q`, obs.ListSource, obs.ListVersion)
+	}
+	if obs.Netns != "" {
+		destination += fmt.Sprintf(`, "netns": %q`, obs.Netns)
+	}
+	if obs.Interface != "" {
+		destination += fmt.Sprintf(`, "interface": %q`, obs.Interface)
+	}
+	if obs.DestAddressType != "" {
+		destination += fmt.Sprintf(`, "destAddressType": %q`, obs.DestAddressType)
+	}
+	triggeredBy := ""
+	if obs.TriggeredBy != "" {
+		triggeredBy = fmt.Sprintf(`, "triggeredBy": %q`, obs.TriggeredBy)
+	}
+	if obs.Maintenance {
+		triggeredBy += `, "maintenance": true`
+	}
+	if obs.LatencyBreach {
+		triggeredBy += `, "latencyBreach": true`
+	}
+	if obs.WarmingUp {
+		triggeredBy += `, "warmingUp": true`
+	}
+	if obs.Protocol == nwpd.ProtocolNetStats {
+		// the result contains the deltas of the counters
+		triggeredBy += fmt.Sprintf(`, "result": %q`, obs.Result)
+	}
+	return fmt.Sprintf("{%q: %q, %q: %q, %q: %q%s, %q: %q%s, %q: %t%s}", "time", t, "src", obs.SrcHost, "dest", obs.DestHost, destination,
+		"jobID", obs.JobID, dur, "ok", obs.Ok, triggeredBy)
+}
+
+// formatObservationWithNetStats adds the samples of the kernel network counters to the formatted observation.
+func formatObservationWithNetStats(obs *nwpd.Observation, samples []*nwpd.Observation) string {
+	formatted := formatObservation(obs)
+	if len(samples) == 0 {
+		return formatted
+	}
+	var items []string
+	for _, sample := range samples {
+		items = append(items, fmt.Sprintf("{%q: %q, %q: %q, %q: %t, %q: %q}", "time", formatTime(sample.Timestamp.AsTime()),
+			"dest", sample.DestHost, "ok", sample.Ok, "result", sample.Result))
+	}
+	return fmt.Sprintf("%s, %q: [%s]}", strings.TrimSuffix(formatted, "}"), "netStats", strings.Join(items, ", "))
+}
+
+func formatNodeEvent(event config.NodeEvent) string {
+	return fmt.Sprintf("{%q: %q, %q: %q, %q: %q, %q: %q, %q: %q}", "time", formatTime(event.Time), "node", event.Node,
+		"event", event.Type, "old", event.Old, "new", event.New)
 }
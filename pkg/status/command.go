@@ -0,0 +1,301 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package status
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gardener/network-problem-detector/pkg/common"
+	"github.com/gardener/network-problem-detector/pkg/common/config"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+type statusCommand struct {
+	common.ClientsetBase
+	prometheusURL  string
+	staleThreshold time.Duration
+	workers        int
+	timeout        time.Duration
+}
+
+// agentGeneration is the config generation reported by a single agent.
+type agentGeneration struct {
+	pod        string
+	node       string
+	generation string
+	err        error
+}
+
+// statusReport summarizes the config generations of all agents.
+type statusReport struct {
+	expected string
+	counts   map[string]int
+	stale    []agentGeneration
+	failed   []agentGeneration
+}
+
+func CreateStatusCmd() *cobra.Command {
+	sc := &statusCommand{}
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "show the config generations of the agents",
+		Long: `show how many agents run which generation of the agent config and which agents are stale.
+The generations are read from the metric ` + common.MetricConfigGenerationInfo + ` of all agent pods via the API server proxy
+or from Prometheus if a Prometheus URL is given.
+An agent is stale if it does not run the generation of the config map although the config map was updated longer ago than the stale threshold.
+The command fails if any agent is stale.`,
+		RunE: sc.status,
+	}
+	sc.AddKubeConfigFlag(cmd.Flags())
+	cmd.Flags().StringVar(&sc.prometheusURL, "prometheus-url", "", "optional URL of Prometheus to query the config generations instead of the agents")
+	cmd.Flags().DurationVar(&sc.staleThreshold, "stale-threshold", 5*time.Minute, "agents not running the current generation are stale if the config map was updated longer ago than this duration")
+	cmd.Flags().IntVar(&sc.workers, "workers", 10, "number of agents to query in parallel")
+	cmd.Flags().DurationVar(&sc.timeout, "timeout", 10*time.Second, "timeout for querying a single agent or Prometheus")
+	return cmd
+}
+
+func (sc *statusCommand) status(_ *cobra.Command, _ []string) error {
+	log := logrus.WithField("cmd", "status")
+
+	if err := sc.SetupClientSet(); err != nil {
+		return err
+	}
+	cm, err := common.LoadAgentConfigMap(context.Background(), sc.Clientset.CoreV1().ConfigMaps(common.NamespaceKubeSystem))
+	if err != nil {
+		return fmt.Errorf("loading agent config map failed: %w", err)
+	}
+	agentConfig := &config.AgentConfig{}
+	if err := yaml.Unmarshal([]byte(cm.Data[common.AgentConfigFilename]), agentConfig); err != nil {
+		return fmt.Errorf("parsing agent config failed: %w", err)
+	}
+	expected, err := agentConfig.GenerationOrComputed()
+	if err != nil {
+		return err
+	}
+	updated := configMapUpdateTime(cm)
+
+	var agents []agentGeneration
+	if sc.prometheusURL != "" {
+		agents, err = sc.queryPrometheus()
+	} else {
+		agents, err = sc.queryAgents(log)
+	}
+	if err != nil {
+		return err
+	}
+
+	report := buildReport(expected, updated, time.Now(), sc.staleThreshold, agents)
+	fmt.Printf("config map %s updated at %s with generation %s\n", cm.Name, updated.Format(time.RFC3339), expected)
+	report.print()
+	if len(report.stale) > 0 {
+		return fmt.Errorf("%d agents are stale", len(report.stale))
+	}
+	return nil
+}
+
+// configMapUpdateTime returns the time of the last update of the config map from the managed fields
+// or the creation timestamp as fallback.
+func configMapUpdateTime(cm *corev1.ConfigMap) time.Time {
+	updated := cm.CreationTimestamp.Time
+	for _, field := range cm.ManagedFields {
+		if field.Time != nil && field.Time.After(updated) {
+			updated = field.Time.Time
+		}
+	}
+	return updated
+}
+
+// queryAgents reads the config generation from the metrics endpoint of all agent pods using the API server proxy.
+func (sc *statusCommand) queryAgents(log logrus.FieldLogger) ([]agentGeneration, error) {
+	pods, err := sc.Clientset.CoreV1().Pods(common.NamespaceKubeSystem).List(context.Background(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s in (%s,%s)", common.LabelKeyK8sApp, common.NameDaemonSetAgentPodNet, common.NameDaemonSetAgentHostNet),
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("querying %d agent pods", len(pods.Items))
+
+	workers := sc.workers
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]agentGeneration, len(pods.Items))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indices {
+				results[idx] = sc.queryAgent(&pods.Items[idx])
+			}
+		}()
+	}
+	for i := range pods.Items {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	return results, nil
+}
+
+func (sc *statusCommand) queryAgent(pod *corev1.Pod) agentGeneration {
+	result := agentGeneration{pod: pod.Name, node: pod.Spec.NodeName}
+	if pod.Status.Phase != corev1.PodRunning {
+		result.err = fmt.Errorf("pod is %s", pod.Status.Phase)
+		return result
+	}
+	port := common.PodNetPodHttpPort
+	if pod.Spec.HostNetwork {
+		port = common.HostNetPodHttpPort
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, p := range container.Ports {
+			if p.Name == common.PortNameMetrics {
+				port = int(p.ContainerPort)
+			}
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sc.timeout)
+	defer cancel()
+	data, err := sc.Clientset.CoreV1().Pods(pod.Namespace).ProxyGet("http", pod.Name, strconv.Itoa(port), "/metrics", nil).DoRaw(ctx)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.generation, result.err = parseGeneration(bytes.NewReader(data))
+	return result
+}
+
+// parseGeneration returns the config generation from the metrics in Prometheus text format.
+func parseGeneration(in io.Reader) (string, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(in)
+	if err != nil {
+		return "", err
+	}
+	family, ok := families[common.MetricConfigGenerationInfo]
+	if !ok || len(family.Metric) == 0 {
+		return "", fmt.Errorf("metric %s not found", common.MetricConfigGenerationInfo)
+	}
+	for _, label := range family.Metric[0].Label {
+		if label.GetName() == "hash" {
+			return label.GetValue(), nil
+		}
+	}
+	return "", fmt.Errorf("metric %s without label hash", common.MetricConfigGenerationInfo)
+}
+
+// queryPrometheus reads the config generations of the agents from Prometheus.
+func (sc *statusCommand) queryPrometheus() ([]agentGeneration, error) {
+	u, err := url.Parse(strings.TrimSuffix(sc.prometheusURL, "/") + "/api/v1/query")
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = url.Values{"query": {common.MetricConfigGenerationInfo}}.Encode()
+	client := &http.Client{Timeout: sc.timeout}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("querying Prometheus failed with status %s", resp.Status)
+	}
+	return parsePrometheusResponse(resp.Body)
+}
+
+// parsePrometheusResponse extracts the config generations from the response of an instant query.
+// The agents are identified by the labels 'pod' and 'node' added by the scrape configuration, with the label 'instance' as fallback.
+func parsePrometheusResponse(in io.Reader) ([]agentGeneration, error) {
+	var response struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			Result []struct {
+				Metric map[string]string `json:"metric"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(in).Decode(&response); err != nil {
+		return nil, err
+	}
+	if response.Status != "success" {
+		return nil, fmt.Errorf("querying Prometheus failed: %s", response.Error)
+	}
+	var agents []agentGeneration
+	for _, r := range response.Data.Result {
+		pod := r.Metric["pod"]
+		if pod == "" {
+			pod = r.Metric["instance"]
+		}
+		agents = append(agents, agentGeneration{pod: pod, node: r.Metric["node"], generation: r.Metric["hash"]})
+	}
+	return agents, nil
+}
+
+// buildReport counts the agents per generation. Agents not running the expected generation are stale if the
+// config was updated longer ago than the threshold.
+func buildReport(expected string, updated, now time.Time, threshold time.Duration, agents []agentGeneration) *statusReport {
+	report := &statusReport{expected: expected, counts: map[string]int{}}
+	for _, agent := range agents {
+		if agent.err != nil {
+			report.failed = append(report.failed, agent)
+			continue
+		}
+		report.counts[agent.generation]++
+		if agent.generation != expected && now.Sub(updated) > threshold {
+			report.stale = append(report.stale, agent)
+		}
+	}
+	sort.Slice(report.stale, func(i, j int) bool { return report.stale[i].pod < report.stale[j].pod })
+	sort.Slice(report.failed, func(i, j int) bool { return report.failed[i].pod < report.failed[j].pod })
+	return report
+}
+
+func (r *statusReport) print() {
+	var generations []string
+	for generation := range r.counts {
+		generations = append(generations, generation)
+	}
+	sort.Strings(generations)
+	fmt.Printf("%-16s %6s\n", "GENERATION", "AGENTS")
+	for _, generation := range generations {
+		current := ""
+		if generation == r.expected {
+			current = " (current)"
+		}
+		fmt.Printf("%-16s %6d%s\n", generation, r.counts[generation], current)
+	}
+	if len(r.stale) > 0 {
+		fmt.Printf("\nstale agents:\n")
+		for _, agent := range r.stale {
+			fmt.Printf("  %s (node %s): %s\n", agent.pod, agent.node, agent.generation)
+		}
+	}
+	if len(r.failed) > 0 {
+		fmt.Printf("\nagents failed to query:\n")
+		for _, agent := range r.failed {
+			fmt.Printf("  %s (node %s): %s\n", agent.pod, agent.node, agent.err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2022 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package status
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGeneration(t *testing.T) {
+	generation, err := parseGeneration(strings.NewReader(`# HELP nwpd_config_generation_info Generation of the loaded agent config
+# TYPE nwpd_config_generation_info gauge
+nwpd_config_generation_info{hash="0123456789ab"} 1
+# TYPE nwpd_aggregated_observations counter
+nwpd_aggregated_observations{dest="b",jobid="x",src="a",status="ok"} 3
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789ab", generation)
+
+	_, err = parseGeneration(strings.NewReader("other_metric 1\n"))
+	assert.Error(t, err)
+}
+
+func TestParsePrometheusResponse(t *testing.T) {
+	agents, err := parsePrometheusResponse(strings.NewReader(`{"status":"success","data":{"resultType":"vector","result":[
+{"metric":{"__name__":"nwpd_config_generation_info","hash":"aaa","pod":"nwpd-pod-1","node":"node1"},"value":[1660000000,"1"]},
+{"metric":{"__name__":"nwpd_config_generation_info","hash":"bbb","instance":"10.0.0.2:1012"},"value":[1660000000,"1"]}]}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []agentGeneration{
+		{pod: "nwpd-pod-1", node: "node1", generation: "aaa"},
+		{pod: "10.0.0.2:1012", generation: "bbb"},
+	}, agents)
+
+	_, err = parsePrometheusResponse(strings.NewReader(`{"status":"error","error":"bad query"}`))
+	assert.Error(t, err)
+}
+
+func TestBuildReport(t *testing.T) {
+	updated := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	agents := []agentGeneration{
+		{pod: "pod-c", generation: "new"},
+		{pod: "pod-b", generation: "old"},
+		{pod: "pod-a", generation: "new"},
+		{pod: "pod-d", err: fmt.Errorf("timeout")},
+	}
+
+	report := buildReport("new", updated, updated.Add(1*time.Minute), 5*time.Minute, agents)
+	assert.Equal(t, map[string]int{"new": 2, "old": 1}, report.counts)
+	assert.Empty(t, report.stale, "rollout still in progress")
+	assert.Len(t, report.failed, 1)
+
+	report = buildReport("new", updated, updated.Add(10*time.Minute), 5*time.Minute, agents)
+	assert.Len(t, report.stale, 1)
+	assert.Equal(t, "pod-b", report.stale[0].pod)
+}